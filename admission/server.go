@@ -0,0 +1,254 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	admissionregistrationclient "k8s.io/client-go/kubernetes/typed/admissionregistration/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// Options configures the admission webhook server.
+type Options struct {
+	// Addr is the address to serve admission webhooks on.
+	// Admission webhooks are disabled if it's empty.
+	Addr string
+	// CertFile and KeyFile hold the TLS serving certificate.
+	CertFile, KeyFile string
+	// Service is the Service through which the API server reaches Addr.
+	Service types.NamespacedName
+	// CABundle is the PEM encoded CA bundle the API server uses to verify
+	// the serving certificate.
+	CABundle []byte
+}
+
+// Handler decides whether an admission request is allowed.
+type Handler func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// Webhook describes a ValidatingWebhookConfiguration managed on behalf of
+// a controller.
+type Webhook struct {
+	// Name identifies the webhook. It's used to name the configuration,
+	// and as the path the API server calls.
+	Name string
+	// Owner is the controller the configuration is garbage collected with.
+	Owner metav1.OwnerReference
+	// Rules selects the requests sent to the webhook.
+	Rules []admissionregistrationv1.RuleWithOperations
+}
+
+func (w *Webhook) configName() string {
+	return "metacontroller-" + w.Name
+}
+
+func (w *Webhook) path() string {
+	return "/validate/" + w.Name
+}
+
+// Server serves admission webhooks for controllers with validate hooks,
+// and manages the ValidatingWebhookConfigurations that route requests to it.
+type Server struct {
+	options Options
+	client  admissionregistrationclient.ValidatingWebhookConfigurationInterface
+	srv     *http.Server
+
+	mutex    sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewServer(config *rest.Config, options Options) (*Server, error) {
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("can't create client for admission webhooks: %v", err)
+	}
+	s := &Server{
+		options:  options,
+		client:   clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations(),
+		handlers: make(map[string]Handler),
+	}
+	s.srv = &http.Server{
+		Addr:    options.Addr,
+		Handler: s,
+	}
+	return s, nil
+}
+
+// Start serves admission webhooks in the background.
+func (s *Server) Start() {
+	go func() {
+		klog.InfoS("Starting admission webhook server", "addr", s.options.Addr)
+		if err := s.srv.ListenAndServeTLS(s.options.CertFile, s.options.KeyFile); err != http.ErrServerClosed {
+			klog.ErrorS(err, "Error serving admission webhooks")
+		}
+	}()
+}
+
+// Stop stops serving admission webhooks. The configurations are left in
+// place, so requests keep being rejected while metacontroller is down.
+func (s *Server) Stop() {
+	s.srv.Close()
+}
+
+// Register handles requests for the webhook with handler.
+// Ensure must be called first for the API server to send any.
+func (s *Server) Register(webhook *Webhook, handler Handler) {
+	s.mutex.Lock()
+	s.handlers[webhook.path()] = handler
+	s.mutex.Unlock()
+}
+
+// Unregister stops handling requests for the webhook.
+// It leaves the configuration in place, so it can be registered again.
+func (s *Server) Unregister(webhook *Webhook) {
+	s.mutex.Lock()
+	delete(s.handlers, webhook.path())
+	s.mutex.Unlock()
+}
+
+// Delete removes the configuration of the webhook, if any.
+func (s *Server) Delete(webhook *Webhook) error {
+	s.Unregister(webhook)
+	err := s.client.Delete(webhook.configName(), &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("can't delete ValidatingWebhookConfiguration %v: %v", webhook.configName(), err)
+	}
+	return nil
+}
+
+// Ensure creates or updates the configuration that makes the API server
+// send requests matching the webhook to us.
+func (s *Server) Ensure(webhook *Webhook) error {
+	path := webhook.path()
+	failurePolicy := admissionregistrationv1.Fail
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	desired := []admissionregistrationv1.ValidatingWebhook{
+		{
+			Name: webhook.Name + ".metacontroller.k8s.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{
+					Namespace: s.options.Service.Namespace,
+					Name:      s.options.Service.Name,
+					Path:      &path,
+				},
+				CABundle: s.options.CABundle,
+			},
+			Rules:                   webhook.Rules,
+			FailurePolicy:           &failurePolicy,
+			SideEffects:             &sideEffects,
+			AdmissionReviewVersions: []string{"v1"},
+		},
+	}
+
+	config, err := s.client.Get(webhook.configName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		config = &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            webhook.configName(),
+				OwnerReferences: []metav1.OwnerReference{webhook.Owner},
+			},
+			Webhooks: desired,
+		}
+		klog.InfoS("Creating ValidatingWebhookConfiguration", "name", config.Name)
+		if _, err := s.client.Create(config); err != nil {
+			return fmt.Errorf("can't create ValidatingWebhookConfiguration %v: %v", config.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can't get ValidatingWebhookConfiguration %v: %v", webhook.configName(), err)
+	}
+
+	// Compare with what we'd get back from the API server, with defaults.
+	updated := config.DeepCopy()
+	updated.OwnerReferences = []metav1.OwnerReference{webhook.Owner}
+	updated.Webhooks = desired
+	for i := range updated.Webhooks {
+		if i < len(config.Webhooks) {
+			updated.Webhooks[i].MatchPolicy = config.Webhooks[i].MatchPolicy
+			updated.Webhooks[i].NamespaceSelector = config.Webhooks[i].NamespaceSelector
+			updated.Webhooks[i].ObjectSelector = config.Webhooks[i].ObjectSelector
+			updated.Webhooks[i].TimeoutSeconds = config.Webhooks[i].TimeoutSeconds
+			// A config that was changed to call a URL has no Service, and
+			// needs to be pointed back at us.
+			if service := config.Webhooks[i].ClientConfig.Service; service != nil {
+				updated.Webhooks[i].ClientConfig.Service.Port = service.Port
+			}
+		}
+	}
+	if reflect.DeepEqual(updated, config) {
+		return nil
+	}
+	klog.InfoS("Updating ValidatingWebhookConfiguration", "name", config.Name)
+	if _, err := s.client.Update(updated); err != nil {
+		return fmt.Errorf("can't update ValidatingWebhookConfiguration %v: %v", config.Name, err)
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "expected POST", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("can't read request: %v", err), http.StatusBadRequest)
+		return
+	}
+	review := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+		http.Error(w, "expected an AdmissionReview request", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.RLock()
+	handler := s.handlers[r.URL.Path]
+	s.mutex.RUnlock()
+
+	var response *admissionv1.AdmissionResponse
+	if handler == nil {
+		// The controller isn't running (yet), so we can't tell.
+		response = Denied(fmt.Sprintf("no controller is running for %v", r.URL.Path))
+	} else {
+		response = handler(review.Request)
+	}
+	response.UID = review.Request.UID
+
+	review.Request = nil
+	review.Response = response
+	review.APIVersion = admissionv1.SchemeGroupVersion.String()
+	review.Kind = "AdmissionReview"
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		klog.ErrorS(err, "Error writing admission response")
+	}
+}
+
+// Allowed returns a response that allows the request.
+func Allowed() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+// Denied returns a response that rejects the request with the given message.
+func Denied(message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: message,
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		},
+	}
+}
@@ -0,0 +1,158 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestServeHTTP(t *testing.T) {
+	webhook := &Webhook{Name: "compositecontroller-test"}
+	s := &Server{handlers: make(map[string]Handler)}
+	s.handlers[webhook.path()] = func(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		if request.Name == "bad" {
+			return Denied("bad name")
+		}
+		return Allowed()
+	}
+
+	table := []struct {
+		path    string
+		name    string
+		allowed bool
+	}{
+		{path: "/validate/compositecontroller-test", name: "good", allowed: true},
+		{path: "/validate/compositecontroller-test", name: "bad", allowed: false},
+		{path: "/validate/compositecontroller-other", name: "good", allowed: false},
+	}
+
+	for _, tc := range table {
+		body, err := json.Marshal(&admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{UID: types.UID("uid-" + tc.name), Name: tc.name},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tc.path, bytes.NewReader(body)))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("%v %v: status = %v, want %v", tc.path, tc.name, recorder.Code, http.StatusOK)
+			continue
+		}
+
+		var review admissionv1.AdmissionReview
+		if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+			t.Fatal(err)
+		}
+		if review.Response == nil {
+			t.Errorf("%v %v: expected a response", tc.path, tc.name)
+			continue
+		}
+		if got, want := review.Response.UID, types.UID("uid-"+tc.name); got != want {
+			t.Errorf("%v %v: UID = %v, want %v", tc.path, tc.name, got, want)
+		}
+		if got := review.Response.Allowed; got != tc.allowed {
+			t.Errorf("%v %v: Allowed = %v, want %v", tc.path, tc.name, got, tc.allowed)
+		}
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	webhook := &Webhook{Name: "compositecontroller-test"}
+	service := types.NamespacedName{Namespace: "metacontroller", Name: "metacontroller-admission"}
+	path := webhook.path()
+	port := int32(443)
+	url := "https://example.com/validate"
+	failurePolicy := admissionregistrationv1.Fail
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	newConfig := func(clientConfig admissionregistrationv1.WebhookClientConfig) *admissionregistrationv1.ValidatingWebhookConfiguration {
+		return &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            webhook.configName(),
+				OwnerReferences: []metav1.OwnerReference{webhook.Owner},
+			},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+				Name:                    webhook.Name + ".metacontroller.k8s.io",
+				ClientConfig:            clientConfig,
+				FailurePolicy:           &failurePolicy,
+				SideEffects:             &sideEffects,
+				AdmissionReviewVersions: []string{"v1"},
+			}},
+		}
+	}
+	serviceConfig := admissionregistrationv1.WebhookClientConfig{
+		Service: &admissionregistrationv1.ServiceReference{
+			Namespace: service.Namespace,
+			Name:      service.Name,
+			Path:      &path,
+			Port:      &port,
+		},
+	}
+
+	table := []struct {
+		name       string
+		existing   *admissionregistrationv1.ValidatingWebhookConfiguration
+		wantAction string
+	}{
+		{
+			name:       "missing",
+			wantAction: "create",
+		},
+		{
+			name:     "up to date",
+			existing: newConfig(serviceConfig),
+		},
+		{
+			name:       "changed to a URL",
+			existing:   newConfig(admissionregistrationv1.WebhookClientConfig{URL: &url}),
+			wantAction: "update",
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if tc.existing != nil {
+				objects = append(objects, tc.existing)
+			}
+			clientSet := fake.NewSimpleClientset(objects...)
+			s := &Server{
+				options: Options{Service: service},
+				client:  clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations(),
+			}
+			if err := s.Ensure(webhook); err != nil {
+				t.Fatalf("Ensure() error: %v", err)
+			}
+
+			var writes []k8stesting.Action
+			for _, action := range clientSet.Actions() {
+				if action.GetVerb() != "get" {
+					writes = append(writes, action)
+				}
+			}
+			switch {
+			case tc.wantAction == "" && len(writes) != 0:
+				t.Errorf("Expected no writes, got %v", writes)
+			case tc.wantAction != "" && (len(writes) != 1 || writes[0].GetVerb() != tc.wantAction):
+				t.Errorf("Expected a single %v, got %v", tc.wantAction, writes)
+			}
+			config, err := s.client.Get(webhook.configName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Can't get config: %v", err)
+			}
+			if config.Webhooks[0].ClientConfig.Service == nil {
+				t.Error("Expected the config to point at the admission Service")
+			}
+		})
+	}
+}
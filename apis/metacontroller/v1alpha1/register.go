@@ -45,6 +45,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&CompositeControllerList{},
 		&DecoratorController{},
 		&DecoratorControllerList{},
+		&EventController{},
+		&EventControllerList{},
+		&StatusController{},
+		&StatusControllerList{},
+		&WatchController{},
+		&WatchControllerList{},
 		&ControllerRevision{},
 		&ControllerRevisionList{},
 	)
@@ -18,6 +18,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -43,16 +44,234 @@ func (cc *CompositeController) GetCustomizeHook() *Hook {
 	return cc.Spec.Hooks.Customize
 }
 
+func (cc *CompositeController) GetHookParameters() *HookParameters {
+	return &cc.Spec.HookParameters
+}
+
+// GetParentResources returns the parent resource, followed by the additional
+// parent resources.
+func (cc *CompositeController) GetParentResources() []CompositeControllerParentResourceRule {
+	return append([]CompositeControllerParentResourceRule{cc.Spec.ParentResource}, cc.Spec.AdditionalParentResources...)
+}
+
 type CompositeControllerSpec struct {
 	ParentResource CompositeControllerParentResourceRule  `json:"parentResource"`
 	ChildResources []CompositeControllerChildResourceRule `json:"childResources,omitempty"`
+	// AdditionalParentResources are synced by the same hooks as the parent
+	// resource, which can tell them apart by the kind of the parent.
+	AdditionalParentResources []CompositeControllerParentResourceRule `json:"additionalParentResources,omitempty"`
 
 	Hooks *CompositeControllerHooks `json:"hooks,omitempty"`
 
 	ResyncPeriodSeconds *int32 `json:"resyncPeriodSeconds,omitempty"`
 	GenerateSelector    *bool  `json:"generateSelector,omitempty"`
+	// InjectSelectorLabels adds the matchLabels of the parent selector to
+	// desired children that don't set those labels, so they aren't orphaned.
+	InjectSelectorLabels bool `json:"injectSelectorLabels,omitempty"`
+	// AdoptOnly adopts and updates existing children that match the parent
+	// selector, but never creates or deletes any.
+	AdoptOnly bool `json:"adoptOnly,omitempty"`
+
+	// Mode is whether the controller makes changes to children, or only
+	// reports the changes it would make. Defaults to Enforce.
+	Mode ControllerMode `json:"mode,omitempty"`
+
+	// DeletionProtection holds back the deletion of children that aren't
+	// desired anymore.
+	DeletionProtection *ChildDeletionProtection `json:"deletionProtection,omitempty"`
+
+	// FreshParentRead, if true, reads each parent from the API server right
+	// before it's synced, rather than from the informer cache, so hooks see
+	// the latest spec and resourceVersion even after rapid consecutive edits.
+	FreshParentRead bool `json:"freshParentRead,omitempty"`
+
+	// EventRateLimit overrides the default rate limits of events sent by the
+	// controller.
+	EventRateLimit *EventRateLimit `json:"eventRateLimit,omitempty"`
+
+	// EventSource overrides the source of events sent by the controller.
+	EventSource *EventSource `json:"eventSource,omitempty"`
+
+	// ClientRateLimit overrides the client-go QPS and burst of the API calls
+	// made by the controller, which then gets a client of its own.
+	ClientRateLimit *ClientRateLimit `json:"clientRateLimit,omitempty"`
+
+	// DryRunChildren, if true, validates desired children with a server-side
+	// dry-run before writing any of them.
+	DryRunChildren bool `json:"dryRunChildren,omitempty"`
+
+	// RollbackChildren, if true, undoes the creations and updates of
+	// children in a sync if writing any other child fails, and only deletes
+	// children once all others were written.
+	RollbackChildren bool `json:"rollbackChildren,omitempty"`
+
+	// ChildWriteParallelism is the number of children created, updated or
+	// deleted at the same time in a sync. By default, they're written one
+	// after the other.
+	ChildWriteParallelism int32 `json:"childWriteParallelism,omitempty"`
+
+	// StatusCoalesceSeconds is the minimum time between two status writes of
+	// a parent. Status changes in between are written together once it's
+	// over.
+	StatusCoalesceSeconds int32 `json:"statusCoalesceSeconds,omitempty"`
+
+	// ResyncSchedule is a cron expression, evaluated in UTC, on which every
+	// parent is resynced, in addition to any resyncPeriodSeconds.
+	ResyncSchedule string `json:"resyncSchedule,omitempty"`
+
+	// RevisionHistoryLimit is the maximum number of ControllerRevisions kept
+	// for each parent. Revisions that still own children are never pruned.
+	// Defaults to keeping only the revisions that are still in use.
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// RevisionSnapshots, if set, keeps some of the revisions beyond
+	// RevisionHistoryLimit as snapshots, spread out over time, instead of
+	// pruning them all.
+	RevisionSnapshots *RevisionSnapshots `json:"revisionSnapshots,omitempty"`
+
+	Finalizer *ControllerFinalizer `json:"finalizer,omitempty"`
+
+	ChildReadiness *ChildReadiness `json:"childReadiness,omitempty"`
+
+	// ChildApplyMode is how children are written. Defaults to ThreeWayMerge.
+	ChildApplyMode ChildApplyMode `json:"childApplyMode,omitempty"`
+
+	// SyncFailureAnnotations records how many times in a row the sync of a
+	// parent failed, and when it's retried, in annotations on the parent.
+	SyncFailureAnnotations bool `json:"syncFailureAnnotations,omitempty"`
+
+	// Inventory records the children of each parent in an annotation, so
+	// children of kinds that are later removed from ChildResources are
+	// deleted rather than left behind.
+	Inventory bool `json:"inventory,omitempty"`
+
+	// UIDMismatchPolicy is what to do with an object that has the name of a
+	// child but another UID than the child had, because it was deleted and
+	// recreated by someone else. Defaults to Adopt.
+	UIDMismatchPolicy UIDMismatchPolicy `json:"uidMismatchPolicy,omitempty"`
+
+	// ChildPatches are applied, in order, to the children returned by hooks.
+	ChildPatches []ChildPatch `json:"childPatches,omitempty"`
+
+	// PropagateMetadata copies labels and annotations of parents to all
+	// their children.
+	PropagateMetadata *MetadataPropagation `json:"propagateMetadata,omitempty"`
+
+	// Rollout stages later changes to this spec: the new spec only applies to
+	// a share of parents at first, which grows while their syncs succeed.
+	// Changes apply to all parents at once if unset.
+	Rollout *ControllerRollout `json:"rollout,omitempty"`
+
+	// DeletionPolicy is what happens to parents and children when the
+	// controller is deleted. Defaults to Retain.
+	DeletionPolicy *ControllerDeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// ChildNamespaces lets namespaced parents have children in other
+	// namespaces than their own, and restricts which namespaces children
+	// may be in. Children of namespaced parents must be in the parent's
+	// namespace if unset.
+	ChildNamespaces *ChildNamespacePolicy `json:"childNamespaces,omitempty"`
+
+	// HookParameters are passed to every hook of the controller.
+	HookParameters `json:",inline"`
+}
+
+// MetadataPropagation selects the labels and annotations of parents that are
+// copied to their children. Each entry is either a key, or a prefix followed
+// by "*" (e.g. "app.kubernetes.io/*").
+type MetadataPropagation struct {
+	Labels      []string `json:"labels,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
 }
 
+// ChildReadiness enables a readiness summary of all children, computed by
+// Metacontroller with the standard conventions for well-known kinds.
+// The summary is always sent to hooks when this is set.
+type ChildReadiness struct {
+	// StatusField, if set, is the name of the field in the parent's status
+	// that Metacontroller fills with the readiness summary.
+	StatusField string `json:"statusField,omitempty"`
+}
+
+// ChildPatch is a patch applied to the children returned by hooks, before
+// they're compared with observed children.
+type ChildPatch struct {
+	// Target selects the children the patch applies to.
+	// If unset, it applies to every child.
+	Target *ChildPatchTarget `json:"target,omitempty"`
+	// Patch is a JSON merge patch (an object) or a JSON patch (a list of
+	// operations), written in JSON or YAML.
+	Patch string `json:"patch,omitempty"`
+	// Images rewrites the images of containers, after Patch is applied.
+	Images []ChildImage `json:"images,omitempty"`
+}
+
+// ChildPatchTarget selects children by type, name and labels.
+// Empty fields match any child.
+type ChildPatchTarget struct {
+	APIVersion    string `json:"apiVersion,omitempty"`
+	Kind          string `json:"kind,omitempty"`
+	Name          string `json:"name,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// ChildImage rewrites the container images with the given name, ignoring
+// any tag or digest.
+type ChildImage struct {
+	Name string `json:"name"`
+	// NewName replaces the name, for example to use another registry.
+	NewName string `json:"newName,omitempty"`
+	// NewTag replaces the tag, or digest.
+	NewTag string `json:"newTag,omitempty"`
+	// Digest replaces the tag, or digest. It takes precedence over NewTag.
+	Digest string `json:"digest,omitempty"`
+}
+
+// ControllerFinalizer configures the finalizer a controller adds to parent
+// objects when it has a finalize hook.
+type ControllerFinalizer struct {
+	// Name overrides the default finalizer name, which is
+	// "metacontroller.io/<compositecontroller|decoratorcontroller>-<controller name>".
+	Name string `json:"name,omitempty"`
+	// PreviousNames lists finalizers the controller used before being renamed.
+	// They're recognized as belonging to the controller, and are replaced by
+	// Name as parents are synced.
+	PreviousNames []string `json:"previousNames,omitempty"`
+}
+
+// ControllerDeletionPolicy configures what happens to the parents and children
+// of a controller when the controller is deleted.
+type ControllerDeletionPolicy struct {
+	// Policy is Retain or Cleanup. Defaults to Retain.
+	Policy DeletionPolicy `json:"policy,omitempty"`
+	// DeleteChildren, with the Cleanup policy, also deletes the children of
+	// the controller, while their parents stay.
+	DeleteChildren bool `json:"deleteChildren,omitempty"`
+}
+
+// ChildNamespacePolicy configures which namespaces children may be in,
+// besides the namespace of their parent.
+type ChildNamespacePolicy struct {
+	// NamespaceSelector selects the namespaces children may be in. All
+	// namespaces are allowed if it's unset.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// DeletionPolicy is what happens to the parents and children of a controller
+// when the controller is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain leaves parents and children as they are. Parents
+	// keep the controller's finalizer, if it has a finalize hook, so they
+	// can't be deleted until it's removed by hand or the controller is
+	// recreated.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyCleanup removes the controller's finalizers from its
+	// parents and children before the controller goes away.
+	DeletionPolicyCleanup DeletionPolicy = "Cleanup"
+)
+
 type ResourceRule struct {
 	APIVersion string `json:"apiVersion"`
 	Resource   string `json:"resource"`
@@ -61,12 +280,45 @@ type ResourceRule struct {
 type CompositeControllerParentResourceRule struct {
 	ResourceRule    `json:",inline"`
 	RevisionHistory *CompositeControllerRevisionHistory `json:"revisionHistory,omitempty"`
+
+	// Scale maps parents to the scale subresource of their CRD, so they can
+	// be scaled with kubectl scale or a HorizontalPodAutoscaler.
+	Scale *CompositeControllerParentScale `json:"scale,omitempty"`
+
+	// Match, if set, is a CEL expression over the parent, as `object`, that
+	// must be true for the parent to be synced.
+	Match string `json:"match,omitempty"`
+}
+
+// CompositeControllerParentScale tells Metacontroller where the fields that
+// the scale subresource of a parent CRD reads and writes live in parents.
+// Paths are dot-separated, with an optional leading dot, as in the CRD.
+type CompositeControllerParentScale struct {
+	// SpecReplicasPath is the path of the desired number of replicas, which
+	// the scale subresource writes, such as ".spec.replicas".
+	SpecReplicasPath string `json:"specReplicasPath"`
+	// LabelSelectorPath, if set, is the path in the parent status where the
+	// parent selector is written as a string, such as ".status.selector".
+	LabelSelectorPath string `json:"labelSelectorPath,omitempty"`
 }
 
 type CompositeControllerRevisionHistory struct {
 	FieldPaths []string `json:"fieldPaths,omitempty"`
 }
 
+// RevisionSnapshots is how older revisions of parents are thinned out.
+type RevisionSnapshots struct {
+	// IntervalSeconds is the minimum time between the creation of two
+	// snapshots. Defaults to a day.
+	IntervalSeconds int32 `json:"intervalSeconds,omitempty"`
+	// Limit is the maximum number of snapshots kept for each parent.
+	// Defaults to 10.
+	Limit *int32 `json:"limit,omitempty"`
+	// MaxAgeSeconds, if set, prunes snapshots of revisions that were created
+	// longer ago than that.
+	MaxAgeSeconds int32 `json:"maxAgeSeconds,omitempty"`
+}
+
 type ChildUpdateMethod string
 
 const (
@@ -75,11 +327,203 @@ const (
 	ChildUpdateInPlace         ChildUpdateMethod = "InPlace"
 	ChildUpdateRollingRecreate ChildUpdateMethod = "RollingRecreate"
 	ChildUpdateRollingInPlace  ChildUpdateMethod = "RollingInPlace"
+	// ChildUpdateRecreateWhenFinished is like Recreate, but waits for the
+	// child to finish first, as Jobs do when they complete or fail.
+	ChildUpdateRecreateWhenFinished ChildUpdateMethod = "RecreateWhenFinished"
+)
+
+// ChildApplyMode is how desired children are written to the API server.
+type ChildApplyMode string
+
+const (
+	// ChildApplyThreeWayMerge merges desired children into existing ones in
+	// the style of "kubectl apply", keeping track of what was last applied
+	// in an annotation on each child.
+	ChildApplyThreeWayMerge ChildApplyMode = "ThreeWayMerge"
+	// ChildApplyServerSide uses server-side apply. Children that were
+	// previously written with ThreeWayMerge are migrated the first time
+	// they're updated.
+	ChildApplyServerSide ChildApplyMode = "ServerSideApply"
+)
+
+// UIDMismatchPolicy is what to do with an orphan that took the place of a
+// child, with the same name but another UID.
+type UIDMismatchPolicy string
+
+const (
+	// UIDMismatchAdopt adopts the object like any other orphan.
+	UIDMismatchAdopt UIDMismatchPolicy = "Adopt"
+	// UIDMismatchIgnore leaves the object alone, and doesn't create the
+	// child in its place for as long as it's there.
+	UIDMismatchIgnore UIDMismatchPolicy = "Ignore"
+	// UIDMismatchReplace deletes the object, so the child is recreated.
+	UIDMismatchReplace UIDMismatchPolicy = "Replace"
 )
 
+// ControllerMode is whether a controller acts on the children of its parents.
+// ControllerRollout stages changes to the spec of a controller across its
+// parents.
+type ControllerRollout struct {
+	// Steps are the percentages of parents synced with the new spec, in
+	// increasing order, before it applies to all of them. Defaults to 10
+	// and 50.
+	Steps []int32 `json:"steps,omitempty"`
+	// StepSeconds is how long each step lasts, at least. Defaults to 300.
+	StepSeconds *int32 `json:"stepSeconds,omitempty"`
+	// MaxFailingPercent is the percentage of parents synced with the new
+	// spec that may be failing at the end of a step for the rollout to move
+	// on. The rollout is paused at that step otherwise. Defaults to 0.
+	MaxFailingPercent *int32 `json:"maxFailingPercent,omitempty"`
+}
+
+type ControllerMode string
+
+const (
+	// ControllerModeEnforce makes the changes to children that hooks ask for.
+	ControllerModeEnforce ControllerMode = "Enforce"
+	// ControllerModeObserve calls hooks and updates the status of parents,
+	// but only reports the changes it would make to children, with events
+	// and metrics, so a new controller can be shadow-run safely.
+	ControllerModeObserve ControllerMode = "Observe"
+	// ControllerModeAudit creates and updates children, but neither adopts
+	// orphans nor deletes children. It reports those it would adopt or delete
+	// instead, so a controller can be enabled on an existing cluster safely.
+	ControllerModeAudit ControllerMode = "Audit"
+)
+
+// ChildDeletionProtection guards against hooks that wrongly stop returning
+// children, by holding back their deletion.
+type ChildDeletionProtection struct {
+	// MinSyncs is the number of consecutive syncs in which a child must not
+	// be desired before it's deleted.
+	MinSyncs int32 `json:"minSyncs,omitempty"`
+	// RequireConfirmation only deletes children annotated with
+	// metacontroller.k8s.io/confirm-deletion: "true".
+	RequireConfirmation bool `json:"requireConfirmation,omitempty"`
+	// MaxPerSync is the maximum number of children deleted in one sync of a
+	// parent. Other deletions are deferred to later syncs.
+	MaxPerSync int32 `json:"maxPerSync,omitempty"`
+	// MaxPerMinute is the maximum number of children the controller deletes
+	// per minute, across all parents.
+	MaxPerMinute int32 `json:"maxPerMinute,omitempty"`
+}
+
+// EventRateLimit limits the events a controller sends about each object,
+// separately from other controllers.
+type EventRateLimit struct {
+	// PeriodSeconds is how often one more event can be sent about an object,
+	// once the burst is used up.
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+	// Burst is the number of events that can be sent about an object at once.
+	Burst int32 `json:"burst,omitempty"`
+}
+
+// EventSource is the source events of a controller are attributed to.
+type EventSource struct {
+	// Component is the component the events come from, instead of
+	// metacontroller.
+	Component string `json:"component,omitempty"`
+	// Host is the host the events come from, if any.
+	Host string `json:"host,omitempty"`
+}
+
+// ClientRateLimit limits the API calls a controller makes, separately from
+// other controllers. Unset fields default to the client-go flags.
+type ClientRateLimit struct {
+	// QPS is the number of queries per second the controller can make, once
+	// the burst is used up.
+	QPS int32 `json:"qps,omitempty"`
+	// Burst is the number of queries the controller can make at once.
+	Burst int32 `json:"burst,omitempty"`
+
+	// Reads, Writes and Deletes, if set, give gets, lists and watches,
+	// creates, updates and patches, and deletes each a budget of their own,
+	// so they don't hold each other up. Others share the budget above.
+	// The lists and watches of the shared informers, including relists,
+	// aren't covered, since they're made on behalf of all controllers.
+	Reads   *ClientRateBudget `json:"reads,omitempty"`
+	Writes  *ClientRateBudget `json:"writes,omitempty"`
+	Deletes *ClientRateBudget `json:"deletes,omitempty"`
+}
+
+// ClientRateBudget limits one kind of API calls a controller makes. Unset
+// fields default to the ones of the ClientRateLimit.
+type ClientRateBudget struct {
+	QPS   int32 `json:"qps,omitempty"`
+	Burst int32 `json:"burst,omitempty"`
+}
+
 type CompositeControllerChildResourceRule struct {
 	ResourceRule   `json:",inline"`
 	UpdateStrategy *CompositeControllerChildUpdateStrategy `json:"updateStrategy,omitempty"`
+	// Finalize, if true, places the controller's finalizer on children of
+	// this type, so they aren't removed before a hook has seen them pending
+	// deletion. Requires a finalize hook.
+	Finalize *bool `json:"finalize,omitempty"`
+	// OwnerReference configures the owner reference that links children of
+	// this type to their parent.
+	OwnerReference *ChildOwnerReference `json:"ownerReference,omitempty"`
+	// BypassCache, if true, lists children of this type from the API server
+	// on every sync, rather than from the informer cache.
+	BypassCache bool `json:"bypassCache,omitempty"`
+	// Adopt claims pre-existing objects of this type that don't match the
+	// parent's selector, so they're adopted rather than recreated.
+	Adopt *ChildAdoption `json:"adopt,omitempty"`
+	// NamespaceTemplate is the namespace of the children of this type that
+	// don't set one, as a Go template evaluated with the parent as ".parent",
+	// such as "{{ .parent.spec.tenant }}-apps".
+	NamespaceTemplate string `json:"namespaceTemplate,omitempty"`
+	// Prune defaults to true. If false, children of this type are created
+	// and updated, but never deleted when they're no longer desired.
+	Prune *bool `json:"prune,omitempty"`
+	// DeleteStrategy configures how children of this type are deleted when
+	// they're no longer desired.
+	DeleteStrategy *ChildDeleteStrategy `json:"deleteStrategy,omitempty"`
+}
+
+// ChildDeleteMethod is how children are deleted.
+type ChildDeleteMethod string
+
+const (
+	// ChildDeleteMethodDelete deletes children right away.
+	ChildDeleteMethodDelete ChildDeleteMethod = "Delete"
+	// ChildDeleteMethodScaleDown sets spec.replicas of children to zero, and
+	// only deletes them once their status reports no replicas left, or the
+	// timeout is up.
+	ChildDeleteMethodScaleDown ChildDeleteMethod = "ScaleDown"
+)
+
+// ChildDeleteStrategy configures how children are deleted.
+type ChildDeleteStrategy struct {
+	// Method defaults to Delete.
+	Method ChildDeleteMethod `json:"method,omitempty"`
+	// TimeoutSeconds is how long ScaleDown waits for the replicas of a child
+	// to terminate before deleting it anyway. Defaults to 300.
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// ChildAdoption selects the pre-existing objects a parent adopts, besides the
+// ones that match its selector. Only objects without a controller are
+// adopted. Templates are Go templates evaluated with the parent as data, such
+// as "{{ .metadata.name }}-web".
+type ChildAdoption struct {
+	// NameTemplate is the name of the object to adopt.
+	NameTemplate string `json:"nameTemplate,omitempty"`
+	// MatchLabels are labels the objects to adopt must have. Values are
+	// templates.
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+// ChildOwnerReference configures the owner references set on children.
+type ChildOwnerReference struct {
+	// BlockOwnerDeletion defaults to true.
+	BlockOwnerDeletion *bool `json:"blockOwnerDeletion,omitempty"`
+	// Controller defaults to true. If false, children get a plain owner
+	// reference, so other controllers can manage them.
+	Controller *bool `json:"controller,omitempty"`
+	// LabelsOnly tracks children by the parent selector alone, without any
+	// owner reference, so they aren't garbage collected with their parent.
+	LabelsOnly bool `json:"labelsOnly,omitempty"`
 }
 
 type CompositeControllerChildUpdateStrategy struct {
@@ -108,13 +552,78 @@ type CompositeControllerHooks struct {
 	Customize *Hook `json:"customize,omitempty"`
 	Sync      *Hook `json:"sync,omitempty"`
 	Finalize  *Hook `json:"finalize,omitempty"`
+	// PostSync hooks are called in order after the sync or finalize hook,
+	// each with the response so far, which they may revise.
+	PostSync []Hook `json:"postSync,omitempty"`
 
 	PreUpdateChild  *Hook `json:"preUpdateChild,omitempty"`
 	PostUpdateChild *Hook `json:"postUpdateChild,omitempty"`
+
+	// Validate is called by the API server, through metacontroller, to admit
+	// or reject changes to parent objects.
+	Validate *Hook `json:"validate,omitempty"`
+
+	// Helm renders the children of each parent from a Helm chart, instead
+	// of calling a sync hook.
+	Helm *HelmChart `json:"helm,omitempty"`
+}
+
+// HelmChart selects a packaged Helm chart, and how to render it for a parent.
+type HelmChart struct {
+	Chart HelmChartSource `json:"chart"`
+	// Values are merged over the default values of the chart. Strings in
+	// values are Go templates, evaluated with the parent as .parent.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values *runtime.RawExtension `json:"values,omitempty"`
+	// ReleaseName is a Go template for the name of the release, evaluated
+	// with the parent as .parent. Defaults to the name of the parent.
+	ReleaseName string `json:"releaseName,omitempty"`
+}
+
+// HelmChartSource selects where a packaged chart is read from.
+// Exactly one of its fields must be set.
+type HelmChartSource struct {
+	// OCI references a chart in an OCI registry, such as
+	// registry.example.com/charts/redis:17.3.0.
+	OCI string `json:"oci,omitempty"`
+	// ConfigMapRef selects a binaryData key of a ConfigMap holding the chart.
+	ConfigMapRef *ConfigMapKeyReference `json:"configMapRef,omitempty"`
 }
 
 type Hook struct {
 	Webhook *Webhook `json:"webhook,omitempty"`
+	// OPAServer calls the data API of an external Open Policy Agent server
+	// instead of a webhook. The hook request is sent as the policy input,
+	// and the decision is used as the hook response.
+	OPAServer *Webhook `json:"opaServer,omitempty"`
+	// Starlark evaluates the hook in process, with a script embedded in the
+	// controller object.
+	Starlark *StarlarkHook `json:"starlark,omitempty"`
+
+	// Cache reuses the response of the hook for requests that are the same
+	// as a previous one, instead of calling the hook again.
+	Cache *HookCache `json:"cache,omitempty"`
+}
+
+type StarlarkHook struct {
+	// Script must define a function named hook, which is called with the
+	// hook request and returns the hook response.
+	Script string `json:"script"`
+
+	// MaxSteps limits the number of computation steps of each call.
+	// Defaults to 1000000.
+	MaxSteps *int64 `json:"maxSteps,omitempty"`
+	// MaxMemory limits how much the heap may grow during each call.
+	// Defaults to 64Mi.
+	MaxMemory *resource.Quantity `json:"maxMemory,omitempty"`
+	// Timeout limits the duration of each call. Defaults to 10s.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// HookCache configures the caching of hook responses.
+type HookCache struct {
+	// TTL is how long a response is reused for. Defaults to 5 minutes.
+	TTL *metav1.Duration `json:"ttl,omitempty"`
 }
 
 type Webhook struct {
@@ -123,9 +632,129 @@ type Webhook struct {
 
 	Path    *string           `json:"path,omitempty"`
 	Service *ServiceReference `json:"service,omitempty"`
+
+	// CABundle is a PEM encoded CA bundle used to verify the webhook's
+	// serving certificate, in addition to the system trust roots.
+	CABundle []byte `json:"caBundle,omitempty"`
+	// CABundleFrom references a Secret key holding a PEM encoded CA bundle.
+	// It may be used instead of (or along with) an inline CABundle.
+	CABundleFrom *SecretKeyReference `json:"caBundleFrom,omitempty"`
+
+	// Authorization configures the credentials sent with each webhook request.
+	Authorization *WebhookAuthorization `json:"authorization,omitempty"`
+
+	// MaxInFlight limits the number of concurrent calls to the webhook URL.
+	// Further calls wait for one of them to finish. Unlimited if unset.
+	MaxInFlight *int32 `json:"maxInFlight,omitempty"`
+
+	// AllowedURLOverrides lists the URLs that parents may route this webhook
+	// to with the metacontroller.k8s.io/hook-url annotation, such as a canary
+	// instance of the hook server. Parents can't override the URL otherwise.
+	AllowedURLOverrides []string `json:"allowedURLOverrides,omitempty"`
+
+	// Canary sends the calls made on behalf of a share of parents to another
+	// URL, such as a new version of the hook server.
+	Canary *WebhookCanary `json:"canary,omitempty"`
+}
+
+// WebhookCanary splits the calls to a webhook between its regular URL and a
+// canary URL. Each parent sticks to one of them as long as Weight is the
+// same.
+type WebhookCanary struct {
+	URL string `json:"url"`
+	// Weight is the percentage, from 0 to 100, of parents whose calls are sent
+	// to URL.
+	Weight int32 `json:"weight"`
+}
+
+// WebhookAuthorization specifies how to obtain the bearer token sent in the
+// Authorization header of webhook requests. At most one field may be set.
+type WebhookAuthorization struct {
+	// BearerTokenFrom references a Secret key holding a static bearer token.
+	BearerTokenFrom *SecretKeyReference `json:"bearerTokenFrom,omitempty"`
+	// OAuth2 obtains tokens using the OAuth2 client credentials flow.
+	OAuth2 *OAuth2ClientCredentials `json:"oauth2,omitempty"`
+	// ServiceAccountToken sends an audience-bound token for the ServiceAccount
+	// metacontroller runs as, which hook servers can verify with a TokenReview.
+	ServiceAccountToken *ServiceAccountTokenProjection `json:"serviceAccountToken,omitempty"`
+}
+
+// ServiceAccountTokenProjection configures the ServiceAccount tokens
+// requested on behalf of a hook.
+type ServiceAccountTokenProjection struct {
+	// Audience is the intended audience of the token. Hook servers should
+	// reject tokens that don't carry their own identifier as audience.
+	Audience string `json:"audience"`
+	// ExpirationSeconds is the requested lifetime of the token.
+	// Defaults to 1 hour.
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
+}
+
+// OAuth2ClientCredentials configures the OAuth2 client credentials flow.
+// Tokens are cached and refreshed automatically before they expire.
+type OAuth2ClientCredentials struct {
+	TokenURL         string             `json:"tokenURL"`
+	ClientID         string             `json:"clientID"`
+	ClientSecretFrom SecretKeyReference `json:"clientSecretFrom"`
+	Scopes           []string           `json:"scopes,omitempty"`
+}
+
+// SecretKeyReference selects a key of a Secret in a given namespace.
+type SecretKeyReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+}
+
+// ConfigMapKeyReference selects a key of a ConfigMap in a given namespace.
+type ConfigMapKeyReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+}
+
+// HookParameters configure the hooks of one controller, so that the same
+// hook server can back several controllers.
+type HookParameters struct {
+	// Parameters are passed as is to hooks. They override the parameters read
+	// from ParametersFrom.
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// ParametersFrom lists ConfigMaps and Secrets whose data is passed to
+	// hooks as parameters. Keys of later sources override earlier ones.
+	ParametersFrom []ParametersSource `json:"parametersFrom,omitempty"`
+}
+
+// ParametersSource selects a ConfigMap or a Secret holding hook parameters.
+// Exactly one of its fields must be set.
+type ParametersSource struct {
+	ConfigMapRef *ObjectReference `json:"configMapRef,omitempty"`
+	SecretRef    *ObjectReference `json:"secretRef,omitempty"`
+}
+
+// ObjectReference selects an object by name in a given namespace.
+type ObjectReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
 }
 
 type CompositeControllerStatus struct {
+	Conditions []ControllerCondition `json:"conditions,omitempty"`
+}
+
+const (
+	// ControllerConditionReady is true once the controller is started. It's
+	// false while the controller waits for its resources to be served.
+	ControllerConditionReady = "Ready"
+)
+
+// ControllerCondition is a condition of a CompositeController or a
+// DecoratorController.
+type ControllerCondition struct {
+	Type               string      `json:"type"`
+	Status             string      `json:"status"`
+	Reason             string      `json:"reason,omitempty"`
+	Message            string      `json:"message,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -144,8 +773,16 @@ type ControllerRevision struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata"`
 
-	ParentPatch runtime.RawExtension         `json:"parentPatch"`
-	Children    []ControllerRevisionChildren `json:"children,omitempty"`
+	ParentPatch runtime.RawExtension `json:"parentPatch"`
+	// ParentPatchData holds the gzip-compressed parentPatch, if it was too
+	// large to store as-is. In that case, ParentPatch is empty.
+	ParentPatchData []byte `json:"parentPatchData,omitempty"`
+	// ParentPatchChunks is the number of additional ControllerRevisions
+	// (chunks) holding the rest of ParentPatchData, if it was too large to fit
+	// into a single object.
+	ParentPatchChunks int32 `json:"parentPatchChunks,omitempty"`
+
+	Children []ControllerRevisionChildren `json:"children,omitempty"`
 }
 
 type ControllerRevisionChildren struct {
@@ -182,6 +819,10 @@ func (dc *DecoratorController) GetCustomizeHook() *Hook {
 	return dc.Spec.Hooks.Customize
 }
 
+func (dc *DecoratorController) GetHookParameters() *HookParameters {
+	return &dc.Spec.HookParameters
+}
+
 type DecoratorControllerSpec struct {
 	Resources   []DecoratorControllerResourceRule   `json:"resources"`
 	Attachments []DecoratorControllerAttachmentRule `json:"attachments,omitempty"`
@@ -189,12 +830,94 @@ type DecoratorControllerSpec struct {
 	Hooks *DecoratorControllerHooks `json:"hooks,omitempty"`
 
 	ResyncPeriodSeconds *int32 `json:"resyncPeriodSeconds,omitempty"`
+	// ResyncSchedule is a cron expression, evaluated in UTC, on which every
+	// matching object is resynced, in addition to any resyncPeriodSeconds.
+	ResyncSchedule string `json:"resyncSchedule,omitempty"`
+
+	Finalizer *ControllerFinalizer `json:"finalizer,omitempty"`
+
+	ChildReadiness *ChildReadiness `json:"childReadiness,omitempty"`
+
+	// ChildApplyMode is how attachments are written. Defaults to ThreeWayMerge.
+	ChildApplyMode ChildApplyMode `json:"childApplyMode,omitempty"`
+
+	// Mode is whether the controller makes changes to attachments, or only
+	// reports the changes it would make. Defaults to Enforce.
+	Mode ControllerMode `json:"mode,omitempty"`
+
+	// DeletionProtection holds back the deletion of attachments that aren't
+	// desired anymore.
+	DeletionProtection *ChildDeletionProtection `json:"deletionProtection,omitempty"`
+
+	// FreshParentRead, if true, reads each target object from the API server
+	// right before it's synced, rather than from the informer cache.
+	FreshParentRead bool `json:"freshParentRead,omitempty"`
+
+	// EventRateLimit overrides the default rate limits of events sent by the
+	// controller.
+	EventRateLimit *EventRateLimit `json:"eventRateLimit,omitempty"`
+
+	// EventSource overrides the source of events sent by the controller.
+	EventSource *EventSource `json:"eventSource,omitempty"`
+
+	// ClientRateLimit overrides the client-go QPS and burst of the API calls
+	// made by the controller, which then gets a client of its own.
+	ClientRateLimit *ClientRateLimit `json:"clientRateLimit,omitempty"`
+
+	// DryRunChildren, if true, validates desired attachments with a
+	// server-side dry-run before writing any of them.
+	DryRunChildren bool `json:"dryRunChildren,omitempty"`
+
+	// RollbackChildren, if true, undoes the creations and updates of
+	// attachments in a sync if writing any other attachment fails, and only
+	// deletes attachments once all others were written.
+	RollbackChildren bool `json:"rollbackChildren,omitempty"`
+
+	// ChildWriteParallelism is the number of attachments created, updated
+	// or deleted at the same time in a sync. By default, they're written one
+	// after the other.
+	ChildWriteParallelism int32 `json:"childWriteParallelism,omitempty"`
+
+	// StatusCoalesceSeconds is the minimum time between two status writes of
+	// a target object. Status changes in between are written together once
+	// it's over.
+	StatusCoalesceSeconds int32 `json:"statusCoalesceSeconds,omitempty"`
+
+	// SyncFailureAnnotations records how many times in a row the sync of a
+	// target object failed, and when it's retried, in annotations on it.
+	SyncFailureAnnotations bool `json:"syncFailureAnnotations,omitempty"`
+
+	// ChildPatches are applied, in order, to the attachments returned by hooks.
+	ChildPatches []ChildPatch `json:"childPatches,omitempty"`
+
+	// PropagateMetadata copies labels and annotations of target objects to
+	// all their attachments.
+	PropagateMetadata *MetadataPropagation `json:"propagateMetadata,omitempty"`
+
+	// DeletionPolicy is what happens to target objects and attachments when
+	// the controller is deleted. Defaults to Retain.
+	DeletionPolicy *ControllerDeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// HookParameters are passed to every hook of the controller.
+	HookParameters `json:",inline"`
 }
 
 type DecoratorControllerResourceRule struct {
 	ResourceRule       `json:",inline"`
 	LabelSelector      *metav1.LabelSelector `json:"labelSelector,omitempty"`
 	AnnotationSelector *AnnotationSelector   `json:"annotationSelector,omitempty"`
+	FieldSelector      *FieldSelector        `json:"fieldSelector,omitempty"`
+	// Match, if set, is a CEL expression over the object, as `object`, that
+	// must be true for the object to be selected.
+	Match string `json:"match,omitempty"`
+}
+
+// FieldSelector selects objects by the values of arbitrary fields, given as
+// dot-separated paths such as "metadata.namespace" or "spec.type".
+// Only fields with a string, boolean or number value can be matched.
+type FieldSelector struct {
+	MatchFields      map[string]string                 `json:"matchFields,omitempty"`
+	MatchExpressions []metav1.LabelSelectorRequirement `json:"matchExpressions,omitempty"`
 }
 
 type AnnotationSelector struct {
@@ -205,6 +928,13 @@ type AnnotationSelector struct {
 type DecoratorControllerAttachmentRule struct {
 	ResourceRule   `json:",inline"`
 	UpdateStrategy *DecoratorControllerAttachmentUpdateStrategy `json:"updateStrategy,omitempty"`
+	// Finalize, if true, places the controller's finalizer on attachments of
+	// this type, so they aren't removed before a hook has seen them pending
+	// deletion. Requires a finalize hook.
+	Finalize *bool `json:"finalize,omitempty"`
+	// BypassCache, if true, lists attachments of this type from the API
+	// server on every sync, rather than from the informer cache.
+	BypassCache bool `json:"bypassCache,omitempty"`
 }
 
 type DecoratorControllerAttachmentUpdateStrategy struct {
@@ -215,9 +945,13 @@ type DecoratorControllerHooks struct {
 	Customize *Hook `json:"customize,omitempty"`
 	Sync      *Hook `json:"sync,omitempty"`
 	Finalize  *Hook `json:"finalize,omitempty"`
+	// PostSync hooks are called in order after the sync or finalize hook,
+	// each with the response so far, which they may revise.
+	PostSync []Hook `json:"postSync,omitempty"`
 }
 
 type DecoratorControllerStatus struct {
+	Conditions []ControllerCondition `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -227,6 +961,170 @@ type DecoratorControllerList struct {
 	Items           []DecoratorController `json:"items"`
 }
 
+// +genclient
+// +genclient:noStatus
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=statuscontrollers,scope=Cluster,shortName=stc
+type StatusController struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   StatusControllerSpec   `json:"spec"`
+	Status StatusControllerStatus `json:"status,omitempty"`
+}
+
+func (sc *StatusController) GetCustomizeHook() *Hook {
+	if sc.Spec.Hooks == nil {
+		return nil
+	}
+	return sc.Spec.Hooks.Customize
+}
+
+func (sc *StatusController) GetHookParameters() *HookParameters {
+	return &sc.Spec.HookParameters
+}
+
+type StatusControllerSpec struct {
+	Resource StatusControllerResourceRule `json:"resource"`
+
+	Hooks *StatusControllerHooks `json:"hooks,omitempty"`
+
+	ResyncPeriodSeconds *int32 `json:"resyncPeriodSeconds,omitempty"`
+	// ResyncSchedule is a cron expression, evaluated in UTC, on which every
+	// object is resynced, in addition to any resyncPeriodSeconds.
+	ResyncSchedule string `json:"resyncSchedule,omitempty"`
+	// HookParameters are passed to every hook of the controller.
+	HookParameters `json:",inline"`
+}
+
+type StatusControllerResourceRule struct {
+	ResourceRule       `json:",inline"`
+	LabelSelector      *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	AnnotationSelector *AnnotationSelector   `json:"annotationSelector,omitempty"`
+}
+
+type StatusControllerHooks struct {
+	Customize *Hook `json:"customize,omitempty"`
+	Sync      *Hook `json:"sync,omitempty"`
+}
+
+type StatusControllerStatus struct {
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type StatusControllerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []StatusController `json:"items"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=watchcontrollers,scope=Cluster,shortName=wc
+type WatchController struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   WatchControllerSpec   `json:"spec"`
+	Status WatchControllerStatus `json:"status,omitempty"`
+}
+
+func (wc *WatchController) GetHookParameters() *HookParameters {
+	return &wc.Spec.HookParameters
+}
+
+type WatchControllerSpec struct {
+	Resource WatchControllerResourceRule `json:"resource"`
+
+	Hooks *WatchControllerHooks `json:"hooks,omitempty"`
+	// HookParameters are passed to every hook of the controller.
+	HookParameters `json:",inline"`
+}
+
+type WatchControllerResourceRule struct {
+	ResourceRule       `json:",inline"`
+	LabelSelector      *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	AnnotationSelector *AnnotationSelector   `json:"annotationSelector,omitempty"`
+}
+
+type WatchControllerHooks struct {
+	Notify *Hook `json:"notify,omitempty"`
+}
+
+type WatchControllerStatus struct {
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type WatchControllerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []WatchController `json:"items"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=eventcontrollers,scope=Cluster,shortName=evc
+type EventController struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec   EventControllerSpec   `json:"spec"`
+	Status EventControllerStatus `json:"status,omitempty"`
+}
+
+func (ec *EventController) GetHookParameters() *HookParameters {
+	return &ec.Spec.HookParameters
+}
+
+type EventControllerSpec struct {
+	Selector EventSelector `json:"selector,omitempty"`
+
+	Hooks *EventControllerHooks `json:"hooks,omitempty"`
+	// HookParameters are passed to every hook of the controller.
+	HookParameters `json:",inline"`
+}
+
+// EventSelector selects core Events. All fields are optional, and an
+// Event must match all of the fields that are set.
+type EventSelector struct {
+	// Namespace restricts Events to a single namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// Type is the Event type, such as Normal or Warning.
+	Type string `json:"type,omitempty"`
+	// Reasons is a list of Event reasons, such as OOMKilling or FailedScheduling.
+	// An Event matches if it has any of these reasons.
+	Reasons []string `json:"reasons,omitempty"`
+	// InvolvedObject restricts Events to those about objects of a given kind.
+	InvolvedObject *EventInvolvedObjectSelector `json:"involvedObject,omitempty"`
+}
+
+type EventInvolvedObjectSelector struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+}
+
+type EventControllerHooks struct {
+	Notify *Hook `json:"notify,omitempty"`
+}
+
+type EventControllerStatus struct {
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type EventControllerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []EventController `json:"items"`
+}
+
 type RelatedResourceRule struct {
 	ResourceRule          `json:",inline"`
 	*metav1.LabelSelector `json:"labelSelector"`
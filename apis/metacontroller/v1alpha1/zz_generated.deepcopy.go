@@ -55,6 +55,187 @@ func (in *AnnotationSelector) DeepCopy() *AnnotationSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildAdoption) DeepCopyInto(out *ChildAdoption) {
+	*out = *in
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildAdoption.
+func (in *ChildAdoption) DeepCopy() *ChildAdoption {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildAdoption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildDeleteStrategy) DeepCopyInto(out *ChildDeleteStrategy) {
+	*out = *in
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildDeleteStrategy.
+func (in *ChildDeleteStrategy) DeepCopy() *ChildDeleteStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildDeleteStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildDeletionProtection) DeepCopyInto(out *ChildDeletionProtection) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildDeletionProtection.
+func (in *ChildDeletionProtection) DeepCopy() *ChildDeletionProtection {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildDeletionProtection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildImage) DeepCopyInto(out *ChildImage) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildImage.
+func (in *ChildImage) DeepCopy() *ChildImage {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildNamespacePolicy) DeepCopyInto(out *ChildNamespacePolicy) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildNamespacePolicy.
+func (in *ChildNamespacePolicy) DeepCopy() *ChildNamespacePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildNamespacePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildOwnerReference) DeepCopyInto(out *ChildOwnerReference) {
+	*out = *in
+	if in.BlockOwnerDeletion != nil {
+		in, out := &in.BlockOwnerDeletion, &out.BlockOwnerDeletion
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Controller != nil {
+		in, out := &in.Controller, &out.Controller
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildOwnerReference.
+func (in *ChildOwnerReference) DeepCopy() *ChildOwnerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildOwnerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildPatch) DeepCopyInto(out *ChildPatch) {
+	*out = *in
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(ChildPatchTarget)
+		**out = **in
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]ChildImage, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildPatch.
+func (in *ChildPatch) DeepCopy() *ChildPatch {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildPatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildPatchTarget) DeepCopyInto(out *ChildPatchTarget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildPatchTarget.
+func (in *ChildPatchTarget) DeepCopy() *ChildPatchTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildPatchTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildReadiness) DeepCopyInto(out *ChildReadiness) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildReadiness.
+func (in *ChildReadiness) DeepCopy() *ChildReadiness {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildReadiness)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ChildUpdateStatusChecks) DeepCopyInto(out *ChildUpdateStatusChecks) {
 	*out = *in
@@ -78,13 +259,60 @@ func (in *ChildUpdateStatusChecks) DeepCopy() *ChildUpdateStatusChecks {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClientRateBudget) DeepCopyInto(out *ClientRateBudget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClientRateBudget.
+func (in *ClientRateBudget) DeepCopy() *ClientRateBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(ClientRateBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClientRateLimit) DeepCopyInto(out *ClientRateLimit) {
+	*out = *in
+	if in.Reads != nil {
+		in, out := &in.Reads, &out.Reads
+		*out = new(ClientRateBudget)
+		**out = **in
+	}
+	if in.Writes != nil {
+		in, out := &in.Writes, &out.Writes
+		*out = new(ClientRateBudget)
+		**out = **in
+	}
+	if in.Deletes != nil {
+		in, out := &in.Deletes, &out.Deletes
+		*out = new(ClientRateBudget)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClientRateLimit.
+func (in *ClientRateLimit) DeepCopy() *ClientRateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(ClientRateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CompositeController) DeepCopyInto(out *CompositeController) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -115,6 +343,31 @@ func (in *CompositeControllerChildResourceRule) DeepCopyInto(out *CompositeContr
 		*out = new(CompositeControllerChildUpdateStrategy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Finalize != nil {
+		in, out := &in.Finalize, &out.Finalize
+		*out = new(bool)
+		**out = **in
+	}
+	if in.OwnerReference != nil {
+		in, out := &in.OwnerReference, &out.OwnerReference
+		*out = new(ChildOwnerReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Adopt != nil {
+		in, out := &in.Adopt, &out.Adopt
+		*out = new(ChildAdoption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Prune != nil {
+		in, out := &in.Prune, &out.Prune
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DeleteStrategy != nil {
+		in, out := &in.DeleteStrategy, &out.DeleteStrategy
+		*out = new(ChildDeleteStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -163,6 +416,13 @@ func (in *CompositeControllerHooks) DeepCopyInto(out *CompositeControllerHooks)
 		*out = new(Hook)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PostSync != nil {
+		in, out := &in.PostSync, &out.PostSync
+		*out = make([]Hook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.PreUpdateChild != nil {
 		in, out := &in.PreUpdateChild, &out.PreUpdateChild
 		*out = new(Hook)
@@ -173,6 +433,16 @@ func (in *CompositeControllerHooks) DeepCopyInto(out *CompositeControllerHooks)
 		*out = new(Hook)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Validate != nil {
+		in, out := &in.Validate, &out.Validate
+		*out = new(Hook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Helm != nil {
+		in, out := &in.Helm, &out.Helm
+		*out = new(HelmChart)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -228,6 +498,11 @@ func (in *CompositeControllerParentResourceRule) DeepCopyInto(out *CompositeCont
 		*out = new(CompositeControllerRevisionHistory)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Scale != nil {
+		in, out := &in.Scale, &out.Scale
+		*out = new(CompositeControllerParentScale)
+		**out = **in
+	}
 	return
 }
 
@@ -241,6 +516,22 @@ func (in *CompositeControllerParentResourceRule) DeepCopy() *CompositeController
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CompositeControllerParentScale) DeepCopyInto(out *CompositeControllerParentScale) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CompositeControllerParentScale.
+func (in *CompositeControllerParentScale) DeepCopy() *CompositeControllerParentScale {
+	if in == nil {
+		return nil
+	}
+	out := new(CompositeControllerParentScale)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CompositeControllerRevisionHistory) DeepCopyInto(out *CompositeControllerRevisionHistory) {
 	*out = *in
@@ -273,6 +564,13 @@ func (in *CompositeControllerSpec) DeepCopyInto(out *CompositeControllerSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.AdditionalParentResources != nil {
+		in, out := &in.AdditionalParentResources, &out.AdditionalParentResources
+		*out = make([]CompositeControllerParentResourceRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Hooks != nil {
 		in, out := &in.Hooks, &out.Hooks
 		*out = new(CompositeControllerHooks)
@@ -288,6 +586,74 @@ func (in *CompositeControllerSpec) DeepCopyInto(out *CompositeControllerSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.DeletionProtection != nil {
+		in, out := &in.DeletionProtection, &out.DeletionProtection
+		*out = new(ChildDeletionProtection)
+		**out = **in
+	}
+	if in.EventRateLimit != nil {
+		in, out := &in.EventRateLimit, &out.EventRateLimit
+		*out = new(EventRateLimit)
+		**out = **in
+	}
+	if in.EventSource != nil {
+		in, out := &in.EventSource, &out.EventSource
+		*out = new(EventSource)
+		**out = **in
+	}
+	if in.ClientRateLimit != nil {
+		in, out := &in.ClientRateLimit, &out.ClientRateLimit
+		*out = new(ClientRateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RevisionSnapshots != nil {
+		in, out := &in.RevisionSnapshots, &out.RevisionSnapshots
+		*out = new(RevisionSnapshots)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Finalizer != nil {
+		in, out := &in.Finalizer, &out.Finalizer
+		*out = new(ControllerFinalizer)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ChildReadiness != nil {
+		in, out := &in.ChildReadiness, &out.ChildReadiness
+		*out = new(ChildReadiness)
+		**out = **in
+	}
+	if in.ChildPatches != nil {
+		in, out := &in.ChildPatches, &out.ChildPatches
+		*out = make([]ChildPatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PropagateMetadata != nil {
+		in, out := &in.PropagateMetadata, &out.PropagateMetadata
+		*out = new(MetadataPropagation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(ControllerRollout)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeletionPolicy != nil {
+		in, out := &in.DeletionPolicy, &out.DeletionPolicy
+		*out = new(ControllerDeletionPolicy)
+		**out = **in
+	}
+	if in.ChildNamespaces != nil {
+		in, out := &in.ChildNamespaces, &out.ChildNamespaces
+		*out = new(ChildNamespacePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	in.HookParameters.DeepCopyInto(&out.HookParameters)
 	return
 }
 
@@ -304,6 +670,13 @@ func (in *CompositeControllerSpec) DeepCopy() *CompositeControllerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CompositeControllerStatus) DeepCopyInto(out *CompositeControllerStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]ControllerCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -318,37 +691,112 @@ func (in *CompositeControllerStatus) DeepCopy() *CompositeControllerStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ControllerRevision) DeepCopyInto(out *ControllerRevision) {
+func (in *ConfigMapKeyReference) DeepCopyInto(out *ConfigMapKeyReference) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.ParentPatch.DeepCopyInto(&out.ParentPatch)
-	if in.Children != nil {
-		in, out := &in.Children, &out.Children
-		*out = make([]ControllerRevisionChildren, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerRevision.
-func (in *ControllerRevision) DeepCopy() *ControllerRevision {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyReference.
+func (in *ConfigMapKeyReference) DeepCopy() *ConfigMapKeyReference {
 	if in == nil {
 		return nil
 	}
-	out := new(ControllerRevision)
+	out := new(ConfigMapKeyReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ControllerRevision) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerCondition) DeepCopyInto(out *ControllerCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerCondition.
+func (in *ControllerCondition) DeepCopy() *ControllerCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerDeletionPolicy) DeepCopyInto(out *ControllerDeletionPolicy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerDeletionPolicy.
+func (in *ControllerDeletionPolicy) DeepCopy() *ControllerDeletionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerDeletionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerFinalizer) DeepCopyInto(out *ControllerFinalizer) {
+	*out = *in
+	if in.PreviousNames != nil {
+		in, out := &in.PreviousNames, &out.PreviousNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerFinalizer.
+func (in *ControllerFinalizer) DeepCopy() *ControllerFinalizer {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerFinalizer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerRevision) DeepCopyInto(out *ControllerRevision) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.ParentPatch.DeepCopyInto(&out.ParentPatch)
+	if in.ParentPatchData != nil {
+		in, out := &in.ParentPatchData, &out.ParentPatchData
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.Children != nil {
+		in, out := &in.Children, &out.Children
+		*out = make([]ControllerRevisionChildren, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerRevision.
+func (in *ControllerRevision) DeepCopy() *ControllerRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ControllerRevision) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -405,13 +853,44 @@ func (in *ControllerRevisionList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerRollout) DeepCopyInto(out *ControllerRollout) {
+	*out = *in
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.StepSeconds != nil {
+		in, out := &in.StepSeconds, &out.StepSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxFailingPercent != nil {
+		in, out := &in.MaxFailingPercent, &out.MaxFailingPercent
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerRollout.
+func (in *ControllerRollout) DeepCopy() *ControllerRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DecoratorController) DeepCopyInto(out *DecoratorController) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -442,6 +921,11 @@ func (in *DecoratorControllerAttachmentRule) DeepCopyInto(out *DecoratorControll
 		*out = new(DecoratorControllerAttachmentUpdateStrategy)
 		**out = **in
 	}
+	if in.Finalize != nil {
+		in, out := &in.Finalize, &out.Finalize
+		*out = new(bool)
+		**out = **in
+	}
 	return
 }
 
@@ -489,6 +973,13 @@ func (in *DecoratorControllerHooks) DeepCopyInto(out *DecoratorControllerHooks)
 		*out = new(Hook)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PostSync != nil {
+		in, out := &in.PostSync, &out.PostSync
+		*out = make([]Hook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -549,6 +1040,11 @@ func (in *DecoratorControllerResourceRule) DeepCopyInto(out *DecoratorController
 		*out = new(AnnotationSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.FieldSelector != nil {
+		in, out := &in.FieldSelector, &out.FieldSelector
+		*out = new(FieldSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -589,6 +1085,54 @@ func (in *DecoratorControllerSpec) DeepCopyInto(out *DecoratorControllerSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.Finalizer != nil {
+		in, out := &in.Finalizer, &out.Finalizer
+		*out = new(ControllerFinalizer)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ChildReadiness != nil {
+		in, out := &in.ChildReadiness, &out.ChildReadiness
+		*out = new(ChildReadiness)
+		**out = **in
+	}
+	if in.DeletionProtection != nil {
+		in, out := &in.DeletionProtection, &out.DeletionProtection
+		*out = new(ChildDeletionProtection)
+		**out = **in
+	}
+	if in.EventRateLimit != nil {
+		in, out := &in.EventRateLimit, &out.EventRateLimit
+		*out = new(EventRateLimit)
+		**out = **in
+	}
+	if in.EventSource != nil {
+		in, out := &in.EventSource, &out.EventSource
+		*out = new(EventSource)
+		**out = **in
+	}
+	if in.ClientRateLimit != nil {
+		in, out := &in.ClientRateLimit, &out.ClientRateLimit
+		*out = new(ClientRateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ChildPatches != nil {
+		in, out := &in.ChildPatches, &out.ChildPatches
+		*out = make([]ChildPatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PropagateMetadata != nil {
+		in, out := &in.PropagateMetadata, &out.PropagateMetadata
+		*out = new(MetadataPropagation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeletionPolicy != nil {
+		in, out := &in.DeletionPolicy, &out.DeletionPolicy
+		*out = new(ControllerDeletionPolicy)
+		**out = **in
+	}
+	in.HookParameters.DeepCopyInto(&out.HookParameters)
 	return
 }
 
@@ -605,6 +1149,13 @@ func (in *DecoratorControllerSpec) DeepCopy() *DecoratorControllerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DecoratorControllerStatus) DeepCopyInto(out *DecoratorControllerStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]ControllerCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -619,149 +1170,998 @@ func (in *DecoratorControllerStatus) DeepCopy() *DecoratorControllerStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Hook) DeepCopyInto(out *Hook) {
+func (in *EventController) DeepCopyInto(out *EventController) {
 	*out = *in
-	if in.Webhook != nil {
-		in, out := &in.Webhook, &out.Webhook
-		*out = new(Webhook)
-		(*in).DeepCopyInto(*out)
-	}
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hook.
-func (in *Hook) DeepCopy() *Hook {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventController.
+func (in *EventController) DeepCopy() *EventController {
 	if in == nil {
 		return nil
 	}
-	out := new(Hook)
+	out := new(EventController)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EventController) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RelatedResourceRule) DeepCopyInto(out *RelatedResourceRule) {
+func (in *EventControllerHooks) DeepCopyInto(out *EventControllerHooks) {
 	*out = *in
-	out.ResourceRule = in.ResourceRule
-	if in.LabelSelector != nil {
-		in, out := &in.LabelSelector, &out.LabelSelector
-		*out = new(v1.LabelSelector)
+	if in.Notify != nil {
+		in, out := &in.Notify, &out.Notify
+		*out = new(Hook)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.Names != nil {
-		in, out := &in.Names, &out.Names
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RelatedResourceRule.
-func (in *RelatedResourceRule) DeepCopy() *RelatedResourceRule {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventControllerHooks.
+func (in *EventControllerHooks) DeepCopy() *EventControllerHooks {
 	if in == nil {
 		return nil
 	}
-	out := new(RelatedResourceRule)
+	out := new(EventControllerHooks)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceRule) DeepCopyInto(out *ResourceRule) {
+func (in *EventControllerList) DeepCopyInto(out *EventControllerList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EventController, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRule.
-func (in *ResourceRule) DeepCopy() *ResourceRule {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventControllerList.
+func (in *EventControllerList) DeepCopy() *EventControllerList {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceRule)
+	out := new(EventControllerList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EventControllerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ServiceReference) DeepCopyInto(out *ServiceReference) {
+func (in *EventControllerSpec) DeepCopyInto(out *EventControllerSpec) {
 	*out = *in
-	if in.Port != nil {
-		in, out := &in.Port, &out.Port
-		*out = new(int32)
-		**out = **in
-	}
-	if in.Protocol != nil {
-		in, out := &in.Protocol, &out.Protocol
-		*out = new(string)
-		**out = **in
+	in.Selector.DeepCopyInto(&out.Selector)
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(EventControllerHooks)
+		(*in).DeepCopyInto(*out)
 	}
+	in.HookParameters.DeepCopyInto(&out.HookParameters)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceReference.
-func (in *ServiceReference) DeepCopy() *ServiceReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventControllerSpec.
+func (in *EventControllerSpec) DeepCopy() *EventControllerSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ServiceReference)
+	out := new(EventControllerSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *StatusConditionCheck) DeepCopyInto(out *StatusConditionCheck) {
+func (in *EventControllerStatus) DeepCopyInto(out *EventControllerStatus) {
 	*out = *in
-	if in.Status != nil {
-		in, out := &in.Status, &out.Status
-		*out = new(string)
-		**out = **in
-	}
-	if in.Reason != nil {
-		in, out := &in.Reason, &out.Reason
-		*out = new(string)
-		**out = **in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventControllerStatus.
+func (in *EventControllerStatus) DeepCopy() *EventControllerStatus {
+	if in == nil {
+		return nil
 	}
+	out := new(EventControllerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventInvolvedObjectSelector) DeepCopyInto(out *EventInvolvedObjectSelector) {
+	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusConditionCheck.
-func (in *StatusConditionCheck) DeepCopy() *StatusConditionCheck {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventInvolvedObjectSelector.
+func (in *EventInvolvedObjectSelector) DeepCopy() *EventInvolvedObjectSelector {
 	if in == nil {
 		return nil
 	}
-	out := new(StatusConditionCheck)
+	out := new(EventInvolvedObjectSelector)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Webhook) DeepCopyInto(out *Webhook) {
+func (in *EventRateLimit) DeepCopyInto(out *EventRateLimit) {
 	*out = *in
-	if in.URL != nil {
-		in, out := &in.URL, &out.URL
-		*out = new(string)
-		**out = **in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventRateLimit.
+func (in *EventRateLimit) DeepCopy() *EventRateLimit {
+	if in == nil {
+		return nil
 	}
-	if in.Timeout != nil {
-		in, out := &in.Timeout, &out.Timeout
-		*out = new(v1.Duration)
-		**out = **in
+	out := new(EventRateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventSelector) DeepCopyInto(out *EventSelector) {
+	*out = *in
+	if in.Reasons != nil {
+		in, out := &in.Reasons, &out.Reasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	if in.Path != nil {
-		in, out := &in.Path, &out.Path
-		*out = new(string)
+	if in.InvolvedObject != nil {
+		in, out := &in.InvolvedObject, &out.InvolvedObject
+		*out = new(EventInvolvedObjectSelector)
 		**out = **in
 	}
-	if in.Service != nil {
-		in, out := &in.Service, &out.Service
-		*out = new(ServiceReference)
-		(*in).DeepCopyInto(*out)
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Webhook.
-func (in *Webhook) DeepCopy() *Webhook {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventSelector.
+func (in *EventSelector) DeepCopy() *EventSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(EventSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventSource) DeepCopyInto(out *EventSource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventSource.
+func (in *EventSource) DeepCopy() *EventSource {
+	if in == nil {
+		return nil
+	}
+	out := new(EventSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FieldSelector) DeepCopyInto(out *FieldSelector) {
+	*out = *in
+	if in.MatchFields != nil {
+		in, out := &in.MatchFields, &out.MatchFields
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MatchExpressions != nil {
+		in, out := &in.MatchExpressions, &out.MatchExpressions
+		*out = make([]v1.LabelSelectorRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FieldSelector.
+func (in *FieldSelector) DeepCopy() *FieldSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(FieldSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmChart) DeepCopyInto(out *HelmChart) {
+	*out = *in
+	in.Chart.DeepCopyInto(&out.Chart)
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmChart.
+func (in *HelmChart) DeepCopy() *HelmChart {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmChart)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmChartSource) DeepCopyInto(out *HelmChartSource) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmChartSource.
+func (in *HelmChartSource) DeepCopy() *HelmChartSource {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmChartSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Hook) DeepCopyInto(out *Hook) {
+	*out = *in
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(Webhook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OPAServer != nil {
+		in, out := &in.OPAServer, &out.OPAServer
+		*out = new(Webhook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Starlark != nil {
+		in, out := &in.Starlark, &out.Starlark
+		*out = new(StarlarkHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(HookCache)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hook.
+func (in *Hook) DeepCopy() *Hook {
+	if in == nil {
+		return nil
+	}
+	out := new(Hook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HookCache) DeepCopyInto(out *HookCache) {
+	*out = *in
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HookCache.
+func (in *HookCache) DeepCopy() *HookCache {
+	if in == nil {
+		return nil
+	}
+	out := new(HookCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HookParameters) DeepCopyInto(out *HookParameters) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ParametersFrom != nil {
+		in, out := &in.ParametersFrom, &out.ParametersFrom
+		*out = make([]ParametersSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HookParameters.
+func (in *HookParameters) DeepCopy() *HookParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(HookParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataPropagation) DeepCopyInto(out *MetadataPropagation) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataPropagation.
+func (in *MetadataPropagation) DeepCopy() *MetadataPropagation {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataPropagation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2ClientCredentials) DeepCopyInto(out *OAuth2ClientCredentials) {
+	*out = *in
+	out.ClientSecretFrom = in.ClientSecretFrom
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2ClientCredentials.
+func (in *OAuth2ClientCredentials) DeepCopy() *OAuth2ClientCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2ClientCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectReference) DeepCopyInto(out *ObjectReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectReference.
+func (in *ObjectReference) DeepCopy() *ObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ParametersSource) DeepCopyInto(out *ParametersSource) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(ObjectReference)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ParametersSource.
+func (in *ParametersSource) DeepCopy() *ParametersSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ParametersSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RelatedResourceRule) DeepCopyInto(out *RelatedResourceRule) {
+	*out = *in
+	out.ResourceRule = in.ResourceRule
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RelatedResourceRule.
+func (in *RelatedResourceRule) DeepCopy() *RelatedResourceRule {
+	if in == nil {
+		return nil
+	}
+	out := new(RelatedResourceRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRule) DeepCopyInto(out *ResourceRule) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRule.
+func (in *ResourceRule) DeepCopy() *ResourceRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RevisionSnapshots) DeepCopyInto(out *RevisionSnapshots) {
+	*out = *in
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RevisionSnapshots.
+func (in *RevisionSnapshots) DeepCopy() *RevisionSnapshots {
+	if in == nil {
+		return nil
+	}
+	out := new(RevisionSnapshots)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyReference) DeepCopyInto(out *SecretKeyReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyReference.
+func (in *SecretKeyReference) DeepCopy() *SecretKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAccountTokenProjection) DeepCopyInto(out *ServiceAccountTokenProjection) {
+	*out = *in
+	if in.ExpirationSeconds != nil {
+		in, out := &in.ExpirationSeconds, &out.ExpirationSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountTokenProjection.
+func (in *ServiceAccountTokenProjection) DeepCopy() *ServiceAccountTokenProjection {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAccountTokenProjection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceReference) DeepCopyInto(out *ServiceReference) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Protocol != nil {
+		in, out := &in.Protocol, &out.Protocol
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceReference.
+func (in *ServiceReference) DeepCopy() *ServiceReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StarlarkHook) DeepCopyInto(out *StarlarkHook) {
+	*out = *in
+	if in.MaxSteps != nil {
+		in, out := &in.MaxSteps, &out.MaxSteps
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MaxMemory != nil {
+		in, out := &in.MaxMemory, &out.MaxMemory
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StarlarkHook.
+func (in *StarlarkHook) DeepCopy() *StarlarkHook {
+	if in == nil {
+		return nil
+	}
+	out := new(StarlarkHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusConditionCheck) DeepCopyInto(out *StatusConditionCheck) {
+	*out = *in
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(string)
+		**out = **in
+	}
+	if in.Reason != nil {
+		in, out := &in.Reason, &out.Reason
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusConditionCheck.
+func (in *StatusConditionCheck) DeepCopy() *StatusConditionCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusConditionCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusController) DeepCopyInto(out *StatusController) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusController.
+func (in *StatusController) DeepCopy() *StatusController {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusController)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StatusController) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusControllerHooks) DeepCopyInto(out *StatusControllerHooks) {
+	*out = *in
+	if in.Customize != nil {
+		in, out := &in.Customize, &out.Customize
+		*out = new(Hook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sync != nil {
+		in, out := &in.Sync, &out.Sync
+		*out = new(Hook)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusControllerHooks.
+func (in *StatusControllerHooks) DeepCopy() *StatusControllerHooks {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusControllerHooks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusControllerList) DeepCopyInto(out *StatusControllerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]StatusController, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusControllerList.
+func (in *StatusControllerList) DeepCopy() *StatusControllerList {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusControllerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StatusControllerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusControllerResourceRule) DeepCopyInto(out *StatusControllerResourceRule) {
+	*out = *in
+	out.ResourceRule = in.ResourceRule
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AnnotationSelector != nil {
+		in, out := &in.AnnotationSelector, &out.AnnotationSelector
+		*out = new(AnnotationSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusControllerResourceRule.
+func (in *StatusControllerResourceRule) DeepCopy() *StatusControllerResourceRule {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusControllerResourceRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusControllerSpec) DeepCopyInto(out *StatusControllerSpec) {
+	*out = *in
+	in.Resource.DeepCopyInto(&out.Resource)
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(StatusControllerHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResyncPeriodSeconds != nil {
+		in, out := &in.ResyncPeriodSeconds, &out.ResyncPeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	in.HookParameters.DeepCopyInto(&out.HookParameters)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusControllerSpec.
+func (in *StatusControllerSpec) DeepCopy() *StatusControllerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusControllerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusControllerStatus) DeepCopyInto(out *StatusControllerStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusControllerStatus.
+func (in *StatusControllerStatus) DeepCopy() *StatusControllerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusControllerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchController) DeepCopyInto(out *WatchController) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchController.
+func (in *WatchController) DeepCopy() *WatchController {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchController)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WatchController) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchControllerHooks) DeepCopyInto(out *WatchControllerHooks) {
+	*out = *in
+	if in.Notify != nil {
+		in, out := &in.Notify, &out.Notify
+		*out = new(Hook)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchControllerHooks.
+func (in *WatchControllerHooks) DeepCopy() *WatchControllerHooks {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchControllerHooks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchControllerList) DeepCopyInto(out *WatchControllerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]WatchController, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchControllerList.
+func (in *WatchControllerList) DeepCopy() *WatchControllerList {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchControllerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WatchControllerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchControllerResourceRule) DeepCopyInto(out *WatchControllerResourceRule) {
+	*out = *in
+	out.ResourceRule = in.ResourceRule
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AnnotationSelector != nil {
+		in, out := &in.AnnotationSelector, &out.AnnotationSelector
+		*out = new(AnnotationSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchControllerResourceRule.
+func (in *WatchControllerResourceRule) DeepCopy() *WatchControllerResourceRule {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchControllerResourceRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchControllerSpec) DeepCopyInto(out *WatchControllerSpec) {
+	*out = *in
+	in.Resource.DeepCopyInto(&out.Resource)
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(WatchControllerHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	in.HookParameters.DeepCopyInto(&out.HookParameters)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchControllerSpec.
+func (in *WatchControllerSpec) DeepCopy() *WatchControllerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchControllerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchControllerStatus) DeepCopyInto(out *WatchControllerStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchControllerStatus.
+func (in *WatchControllerStatus) DeepCopy() *WatchControllerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchControllerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Webhook) DeepCopyInto(out *Webhook) {
+	*out = *in
+	if in.URL != nil {
+		in, out := &in.URL, &out.URL
+		*out = new(string)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Path != nil {
+		in, out := &in.Path, &out.Path
+		*out = new(string)
+		**out = **in
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ServiceReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.CABundleFrom != nil {
+		in, out := &in.CABundleFrom, &out.CABundleFrom
+		*out = new(SecretKeyReference)
+		**out = **in
+	}
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(WebhookAuthorization)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxInFlight != nil {
+		in, out := &in.MaxInFlight, &out.MaxInFlight
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AllowedURLOverrides != nil {
+		in, out := &in.AllowedURLOverrides, &out.AllowedURLOverrides
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(WebhookCanary)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Webhook.
+func (in *Webhook) DeepCopy() *Webhook {
 	if in == nil {
 		return nil
 	}
@@ -769,3 +2169,50 @@ func (in *Webhook) DeepCopy() *Webhook {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookAuthorization) DeepCopyInto(out *WebhookAuthorization) {
+	*out = *in
+	if in.BearerTokenFrom != nil {
+		in, out := &in.BearerTokenFrom, &out.BearerTokenFrom
+		*out = new(SecretKeyReference)
+		**out = **in
+	}
+	if in.OAuth2 != nil {
+		in, out := &in.OAuth2, &out.OAuth2
+		*out = new(OAuth2ClientCredentials)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ServiceAccountToken != nil {
+		in, out := &in.ServiceAccountToken, &out.ServiceAccountToken
+		*out = new(ServiceAccountTokenProjection)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookAuthorization.
+func (in *WebhookAuthorization) DeepCopy() *WebhookAuthorization {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookAuthorization)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookCanary) DeepCopyInto(out *WebhookCanary) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookCanary.
+func (in *WebhookCanary) DeepCopy() *WebhookCanary {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookCanary)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -21,3 +21,9 @@ package v1alpha1
 type CompositeControllerExpansion interface{}
 
 type DecoratorControllerExpansion interface{}
+
+type EventControllerExpansion interface{}
+
+type StatusControllerExpansion interface{}
+
+type WatchControllerExpansion interface{}
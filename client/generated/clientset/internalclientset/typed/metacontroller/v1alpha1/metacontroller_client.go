@@ -29,6 +29,9 @@ type MetacontrollerV1alpha1Interface interface {
 	CompositeControllersGetter
 	ControllerRevisionsGetter
 	DecoratorControllersGetter
+	EventControllersGetter
+	StatusControllersGetter
+	WatchControllersGetter
 }
 
 // MetacontrollerV1alpha1Client is used to interact with features provided by the metacontroller group.
@@ -48,6 +51,18 @@ func (c *MetacontrollerV1alpha1Client) DecoratorControllers() DecoratorControlle
 	return newDecoratorControllers(c)
 }
 
+func (c *MetacontrollerV1alpha1Client) EventControllers() EventControllerInterface {
+	return newEventControllers(c)
+}
+
+func (c *MetacontrollerV1alpha1Client) StatusControllers() StatusControllerInterface {
+	return newStatusControllers(c)
+}
+
+func (c *MetacontrollerV1alpha1Client) WatchControllers() WatchControllerInterface {
+	return newWatchControllers(c)
+}
+
 // NewForConfig creates a new MetacontrollerV1alpha1Client for the given config.
 func NewForConfig(c *rest.Config) (*MetacontrollerV1alpha1Client, error) {
 	config := *c
@@ -59,6 +59,12 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Metacontroller().V1alpha1().ControllerRevisions().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("decoratorcontrollers"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Metacontroller().V1alpha1().DecoratorControllers().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("eventcontrollers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Metacontroller().V1alpha1().EventControllers().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("statuscontrollers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Metacontroller().V1alpha1().StatusControllers().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("watchcontrollers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Metacontroller().V1alpha1().WatchControllers().Informer()}, nil
 
 	}
 
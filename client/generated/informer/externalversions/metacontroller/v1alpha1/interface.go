@@ -30,6 +30,12 @@ type Interface interface {
 	ControllerRevisions() ControllerRevisionInformer
 	// DecoratorControllers returns a DecoratorControllerInformer.
 	DecoratorControllers() DecoratorControllerInformer
+	// EventControllers returns a EventControllerInformer.
+	EventControllers() EventControllerInformer
+	// StatusControllers returns a StatusControllerInformer.
+	StatusControllers() StatusControllerInformer
+	// WatchControllers returns a WatchControllerInformer.
+	WatchControllers() WatchControllerInformer
 }
 
 type version struct {
@@ -57,3 +63,18 @@ func (v *version) ControllerRevisions() ControllerRevisionInformer {
 func (v *version) DecoratorControllers() DecoratorControllerInformer {
 	return &decoratorControllerInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
+
+// EventControllers returns a EventControllerInformer.
+func (v *version) EventControllers() EventControllerInformer {
+	return &eventControllerInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// StatusControllers returns a StatusControllerInformer.
+func (v *version) StatusControllers() StatusControllerInformer {
+	return &statusControllerInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// WatchControllers returns a WatchControllerInformer.
+func (v *version) WatchControllers() WatchControllerInformer {
+	return &watchControllerInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
@@ -33,3 +33,15 @@ type ControllerRevisionNamespaceListerExpansion interface{}
 // DecoratorControllerListerExpansion allows custom methods to be added to
 // DecoratorControllerLister.
 type DecoratorControllerListerExpansion interface{}
+
+// EventControllerListerExpansion allows custom methods to be added to
+// EventControllerLister.
+type EventControllerListerExpansion interface{}
+
+// StatusControllerListerExpansion allows custom methods to be added to
+// StatusControllerLister.
+type StatusControllerListerExpansion interface{}
+
+// WatchControllerListerExpansion allows custom methods to be added to
+// WatchControllerLister.
+type WatchControllerListerExpansion interface{}
@@ -0,0 +1,28 @@
+package common
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+// AdoptOnly restricts the observed and desired children of a parent to the
+// ones that are both observed and desired, so that ManageChildren updates
+// existing children without creating or deleting any.
+func AdoptOnly(observed, desired ChildMap) (ChildMap, ChildMap) {
+	keptDesired := make(ChildMap, len(desired))
+	for key, objects := range desired {
+		keptDesired[key] = make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if observed[key][name] == nil {
+				klog.V(4).InfoS("Not creating child in adopt-only mode", "child_kind", obj.GetKind(), "child", klog.KObj(obj))
+				continue
+			}
+			keptDesired[key][name] = obj
+		}
+	}
+	keptObserved := keepObserved(observed, desired, func(obj *unstructured.Unstructured) bool {
+		klog.V(4).InfoS("Not deleting child in adopt-only mode", "child_kind", obj.GetKind(), "child", klog.KObj(obj))
+		return true
+	})
+	return keptObserved, keptDesired
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAdoptOnly(t *testing.T) {
+	child := func(name string, deleting bool) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		if deleting {
+			now := metav1.Now()
+			obj.SetDeletionTimestamp(&now)
+		}
+		return obj
+	}
+	names := func(children ChildMap) []string {
+		got := []string{}
+		for _, obj := range children.List() {
+			got = append(got, obj.GetName())
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	table := []struct {
+		name                      string
+		observed, desired         []*unstructured.Unstructured
+		wantObserved, wantDesired []string
+	}{
+		{
+			name:         "existing children are updated",
+			observed:     []*unstructured.Unstructured{child("a", false), child("b", false)},
+			desired:      []*unstructured.Unstructured{child("a", false), child("b", false)},
+			wantObserved: []string{"a", "b"},
+			wantDesired:  []string{"a", "b"},
+		},
+		{
+			name:         "missing children are not created",
+			observed:     []*unstructured.Unstructured{child("a", false)},
+			desired:      []*unstructured.Unstructured{child("a", false), child("new", false)},
+			wantObserved: []string{"a"},
+			wantDesired:  []string{"a"},
+		},
+		{
+			name:         "undesired children are not deleted",
+			observed:     []*unstructured.Unstructured{child("a", false), child("old", false)},
+			desired:      []*unstructured.Unstructured{child("a", false)},
+			wantObserved: []string{"a"},
+			wantDesired:  []string{"a"},
+		},
+		{
+			name:         "children pending deletion are kept",
+			observed:     []*unstructured.Unstructured{child("a", false), child("gone", true)},
+			desired:      []*unstructured.Unstructured{child("a", false)},
+			wantObserved: []string{"a", "gone"},
+			wantDesired:  []string{"a"},
+		},
+	}
+
+	parent := &unstructured.Unstructured{}
+	for _, tc := range table {
+		observed, desired := AdoptOnly(MakeChildMap(parent, tc.observed), MakeChildMap(parent, tc.desired))
+		if got := names(observed); !reflect.DeepEqual(got, tc.wantObserved) {
+			t.Errorf("%v: AdoptOnly() observed = %v, want %v", tc.name, got, tc.wantObserved)
+		}
+		if got := names(desired); !reflect.DeepEqual(got, tc.wantDesired) {
+			t.Errorf("%v: AdoptOnly() desired = %v, want %v", tc.name, got, tc.wantDesired)
+		}
+	}
+}
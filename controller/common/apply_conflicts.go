@@ -0,0 +1,186 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	dynamicapply "metacontroller.io/dynamic/apply"
+	"metacontroller.io/events"
+)
+
+// FieldManager is the field manager we write children as.
+const FieldManager = "metacontroller"
+
+var applyConflicts = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Namespace:      "metacontroller",
+		Name:           "apply_conflicts_total",
+		Help:           "Number of times a child field we manage was found changed by another field manager.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"kind", "manager"},
+)
+
+func init() {
+	legacyregistry.MustRegister(applyConflicts)
+}
+
+// ApplyConflict is a field we last applied to a child that another field
+// manager has since changed.
+type ApplyConflict struct {
+	// Path is the field path, like "spec.replicas".
+	Path string
+	// Manager is the field manager that now owns the field.
+	Manager string
+}
+
+// DetectApplyConflicts returns the fields we last applied to obj that
+// currently have a different value, and are owned by some other field manager
+// according to the managed fields of obj.
+//
+// Fields that were changed by the API server on our own writes (e.g. defaults
+// or normalization) are owned by us, so they're not reported.
+func DetectApplyConflicts(obj *unstructured.Unstructured) ([]ApplyConflict, error) {
+	lastApplied, err := dynamicapply.GetLastApplied(obj)
+	if err != nil || lastApplied == nil {
+		return nil, err
+	}
+	return detectFieldConflicts(obj, lastApplied), nil
+}
+
+// detectFieldConflicts returns the fields in applied that have a different
+// value in obj, and are owned by some other field manager.
+func detectFieldConflicts(obj *unstructured.Unstructured, applied map[string]interface{}) []ApplyConflict {
+	type managerFields struct {
+		name   string
+		fields map[string]interface{}
+	}
+	var managers []managerFields
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == FieldManager || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		managers = append(managers, managerFields{name: entry.Manager, fields: fields})
+	}
+	if len(managers) == 0 {
+		return nil
+	}
+
+	var conflicts []ApplyConflict
+	var walk func(path []string, applied map[string]interface{})
+	walk = func(path []string, applied map[string]interface{}) {
+		for key, value := range applied {
+			fieldPath := append(path[:len(path):len(path)], key)
+			if len(fieldPath) == 1 && key == "status" {
+				// We never apply status to children.
+				continue
+			}
+			if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+				walk(fieldPath, nested)
+				continue
+			}
+			live, found, _ := unstructured.NestedFieldNoCopy(obj.UnstructuredContent(), fieldPath...)
+			if !found || matchesApplied(value, live) {
+				// Removed fields have no owner to blame.
+				continue
+			}
+			for _, manager := range managers {
+				if ownsField(manager.fields, fieldPath) {
+					conflicts = append(conflicts, ApplyConflict{Path: strings.Join(fieldPath, "."), Manager: manager.name})
+					break
+				}
+			}
+		}
+	}
+	walk(nil, applied)
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Path < conflicts[j].Path
+	})
+	return conflicts
+}
+
+// matchesApplied returns whether live still has the applied value.
+// Maps, including those inside lists, may have extra fields in live,
+// such as defaults filled in by the API server.
+func matchesApplied(applied, live interface{}) bool {
+	switch applied := applied.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range applied {
+			if !matchesApplied(value, liveMap[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok || len(liveList) != len(applied) {
+			return false
+		}
+		for i := range applied {
+			if !matchesApplied(applied[i], liveList[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(applied, live)
+	}
+}
+
+// ownsField returns whether a managed fields set (in FieldsV1 format)
+// includes the field at path, or anything below it.
+func ownsField(fields map[string]interface{}, path []string) bool {
+	for _, key := range path {
+		next, ok := fields["f:"+key].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		fields = next
+	}
+	return true
+}
+
+// reportApplyConflicts emits an event on the parent and counts a metric for
+// each field of the child that someone else reverted since our last update,
+// so fights over a field don't go unnoticed while we keep reapplying it.
+func reportApplyConflicts(eventRecorder record.EventRecorder, parent, child, desired *unstructured.Unstructured, serverSide bool) {
+	var conflicts []ApplyConflict
+	if serverSide && !hasLastApplied(child) {
+		// With server-side apply, we own everything we applied, so any desired
+		// field that's owned by someone else was changed since.
+		conflicts = detectFieldConflicts(child, desired.UnstructuredContent())
+	} else {
+		var err error
+		conflicts, err = DetectApplyConflicts(child)
+		if err != nil {
+			klog.V(4).InfoS("Can't detect apply conflicts", "child", klog.KObj(child), "err", err)
+			return
+		}
+	}
+	for _, conflict := range conflicts {
+		klog.InfoS("Apply conflict", "parent", klog.KObj(parent), "child", klog.KObj(child), "field", conflict.Path, "manager", conflict.Manager)
+		applyConflicts.WithLabelValues(child.GetKind(), conflict.Manager).Inc()
+		if eventRecorder != nil {
+			eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonApplyConflict,
+				"Field %v of %v was changed by field manager %q; reverting it to the desired value", conflict.Path, describeObject(child), conflict.Manager)
+		}
+	}
+}
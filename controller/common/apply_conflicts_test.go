@@ -0,0 +1,114 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+func TestDetectApplyConflicts(t *testing.T) {
+	table := []struct {
+		name    string
+		objJSON string
+		want    []ApplyConflict
+	}{
+		{
+			name: "no last applied",
+			objJSON: `{
+				"metadata": {"name": "test"},
+				"spec": {"replicas": 3}
+			}`,
+			want: nil,
+		},
+		{
+			name: "unchanged",
+			objJSON: `{
+				"metadata": {
+					"name": "test",
+					"annotations": {"metacontroller.k8s.io/last-applied-configuration": "{\"spec\":{\"replicas\":1}}"},
+					"managedFields": [
+						{"manager": "kubectl", "operation": "Update", "fieldsType": "FieldsV1", "fieldsV1": {"f:spec": {"f:replicas": {}}}}
+					]
+				},
+				"spec": {"replicas": 1}
+			}`,
+			want: nil,
+		},
+		{
+			name: "changed by another manager",
+			objJSON: `{
+				"metadata": {
+					"name": "test",
+					"annotations": {"metacontroller.k8s.io/last-applied-configuration": "{\"spec\":{\"replicas\":1,\"paused\":false}}"},
+					"managedFields": [
+						{"manager": "metacontroller", "operation": "Update", "fieldsType": "FieldsV1", "fieldsV1": {"f:spec": {"f:paused": {}}}},
+						{"manager": "kubectl", "operation": "Update", "fieldsType": "FieldsV1", "fieldsV1": {"f:spec": {"f:replicas": {}}}}
+					]
+				},
+				"spec": {"replicas": 3, "paused": false}
+			}`,
+			want: []ApplyConflict{{Path: "spec.replicas", Manager: "kubectl"}},
+		},
+		{
+			name: "changed by ourselves",
+			objJSON: `{
+				"metadata": {
+					"name": "test",
+					"annotations": {"metacontroller.k8s.io/last-applied-configuration": "{\"spec\":{\"cpu\":\"500m\"}}"},
+					"managedFields": [
+						{"manager": "metacontroller", "operation": "Update", "fieldsType": "FieldsV1", "fieldsV1": {"f:spec": {"f:cpu": {}}}},
+						{"manager": "kubectl", "operation": "Update", "fieldsType": "FieldsV1", "fieldsV1": {"f:spec": {"f:other": {}}}}
+					]
+				},
+				"spec": {"cpu": "0.5"}
+			}`,
+			want: nil,
+		},
+		{
+			name: "list changed",
+			objJSON: `{
+				"metadata": {
+					"name": "test",
+					"annotations": {"metacontroller.k8s.io/last-applied-configuration": "{\"spec\":{\"args\":[\"a\"]}}"},
+					"managedFields": [
+						{"manager": "other-controller", "operation": "Update", "fieldsType": "FieldsV1", "fieldsV1": {"f:spec": {"f:args": {"v:\"b\"": {}}}}}
+					]
+				},
+				"spec": {"args": ["b"]}
+			}`,
+			want: []ApplyConflict{{Path: "spec.args", Manager: "other-controller"}},
+		},
+		{
+			name: "status ignored",
+			objJSON: `{
+				"metadata": {
+					"name": "test",
+					"annotations": {"metacontroller.k8s.io/last-applied-configuration": "{\"status\":{\"ready\":false}}"},
+					"managedFields": [
+						{"manager": "kubelet", "operation": "Update", "fieldsType": "FieldsV1", "fieldsV1": {"f:status": {"f:ready": {}}}}
+					]
+				},
+				"status": {"ready": true}
+			}`,
+			want: nil,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{}
+			if err := json.Unmarshal([]byte(tc.objJSON), &obj.Object); err != nil {
+				t.Fatalf("can't unmarshal object: %v", err)
+			}
+			got, err := DetectApplyConflicts(obj)
+			if err != nil {
+				t.Fatalf("DetectApplyConflicts error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("DetectApplyConflicts() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
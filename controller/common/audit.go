@@ -0,0 +1,146 @@
+package common
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/events"
+)
+
+// AuditedChild is a child that a controller in Audit mode would have adopted
+// or deleted.
+type AuditedChild struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// Audit collects the children a controller in Audit mode holds back from
+// adoption and deletion during a sync of parent.
+type Audit struct {
+	parent  *unstructured.Unstructured
+	orphans ChildMap
+
+	WouldAdopt  []AuditedChild
+	WouldDelete []AuditedChild
+}
+
+// NewAudit returns an Audit of a sync of parent, in which the given orphans
+// would have been adopted.
+func NewAudit(parent *unstructured.Unstructured, orphans []*unstructured.Unstructured) *Audit {
+	return &Audit{
+		parent:      parent,
+		orphans:     MakeChildMap(parent, orphans),
+		WouldAdopt:  []AuditedChild{},
+		WouldDelete: []AuditedChild{},
+	}
+}
+
+// Filter returns the observed children, without those that aren't desired
+// anymore, and the desired children, without orphans that would have to be
+// adopted first, so ManageChildren neither deletes nor adopts anything.
+// Children that are only created or updated are left in.
+func (a *Audit) Filter(observed, desired ChildMap) (ChildMap, ChildMap) {
+	for key, objects := range a.orphans {
+		for name, obj := range objects {
+			a.WouldAdopt = append(a.WouldAdopt, a.auditedChild(obj))
+			// Orphans that aren't desired would be deleted right after adoption.
+			if desired[key][name] == nil {
+				a.WouldDelete = append(a.WouldDelete, a.auditedChild(obj))
+			}
+		}
+	}
+
+	keptObserved := make(ChildMap, len(observed))
+	for key, objects := range observed {
+		keptObserved[key] = make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if desired[key][name] == nil && obj.GetDeletionTimestamp() == nil {
+				a.WouldDelete = append(a.WouldDelete, a.auditedChild(obj))
+				continue
+			}
+			keptObserved[key][name] = obj
+		}
+	}
+
+	keptDesired := make(ChildMap, len(desired))
+	for key, objects := range desired {
+		keptDesired[key] = make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if a.orphans[key][name] != nil {
+				continue
+			}
+			keptDesired[key][name] = obj
+		}
+	}
+
+	sortAuditedChildren(a.WouldAdopt)
+	sortAuditedChildren(a.WouldDelete)
+	return keptObserved, keptDesired
+}
+
+// Report logs what would have been adopted or deleted, and emits an Audited
+// event on the parent for each of those children.
+func (a *Audit) Report(eventRecorder record.EventRecorder) {
+	report := func(action string, children []AuditedChild) {
+		for _, child := range children {
+			ref := klog.KRef(child.Namespace, child.Name)
+			klog.InfoS("Audit", "parent", klog.KObj(a.parent), "action", action, "child_kind", child.Kind, "child", ref)
+			if eventRecorder != nil {
+				eventRecorder.Eventf(a.parent, v1.EventTypeNormal, events.ReasonAudited, "Would %v %v %v", action, child.Kind, ref)
+			}
+		}
+	}
+	report("adopt", a.WouldAdopt)
+	report("delete", a.WouldDelete)
+}
+
+// SetStatus writes the audit into the audit field of the parent status.
+func (a *Audit) SetStatus(status map[string]interface{}) {
+	list := func(children []AuditedChild) []interface{} {
+		result := make([]interface{}, 0, len(children))
+		for _, child := range children {
+			item := map[string]interface{}{
+				"apiVersion": child.APIVersion,
+				"kind":       child.Kind,
+				"name":       child.Name,
+			}
+			if child.Namespace != "" {
+				item["namespace"] = child.Namespace
+			}
+			result = append(result, item)
+		}
+		return result
+	}
+	status["audit"] = map[string]interface{}{
+		"wouldAdopt":  list(a.WouldAdopt),
+		"wouldDelete": list(a.WouldDelete),
+	}
+}
+
+func (a *Audit) auditedChild(obj *unstructured.Unstructured) AuditedChild {
+	return AuditedChild{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+func sortAuditedChildren(children []AuditedChild) {
+	sort.Slice(children, func(i, j int) bool {
+		a, b := children[i], children[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
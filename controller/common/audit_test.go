@@ -0,0 +1,60 @@
+package common
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAuditFilter(t *testing.T) {
+	child := func(name string, deleting bool) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("ns")
+		obj.SetName(name)
+		if deleting {
+			now := metav1.Now()
+			obj.SetDeletionTimestamp(&now)
+		}
+		return obj
+	}
+	names := func(children ChildMap) []string {
+		got := []string{}
+		for _, obj := range children.List() {
+			got = append(got, obj.GetName())
+		}
+		sort.Strings(got)
+		return got
+	}
+	auditedNames := func(children []AuditedChild) []string {
+		got := []string{}
+		for _, child := range children {
+			got = append(got, child.Name)
+		}
+		return got
+	}
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("ns")
+
+	audit := NewAudit(parent, []*unstructured.Unstructured{child("orphan-desired", false), child("orphan-undesired", false)})
+	observed := MakeChildMap(parent, []*unstructured.Unstructured{child("kept", false), child("undesired", false), child("deleting", true)})
+	desired := MakeChildMap(parent, []*unstructured.Unstructured{child("kept", false), child("new", false), child("orphan-desired", false)})
+	gotObserved, gotDesired := audit.Filter(observed, desired)
+
+	if got, want := names(gotObserved), []string{"deleting", "kept"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter() observed = %v, want %v", got, want)
+	}
+	if got, want := names(gotDesired), []string{"kept", "new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter() desired = %v, want %v", got, want)
+	}
+	if got, want := auditedNames(audit.WouldAdopt), []string{"orphan-desired", "orphan-undesired"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WouldAdopt = %v, want %v", got, want)
+	}
+	if got, want := auditedNames(audit.WouldDelete), []string{"orphan-undesired", "undesired"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WouldDelete = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,49 @@
+package common
+
+import "time"
+
+// SyncPressure is sent to sync hooks, so they can shed optional work, like
+// expensive calls to external systems, when Metacontroller is under pressure.
+type SyncPressure struct {
+	// QueueDepth is the number of parents of the controller waiting to be
+	// synced.
+	QueueDepth int `json:"queueDepth"`
+	// Failures is the number of consecutive failed syncs of the parent.
+	Failures int `json:"failures"`
+	// SecondsSinceLastSuccess is how long ago the parent last synced
+	// successfully, or nil if it didn't since Metacontroller started.
+	SecondsSinceLastSuccess *float64 `json:"secondsSinceLastSuccess,omitempty"`
+}
+
+// Synced records that the parent with the given queue key synced
+// successfully.
+func (r *SyncRetries) Synced(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastSuccess[key] = time.Now()
+}
+
+// Forget drops when the parent with the given queue key last synced, and the
+// response applied then, because it's gone.
+func (r *SyncRetries) Forget(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.lastSuccess, key)
+	delete(r.responseHashes, key)
+}
+
+// Pressure returns the pressure to tell the hooks of the parent with the
+// given queue key about, given the depth of the queue of the controller.
+func (r *SyncRetries) Pressure(key string, queueDepth int) *SyncPressure {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	pressure := &SyncPressure{
+		QueueDepth: queueDepth,
+		Failures:   r.failures[key],
+	}
+	if lastSuccess, ok := r.lastSuccess[key]; ok {
+		seconds := time.Since(lastSuccess).Seconds()
+		pressure.SecondsSinceLastSuccess = &seconds
+	}
+	return pressure
+}
@@ -0,0 +1,29 @@
+package common
+
+import "testing"
+
+func TestSyncRetriesPressure(t *testing.T) {
+	retries := NewSyncRetries("CompositeController", "test")
+	defer retries.Stop()
+
+	pressure := retries.Pressure("ns/a", 3)
+	if pressure.QueueDepth != 3 || pressure.Failures != 0 || pressure.SecondsSinceLastSuccess != nil {
+		t.Errorf("Pressure() = %+v, want a queue depth of 3 and no failures or success", pressure)
+	}
+
+	retries.Synced("ns/a")
+	retries.Failed("ns/a")
+	retries.Failed("ns/a")
+	pressure = retries.Pressure("ns/a", 0)
+	if pressure.Failures != 2 {
+		t.Errorf("Pressure() = %v failures, want 2", pressure.Failures)
+	}
+	if pressure.SecondsSinceLastSuccess == nil || *pressure.SecondsSinceLastSuccess < 0 {
+		t.Errorf("Pressure() = %v seconds since last success, want when it synced", pressure.SecondsSinceLastSuccess)
+	}
+
+	retries.Forget("ns/a")
+	if pressure := retries.Pressure("ns/a", 0); pressure.SecondsSinceLastSuccess != nil {
+		t.Errorf("Pressure() = %v seconds since last success after Forget(), want none", *pressure.SecondsSinceLastSuccess)
+	}
+}
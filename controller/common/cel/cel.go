@@ -0,0 +1,91 @@
+// Package cel evaluates selection predicates written in the Common
+// Expression Language (https://github.com/google/cel-spec) against objects
+// decoded from JSON.
+//
+// It implements the subset of CEL that predicates over Kubernetes objects
+// need, without protocol buffers: literals, lists and maps, field selection
+// and indexing, the has(), all(), exists(), exists_one(), filter() and map()
+// macros, the logical, relational and arithmetic operators, the conditional
+// operator, and the size(), contains(), startsWith(), endsWith(), matches(),
+// int(), double() and string() functions.
+package cel
+
+import (
+	"fmt"
+)
+
+// Variable is the name of the variable holding the object an expression is
+// evaluated against.
+const Variable = "object"
+
+// CostLimit bounds the number of operations an evaluation may take, so an
+// expression over a huge object can't stall a worker.
+const CostLimit = 1000000
+
+// Program is a compiled predicate.
+type Program struct {
+	expression string
+	root       node
+}
+
+// Compile parses the predicate expression, which refers to the object it's
+// evaluated against as Variable, and must evaluate to a bool.
+func Compile(expression string) (*Program, error) {
+	root, err := parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("can't compile %q: %v", expression, err)
+	}
+	if !mayBeBool(root) {
+		return nil, fmt.Errorf("can't compile %q: it must evaluate to a bool", expression)
+	}
+	return &Program{expression: expression, root: root}, nil
+}
+
+// String returns the expression p was compiled from.
+func (p *Program) String() string {
+	return p.expression
+}
+
+// Matches evaluates p against obj, such as the content of an
+// unstructured.Unstructured.
+func (p *Program) Matches(obj map[string]interface{}) (bool, error) {
+	a := &activation{vars: map[string]interface{}{Variable: obj}, cost: CostLimit}
+	value, err := p.root.eval(a)
+	if err != nil {
+		return false, fmt.Errorf("can't evaluate %q: %v", p.expression, err)
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("can't evaluate %q: got %v, want a bool", p.expression, typeName(value))
+	}
+	return result, nil
+}
+
+// mayBeBool returns false if n can't evaluate to a bool, as far as can be
+// told without knowing the type of the fields of the object.
+func mayBeBool(n node) bool {
+	switch n := n.(type) {
+	case *literal:
+		_, ok := n.value.(bool)
+		return ok
+	case *list, *mapLiteral:
+		return false
+	case *unary:
+		return n.op == "!"
+	case *binary:
+		switch n.op {
+		case "+", "-", "*", "/", "%":
+			return false
+		}
+	case *conditional:
+		return mayBeBool(n.then) || mayBeBool(n.otherwise)
+	case *comprehension:
+		return n.kind != comprehensionFilter && n.kind != comprehensionMap
+	case *call:
+		switch n.function {
+		case "size", "int", "double", "string":
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package cel
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+const service = `{
+	"apiVersion": "v1",
+	"kind": "Service",
+	"metadata": {
+		"name": "web",
+		"namespace": "prod",
+		"labels": {"app": "web", "tier": "frontend"}
+	},
+	"spec": {
+		"type": "LoadBalancer",
+		"ports": [{"name": "http", "port": 80}, {"name": "https", "port": 443}],
+		"sessionAffinity": null,
+		"ratio": 0.5
+	}
+}`
+
+func TestProgramMatches(t *testing.T) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(service), &obj); err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		name       string
+		expression string
+		want       bool
+		wantErr    bool
+	}{
+		{name: "literal", expression: "true", want: true},
+		{name: "field", expression: "object.spec.type == 'LoadBalancer'", want: true},
+		{name: "absent field", expression: "object.spec.type == 'LoadBalancer' && !has(object.spec.loadBalancerClass)", want: true},
+		{name: "present field", expression: "has(object.metadata.labels)", want: true},
+		{name: "null field", expression: "has(object.spec.sessionAffinity) && object.spec.sessionAffinity == null", want: true},
+		{name: "missing field", expression: "object.spec.loadBalancerClass == 'internal'", wantErr: true},
+		{name: "missing field decided by other side", expression: "object.spec.loadBalancerClass == 'internal' || object.kind == 'Service'", want: true},
+		{name: "missing field decided by left side", expression: "object.kind == 'Pod' && object.spec.loadBalancerClass == 'internal'", want: false},
+		{name: "index", expression: "object.metadata.labels['app'] == 'web' && object.spec.ports[1].port == 443", want: true},
+		{name: "map membership", expression: "'tier' in object.metadata.labels && !('env' in object.metadata.labels)", want: true},
+		{name: "list membership", expression: "object.metadata.namespace in ['prod', 'staging']", want: true},
+		{name: "exists", expression: "object.spec.ports.exists(p, p.port == 443)", want: true},
+		{name: "all", expression: "object.spec.ports.all(p, p.port < 1024)", want: true},
+		{name: "exists_one", expression: "object.spec.ports.exists_one(p, p.name.startsWith('http'))", want: false},
+		{name: "filter and size", expression: "size(object.spec.ports.filter(p, p.port > 100)) == 1", want: true},
+		{name: "map macro", expression: "object.spec.ports.map(p, p.name) == ['http', 'https']", want: true},
+		{name: "nested macros", expression: "object.spec.ports.all(p, ['http', 'https'].exists(n, n == p.name))", want: true},
+		{name: "arithmetic", expression: "object.spec.ports[0].port * 2 + 1 == 161", want: true},
+		{name: "mixed numbers", expression: "object.spec.ratio < 1 && object.spec.ports[0].port == 80.0", want: true},
+		{name: "conditional", expression: "object.metadata.namespace == 'prod' ? object.spec.ports.size() > 1 : false", want: true},
+		{name: "string functions", expression: "object.metadata.name.contains('e') && object.metadata.name.endsWith('b') && object.metadata.name.matches('^w.b$')", want: true},
+		{name: "conversions", expression: "string(object.spec.ports[0].port) == '80' && int('42') == 42 && double(1) == 1.0", want: true},
+		{name: "string concatenation", expression: "object.metadata.namespace + '/' + object.metadata.name == 'prod/web'", want: true},
+		{name: "escapes", expression: `"a\tb" == 'a\u0009b' && r'\d' == '\\d'`, want: true},
+		{name: "type mismatch", expression: "object.metadata.name < 1", wantErr: true},
+		{name: "not a bool", expression: "object.metadata.name", wantErr: true},
+		{name: "division by zero", expression: "1 / (size(object.spec.ports) - 2) == 1", wantErr: true},
+	}
+	for _, tc := range table {
+		p, err := Compile(tc.expression)
+		if err != nil {
+			t.Errorf("%v: Compile() = %v", tc.name, err)
+			continue
+		}
+		got, err := p.Matches(obj)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%v: Matches() = %v, want error", tc.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: Matches() = %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%v: Matches() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	table := []struct {
+		name       string
+		expression string
+	}{
+		{name: "empty", expression: ""},
+		{name: "syntax", expression: "object.spec.type =="},
+		{name: "unbalanced", expression: "(object.spec.type == 'a'"},
+		{name: "undeclared variable", expression: "self.spec.type == 'a'"},
+		{name: "macro variable out of scope", expression: "object.spec.ports.exists(p, true) && p.port == 80"},
+		{name: "unknown function", expression: "lower(object.metadata.name) == 'a'"},
+		{name: "wrong number of arguments", expression: "object.metadata.name.startsWith()"},
+		{name: "has without selection", expression: "has(object)"},
+		{name: "invalid pattern", expression: "object.metadata.name.matches('(')"},
+		{name: "not a bool", expression: "1 + 2"},
+		{name: "unterminated string", expression: "object.kind == 'Service"},
+	}
+	for _, tc := range table {
+		if _, err := Compile(tc.expression); err == nil {
+			t.Errorf("%v: Compile(%q) succeeded, want error", tc.name, tc.expression)
+		}
+	}
+}
+
+func TestProgramMatchesCostLimit(t *testing.T) {
+	items := make([]interface{}, 2000)
+	for i := range items {
+		items[i] = int64(i)
+	}
+	obj := map[string]interface{}{"items": items}
+	p, err := Compile("object.items.all(a, object.items.all(b, a >= 0))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Matches(obj); err == nil || !strings.Contains(err.Error(), "cost limit") {
+		t.Errorf("Matches() = %v, want cost limit error", err)
+	}
+}
@@ -0,0 +1,848 @@
+package cel
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// node is a node of the syntax tree of an expression.
+type node interface {
+	eval(a *activation) (interface{}, error)
+}
+
+// activation holds the variables of an evaluation, and its remaining cost.
+type activation struct {
+	vars map[string]interface{}
+	cost int
+}
+
+// step charges one unit of cost to the evaluation.
+func (a *activation) step() error {
+	a.cost--
+	if a.cost < 0 {
+		return fmt.Errorf("cost limit exceeded")
+	}
+	return nil
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (n *literal) eval(a *activation) (interface{}, error) {
+	return n.value, nil
+}
+
+type identifier struct {
+	name string
+}
+
+func (n *identifier) eval(a *activation) (interface{}, error) {
+	return a.vars[n.name], nil
+}
+
+type selection struct {
+	operand node
+	field   string
+}
+
+func (n *selection) eval(a *activation) (interface{}, error) {
+	if err := a.step(); err != nil {
+		return nil, err
+	}
+	operand, err := n.operand.eval(a)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := operand.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("can't select field %q of %v", n.field, typeName(operand))
+	}
+	value, ok := m[n.field]
+	if !ok {
+		return nil, fmt.Errorf("no such key: %v", n.field)
+	}
+	return normalize(value), nil
+}
+
+// presence is the has() macro, which tests whether a field is set.
+type presence struct {
+	selection *selection
+}
+
+func (n *presence) eval(a *activation) (interface{}, error) {
+	if err := a.step(); err != nil {
+		return nil, err
+	}
+	operand, err := n.selection.operand.eval(a)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := operand.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("can't test field %q of %v", n.selection.field, typeName(operand))
+	}
+	_, ok = m[n.selection.field]
+	return ok, nil
+}
+
+type indexing struct {
+	operand node
+	index   node
+}
+
+func (n *indexing) eval(a *activation) (interface{}, error) {
+	if err := a.step(); err != nil {
+		return nil, err
+	}
+	operand, err := n.operand.eval(a)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(a)
+	if err != nil {
+		return nil, err
+	}
+	switch operand := operand.(type) {
+	case []interface{}:
+		i, ok := index.(int64)
+		if !ok {
+			if d, isDouble := index.(float64); isDouble && d == math.Trunc(d) {
+				i, ok = int64(d), true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("can't index a list with %v", typeName(index))
+		}
+		if i < 0 || i >= int64(len(operand)) {
+			return nil, fmt.Errorf("index out of range: %v", i)
+		}
+		return normalize(operand[i]), nil
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("can't index a map with %v", typeName(index))
+		}
+		value, ok := operand[key]
+		if !ok {
+			return nil, fmt.Errorf("no such key: %v", key)
+		}
+		return normalize(value), nil
+	}
+	return nil, fmt.Errorf("can't index %v", typeName(operand))
+}
+
+type list struct {
+	elements []node
+}
+
+func (n *list) eval(a *activation) (interface{}, error) {
+	values := make([]interface{}, 0, len(n.elements))
+	for _, element := range n.elements {
+		value, err := element.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+type mapLiteral struct {
+	keys   []node
+	values []node
+}
+
+func (n *mapLiteral) eval(a *activation) (interface{}, error) {
+	m := make(map[string]interface{}, len(n.keys))
+	for i := range n.keys {
+		key, err := n.keys[i].eval(a)
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("map keys must be strings, not %v", typeName(key))
+		}
+		if _, ok := m[k]; ok {
+			return nil, fmt.Errorf("duplicate map key: %v", k)
+		}
+		value, err := n.values[i].eval(a)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = value
+	}
+	return m, nil
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (n *unary) eval(a *activation) (interface{}, error) {
+	if err := a.step(); err != nil {
+		return nil, err
+	}
+	operand, err := n.operand.eval(a)
+	if err != nil {
+		return nil, err
+	}
+	switch operand := operand.(type) {
+	case bool:
+		if n.op == "!" {
+			return !operand, nil
+		}
+	case int64:
+		if n.op == "-" {
+			if operand == math.MinInt64 {
+				return nil, fmt.Errorf("int overflow")
+			}
+			return -operand, nil
+		}
+	case float64:
+		if n.op == "-" {
+			return -operand, nil
+		}
+	}
+	return nil, noOverload(n.op, operand)
+}
+
+// logical is && or ||, which are commutative as far as errors go: an error
+// on one side is ignored if the other side decides the result.
+type logical struct {
+	and         bool
+	left, right node
+}
+
+func (n *logical) eval(a *activation) (interface{}, error) {
+	if err := a.step(); err != nil {
+		return nil, err
+	}
+	op := "||"
+	if n.and {
+		op = "&&"
+	}
+	left, leftErr := n.left.eval(a)
+	if leftErr == nil {
+		l, ok := left.(bool)
+		if !ok {
+			leftErr = noOverload(op, left)
+		} else if l != n.and {
+			// false && ..., true || ...
+			return l, nil
+		}
+	}
+	right, rightErr := n.right.eval(a)
+	if rightErr == nil {
+		r, ok := right.(bool)
+		if !ok {
+			rightErr = noOverload(op, right)
+		} else if r != n.and {
+			return r, nil
+		}
+	}
+	if leftErr != nil {
+		return nil, leftErr
+	}
+	if rightErr != nil {
+		return nil, rightErr
+	}
+	return n.and, nil
+}
+
+type conditional struct {
+	cond, then, otherwise node
+}
+
+func (n *conditional) eval(a *activation) (interface{}, error) {
+	if err := a.step(); err != nil {
+		return nil, err
+	}
+	cond, err := n.cond.eval(a)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := cond.(bool)
+	if !ok {
+		return nil, noOverload("?:", cond)
+	}
+	if c {
+		return n.then.eval(a)
+	}
+	return n.otherwise.eval(a)
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (n *binary) eval(a *activation) (interface{}, error) {
+	if err := a.step(); err != nil {
+		return nil, err
+	}
+	left, err := n.left.eval(a)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(a)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		c, ok := compare(left, right)
+		if !ok {
+			return nil, noOverload(n.op, left, right)
+		}
+		if c == unordered {
+			return false, nil
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "in":
+		switch right := right.(type) {
+		case []interface{}:
+			for _, element := range right {
+				if err := a.step(); err != nil {
+					return nil, err
+				}
+				if equal(left, normalize(element)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]interface{}:
+			key, ok := left.(string)
+			if !ok {
+				return false, nil
+			}
+			_, ok = right[key]
+			return ok, nil
+		}
+		return nil, noOverload(n.op, left, right)
+	}
+	return arithmetic(n.op, left, right)
+}
+
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+	switch l := left.(type) {
+	case int64:
+		r, ok := right.(int64)
+		if !ok {
+			break
+		}
+		switch op {
+		case "+":
+			sum := l + r
+			if (sum > l) != (r > 0) {
+				return nil, fmt.Errorf("int overflow")
+			}
+			return sum, nil
+		case "-":
+			difference := l - r
+			if (difference < l) != (r > 0) {
+				return nil, fmt.Errorf("int overflow")
+			}
+			return difference, nil
+		case "*":
+			product := l * r
+			if l != 0 && (product/l != r || (l == -1 && r == math.MinInt64)) {
+				return nil, fmt.Errorf("int overflow")
+			}
+			return product, nil
+		case "/", "%":
+			if r == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if l == math.MinInt64 && r == -1 {
+				return nil, fmt.Errorf("int overflow")
+			}
+			if op == "/" {
+				return l / r, nil
+			}
+			return l % r, nil
+		}
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			break
+		}
+		switch op {
+		case "+":
+			return l + r, nil
+		case "-":
+			return l - r, nil
+		case "*":
+			return l * r, nil
+		case "/":
+			return l / r, nil
+		}
+	case string:
+		if r, ok := right.(string); ok && op == "+" {
+			return l + r, nil
+		}
+	case []interface{}:
+		if r, ok := right.([]interface{}); ok && op == "+" {
+			return append(append(make([]interface{}, 0, len(l)+len(r)), l...), r...), nil
+		}
+	}
+	return nil, noOverload(op, left, right)
+}
+
+type comprehensionKind int
+
+const (
+	comprehensionAll comprehensionKind = iota
+	comprehensionExists
+	comprehensionExistsOne
+	comprehensionFilter
+	comprehensionMap
+)
+
+var comprehensionKinds = map[string]comprehensionKind{
+	"all":        comprehensionAll,
+	"exists":     comprehensionExists,
+	"exists_one": comprehensionExistsOne,
+	"filter":     comprehensionFilter,
+	"map":        comprehensionMap,
+}
+
+// comprehension is one of the macros that iterate over the elements of a
+// list, or the keys of a map.
+type comprehension struct {
+	kind     comprehensionKind
+	target   node
+	variable string
+	// filter is the optional filter of map(x, filter, body).
+	filter node
+	body   node
+}
+
+func (n *comprehension) eval(a *activation) (interface{}, error) {
+	target, err := n.target.eval(a)
+	if err != nil {
+		return nil, err
+	}
+	var elements []interface{}
+	switch target := target.(type) {
+	case []interface{}:
+		elements = target
+	case map[string]interface{}:
+		for key := range target {
+			elements = append(elements, key)
+		}
+	default:
+		return nil, fmt.Errorf("can't iterate over %v", typeName(target))
+	}
+
+	shadowed, wasSet := a.vars[n.variable]
+	defer func() {
+		if wasSet {
+			a.vars[n.variable] = shadowed
+		} else {
+			delete(a.vars, n.variable)
+		}
+	}()
+
+	matches := 0
+	var results []interface{}
+	// As for &&, errors are ignored if another element decides the result.
+	var firstErr error
+	for _, element := range elements {
+		if err := a.step(); err != nil {
+			return nil, err
+		}
+		a.vars[n.variable] = normalize(element)
+		predicate := n.body
+		if n.kind == comprehensionMap {
+			predicate = n.filter
+		}
+		keep := true
+		if predicate != nil {
+			value, err := predicate.eval(a)
+			if err != nil {
+				if n.kind == comprehensionAll || n.kind == comprehensionExists {
+					if firstErr == nil {
+						firstErr = err
+					}
+					continue
+				}
+				return nil, err
+			}
+			b, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("predicate of %v must be a bool, not %v", n.name(), typeName(value))
+			}
+			keep = b
+		}
+		switch n.kind {
+		case comprehensionAll:
+			if !keep {
+				return false, nil
+			}
+		case comprehensionExists:
+			if keep {
+				return true, nil
+			}
+		case comprehensionExistsOne:
+			if keep {
+				matches++
+			}
+		case comprehensionFilter:
+			if keep {
+				results = append(results, a.vars[n.variable])
+			}
+		case comprehensionMap:
+			if keep {
+				value, err := n.body.eval(a)
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, value)
+			}
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	switch n.kind {
+	case comprehensionAll:
+		return true, nil
+	case comprehensionExists:
+		return false, nil
+	case comprehensionExistsOne:
+		return matches == 1, nil
+	}
+	if results == nil {
+		results = []interface{}{}
+	}
+	return results, nil
+}
+
+func (n *comprehension) name() string {
+	for name, kind := range comprehensionKinds {
+		if kind == n.kind {
+			return name + "()"
+		}
+	}
+	return "comprehension"
+}
+
+// functions are the functions that can be called, by name, with the
+// number of arguments they take, including the target of a method call.
+var functions = map[string]struct {
+	method, global bool
+	args           int
+}{
+	"size":       {method: true, global: true, args: 1},
+	"contains":   {method: true, args: 2},
+	"startsWith": {method: true, args: 2},
+	"endsWith":   {method: true, args: 2},
+	"matches":    {method: true, global: true, args: 2},
+	"int":        {global: true, args: 1},
+	"double":     {global: true, args: 1},
+	"string":     {global: true, args: 1},
+}
+
+type call struct {
+	function string
+	// args includes the target of a method call first.
+	args []node
+	// pattern is the compiled regular expression of matches(), if it's a
+	// literal.
+	pattern *regexp.Regexp
+}
+
+func newCall(name token, target node, args []node) (node, error) {
+	f, ok := functions[name.text]
+	if !ok || (target != nil && !f.method) || (target == nil && !f.global) {
+		return nil, fmt.Errorf("undeclared reference to %q at %v", name.text, name.pos)
+	}
+	if target != nil {
+		args = append([]node{target}, args...)
+	}
+	if len(args) != f.args {
+		return nil, fmt.Errorf("%v() takes %v arguments at %v", name.text, f.args-btoi(target != nil), name.pos)
+	}
+	c := &call{function: name.text, args: args}
+	if c.function == "matches" {
+		if pattern, ok := args[1].(*literal); ok {
+			s, ok := pattern.value.(string)
+			if !ok {
+				return nil, fmt.Errorf("matches() takes a string pattern at %v", name.pos)
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern at %v: %v", name.pos, err)
+			}
+			c.pattern = re
+		}
+	}
+	return c, nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (n *call) eval(a *activation) (interface{}, error) {
+	if err := a.step(); err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	switch n.function {
+	case "size":
+		switch arg := args[0].(type) {
+		case string:
+			return int64(utf8.RuneCountInString(arg)), nil
+		case []interface{}:
+			return int64(len(arg)), nil
+		case map[string]interface{}:
+			return int64(len(arg)), nil
+		}
+	case "contains", "startsWith", "endsWith", "matches":
+		s, ok := args[0].(string)
+		arg, argOK := args[1].(string)
+		if !ok || !argOK {
+			break
+		}
+		switch n.function {
+		case "contains":
+			return strings.Contains(s, arg), nil
+		case "startsWith":
+			return strings.HasPrefix(s, arg), nil
+		case "endsWith":
+			return strings.HasSuffix(s, arg), nil
+		}
+		re := n.pattern
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(arg); err != nil {
+				return nil, fmt.Errorf("invalid pattern: %v", err)
+			}
+		}
+		return re.MatchString(s), nil
+	case "int":
+		switch arg := args[0].(type) {
+		case int64:
+			return arg, nil
+		case float64:
+			if math.IsNaN(arg) || arg <= math.MinInt64 || arg >= math.MaxInt64 {
+				return nil, fmt.Errorf("int overflow")
+			}
+			return int64(arg), nil
+		case string:
+			i, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("can't convert %q to int", arg)
+			}
+			return i, nil
+		}
+	case "double":
+		switch arg := args[0].(type) {
+		case int64:
+			return float64(arg), nil
+		case float64:
+			return arg, nil
+		case string:
+			d, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("can't convert %q to double", arg)
+			}
+			return d, nil
+		}
+	case "string":
+		switch arg := args[0].(type) {
+		case string:
+			return arg, nil
+		case bool:
+			return strconv.FormatBool(arg), nil
+		case int64:
+			return strconv.FormatInt(arg, 10), nil
+		case float64:
+			return strconv.FormatFloat(arg, 'g', -1, 64), nil
+		}
+	}
+	return nil, noOverload(n.function, args...)
+}
+
+// equal is CEL's heterogeneous equality: values of different types are
+// never equal, except numbers, which are compared by value.
+func equal(left, right interface{}) bool {
+	if c, ok := compareNumbers(left, right); ok {
+		return c == 0
+	}
+	switch l := left.(type) {
+	case []interface{}:
+		r, ok := right.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !equal(normalize(l[i]), normalize(r[i])) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		r, ok := right.(map[string]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for key, value := range l {
+			other, ok := r[key]
+			if !ok || !equal(normalize(value), normalize(other)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.TypeOf(left) == reflect.TypeOf(right) && left == right
+}
+
+// compare returns -1, 0 or 1 as left is less than, equal to or greater than
+// right, if they can be ordered.
+func compare(left, right interface{}) (int, bool) {
+	if c, ok := compareNumbers(left, right); ok {
+		return c, true
+	}
+	switch l := left.(type) {
+	case string:
+		if r, ok := right.(string); ok {
+			return strings.Compare(l, r), true
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			switch {
+			case l == r:
+				return 0, true
+			case r:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func compareNumbers(left, right interface{}) (int, bool) {
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			switch {
+			case l < r:
+				return -1, true
+			case l > r:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	l, ok := toDouble(left)
+	if !ok {
+		return 0, false
+	}
+	r, ok := toDouble(right)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case l < r:
+		return -1, true
+	case l > r:
+		return 1, true
+	case l == r:
+		return 0, true
+	}
+	// NaN isn't ordered, nor equal to anything.
+	return unordered, true
+}
+
+// unordered is what compareNumbers returns when either number is NaN.
+const unordered = 2
+
+func toDouble(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case int64:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+// normalize converts numbers to int64 or float64, the only numeric types
+// expressions deal with, since objects decoded by other means than
+// encoding/json may hold others.
+func normalize(value interface{}) interface{} {
+	switch value := value.(type) {
+	case int:
+		return int64(value)
+	case int32:
+		return int64(value)
+	case uint32:
+		return int64(value)
+	case float32:
+		return float64(value)
+	}
+	return value
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func noOverload(function string, args ...interface{}) error {
+	types := make([]string, len(args))
+	for i, arg := range args {
+		types[i] = typeName(arg)
+	}
+	return fmt.Errorf("no matching overload for %v applied to (%v)", function, strings.Join(types, ", "))
+}
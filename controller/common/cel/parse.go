@@ -0,0 +1,666 @@
+package cel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenDouble
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	// text is the identifier, the operator or punctuation, or the source of
+	// a number. For strings, it's the unquoted value.
+	text string
+	pos  int
+}
+
+// punctuation lists the operators and punctuation, longest first, so the
+// lexer matches "==" before "=".
+var punctuation = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "!", "+", "-", "*", "/", "%", "?", ":", ".", ",", "(", ")", "[", "]", "{", "}",
+}
+
+// reserved are identifiers that can't name variables or fields selected
+// without quotes.
+var reserved = map[string]bool{
+	"as": true, "break": true, "const": true, "continue": true, "else": true,
+	"for": true, "function": true, "if": true, "import": true, "let": true,
+	"loop": true, "package": true, "namespace": true, "return": true,
+	"var": true, "void": true, "while": true,
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case strings.HasPrefix(src[i:], "//"):
+			// Comments run to the end of the line.
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			i += end
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			ident := src[start:i]
+			if (ident == "r" || ident == "R") && i < len(src) && (src[i] == '\'' || src[i] == '"') {
+				value, end, err := lexString(src, i, true)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, token{kind: tokenString, text: value, pos: start})
+				i = end
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: ident, pos: start})
+		case r >= '0' && r <= '9':
+			start := i
+			kind, end := lexNumber(src, i)
+			tokens = append(tokens, token{kind: kind, text: src[start:end], pos: start})
+			i = end
+		case r == '\'' || r == '"':
+			value, end, err := lexString(src, i, false)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value, pos: i})
+			i = end
+		default:
+			matched := false
+			for _, p := range punctuation {
+				if strings.HasPrefix(src[i:], p) {
+					tokens = append(tokens, token{kind: tokenPunct, text: p, pos: i})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at %v", r, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// lexNumber returns the kind and the end of the number starting at i.
+func lexNumber(src string, i int) (tokenKind, int) {
+	if strings.HasPrefix(src[i:], "0x") || strings.HasPrefix(src[i:], "0X") {
+		i += 2
+		for i < len(src) && strings.IndexByte("0123456789abcdefABCDEF", src[i]) >= 0 {
+			i++
+		}
+		if i < len(src) && (src[i] == 'u' || src[i] == 'U') {
+			i++
+		}
+		return tokenInt, i
+	}
+	kind := tokenInt
+	digits := func() {
+		for i < len(src) && src[i] >= '0' && src[i] <= '9' {
+			i++
+		}
+	}
+	digits()
+	if i+1 < len(src) && src[i] == '.' && src[i+1] >= '0' && src[i+1] <= '9' {
+		kind = tokenDouble
+		i++
+		digits()
+	}
+	if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		j := i + 1
+		if j < len(src) && (src[j] == '+' || src[j] == '-') {
+			j++
+		}
+		if j < len(src) && src[j] >= '0' && src[j] <= '9' {
+			kind = tokenDouble
+			i = j
+			digits()
+		}
+	}
+	if kind == tokenInt && i < len(src) && (src[i] == 'u' || src[i] == 'U') {
+		i++
+	}
+	return kind, i
+}
+
+// lexString returns the value and the end of the string literal whose
+// opening quote is at i.
+func lexString(src string, i int, raw bool) (string, int, error) {
+	start := i
+	quote := src[i : i+1]
+	if strings.HasPrefix(src[i:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	i += len(quote)
+	var value strings.Builder
+	for {
+		if i >= len(src) {
+			return "", 0, fmt.Errorf("unterminated string at %v", start)
+		}
+		if strings.HasPrefix(src[i:], quote) {
+			return value.String(), i + len(quote), nil
+		}
+		if len(quote) == 1 && src[i] == '\n' {
+			return "", 0, fmt.Errorf("unterminated string at %v", start)
+		}
+		if src[i] != '\\' || raw {
+			value.WriteByte(src[i])
+			i++
+			continue
+		}
+		if i+1 >= len(src) {
+			return "", 0, fmt.Errorf("unterminated string at %v", start)
+		}
+		escape := src[i+1]
+		i += 2
+		switch escape {
+		case '\\', '\'', '"', '`', '?':
+			value.WriteByte(escape)
+		case 'a':
+			value.WriteByte('\a')
+		case 'b':
+			value.WriteByte('\b')
+		case 'f':
+			value.WriteByte('\f')
+		case 'n':
+			value.WriteByte('\n')
+		case 'r':
+			value.WriteByte('\r')
+		case 't':
+			value.WriteByte('\t')
+		case 'v':
+			value.WriteByte('\v')
+		case 'x', 'u', 'U':
+			size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[escape]
+			if i+size > len(src) {
+				return "", 0, fmt.Errorf("invalid escape sequence at %v", i-2)
+			}
+			code, err := strconv.ParseUint(src[i:i+size], 16, 32)
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid escape sequence at %v", i-2)
+			}
+			if escape == 'x' {
+				value.WriteByte(byte(code))
+			} else {
+				value.WriteRune(rune(code))
+			}
+			i += size
+		default:
+			return "", 0, fmt.Errorf("invalid escape sequence at %v", i-2)
+		}
+	}
+}
+
+// parser is a recursive descent parser of the CEL grammar, from
+// https://github.com/google/cel-spec/blob/master/doc/langdef.md#syntax.
+type parser struct {
+	tokens []token
+	pos    int
+	// scopes are the variables declared by enclosing macros, innermost last.
+	scopes []string
+}
+
+func parse(src string) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it's the punctuation or keyword text.
+func (p *parser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokenPunct || t.kind == tokenIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %q at %v", text, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at %v", t.text, t.pos)
+}
+
+func (p *parser) expr() (node, error) {
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("?") {
+		return cond, nil
+	}
+	then, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &conditional{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.relation()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.relation()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) relation() (node, error) {
+	left, err := p.addition()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		op := t.text
+		if !(t.kind == tokenPunct && (op == "==" || op == "!=" || op == "<" || op == "<=" || op == ">" || op == ">=")) &&
+			!(t.kind == tokenIdent && op == "in") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.addition()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) addition() (node, error) {
+	left, err := p.multiplication()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenPunct || (t.text != "+" && t.text != "-") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.multiplication()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) multiplication() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenPunct || (t.text != "*" && t.text != "/" && t.text != "%") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: "!", operand: operand}, nil
+	}
+	if p.accept("-") {
+		// Fold negative literals, so the smallest int can be written.
+		if t := p.peek(); t.kind == tokenInt || t.kind == tokenDouble {
+			p.next()
+			value, err := parseNumber(t, true)
+			if err != nil {
+				return nil, err
+			}
+			return p.member(&literal{value: value})
+		}
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: "-", operand: operand}, nil
+	}
+	primary, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	return p.member(primary)
+}
+
+func (p *parser) member(operand node) (node, error) {
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokenIdent {
+				return nil, p.unexpected(t)
+			}
+			if p.peek().text == "(" && p.peek().kind == tokenPunct {
+				p.next()
+				call, err := p.method(operand, t)
+				if err != nil {
+					return nil, err
+				}
+				operand = call
+				continue
+			}
+			operand = &selection{operand: operand, field: t.text}
+		case p.accept("["):
+			index, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			operand = &indexing{operand: operand, index: index}
+		default:
+			return operand, nil
+		}
+	}
+}
+
+// method parses the arguments of a method call on target, after the
+// opening parenthesis, including the macros that take a variable.
+func (p *parser) method(target node, name token) (node, error) {
+	if kind, ok := comprehensionKinds[name.text]; ok {
+		variable := p.next()
+		if variable.kind != tokenIdent || reserved[variable.text] {
+			return nil, fmt.Errorf("%v() must declare a variable at %v", name.text, variable.pos)
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		p.scopes = append(p.scopes, variable.text)
+		defer func() { p.scopes = p.scopes[:len(p.scopes)-1] }()
+		body, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		c := &comprehension{kind: kind, target: target, variable: variable.text, body: body}
+		if kind == comprehensionMap && p.accept(",") {
+			// map(x, filter, transform)
+			c.filter = body
+			if c.body, err = p.expr(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	args, err := p.args(")")
+	if err != nil {
+		return nil, err
+	}
+	return newCall(name, target, args)
+}
+
+// args parses a comma-separated list of expressions up to the closing text.
+func (p *parser) args(closing string) ([]node, error) {
+	var args []node
+	if p.accept(closing) {
+		return args, nil
+	}
+	for {
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(closing) {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		// Trailing commas are allowed.
+		if p.accept(closing) {
+			return args, nil
+		}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenInt, tokenDouble:
+		value, err := parseNumber(t, false)
+		if err != nil {
+			return nil, err
+		}
+		return &literal{value: value}, nil
+	case tokenString:
+		return &literal{value: t.text}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null":
+			return &literal{value: nil}, nil
+		}
+		if reserved[t.text] || t.text == "in" {
+			return nil, fmt.Errorf("reserved identifier %q at %v", t.text, t.pos)
+		}
+		if p.peek().kind == tokenPunct && p.peek().text == "(" {
+			p.next()
+			if t.text == "has" {
+				return p.has(t)
+			}
+			args, err := p.args(")")
+			if err != nil {
+				return nil, err
+			}
+			return newCall(t, nil, args)
+		}
+		if !p.declared(t.text) {
+			return nil, fmt.Errorf("undeclared reference to %q at %v", t.text, t.pos)
+		}
+		return &identifier{name: t.text}, nil
+	case tokenPunct:
+		switch t.text {
+		case "(":
+			n, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		case "[":
+			elements, err := p.args("]")
+			if err != nil {
+				return nil, err
+			}
+			return &list{elements: elements}, nil
+		case "{":
+			return p.mapLiteral()
+		case ".":
+			// A leading dot only qualifies a name in the root scope.
+			ident := p.next()
+			if ident.kind != tokenIdent || ident.text != Variable {
+				return nil, p.unexpected(ident)
+			}
+			return &identifier{name: ident.text}, nil
+		}
+	}
+	return nil, p.unexpected(t)
+}
+
+func (p *parser) has(t token) (node, error) {
+	arg, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	sel, ok := arg.(*selection)
+	if !ok {
+		return nil, fmt.Errorf("invalid argument to has() at %v: it must be a field selection", t.pos)
+	}
+	return &presence{selection: sel}, nil
+}
+
+func (p *parser) mapLiteral() (node, error) {
+	m := &mapLiteral{}
+	if p.accept("}") {
+		return m, nil
+	}
+	for {
+		key, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		m.keys = append(m.keys, key)
+		m.values = append(m.values, value)
+		if p.accept("}") {
+			return m, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if p.accept("}") {
+			return m, nil
+		}
+	}
+}
+
+func (p *parser) declared(name string) bool {
+	for _, scope := range p.scopes {
+		if scope == name {
+			return true
+		}
+	}
+	return name == Variable
+}
+
+func parseNumber(t token, negative bool) (interface{}, error) {
+	text := t.text
+	if negative {
+		text = "-" + text
+	}
+	if t.kind == tokenDouble {
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double %q at %v", text, t.pos)
+		}
+		return value, nil
+	}
+	// Unsigned ints are handled as ints, since JSON numbers have no sign
+	// distinction anyway.
+	text = strings.TrimRight(text, "uU")
+	base := 10
+	if digits := strings.TrimPrefix(text, "-"); strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		base = 16
+		text = strings.Replace(strings.Replace(text, "0x", "", 1), "0X", "", 1)
+	}
+	value, err := strconv.ParseInt(text, base, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid int %q at %v", text, t.pos)
+	}
+	return value, nil
+}
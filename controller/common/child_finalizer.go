@@ -0,0 +1,130 @@
+package common
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/controller/common/finalizer"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	"metacontroller.io/events"
+)
+
+// ChildFinalizerTimeout is how long a child that's pending deletion is held
+// at most, if the sync hook can't be called successfully in the meantime.
+const ChildFinalizerTimeout = 10 * time.Minute
+
+// ChildFinalizer places the controller's finalizer on children of selected
+// kinds, so that hooks get a chance to act before those children disappear.
+//
+// A child that's pending deletion keeps the finalizer until the sync hook has
+// been called with it, or until ChildFinalizerTimeout has passed, or until
+// the parent itself is finalized.
+type ChildFinalizer struct {
+	*finalizer.Manager
+	Kinds map[schema.GroupKind]bool
+	// EventRecorder records why children pending deletion are held.
+	EventRecorder record.EventRecorder
+}
+
+// IsEnabled returns true if children of the given kind should carry
+// the finalizer. It's safe to call on a nil ChildFinalizer.
+func (f *ChildFinalizer) IsEnabled(apiGroup, kind string) bool {
+	if f == nil {
+		return false
+	}
+	return f.Kinds[schema.GroupKind{Group: apiGroup, Kind: kind}]
+}
+
+// ownsFinalizer returns true if obj carries the finalizer under any of its
+// names.
+func (f *ChildFinalizer) ownsFinalizer(obj *unstructured.Unstructured) bool {
+	return f != nil && f.Manager != nil && f.HasFinalizer(obj)
+}
+
+// ReleaseChildren removes the finalizer from all the given children.
+// It should be called before the parent itself is finalized, since there
+// will be nobody left to release the children afterwards.
+func (f *ChildFinalizer) ReleaseChildren(dynClient *dynamicclientset.Clientset, children ChildMap) error {
+	if f == nil || f.Manager == nil {
+		return nil
+	}
+	var errs []error
+	for key, objects := range children {
+		apiVersion, kind := ParseChildMapKey(key)
+		client, err := dynClient.Kind(apiVersion, kind)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, obj := range objects {
+			if !f.ownsFinalizer(obj) {
+				continue
+			}
+			klog.InfoS("Releasing finalizer", "child", klog.KObj(obj), "finalizer", f.Name)
+			if _, err := f.RemoveFinalizer(client, obj); err != nil {
+				errs = append(errs, fmt.Errorf("can't remove finalizer from %v: %v", describeObject(obj), err))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// HoldChildren keeps the finalizer on the given children that are pending
+// deletion until the sync hook has been called with them, and records an
+// event on each explaining why it's held. Children held for longer than
+// ChildFinalizerTimeout are released right away, so a failing hook can't
+// block their deletion forever.
+// It returns how long until the next held child times out, or 0 if none is
+// held, so the parent can be resynced by then.
+func (f *ChildFinalizer) HoldChildren(dynClient *dynamicclientset.Clientset, parent *unstructured.Unstructured, children ChildMap) (time.Duration, error) {
+	if f == nil || f.Manager == nil {
+		return 0, nil
+	}
+	var next time.Duration
+	var errs []error
+	for key, objects := range children {
+		for _, obj := range objects {
+			deletionTimestamp := obj.GetDeletionTimestamp()
+			if deletionTimestamp == nil || !f.ownsFinalizer(obj) {
+				continue
+			}
+			if remaining := time.Until(deletionTimestamp.Add(ChildFinalizerTimeout)); remaining > 0 {
+				f.recordEvent(obj, v1.EventTypeNormal, events.ReasonChildHeld,
+					"Holding finalizer %v until the sync hook of %v %v/%v has been called with this object pending deletion, or for at most %v",
+					f.Name, parent.GetKind(), parent.GetNamespace(), parent.GetName(), ChildFinalizerTimeout)
+				if next == 0 || remaining < next {
+					next = remaining
+				}
+				continue
+			}
+			apiVersion, kind := ParseChildMapKey(key)
+			client, err := dynClient.Kind(apiVersion, kind)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			klog.InfoS("Releasing finalizer after timeout", "parent", klog.KObj(parent), "child", klog.KObj(obj), "finalizer", f.Name)
+			if _, err := f.RemoveFinalizer(client, obj); err != nil {
+				errs = append(errs, fmt.Errorf("can't remove finalizer from %v: %v", describeObject(obj), err))
+				continue
+			}
+			f.recordEvent(obj, v1.EventTypeWarning, events.ReasonChildReleased,
+				"Released finalizer %v since the sync hook of %v %v/%v wasn't called successfully within %v",
+				f.Name, parent.GetKind(), parent.GetNamespace(), parent.GetName(), ChildFinalizerTimeout)
+		}
+	}
+	return next, utilerrors.NewAggregate(errs)
+}
+
+func (f *ChildFinalizer) recordEvent(obj *unstructured.Unstructured, eventType, reason, messageFmt string, args ...interface{}) {
+	if f.EventRecorder != nil {
+		f.EventRecorder.Eventf(obj, eventType, reason, messageFmt, args...)
+	}
+}
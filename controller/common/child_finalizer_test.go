@@ -0,0 +1,71 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+
+	"metacontroller.io/controller/common/finalizer"
+)
+
+func TestChildFinalizerIsEnabled(t *testing.T) {
+	var nilFinalizer *ChildFinalizer
+	if nilFinalizer.IsEnabled("apps", "Deployment") {
+		t.Error("Expected nil ChildFinalizer to be disabled for every kind")
+	}
+
+	childFinalizer := &ChildFinalizer{
+		Kinds: map[schema.GroupKind]bool{{Group: "", Kind: "Service"}: true},
+	}
+	if !childFinalizer.IsEnabled("", "Service") {
+		t.Error("Expected ChildFinalizer to be enabled for Service")
+	}
+	if childFinalizer.IsEnabled("apps", "Deployment") {
+		t.Error("Expected ChildFinalizer to be disabled for Deployment")
+	}
+}
+
+func TestChildFinalizerHoldChildren(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	childFinalizer := &ChildFinalizer{
+		Manager:       finalizer.NewManager("metacontroller.io/compositecontroller-test", nil, true),
+		Kinds:         map[schema.GroupKind]bool{{Group: "", Kind: "Service"}: true},
+		EventRecorder: recorder,
+	}
+	parent := &unstructured.Unstructured{}
+	parent.SetKind("Parent")
+	parent.SetNamespace("default")
+	parent.SetName("parent")
+
+	deleted := &unstructured.Unstructured{}
+	deleted.SetAPIVersion("v1")
+	deleted.SetKind("Service")
+	deleted.SetNamespace("default")
+	deleted.SetName("deleted")
+	deleted.SetFinalizers([]string{childFinalizer.Name})
+	deletionTimestamp := metav1.NewTime(time.Now())
+	deleted.SetDeletionTimestamp(&deletionTimestamp)
+
+	alive := deleted.DeepCopy()
+	alive.SetName("alive")
+	alive.SetDeletionTimestamp(nil)
+
+	children := make(ChildMap)
+	children.Insert(parent, deleted)
+	children.Insert(parent, alive)
+
+	holdFor, err := childFinalizer.HoldChildren(nil, parent, children)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if holdFor <= 0 || holdFor > ChildFinalizerTimeout {
+		t.Errorf("HoldChildren() = %v; want a duration up to %v", holdFor, ChildFinalizerTimeout)
+	}
+	if got := len(recorder.Events); got != 1 {
+		t.Fatalf("Expected 1 event for the held child, got %v", got)
+	}
+}
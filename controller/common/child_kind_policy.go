@@ -0,0 +1,86 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+// anyKind can be used in place of a Kind to match every kind in a group.
+const anyKind = "*"
+
+// ChildKindPolicy restricts which group/kinds controllers are allowed to
+// declare as children (CompositeController) or attachments
+// (DecoratorController).
+//
+// If Allowed is empty, every kind that isn't Forbidden is allowed.
+// Forbidden always takes precedence over Allowed.
+type ChildKindPolicy struct {
+	Allowed   []schema.GroupKind
+	Forbidden []schema.GroupKind
+}
+
+// ParseGroupKinds parses a comma-separated list of group/kinds in the
+// "Kind.group" form used by kubectl (e.g. "ClusterRoleBinding.rbac.authorization.k8s.io",
+// or just "ConfigMap" for core kinds). A Kind of "*" matches every kind in the
+// given group (e.g. "*.rbac.authorization.k8s.io").
+func ParseGroupKinds(list string) []schema.GroupKind {
+	var groupKinds []schema.GroupKind
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		groupKinds = append(groupKinds, schema.ParseGroupKind(item))
+	}
+	return groupKinds
+}
+
+// Check returns an error if the given resource may not be declared as a child
+// or attachment according to the policy.
+func (p ChildKindPolicy) Check(resource *dynamicdiscovery.APIResource) error {
+	gk := schema.GroupKind{Group: resource.Group, Kind: resource.Kind}
+	if containsGroupKind(p.Forbidden, gk) {
+		return fmt.Errorf("kind %v is forbidden as a child resource", gk)
+	}
+	if len(p.Allowed) > 0 && !containsGroupKind(p.Allowed, gk) {
+		return fmt.Errorf("kind %v is not in the list of allowed child resources", gk)
+	}
+	return nil
+}
+
+// CheckChildren returns an error if any of the desired children is of a kind
+// that isn't one of the declared child resources, or that the policy forbids.
+// Hooks can return children of any kind, so this is checked on every sync,
+// before any child is written, and not only when the controller is created.
+func (p ChildKindPolicy) CheckChildren(declared GroupKindMap, children ChildMap) error {
+	for key, group := range children {
+		for _, child := range group {
+			apiVersion, kind := ParseChildMapKey(key)
+			apiGroup, _ := ParseAPIVersion(apiVersion)
+			gk := schema.GroupKind{Group: apiGroup, Kind: kind}
+			resource := declared.Get(gk)
+			if resource == nil {
+				return fmt.Errorf("desired child %v %v/%v: kind %v isn't a declared child resource", kind, child.GetNamespace(), child.GetName(), gk)
+			}
+			if err := p.Check(resource); err != nil {
+				return fmt.Errorf("desired child %v %v/%v: %v", kind, child.GetNamespace(), child.GetName(), err)
+			}
+			// The other children of the group have the same kind.
+			break
+		}
+	}
+	return nil
+}
+
+func containsGroupKind(list []schema.GroupKind, gk schema.GroupKind) bool {
+	for _, item := range list {
+		if item.Group == gk.Group && (item.Kind == anyKind || item.Kind == gk.Kind) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+func newTestResource(group, kind string) *dynamicdiscovery.APIResource {
+	return &dynamicdiscovery.APIResource{
+		APIResource: metav1.APIResource{Group: group, Kind: kind},
+	}
+}
+
+func TestChildKindPolicyCheck(t *testing.T) {
+	table := []struct {
+		name               string
+		allowed, forbidden string
+		group, kind        string
+		wantErr            bool
+	}{
+		{
+			name:  "empty policy allows everything",
+			group: "rbac.authorization.k8s.io",
+			kind:  "ClusterRoleBinding",
+		},
+		{
+			name:      "forbidden kind",
+			forbidden: "ClusterRoleBinding.rbac.authorization.k8s.io",
+			group:     "rbac.authorization.k8s.io",
+			kind:      "ClusterRoleBinding",
+			wantErr:   true,
+		},
+		{
+			name:      "forbidden group wildcard",
+			forbidden: "*.rbac.authorization.k8s.io",
+			group:     "rbac.authorization.k8s.io",
+			kind:      "Role",
+			wantErr:   true,
+		},
+		{
+			name:    "allowed core kind",
+			allowed: "ConfigMap, Deployment.apps",
+			group:   "",
+			kind:    "ConfigMap",
+		},
+		{
+			name:    "not in allowed list",
+			allowed: "ConfigMap,Deployment.apps",
+			group:   "apps",
+			kind:    "StatefulSet",
+			wantErr: true,
+		},
+		{
+			name:      "forbidden takes precedence",
+			allowed:   "*.apps",
+			forbidden: "DaemonSet.apps",
+			group:     "apps",
+			kind:      "DaemonSet",
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := ChildKindPolicy{
+				Allowed:   ParseGroupKinds(tc.allowed),
+				Forbidden: ParseGroupKinds(tc.forbidden),
+			}
+			err := policy.Check(newTestResource(tc.group, tc.kind))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestChildKindPolicyCheckChildren(t *testing.T) {
+	declared := make(GroupKindMap)
+	declared.Set(schema.GroupKind{Kind: "ConfigMap"}, newTestResource("", "ConfigMap"))
+	declared.Set(schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}, newTestResource("rbac.authorization.k8s.io", "ClusterRoleBinding"))
+
+	child := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		return obj
+	}
+	table := []struct {
+		name      string
+		forbidden string
+		children  []*unstructured.Unstructured
+		wantErr   bool
+	}{
+		{
+			name:     "no children",
+			children: nil,
+		},
+		{
+			name:     "declared kinds",
+			children: []*unstructured.Unstructured{child("v1", "ConfigMap", "a"), child("rbac.authorization.k8s.io/v1", "ClusterRoleBinding", "b")},
+		},
+		{
+			name:      "hook returns a forbidden kind",
+			forbidden: "ClusterRoleBinding.rbac.authorization.k8s.io",
+			children:  []*unstructured.Unstructured{child("v1", "ConfigMap", "a"), child("rbac.authorization.k8s.io/v1", "ClusterRoleBinding", "b")},
+			wantErr:   true,
+		},
+		{
+			name:     "hook returns an undeclared kind",
+			children: []*unstructured.Unstructured{child("v1", "ConfigMap", "a"), child("rbac.authorization.k8s.io/v1", "RoleBinding", "b")},
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := ChildKindPolicy{Forbidden: ParseGroupKinds(tc.forbidden)}
+			parent := &unstructured.Unstructured{}
+			err := policy.CheckChildren(declared, MakeChildMap(parent, tc.children))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("CheckChildren() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// ChildPatches applies the child patches of a controller spec to the children
+// returned by its hooks, so policies that apply to every child don't have to
+// be implemented in every hook.
+type ChildPatches []childPatch
+
+type childPatch struct {
+	target   *v1alpha1.ChildPatchTarget
+	selector labels.Selector
+	// Only one of mergePatch and jsonPatch is set.
+	mergePatch []byte
+	jsonPatch  jsonpatch.Patch
+	images     []v1alpha1.ChildImage
+}
+
+// NewChildPatches parses the given child patches.
+func NewChildPatches(patches []v1alpha1.ChildPatch) (ChildPatches, error) {
+	var result ChildPatches
+	for i, patch := range patches {
+		p := childPatch{
+			target:   patch.Target,
+			selector: labels.Everything(),
+			images:   patch.Images,
+		}
+		if patch.Target != nil && patch.Target.LabelSelector != "" {
+			selector, err := labels.Parse(patch.Target.LabelSelector)
+			if err != nil {
+				return nil, fmt.Errorf("invalid childPatches[%v].target.labelSelector: %v", i, err)
+			}
+			p.selector = selector
+		}
+		if strings.TrimSpace(patch.Patch) != "" {
+			data, err := yaml.ToJSON([]byte(patch.Patch))
+			if err != nil {
+				return nil, fmt.Errorf("invalid childPatches[%v].patch: %v", i, err)
+			}
+			if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+				p.jsonPatch, err = jsonpatch.DecodePatch(data)
+				if err != nil {
+					return nil, fmt.Errorf("invalid childPatches[%v].patch: %v", i, err)
+				}
+			} else {
+				// yaml.ToJSON passes anything that looks like JSON through
+				// as-is, so check that it's a JSON object here, rather than
+				// failing on every sync.
+				var object map[string]interface{}
+				if err := json.Unmarshal(data, &object); err != nil || object == nil {
+					return nil, fmt.Errorf("invalid childPatches[%v].patch: must be a JSON patch or a merge patch object", i)
+				}
+				p.mergePatch = data
+			}
+		}
+		for j, image := range patch.Images {
+			if image.Name == "" {
+				return nil, fmt.Errorf("invalid childPatches[%v].images[%v]: name is required", i, j)
+			}
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// Apply patches children in place.
+func (patches ChildPatches) Apply(children []*unstructured.Unstructured) error {
+	for _, child := range children {
+		for _, patch := range patches {
+			if !patch.matches(child) {
+				continue
+			}
+			if err := patch.apply(child); err != nil {
+				return fmt.Errorf("can't patch child %v %v: %v", child.GetKind(), child.GetName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *childPatch) matches(child *unstructured.Unstructured) bool {
+	if p.target == nil {
+		return true
+	}
+	if p.target.APIVersion != "" && p.target.APIVersion != child.GetAPIVersion() {
+		return false
+	}
+	if p.target.Kind != "" && p.target.Kind != child.GetKind() {
+		return false
+	}
+	if p.target.Name != "" && p.target.Name != child.GetName() {
+		return false
+	}
+	return p.selector.Matches(labels.Set(child.GetLabels()))
+}
+
+func (p *childPatch) apply(child *unstructured.Unstructured) error {
+	if p.mergePatch != nil || p.jsonPatch != nil {
+		data, err := json.Marshal(child.UnstructuredContent())
+		if err != nil {
+			return err
+		}
+		if p.mergePatch != nil {
+			data, err = jsonpatch.MergePatch(data, p.mergePatch)
+		} else {
+			data, err = p.jsonPatch.Apply(data)
+		}
+		if err != nil {
+			return err
+		}
+		patched := &unstructured.Unstructured{}
+		if err := patched.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		// The patched child must still be the same object, since children are
+		// keyed by type and name.
+		if patched.GetAPIVersion() != child.GetAPIVersion() || patched.GetKind() != child.GetKind() || patched.GetName() != child.GetName() {
+			return fmt.Errorf("patches can't change apiVersion, kind or name")
+		}
+		child.Object = patched.Object
+	}
+	if len(p.images) > 0 {
+		rewriteImages(child.UnstructuredContent(), p.images)
+	}
+	return nil
+}
+
+// containerListFields are the fields holding lists of containers in Pod specs,
+// wherever they're embedded (e.g. in Pod templates).
+var containerListFields = map[string]bool{
+	"containers":          true,
+	"initContainers":      true,
+	"ephemeralContainers": true,
+}
+
+// rewriteImages rewrites the images of every container found in obj.
+func rewriteImages(obj interface{}, images []v1alpha1.ChildImage) {
+	switch obj := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range obj {
+			if list, ok := value.([]interface{}); ok && containerListFields[key] {
+				for _, item := range list {
+					container, ok := item.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					if image, ok := container["image"].(string); ok {
+						container["image"] = rewriteImage(image, images)
+					}
+				}
+				continue
+			}
+			rewriteImages(value, images)
+		}
+	case []interface{}:
+		for _, item := range obj {
+			rewriteImages(item, images)
+		}
+	}
+}
+
+// rewriteImage applies the first matching rewrite to image.
+func rewriteImage(image string, images []v1alpha1.ChildImage) string {
+	name, suffix := splitImage(image)
+	for _, rewrite := range images {
+		if rewrite.Name != name {
+			continue
+		}
+		if rewrite.NewName != "" {
+			name = rewrite.NewName
+		}
+		switch {
+		case rewrite.Digest != "":
+			suffix = "@" + rewrite.Digest
+		case rewrite.NewTag != "":
+			suffix = ":" + rewrite.NewTag
+		}
+		return name + suffix
+	}
+	return image
+}
+
+// splitImage splits an image reference into its name, and its tag or digest
+// (including the leading ":" or "@").
+func splitImage(image string) (name, suffix string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		// A tag next to a digest is ignored when pulling, so drop it.
+		name, _ := splitImage(image[:i])
+		return name, image[i:]
+	}
+	// A colon before the last slash separates a registry port, not a tag.
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i:]
+	}
+	return image, ""
+}
@@ -0,0 +1,166 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestChildPatches(t *testing.T) {
+	deploymentJSON := `{
+		"apiVersion": "apps/v1",
+		"kind": "Deployment",
+		"metadata": {"name": "web", "labels": {"app": "web"}},
+		"spec": {
+			"replicas": 1,
+			"template": {"spec": {
+				"initContainers": [{"name": "init", "image": "busybox"}],
+				"containers": [
+					{"name": "main", "image": "nginx:1.19"},
+					{"name": "sidecar", "image": "docker.io/envoyproxy/envoy@sha256:abc"}
+				]
+			}}
+		}
+	}`
+
+	table := []struct {
+		name    string
+		patches []v1alpha1.ChildPatch
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "merge patch in YAML",
+			patches: []v1alpha1.ChildPatch{{
+				Patch: "metadata:\n  labels:\n    team: a\nspec:\n  replicas: null\n",
+			}},
+			want: `{
+				"apiVersion": "apps/v1",
+				"kind": "Deployment",
+				"metadata": {"name": "web", "labels": {"app": "web", "team": "a"}},
+				"spec": {
+					"template": {"spec": {
+						"initContainers": [{"name": "init", "image": "busybox"}],
+						"containers": [
+							{"name": "main", "image": "nginx:1.19"},
+							{"name": "sidecar", "image": "docker.io/envoyproxy/envoy@sha256:abc"}
+						]
+					}}
+				}
+			}`,
+		},
+		{
+			name: "JSON patch",
+			patches: []v1alpha1.ChildPatch{{
+				Target: &v1alpha1.ChildPatchTarget{Kind: "Deployment"},
+				Patch:  `[{"op": "add", "path": "/spec/template/spec/tolerations", "value": [{"operator": "Exists"}]}]`,
+			}},
+			want: `{
+				"apiVersion": "apps/v1",
+				"kind": "Deployment",
+				"metadata": {"name": "web", "labels": {"app": "web"}},
+				"spec": {
+					"replicas": 1,
+					"template": {"spec": {
+						"initContainers": [{"name": "init", "image": "busybox"}],
+						"containers": [
+							{"name": "main", "image": "nginx:1.19"},
+							{"name": "sidecar", "image": "docker.io/envoyproxy/envoy@sha256:abc"}
+						],
+						"tolerations": [{"operator": "Exists"}]
+					}}
+				}
+			}`,
+		},
+		{
+			name: "images",
+			patches: []v1alpha1.ChildPatch{{
+				Images: []v1alpha1.ChildImage{
+					{Name: "busybox", NewName: "registry.example.com/busybox"},
+					{Name: "nginx", NewName: "registry.example.com:5000/nginx", NewTag: "1.20"},
+					{Name: "docker.io/envoyproxy/envoy", NewName: "registry.example.com/envoy"},
+				},
+			}},
+			want: `{
+				"apiVersion": "apps/v1",
+				"kind": "Deployment",
+				"metadata": {"name": "web", "labels": {"app": "web"}},
+				"spec": {
+					"replicas": 1,
+					"template": {"spec": {
+						"initContainers": [{"name": "init", "image": "registry.example.com/busybox"}],
+						"containers": [
+							{"name": "main", "image": "registry.example.com:5000/nginx:1.20"},
+							{"name": "sidecar", "image": "registry.example.com/envoy@sha256:abc"}
+						]
+					}}
+				}
+			}`,
+		},
+		{
+			name: "target doesn't match",
+			patches: []v1alpha1.ChildPatch{
+				{Target: &v1alpha1.ChildPatchTarget{Kind: "StatefulSet"}, Patch: `{"spec": {"replicas": 3}}`},
+				{Target: &v1alpha1.ChildPatchTarget{LabelSelector: "app!=web"}, Patch: `{"spec": {"replicas": 3}}`},
+			},
+			want: deploymentJSON,
+		},
+		{
+			name:    "name changed",
+			patches: []v1alpha1.ChildPatch{{Patch: `{"metadata": {"name": "other"}}`}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range table {
+		child := &unstructured.Unstructured{}
+		if err := json.Unmarshal([]byte(deploymentJSON), &child.Object); err != nil {
+			t.Fatalf("can't unmarshal child: %v", err)
+		}
+		patches, err := NewChildPatches(tc.patches)
+		if err != nil {
+			t.Errorf("%v: NewChildPatches() = %v", tc.name, err)
+			continue
+		}
+		err = patches.Apply([]*unstructured.Unstructured{child})
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%v: Apply() = nil, want error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: Apply() = %v", tc.name, err)
+			continue
+		}
+		var want map[string]interface{}
+		if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+			t.Fatalf("%v: can't unmarshal want: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(child.Object, want) {
+			t.Errorf("%v: Apply() = %#v, want %#v", tc.name, child.Object, want)
+		}
+	}
+}
+
+func TestNewChildPatchesInvalid(t *testing.T) {
+	table := []struct {
+		name  string
+		patch v1alpha1.ChildPatch
+	}{
+		{name: "bad selector", patch: v1alpha1.ChildPatch{Target: &v1alpha1.ChildPatchTarget{LabelSelector: "a in ("}}},
+		{name: "bad patch", patch: v1alpha1.ChildPatch{Patch: "{"}},
+		{name: "scalar patch", patch: v1alpha1.ChildPatch{Patch: "replicas"}},
+		{name: "null patch", patch: v1alpha1.ChildPatch{Patch: "null"}},
+		{name: "image without name", patch: v1alpha1.ChildPatch{Images: []v1alpha1.ChildImage{{NewTag: "1"}}}},
+	}
+	for _, tc := range table {
+		if _, err := NewChildPatches([]v1alpha1.ChildPatch{tc.patch}); err == nil {
+			t.Errorf("%v: NewChildPatches() = nil, want error", tc.name)
+		}
+	}
+}
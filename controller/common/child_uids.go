@@ -0,0 +1,134 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// ValidateUIDMismatchPolicy returns an error if policy isn't a known
+// UIDMismatchPolicy.
+func ValidateUIDMismatchPolicy(policy v1alpha1.UIDMismatchPolicy) error {
+	switch policy {
+	case "", v1alpha1.UIDMismatchAdopt, v1alpha1.UIDMismatchIgnore, v1alpha1.UIDMismatchReplace:
+		return nil
+	}
+	return fmt.Errorf("invalid uidMismatchPolicy %q", policy)
+}
+
+// ChildUIDs remembers the UIDs of the children of each parent, to tell when a
+// child was replaced by another object with the same name, which mustn't be
+// updated as if it was the child.
+// Only the children seen since the controller started are known.
+type ChildUIDs struct {
+	mutex sync.Mutex
+	// uids maps the queue key of each parent to the UID of each of its
+	// children, by child ID.
+	uids map[string]map[string]types.UID
+	// ignored maps the queue key of each parent to the IDs of the children
+	// whose replacements were ignored in its last sync.
+	ignored map[string]map[string]bool
+	// pending holds the IDs of the children whose replacements were ignored
+	// since the last call to Observe.
+	pending map[string]map[string]bool
+}
+
+// NewChildUIDs returns an empty ChildUIDs.
+func NewChildUIDs() *ChildUIDs {
+	return &ChildUIDs{
+		uids:    make(map[string]map[string]types.UID),
+		ignored: make(map[string]map[string]bool),
+		pending: make(map[string]map[string]bool),
+	}
+}
+
+// childID identifies a child among the children of parent.
+func childID(parent metav1.Object, obj *unstructured.Unstructured) string {
+	return childMapKey(obj.GetAPIVersion(), obj.GetKind()) + "/" + relativeName(parent, obj)
+}
+
+// Mismatched returns the UID of the child that obj replaced, if obj doesn't
+// belong to parent, whose queue key is key, but has the name of one of its
+// children.
+func (c *ChildUIDs) Mismatched(key string, parent *unstructured.Unstructured, obj *unstructured.Unstructured) (types.UID, bool) {
+	if belongsTo(obj, parent.GetUID()) {
+		return "", false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	uid, ok := c.uids[key][childID(parent, obj)]
+	if !ok || uid == obj.GetUID() {
+		return "", false
+	}
+	return uid, true
+}
+
+// Ignore records that the replacement obj of a child of parent is left alone
+// in the current sync, so the child isn't created in its place, and isn't
+// forgotten either.
+func (c *ChildUIDs) Ignore(key string, parent *unstructured.Unstructured, obj *unstructured.Unstructured) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.pending[key] == nil {
+		c.pending[key] = make(map[string]bool)
+	}
+	c.pending[key][childID(parent, obj)] = true
+}
+
+// Observe records the children of the parent with the given queue key, after
+// they were claimed in a sync.
+func (c *ChildUIDs) Observe(key string, parent *unstructured.Unstructured, children ChildMap) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	previous := c.uids[key]
+	uids := make(map[string]types.UID)
+	for _, obj := range children.List() {
+		uids[childID(parent, obj)] = obj.GetUID()
+	}
+	// Keep the UIDs of the children whose replacements were ignored, so
+	// they're still told apart in the next sync.
+	for id := range c.pending[key] {
+		if uid, ok := previous[id]; ok {
+			uids[id] = uid
+		}
+	}
+	c.uids[key] = uids
+	c.ignored[key] = c.pending[key]
+	delete(c.pending, key)
+}
+
+// WithoutIgnored returns the desired children of the parent with the given
+// queue key, without those whose replacements were ignored in its last sync.
+func (c *ChildUIDs) WithoutIgnored(key string, parent *unstructured.Unstructured, desired ChildMap) ChildMap {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ignored := c.ignored[key]
+	if len(ignored) == 0 {
+		return desired
+	}
+	result := make(ChildMap, len(desired))
+	for groupKey, objects := range desired {
+		result[groupKey] = make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if !ignored[childID(parent, obj)] {
+				result[groupKey][name] = obj
+			}
+		}
+	}
+	return result
+}
+
+// Forget drops what's known about the parent with the given queue key,
+// because it's gone or no longer managed by the controller.
+func (c *ChildUIDs) Forget(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.uids, key)
+	delete(c.ignored, key)
+	delete(c.pending, key)
+}
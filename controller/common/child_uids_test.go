@@ -0,0 +1,70 @@
+package common
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestChildUIDs(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("ns")
+	parent.SetName("parent")
+	parent.SetUID("parent-uid")
+	object := func(uid types.UID, owned bool) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("ns")
+		obj.SetName("config")
+		obj.SetUID(uid)
+		if owned {
+			obj.SetOwnerReferences([]metav1.OwnerReference{{UID: parent.GetUID()}})
+		}
+		return obj
+	}
+	const key = "ns/parent"
+
+	uids := NewChildUIDs()
+	if _, ok := uids.Mismatched(key, parent, object("foreign", false)); ok {
+		t.Errorf("Mismatched() = true before any child was observed")
+	}
+
+	uids.Observe(key, parent, MakeChildMap(parent, []*unstructured.Unstructured{object("child", true)}))
+	if _, ok := uids.Mismatched(key, parent, object("child", false)); ok {
+		t.Errorf("Mismatched() = true for the child itself")
+	}
+	if _, ok := uids.Mismatched(key, parent, object("recreated", true)); ok {
+		t.Errorf("Mismatched() = true for a child recreated by the parent")
+	}
+	foreign := object("foreign", false)
+	if uid, ok := uids.Mismatched(key, parent, foreign); !ok || uid != "child" {
+		t.Fatalf("Mismatched() = %q, %v, want %q, true", uid, ok, "child")
+	}
+
+	// Ignoring the replacement keeps the child from being created, and keeps
+	// its UID around for the next sync.
+	uids.Ignore(key, parent, foreign)
+	uids.Observe(key, parent, make(ChildMap))
+	desired := MakeChildMap(parent, []*unstructured.Unstructured{object("", false)})
+	if got := uids.WithoutIgnored(key, parent, desired).List(); len(got) != 0 {
+		t.Errorf("WithoutIgnored() = %v, want no children", got)
+	}
+	if _, ok := uids.Mismatched(key, parent, foreign); !ok {
+		t.Errorf("Mismatched() = false in the sync after the replacement was ignored")
+	}
+
+	// Once the replacement is gone, nothing is ignored anymore.
+	uids.Observe(key, parent, make(ChildMap))
+	if got := uids.WithoutIgnored(key, parent, desired).List(); len(got) != 1 {
+		t.Errorf("WithoutIgnored() = %v, want the desired child", got)
+	}
+
+	uids.Observe(key, parent, MakeChildMap(parent, []*unstructured.Unstructured{object("child", true)}))
+	uids.Forget(key)
+	if _, ok := uids.Mismatched(key, parent, foreign); ok {
+		t.Errorf("Mismatched() = true after Forget()")
+	}
+}
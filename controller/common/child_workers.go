@@ -0,0 +1,83 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// childWorkers runs the writes to the children of a sync, up to a given
+// number at a time, and collects their errors.
+type childWorkers struct {
+	// slots is nil if writes run one after the other.
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mutex sync.Mutex
+	errs  []error
+	// retryAfter is how soon to sync the parent again, for writes that must
+	// wait for a previous object to be deleted, or zero if none must.
+	retryAfter time.Duration
+}
+
+// newChildWorkers returns childWorkers that run up to parallelism writes at a
+// time, or one after the other if parallelism is 1 or less.
+func newChildWorkers(parallelism int) *childWorkers {
+	w := &childWorkers{}
+	if parallelism > 1 {
+		w.slots = make(chan struct{}, parallelism)
+	}
+	return w
+}
+
+// run runs write, or starts it as soon as there's a free slot.
+func (w *childWorkers) run(write func() error) {
+	if w.slots == nil {
+		w.record(write())
+		return
+	}
+	w.slots <- struct{}{}
+	w.wg.Add(1)
+	go func() {
+		defer func() {
+			<-w.slots
+			w.wg.Done()
+		}()
+		w.record(write())
+	}()
+}
+
+func (w *childWorkers) record(err error) {
+	if err == nil {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	var pending *pendingDeletionError
+	if errors.As(err, &pending) {
+		w.retryAfter = recreateDelay
+		return
+	}
+	w.errs = append(w.errs, err)
+}
+
+// wait waits for the writes started so far, and returns their errors, which
+// it forgets, so the workers can be used for the next batch of writes.
+func (w *childWorkers) wait() error {
+	w.wg.Wait()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	err := utilerrors.NewAggregate(w.errs)
+	w.errs = nil
+	return err
+}
+
+// retryDelay returns how soon to sync the parent again, because some writes
+// must wait for a previous object to be deleted, or zero.
+func (w *childWorkers) retryDelay() time.Duration {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.retryAfter
+}
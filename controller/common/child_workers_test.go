@@ -0,0 +1,50 @@
+package common
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChildWorkers(t *testing.T) {
+	table := []struct {
+		parallelism, wantMax int32
+	}{
+		{parallelism: 0, wantMax: 1},
+		{parallelism: 1, wantMax: 1},
+		{parallelism: 3, wantMax: 3},
+	}
+	for _, tc := range table {
+		workers := newChildWorkers(int(tc.parallelism))
+		var running, max int32
+		for i := 0; i < 10; i++ {
+			i := i
+			workers.run(func() error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					old := atomic.LoadInt32(&max)
+					if n <= old || atomic.CompareAndSwapInt32(&max, old, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				if i%5 == 0 {
+					return fmt.Errorf("write %v failed", i)
+				}
+				return nil
+			})
+		}
+		err := workers.wait()
+		if max > tc.wantMax {
+			t.Errorf("parallelism %v: %v writes ran at once, want at most %v", tc.parallelism, max, tc.wantMax)
+		}
+		if err == nil {
+			t.Errorf("parallelism %v: wait() = nil, want the failed writes", tc.parallelism)
+		}
+		if err := workers.wait(); err != nil {
+			t.Errorf("parallelism %v: second wait() = %v, want errors to be forgotten", tc.parallelism, err)
+		}
+	}
+}
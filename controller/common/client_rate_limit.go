@@ -0,0 +1,59 @@
+package common
+
+import (
+	"fmt"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+)
+
+// RateLimitedClient returns the client a controller with the given client
+// rate limit makes its API calls with: client itself if limit isn't set, or
+// a client of its own that's rate limited separately otherwise.
+func RateLimitedClient(client *dynamicclientset.Clientset, limit *v1alpha1.ClientRateLimit) (*dynamicclientset.Clientset, error) {
+	if limit == nil {
+		return client, nil
+	}
+	if err := validateClientRateLimit(limit); err != nil {
+		return nil, err
+	}
+	limited := client
+	if limit.QPS > 0 || limit.Burst > 0 {
+		var err error
+		limited, err = client.WithRateLimit(float32(limit.QPS), int(limit.Burst))
+		if err != nil {
+			return nil, fmt.Errorf("can't create rate limited client: %v", err)
+		}
+	}
+	if limit.Reads != nil || limit.Writes != nil || limit.Deletes != nil {
+		var err error
+		limited, err = limited.WithOperationRateLimits(operationRateLimit(limit.Reads), operationRateLimit(limit.Writes), operationRateLimit(limit.Deletes))
+		if err != nil {
+			return nil, fmt.Errorf("can't create rate limited client: %v", err)
+		}
+	}
+	return limited, nil
+}
+
+// validateClientRateLimit checks that limit and its budgets don't have
+// negative values.
+func validateClientRateLimit(limit *v1alpha1.ClientRateLimit) error {
+	if limit.QPS < 0 || limit.Burst < 0 {
+		return fmt.Errorf("invalid clientRateLimit: qps and burst can't be negative")
+	}
+	for name, budget := range map[string]*v1alpha1.ClientRateBudget{"reads": limit.Reads, "writes": limit.Writes, "deletes": limit.Deletes} {
+		if budget != nil && (budget.QPS < 0 || budget.Burst < 0) {
+			return fmt.Errorf("invalid clientRateLimit.%v: qps and burst can't be negative", name)
+		}
+	}
+	return nil
+}
+
+// operationRateLimit returns the rate limit of the operations with the given
+// budget, or nil if they don't have one of their own.
+func operationRateLimit(budget *v1alpha1.ClientRateBudget) *dynamicclientset.RateLimit {
+	if budget == nil {
+		return nil
+	}
+	return &dynamicclientset.RateLimit{QPS: float32(budget.QPS), Burst: int(budget.Burst)}
+}
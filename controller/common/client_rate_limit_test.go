@@ -0,0 +1,48 @@
+package common
+
+import (
+	"testing"
+
+	"k8s.io/client-go/rest"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+)
+
+func TestRateLimitedClient(t *testing.T) {
+	client, err := dynamicclientset.New(&rest.Config{Host: "http://localhost"}, nil)
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+
+	table := []struct {
+		name    string
+		limit   *v1alpha1.ClientRateLimit
+		shared  bool
+		wantErr bool
+	}{
+		{name: "unset", limit: nil, shared: true},
+		{name: "empty", limit: &v1alpha1.ClientRateLimit{}, shared: true},
+		{name: "qps", limit: &v1alpha1.ClientRateLimit{QPS: 50}},
+		{name: "burst", limit: &v1alpha1.ClientRateLimit{Burst: 100}},
+		{name: "reads", limit: &v1alpha1.ClientRateLimit{Reads: &v1alpha1.ClientRateBudget{QPS: 5}}},
+		{name: "negative", limit: &v1alpha1.ClientRateLimit{QPS: -1}, wantErr: true},
+		{name: "negative deletes", limit: &v1alpha1.ClientRateLimit{Deletes: &v1alpha1.ClientRateBudget{Burst: -1}}, wantErr: true},
+	}
+	for _, tc := range table {
+		got, err := RateLimitedClient(client, tc.limit)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if shared := got == client; shared != tc.shared {
+			t.Errorf("%v: shared client = %v, want %v", tc.name, shared, tc.shared)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"k8s.io/apimachinery/pkg/version"
+
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+// ClusterInfo is what hooks are told about the cluster, so they can return
+// objects in API versions it serves without doing their own discovery.
+type ClusterInfo struct {
+	// ServerVersion is the version of the Kubernetes API server.
+	ServerVersion *version.Info `json:"serverVersion,omitempty"`
+	// APIGroups maps the API groups of the parent and child resources of the
+	// controller to the versions they serve, from the most to the least
+	// stable. The core API group is "".
+	APIGroups map[string][]string `json:"apiGroups"`
+	// ParentVersions lists the versions in which the parent resource is
+	// served, from the most to the least stable.
+	ParentVersions []string `json:"parentVersions"`
+}
+
+// NewClusterInfo returns the ClusterInfo for a parent of the given resource,
+// with children in the given API versions.
+func NewClusterInfo(resources *dynamicdiscovery.ResourceMap, parent *dynamicdiscovery.APIResource, childAPIVersions []string) *ClusterInfo {
+	info := &ClusterInfo{
+		ServerVersion:  resources.ServerVersion(),
+		APIGroups:      make(map[string][]string),
+		ParentVersions: []string{},
+	}
+	if parent != nil {
+		info.ParentVersions = resources.ResourceVersions(parent.Group, parent.Name)
+		info.APIGroups[parent.Group] = resources.GroupVersions(parent.Group)
+	}
+	for _, apiVersion := range childAPIVersions {
+		group, _ := ParseAPIVersion(apiVersion)
+		if _, ok := info.APIGroups[group]; !ok {
+			info.APIGroups[group] = resources.GroupVersions(group)
+		}
+	}
+	return info
+}
@@ -24,8 +24,10 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/cache"
 
+	dynamicclientset "metacontroller.io/dynamic/clientset"
 	dynamicdiscovery "metacontroller.io/dynamic/discovery"
 	dynamicinformer "metacontroller.io/dynamic/informer"
 )
@@ -34,6 +36,34 @@ var (
 	KeyFunc = cache.DeletionHandlingMetaNamespaceKeyFunc
 )
 
+// ParentQueueKey returns the queue key of a parent. Unlike KeyFunc, it
+// includes the apiVersion and kind, for controllers with several parent
+// resources.
+func ParentQueueKey(obj interface{}) (string, error) {
+	switch o := obj.(type) {
+	case cache.DeletedFinalStateUnknown:
+		if parent, ok := o.Obj.(*unstructured.Unstructured); ok {
+			return ParentQueueKey(parent)
+		}
+		return o.Key, nil
+	case cache.ExplicitKey:
+		return string(o), nil
+	case *unstructured.Unstructured:
+		return fmt.Sprintf("%s:%s:%s:%s", o.GetAPIVersion(), o.GetKind(), o.GetNamespace(), o.GetName()), nil
+	default:
+		return "", fmt.Errorf("can't get key for object of type %T; expected *unstructured.Unstructured", obj)
+	}
+}
+
+// SplitParentQueueKey splits a key returned by ParentQueueKey.
+func SplitParentQueueKey(key string) (apiVersion, kind, namespace, name string, err error) {
+	parts := strings.SplitN(key, ":", 4)
+	if len(parts) != 4 {
+		return "", "", "", "", fmt.Errorf("invalid parent key: %q", key)
+	}
+	return parts[0], parts[1], parts[2], parts[3], nil
+}
+
 type ChildMap map[string]map[string]*unstructured.Unstructured
 
 func (m ChildMap) InitGroup(apiVersion, kind string) {
@@ -74,12 +104,30 @@ func (m ChildMap) FindGroupKindName(apiGroup, kind, name string) *unstructured.U
 	return nil
 }
 
+// keepObserved returns the observed children, without the ones that aren't
+// desired and that drop rejects, so ManageChildren leaves those alone.
+// Children pending deletion are always kept, so our finalizer is still
+// released from them.
+func keepObserved(observed, desired ChildMap, drop func(obj *unstructured.Unstructured) bool) ChildMap {
+	kept := make(ChildMap, len(observed))
+	for key, objects := range observed {
+		kept[key] = make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if desired[key][name] == nil && obj.GetDeletionTimestamp() == nil && drop(obj) {
+				continue
+			}
+			kept[key][name] = obj
+		}
+	}
+	return kept
+}
+
 // relativeName returns the name of the child relative to the parent.
-// If the parent is cluster scoped and the child namespaced scoped the
-// name is of the format <namespace>/<name>. Otherwise the name of the child
-// is returned.
+// If the child is namespaced and not in the namespace of the parent, such as
+// when the parent is cluster scoped, the name is of the format
+// <namespace>/<name>. Otherwise the name of the child is returned.
 func relativeName(parent metav1.Object, child *unstructured.Unstructured) string {
-	if parent.GetNamespace() == "" && child.GetNamespace() != "" {
+	if child.GetNamespace() != "" && child.GetNamespace() != parent.GetNamespace() {
 		return fmt.Sprintf("%s/%s", child.GetNamespace(), child.GetName())
 	}
 	return child.GetName()
@@ -192,3 +240,35 @@ func GetObject(informer *dynamicinformer.ResourceInformer, namespace, name strin
 	}
 	return informer.Lister().Namespace(namespace).Get(name)
 }
+
+// GetParent returns the parent with the given name in namespace from the
+// informer. If fresh is true, a parent found there is read again from the API
+// server with client, since the cache may lag behind rapid consecutive edits.
+func GetParent(informer *dynamicinformer.ResourceInformer, client *dynamicclientset.ResourceClient, namespace, name string, fresh bool) (*unstructured.Unstructured, error) {
+	parent, err := GetObject(informer, namespace, name)
+	if err != nil || !fresh {
+		return parent, err
+	}
+	return client.Namespace(namespace).Get(name, metav1.GetOptions{})
+}
+
+// ListObjects lists the objects matching selector in namespace, or in all
+// namespaces if it's empty, from the given informer, or directly from the
+// API server with client if bypassCache is true.
+func ListObjects(informer *dynamicinformer.ResourceInformer, client *dynamicclientset.ResourceClient, namespace string, selector labels.Selector, bypassCache bool) ([]*unstructured.Unstructured, error) {
+	if bypassCache {
+		list, err := client.Namespace(namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, err
+		}
+		objects := make([]*unstructured.Unstructured, 0, len(list.Items))
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+		return objects, nil
+	}
+	if namespace == "" {
+		return informer.Lister().List(selector)
+	}
+	return informer.Lister().Namespace(namespace).List(selector)
+}
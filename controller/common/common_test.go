@@ -0,0 +1,234 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicinformer "metacontroller.io/dynamic/informer"
+)
+
+func TestParentQueueKey_roundTrip(t *testing.T) {
+	table := []struct {
+		name       string
+		apiVersion string
+		kind       string
+		namespace  string
+		objName    string
+		tombstone  bool
+	}{
+		{name: "namespaced", apiVersion: "example.com/v1", kind: "Thing", namespace: "default", objName: "a"},
+		{name: "other kind with the same name", apiVersion: "example.com/v1beta1", kind: "Widget", namespace: "default", objName: "a"},
+		{name: "core group", apiVersion: "v1", kind: "ConfigMap", namespace: "default", objName: "a"},
+		{name: "cluster scoped", apiVersion: "example.com/v1", kind: "ClusterThing", objName: "a"},
+		{name: "colon in name", apiVersion: "rbac.authorization.k8s.io/v1", kind: "ClusterRole", objName: "system:aggregate-to-view"},
+		{name: "tombstone", apiVersion: "example.com/v1", kind: "Thing", namespace: "default", objName: "a", tombstone: true},
+	}
+	for _, tc := range table {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(tc.apiVersion)
+		obj.SetKind(tc.kind)
+		obj.SetNamespace(tc.namespace)
+		obj.SetName(tc.objName)
+		var queued interface{} = obj
+		if tc.tombstone {
+			queued = cache.DeletedFinalStateUnknown{Key: tc.namespace + "/" + tc.objName, Obj: obj}
+		}
+
+		key, err := ParentQueueKey(queued)
+		if err != nil {
+			t.Errorf("%v: ParentQueueKey() = %v", tc.name, err)
+			continue
+		}
+		apiVersion, kind, namespace, name, err := SplitParentQueueKey(key)
+		if err != nil {
+			t.Errorf("%v: SplitParentQueueKey(%q) = %v", tc.name, key, err)
+			continue
+		}
+		if apiVersion != tc.apiVersion || kind != tc.kind || namespace != tc.namespace || name != tc.objName {
+			t.Errorf("%v: SplitParentQueueKey(%q) = %q, %q, %q, %q; want %q, %q, %q, %q", tc.name, key, apiVersion, kind, namespace, name, tc.apiVersion, tc.kind, tc.namespace, tc.objName)
+		}
+	}
+}
+
+func TestSplitParentQueueKey_invalid(t *testing.T) {
+	// Keys of KeyFunc lack the apiVersion and kind.
+	for _, key := range []string{"", "default/a", "v1:ConfigMap:default"} {
+		if _, _, _, _, err := SplitParentQueueKey(key); err == nil {
+			t.Errorf("SplitParentQueueKey(%q) succeeded, want error", key)
+		}
+	}
+}
+
+// newTestListServer returns an API server that answers lists with
+// respond, and watches with no events until the watcher stops.
+func newTestListServer(t *testing.T, respond http.HandlerFunc) *httptest.Server {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		respond(w, r)
+	}))
+	// Informers are stopped by cleanups registered later, so they run first.
+	t.Cleanup(apiServer.Close)
+	return apiServer
+}
+
+// newTestInformer returns a synced informer for resource.
+func newTestInformer(t *testing.T, dynClient *dynamicclientset.Clientset, apiVersion, resource string) *dynamicinformer.ResourceInformer {
+	informer, err := dynamicinformer.NewSharedInformerFactory(dynClient, time.Hour).Resource(apiVersion, resource)
+	if err != nil {
+		t.Fatalf("Can't create informer for %v: %v", resource, err)
+	}
+	t.Cleanup(informer.Close)
+	for !informer.Informer().HasSynced() {
+		time.Sleep(time.Millisecond)
+	}
+	return informer
+}
+
+func TestGetParent(t *testing.T) {
+	// The informer caches ConfigMap "a" with data "cached", then the API
+	// server returns "live", or nothing once it's deleted.
+	var mutex sync.Mutex
+	data := "cached"
+	gets := 0
+	apiServer := newTestListServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		configMap := fmt.Sprintf(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a", "namespace": "default"}, "data": {"key": %q}}`, data)
+		switch {
+		case r.URL.Path == "/api/v1/configmaps":
+			fmt.Fprintf(w, `{"apiVersion": "v1", "kind": "ConfigMapList", "metadata": {"resourceVersion": "1"}, "items": [%s]}`, configMap)
+		case data == "":
+			gets++
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404}`))
+		default:
+			gets++
+			w.Write([]byte(configMap))
+		}
+	})
+	dynClient := newTestClientset(t, apiServer.URL, "v1", "configmaps")
+	client, err := dynClient.Resource("v1", "configmaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	informer := newTestInformer(t, dynClient, "v1", "configmaps")
+
+	table := []struct {
+		name         string
+		parentName   string
+		fresh        bool
+		live         string
+		want         string
+		wantNotFound bool
+		wantGets     int
+	}{
+		{name: "cached", parentName: "a", live: "live", want: "cached"},
+		{name: "fresh", parentName: "a", fresh: true, live: "live", want: "live", wantGets: 1},
+		{name: "not in cache", parentName: "b", fresh: true, live: "live", wantNotFound: true},
+		{name: "deleted since cached", parentName: "a", fresh: true, wantNotFound: true, wantGets: 1},
+	}
+	for _, tc := range table {
+		mutex.Lock()
+		data, gets = tc.live, 0
+		mutex.Unlock()
+
+		parent, err := GetParent(informer, client, "default", tc.parentName, tc.fresh)
+		mutex.Lock()
+		if gets != tc.wantGets {
+			t.Errorf("%v: GetParent() read the API server %v times, want %v", tc.name, gets, tc.wantGets)
+		}
+		mutex.Unlock()
+		if tc.wantNotFound {
+			if !apierrors.IsNotFound(err) {
+				t.Errorf("%v: GetParent() = %v, want NotFound", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: GetParent() = %v", tc.name, err)
+			continue
+		}
+		if got, _, _ := unstructured.NestedString(parent.Object, "data", "key"); got != tc.want {
+			t.Errorf("%v: GetParent() returned data %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	// The API server first lists child "cached", which the informer caches,
+	// then child "live".
+	var mutex sync.Mutex
+	name := "cached"
+	var selectors []string
+	apiServer := newTestListServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		selectors = append(selectors, r.URL.Query().Get("labelSelector"))
+		fmt.Fprintf(w, `{"apiVersion": "v1", "kind": "ConfigMapList", "metadata": {"resourceVersion": "1"}, "items": [
+			{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": %q, "namespace": "default", "labels": {"app": "web"}}}
+		]}`, name)
+	})
+	dynClient := newTestClientset(t, apiServer.URL, "v1", "configmaps")
+	client, err := dynClient.Resource("v1", "configmaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	informer := newTestInformer(t, dynClient, "v1", "configmaps")
+	mutex.Lock()
+	name = "live"
+	mutex.Unlock()
+
+	selector := labels.SelectorFromSet(labels.Set{"app": "web"})
+	table := []struct {
+		name          string
+		namespace     string
+		bypassCache   bool
+		want          []string
+		wantSelectors []string
+	}{
+		{name: "cache", namespace: "default", want: []string{"cached"}},
+		{name: "cache, all namespaces", want: []string{"cached"}},
+		{name: "bypass cache", namespace: "default", bypassCache: true, want: []string{"live"}, wantSelectors: []string{"app=web"}},
+		{name: "bypass cache, all namespaces", bypassCache: true, want: []string{"live"}, wantSelectors: []string{"app=web"}},
+	}
+	for _, tc := range table {
+		mutex.Lock()
+		selectors = nil
+		mutex.Unlock()
+
+		objects, err := ListObjects(informer, client, tc.namespace, selector, tc.bypassCache)
+		if err != nil {
+			t.Errorf("%v: ListObjects() = %v", tc.name, err)
+			continue
+		}
+		var got []string
+		for _, obj := range objects {
+			got = append(got, obj.GetName())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: ListObjects() = %v, want %v", tc.name, got, tc.want)
+		}
+		mutex.Lock()
+		if !reflect.DeepEqual(selectors, tc.wantSelectors) {
+			t.Errorf("%v: API server got lists with selectors %q, want %q", tc.name, selectors, tc.wantSelectors)
+		}
+		mutex.Unlock()
+	}
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// These are the rules of metav1.Condition fields.
+var (
+	conditionTypeRegexp   = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*/)?(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])$`)
+	conditionReasonRegexp = regexp.MustCompile(`^[A-Za-z]([A-Za-z0-9_,:]*[A-Za-z0-9_])?$`)
+)
+
+const (
+	maxConditionTypeLength    = 316
+	maxConditionReasonLength  = 1024
+	maxConditionMessageLength = 32768
+
+	// DefaultConditionReason is the reason given to conditions a hook
+	// returns without one.
+	DefaultConditionReason = "Unspecified"
+)
+
+// ValidateConditions checks the conditions in status.conditions, as returned
+// by a hook, against the rules of metav1.Condition fields. Their
+// lastTransitionTime may be left out, since MergeConditions sets it.
+// Conditions without a reason are given DefaultConditionReason, with a
+// warning, since hooks written before conditions were validated didn't set
+// one.
+func ValidateConditions(status map[string]interface{}) error {
+	conditions, ok := status["conditions"]
+	if !ok || conditions == nil {
+		return nil
+	}
+	list, ok := conditions.([]interface{})
+	if !ok {
+		return fmt.Errorf("status.conditions must be a list")
+	}
+	types := make(map[string]bool, len(list))
+	for i, item := range list {
+		condition, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("status.conditions[%v] must be an object", i)
+		}
+		if err := validateCondition(condition); err != nil {
+			return fmt.Errorf("status.conditions[%v]: %v", i, err)
+		}
+		conditionType := condition["type"].(string)
+		if types[conditionType] {
+			return fmt.Errorf("status.conditions[%v]: duplicate type %q", i, conditionType)
+		}
+		types[conditionType] = true
+	}
+	return nil
+}
+
+func validateCondition(condition map[string]interface{}) error {
+	conditionType, _ := condition["type"].(string)
+	if len(conditionType) > maxConditionTypeLength || !conditionTypeRegexp.MatchString(conditionType) {
+		return fmt.Errorf("invalid type %q", condition["type"])
+	}
+	switch condition["status"] {
+	case "True", "False", "Unknown":
+	default:
+		return fmt.Errorf("status must be True, False or Unknown, not %q", condition["status"])
+	}
+	reason, _ := condition["reason"].(string)
+	if reason == "" {
+		klog.InfoS("Warning: hook returned a condition without a reason", "type", conditionType, "reason", DefaultConditionReason)
+		reason = DefaultConditionReason
+		condition["reason"] = reason
+	}
+	if len(reason) > maxConditionReasonLength || !conditionReasonRegexp.MatchString(reason) {
+		return fmt.Errorf("invalid reason %q: it must be CamelCase", condition["reason"])
+	}
+	if message, ok := condition["message"]; ok {
+		message, ok := message.(string)
+		if !ok || len(message) > maxConditionMessageLength {
+			return fmt.Errorf("message must be a string of at most %v characters", maxConditionMessageLength)
+		}
+	}
+	if generation, ok := condition["observedGeneration"]; ok {
+		if generation, ok := jsonNumber(generation); !ok || generation < 0 {
+			return fmt.Errorf("observedGeneration must be a number of at least 0")
+		}
+	}
+	if lastTransitionTime, ok := condition["lastTransitionTime"]; ok {
+		lastTransitionTime, ok := lastTransitionTime.(string)
+		if !ok {
+			return fmt.Errorf("lastTransitionTime must be a string")
+		}
+		if _, err := time.Parse(time.RFC3339, lastTransitionTime); err != nil {
+			return fmt.Errorf("invalid lastTransitionTime: %v", err)
+		}
+	}
+	return nil
+}
+
+// MergeConditions sets the lastTransitionTime of the conditions in
+// status.conditions, as returned by a hook, from the ones of the observed
+// status: it's kept as long as the status and reason of a condition don't
+// change. Otherwise, it's the one the hook set, or now if it didn't.
+// The conditions are also sorted by type, so their order doesn't change
+// from one sync to the next.
+func MergeConditions(observed interface{}, status map[string]interface{}, now time.Time) {
+	conditions, ok := status["conditions"].([]interface{})
+	if !ok {
+		return
+	}
+	observedConditions := make(map[interface{}]map[string]interface{})
+	if observed, ok := observed.(map[string]interface{}); ok {
+		if list, ok := observed["conditions"].([]interface{}); ok {
+			for _, item := range list {
+				if condition, ok := item.(map[string]interface{}); ok {
+					observedConditions[condition["type"]] = condition
+				}
+			}
+		}
+	}
+
+	merged := make([]interface{}, 0, len(conditions))
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok {
+			// It's not a condition that can be merged.
+			return
+		}
+		// Copy the condition, since the status may be shared with other
+		// revisions of the parent.
+		copied := make(map[string]interface{}, len(condition)+1)
+		for key, value := range condition {
+			copied[key] = value
+		}
+		old := observedConditions[condition["type"]]
+		switch {
+		case old != nil && old["status"] == condition["status"] && old["reason"] == condition["reason"] && old["lastTransitionTime"] != nil:
+			copied["lastTransitionTime"] = old["lastTransitionTime"]
+		case copied["lastTransitionTime"] == nil:
+			copied["lastTransitionTime"] = now.UTC().Format(time.RFC3339)
+		}
+		merged = append(merged, copied)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return fmt.Sprint(merged[i].(map[string]interface{})["type"]) < fmt.Sprint(merged[j].(map[string]interface{})["type"])
+	})
+	status["conditions"] = merged
+}
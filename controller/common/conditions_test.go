@@ -0,0 +1,86 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidateConditions(t *testing.T) {
+	condition := func(fields ...string) map[string]interface{} {
+		c := map[string]interface{}{"type": "Ready", "status": "True", "reason": "AllGood"}
+		for i := 0; i+1 < len(fields); i += 2 {
+			c[fields[i]] = fields[i+1]
+		}
+		return c
+	}
+
+	table := []struct {
+		name       string
+		conditions interface{}
+		wantErr    bool
+	}{
+		{name: "none", conditions: nil},
+		{name: "valid", conditions: []interface{}{condition(), condition("type", "example.com/Synced", "lastTransitionTime", "2021-01-01T00:00:00Z")}},
+		{name: "not a list", conditions: "Ready", wantErr: true},
+		{name: "missing type", conditions: []interface{}{condition("type", "")}, wantErr: true},
+		{name: "invalid status", conditions: []interface{}{condition("status", "Yes")}, wantErr: true},
+		{name: "missing reason", conditions: []interface{}{condition("reason", "")}},
+		{name: "invalid reason", conditions: []interface{}{condition("reason", "all good")}, wantErr: true},
+		{name: "invalid lastTransitionTime", conditions: []interface{}{condition("lastTransitionTime", "yesterday")}, wantErr: true},
+		{name: "duplicate type", conditions: []interface{}{condition(), condition()}, wantErr: true},
+	}
+	for _, tc := range table {
+		err := ValidateConditions(map[string]interface{}{"conditions": tc.conditions})
+		if tc.wantErr && err == nil {
+			t.Errorf("%v: expected error", tc.name)
+		}
+		if !tc.wantErr && err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+		}
+	}
+}
+
+func TestValidateConditions_defaultReason(t *testing.T) {
+	// As returned by examples/daemonjob.
+	status := map[string]interface{}{
+		"numberReady": 1,
+		"conditions":  []interface{}{map[string]interface{}{"type": "Complete", "status": "False"}},
+	}
+	if err := ValidateConditions(status); err != nil {
+		t.Fatalf("ValidateConditions() = %v, want no error", err)
+	}
+	condition := status["conditions"].([]interface{})[0].(map[string]interface{})
+	if got := condition["reason"]; got != DefaultConditionReason {
+		t.Errorf("reason = %v, want %v", got, DefaultConditionReason)
+	}
+}
+
+func TestMergeConditions(t *testing.T) {
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	observed := map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Synced", "status": "True", "reason": "Synced", "lastTransitionTime": "2021-01-01T00:00:00Z"},
+			map[string]interface{}{"type": "Ready", "status": "True", "reason": "AllGood", "lastTransitionTime": "2021-01-01T00:00:00Z"},
+		},
+	}
+	status := map[string]interface{}{
+		"conditions": []interface{}{
+			// Unchanged, but the hook bumped its timestamp.
+			map[string]interface{}{"type": "Synced", "status": "True", "reason": "Synced", "lastTransitionTime": "2021-01-01T12:00:00Z"},
+			// Changed, without a timestamp.
+			map[string]interface{}{"type": "Ready", "status": "False", "reason": "Waiting"},
+			// New, with a timestamp.
+			map[string]interface{}{"type": "Degraded", "status": "False", "reason": "AllGood", "lastTransitionTime": "2021-01-01T06:00:00Z"},
+		},
+	}
+	want := []interface{}{
+		map[string]interface{}{"type": "Degraded", "status": "False", "reason": "AllGood", "lastTransitionTime": "2021-01-01T06:00:00Z"},
+		map[string]interface{}{"type": "Ready", "status": "False", "reason": "Waiting", "lastTransitionTime": "2021-01-02T00:00:00Z"},
+		map[string]interface{}{"type": "Synced", "status": "True", "reason": "Synced", "lastTransitionTime": "2021-01-01T00:00:00Z"},
+	}
+	MergeConditions(observed, status, now)
+	if got := status["conditions"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeConditions() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+package common
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common/finalizer"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicobject "metacontroller.io/dynamic/object"
+)
+
+// CleanupFinalizer is placed on controllers with the Cleanup deletion policy,
+// so they're only deleted once their parents and children are cleaned up.
+const CleanupFinalizer = "metacontroller.io/cleanup"
+
+// ValidateDeletionPolicy returns an error if policy isn't valid.
+func ValidateDeletionPolicy(policy *v1alpha1.ControllerDeletionPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	switch policy.Policy {
+	case "", v1alpha1.DeletionPolicyRetain:
+		if policy.DeleteChildren {
+			return fmt.Errorf("invalid deletionPolicy: deleteChildren requires the %v policy", v1alpha1.DeletionPolicyCleanup)
+		}
+		return nil
+	case v1alpha1.DeletionPolicyCleanup:
+		return nil
+	}
+	return fmt.Errorf("invalid deletionPolicy %q", policy.Policy)
+}
+
+// CleansUp returns whether policy asks to clean up when the controller is
+// deleted.
+func CleansUp(policy *v1alpha1.ControllerDeletionPolicy) bool {
+	return policy != nil && policy.Policy == v1alpha1.DeletionPolicyCleanup
+}
+
+// SyncCleanupFinalizer adds the cleanup finalizer to a CompositeController or
+// DecoratorController that isn't being deleted if its deletion policy is
+// Cleanup, and removes it otherwise.
+func SyncCleanupFinalizer(dynClient *dynamicclientset.Clientset, resource string, controller metav1.Object, policy *v1alpha1.ControllerDeletionPolicy) error {
+	if controller.GetDeletionTimestamp() != nil {
+		// The finalizer is only removed by RemoveCleanupFinalizer, once the
+		// cleanup is done.
+		return nil
+	}
+	want := CleansUp(policy)
+	if dynamicobject.HasFinalizer(controller, CleanupFinalizer) == want {
+		return nil
+	}
+	client, err := dynClient.Resource(v1alpha1.SchemeGroupVersion.String(), resource)
+	if err != nil {
+		return err
+	}
+	orig := &unstructured.Unstructured{}
+	orig.SetName(controller.GetName())
+	orig.SetUID(controller.GetUID())
+	if want {
+		_, err = client.AddFinalizer(orig, CleanupFinalizer)
+	} else {
+		_, err = client.RemoveFinalizer(orig, CleanupFinalizer)
+	}
+	return err
+}
+
+// RemoveCleanupFinalizer removes the cleanup finalizer from a
+// CompositeController or DecoratorController, once it's cleaned up, so it
+// can go away.
+func RemoveCleanupFinalizer(dynClient *dynamicclientset.Clientset, resource string, controller metav1.Object) error {
+	if !dynamicobject.HasFinalizer(controller, CleanupFinalizer) {
+		return nil
+	}
+	client, err := dynClient.Resource(v1alpha1.SchemeGroupVersion.String(), resource)
+	if err != nil {
+		return err
+	}
+	orig := &unstructured.Unstructured{}
+	orig.SetName(controller.GetName())
+	orig.SetUID(controller.GetUID())
+	_, err = client.RemoveFinalizer(orig, CleanupFinalizer)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// ControllerCleanup cleans up after a controller that's being deleted with
+// the Cleanup policy: it removes the controller's finalizer from its parents
+// and children, and deletes its children if requested. It reads from the API
+// server, since the controller's informers may be gone.
+type ControllerCleanup struct {
+	ControllerKind, Controller string
+
+	// Finalizer is the finalizer the controller places on parents and
+	// children.
+	Finalizer *finalizer.Manager
+	Parents   []*dynamicclientset.ResourceClient
+	Children  []*dynamicclientset.ResourceClient
+	// IsParent returns whether the controller manages parent, besides the
+	// parents that have its finalizer. All parents are managed if it's nil.
+	IsParent func(parent *unstructured.Unstructured) bool
+	// IsChild returns whether child, whose controller is a parent of the
+	// controller, belongs to it. All of them do if it's nil.
+	IsChild func(child *unstructured.Unstructured) bool
+	// DeleteChildren deletes the children.
+	DeleteChildren bool
+}
+
+// Run cleans up the parents and children of the controller. It can be called
+// again after it fails, since it only changes what's left to clean up.
+func (c *ControllerCleanup) Run() error {
+	var errs []error
+	parentUIDs := make(map[types.UID]bool)
+	for _, client := range c.Parents {
+		parents, err := client.List(metav1.ListOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't list %v: %v", client.Name, err))
+			continue
+		}
+		for i := range parents.Items {
+			parent := &parents.Items[i]
+			hasFinalizer := c.Finalizer.HasFinalizer(parent)
+			if !hasFinalizer && c.IsParent != nil && !c.IsParent(parent) {
+				continue
+			}
+			parentUIDs[parent.GetUID()] = true
+			if !hasFinalizer {
+				continue
+			}
+			if _, err := c.Finalizer.RemoveFinalizer(client, parent); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("can't remove finalizer from %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err))
+				continue
+			}
+			klog.InfoS("Removed finalizer of deleted controller", "controller_kind", c.ControllerKind, "controller", c.Controller, "parent_kind", parent.GetKind(), "parent", klog.KObj(parent))
+		}
+	}
+	if len(errs) > 0 {
+		// Don't look for children until all parents are known.
+		return utilerrors.NewAggregate(errs)
+	}
+
+	for _, client := range c.Children {
+		children, err := client.List(metav1.ListOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't list %v: %v", client.Name, err))
+			continue
+		}
+		for i := range children.Items {
+			child := &children.Items[i]
+			ref := metav1.GetControllerOf(child)
+			if ref == nil || !parentUIDs[ref.UID] || c.IsChild != nil && !c.IsChild(child) {
+				continue
+			}
+			if c.Finalizer.HasFinalizer(child) {
+				if _, err := c.Finalizer.RemoveFinalizer(client, child); err != nil && !apierrors.IsNotFound(err) {
+					errs = append(errs, fmt.Errorf("can't remove finalizer from %v %v/%v: %v", child.GetKind(), child.GetNamespace(), child.GetName(), err))
+					continue
+				}
+			}
+			if !c.DeleteChildren || child.GetDeletionTimestamp() != nil {
+				continue
+			}
+			uid := child.GetUID()
+			propagation := metav1.DeletePropagationBackground
+			err := client.Namespace(child.GetNamespace()).Delete(child.GetName(), &metav1.DeleteOptions{
+				Preconditions:     &metav1.Preconditions{UID: &uid},
+				PropagationPolicy: &propagation,
+			})
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("can't delete %v %v/%v: %v", child.GetKind(), child.GetNamespace(), child.GetName(), err))
+				continue
+			}
+			klog.InfoS("Deleted child of deleted controller", "controller_kind", c.ControllerKind, "controller", c.Controller, "child", klog.KObj(child))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
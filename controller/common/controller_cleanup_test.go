@@ -0,0 +1,45 @@
+package common
+
+import (
+	"testing"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestValidateDeletionPolicy(t *testing.T) {
+	table := []struct {
+		name    string
+		policy  *v1alpha1.ControllerDeletionPolicy
+		wantErr bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:   "retain",
+			policy: &v1alpha1.ControllerDeletionPolicy{Policy: v1alpha1.DeletionPolicyRetain},
+		},
+		{
+			name:   "cleanup and delete children",
+			policy: &v1alpha1.ControllerDeletionPolicy{Policy: v1alpha1.DeletionPolicyCleanup, DeleteChildren: true},
+		},
+		{
+			name:    "retain and delete children",
+			policy:  &v1alpha1.ControllerDeletionPolicy{DeleteChildren: true},
+			wantErr: true,
+		},
+		{
+			name:    "unknown",
+			policy:  &v1alpha1.ControllerDeletionPolicy{Policy: "Orphan"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateDeletionPolicy(tc.policy); (err != nil) != tc.wantErr {
+				t.Errorf("ValidateDeletionPolicy() = %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -13,11 +13,15 @@ var callCustomizeHook = hooks.Call
 
 type CustomizableController interface {
 	GetCustomizeHook() *v1alpha1.Hook
+	GetHookParameters() *v1alpha1.HookParameters
 }
 
 type CustomizeHookRequest struct {
 	Controller CustomizableController     `json:"controller"`
 	Parent     *unstructured.Unstructured `json:"parent"`
+
+	// Parameters are the hook parameters of the controller, if it has any.
+	Parameters map[string]string `json:"parameters,omitempty"`
 }
 
 type CustomizeHookResponse struct {
@@ -33,6 +37,16 @@ func CallCustomizeHook(cc CustomizableController, request *CustomizeHookRequest)
 		return &response, nil
 	}
 
+	parameters, err := hooks.ResolveParameters(cc.GetHookParameters())
+	if err != nil {
+		return nil, err
+	}
+	request.Parameters = parameters
+
+	hook, err = hooks.ForObject(hook, request.Parent)
+	if err != nil {
+		return nil, err
+	}
 	if err := callCustomizeHook(hook, request, &response); err != nil {
 		return nil, fmt.Errorf("related hook failed: %v", err)
 	}
@@ -24,6 +24,10 @@ func (cc *nilCustomizableController) GetCustomizeHook() *v1alpha1.Hook {
 	return nil
 }
 
+func (cc *nilCustomizableController) GetHookParameters() *v1alpha1.HookParameters {
+	return nil
+}
+
 var fakeWebhook = v1alpha1.Webhook{}
 var fakeHook = v1alpha1.Hook{Webhook: &fakeWebhook}
 
@@ -34,6 +38,10 @@ func (cc *fakeCustomizableController) GetCustomizeHook() *v1alpha1.Hook {
 	return &fakeHook
 }
 
+func (cc *fakeCustomizableController) GetHookParameters() *v1alpha1.HookParameters {
+	return nil
+}
+
 var customizeManagerWithNilController = NewCustomizeManager("test",
 	fakeEnqueueParent,
 	&nilCustomizableController{},
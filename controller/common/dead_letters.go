@@ -0,0 +1,208 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	"metacontroller.io/events"
+)
+
+const (
+	// DeadLetterAnnotation is set on parents whose sync failed too many times
+	// in a row, to the last error. Such parents aren't synced again until the
+	// annotation is removed.
+	DeadLetterAnnotation = "metacontroller.k8s.io/dead-letter"
+	// DeadLetterTimeAnnotation is set on dead-lettered parents to when they
+	// were dead-lettered, in RFC 3339 format.
+	DeadLetterTimeAnnotation = "metacontroller.k8s.io/dead-letter-time"
+)
+
+var deadLetterParents = metrics.NewGaugeVec(
+	&metrics.GaugeOpts{
+		Namespace:      "metacontroller",
+		Name:           "dead_letter_parents",
+		Help:           "Number of parents that aren't synced anymore, because their sync failed too many times in a row.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"controller_kind", "controller"},
+)
+
+func init() {
+	legacyregistry.MustRegister(deadLetterParents)
+}
+
+// DeadLetterOptions configures when parents are moved to the dead letters.
+type DeadLetterOptions struct {
+	// After is how many times in a row the sync of a parent must fail for it
+	// to be dead-lettered. Zero disables dead letters.
+	After int
+}
+
+var deadLetters = struct {
+	mutex   sync.Mutex
+	options DeadLetterOptions
+	// retries maps each controller with dead-lettered parents, as
+	// "<kind>/<name>", to its SyncRetries.
+	retries map[string]*SyncRetries
+}{retries: make(map[string]*SyncRetries)}
+
+// InitDeadLetters configures the dead letters of all controllers.
+func InitDeadLetters(options DeadLetterOptions) {
+	deadLetters.mutex.Lock()
+	defer deadLetters.mutex.Unlock()
+	deadLetters.options = options
+}
+
+// DeadLetter is a parent that isn't synced anymore, because its sync failed
+// too many times in a row.
+type DeadLetter struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Time is when the parent was dead-lettered, in RFC 3339 format.
+	Time  string `json:"time,omitempty"`
+	Error string `json:"error"`
+}
+
+// Exhausted returns whether a parent whose sync failed failures times in a
+// row must be dead-lettered, instead of retried.
+func (r *SyncRetries) Exhausted(failures int) bool {
+	deadLetters.mutex.Lock()
+	defer deadLetters.mutex.Unlock()
+	return deadLetters.options.After > 0 && failures >= deadLetters.options.After
+}
+
+// DeadLetter moves parent, with the given queue key, to the dead letters,
+// after its sync failed failures times in a row with syncErr. This is
+// recorded in its annotations, replacing its sync failure annotations, so
+// it's kept across restarts, and in a Warning event.
+func (r *SyncRetries) DeadLetter(eventRecorder record.EventRecorder, parentClient *dynamicclientset.ResourceClient, key string, parent *unstructured.Unstructured, failures int, syncErr error) error {
+	err := patchSyncFailureAnnotations(parentClient, parent, map[string]interface{}{
+		DeadLetterAnnotation:     syncErr.Error(),
+		DeadLetterTimeAnnotation: time.Now().UTC().Format(time.RFC3339),
+		SyncFailuresAnnotation:   nil,
+		NextSyncRetryAnnotation:  nil,
+	})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Dead-lettered parent", "controller_kind", r.controllerKind, "controller", r.controller, "parent", klog.KObj(parent), "failures", failures)
+	eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonDeadLettered,
+		"Sync failed %v times in a row, not retrying until the %v annotation is removed: %v", failures, DeadLetterAnnotation, syncErr)
+
+	// Start over once the parent is requeued.
+	r.Succeeded(key)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.addDeadLetter(key, syncErr.Error(), time.Now().UTC().Format(time.RFC3339))
+	return nil
+}
+
+// DeadLettered returns whether parent, with the given queue key, is in the
+// dead letters, and must not be synced. A parent leaves the dead letters once
+// its dead-letter annotation is removed.
+func (r *SyncRetries) DeadLettered(key string, parent *unstructured.Unstructured) bool {
+	annotations := parent.GetAnnotations()
+	syncErr, ok := annotations[DeadLetterAnnotation]
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !ok {
+		r.removeDeadLetter(key)
+		return false
+	}
+	// The parent may have been dead-lettered before Metacontroller
+	// restarted.
+	r.addDeadLetter(key, syncErr, annotations[DeadLetterTimeAnnotation])
+	return true
+}
+
+// addDeadLetter records a dead-lettered parent. The caller must hold
+// r.mutex.
+func (r *SyncRetries) addDeadLetter(key, syncErr, deadLetterTime string) {
+	if _, ok := r.deadLetters[key]; !ok {
+		deadLetters.mutex.Lock()
+		deadLetters.retries[r.controllerKind+"/"+r.controller] = r
+		deadLetters.mutex.Unlock()
+	}
+	r.deadLetters[key] = DeadLetter{Time: deadLetterTime, Error: syncErr}
+	deadLetterParents.WithLabelValues(r.controllerKind, r.controller).Set(float64(len(r.deadLetters)))
+}
+
+// removeDeadLetter forgets a parent that left the dead letters, or is gone.
+// The caller must hold r.mutex.
+func (r *SyncRetries) removeDeadLetter(key string) {
+	if _, ok := r.deadLetters[key]; !ok {
+		return
+	}
+	delete(r.deadLetters, key)
+	deadLetterParents.WithLabelValues(r.controllerKind, r.controller).Set(float64(len(r.deadLetters)))
+}
+
+// forgetDeadLetters drops all dead-lettered parents, when the controller
+// stops. Their annotations remain. The caller must hold r.mutex.
+func (r *SyncRetries) forgetDeadLetters() {
+	r.deadLetters = make(map[string]DeadLetter)
+	deadLetterParents.DeleteLabelValues(r.controllerKind, r.controller)
+	deadLetters.mutex.Lock()
+	defer deadLetters.mutex.Unlock()
+	if deadLetters.retries[r.controllerKind+"/"+r.controller] == r {
+		delete(deadLetters.retries, r.controllerKind+"/"+r.controller)
+	}
+}
+
+func (r *SyncRetries) deadLetterList() []DeadLetter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	list := make([]DeadLetter, 0, len(r.deadLetters))
+	for key, deadLetter := range r.deadLetters {
+		apiVersion, kind, namespace, name, err := SplitParentQueueKey(key)
+		if err != nil {
+			continue
+		}
+		deadLetter.APIVersion, deadLetter.Kind, deadLetter.Namespace, deadLetter.Name = apiVersion, kind, namespace, name
+		list = append(list, deadLetter)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Time != list[j].Time {
+			return list[i].Time < list[j].Time
+		}
+		return list[i].Namespace+"/"+list[i].Name < list[j].Namespace+"/"+list[j].Name
+	})
+	return list
+}
+
+// ServeDeadLetters serves the dead-lettered parents of each controller, as a
+// JSON object keyed by "<controller kind>/<controller name>".
+// Parents are only listed once the controller has seen them since
+// Metacontroller started.
+func ServeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deadLetters.mutex.Lock()
+	retries := make(map[string]*SyncRetries, len(deadLetters.retries))
+	for controller, controllerRetries := range deadLetters.retries {
+		retries[controller] = controllerRetries
+	}
+	deadLetters.mutex.Unlock()
+
+	reports := make(map[string][]DeadLetter, len(retries))
+	for controller, controllerRetries := range retries {
+		reports[controller] = controllerRetries.deadLetterList()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(reports)
+}
@@ -0,0 +1,60 @@
+package common
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSyncRetriesExhausted(t *testing.T) {
+	retries := NewSyncRetries("CompositeController", "test")
+	defer retries.Stop()
+
+	InitDeadLetters(DeadLetterOptions{})
+	if retries.Exhausted(1000) {
+		t.Errorf("Exhausted() = true with dead letters disabled")
+	}
+	InitDeadLetters(DeadLetterOptions{After: 3})
+	defer InitDeadLetters(DeadLetterOptions{})
+	if retries.Exhausted(2) {
+		t.Errorf("Exhausted(2) = true, want false")
+	}
+	if !retries.Exhausted(3) {
+		t.Errorf("Exhausted(3) = false, want true")
+	}
+}
+
+func TestSyncRetriesDeadLettered(t *testing.T) {
+	retries := NewSyncRetries("CompositeController", "test")
+	defer retries.Stop()
+
+	key := "v1:Thing:ns:a"
+	parent := &unstructured.Unstructured{}
+	parent.SetName("a")
+	if retries.DeadLettered(key, parent) {
+		t.Errorf("DeadLettered() = true without the annotation")
+	}
+
+	// A parent dead-lettered before a restart.
+	parent.SetAnnotations(map[string]string{
+		DeadLetterAnnotation:     "sync hook failed: boom",
+		DeadLetterTimeAnnotation: "2021-01-02T03:04:05Z",
+	})
+	if !retries.DeadLettered(key, parent) {
+		t.Errorf("DeadLettered() = false with the annotation")
+	}
+	got := retries.deadLetterList()
+	want := DeadLetter{APIVersion: "v1", Kind: "Thing", Namespace: "ns", Name: "a", Time: "2021-01-02T03:04:05Z", Error: "sync hook failed: boom"}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("deadLetterList() = %v, want [%v]", got, want)
+	}
+
+	// The parent is requeued.
+	parent.SetAnnotations(nil)
+	if retries.DeadLettered(key, parent) {
+		t.Errorf("DeadLettered() = true after the annotation was removed")
+	}
+	if got := retries.deadLetterList(); len(got) != 0 {
+		t.Errorf("deadLetterList() = %v, want none", got)
+	}
+}
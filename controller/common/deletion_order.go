@@ -0,0 +1,67 @@
+package common
+
+import (
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DeletionOrderAnnotation can be set by hooks on desired children to
+	// order their deletion while the parent is being finalized. Children
+	// with a lower order are deleted, and gone, before any children with
+	// a higher order are deleted. Children without it have order 0.
+	DeletionOrderAnnotation = "metacontroller.k8s.io/deletion-order"
+)
+
+// OrderDeletions returns the observed children that can be managed now, while
+// the parent is being finalized. Children that are no longer desired, but have
+// to wait for children with a lower deletion order to be gone, are left out,
+// so they aren't deleted yet.
+func OrderDeletions(observed, desired ChildMap) ChildMap {
+	// Find the lowest order among the children that are going away,
+	// including the ones that are already pending deletion.
+	var lowest *int
+	for key, objects := range observed {
+		for name, obj := range objects {
+			if desired[key][name] != nil {
+				continue
+			}
+			order := deletionOrder(obj)
+			if lowest == nil || order < *lowest {
+				lowest = &order
+			}
+		}
+	}
+	if lowest == nil {
+		return observed
+	}
+
+	result := make(ChildMap, len(observed))
+	for key, objects := range observed {
+		group := make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if desired[key][name] == nil && deletionOrder(obj) > *lowest {
+				klog.V(4).InfoS("Waiting to delete child", "child", klog.KObj(obj), "order", deletionOrder(obj), "waitingFor", *lowest)
+				continue
+			}
+			group[name] = obj
+		}
+		result[key] = group
+	}
+	return result
+}
+
+func deletionOrder(obj *unstructured.Unstructured) int {
+	value, ok := obj.GetAnnotations()[DeletionOrderAnnotation]
+	if !ok {
+		return 0
+	}
+	order, err := strconv.Atoi(value)
+	if err != nil {
+		klog.InfoS("Ignoring invalid deletion order", "child", klog.KObj(obj), "order", value)
+		return 0
+	}
+	return order
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestOrderDeletions(t *testing.T) {
+	child := func(name, order string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		if order != "" {
+			obj.SetAnnotations(map[string]string{DeletionOrderAnnotation: order})
+		}
+		return obj
+	}
+
+	table := []struct {
+		name     string
+		observed []*unstructured.Unstructured
+		desired  []*unstructured.Unstructured
+		want     []string
+	}{
+		{
+			name:     "no order",
+			observed: []*unstructured.Unstructured{child("a", ""), child("b", "")},
+			want:     []string{"a", "b"},
+		},
+		{
+			name:     "lowest order first",
+			observed: []*unstructured.Unstructured{child("db", "1"), child("cleanup", "2"), child("other", "")},
+			want:     []string{"other"},
+		},
+		{
+			name:     "next tier once previous is gone",
+			observed: []*unstructured.Unstructured{child("db", "1"), child("cleanup", "2")},
+			want:     []string{"db"},
+		},
+		{
+			name:     "desired children are kept",
+			observed: []*unstructured.Unstructured{child("db", "1"), child("cleanup", "2")},
+			desired:  []*unstructured.Unstructured{child("cleanup", "2")},
+			want:     []string{"cleanup", "db"},
+		},
+		{
+			name:     "negative and invalid orders",
+			observed: []*unstructured.Unstructured{child("first", "-1"), child("invalid", "x"), child("last", "3")},
+			want:     []string{"first"},
+		},
+	}
+
+	parent := &unstructured.Unstructured{}
+	for _, tc := range table {
+		observed := MakeChildMap(parent, tc.observed)
+		desired := MakeChildMap(parent, tc.desired)
+		var got []string
+		for _, obj := range OrderDeletions(observed, desired).List() {
+			got = append(got, obj.GetName())
+		}
+		sort.Strings(got)
+		if len(got) != len(tc.want) {
+			t.Errorf("%v: OrderDeletions() = %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%v: OrderDeletions() = %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
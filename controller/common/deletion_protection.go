@@ -0,0 +1,146 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/events"
+)
+
+var deferredChildDeletions = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Namespace:      "metacontroller",
+		Name:           "deferred_child_deletions_total",
+		Help:           "Number of child deletions deferred to a later sync by deletion rate limits.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"controller_kind", "controller"},
+)
+
+func init() {
+	legacyregistry.MustRegister(deferredChildDeletions)
+}
+
+// ConfirmDeletionAnnotation must be set to "true" on a child before it's
+// deleted, if the controller requires deletions to be confirmed.
+const ConfirmDeletionAnnotation = "metacontroller.k8s.io/confirm-deletion"
+
+// DeletionProtection holds back the deletion of children that aren't desired
+// anymore, according to the deletion protection of a controller, so a hook
+// that wrongly returns no children doesn't delete them all at once.
+type DeletionProtection struct {
+	controllerKind, controller string
+	policy                     *v1alpha1.ChildDeletionProtection
+	// limiter limits the deletions of the whole controller, if MaxPerMinute
+	// is set.
+	limiter flowcontrol.RateLimiter
+
+	mutex sync.Mutex
+	// undesired maps the queue key of each parent to the number of
+	// consecutive syncs in which each of its children, by UID, wasn't desired.
+	undesired map[string]map[types.UID]int32
+}
+
+// NewDeletionProtection returns a DeletionProtection for the controller with
+// the given kind and name, and the given policy, which may be nil to delete
+// children as soon as they're not desired.
+func NewDeletionProtection(controllerKind, controller string, policy *v1alpha1.ChildDeletionProtection) *DeletionProtection {
+	p := &DeletionProtection{
+		controllerKind: controllerKind,
+		controller:     controller,
+		policy:         policy,
+		undesired:      make(map[string]map[types.UID]int32),
+	}
+	if policy != nil && policy.MaxPerMinute > 0 {
+		p.limiter = flowcontrol.NewTokenBucketRateLimiter(float32(policy.MaxPerMinute)/60, int(policy.MaxPerMinute))
+	}
+	return p
+}
+
+// Filter is called once per sync of parent, whose queue key is key. It
+// returns the observed children, without those that aren't desired but
+// can't be deleted yet, so ManageChildren leaves them alone.
+// If deletions were deferred by rate limits, it also returns when the parent
+// should be synced again to carry them out.
+// Children of a parent pending deletion are never held back.
+func (p *DeletionProtection) Filter(eventRecorder record.EventRecorder, key string, parent *unstructured.Unstructured, observed, desired ChildMap) (ChildMap, time.Duration) {
+	if p.policy == nil || parent.GetDeletionTimestamp() != nil {
+		p.Forget(key)
+		return observed, 0
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	previous := p.undesired[key]
+	undesired := make(map[types.UID]int32)
+	result := make(ChildMap, len(observed))
+	held, deletions, deferred := 0, int32(0), 0
+	for groupKey, objects := range observed {
+		result[groupKey] = make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if desired[groupKey][name] != nil || obj.GetDeletionTimestamp() != nil {
+				result[groupKey][name] = obj
+				continue
+			}
+			syncs := previous[obj.GetUID()] + 1
+			undesired[obj.GetUID()] = syncs
+			if syncs < p.policy.MinSyncs {
+				held++
+				continue
+			}
+			if p.policy.RequireConfirmation && obj.GetAnnotations()[ConfirmDeletionAnnotation] != "true" {
+				held++
+				continue
+			}
+			if (p.policy.MaxPerSync > 0 && deletions >= p.policy.MaxPerSync) || (p.limiter != nil && !p.limiter.TryAccept()) {
+				deferred++
+				continue
+			}
+			deletions++
+			result[groupKey][name] = obj
+		}
+	}
+	if len(undesired) > 0 {
+		p.undesired[key] = undesired
+	} else {
+		delete(p.undesired, key)
+	}
+
+	if held > 0 {
+		klog.InfoS("Holding back deletion of children", "parent", klog.KObj(parent), "children", held)
+		if eventRecorder != nil {
+			eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonDeletionProtected,
+				"Holding back deletion of %v children that aren't desired anymore", held)
+		}
+	}
+	if deferred == 0 {
+		return result, 0
+	}
+	klog.InfoS("Deferring deletion of children", "parent", klog.KObj(parent), "children", deferred)
+	deferredChildDeletions.WithLabelValues(p.controllerKind, p.controller).Add(float64(deferred))
+	retryAfter := time.Second
+	if p.policy.MaxPerMinute > 0 {
+		if interval := time.Minute / time.Duration(p.policy.MaxPerMinute); interval > retryAfter {
+			retryAfter = interval
+		}
+	}
+	return result, retryAfter
+}
+
+// Forget drops what's known about the parent with the given queue key,
+// because it's gone or no longer managed by the controller.
+func (p *DeletionProtection) Forget(key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.undesired, key)
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestDeletionProtection(t *testing.T) {
+	child := func(name string, confirmed bool) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetUID(types.UID(name))
+		if confirmed {
+			obj.SetAnnotations(map[string]string{ConfirmDeletionAnnotation: "true"})
+		}
+		return obj
+	}
+	names := func(children ChildMap) []string {
+		got := []string{}
+		for _, obj := range children.List() {
+			got = append(got, obj.GetName())
+		}
+		sort.Strings(got)
+		return got
+	}
+	parent := &unstructured.Unstructured{}
+
+	table := []struct {
+		name   string
+		policy *v1alpha1.ChildDeletionProtection
+		// syncs are the desired children of successive syncs, with the
+		// observed children staying the same.
+		syncs [][]*unstructured.Unstructured
+		want  []string
+		// wantCount is checked instead of want, if set, when it's not known
+		// which children are kept.
+		wantCount int
+		wantRetry bool
+	}{
+		{
+			name:  "no protection",
+			syncs: [][]*unstructured.Unstructured{nil},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:   "held for first sync",
+			policy: &v1alpha1.ChildDeletionProtection{MinSyncs: 2},
+			syncs:  [][]*unstructured.Unstructured{{child("a", false)}},
+			want:   []string{"a"},
+		},
+		{
+			name:   "deleted after min syncs",
+			policy: &v1alpha1.ChildDeletionProtection{MinSyncs: 2},
+			syncs:  [][]*unstructured.Unstructured{{child("a", false)}, {child("a", false)}},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "count restarts when desired again",
+			policy: &v1alpha1.ChildDeletionProtection{MinSyncs: 2},
+			syncs:  [][]*unstructured.Unstructured{nil, {child("a", false), child("b", false)}, nil},
+			want:   []string{},
+		},
+		{
+			name:   "requires confirmation",
+			policy: &v1alpha1.ChildDeletionProtection{RequireConfirmation: true},
+			syncs:  [][]*unstructured.Unstructured{nil},
+			want:   []string{"b"},
+		},
+		{
+			name:      "max per sync",
+			policy:    &v1alpha1.ChildDeletionProtection{MaxPerSync: 1},
+			syncs:     [][]*unstructured.Unstructured{nil},
+			wantCount: 1,
+			wantRetry: true,
+		},
+		{
+			name:      "max per minute",
+			policy:    &v1alpha1.ChildDeletionProtection{MaxPerMinute: 1},
+			syncs:     [][]*unstructured.Unstructured{nil, nil},
+			want:      []string{},
+			wantRetry: true,
+		},
+	}
+
+	for _, tc := range table {
+		observed := MakeChildMap(parent, []*unstructured.Unstructured{child("a", false), child("b", true)})
+		p := NewDeletionProtection("CompositeController", "test", tc.policy)
+		var got ChildMap
+		var retryAfter time.Duration
+		for _, desired := range tc.syncs {
+			got, retryAfter = p.Filter(nil, "ns/parent", parent, observed, MakeChildMap(parent, desired))
+		}
+		gotNames := names(got)
+		if tc.wantCount > 0 {
+			if len(gotNames) != tc.wantCount {
+				t.Errorf("%v: Filter() = %v, want %v children", tc.name, gotNames, tc.wantCount)
+			}
+		} else if !reflect.DeepEqual(gotNames, tc.want) {
+			t.Errorf("%v: Filter() = %v, want %v", tc.name, gotNames, tc.want)
+		}
+		if (retryAfter > 0) != tc.wantRetry {
+			t.Errorf("%v: Filter() retry after %v, want retry %v", tc.name, retryAfter, tc.wantRetry)
+		}
+	}
+
+	// Children of a parent pending deletion are never held back.
+	deleting := parent.DeepCopy()
+	now := metav1.Now()
+	deleting.SetDeletionTimestamp(&now)
+	p := NewDeletionProtection("CompositeController", "test", &v1alpha1.ChildDeletionProtection{MinSyncs: 3, RequireConfirmation: true})
+	observed := MakeChildMap(parent, []*unstructured.Unstructured{child("a", false)})
+	got, _ := p.Filter(nil, "ns/parent", deleting, observed, nil)
+	if got := names(got); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Filter() = %v for a parent pending deletion, want [a]", got)
+	}
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	"metacontroller.io/events"
+)
+
+// updateIsNoop asks the API server what it would store if we updated oldObj
+// to newObj, and returns whether that's the same as what's already there.
+//
+// The 3-way merge can't tell that a child is up to date when the hook sets
+// a field to a value the API server normalizes (like a quantity of "0.5"
+// that's stored as "500m"), or omits fields the API server defaults inside
+// lists it replaces. Without this check, such children are updated (or
+// recreated) on every sync, forever.
+//
+// If the dry-run fails, for example because the cluster or an admission
+// webhook doesn't support it, we assume the update is needed.
+func updateIsNoop(client *dynamicclientset.ResourceClient, oldObj, newObj *unstructured.Unstructured) bool {
+	result, err := client.Namespace(oldObj.GetNamespace()).Update(newObj, metav1.UpdateOptions{
+		DryRun:       []string{metav1.DryRunAll},
+		FieldManager: FieldManager,
+	})
+	if err != nil {
+		klog.V(4).InfoS("Dry-run update failed", "child", klog.KObj(oldObj), "err", err)
+		return false
+	}
+	return equalIgnoringServerFields(oldObj, result)
+}
+
+// serverMetadataFields are the fields of ObjectMeta that the API server
+// may change on an update that has no other effect.
+var serverMetadataFields = []string{
+	"resourceVersion",
+	"managedFields",
+}
+
+// equalIgnoringServerFields returns whether a and b are the same, apart from
+// serverMetadataFields.
+func equalIgnoringServerFields(a, b *unstructured.Unstructured) bool {
+	a, b = a.DeepCopy(), b.DeepCopy()
+	for _, field := range serverMetadataFields {
+		unstructured.RemoveNestedField(a.UnstructuredContent(), "metadata", field)
+		unstructured.RemoveNestedField(b.UnstructuredContent(), "metadata", field)
+	}
+	return reflect.DeepEqual(a.UnstructuredContent(), b.UnstructuredContent())
+}
+
+// DryRunChildren asks the API server to validate the desired children before
+// any of them is written: new children with a dry-run create, and existing
+// ones with a dry-run update to the result of the 3-way merge. Each rejected
+// child is reported with a ChildRejected warning event on the parent, which
+// says why, such as the admission webhook or the schema field that refused it.
+// If any child is rejected, an error is returned and nothing should be written,
+// so a bad manifest from a hook doesn't leave children half-updated.
+func DryRunChildren(dynClient *dynamicclientset.Clientset, eventRecorder record.EventRecorder, parent *unstructured.Unstructured, observed, desired ChildMap) error {
+	var errs []error
+	for key, objects := range desired {
+		apiVersion, kind := ParseChildMapKey(key)
+		client, err := dynClient.Kind(apiVersion, kind)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for name, obj := range objects {
+			obj = obj.DeepCopy()
+			if obj.GetNamespace() == "" && client.Namespaced {
+				obj.SetNamespace(parent.GetNamespace())
+			}
+			if err := dryRunChild(client.Namespace(obj.GetNamespace()), observed[key][name], obj); err != nil {
+				klog.InfoS("Child rejected by dry-run", "parent", klog.KObj(parent), "child", klog.KObj(obj), "err", err)
+				if eventRecorder != nil {
+					eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonChildRejected,
+						"Desired %v was rejected by the API server: %v", describeObject(obj), err)
+				}
+				errs = append(errs, fmt.Errorf("desired %v was rejected: %v", describeObject(obj), err))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// dryRunChild does a dry-run write of desired, as a create if there's no
+// observed child, or as an update of observed otherwise.
+func dryRunChild(client *dynamicclientset.ResourceClient, observed, desired *unstructured.Unstructured) error {
+	options := []string{metav1.DryRunAll}
+	if observed == nil {
+		_, err := client.Create(desired, metav1.CreateOptions{DryRun: options, FieldManager: FieldManager})
+		return err
+	}
+	if observed.GetDeletionTimestamp() != nil {
+		// It won't be updated anyway.
+		return nil
+	}
+	newObj, err := ApplyUpdate(observed, desired)
+	if err != nil {
+		return err
+	}
+	if SemanticDeepEqual(newObj.UnstructuredContent(), observed.UnstructuredContent()) {
+		return nil
+	}
+	_, err = client.Update(newObj, metav1.UpdateOptions{DryRun: options, FieldManager: FieldManager})
+	return err
+}
@@ -0,0 +1,182 @@
+package common
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEqualIgnoringServerFields(t *testing.T) {
+	table := []struct {
+		name string
+		a, b map[string]interface{}
+		want bool
+	}{
+		{
+			name: "equal",
+			a:    map[string]interface{}{"metadata": map[string]interface{}{"name": "test"}, "spec": map[string]interface{}{"cpu": "500m"}},
+			b:    map[string]interface{}{"metadata": map[string]interface{}{"name": "test"}, "spec": map[string]interface{}{"cpu": "500m"}},
+			want: true,
+		},
+		{
+			name: "server fields differ",
+			a: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "test", "resourceVersion": "1"},
+				"spec":     map[string]interface{}{"cpu": "500m"},
+			},
+			b: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "test", "resourceVersion": "2", "managedFields": []interface{}{}},
+				"spec":     map[string]interface{}{"cpu": "500m"},
+			},
+			want: true,
+		},
+		{
+			name: "spec differs",
+			a:    map[string]interface{}{"metadata": map[string]interface{}{"name": "test"}, "spec": map[string]interface{}{"cpu": "500m"}},
+			b:    map[string]interface{}{"metadata": map[string]interface{}{"name": "test"}, "spec": map[string]interface{}{"cpu": "1"}},
+			want: false,
+		},
+		{
+			name: "annotations differ",
+			a:    map[string]interface{}{"metadata": map[string]interface{}{"name": "test", "annotations": map[string]interface{}{"a": "1"}}},
+			b:    map[string]interface{}{"metadata": map[string]interface{}{"name": "test", "annotations": map[string]interface{}{"a": "2"}}},
+			want: false,
+		},
+	}
+
+	for _, tc := range table {
+		a := &unstructured.Unstructured{Object: tc.a}
+		b := &unstructured.Unstructured{Object: tc.b}
+		if got := equalIgnoringServerFields(a, b); got != tc.want {
+			t.Errorf("%v: equalIgnoringServerFields() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDryRunChildren(t *testing.T) {
+	configMap := func(name, value string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.Object["data"] = map[string]interface{}{"key": value}
+		return obj
+	}
+	// applied returns obj as written by a previous sync.
+	applied := func(obj *unstructured.Unstructured) *unstructured.Unstructured {
+		obj.SetNamespace("default")
+		result, err := ApplyUpdate(obj, obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	deleting := func(obj *unstructured.Unstructured) *unstructured.Unstructured {
+		now := metav1.Now()
+		obj.SetDeletionTimestamp(&now)
+		return obj
+	}
+
+	table := []struct {
+		name      string
+		observed  []*unstructured.Unstructured
+		desired   []*unstructured.Unstructured
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			name:      "new child",
+			desired:   []*unstructured.Unstructured{configMap("a", "1")},
+			wantCalls: []string{"POST"},
+		},
+		{
+			name:      "changed child",
+			observed:  []*unstructured.Unstructured{configMap("a", "1")},
+			desired:   []*unstructured.Unstructured{configMap("a", "2")},
+			wantCalls: []string{"PUT a"},
+		},
+		{
+			name:     "unchanged child",
+			observed: []*unstructured.Unstructured{applied(configMap("a", "1"))},
+			desired:  []*unstructured.Unstructured{configMap("a", "1")},
+		},
+		{
+			name:     "child being deleted",
+			observed: []*unstructured.Unstructured{deleting(configMap("a", "1"))},
+			desired:  []*unstructured.Unstructured{configMap("a", "2")},
+		},
+		{
+			name:      "rejected new child",
+			desired:   []*unstructured.Unstructured{configMap("a", "invalid")},
+			wantCalls: []string{"POST"},
+			wantErr:   true,
+		},
+		{
+			name:      "rejected update",
+			observed:  []*unstructured.Unstructured{configMap("a", "1")},
+			desired:   []*unstructured.Unstructured{configMap("a", "invalid")},
+			wantCalls: []string{"PUT a"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range table {
+		var calls []string
+		apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call := r.Method
+			if r.Method == http.MethodPut {
+				call += " " + r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			}
+			calls = append(calls, call)
+			if dryRun := r.URL.Query().Get("dryRun"); dryRun != metav1.DryRunAll {
+				t.Errorf("%v: %v with dryRun %q, want %q", tc.name, call, dryRun, metav1.DryRunAll)
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			if strings.Contains(string(body), "invalid") {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "Invalid", "message": "data.key: Invalid value", "code": 422}`))
+				return
+			}
+			w.Write(body)
+		}))
+		dynClient := newTestClientset(t, apiServer.URL, "v1", "configmaps")
+
+		parent := &unstructured.Unstructured{}
+		parent.SetAPIVersion("example.com/v1")
+		parent.SetKind("Thing")
+		parent.SetNamespace("default")
+		parent.SetName("parent")
+		observed, desired := make(ChildMap), make(ChildMap)
+		observed.InsertAll(parent, tc.observed)
+		desired.InsertAll(parent, tc.desired)
+		recorder := record.NewFakeRecorder(10)
+
+		err := DryRunChildren(dynClient, recorder, parent, observed, desired)
+		apiServer.Close()
+		if got := err != nil; got != tc.wantErr {
+			t.Errorf("%v: DryRunChildren() = %v, want error: %v", tc.name, err, tc.wantErr)
+		}
+		if !reflect.DeepEqual(calls, tc.wantCalls) {
+			t.Errorf("%v: API calls = %v, want %v", tc.name, calls, tc.wantCalls)
+		}
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		var wantEvents []string
+		if tc.wantErr {
+			wantEvents = []string{"Warning ChildRejected Desired ConfigMap default/a was rejected by the API server: data.key: Invalid value"}
+		}
+		if !reflect.DeepEqual(events, wantEvents) {
+			t.Errorf("%v: events = %q, want %q", tc.name, events, wantEvents)
+		}
+	}
+}
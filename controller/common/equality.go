@@ -0,0 +1,104 @@
+package common
+
+import (
+	"reflect"
+)
+
+// SemanticDeepEqual returns whether two JSON values, like the contents of
+// an observed and a desired child, are the same once they've been through
+// the API server. Unlike reflect.DeepEqual, it ignores differences that
+// hooks written in languages with loose JSON typing tend to introduce:
+//
+//   - numbers are compared by value, so 1 and 1.0 are equal;
+//   - null, empty objects and empty lists are equal to each other, and to
+//     fields that are absent altogether.
+func SemanticDeepEqual(a, b interface{}) bool {
+	if isEmptyJSON(a) && isEmptyJSON(b) {
+		return true
+	}
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range a {
+			if !SemanticDeepEqual(value, b[key]) {
+				return false
+			}
+		}
+		for key, value := range b {
+			if _, ok := a[key]; !ok && !isEmptyJSON(value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !SemanticDeepEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if x, ok := jsonInt(a); ok {
+		if y, ok := jsonInt(b); ok {
+			return x == y
+		}
+	}
+	if x, ok := jsonNumber(a); ok {
+		if y, ok := jsonNumber(b); ok {
+			return x == y
+		}
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// isEmptyJSON returns whether v is null, an empty object or an empty list.
+func isEmptyJSON(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// jsonInt returns the value of v if it's an integer type.
+// Integers are compared as such to avoid losing precision on large values.
+func jsonInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// jsonNumber returns the value of v if it's any numeric type.
+func jsonNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestSemanticDeepEqual(t *testing.T) {
+	table := []struct {
+		name string
+		a, b interface{}
+		want bool
+	}{
+		{name: "equal scalars", a: "x", b: "x", want: true},
+		{name: "different scalars", a: "x", b: "y", want: false},
+		{name: "int vs float", a: int64(1), b: float64(1), want: true},
+		{name: "int vs fractional float", a: int64(1), b: 1.5, want: false},
+		{name: "large ints", a: int64(1<<62 + 1), b: int64(1 << 62), want: false},
+		{name: "number vs string", a: int64(1), b: "1", want: false},
+		{name: "null vs empty object", a: nil, b: map[string]interface{}{}, want: true},
+		{name: "null vs empty list", a: nil, b: []interface{}{}, want: true},
+		{
+			name: "absent vs empty fields",
+			a:    map[string]interface{}{"a": int64(1)},
+			b:    map[string]interface{}{"a": 1.0, "b": nil, "c": map[string]interface{}{}, "d": []interface{}{}},
+			want: true,
+		},
+		{
+			name: "absent vs set field",
+			a:    map[string]interface{}{"a": int64(1)},
+			b:    map[string]interface{}{"a": int64(1), "b": false},
+			want: false,
+		},
+		{
+			name: "set vs absent field",
+			a:    map[string]interface{}{"a": int64(1), "b": ""},
+			b:    map[string]interface{}{"a": int64(1)},
+			want: false,
+		},
+		{
+			name: "nested lists",
+			a:    map[string]interface{}{"list": []interface{}{map[string]interface{}{"x": int64(2), "y": nil}}},
+			b:    map[string]interface{}{"list": []interface{}{map[string]interface{}{"x": 2.0}}},
+			want: true,
+		},
+		{
+			name: "list order",
+			a:    []interface{}{"a", "b"},
+			b:    []interface{}{"b", "a"},
+			want: false,
+		},
+		{
+			name: "object vs list",
+			a:    map[string]interface{}{"a": "b"},
+			b:    []interface{}{"a", "b"},
+			want: false,
+		},
+	}
+
+	for _, tc := range table {
+		if got := SemanticDeepEqual(tc.a, tc.b); got != tc.want {
+			t.Errorf("%v: SemanticDeepEqual(a, b) = %v, want %v", tc.name, got, tc.want)
+		}
+		if got := SemanticDeepEqual(tc.b, tc.a); got != tc.want {
+			t.Errorf("%v: SemanticDeepEqual(b, a) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
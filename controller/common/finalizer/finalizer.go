@@ -20,34 +20,116 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
+	"metacontroller.io/apis/metacontroller/v1alpha1"
 	dynamicclientset "metacontroller.io/dynamic/clientset"
 	dynamicobject "metacontroller.io/dynamic/object"
 )
 
 // Manager encapsulates controller logic for dealing with finalizers.
 type Manager struct {
-	Name    string
-	Enabled bool
+	Name string
+	// PreviousNames are finalizers the controller used to add before its
+	// finalizer was renamed. They're treated as equivalent to Name, and are
+	// replaced by Name whenever the object is synced.
+	PreviousNames []string
+	Enabled       bool
+}
+
+// NewManager returns a Manager for the given default finalizer name,
+// which may be overridden by the controller's finalizer config (if any).
+func NewManager(defaultName string, config *v1alpha1.ControllerFinalizer, enabled bool) *Manager {
+	m := &Manager{
+		Name:    defaultName,
+		Enabled: enabled,
+	}
+	if config != nil {
+		if config.Name != "" {
+			m.Name = config.Name
+		}
+		for _, name := range config.PreviousNames {
+			if name != m.Name {
+				m.PreviousNames = append(m.PreviousNames, name)
+			}
+		}
+	}
+	return m
+}
+
+// HasFinalizer returns true if obj has the finalizer under its current name
+// or any of its previous names.
+func (m *Manager) HasFinalizer(obj metav1.Object) bool {
+	if dynamicobject.HasFinalizer(obj, m.Name) {
+		return true
+	}
+	return m.hasPreviousFinalizer(obj)
+}
+
+func (m *Manager) hasPreviousFinalizer(obj metav1.Object) bool {
+	for _, name := range m.PreviousNames {
+		if dynamicobject.HasFinalizer(obj, name) {
+			return true
+		}
+	}
+	return false
 }
 
 // SyncObject adds or removes the finalizer on the given object as necessary.
 func (m *Manager) SyncObject(client *dynamicclientset.ResourceClient, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
 	// If the cached object passed in is already in the right state,
 	// we'll assume we don't need to check the live object.
-	if dynamicobject.HasFinalizer(obj, m.Name) == m.Enabled {
+	if dynamicobject.HasFinalizer(obj, m.Name) == m.Enabled && !m.hasPreviousFinalizer(obj) {
 		return obj, nil
 	}
 	// Otherwise, we may need to update the object.
 	if m.Enabled {
 		// If the object is already pending deletion, we don't add the finalizer.
-		// We might have already removed it.
+		// We might have already removed it. Any previous name is left in place
+		// until finalization is done, so we keep blocking deletion until then.
 		if obj.GetDeletionTimestamp() != nil {
 			return obj, nil
 		}
-		return client.Namespace(obj.GetNamespace()).AddFinalizer(obj, m.Name)
+		return client.Namespace(obj.GetNamespace()).AtomicUpdate(obj, func(obj *unstructured.Unstructured) bool {
+			changed := m.removePreviousFinalizers(obj)
+			if !dynamicobject.HasFinalizer(obj, m.Name) {
+				dynamicobject.AddFinalizer(obj, m.Name)
+				changed = true
+			}
+			return changed
+		})
 	} else {
-		return client.Namespace(obj.GetNamespace()).RemoveFinalizer(obj, m.Name)
+		return m.RemoveFinalizer(client, obj)
+	}
+}
+
+// RemoveFinalizer removes the finalizer from the given object, under its
+// current name as well as any of its previous names.
+func (m *Manager) RemoveFinalizer(client *dynamicclientset.ResourceClient, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	return client.Namespace(obj.GetNamespace()).AtomicUpdate(obj, func(obj *unstructured.Unstructured) bool {
+		changed := m.removePreviousFinalizers(obj)
+		if dynamicobject.HasFinalizer(obj, m.Name) {
+			dynamicobject.RemoveFinalizer(obj, m.Name)
+			changed = true
+		}
+		return changed
+	})
+}
+
+// RemoveFinalizerFrom removes the finalizer from the given object in memory,
+// under its current name as well as any of its previous names.
+func (m *Manager) RemoveFinalizerFrom(obj metav1.Object) {
+	m.removePreviousFinalizers(obj)
+	dynamicobject.RemoveFinalizer(obj, m.Name)
+}
+
+func (m *Manager) removePreviousFinalizers(obj metav1.Object) bool {
+	changed := false
+	for _, name := range m.PreviousNames {
+		if dynamicobject.HasFinalizer(obj, name) {
+			dynamicobject.RemoveFinalizer(obj, name)
+			changed = true
+		}
 	}
+	return changed
 }
 
 // ShouldFinalize returns true if the controller should take action to manage
@@ -59,7 +141,7 @@ func (m *Manager) ShouldFinalize(parent metav1.Object) bool {
 		return false
 	}
 	// If we already removed the finalizer, don't try to manage children anymore.
-	if !dynamicobject.HasFinalizer(parent, m.Name) {
+	if !m.HasFinalizer(parent) {
 		return false
 	}
 	return m.Enabled
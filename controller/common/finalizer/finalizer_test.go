@@ -0,0 +1,65 @@
+package finalizer
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestNewManager(t *testing.T) {
+	table := []struct {
+		name              string
+		config            *v1alpha1.ControllerFinalizer
+		wantName          string
+		wantPreviousNames []string
+	}{
+		{
+			name:     "default name",
+			wantName: "metacontroller.io/compositecontroller-test",
+		},
+		{
+			name:     "overridden name",
+			config:   &v1alpha1.ControllerFinalizer{Name: "example.com/test"},
+			wantName: "example.com/test",
+		},
+		{
+			name: "previous names exclude current name",
+			config: &v1alpha1.ControllerFinalizer{
+				Name:          "example.com/test",
+				PreviousNames: []string{"metacontroller.io/compositecontroller-test", "example.com/test"},
+			},
+			wantName:          "example.com/test",
+			wantPreviousNames: []string{"metacontroller.io/compositecontroller-test"},
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewManager("metacontroller.io/compositecontroller-test", tc.config, true)
+			if m.Name != tc.wantName {
+				t.Errorf("Name = %q, want %q", m.Name, tc.wantName)
+			}
+			if !reflect.DeepEqual(m.PreviousNames, tc.wantPreviousNames) {
+				t.Errorf("PreviousNames = %v, want %v", m.PreviousNames, tc.wantPreviousNames)
+			}
+		})
+	}
+}
+
+func TestManagerRecognizesPreviousNames(t *testing.T) {
+	m := NewManager("new", &v1alpha1.ControllerFinalizer{PreviousNames: []string{"old"}}, true)
+	obj := &unstructured.Unstructured{}
+	obj.SetFinalizers([]string{"other", "old"})
+
+	if !m.HasFinalizer(obj) {
+		t.Error("Expected previous finalizer name to be recognized")
+	}
+
+	m.RemoveFinalizerFrom(obj)
+	if got, want := obj.GetFinalizers(), []string{"other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetFinalizers() = %v, want %v", got, want)
+	}
+}
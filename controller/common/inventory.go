@@ -0,0 +1,255 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicinformer "metacontroller.io/dynamic/informer"
+)
+
+// InventoryAnnotation is set on parents, if requested, to the inventory of
+// their children after their last sync, as a JSON list of InventoryEntry.
+// Large inventories are gzipped and base64 encoded, behind
+// compressedInventoryPrefix.
+const InventoryAnnotation = "metacontroller.k8s.io/inventory"
+
+const (
+	// MaxInventorySize is the maximum size of the inventory annotation. The
+	// API server rejects objects whose annotations total more than 256KiB,
+	// so this leaves room for the other annotations of the parent.
+	MaxInventorySize = 128 * 1024
+
+	// inventoryCompressionThreshold is the size above which the inventory
+	// is compressed. Smaller ones are kept readable.
+	inventoryCompressionThreshold = 8 * 1024
+
+	compressedInventoryPrefix = "gzip:"
+)
+
+// InventoryTooLargeError is returned when the inventory of a parent doesn't
+// fit in MaxInventorySize, even compressed.
+type InventoryTooLargeError struct {
+	Entries int
+	Size    int
+}
+
+func (e *InventoryTooLargeError) Error() string {
+	return fmt.Sprintf("inventory of %v children takes %v bytes compressed, more than the maximum of %v", e.Entries, e.Size, MaxInventorySize)
+}
+
+// IsInventoryTooLarge returns whether err is an InventoryTooLargeError.
+func IsInventoryTooLarge(err error) bool {
+	var sizeErr *InventoryTooLargeError
+	return errors.As(err, &sizeErr)
+}
+
+// InventoryEntry identifies a child in an inventory.
+type InventoryEntry struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// Inventory lists the children a parent had after its last sync, so the
+// children of kinds that were since removed from the controller's child
+// rules can still be found and deleted.
+type Inventory []InventoryEntry
+
+// ReadInventory returns the inventory recorded on parent, if any.
+func ReadInventory(parent *unstructured.Unstructured) (Inventory, error) {
+	value, ok := parent.GetAnnotations()[InventoryAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	data, err := decodeInventory(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v annotation: %v", InventoryAnnotation, err)
+	}
+	var inventory Inventory
+	if err := json.Unmarshal(data, &inventory); err != nil {
+		return nil, fmt.Errorf("invalid %v annotation: %v", InventoryAnnotation, err)
+	}
+	return inventory, nil
+}
+
+// encodeInventory returns the value of the inventory annotation for
+// inventory, compressed if it's large.
+func encodeInventory(inventory Inventory) (string, error) {
+	data, err := json.Marshal(inventory)
+	if err != nil {
+		return "", err
+	}
+	if len(data) <= inventoryCompressionThreshold {
+		return string(data), nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	value := compressedInventoryPrefix + base64.StdEncoding.EncodeToString(buf.Bytes())
+	if len(value) > MaxInventorySize {
+		return "", &InventoryTooLargeError{Entries: len(inventory), Size: len(value)}
+	}
+	return value, nil
+}
+
+// decodeInventory returns the JSON encoding of the inventory in the value of
+// the inventory annotation.
+func decodeInventory(value string) ([]byte, error) {
+	if !strings.HasPrefix(value, compressedInventoryPrefix) {
+		return []byte(value), nil
+	}
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, compressedInventoryPrefix))
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// MakeInventory returns the sorted inventory of the given children, without
+// duplicates, along with the extra entries.
+func MakeInventory(extra Inventory, children ...ChildMap) Inventory {
+	seen := make(map[InventoryEntry]bool)
+	inventory := make(Inventory, 0, len(extra))
+	add := func(entry InventoryEntry) {
+		if !seen[entry] {
+			seen[entry] = true
+			inventory = append(inventory, entry)
+		}
+	}
+	for _, entry := range extra {
+		add(entry)
+	}
+	for _, childMap := range children {
+		for _, obj := range childMap.List() {
+			add(InventoryEntry{
+				APIVersion: obj.GetAPIVersion(),
+				Kind:       obj.GetKind(),
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+			})
+		}
+	}
+	sort.Slice(inventory, func(i, j int) bool {
+		a, b := inventory[i], inventory[j]
+		if a.APIVersion != b.APIVersion {
+			return a.APIVersion < b.APIVersion
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return inventory
+}
+
+// PruneInventory deletes the children in inventory whose kind isn't managed
+// any more, as long as they still belong to parent. It returns the entries
+// that couldn't be deleted yet, so they're kept in the inventory and retried.
+func PruneInventory(log logr.Logger, dynClient *dynamicclientset.Clientset, parent *unstructured.Unstructured, inventory Inventory, managed func(apiVersion, kind string) bool) (Inventory, error) {
+	var remaining Inventory
+	var errs []error
+	for _, entry := range inventory {
+		if managed(entry.APIVersion, entry.Kind) {
+			continue
+		}
+		client, err := dynClient.Kind(entry.APIVersion, entry.Kind)
+		if err != nil {
+			// The kind is gone from the API server, and its objects with it.
+			log.V(4).Info("Dropping inventory entry of unknown kind", "child_kind", entry.Kind, "apiVersion", entry.APIVersion)
+			continue
+		}
+		obj, err := client.Namespace(entry.Namespace).Get(entry.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			remaining = append(remaining, entry)
+			errs = append(errs, fmt.Errorf("can't get %v %v/%v: %v", entry.Kind, entry.Namespace, entry.Name, err))
+			continue
+		}
+		if !belongsTo(obj, parent.GetUID()) || obj.GetDeletionTimestamp() != nil {
+			continue
+		}
+		log.Info("Deleting child of removed kind", "child", klog.KObj(obj), "child_kind", entry.Kind)
+		uid := obj.GetUID()
+		propagation := metav1.DeletePropagationBackground
+		err = client.Namespace(entry.Namespace).Delete(entry.Name, &metav1.DeleteOptions{
+			Preconditions:     &metav1.Preconditions{UID: &uid},
+			PropagationPolicy: &propagation,
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			remaining = append(remaining, entry)
+			errs = append(errs, fmt.Errorf("can't delete %v: %v", describeObject(obj), err))
+		}
+	}
+	return remaining, utilerrors.NewAggregate(errs)
+}
+
+// belongsTo returns whether obj has an owner reference to the parent with
+// the given UID, or is tracked by label as its child.
+func belongsTo(obj *unstructured.Unstructured, parentUID types.UID) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == parentUID {
+			return true
+		}
+	}
+	return obj.GetLabels()[dynamicinformer.ParentUIDLabel] == string(parentUID)
+}
+
+// WriteInventory records inventory on parent, unless it's already there, and
+// returns the updated parent. It returns an InventoryTooLargeError, without
+// updating parent, if inventory doesn't fit in MaxInventorySize.
+func WriteInventory(parentClient *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, inventory Inventory) (*unstructured.Unstructured, error) {
+	value, err := encodeInventory(inventory)
+	if err != nil {
+		return nil, err
+	}
+	if current, ok := parent.GetAnnotations()[InventoryAnnotation]; ok && current == value {
+		return parent, nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				InventoryAnnotation: value,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	updated, err := parentClient.Namespace(parent.GetNamespace()).Patch(parent.GetName(), types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+	if err != nil {
+		return nil, fmt.Errorf("can't record inventory of %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	return updated, nil
+}
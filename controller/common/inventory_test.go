@@ -0,0 +1,201 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	dynamicinformer "metacontroller.io/dynamic/informer"
+)
+
+func TestMakeInventory(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("ns")
+	child := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetNamespace("ns")
+		obj.SetName(name)
+		return obj
+	}
+	observed := MakeChildMap(parent, []*unstructured.Unstructured{child("v1", "ConfigMap", "b"), child("apps/v1", "Deployment", "web")})
+	desired := MakeChildMap(parent, []*unstructured.Unstructured{child("v1", "ConfigMap", "a"), child("v1", "ConfigMap", "b")})
+	extra := Inventory{{APIVersion: "v1", Kind: "Secret", Namespace: "ns", Name: "old"}}
+
+	got := MakeInventory(extra, observed, desired)
+	want := Inventory{
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "web"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "a"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "b"},
+		{APIVersion: "v1", Kind: "Secret", Namespace: "ns", Name: "old"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MakeInventory() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadInventory(t *testing.T) {
+	table := []struct {
+		name        string
+		annotations map[string]string
+		want        Inventory
+		wantErr     bool
+	}{
+		{name: "no inventory"},
+		{
+			name:        "inventory",
+			annotations: map[string]string{InventoryAnnotation: `[{"apiVersion":"v1","kind":"ConfigMap","name":"a"}]`},
+			want:        Inventory{{APIVersion: "v1", Kind: "ConfigMap", Name: "a"}},
+		},
+		{
+			name:        "compressed inventory",
+			annotations: map[string]string{InventoryAnnotation: compressedInventoryPrefix + "H4sIAAAAAAACA4uuVkosyAxLLSrOzM9TslIqM1TSUcrOzEsBsp3z89Iy030TC4BCeYm5qUChRKXaWAB78hHRMwAAAA=="},
+			want:        Inventory{{APIVersion: "v1", Kind: "ConfigMap", Name: "a"}},
+		},
+		{
+			name:        "invalid compressed inventory",
+			annotations: map[string]string{InventoryAnnotation: compressedInventoryPrefix + "not base64"},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid inventory",
+			annotations: map[string]string{InventoryAnnotation: "{"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range table {
+		parent := &unstructured.Unstructured{}
+		parent.SetAnnotations(tc.annotations)
+		got, err := ReadInventory(parent)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%v: ReadInventory() error = %v, want error %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: ReadInventory() = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEncodeInventory(t *testing.T) {
+	entries := func(n int, name func(i int) string) Inventory {
+		inventory := make(Inventory, n)
+		for i := range inventory {
+			inventory[i] = InventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: name(i)}
+		}
+		return inventory
+	}
+
+	table := []struct {
+		name           string
+		inventory      Inventory
+		wantCompressed bool
+		wantTooLarge   bool
+	}{
+		{
+			name:      "small",
+			inventory: entries(2, func(i int) string { return fmt.Sprintf("child-%d", i) }),
+		},
+		{
+			name:           "large",
+			inventory:      entries(5000, func(i int) string { return fmt.Sprintf("child-%d", i) }),
+			wantCompressed: true,
+		},
+		{
+			name:         "too large",
+			inventory:    incompressibleInventory(1000),
+			wantTooLarge: true,
+		},
+	}
+
+	for _, tc := range table {
+		value, err := encodeInventory(tc.inventory)
+		if tc.wantTooLarge {
+			if !IsInventoryTooLarge(err) {
+				t.Errorf("%v: encodeInventory() = %v, want InventoryTooLargeError", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: encodeInventory() = %v", tc.name, err)
+			continue
+		}
+		if got := strings.HasPrefix(value, compressedInventoryPrefix); got != tc.wantCompressed {
+			t.Errorf("%v: encodeInventory() compressed = %v, want %v", tc.name, got, tc.wantCompressed)
+		}
+		if len(value) > MaxInventorySize {
+			t.Errorf("%v: encodeInventory() size = %v, want at most %v", tc.name, len(value), MaxInventorySize)
+		}
+		parent := &unstructured.Unstructured{}
+		parent.SetAnnotations(map[string]string{InventoryAnnotation: value})
+		got, err := ReadInventory(parent)
+		if err != nil {
+			t.Errorf("%v: ReadInventory() = %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.inventory) {
+			t.Errorf("%v: ReadInventory() = %v entries, want %v", tc.name, len(got), len(tc.inventory))
+		}
+	}
+}
+
+func TestWriteInventory_tooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		http.Error(w, "unexpected", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	client := newTestResourceClient(t, srv.URL, "v1", "configmaps")
+
+	inventory := incompressibleInventory(1000)
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("ns")
+	parent.SetName("parent")
+
+	if _, err := WriteInventory(client, parent, inventory); !IsInventoryTooLarge(err) {
+		t.Errorf("WriteInventory() = %v, want InventoryTooLargeError", err)
+	}
+}
+
+// incompressibleInventory returns an inventory of n ConfigMaps with random
+// names of the maximum length.
+func incompressibleInventory(n int) Inventory {
+	random := rand.New(rand.NewSource(1))
+	inventory := make(Inventory, n)
+	for i := range inventory {
+		name := make([]byte, 253)
+		for j := range name {
+			name[j] = byte('a' + random.Intn(26))
+		}
+		inventory[i] = InventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: string(name)}
+	}
+	return inventory
+}
+
+func TestBelongsTo(t *testing.T) {
+	owned := &unstructured.Unstructured{}
+	owned.SetOwnerReferences([]metav1.OwnerReference{{UID: "parent-uid"}})
+	labeled := &unstructured.Unstructured{}
+	labeled.SetLabels(map[string]string{dynamicinformer.ParentUIDLabel: "parent-uid"})
+	other := &unstructured.Unstructured{}
+	other.SetOwnerReferences([]metav1.OwnerReference{{UID: "other-uid"}})
+
+	if !belongsTo(owned, "parent-uid") {
+		t.Error("belongsTo() = false for a child with an owner reference to the parent")
+	}
+	if !belongsTo(labeled, "parent-uid") {
+		t.Error("belongsTo() = false for a child tracked by label")
+	}
+	if belongsTo(other, "parent-uid") {
+		t.Error("belongsTo() = true for the child of another parent")
+	}
+}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	dynamicobject "metacontroller.io/dynamic/object"
+)
+
+// JobState is whether a Job is still running, or how it finished.
+type JobState string
+
+const (
+	JobRunning  JobState = "Running"
+	JobComplete JobState = "Complete"
+	JobFailed   JobState = "Failed"
+)
+
+// GetJobState returns the state of a Job, or of any object that reports
+// Complete and Failed conditions like Jobs do. A Job is Failed once it hits
+// its backoff limit or deadline.
+func GetJobState(obj *unstructured.Unstructured) JobState {
+	for _, state := range []JobState{JobComplete, JobFailed} {
+		condition, err := dynamicobject.GetStatusCondition(obj.UnstructuredContent(), string(state))
+		if err == nil && condition != nil && condition.Status == "True" {
+			return state
+		}
+	}
+	return JobRunning
+}
+
+// JobSummary lists the Job children of a parent by state, so hooks don't have
+// to look at their conditions.
+type JobSummary struct {
+	Running  []string `json:"running,omitempty"`
+	Complete []string `json:"complete,omitempty"`
+	Failed   []string `json:"failed,omitempty"`
+}
+
+// SummarizeJobs returns the summary of the Job children, by the names they
+// have in children, or nil if there are none.
+func SummarizeJobs(children ChildMap) *JobSummary {
+	var summary *JobSummary
+	for key, group := range children {
+		apiVersion, kind := ParseChildMapKey(key)
+		if apiGroup, _ := ParseAPIVersion(apiVersion); apiGroup != "batch" || kind != "Job" {
+			continue
+		}
+		for name, obj := range group {
+			if summary == nil {
+				summary = &JobSummary{}
+			}
+			switch GetJobState(obj) {
+			case JobComplete:
+				summary.Complete = append(summary.Complete, name)
+			case JobFailed:
+				summary.Failed = append(summary.Failed, name)
+			default:
+				summary.Running = append(summary.Running, name)
+			}
+		}
+	}
+	if summary != nil {
+		sort.Strings(summary.Running)
+		sort.Strings(summary.Complete)
+		sort.Strings(summary.Failed)
+	}
+	return summary
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newTestJob(name, condition string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("batch/v1")
+	obj.SetKind("Job")
+	obj.SetName(name)
+	if condition != "" {
+		obj.Object["status"] = map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": condition, "status": "True"},
+			},
+		}
+	}
+	return obj
+}
+
+func TestGetJobState(t *testing.T) {
+	table := []struct {
+		condition string
+		want      JobState
+	}{
+		{condition: "", want: JobRunning},
+		{condition: "Suspended", want: JobRunning},
+		{condition: "Complete", want: JobComplete},
+		{condition: "Failed", want: JobFailed},
+	}
+
+	for _, tc := range table {
+		if got := GetJobState(newTestJob("job", tc.condition)); got != tc.want {
+			t.Errorf("GetJobState() with %q condition = %v, want %v", tc.condition, got, tc.want)
+		}
+	}
+}
+
+func TestSummarizeJobs(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("config")
+
+	if got := SummarizeJobs(MakeChildMap(parent, []*unstructured.Unstructured{configMap})); got != nil {
+		t.Errorf("SummarizeJobs() without Jobs = %+v, want nil", got)
+	}
+
+	children := MakeChildMap(parent, []*unstructured.Unstructured{
+		configMap,
+		newTestJob("b", ""),
+		newTestJob("a", ""),
+		newTestJob("done", "Complete"),
+		newTestJob("broken", "Failed"),
+	})
+	want := &JobSummary{
+		Running:  []string{"a", "b"},
+		Complete: []string{"done"},
+		Failed:   []string{"broken"},
+	}
+	if got := SummarizeJobs(children); !reflect.DeepEqual(got, want) {
+		t.Errorf("SummarizeJobs() = %+v, want %+v", got, want)
+	}
+}
@@ -18,8 +18,9 @@ package common
 
 import (
 	"fmt"
-	"reflect"
+	"time"
 
+	"github.com/go-logr/logr"
 	"k8s.io/utils/pointer"
 
 	"k8s.io/klog/v2"
@@ -28,6 +29,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/diff"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 
 	"metacontroller.io/apis/metacontroller/v1alpha1"
 	dynamicapply "metacontroller.io/dynamic/apply"
@@ -62,6 +64,19 @@ func ApplyUpdate(orig, update *unstructured.Unstructured) (*unstructured.Unstruc
 	return newObj, nil
 }
 
+// ChildUpToDate returns whether observed already matches desired, so that
+// applying desired with applyMode wouldn't change anything.
+func ChildUpToDate(applyMode v1alpha1.ChildApplyMode, observed, desired *unstructured.Unstructured) (bool, error) {
+	if applyMode == v1alpha1.ChildApplyServerSide {
+		return ServerSideUpToDate(observed, desired), nil
+	}
+	updated, err := ApplyUpdate(observed, desired)
+	if err != nil {
+		return false, err
+	}
+	return SemanticDeepEqual(observed.UnstructuredContent(), updated.UnstructuredContent()), nil
+}
+
 // objectMetaSystemFields is a list of JSON field names within ObjectMeta that
 // are both read-only and system-populated according to the comments in
 // k8s.io/apimachinery/pkg/apis/meta/v1/types.go.
@@ -124,10 +139,16 @@ type ChildUpdateStrategy interface {
 	GetMethod(apiGroup, kind string) v1alpha1.ChildUpdateMethod
 }
 
-func ManageChildren(dynClient *dynamicclientset.Clientset, updateStrategy ChildUpdateStrategy, parent *unstructured.Unstructured, observedChildren, desiredChildren ChildMap) error {
+// ManageChildren deletes the observed children that aren't desired, and
+// creates or updates the desired ones. It also returns how soon to sync
+// parent again, if some children can only be created once a previous object
+// with the same name is gone, or zero.
+
+func ManageChildren(log logr.Logger, dynClient *dynamicclientset.Clientset, eventRecorder record.EventRecorder, applyMode v1alpha1.ChildApplyMode, updateStrategy ChildUpdateStrategy, childFinalizer *ChildFinalizer, ownerRefs ChildOwnerReferences, parent *unstructured.Unstructured, observedChildren, desiredChildren ChildMap, parallelism int) (time.Duration, error) {
 	// If some operations fail, keep trying others so, for example,
 	// we don't block recovery (create new Pod) on a failed delete.
 	var errs []error
+	workers := newChildWorkers(parallelism)
 
 	// Delete observed, owned objects that are not desired.
 	for key, objects := range observedChildren {
@@ -137,10 +158,10 @@ func ManageChildren(dynClient *dynamicclientset.Clientset, updateStrategy ChildU
 			errs = append(errs, err)
 			continue
 		}
-		if err := deleteChildren(client, parent, objects, desiredChildren[key]); err != nil {
-			errs = append(errs, err)
-			continue
-		}
+		deleteChildren(log, workers, client, childFinalizer, parent, objects, desiredChildren[key])
+	}
+	if err := workers.wait(); err != nil {
+		errs = append(errs, err)
 	}
 
 	// Create or update desired objects.
@@ -151,130 +172,229 @@ func ManageChildren(dynClient *dynamicclientset.Clientset, updateStrategy ChildU
 			errs = append(errs, err)
 			continue
 		}
-		if err := updateChildren(client, updateStrategy, parent, observedChildren[key], objects); err != nil {
-			errs = append(errs, err)
-			continue
-		}
+		updateChildren(log, workers, client, eventRecorder, applyMode, updateStrategy, childFinalizer, ownerRefs, parent, observedChildren[key], objects, nil)
+	}
+	if err := workers.wait(); err != nil {
+		errs = append(errs, err)
 	}
 
-	return utilerrors.NewAggregate(errs)
+	return workers.retryDelay(), utilerrors.NewAggregate(errs)
 }
 
-func deleteChildren(client *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, observed, desired map[string]*unstructured.Unstructured) error {
-	var errs []error
+// deleteChildren deletes the observed children that aren't desired, through
+// workers, and releases our finalizer from them when it's time.
+func deleteChildren(log logr.Logger, workers *childWorkers, client *dynamicclientset.ResourceClient, childFinalizer *ChildFinalizer, parent *unstructured.Unstructured, observed, desired map[string]*unstructured.Unstructured) {
 	for name, obj := range observed {
-		if obj.GetDeletionTimestamp() != nil {
-			// Skip objects that are already pending deletion.
-			continue
-		}
-		if desired == nil || desired[name] == nil {
-			// This observed object wasn't listed as desired.
-			klog.InfoS("Deleting child", "parent", klog.KObj(parent), "child", klog.KObj(obj))
-			uid := obj.GetUID()
-			// Explicitly request deletion propagation, which is what users expect,
-			// since some objects default to orphaning for backwards compatibility.
-			propagation := metav1.DeletePropagationBackground
-			err := client.Namespace(obj.GetNamespace()).Delete(obj.GetName(), &metav1.DeleteOptions{
-				Preconditions:     &metav1.Preconditions{UID: &uid},
-				PropagationPolicy: &propagation,
-			})
-			if err != nil {
-				errs = append(errs, fmt.Errorf("can't delete %v: %v", describeObject(obj), err))
-				continue
+		obj, desiredObj := obj, desired[name]
+		workers.run(func() error {
+			return deleteChild(log, client, childFinalizer, obj, desiredObj)
+		})
+	}
+}
+
+// deleteChild deletes obj if it isn't desired, which is nil then.
+func deleteChild(log logr.Logger, client *dynamicclientset.ResourceClient, childFinalizer *ChildFinalizer, obj, desired *unstructured.Unstructured) error {
+	if childFinalizer.ownsFinalizer(obj) {
+		// Children are only managed after the sync hook has been called
+		// with them, so release the child once it's pending deletion, or
+		// if this kind no longer calls for the finalizer at all.
+		if obj.GetDeletionTimestamp() != nil || !childFinalizer.IsEnabled(client.Group, client.Kind) {
+			log.Info("Releasing finalizer", "child", klog.KObj(obj), "finalizer", childFinalizer.Name)
+			if _, err := childFinalizer.RemoveFinalizer(client, obj); err != nil {
+				return fmt.Errorf("can't remove finalizer from %v: %v", describeObject(obj), err)
 			}
 		}
 	}
-	return utilerrors.NewAggregate(errs)
+	if obj.GetDeletionTimestamp() != nil {
+		// Skip objects that are already pending deletion.
+		return nil
+	}
+	if desired != nil {
+		return nil
+	}
+	// This observed object wasn't listed as desired.
+	log.Info("Deleting child", "child", klog.KObj(obj))
+	uid := obj.GetUID()
+	// Explicitly request deletion propagation, which is what users expect,
+	// since some objects default to orphaning for backwards compatibility.
+	propagation := metav1.DeletePropagationBackground
+	err := client.Namespace(obj.GetNamespace()).Delete(obj.GetName(), &metav1.DeleteOptions{
+		Preconditions:     &metav1.Preconditions{UID: &uid},
+		PropagationPolicy: &propagation,
+	})
+	if err != nil {
+		return fmt.Errorf("can't delete %v: %v", describeObject(obj), err)
+	}
+	return nil
 }
 
-func updateChildren(client *dynamicclientset.ResourceClient, updateStrategy ChildUpdateStrategy, parent *unstructured.Unstructured, observed, desired map[string]*unstructured.Unstructured) error {
-	var errs []error
+// updateChildren creates the desired children that don't exist yet, and
+// updates the observed ones according to the update strategy, through
+// workers.
+func updateChildren(log logr.Logger, workers *childWorkers, client *dynamicclientset.ResourceClient, eventRecorder record.EventRecorder, applyMode v1alpha1.ChildApplyMode, updateStrategy ChildUpdateStrategy, childFinalizer *ChildFinalizer, ownerRefs ChildOwnerReferences, parent *unstructured.Unstructured, observed, desired map[string]*unstructured.Unstructured, writes *childWrites) {
+	addFinalizer := childFinalizer.IsEnabled(client.Group, client.Kind)
+	kindOwnerRef := ownerRefs.MakeOwnerRef(parent, client.Group, client.Kind)
 	for name, obj := range desired {
-		ns := obj.GetNamespace()
-		if ns == "" {
-			ns = parent.GetNamespace()
-		}
-		if oldObj := observed[name]; oldObj != nil {
-			// Update
-			newObj, err := ApplyUpdate(oldObj, obj)
-			if err != nil {
-				errs = append(errs, err)
-				continue
-			}
+		obj, oldObj := obj, observed[name]
+		workers.run(func() error {
+			return updateOrCreateChild(log, client, eventRecorder, applyMode, updateStrategy, childFinalizer, addFinalizer, kindOwnerRef, parent, oldObj, obj, writes)
+		})
+	}
+}
 
-			// Attempt an update, if the 3-way merge resulted in any changes.
-			if reflect.DeepEqual(newObj.UnstructuredContent(), oldObj.UnstructuredContent()) {
-				// Nothing changed.
-				continue
-			}
-			if klog.V(5).Enabled() {
-				klog.InfoS("Reflect diff: a=observed, b=desired", "diff", diff.ObjectReflectDiff(oldObj.UnstructuredContent(), newObj.UnstructuredContent()))
+// updateOrCreateChild updates oldObj to obj, or creates obj if oldObj is nil.
+func updateOrCreateChild(log logr.Logger, client *dynamicclientset.ResourceClient, eventRecorder record.EventRecorder, applyMode v1alpha1.ChildApplyMode, updateStrategy ChildUpdateStrategy, childFinalizer *ChildFinalizer, addFinalizer bool, kindOwnerRef *metav1.OwnerReference, parent, oldObj, obj *unstructured.Unstructured, writes *childWrites) error {
+	ns := obj.GetNamespace()
+	if ns == "" {
+		ns = parent.GetNamespace()
+	}
+	ownerRef := kindOwnerRef
+	if IsCrossNamespace(parent, client.Namespaced, ns) {
+		// Owner references can't cross namespaces, so such children are
+		// tracked by labels only.
+		ownerRef = nil
+	}
+	if oldObj == nil {
+		// Create
+		log.Info("Creating", "child", klog.KObj(obj))
+		var previous *unstructured.Unstructured
+		if applyMode == v1alpha1.ChildApplyServerSide {
+			var err error
+			if previous, err = writes.previous(client.Namespace(ns), obj.GetName()); err != nil {
+				return err
 			}
+		}
+		created, err := createChild(client.Namespace(ns), applyMode, childFinalizer, addFinalizer, ownerRef, parent, obj, ns)
+		if err != nil {
+			return err
+		}
+		writes.add(client.Namespace(ns), previous, created)
+		return nil
+	}
 
-			// Leave it alone if it's pending deletion.
-			if oldObj.GetDeletionTimestamp() != nil {
-				klog.InfoS("Not updating", "parent", klog.KObj(parent), "child", klog.KObj(obj), "reason", "Pending deletion of child object")
-				continue
-			}
+	// Add our finalizer to existing children that don't have it yet,
+	// regardless of the update strategy.
+	if addFinalizer && oldObj.GetDeletionTimestamp() == nil && !childFinalizer.HasFinalizer(oldObj) {
+		updated, err := client.Namespace(ns).AddFinalizer(oldObj, childFinalizer.Name)
+		if err != nil {
+			return fmt.Errorf("can't add finalizer to %v: %v", describeObject(oldObj), err)
+		}
+		oldObj = updated
+	}
 
-			// Check the update strategy for this child kind.
-			switch method := updateStrategy.GetMethod(client.Group, client.Kind); method {
-			case v1alpha1.ChildUpdateOnDelete, "":
-				// This means we don't try to update anything unless it gets deleted
-				// by someone else (we won't delete it ourselves).
-				klog.V(5).InfoS("Not updating", "parent", klog.KObj(parent), "child", klog.KObj(obj), "reason", "OnDelete update strategy selected")
-				continue
-			case v1alpha1.ChildUpdateRecreate, v1alpha1.ChildUpdateRollingRecreate:
-				// Delete the object (now) and recreate it (on the next sync).
-				klog.InfoS("Deleting for update", "parent", klog.KObj(parent), "child", klog.KObj(obj), "reason", "Recreate update strategy selected")
-				uid := oldObj.GetUID()
-				// Explicitly request deletion propagation, which is what users expect,
-				// since some objects default to orphaning for backwards compatibility.
-				propagation := metav1.DeletePropagationBackground
-				err := client.Namespace(ns).Delete(obj.GetName(), &metav1.DeleteOptions{
-					Preconditions:     &metav1.Preconditions{UID: &uid},
-					PropagationPolicy: &propagation,
-				})
-				if err != nil {
-					errs = append(errs, err)
-					continue
-				}
-			case v1alpha1.ChildUpdateInPlace, v1alpha1.ChildUpdateRollingInPlace:
-				// Update the object in-place.
-				klog.InfoS("Updating", "parent", klog.KObj(parent), "child", klog.KObj(obj), "reason", "Recreate update strategy selected")
-				if _, err := client.Namespace(ns).Update(newObj, metav1.UpdateOptions{}); err != nil {
-					errs = append(errs, err)
-					continue
-				}
-			default:
-				errs = append(errs, fmt.Errorf("invalid update strategy for %v: unknown method %q", client.Kind, method))
-				continue
-			}
-		} else {
-			// Create
-			klog.InfoS("Creating", "parent", klog.KObj(parent), "child", klog.KObj(obj))
-
-			// The controller should return a partial object containing only the
-			// fields it cares about. We save this partial object so we can do
-			// a 3-way merge upon update, in the style of "kubectl apply".
-			//
-			// Make sure this happens before we add anything else to the object.
-			if err := dynamicapply.SetLastApplied(obj, obj.UnstructuredContent()); err != nil {
-				errs = append(errs, err)
-				continue
-			}
+	// Update
+	serverSide := applyMode == v1alpha1.ChildApplyServerSide
+	var newObj *unstructured.Unstructured
+	if serverSide {
+		if ServerSideUpToDate(oldObj, obj) {
+			// Nothing would change.
+			return nil
+		}
+	} else {
+		var err error
+		newObj, err = ApplyUpdate(oldObj, obj)
+		if err != nil {
+			return err
+		}
 
-			// We always claim everything we create.
-			controllerRef := MakeControllerRef(parent)
-			ownerRefs := obj.GetOwnerReferences()
-			ownerRefs = append(ownerRefs, *controllerRef)
-			obj.SetOwnerReferences(ownerRefs)
+		// Attempt an update, if the 3-way merge resulted in any changes.
+		if SemanticDeepEqual(newObj.UnstructuredContent(), oldObj.UnstructuredContent()) {
+			// Nothing changed.
+			return nil
+		}
+		if log.V(5).Enabled() {
+			log.V(5).Info("Reflect diff: a=observed, b=desired", "diff", diff.ObjectReflectDiff(oldObj.UnstructuredContent(), newObj.UnstructuredContent()))
+		}
+	}
 
-			if _, err := client.Namespace(ns).Create(obj, metav1.CreateOptions{}); err != nil {
-				errs = append(errs, err)
-				continue
-			}
+	// Leave it alone if it's pending deletion.
+	if oldObj.GetDeletionTimestamp() != nil {
+		log.Info("Not updating", "child", klog.KObj(obj), "reason", "Pending deletion of child object")
+		return nil
+	}
+
+	// Check the update strategy for this child kind.
+	method := updateStrategy.GetMethod(client.Group, client.Kind)
+
+	// Before touching the child, make sure the update would actually
+	// change anything once the API server applies defaults.
+	if !serverSide && method != v1alpha1.ChildUpdateOnDelete && method != "" && updateIsNoop(client, oldObj, newObj) {
+		log.V(5).Info("Not updating", "child", klog.KObj(obj), "reason", "Only differs in fields the API server defaults or normalizes")
+		return nil
+	}
+
+	switch method {
+	case v1alpha1.ChildUpdateOnDelete, "":
+		// This means we don't try to update anything unless it gets deleted
+		// by someone else (we won't delete it ourselves).
+		log.V(5).Info("Not updating", "child", klog.KObj(obj), "reason", "OnDelete update strategy selected")
+		return nil
+	case v1alpha1.ChildUpdateRecreate, v1alpha1.ChildUpdateRollingRecreate, v1alpha1.ChildUpdateRecreateWhenFinished:
+		if method == v1alpha1.ChildUpdateRecreateWhenFinished && GetJobState(oldObj) == JobRunning {
+			// It's recreated on the sync that follows its completion.
+			log.V(5).Info("Not updating", "child", klog.KObj(obj), "reason", "Waiting for child to finish")
+			return nil
+		}
+		// Delete the object (now) and recreate it (on the next sync).
+		log.Info("Deleting for update", "child", klog.KObj(obj), "reason", "Recreate update strategy selected")
+		reportApplyConflicts(eventRecorder, parent, oldObj, obj, serverSide)
+		uid := oldObj.GetUID()
+		// Explicitly request deletion propagation, which is what users expect,
+		// since some objects default to orphaning for backwards compatibility.
+		propagation := metav1.DeletePropagationBackground
+		err := client.Namespace(ns).Delete(obj.GetName(), &metav1.DeleteOptions{
+			Preconditions:     &metav1.Preconditions{UID: &uid},
+			PropagationPolicy: &propagation,
+		})
+		if err != nil {
+			return err
+		}
+		// Recreate it on a later sync, once it's gone from the cache.
+		log.Info("Waiting for child to be deleted before recreating it", "child", klog.KObj(obj))
+		return &pendingDeletionError{obj: obj}
+	case v1alpha1.ChildUpdateInPlace, v1alpha1.ChildUpdateRollingInPlace:
+		// Update the object in-place.
+		log.Info("Updating", "child", klog.KObj(obj), "reason", "Recreate update strategy selected")
+		reportApplyConflicts(eventRecorder, parent, oldObj, obj, serverSide)
+		var updated *unstructured.Unstructured
+		var err error
+		if serverSide {
+			updated, err = serverSideUpdate(log, client.Namespace(ns), oldObj, childApplyConfig(ownerRef, obj, ns, childFinalizer, addFinalizer), obj)
+		} else {
+			updated, err = updateChild(log, client.Namespace(ns), oldObj, newObj, obj)
 		}
+		if err != nil {
+			return err
+		}
+		writes.add(client.Namespace(ns), oldObj, updated)
+	default:
+		return fmt.Errorf("invalid update strategy for %v: unknown method %q", client.Kind, method)
 	}
-	return utilerrors.NewAggregate(errs)
+	return nil
+}
+
+// updateChild updates oldObj to newObj, the result of merging desired into it.
+// If the child was changed since we observed it, we merge desired into the
+// latest version and try again, instead of failing the whole sync.
+// It returns the updated child, or nil if there was nothing left to change.
+func updateChild(log logr.Logger, client *dynamicclientset.ResourceClient, oldObj, newObj, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	var result *unstructured.Unstructured
+	err := client.RetryOnConflict(oldObj, func(current *unstructured.Unstructured) error {
+		if current != oldObj {
+			var err error
+			if newObj, err = ApplyUpdate(current, desired); err != nil {
+				return err
+			}
+			if SemanticDeepEqual(newObj.UnstructuredContent(), current.UnstructuredContent()) {
+				// Someone else already made the changes we wanted.
+				result = nil
+				return nil
+			}
+			log.V(4).Info("Retrying update after conflict", "child", klog.KObj(current))
+		}
+		var err error
+		result, err = client.Update(newObj, metav1.UpdateOptions{FieldManager: FieldManager})
+		return err
+	})
+	return result, err
 }
@@ -0,0 +1,26 @@
+package common
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// NoPruneKinds holds the child kinds whose children are created and updated,
+// but never deleted when they're missing from the desired children.
+type NoPruneKinds map[schema.GroupKind]bool
+
+// Filter restricts the observed children of a parent, so that ManageChildren
+// doesn't delete the ones of kinds that aren't pruned.
+func (k NoPruneKinds) Filter(observed, desired ChildMap) ChildMap {
+	if len(k) == 0 {
+		return observed
+	}
+	return keepObserved(observed, desired, func(obj *unstructured.Unstructured) bool {
+		if !k[obj.GroupVersionKind().GroupKind()] {
+			return false
+		}
+		klog.V(4).InfoS("Not pruning child", "child_kind", obj.GetKind(), "child", klog.KObj(obj))
+		return true
+	})
+}
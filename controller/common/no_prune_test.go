@@ -0,0 +1,53 @@
+package common
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNoPruneKindsFilter(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	child := func(kind, name string, deleting bool) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetName(name)
+		if deleting {
+			now := metav1.NewTime(time.Now())
+			obj.SetDeletionTimestamp(&now)
+		}
+		return obj
+	}
+	observed := MakeChildMap(parent, []*unstructured.Unstructured{
+		child("ConfigMap", "desired", false),
+		child("ConfigMap", "stale", false),
+		child("ConfigMap", "deleting", true),
+		child("Secret", "stale", false),
+	})
+	desired := MakeChildMap(parent, []*unstructured.Unstructured{child("ConfigMap", "desired", false)})
+
+	noPrune := NoPruneKinds{schema.GroupKind{Kind: "ConfigMap"}: true}
+	var got []string
+	for _, obj := range noPrune.Filter(observed, desired).List() {
+		got = append(got, obj.GetKind()+"/"+obj.GetName())
+	}
+	sort.Strings(got)
+	want := []string{"ConfigMap/deleting", "ConfigMap/desired", "Secret/stale"}
+	if len(got) != len(want) {
+		t.Fatalf("Filter() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Filter() = %v, want %v", got, want)
+		}
+	}
+
+	if got := NoPruneKinds(nil).Filter(observed, desired); len(got.List()) != 4 {
+		t.Errorf("Filter() without kinds kept %v children, want 4", len(got.List()))
+	}
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var (
+	managedParents = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "metacontroller",
+			Name:           "parents",
+			Help:           "Number of parents managed by a controller.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller_kind", "controller"},
+	)
+	managedChildren = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "metacontroller",
+			Name:           "children",
+			Help:           "Number of children of all the parents of a controller, by child kind.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller_kind", "controller", "child_kind"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(managedParents, managedChildren)
+}
+
+// ObjectCounts tracks the number of parents of a controller, and of their
+// children by kind, as of their last sync. Children are only counted by kind
+// (as "<Kind>.<apiVersion>"), so the cardinality of the metrics is bounded by
+// the child resources of the controller.
+type ObjectCounts struct {
+	controllerKind, controller string
+
+	mutex sync.Mutex
+	// parents maps the queue key of each parent to its number of children
+	// by kind.
+	parents map[string]map[string]int
+	// children is the total number of children by kind.
+	children map[string]int
+}
+
+// NewObjectCounts returns an ObjectCounts for the controller with the given
+// kind and name.
+func NewObjectCounts(controllerKind, controller string) *ObjectCounts {
+	return &ObjectCounts{
+		controllerKind: controllerKind,
+		controller:     controller,
+		parents:        make(map[string]map[string]int),
+		children:       make(map[string]int),
+	}
+}
+
+// Observe records the children of the parent with the given queue key.
+func (c *ObjectCounts) Observe(key string, children ChildMap) {
+	counts := make(map[string]int, len(children))
+	for childKind, group := range children {
+		counts[childKind] = len(group)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.update(c.parents[key], counts)
+	c.parents[key] = counts
+	managedParents.WithLabelValues(c.controllerKind, c.controller).Set(float64(len(c.parents)))
+}
+
+// Forget stops counting the parent with the given queue key, because it's
+// gone or no longer managed by the controller.
+func (c *ObjectCounts) Forget(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	old, ok := c.parents[key]
+	if !ok {
+		return
+	}
+	c.update(old, nil)
+	delete(c.parents, key)
+	managedParents.WithLabelValues(c.controllerKind, c.controller).Set(float64(len(c.parents)))
+}
+
+// update replaces the children counts of a parent, before and after a sync,
+// in the totals.
+func (c *ObjectCounts) update(before, after map[string]int) {
+	changed := make(map[string]bool)
+	for childKind, count := range before {
+		c.children[childKind] -= count
+		changed[childKind] = true
+	}
+	for childKind, count := range after {
+		c.children[childKind] += count
+		changed[childKind] = true
+	}
+	for childKind := range changed {
+		managedChildren.WithLabelValues(c.controllerKind, c.controller, childKind).Set(float64(c.children[childKind]))
+	}
+}
+
+// Stop removes the metrics of the controller, when it stops.
+func (c *ObjectCounts) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for childKind := range c.children {
+		managedChildren.DeleteLabelValues(c.controllerKind, c.controller, childKind)
+	}
+	managedParents.DeleteLabelValues(c.controllerKind, c.controller)
+	c.parents = make(map[string]map[string]int)
+	c.children = make(map[string]int)
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestObjectCounts(t *testing.T) {
+	children := func(names ...string) ChildMap {
+		parent := &unstructured.Unstructured{}
+		m := make(ChildMap)
+		m.InitGroup("v1", "ConfigMap")
+		for _, name := range names {
+			child := &unstructured.Unstructured{}
+			child.SetAPIVersion("v1")
+			child.SetKind("ConfigMap")
+			child.SetName(name)
+			m.Insert(parent, child)
+		}
+		return m
+	}
+
+	c := NewObjectCounts("CompositeController", "test")
+	defer c.Stop()
+
+	c.Observe("ns/a", children("x", "y"))
+	c.Observe("ns/b", children("z"))
+	c.Observe("ns/a", children("x"))
+	if want := map[string]int{"ConfigMap.v1": 2}; !reflect.DeepEqual(c.children, want) {
+		t.Errorf("children = %v, want %v", c.children, want)
+	}
+	if got := len(c.parents); got != 2 {
+		t.Errorf("got %v parents, want 2", got)
+	}
+
+	c.Forget("ns/a")
+	c.Forget("ns/missing")
+	if want := map[string]int{"ConfigMap.v1": 1}; !reflect.DeepEqual(c.children, want) {
+		t.Errorf("children after Forget() = %v, want %v", c.children, want)
+	}
+	if got := len(c.parents); got != 1 {
+		t.Errorf("got %v parents after Forget(), want 1", got)
+	}
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/events"
+)
+
+var observedChildChanges = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Namespace:      "metacontroller",
+		Name:           "observed_child_changes_total",
+		Help:           "Number of changes to children that controllers in Observe mode would have made.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"controller_kind", "controller", "action"},
+)
+
+func init() {
+	legacyregistry.MustRegister(observedChildChanges)
+}
+
+// ValidateControllerMode returns an error if mode is not a known mode.
+func ValidateControllerMode(mode v1alpha1.ControllerMode) error {
+	switch mode {
+	case "", v1alpha1.ControllerModeEnforce, v1alpha1.ControllerModeObserve, v1alpha1.ControllerModeAudit:
+		return nil
+	}
+	return fmt.Errorf("invalid mode %q", mode)
+}
+
+// ReportChanges reports the changes a controller in Observe mode would have
+// made to the children of parent, instead of making them.
+// Changes that would leave a child alone are not reported.
+func ReportChanges(eventRecorder record.EventRecorder, controllerKind, controller string, parent *unstructured.Unstructured, changes []ChildChange) {
+	for _, change := range changes {
+		if change.Action == ChildActionNone {
+			continue
+		}
+		child := klog.KRef(change.Namespace, change.Name)
+		klog.V(4).InfoS("Observed child change", "parent", klog.KObj(parent), "action", change.Action, "child_kind", change.Kind, "child", child)
+		observedChildChanges.WithLabelValues(controllerKind, controller, string(change.Action)).Inc()
+		if eventRecorder != nil {
+			eventRecorder.Eventf(parent, v1.EventTypeNormal, events.ReasonObserved,
+				"Would %v %v %v", strings.ToLower(string(change.Action)), change.Kind, child)
+		}
+	}
+}
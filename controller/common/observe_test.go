@@ -0,0 +1,35 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReportChanges(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("ns")
+	parent.SetName("parent")
+
+	ReportChanges(recorder, "CompositeController", "test", parent, []ChildChange{
+		{Action: ChildActionCreate, Kind: "ConfigMap", Namespace: "ns", Name: "new"},
+		{Action: ChildActionNone, Kind: "ConfigMap", Namespace: "ns", Name: "held"},
+		{Action: ChildActionDelete, Kind: "Namespace", Name: "old"},
+	})
+	close(recorder.Events)
+
+	var got []string
+	for event := range recorder.Events {
+		got = append(got, event)
+	}
+	want := []string{
+		"Normal Observed Would create ConfigMap ns/new",
+		"Normal Observed Would delete Namespace old",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
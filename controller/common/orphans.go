@@ -0,0 +1,210 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+)
+
+var orphanedChildren = metrics.NewGaugeVec(
+	&metrics.GaugeOpts{
+		Namespace:      "metacontroller",
+		Name:           "orphaned_children",
+		Help:           "Number of children found by the last orphan audit whose parent no longer exists.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"controller_kind", "controller", "child_resource"},
+)
+
+func init() {
+	legacyregistry.MustRegister(orphanedChildren)
+}
+
+// OrphanAuditOptions configures the periodic audit of orphaned children.
+type OrphanAuditOptions struct {
+	// Interval is how often each controller looks for orphans among its
+	// children. Zero disables the audit.
+	Interval time.Duration
+	// Cleanup deletes the orphans found, instead of only reporting them.
+	Cleanup bool
+}
+
+var orphanAudits = struct {
+	mutex   sync.Mutex
+	options OrphanAuditOptions
+	// reports maps each controller, as "<kind>/<name>", to the orphans found
+	// by its last audit.
+	reports map[string][]Orphan
+}{reports: make(map[string][]Orphan)}
+
+// InitOrphanAudit configures the orphan audits of all controllers.
+func InitOrphanAudit(options OrphanAuditOptions) {
+	orphanAudits.mutex.Lock()
+	defer orphanAudits.mutex.Unlock()
+	orphanAudits.options = options
+}
+
+// Orphan is a child whose parent no longer exists.
+type Orphan struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	UID        types.UID `json:"uid"`
+	// Owner is the owner reference to the missing parent.
+	Owner metav1.OwnerReference `json:"owner"`
+	// Deleted is whether the audit deleted the orphan.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// OrphanAudit looks for orphans among the children of one controller.
+// Normally the garbage collector deletes children along with their parent,
+// but it can't when the owner reference doesn't block it, such as after a
+// parent was deleted with orphan propagation.
+type OrphanAudit struct {
+	ControllerKind, Controller string
+
+	DynClient      *dynamicclientset.Clientset
+	ChildInformers InformerMap
+
+	// ParentUIDs returns the UIDs of all the parents that exist.
+	ParentUIDs func() (map[types.UID]bool, error)
+	// ParentRef returns the reference from child to its parent, or nil if
+	// child doesn't belong to the controller.
+	ParentRef func(child *unstructured.Unstructured) *metav1.OwnerReference
+}
+
+// Run audits the children every interval until stopCh is closed, if the
+// audits are enabled. It must only be called once the informers of parents
+// and children are synced, or every child would look like an orphan.
+func (a *OrphanAudit) Run(stopCh <-chan struct{}) {
+	orphanAudits.mutex.Lock()
+	interval := orphanAudits.options.Interval
+	orphanAudits.mutex.Unlock()
+	if interval <= 0 {
+		return
+	}
+	defer a.forget()
+	wait.Until(a.audit, interval, stopCh)
+}
+
+func (a *OrphanAudit) audit() {
+	parents, err := a.ParentUIDs()
+	if err != nil {
+		klog.ErrorS(err, "Can't audit orphans", "controller_kind", a.ControllerKind, "controller", a.Controller)
+		return
+	}
+	orphanAudits.mutex.Lock()
+	cleanup := orphanAudits.options.Cleanup
+	orphanAudits.mutex.Unlock()
+
+	orphans := []Orphan{}
+	for gvr, informer := range a.ChildInformers {
+		children, err := informer.Lister().List(labels.Everything())
+		if err != nil {
+			klog.ErrorS(err, "Can't audit orphans", "controller_kind", a.ControllerKind, "controller", a.Controller)
+			continue
+		}
+		count := 0
+		for _, child := range children {
+			ref := a.ParentRef(child)
+			if ref == nil || parents[ref.UID] || child.GetDeletionTimestamp() != nil {
+				continue
+			}
+			count++
+			orphan := Orphan{
+				APIVersion: child.GetAPIVersion(),
+				Kind:       child.GetKind(),
+				Namespace:  child.GetNamespace(),
+				Name:       child.GetName(),
+				UID:        child.GetUID(),
+				Owner:      *ref,
+			}
+			klog.InfoS("Found orphaned child", "controller_kind", a.ControllerKind, "controller", a.Controller, "child", klog.KObj(child), "parent_kind", ref.Kind, "parent", ref.Name)
+			if cleanup {
+				orphan.Deleted = a.delete(child, ref)
+			}
+			orphans = append(orphans, orphan)
+		}
+		orphanedChildren.WithLabelValues(a.ControllerKind, a.Controller, gvr.Resource).Set(float64(count))
+	}
+
+	orphanAudits.mutex.Lock()
+	defer orphanAudits.mutex.Unlock()
+	orphanAudits.reports[a.ControllerKind+"/"+a.Controller] = orphans
+}
+
+// delete deletes child, after checking with the API server that the parent
+// ref points to is really gone, since the cache may lag behind.
+func (a *OrphanAudit) delete(child *unstructured.Unstructured, ref *metav1.OwnerReference) bool {
+	parentClient, err := a.DynClient.Kind(ref.APIVersion, ref.Kind)
+	if err != nil {
+		klog.ErrorS(err, "Can't delete orphaned child", "child", klog.KObj(child))
+		return false
+	}
+	parent, err := parentClient.Namespace(child.GetNamespace()).Get(ref.Name, metav1.GetOptions{})
+	if err == nil && parent.GetUID() == ref.UID {
+		return false
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		klog.ErrorS(err, "Can't delete orphaned child", "child", klog.KObj(child))
+		return false
+	}
+
+	client, err := a.DynClient.Kind(child.GetAPIVersion(), child.GetKind())
+	if err != nil {
+		klog.ErrorS(err, "Can't delete orphaned child", "child", klog.KObj(child))
+		return false
+	}
+	uid := child.GetUID()
+	propagation := metav1.DeletePropagationBackground
+	err = client.Namespace(child.GetNamespace()).Delete(child.GetName(), &metav1.DeleteOptions{
+		Preconditions:     &metav1.Preconditions{UID: &uid},
+		PropagationPolicy: &propagation,
+	})
+	if err != nil {
+		klog.ErrorS(err, "Can't delete orphaned child", "child", klog.KObj(child))
+		return false
+	}
+	klog.InfoS("Deleted orphaned child", "controller_kind", a.ControllerKind, "controller", a.Controller, "child", klog.KObj(child))
+	return true
+}
+
+// forget drops the report and metrics of a controller that's stopped.
+func (a *OrphanAudit) forget() {
+	for gvr := range a.ChildInformers {
+		orphanedChildren.DeleteLabelValues(a.ControllerKind, a.Controller, gvr.Resource)
+	}
+	orphanAudits.mutex.Lock()
+	defer orphanAudits.mutex.Unlock()
+	delete(orphanAudits.reports, a.ControllerKind+"/"+a.Controller)
+}
+
+// ServeOrphans serves the orphans found by the last audit of each controller,
+// as a JSON object keyed by "<controller kind>/<controller name>".
+func ServeOrphans(w http.ResponseWriter, r *http.Request) {
+	orphanAudits.mutex.Lock()
+	reports := make(map[string][]Orphan, len(orphanAudits.reports))
+	for key, orphans := range orphanAudits.reports {
+		reports[key] = orphans
+	}
+	orphanAudits.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(reports)
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// ChildOwnerReferences holds the owner reference settings of child kinds.
+// Kinds that aren't listed get a controller reference that blocks the
+// deletion of their parent, like built-in controllers set.
+type ChildOwnerReferences map[schema.GroupKind]*v1alpha1.ChildOwnerReference
+
+// Get returns the owner reference settings of the given child kind, or nil
+// for the defaults.
+func (r ChildOwnerReferences) Get(apiGroup, kind string) *v1alpha1.ChildOwnerReference {
+	return r[schema.GroupKind{Group: apiGroup, Kind: kind}]
+}
+
+// MakeOwnerRef returns the owner reference to set on children of the given
+// kind, or nil if they're tracked by labels only.
+func (r ChildOwnerReferences) MakeOwnerRef(parent *unstructured.Unstructured, apiGroup, kind string) *metav1.OwnerReference {
+	config := r.Get(apiGroup, kind)
+	if config == nil {
+		return MakeControllerRef(parent)
+	}
+	if config.LabelsOnly {
+		return nil
+	}
+	ownerRef := MakeControllerRef(parent)
+	if config.BlockOwnerDeletion != nil {
+		ownerRef.BlockOwnerDeletion = pointer.BoolPtr(*config.BlockOwnerDeletion)
+	}
+	if config.Controller != nil {
+		ownerRef.Controller = pointer.BoolPtr(*config.Controller)
+	}
+	return ownerRef
+}
+
+// IsController returns whether children of the given kind are owned through
+// a controller reference, so they can be looked up by the UID of their parent.
+func (r ChildOwnerReferences) IsController(apiGroup, kind string) bool {
+	config := r.Get(apiGroup, kind)
+	return config == nil || (!config.LabelsOnly && (config.Controller == nil || *config.Controller))
+}
+
+// IsCrossNamespace returns whether a child in namespace, of a namespaced kind
+// if namespaced is set, is in another namespace than its namespaced parent,
+// so it can't have an owner reference to it.
+func IsCrossNamespace(parent metav1.Object, namespaced bool, namespace string) bool {
+	return namespaced && parent.GetNamespace() != "" && namespace != "" && namespace != parent.GetNamespace()
+}
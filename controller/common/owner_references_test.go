@@ -0,0 +1,96 @@
+package common
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+)
+
+func TestMakeOwnerRef(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetAPIVersion("example.com/v1")
+	parent.SetKind("Parent")
+	parent.SetName("test")
+	parent.SetUID("uid")
+
+	ownerRefs := ChildOwnerReferences{
+		{Group: "", Kind: "Service"}:   {BlockOwnerDeletion: pointer.BoolPtr(false)},
+		{Group: "", Kind: "Secret"}:    {Controller: pointer.BoolPtr(false)},
+		{Group: "", Kind: "ConfigMap"}: {LabelsOnly: true},
+	}
+	table := []struct {
+		kind                                   string
+		wantNil                                bool
+		wantController, wantBlockOwnerDeletion bool
+	}{
+		{kind: "Pod", wantController: true, wantBlockOwnerDeletion: true},
+		{kind: "Service", wantController: true, wantBlockOwnerDeletion: false},
+		{kind: "Secret", wantController: false, wantBlockOwnerDeletion: true},
+		{kind: "ConfigMap", wantNil: true},
+	}
+
+	for _, tc := range table {
+		got := ownerRefs.MakeOwnerRef(parent, "", tc.kind)
+		if tc.wantNil {
+			if got != nil {
+				t.Errorf("%v: MakeOwnerRef() = %v, want nil", tc.kind, got)
+			}
+			continue
+		}
+		if got == nil || got.UID != "uid" {
+			t.Errorf("%v: MakeOwnerRef() = %v, want a reference to the parent", tc.kind, got)
+			continue
+		}
+		if *got.Controller != tc.wantController || *got.BlockOwnerDeletion != tc.wantBlockOwnerDeletion {
+			t.Errorf("%v: MakeOwnerRef() = controller %v, blockOwnerDeletion %v; want %v, %v", tc.kind, *got.Controller, *got.BlockOwnerDeletion, tc.wantController, tc.wantBlockOwnerDeletion)
+		}
+	}
+
+	// The defaults apply with no settings at all.
+	if got := ChildOwnerReferences(nil).MakeOwnerRef(parent, "", "Pod"); got == nil || !*got.Controller {
+		t.Errorf("MakeOwnerRef() with no settings = %v, want a controller reference", got)
+	}
+}
+
+func TestIsController(t *testing.T) {
+	ownerRefs := ChildOwnerReferences{
+		{Group: "", Kind: "Service"}:   {BlockOwnerDeletion: pointer.BoolPtr(false)},
+		{Group: "", Kind: "Secret"}:    {Controller: pointer.BoolPtr(false)},
+		{Group: "", Kind: "ConfigMap"}: {LabelsOnly: true},
+	}
+	table := map[string]bool{
+		"Pod":       true,
+		"Service":   true,
+		"Secret":    false,
+		"ConfigMap": false,
+	}
+	for kind, want := range table {
+		if got := ownerRefs.IsController("", kind); got != want {
+			t.Errorf("IsController(%q) = %v, want %v", kind, got, want)
+		}
+	}
+}
+
+func TestIsCrossNamespace(t *testing.T) {
+	table := []struct {
+		name                       string
+		parentNamespace, namespace string
+		namespaced                 bool
+		want                       bool
+	}{
+		{name: "same namespace", parentNamespace: "ns", namespace: "ns", namespaced: true},
+		{name: "other namespace", parentNamespace: "ns", namespace: "other", namespaced: true, want: true},
+		{name: "default namespace", parentNamespace: "ns", namespaced: true},
+		{name: "cluster-scoped parent", namespace: "other", namespaced: true},
+		{name: "cluster-scoped child", parentNamespace: "ns"},
+	}
+
+	for _, tc := range table {
+		parent := &unstructured.Unstructured{}
+		parent.SetNamespace(tc.parentNamespace)
+		if got := IsCrossNamespace(parent, tc.namespaced, tc.namespace); got != tc.want {
+			t.Errorf("%v: IsCrossNamespace() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+)
+
+// PatchedChild is a patch a sync hook returns for an existing child instead
+// of the whole child, when it only cares about a few of its fields. The
+// fields the patch doesn't touch are left strictly alone, unlike those a
+// desired child leaves out, which the 3-way merge may still remove.
+type PatchedChild struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Namespace defaults to the namespace of the parent.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Patch is a JSON Patch if it's an array, and a JSON merge patch if it's
+	// an object.
+	Patch json.RawMessage `json:"patch"`
+}
+
+// ChildPatchPlan holds the patches that change the children they target.
+type ChildPatchPlan []childPatchOp
+
+type childPatchOp struct {
+	child     *unstructured.Unstructured
+	patchType types.PatchType
+	data      []byte
+}
+
+// PlanChildPatches checks that each patch targets an observed child that
+// isn't also desired, and returns the observed children without the patched
+// ones, so ManageChildren neither updates nor deletes them, along with the
+// patches that change their child.
+func PlanChildPatches(parent *unstructured.Unstructured, observed, desired ChildMap, patches []PatchedChild) (ChildMap, ChildPatchPlan, error) {
+	if len(patches) == 0 {
+		return observed, nil, nil
+	}
+	patched := make(map[string]map[string]bool)
+	var plan ChildPatchPlan
+	for i, p := range patches {
+		key := childMapKey(p.APIVersion, p.Kind)
+		target := &unstructured.Unstructured{}
+		target.SetNamespace(p.Namespace)
+		target.SetName(p.Name)
+		name := relativeName(parent, target)
+		if patched[key][name] {
+			return nil, nil, Permanent(fmt.Errorf("patches[%v]: %v %v is patched more than once", i, p.Kind, name))
+		}
+		if desired[key][name] != nil {
+			return nil, nil, Permanent(fmt.Errorf("patches[%v]: %v %v is also a desired child", i, p.Kind, name))
+		}
+		child := observed[key][name]
+		if child == nil {
+			return nil, nil, Permanent(fmt.Errorf("patches[%v]: %v %v isn't an observed child", i, p.Kind, name))
+		}
+		op, changed, err := planChildPatch(child, p.Patch)
+		if err != nil {
+			return nil, nil, Permanent(fmt.Errorf("patches[%v]: %v", i, err))
+		}
+		if patched[key] == nil {
+			patched[key] = make(map[string]bool)
+		}
+		patched[key][name] = true
+		if changed {
+			plan = append(plan, op)
+		}
+	}
+
+	kept := make(ChildMap, len(observed))
+	for key, objects := range observed {
+		kept[key] = make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if !patched[key][name] {
+				kept[key][name] = obj
+			}
+		}
+	}
+	return kept, plan, nil
+}
+
+// planChildPatch applies data to a copy of child, to tell whether it would
+// change anything and to reject patches of its identity.
+func planChildPatch(child *unstructured.Unstructured, data []byte) (childPatchOp, bool, error) {
+	op := childPatchOp{child: child, data: data}
+	orig, err := json.Marshal(child.UnstructuredContent())
+	if err != nil {
+		return op, false, err
+	}
+	var result []byte
+	switch trimmed := bytes.TrimSpace(data); {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		op.patchType = types.JSONPatchType
+		patch, err := jsonpatch.DecodePatch(trimmed)
+		if err != nil {
+			return op, false, fmt.Errorf("invalid JSON Patch: %v", err)
+		}
+		result, err = patch.Apply(orig)
+		if err != nil {
+			return op, false, fmt.Errorf("can't apply JSON Patch to %v: %v", describeObject(child), err)
+		}
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		op.patchType = types.MergePatchType
+		result, err = jsonpatch.MergePatch(orig, trimmed)
+		if err != nil {
+			return op, false, fmt.Errorf("can't apply merge patch to %v: %v", describeObject(child), err)
+		}
+	default:
+		return op, false, fmt.Errorf("patch must be a JSON Patch array or a merge patch object")
+	}
+
+	// Decode both sides the same way, so numbers compare equal.
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(orig, &before); err != nil {
+		return op, false, err
+	}
+	if err := json.Unmarshal(result, &after); err != nil {
+		return op, false, err
+	}
+	patchedObj := &unstructured.Unstructured{Object: after}
+	if patchedObj.GetAPIVersion() != child.GetAPIVersion() || patchedObj.GetKind() != child.GetKind() ||
+		patchedObj.GetNamespace() != child.GetNamespace() || patchedObj.GetName() != child.GetName() {
+		return op, false, fmt.Errorf("patch can't change the apiVersion, kind, namespace or name of %v", describeObject(child))
+	}
+	return op, !reflect.DeepEqual(before, after), nil
+}
+
+// Send sends the patches to the API server. It keeps going after a patch
+// fails, and returns all the errors.
+func (plan ChildPatchPlan) Send(log logr.Logger, dynClient *dynamicclientset.Clientset) error {
+	var errs []error
+	for _, op := range plan {
+		client, err := dynClient.Kind(op.child.GetAPIVersion(), op.child.GetKind())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Info("Patching", "child", klog.KObj(op.child), "patch_type", op.patchType)
+		_, err = client.Namespace(op.child.GetNamespace()).Patch(op.child.GetName(), op.patchType, op.data, metav1.PatchOptions{FieldManager: FieldManager})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't patch %v: %v", describeObject(op.child), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPlanChildPatches(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("ns")
+	parent.SetName("parent")
+	child := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("ns")
+		obj.SetName(name)
+		unstructured.SetNestedField(obj.Object, "1", "data", "value")
+		return obj
+	}
+	patch := func(name, data string) PatchedChild {
+		return PatchedChild{APIVersion: "v1", Kind: "ConfigMap", Name: name, Patch: json.RawMessage(data)}
+	}
+
+	table := []struct {
+		name         string
+		desired      []*unstructured.Unstructured
+		patches      []PatchedChild
+		wantObserved []string
+		wantPatched  []types.PatchType
+		wantErr      bool
+	}{
+		{
+			name:         "no patches",
+			wantObserved: []string{"a", "b"},
+		},
+		{
+			name:         "merge patch",
+			patches:      []PatchedChild{patch("a", `{"data": {"value": "2"}}`)},
+			wantObserved: []string{"b"},
+			wantPatched:  []types.PatchType{types.MergePatchType},
+		},
+		{
+			name:         "JSON patch",
+			patches:      []PatchedChild{patch("a", `[{"op": "add", "path": "/data/other", "value": "x"}]`)},
+			wantObserved: []string{"b"},
+			wantPatched:  []types.PatchType{types.JSONPatchType},
+		},
+		{
+			name:         "no-op patch isn't sent",
+			patches:      []PatchedChild{patch("a", `{"data": {"value": "1"}}`)},
+			wantObserved: []string{"b"},
+		},
+		{
+			name:    "child isn't observed",
+			patches: []PatchedChild{patch("c", `{"data": {"value": "2"}}`)},
+			wantErr: true,
+		},
+		{
+			name:    "child is also desired",
+			desired: []*unstructured.Unstructured{child("a")},
+			patches: []PatchedChild{patch("a", `{"data": {"value": "2"}}`)},
+			wantErr: true,
+		},
+		{
+			name:    "child is patched twice",
+			patches: []PatchedChild{patch("a", `{"data": {"value": "2"}}`), patch("a", `{"data": {"value": "3"}}`)},
+			wantErr: true,
+		},
+		{
+			name:    "patch renames child",
+			patches: []PatchedChild{patch("a", `{"metadata": {"name": "c"}}`)},
+			wantErr: true,
+		},
+		{
+			name:    "patch isn't an array or object",
+			patches: []PatchedChild{patch("a", `"value"`)},
+			wantErr: true,
+		},
+		{
+			name:    "JSON patch fails",
+			patches: []PatchedChild{patch("a", `[{"op": "test", "path": "/data/value", "value": "2"}]`)},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			observed := MakeChildMap(parent, []*unstructured.Unstructured{child("a"), child("b")})
+			desired := MakeChildMap(parent, tc.desired)
+			kept, plan, err := PlanChildPatches(parent, observed, desired, tc.patches)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("PlanChildPatches() succeeded, want error")
+				}
+				if !IsPermanent(err) {
+					t.Errorf("PlanChildPatches() error %v isn't permanent", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PlanChildPatches() error: %v", err)
+			}
+			gotObserved := []string{}
+			for _, obj := range kept.List() {
+				gotObserved = append(gotObserved, obj.GetName())
+			}
+			sort.Strings(gotObserved)
+			if !reflect.DeepEqual(gotObserved, tc.wantObserved) {
+				t.Errorf("observed = %v, want %v", gotObserved, tc.wantObserved)
+			}
+			var gotPatched []types.PatchType
+			for _, op := range plan {
+				gotPatched = append(gotPatched, op.patchType)
+			}
+			if !reflect.DeepEqual(gotPatched, tc.wantPatched) {
+				t.Errorf("patch types = %v, want %v", gotPatched, tc.wantPatched)
+			}
+		})
+	}
+}
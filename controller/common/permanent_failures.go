@@ -0,0 +1,108 @@
+package common
+
+import (
+	"errors"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	"metacontroller.io/hooks"
+)
+
+// SyncFailedCondition is the type of the condition set in the status of a
+// parent whose sync failed permanently.
+const SyncFailedCondition = "SyncFailed"
+
+// PermanentError is an error that retrying the sync can't fix, such as an
+// invalid parent spec.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as an error that retrying the sync can't fix.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent returns whether err can't be fixed by retrying the sync,
+// because it's a PermanentError or a hook said so.
+func IsPermanent(err error) bool {
+	var permanentErr *PermanentError
+	if errors.As(err, &permanentErr) {
+		return true
+	}
+	hookErr := hooks.AsError(err)
+	return hookErr != nil && !hookErr.IsRetryable()
+}
+
+// FailedPermanently records that the sync of parent, with the given queue
+// key, failed permanently, so it's not synced again until its generation
+// changes.
+func (r *SyncRetries) FailedPermanently(key string, parent *unstructured.Unstructured) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.permanent[key] = parent.GetGeneration()
+}
+
+// Abandoned returns whether the sync of parent, with the given queue key,
+// failed permanently, and neither its generation changed since nor is it
+// being deleted.
+func (r *SyncRetries) Abandoned(key string, parent *unstructured.Unstructured) bool {
+	if parent.GetDeletionTimestamp() != nil {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	generation, ok := r.permanent[key]
+	return ok && generation == parent.GetGeneration()
+}
+
+// SetSyncFailedCondition sets the SyncFailed condition in the status of
+// parent, for a sync that failed permanently with syncErr. It does nothing
+// if the parent resource has no status subresource, since status updates
+// would change its generation, and resume its syncs.
+// The condition goes away with the rest of the status on the next successful
+// sync.
+func SetSyncFailedCondition(parentClient *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, syncErr error) error {
+	if !parentClient.HasSubresource("status") {
+		return nil
+	}
+	reason := "PermanentError"
+	if hookErr := hooks.AsError(syncErr); hookErr != nil && hookErr.Code != "" {
+		reason = hookErr.Code
+	}
+	condition := map[string]interface{}{
+		"type":               SyncFailedCondition,
+		"status":             "True",
+		"reason":             reason,
+		"message":            syncErr.Error(),
+		"observedGeneration": parent.GetGeneration(),
+		"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+	}
+	_, err := parentClient.Namespace(parent.GetNamespace()).AtomicStatusUpdate(parent, func(obj *unstructured.Unstructured) bool {
+		conditions, _, _ := unstructured.NestedSlice(obj.UnstructuredContent(), "status", "conditions")
+		updated := make([]interface{}, 0, len(conditions)+1)
+		for _, c := range conditions {
+			if c, ok := c.(map[string]interface{}); ok && c["type"] == SyncFailedCondition {
+				if c["reason"] == condition["reason"] && c["message"] == condition["message"] && c["observedGeneration"] == condition["observedGeneration"] {
+					// Nothing to do.
+					return false
+				}
+				continue
+			}
+			updated = append(updated, c)
+		}
+		updated = append(updated, condition)
+		return unstructured.SetNestedSlice(obj.UnstructuredContent(), updated, "status", "conditions") == nil
+	})
+	return err
+}
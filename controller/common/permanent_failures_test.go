@@ -0,0 +1,86 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/hooks"
+)
+
+func TestSyncRetriesAbandoned(t *testing.T) {
+	retries := NewSyncRetries("CompositeController", "test")
+	defer retries.Stop()
+
+	parent := &unstructured.Unstructured{}
+	parent.SetGeneration(3)
+	if retries.Abandoned("ns/a", parent) {
+		t.Errorf("Abandoned() = true before any failure")
+	}
+	retries.Failed("ns/a")
+	retries.FailedPermanently("ns/a", parent)
+	if !retries.Abandoned("ns/a", parent) {
+		t.Errorf("Abandoned() = false after a permanent failure")
+	}
+
+	deleting := parent.DeepCopy()
+	now := metav1.Now()
+	deleting.SetDeletionTimestamp(&now)
+	if retries.Abandoned("ns/a", deleting) {
+		t.Errorf("Abandoned() = true for a parent being deleted")
+	}
+	changed := parent.DeepCopy()
+	changed.SetGeneration(4)
+	if retries.Abandoned("ns/a", changed) {
+		t.Errorf("Abandoned() = true after the generation changed")
+	}
+
+	retries.Succeeded("ns/a")
+	if retries.Abandoned("ns/a", parent) {
+		t.Errorf("Abandoned() = true after a successful sync")
+	}
+}
+
+func TestSyncRetryDelay(t *testing.T) {
+	retryable, permanent := true, false
+	backoff := 5 * time.Second
+	table := []struct {
+		name      string
+		err       error
+		wantDelay time.Duration
+		wantRetry bool
+	}{
+		{
+			name:      "plain error",
+			err:       fmt.Errorf("oops"),
+			wantDelay: backoff,
+			wantRetry: true,
+		},
+		{
+			name: "permanent error",
+			err:  fmt.Errorf("invalid parent: %w", Permanent(fmt.Errorf("bad selector"))),
+		},
+		{
+			name: "non-retryable hook error",
+			err:  fmt.Errorf("sync hook failed: %w", &hooks.Error{Message: "bad spec", Retryable: &permanent}),
+		},
+		{
+			name:      "hook error with requeue",
+			err:       fmt.Errorf("sync hook failed: %w", &hooks.Error{Message: "later", Retryable: &retryable, RequeueAfterSeconds: 60}),
+			wantDelay: time.Minute,
+			wantRetry: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			delay, retry := SyncRetryDelay(tc.err, backoff)
+			if delay != tc.wantDelay || retry != tc.wantRetry {
+				t.Errorf("SyncRetryDelay() = %v, %v, want %v, %v", delay, retry, tc.wantDelay, tc.wantRetry)
+			}
+		})
+	}
+}
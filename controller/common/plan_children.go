@@ -0,0 +1,164 @@
+package common
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicapply "metacontroller.io/dynamic/apply"
+)
+
+// ChildAction is what ManageChildren would do to a child.
+type ChildAction string
+
+const (
+	ChildActionCreate   ChildAction = "Create"
+	ChildActionUpdate   ChildAction = "Update"
+	ChildActionRecreate ChildAction = "Recreate"
+	ChildActionDelete   ChildAction = "Delete"
+	// ChildActionNone means the child differs from the desired state,
+	// but it's left alone, e.g. because of the update strategy.
+	ChildActionNone ChildAction = "None"
+)
+
+// ChildChange describes a change ManageChildren would make to a child.
+type ChildChange struct {
+	Action     ChildAction `json:"action"`
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Name       string      `json:"name"`
+	Reason     string      `json:"reason,omitempty"`
+	// Diff is a human-readable diff between the observed child and Object.
+	Diff string `json:"diff,omitempty"`
+	// Object is what would be sent to the API server, if anything.
+	Object *unstructured.Unstructured `json:"object,omitempty"`
+}
+
+// PlanChildren returns the changes ManageChildren would make to go from the
+// observed to the desired children, without making any of them.
+// Children that are already up to date are left out.
+// Finalizers on children are not taken into account.
+func PlanChildren(applyMode v1alpha1.ChildApplyMode, updateStrategy ChildUpdateStrategy, ownerRefs ChildOwnerReferences, parent *unstructured.Unstructured, observedChildren, desiredChildren ChildMap) ([]ChildChange, error) {
+	var changes []ChildChange
+
+	// Delete observed, owned objects that are not desired.
+	for key, objects := range observedChildren {
+		desired := desiredChildren[key]
+		for name, obj := range objects {
+			if obj.GetDeletionTimestamp() != nil || desired[name] != nil {
+				continue
+			}
+			changes = append(changes, newChildChange(ChildActionDelete, obj, "Not desired anymore"))
+		}
+	}
+
+	// Create or update desired objects.
+	for key, objects := range desiredChildren {
+		observed := observedChildren[key]
+		apiVersion, kind := ParseChildMapKey(key)
+		apiGroup, _ := ParseAPIVersion(apiVersion)
+		ownerRef := ownerRefs.MakeOwnerRef(parent, apiGroup, kind)
+		for name, obj := range objects {
+			oldObj := observed[name]
+			if oldObj == nil {
+				var newObj *unstructured.Unstructured
+				if applyMode == v1alpha1.ChildApplyServerSide {
+					newObj = childApplyConfig(ownerRef, obj, parent.GetNamespace(), nil, false)
+				} else {
+					newObj = obj.DeepCopy()
+					if newObj.GetNamespace() == "" {
+						newObj.SetNamespace(parent.GetNamespace())
+					}
+					if err := dynamicapply.SetLastApplied(newObj, obj.UnstructuredContent()); err != nil {
+						return nil, err
+					}
+					if ownerRef != nil {
+						newObj.SetOwnerReferences(append(newObj.GetOwnerReferences(), *ownerRef))
+					}
+				}
+				change := newChildChange(ChildActionCreate, newObj, "")
+				change.Object = newObj
+				changes = append(changes, change)
+				continue
+			}
+
+			var newObj *unstructured.Unstructured
+			if applyMode == v1alpha1.ChildApplyServerSide {
+				if ServerSideUpToDate(oldObj, obj) {
+					// Nothing would change.
+					continue
+				}
+				// This is what we'd apply, rather than the resulting object.
+				newObj = childApplyConfig(ownerRef, obj, oldObj.GetNamespace(), nil, false)
+			} else {
+				var err error
+				newObj, err = ApplyUpdate(oldObj, obj)
+				if err != nil {
+					return nil, err
+				}
+				if SemanticDeepEqual(newObj.UnstructuredContent(), oldObj.UnstructuredContent()) {
+					// Nothing changed.
+					continue
+				}
+			}
+			change := newChildChange(ChildActionNone, oldObj, "")
+			change.Diff = diff.ObjectReflectDiff(oldObj.UnstructuredContent(), newObj.UnstructuredContent())
+
+			if oldObj.GetDeletionTimestamp() != nil {
+				change.Reason = "Pending deletion of child object"
+				changes = append(changes, change)
+				continue
+			}
+			switch method := updateStrategy.GetMethod(apiGroup, kind); method {
+			case v1alpha1.ChildUpdateOnDelete, "":
+				change.Reason = "OnDelete update strategy selected"
+			case v1alpha1.ChildUpdateRecreate, v1alpha1.ChildUpdateRollingRecreate:
+				change.Action = ChildActionRecreate
+				change.Reason = "Recreate update strategy selected"
+			case v1alpha1.ChildUpdateRecreateWhenFinished:
+				if GetJobState(oldObj) == JobRunning {
+					change.Reason = "Waiting for child to finish"
+					break
+				}
+				change.Action = ChildActionRecreate
+				change.Reason = "RecreateWhenFinished update strategy selected"
+			case v1alpha1.ChildUpdateInPlace, v1alpha1.ChildUpdateRollingInPlace:
+				change.Action = ChildActionUpdate
+				change.Reason = "InPlace update strategy selected"
+				change.Object = newObj
+			default:
+				change.Reason = "Unknown update strategy " + string(method)
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.APIVersion != b.APIVersion {
+			return a.APIVersion < b.APIVersion
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return changes, nil
+}
+
+func newChildChange(action ChildAction, obj *unstructured.Unstructured, reason string) ChildChange {
+	return ChildChange{
+		Action:     action,
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		Reason:     reason,
+	}
+}
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicapply "metacontroller.io/dynamic/apply"
+)
+
+type fakeUpdateStrategy map[string]v1alpha1.ChildUpdateMethod
+
+func (s fakeUpdateStrategy) GetMethod(apiGroup, kind string) v1alpha1.ChildUpdateMethod {
+	return s[kind]
+}
+
+func newPlanTestChild(kind, name, value string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"data":       map[string]interface{}{"key": value},
+	}}
+}
+
+// newPlanTestObservedChild returns a child as if it had been created by us.
+func newPlanTestObservedChild(t *testing.T, kind, name, value string) *unstructured.Unstructured {
+	obj := newPlanTestChild(kind, name, value)
+	if err := dynamicapply.SetLastApplied(obj, obj.DeepCopy().UnstructuredContent()); err != nil {
+		t.Fatalf("SetLastApplied() error: %v", err)
+	}
+	return obj
+}
+
+func TestPlanChildren(t *testing.T) {
+	parent := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Parent",
+		"metadata":   map[string]interface{}{"name": "parent", "namespace": "default", "uid": "parent-uid"},
+	}}
+	updateStrategy := fakeUpdateStrategy{
+		"ConfigMap": v1alpha1.ChildUpdateInPlace,
+		"Secret":    v1alpha1.ChildUpdateOnDelete,
+	}
+
+	observed := make(ChildMap)
+	observed.Insert(parent, newPlanTestObservedChild(t, "ConfigMap", "unchanged", "a"))
+	observed.Insert(parent, newPlanTestObservedChild(t, "ConfigMap", "changed", "a"))
+	observed.Insert(parent, newPlanTestObservedChild(t, "ConfigMap", "removed", "a"))
+	observed.Insert(parent, newPlanTestObservedChild(t, "Secret", "changed", "a"))
+
+	desired := make(ChildMap)
+	desired.Insert(parent, newPlanTestChild("ConfigMap", "unchanged", "a"))
+	desired.Insert(parent, newPlanTestChild("ConfigMap", "changed", "b"))
+	desired.Insert(parent, newPlanTestChild("ConfigMap", "added", "a"))
+	desired.Insert(parent, newPlanTestChild("Secret", "changed", "b"))
+
+	changes, err := PlanChildren(v1alpha1.ChildApplyThreeWayMerge, updateStrategy, nil, parent, observed, desired)
+	if err != nil {
+		t.Fatalf("PlanChildren() error: %v", err)
+	}
+
+	table := []struct {
+		kind, name string
+		action     ChildAction
+		hasObject  bool
+	}{
+		{kind: "ConfigMap", name: "added", action: ChildActionCreate, hasObject: true},
+		{kind: "ConfigMap", name: "changed", action: ChildActionUpdate, hasObject: true},
+		{kind: "ConfigMap", name: "removed", action: ChildActionDelete},
+		{kind: "Secret", name: "changed", action: ChildActionNone},
+	}
+	if len(changes) != len(table) {
+		t.Fatalf("PlanChildren() returned %v changes, want %v: %#v", len(changes), len(table), changes)
+	}
+	for i, tc := range table {
+		got := changes[i]
+		if got.Kind != tc.kind || got.Name != tc.name || got.Action != tc.action {
+			t.Errorf("change %v = %v %v %v, want %v %v %v", i, got.Action, got.Kind, got.Name, tc.action, tc.kind, tc.name)
+		}
+		if (got.Object != nil) != tc.hasObject {
+			t.Errorf("%v %v: unexpected object %#v", tc.kind, tc.name, got.Object)
+		}
+		if tc.action == ChildActionUpdate && got.Diff == "" {
+			t.Errorf("%v %v: expected a diff", tc.kind, tc.name)
+		}
+	}
+
+	created := changes[0].Object
+	if ownerRefs := created.GetOwnerReferences(); len(ownerRefs) != 1 || ownerRefs[0].UID != "parent-uid" {
+		t.Errorf("expected created child to be owned by the parent, got %#v", ownerRefs)
+	}
+	if _, ok := desired.FindGroupKindName("", "ConfigMap", "added").GetAnnotations()["metacontroller.k8s.io/last-applied-configuration"]; ok {
+		t.Errorf("PlanChildren() must not modify desired children")
+	}
+}
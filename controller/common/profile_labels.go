@@ -0,0 +1,33 @@
+package common
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// profileLabels returns a context with the pprof labels of the controller
+// with the given kind and name.
+func profileLabels(controllerKind, controller string) context.Context {
+	return pprof.WithLabels(context.Background(), pprof.Labels("controller_kind", controllerKind, "controller", controller))
+}
+
+// SetProfileLabels labels the current goroutine, and the goroutines it
+// starts from now on, with the controller of the given kind and name, so
+// CPU profiles can be broken down by controller. Sync workers call it before
+// they process their first parent.
+func SetProfileLabels(controllerKind, controller string) {
+	pprof.SetGoroutineLabels(profileLabels(controllerKind, controller))
+}
+
+// CallWithProfileLabels calls call with the current goroutine labeled with
+// the controller of the given kind and name, and the type of hook it calls
+// (e.g. "sync" or "finalize"), so the time spent on the hook's request and
+// response shows up by hook in CPU profiles. Afterwards, the goroutine is
+// left labeled with the controller only.
+func CallWithProfileLabels(controllerKind, controller, hook string, call func() error) error {
+	var err error
+	pprof.Do(profileLabels(controllerKind, controller), pprof.Labels("hook", hook), func(context.Context) {
+		err = call()
+	})
+	return err
+}
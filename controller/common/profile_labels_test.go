@@ -0,0 +1,31 @@
+package common
+
+import (
+	"fmt"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestProfileLabels(t *testing.T) {
+	ctx := profileLabels("CompositeController", "catset-controller")
+	for key, want := range map[string]string{"controller_kind": "CompositeController", "controller": "catset-controller"} {
+		if got, ok := pprof.Label(ctx, key); !ok || got != want {
+			t.Errorf("label %q = %q, %v; want %q", key, got, ok, want)
+		}
+	}
+}
+
+func TestCallWithProfileLabels(t *testing.T) {
+	want := fmt.Errorf("hook failed")
+	called := false
+	err := CallWithProfileLabels("CompositeController", "catset-controller", "sync", func() error {
+		called = true
+		return want
+	})
+	if !called {
+		t.Errorf("call wasn't called")
+	}
+	if err != want {
+		t.Errorf("err = %v; want %v", err, want)
+	}
+}
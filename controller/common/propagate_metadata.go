@@ -0,0 +1,73 @@
+package common
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// unpropagatedAnnotations are never copied from parents to children, even if
+// they match a pattern, since they describe the parent object itself.
+var unpropagatedAnnotations = []string{
+	"metacontroller.k8s.io/",
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// PropagateMetadata copies the labels and annotations of parent selected by
+// policy to every child, overriding the values set by hooks, so children
+// always carry the current values of the parent.
+func PropagateMetadata(policy *v1alpha1.MetadataPropagation, parent *unstructured.Unstructured, children []*unstructured.Unstructured) {
+	if policy == nil {
+		return
+	}
+	labels := selectMetadata(parent.GetLabels(), policy.Labels, nil)
+	annotations := selectMetadata(parent.GetAnnotations(), policy.Annotations, unpropagatedAnnotations)
+	for _, child := range children {
+		if len(labels) > 0 {
+			child.SetLabels(mergeMetadata(child.GetLabels(), labels))
+		}
+		if len(annotations) > 0 {
+			child.SetAnnotations(mergeMetadata(child.GetAnnotations(), annotations))
+		}
+	}
+}
+
+// selectMetadata returns the entries of metadata whose key matches one of the
+// patterns, and none of the excluded prefixes. A pattern ending in "*"
+// matches every key with that prefix, and other patterns match exactly.
+func selectMetadata(metadata map[string]string, patterns, excluded []string) map[string]string {
+	selected := make(map[string]string)
+	for key, value := range metadata {
+		if hasAnyPrefix(key, excluded) {
+			continue
+		}
+		for _, pattern := range patterns {
+			if key == pattern || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(key, strings.TrimSuffix(pattern, "*"))) {
+				selected[key] = value
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeMetadata(metadata, add map[string]string) map[string]string {
+	if metadata == nil {
+		metadata = make(map[string]string, len(add))
+	}
+	for key, value := range add {
+		metadata[key] = value
+	}
+	return metadata
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestPropagateMetadata(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetLabels(map[string]string{
+		"team":                      "storage",
+		"app.kubernetes.io/name":    "db",
+		"app.kubernetes.io/part-of": "shop",
+		"other":                     "ignored",
+	})
+	parent.SetAnnotations(map[string]string{
+		"cost-center": "42",
+		"metacontroller.k8s.io/last-applied-configuration": "{}",
+	})
+
+	child := &unstructured.Unstructured{}
+	child.SetLabels(map[string]string{"team": "hook", "tier": "backend"})
+	policy := &v1alpha1.MetadataPropagation{
+		Labels:      []string{"team", "app.kubernetes.io/*"},
+		Annotations: []string{"*"},
+	}
+	PropagateMetadata(policy, parent, []*unstructured.Unstructured{child})
+
+	wantLabels := map[string]string{
+		"team":                      "storage",
+		"tier":                      "backend",
+		"app.kubernetes.io/name":    "db",
+		"app.kubernetes.io/part-of": "shop",
+	}
+	if got := child.GetLabels(); !reflect.DeepEqual(got, wantLabels) {
+		t.Errorf("labels = %v, want %v", got, wantLabels)
+	}
+	wantAnnotations := map[string]string{"cost-center": "42"}
+	if got := child.GetAnnotations(); !reflect.DeepEqual(got, wantAnnotations) {
+		t.Errorf("annotations = %v, want %v", got, wantAnnotations)
+	}
+}
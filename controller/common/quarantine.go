@@ -0,0 +1,228 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/events"
+	"metacontroller.io/hooks"
+)
+
+var quarantinedParents = metrics.NewGaugeVec(
+	&metrics.GaugeOpts{
+		Namespace:      "metacontroller",
+		Name:           "quarantined_parents",
+		Help:           "Number of parents quarantined because their syncs keep crashing or timing out the hook.",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"controller_kind", "controller"},
+)
+
+func init() {
+	legacyregistry.MustRegister(quarantinedParents)
+}
+
+// QuarantineOptions configures the quarantine of parents whose syncs keep
+// crashing or timing out their hook.
+type QuarantineOptions struct {
+	// After is how many syncs of a parent in a row must crash or time out the
+	// hook, within Window, for it to be quarantined. Zero disables the
+	// quarantine.
+	After int
+	// Window is how long after the first crash of a streak the following
+	// ones still count towards After. Zero means they always count.
+	Window time.Duration
+	// Interval is how often the sync of a quarantined parent is retried.
+	Interval time.Duration
+}
+
+var quarantines = struct {
+	mutex   sync.Mutex
+	options QuarantineOptions
+	// retries maps each controller with quarantined parents, as
+	// "<kind>/<name>", to its SyncRetries.
+	retries map[string]*SyncRetries
+}{retries: make(map[string]*SyncRetries)}
+
+// InitQuarantine configures the quarantine of parents of all controllers.
+func InitQuarantine(options QuarantineOptions) {
+	quarantines.mutex.Lock()
+	defer quarantines.mutex.Unlock()
+	quarantines.options = options
+}
+
+// crashStreak is a run of consecutive syncs of a parent that crashed or
+// timed out the hook.
+type crashStreak struct {
+	count int
+	since time.Time
+}
+
+// quarantine is the state of a quarantined parent.
+type quarantine struct {
+	// generation is that of the parent when it was quarantined.
+	generation int64
+	since      time.Time
+	retryAt    time.Time
+	lastError  string
+}
+
+// Quarantine records that the sync of parent, with the given queue key,
+// failed with err. If the hook crashed or timed out often enough in a row
+// within the quarantine window, the parent is quarantined, and Quarantine returns how long to wait before
+// retrying its sync, and true.
+func (r *SyncRetries) Quarantine(key string, parent *unstructured.Unstructured, err error) (time.Duration, bool) {
+	quarantines.mutex.Lock()
+	options := quarantines.options
+	quarantines.mutex.Unlock()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if options.After <= 0 || !hooks.IsCrash(err) {
+		delete(r.crashes, key)
+		r.release(key)
+		return 0, false
+	}
+	now := time.Now()
+	streak, ok := r.crashes[key]
+	if !ok || (options.Window > 0 && now.Sub(streak.since) > options.Window) {
+		streak = &crashStreak{since: now}
+		r.crashes[key] = streak
+	}
+	streak.count++
+	if streak.count < options.After {
+		return 0, false
+	}
+
+	q, ok := r.quarantined[key]
+	if !ok {
+		klog.InfoS("Quarantining parent", "controller_kind", r.controllerKind, "controller", r.controller, "parent", klog.KObj(parent), "crashes", streak.count)
+		q = &quarantine{since: now}
+		r.quarantined[key] = q
+		quarantinedParents.WithLabelValues(r.controllerKind, r.controller).Set(float64(len(r.quarantined)))
+		quarantines.mutex.Lock()
+		quarantines.retries[r.controllerKind+"/"+r.controller] = r
+		quarantines.mutex.Unlock()
+	}
+	q.generation = parent.GetGeneration()
+	q.retryAt = now.Add(options.Interval)
+	q.lastError = err.Error()
+	return options.Interval, true
+}
+
+// Quarantined returns whether parent, with the given queue key, is
+// quarantined and must not be synced yet, and how long until its next retry.
+// A parent leaves the quarantine once a retry succeeds or fails differently,
+// its generation changes, or it's being deleted.
+func (r *SyncRetries) Quarantined(key string, parent *unstructured.Unstructured) (time.Duration, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	q, ok := r.quarantined[key]
+	if !ok {
+		return 0, false
+	}
+	if parent.GetDeletionTimestamp() != nil || parent.GetGeneration() != q.generation {
+		delete(r.crashes, key)
+		r.release(key)
+		return 0, false
+	}
+	wait := time.Until(q.retryAt)
+	return wait, wait > 0
+}
+
+// ReportQuarantine records a Warning event on parent saying it's quarantined,
+// and its sync is only retried every interval until one succeeds.
+func ReportQuarantine(eventRecorder record.EventRecorder, parent *unstructured.Unstructured, interval time.Duration) {
+	eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonQuarantined,
+		"Syncs keep crashing or timing out the hook, only retrying every %v until a retry succeeds or the spec changes", interval)
+}
+
+// release takes the parent with the given queue key out of quarantine, if
+// it's there. The caller must hold r.mutex.
+func (r *SyncRetries) release(key string) {
+	if _, ok := r.quarantined[key]; !ok {
+		return
+	}
+	delete(r.quarantined, key)
+	quarantinedParents.WithLabelValues(r.controllerKind, r.controller).Set(float64(len(r.quarantined)))
+}
+
+// forgetQuarantine drops all quarantined parents, when the controller stops.
+// The caller must hold r.mutex.
+func (r *SyncRetries) forgetQuarantine() {
+	r.crashes = make(map[string]*crashStreak)
+	r.quarantined = make(map[string]*quarantine)
+	quarantinedParents.DeleteLabelValues(r.controllerKind, r.controller)
+	quarantines.mutex.Lock()
+	defer quarantines.mutex.Unlock()
+	if quarantines.retries[r.controllerKind+"/"+r.controller] == r {
+		delete(quarantines.retries, r.controllerKind+"/"+r.controller)
+	}
+}
+
+// QuarantinedParent is a parent in quarantine, as served by ServeQuarantine.
+type QuarantinedParent struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	Since      time.Time `json:"since"`
+	NextRetry  time.Time `json:"nextRetry"`
+	LastError  string    `json:"lastError"`
+}
+
+// ServeQuarantine serves the quarantined parents of each controller, as a
+// JSON object keyed by "<controller kind>/<controller name>".
+func ServeQuarantine(w http.ResponseWriter, r *http.Request) {
+	quarantines.mutex.Lock()
+	retries := make(map[string]*SyncRetries, len(quarantines.retries))
+	for controller, controllerRetries := range quarantines.retries {
+		retries[controller] = controllerRetries
+	}
+	quarantines.mutex.Unlock()
+
+	reports := make(map[string][]QuarantinedParent, len(retries))
+	for controller, controllerRetries := range retries {
+		reports[controller] = controllerRetries.quarantinedParents()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(reports)
+}
+
+func (r *SyncRetries) quarantinedParents() []QuarantinedParent {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	parents := make([]QuarantinedParent, 0, len(r.quarantined))
+	for key, q := range r.quarantined {
+		apiVersion, kind, namespace, name, err := SplitParentQueueKey(key)
+		if err != nil {
+			continue
+		}
+		parents = append(parents, QuarantinedParent{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Namespace:  namespace,
+			Name:       name,
+			Since:      q.since,
+			NextRetry:  q.retryAt,
+			LastError:  q.lastError,
+		})
+	}
+	sort.Slice(parents, func(i, j int) bool {
+		return parents[i].Since.Before(parents[j].Since)
+	})
+	return parents
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/hooks"
+)
+
+func TestSyncRetriesQuarantine(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "panic: runtime error", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	var response map[string]interface{}
+	crashErr := hooks.Call(&v1alpha1.Hook{Webhook: &v1alpha1.Webhook{URL: &srv.URL}}, map[string]interface{}{}, &response)
+	if !hooks.IsCrash(crashErr) {
+		t.Fatalf("IsCrash(%v) = false, want true", crashErr)
+	}
+
+	InitQuarantine(QuarantineOptions{After: 3, Interval: time.Hour})
+	defer InitQuarantine(QuarantineOptions{})
+	retries := NewSyncRetries("CompositeController", "test")
+	defer retries.Stop()
+
+	key := "v1:Thing:ns:a"
+	parent := &unstructured.Unstructured{}
+	parent.SetGeneration(1)
+	for i := 1; i < 3; i++ {
+		if _, ok := retries.Quarantine(key, parent, crashErr); ok {
+			t.Fatalf("Quarantine() = true after %v crashes, want false", i)
+		}
+	}
+	if _, ok := retries.Quarantine(key, parent, fmt.Errorf("not found")); ok {
+		t.Fatalf("Quarantine() = true for an error that isn't a crash")
+	}
+	for i := 1; i < 3; i++ {
+		retries.Quarantine(key, parent, crashErr)
+	}
+	interval, ok := retries.Quarantine(key, parent, crashErr)
+	if !ok || interval != time.Hour {
+		t.Fatalf("Quarantine() = %v, %v after 3 crashes in a row, want %v, true", interval, ok, time.Hour)
+	}
+	if wait, ok := retries.Quarantined(key, parent); !ok || wait <= 0 || wait > time.Hour {
+		t.Errorf("Quarantined() = %v, %v, want a wait of up to %v, true", wait, ok, time.Hour)
+	}
+	if got := retries.quarantinedParents(); len(got) != 1 || got[0].Name != "a" || got[0].Namespace != "ns" {
+		t.Errorf("quarantinedParents() = %v, want ns/a", got)
+	}
+
+	changed := parent.DeepCopy()
+	changed.SetGeneration(2)
+	if _, ok := retries.Quarantined(key, changed); ok {
+		t.Errorf("Quarantined() = true after the generation changed")
+	}
+	if _, ok := retries.Quarantined(key, parent); ok {
+		t.Errorf("Quarantined() = true after the parent left the quarantine")
+	}
+}
+
+func TestSyncRetriesQuarantine_window(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "panic: runtime error", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	var response map[string]interface{}
+	crashErr := hooks.Call(&v1alpha1.Hook{Webhook: &v1alpha1.Webhook{URL: &srv.URL}}, map[string]interface{}{}, &response)
+
+	InitQuarantine(QuarantineOptions{After: 2, Interval: time.Hour, Window: time.Minute})
+	defer InitQuarantine(QuarantineOptions{})
+	retries := NewSyncRetries("CompositeController", "test")
+	defer retries.Stop()
+
+	key := "v1:Thing:ns:a"
+	parent := &unstructured.Unstructured{}
+	parent.SetGeneration(1)
+	if _, ok := retries.Quarantine(key, parent, crashErr); ok {
+		t.Fatalf("Quarantine() = true after 1 crash, want false")
+	}
+	// Age the streak past the window: the next crash starts a new one.
+	retries.crashes[key].since = time.Now().Add(-2 * time.Minute)
+	if _, ok := retries.Quarantine(key, parent, crashErr); ok {
+		t.Fatalf("Quarantine() = true for a crash outside the window, want false")
+	}
+	if _, ok := retries.Quarantine(key, parent, crashErr); !ok {
+		t.Fatalf("Quarantine() = false after 2 crashes within the window, want true")
+	}
+
+	// A successful retry releases the parent, without a spec change.
+	retries.Succeeded(key)
+	if _, ok := retries.Quarantined(key, parent); ok {
+		t.Errorf("Quarantined() = true after a retry succeeded")
+	}
+	if _, ok := retries.Quarantine(key, parent, crashErr); ok {
+		t.Errorf("Quarantine() = true after 1 crash following a success, want false")
+	}
+}
@@ -0,0 +1,180 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicobject "metacontroller.io/dynamic/object"
+)
+
+// ReadinessSummary is the aggregated readiness of all children of a parent.
+type ReadinessSummary struct {
+	// Ready is true if every child is ready.
+	Ready bool `json:"ready"`
+	// Total is the number of observed children.
+	Total int `json:"total"`
+	// ReadyCount is the number of children that are ready.
+	ReadyCount int `json:"readyCount"`
+	// NotReady lists the children that are not ready, in a stable order.
+	NotReady []NotReadyChild `json:"notReady,omitempty"`
+}
+
+// NotReadyChild identifies a child that is not ready and explains why.
+type NotReadyChild struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Reason     string `json:"reason"`
+}
+
+// SummarizeReadiness computes the readiness of every child in the map.
+// It returns nil if readiness aggregation is not enabled.
+func SummarizeReadiness(config *v1alpha1.ChildReadiness, children ChildMap) *ReadinessSummary {
+	if config == nil {
+		return nil
+	}
+	summary := &ReadinessSummary{}
+	for key, group := range children {
+		apiVersion, kind := ParseChildMapKey(key)
+		for name, child := range group {
+			summary.Total++
+			if ready, reason := ChildReady(child); !ready {
+				summary.NotReady = append(summary.NotReady, NotReadyChild{
+					APIVersion: apiVersion,
+					Kind:       kind,
+					Name:       name,
+					Reason:     reason,
+				})
+				continue
+			}
+			summary.ReadyCount++
+		}
+	}
+	summary.Ready = summary.ReadyCount == summary.Total
+	sortNotReady(summary.NotReady)
+	return summary
+}
+
+// sortNotReady sorts not ready children by apiVersion, kind and name.
+func sortNotReady(notReady []NotReadyChild) {
+	sort.Slice(notReady, func(i, j int) bool {
+		a, b := notReady[i], notReady[j]
+		if a.APIVersion != b.APIVersion {
+			return a.APIVersion < b.APIVersion
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+}
+
+// SetReadinessStatus writes the readiness summary into the given field of
+// the parent status. It does nothing if no status field was requested.
+func SetReadinessStatus(config *v1alpha1.ChildReadiness, status map[string]interface{}, summary *ReadinessSummary) {
+	if config == nil || config.StatusField == "" || summary == nil || status == nil {
+		return
+	}
+	notReady := make([]interface{}, 0, len(summary.NotReady))
+	for _, child := range summary.NotReady {
+		notReady = append(notReady, map[string]interface{}{
+			"apiVersion": child.APIVersion,
+			"kind":       child.Kind,
+			"name":       child.Name,
+			"reason":     child.Reason,
+		})
+	}
+	status[config.StatusField] = map[string]interface{}{
+		"ready":      summary.Ready,
+		"total":      int64(summary.Total),
+		"readyCount": int64(summary.ReadyCount),
+		"notReady":   notReady,
+	}
+}
+
+// ChildReady reports whether a child is ready, following the conventions of
+// the built-in workload kinds, unless it has a readiness gate. Objects of other kinds are ready if their
+// Ready condition is True, or if they don't report a Ready condition at all.
+// If the child is not ready, the reason explains why.
+func ChildReady(child *unstructured.Unstructured) (bool, string) {
+	if child.GetDeletionTimestamp() != nil {
+		return false, "pending deletion"
+	}
+	obj := child.UnstructuredContent()
+	// Status may be stale until the child's controller observes the latest spec.
+	if observedGeneration, found, _ := dynamicobject.GetObservedGeneration(obj); found && observedGeneration < child.GetGeneration() {
+		return false, "latest spec not observed yet"
+	}
+	// A readiness gate set by the hook replaces the conventions below.
+	if gate, ok := child.GetAnnotations()[ReadyWhenAnnotation]; ok {
+		return ReadyWhen(child, gate)
+	}
+
+	group, _ := ParseAPIVersion(child.GetAPIVersion())
+	switch {
+	case group == "apps" && child.GetKind() == "Deployment":
+		replicas := specReplicas(obj)
+		if updated := statusInt(obj, "updatedReplicas"); updated < replicas {
+			return false, fmt.Sprintf("%v of %v replicas updated", updated, replicas)
+		}
+		if available := statusInt(obj, "availableReplicas"); available < replicas {
+			return false, fmt.Sprintf("%v of %v replicas available", available, replicas)
+		}
+		return true, ""
+	case group == "apps" && (child.GetKind() == "StatefulSet" || child.GetKind() == "ReplicaSet"):
+		replicas := specReplicas(obj)
+		if ready := statusInt(obj, "readyReplicas"); ready < replicas {
+			return false, fmt.Sprintf("%v of %v replicas ready", ready, replicas)
+		}
+		return true, ""
+	case group == "apps" && child.GetKind() == "DaemonSet":
+		desired := statusInt(obj, "desiredNumberScheduled")
+		if ready := statusInt(obj, "numberReady"); ready < desired {
+			return false, fmt.Sprintf("%v of %v pods ready", ready, desired)
+		}
+		return true, ""
+	case group == "batch" && child.GetKind() == "Job":
+		return conditionTrue(obj, "Complete", true)
+	}
+	return conditionTrue(obj, "Ready", false)
+}
+
+// conditionTrue checks whether the given status condition is True.
+// If required is false, a missing condition counts as ready.
+func conditionTrue(obj map[string]interface{}, conditionType string, required bool) (bool, string) {
+	condition, err := dynamicobject.GetStatusCondition(obj, conditionType)
+	if err != nil {
+		return false, fmt.Sprintf("can't get %v condition: %v", conditionType, err)
+	}
+	if condition == nil {
+		if required {
+			return false, fmt.Sprintf("%v condition not reported", conditionType)
+		}
+		return true, ""
+	}
+	if condition.Status != "True" {
+		if condition.Message != "" {
+			return false, fmt.Sprintf("%v condition is %v: %v", conditionType, condition.Status, condition.Message)
+		}
+		return false, fmt.Sprintf("%v condition is %v", conditionType, condition.Status)
+	}
+	return true, ""
+}
+
+// specReplicas returns spec.replicas, which defaults to 1 if unset.
+func specReplicas(obj map[string]interface{}) int64 {
+	replicas, found, err := unstructured.NestedInt64(obj, "spec", "replicas")
+	if !found || err != nil {
+		return 1
+	}
+	return replicas
+}
+
+// statusInt returns an integer status field, or 0 if it's unset.
+func statusInt(obj map[string]interface{}, field string) int64 {
+	value, _, _ := unstructured.NestedInt64(obj, "status", field)
+	return value
+}
@@ -0,0 +1,212 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestChildReady(t *testing.T) {
+	table := []struct {
+		name   string
+		object map[string]interface{}
+		ready  bool
+	}{
+		{
+			name: "available deployment",
+			object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata":   map[string]interface{}{"name": "test", "generation": int64(2)},
+				"spec":       map[string]interface{}{"replicas": int64(2)},
+				"status": map[string]interface{}{
+					"observedGeneration": int64(2),
+					"updatedReplicas":    int64(2),
+					"availableReplicas":  int64(2),
+				},
+			},
+			ready: true,
+		},
+		{
+			name: "deployment with stale status",
+			object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata":   map[string]interface{}{"name": "test", "generation": int64(3)},
+				"spec":       map[string]interface{}{"replicas": int64(2)},
+				"status": map[string]interface{}{
+					"observedGeneration": int64(2),
+					"updatedReplicas":    int64(2),
+					"availableReplicas":  int64(2),
+				},
+			},
+			ready: false,
+		},
+		{
+			name: "deployment with default replicas not available",
+			object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata":   map[string]interface{}{"name": "test"},
+				"status":     map[string]interface{}{"updatedReplicas": int64(1)},
+			},
+			ready: false,
+		},
+		{
+			name: "statefulset not ready",
+			object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "StatefulSet",
+				"metadata":   map[string]interface{}{"name": "test"},
+				"spec":       map[string]interface{}{"replicas": int64(3)},
+				"status":     map[string]interface{}{"readyReplicas": int64(2)},
+			},
+			ready: false,
+		},
+		{
+			name: "daemonset ready",
+			object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "DaemonSet",
+				"metadata":   map[string]interface{}{"name": "test"},
+				"status": map[string]interface{}{
+					"desiredNumberScheduled": int64(3),
+					"numberReady":            int64(3),
+				},
+			},
+			ready: true,
+		},
+		{
+			name: "job complete",
+			object: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "test"},
+				"status": map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Complete", "status": "True"},
+					},
+				},
+			},
+			ready: true,
+		},
+		{
+			name: "job running",
+			object: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "test"},
+				"status":     map[string]interface{}{"active": int64(1)},
+			},
+			ready: false,
+		},
+		{
+			name: "pod not ready",
+			object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata":   map[string]interface{}{"name": "test"},
+				"status": map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Ready", "status": "False"},
+					},
+				},
+			},
+			ready: false,
+		},
+		{
+			name: "object without status",
+			object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "test"},
+			},
+			ready: true,
+		},
+	}
+
+	for _, tc := range table {
+		ready, reason := ChildReady(&unstructured.Unstructured{Object: tc.object})
+		if ready != tc.ready {
+			t.Errorf("%v: ChildReady() = %v (%q), want %v", tc.name, ready, reason, tc.ready)
+		}
+		if !ready && reason == "" {
+			t.Errorf("%v: expected a reason for a child that is not ready", tc.name)
+		}
+	}
+}
+
+func TestChildReadyDeleting(t *testing.T) {
+	child := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "test"},
+	}}
+	now := metav1.Now()
+	child.SetDeletionTimestamp(&now)
+	if ready, _ := ChildReady(child); ready {
+		t.Error("Expected child pending deletion to be not ready")
+	}
+}
+
+func TestSummarizeReadiness(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("default")
+	children := make(ChildMap)
+	children.InitGroup("v1", "Pod")
+	children.Insert(parent, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "ready", "namespace": "default"},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+			},
+		},
+	}})
+	children.Insert(parent, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "not-ready", "namespace": "default"},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "False"},
+			},
+		},
+	}})
+
+	if got := SummarizeReadiness(nil, children); got != nil {
+		t.Errorf("SummarizeReadiness() = %#v, want nil when disabled", got)
+	}
+
+	config := &v1alpha1.ChildReadiness{StatusField: "childReadiness"}
+	want := &ReadinessSummary{
+		Ready:      false,
+		Total:      2,
+		ReadyCount: 1,
+		NotReady: []NotReadyChild{
+			{APIVersion: "v1", Kind: "Pod", Name: "not-ready", Reason: "Ready condition is False"},
+		},
+	}
+	got := SummarizeReadiness(config, children)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SummarizeReadiness() = %#v, want %#v", got, want)
+	}
+
+	status := map[string]interface{}{"replicas": int64(2)}
+	SetReadinessStatus(config, status, got)
+	summary, ok := status["childReadiness"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected readiness summary in status, got %#v", status)
+	}
+	if summary["readyCount"] != int64(1) || summary["ready"] != false {
+		t.Errorf("unexpected readiness status: %#v", summary)
+	}
+	if status["replicas"] != int64(2) {
+		t.Errorf("expected other status fields to be kept, got %#v", status)
+	}
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicapply "metacontroller.io/dynamic/apply"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicobject "metacontroller.io/dynamic/object"
+)
+
+// recreateDelay is how soon to sync a parent again when one of its children
+// can't be created until an object with the same name is gone.
+const recreateDelay = time.Second
+
+// pendingDeletionError says that a child can't be created yet, because an
+// object with the same name is still being deleted. It isn't a sync failure:
+// the parent is synced again after recreateDelay instead.
+type pendingDeletionError struct {
+	obj *unstructured.Unstructured
+}
+
+func (e *pendingDeletionError) Error() string {
+	return fmt.Sprintf("can't create %v yet: the previous object with the same name is still being deleted", describeObject(e.obj))
+}
+
+// createChild creates the desired child obj of parent in namespace ns, and
+// returns it, or nil if parent already had it.
+// If an object with the same name is still being deleted, it returns a
+// *pendingDeletionError, so it's created on a later sync. If one that parent
+// doesn't control exists, the error says so.
+func createChild(client *dynamicclientset.ResourceClient, applyMode v1alpha1.ChildApplyMode, childFinalizer *ChildFinalizer, addFinalizer bool, ownerRef *metav1.OwnerReference, parent, obj *unstructured.Unstructured, ns string) (*unstructured.Unstructured, error) {
+	if applyMode == v1alpha1.ChildApplyServerSide {
+		return serverSideApply(client, nil, childApplyConfig(ownerRef, obj, ns, childFinalizer, addFinalizer))
+	}
+
+	// The controller should return a partial object containing only the
+	// fields it cares about. We save this partial object so we can do
+	// a 3-way merge upon update, in the style of "kubectl apply".
+	//
+	// Make sure this happens before we add anything else to the object.
+	if err := dynamicapply.SetLastApplied(obj, obj.UnstructuredContent()); err != nil {
+		return nil, err
+	}
+
+	// We always claim everything we create, by owner reference unless
+	// this kind is tracked by labels only.
+	if ownerRef != nil {
+		obj.SetOwnerReferences(append(obj.GetOwnerReferences(), *ownerRef))
+	}
+
+	if addFinalizer {
+		dynamicobject.AddFinalizer(obj, childFinalizer.Name)
+	}
+
+	created, err := client.Create(obj, metav1.CreateOptions{FieldManager: FieldManager})
+	if !apierrors.IsAlreadyExists(err) {
+		return created, err
+	}
+	existing, getErr := client.Get(obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(getErr) {
+		// It was deleted in the meantime.
+		return client.Create(obj, metav1.CreateOptions{FieldManager: FieldManager})
+	}
+	if getErr != nil {
+		return nil, fmt.Errorf("can't get %v: %v", describeObject(obj), getErr)
+	}
+	if existing.GetDeletionTimestamp() == nil {
+		if ref := metav1.GetControllerOf(existing); ref != nil && ref.UID == parent.GetUID() {
+			// We created it already, but it wasn't observed yet.
+			return nil, nil
+		}
+		return nil, fmt.Errorf("can't create %v: an object with the same name but UID %v already exists, and %v %v doesn't control it", describeObject(obj), existing.GetUID(), parent.GetKind(), parent.GetName())
+	}
+	return nil, &pendingDeletionError{obj: obj}
+}
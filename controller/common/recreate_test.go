@@ -0,0 +1,188 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+func TestCreateChild_alreadyExists(t *testing.T) {
+	table := []struct {
+		name    string
+		get     func(w http.ResponseWriter)
+		wantErr string
+	}{
+		{
+			name: "get fails",
+			get: func(w http.ResponseWriter) {
+				http.Error(w, "etcd unavailable", http.StatusInternalServerError)
+			},
+			wantErr: "can't get ConfigMap default/test",
+		},
+		{
+			name: "not controlled by parent",
+			get: func(w http.ResponseWriter) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "default", "uid": "other"}}`))
+			},
+			wantErr: "doesn't control it",
+		},
+	}
+
+	for _, tc := range table {
+		apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "AlreadyExists", "code": 409}`))
+				return
+			}
+			tc.get(w)
+		}))
+		client := newTestResourceClient(t, apiServer.URL, "v1", "configmaps")
+
+		parent := &unstructured.Unstructured{}
+		parent.SetKind("Thing")
+		parent.SetName("parent")
+		parent.SetUID("parent-uid")
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName("test")
+		obj.SetNamespace("default")
+
+		_, err := createChild(client.Namespace("default"), "", nil, false, nil, parent, obj, "default")
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%v: createChild() = %v, want error containing %q", tc.name, err, tc.wantErr)
+		}
+		apiServer.Close()
+	}
+}
+
+func newTestResourceClient(t *testing.T, host, apiVersion, resource string) *dynamicclientset.ResourceClient {
+	client, err := newTestClientset(t, host, apiVersion, resource).Resource(apiVersion, resource)
+	if err != nil {
+		t.Fatalf("Can't create client for %v: %v", resource, err)
+	}
+	return client
+}
+
+// newTestClientset returns a dynamic clientset for the API server at host,
+// which serves ConfigMaps as resource in apiVersion.
+func newTestClientset(t *testing.T, host, apiVersion, resource string) *dynamicclientset.Clientset {
+	resources := dynamicdiscovery.NewResourceMap(&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{
+		Resources: []*metav1.APIResourceList{{
+			GroupVersion: apiVersion,
+			APIResources: []metav1.APIResource{{Name: resource, Namespaced: true, Kind: "ConfigMap"}},
+		}},
+	}})
+	resources.Start(time.Hour)
+	t.Cleanup(resources.Stop)
+	for !resources.HasSynced() {
+		time.Sleep(time.Millisecond)
+	}
+	dynClient, err := dynamicclientset.New(&rest.Config{Host: host}, resources)
+	if err != nil {
+		t.Fatalf("Can't create dynamic clientset: %v", err)
+	}
+	return dynClient
+}
+
+func TestManageChildren_recreateDoesNotWait(t *testing.T) {
+	table := []struct {
+		name     string
+		observed bool
+		wantCall string
+	}{
+		{
+			// The Recreate strategy deletes the child, and leaves creating
+			// it again to a later sync.
+			name:     "recreate",
+			observed: true,
+			wantCall: http.MethodDelete,
+		},
+		{
+			// The old object is gone from the cache, but not from the API
+			// server yet.
+			name:     "create while previous object is being deleted",
+			wantCall: http.MethodPost,
+		},
+	}
+
+	for _, tc := range table {
+		var calls []string
+		apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, r.Method)
+			w.Header().Set("Content-Type", "application/json")
+			switch r.Method {
+			case http.MethodDelete:
+				w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Success"}`))
+			case http.MethodPost:
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "AlreadyExists", "code": 409}`))
+			case http.MethodGet:
+				w.Write([]byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "default", "uid": "old", "deletionTimestamp": "2021-01-01T00:00:00Z"}}`))
+			}
+		}))
+		dynClient := newTestClientset(t, apiServer.URL, "v1", "configmaps")
+
+		parent := &unstructured.Unstructured{}
+		parent.SetKind("Thing")
+		parent.SetName("parent")
+		parent.SetNamespace("default")
+		parent.SetUID("parent-uid")
+		desired := &unstructured.Unstructured{}
+		desired.SetAPIVersion("v1")
+		desired.SetKind("ConfigMap")
+		desired.SetName("test")
+		desired.SetNamespace("default")
+		desired.Object["data"] = map[string]interface{}{"key": "new"}
+		observed := ChildMap{}
+		if tc.observed {
+			old := desired.DeepCopy()
+			old.SetUID("old")
+			old.Object["data"] = map[string]interface{}{"key": "old"}
+			observed.Insert(parent, old)
+		}
+		desiredChildren := ChildMap{}
+		desiredChildren.Insert(parent, desired)
+
+		start := time.Now()
+		retryAfter, err := ManageChildren(logr.Discard(), dynClient, nil, "", fakeUpdateStrategy{"ConfigMap": v1alpha1.ChildUpdateRecreate}, nil, nil, parent, observed, desiredChildren, 1)
+		if err != nil {
+			t.Errorf("%v: ManageChildren() = %v, want no error", tc.name, err)
+		}
+		if retryAfter != recreateDelay {
+			t.Errorf("%v: ManageChildren() retryAfter = %v, want %v", tc.name, retryAfter, recreateDelay)
+		}
+		if elapsed := time.Since(start); elapsed > recreateDelay {
+			t.Errorf("%v: ManageChildren() took %v, want it not to wait for the deletion", tc.name, elapsed)
+		}
+		found := false
+		for _, call := range calls {
+			if call == tc.wantCall {
+				found = true
+			}
+			if call == http.MethodPost && tc.observed {
+				t.Errorf("%v: API calls = %v, want no create in the sync that deletes", tc.name, calls)
+			}
+		}
+		if !found {
+			t.Errorf("%v: API calls = %v, want %v", tc.name, calls, tc.wantCall)
+		}
+		apiServer.Close()
+	}
+}
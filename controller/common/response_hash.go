@@ -0,0 +1,41 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ResponseHash returns the hash sent to hooks once response is applied, or
+// an empty string if response can't be encoded.
+// It's a SHA-256 of the JSON encoding of the response, as Metacontroller
+// decoded it, so it only changes when the response does.
+func ResponseHash(response interface{}) string {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Applied records the hash of the hook response that was applied in the last
+// successful sync of the parent with the given queue key.
+func (r *SyncRetries) Applied(key, hash string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if hash == "" {
+		delete(r.responseHashes, key)
+		return
+	}
+	r.responseHashes[key] = hash
+}
+
+// PreviousResponseHash returns the hash of the hook response applied in the
+// last successful sync of the parent with the given queue key, or an empty
+// string if it didn't sync since Metacontroller started.
+func (r *SyncRetries) PreviousResponseHash(key string) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.responseHashes[key]
+}
@@ -0,0 +1,37 @@
+package common
+
+import "testing"
+
+func TestResponseHash(t *testing.T) {
+	response := map[string]interface{}{"status": map[string]interface{}{"replicas": 1}}
+	hash := ResponseHash(response)
+	if hash == "" {
+		t.Fatalf("ResponseHash() is empty")
+	}
+	if got := ResponseHash(map[string]interface{}{"status": map[string]interface{}{"replicas": 1}}); got != hash {
+		t.Errorf("ResponseHash() = %v for the same response, want %v", got, hash)
+	}
+	if got := ResponseHash(map[string]interface{}{"status": map[string]interface{}{"replicas": 2}}); got == hash {
+		t.Errorf("ResponseHash() = %v for another response, want it to change", got)
+	}
+	if got := ResponseHash(make(chan int)); got != "" {
+		t.Errorf("ResponseHash() = %v for a response that can't be encoded, want none", got)
+	}
+}
+
+func TestSyncRetriesPreviousResponseHash(t *testing.T) {
+	retries := NewSyncRetries("CompositeController", "test")
+	defer retries.Stop()
+
+	if got := retries.PreviousResponseHash("ns/a"); got != "" {
+		t.Errorf("PreviousResponseHash() = %q before any sync, want none", got)
+	}
+	retries.Applied("ns/a", "abc")
+	if got := retries.PreviousResponseHash("ns/a"); got != "abc" {
+		t.Errorf("PreviousResponseHash() = %q, want abc", got)
+	}
+	retries.Forget("ns/a")
+	if got := retries.PreviousResponseHash("ns/a"); got != "" {
+		t.Errorf("PreviousResponseHash() = %q after Forget(), want none", got)
+	}
+}
@@ -0,0 +1,21 @@
+package common
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ResyncRequestAnnotation can be set on a controller to request an
+	// immediate resync of all its parents. Each time its value changes,
+	// every parent is sent to the sync hook again.
+	ResyncRequestAnnotation = "metacontroller.k8s.io/resync-requested"
+)
+
+// ResyncRequested returns the value of the resync request annotation of
+// controller, and whether it changed from last, the value seen when its
+// parents were last resynced for it. Removing the annotation counts as a
+// change too.
+func ResyncRequested(controller metav1.Object, last string) (string, bool) {
+	request := controller.GetAnnotations()[ResyncRequestAnnotation]
+	return request, request != last
+}
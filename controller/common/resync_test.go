@@ -0,0 +1,57 @@
+package common
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResyncRequested(t *testing.T) {
+	table := []struct {
+		name        string
+		annotations map[string]string
+		last        string
+		want        string
+		wantChanged bool
+	}{
+		{
+			name: "never requested",
+		},
+		{
+			name:        "first request",
+			annotations: map[string]string{ResyncRequestAnnotation: "1"},
+			want:        "1",
+			wantChanged: true,
+		},
+		{
+			name:        "same request",
+			annotations: map[string]string{ResyncRequestAnnotation: "1"},
+			last:        "1",
+			want:        "1",
+		},
+		{
+			name:        "new request",
+			annotations: map[string]string{ResyncRequestAnnotation: "2"},
+			last:        "1",
+			want:        "2",
+			wantChanged: true,
+		},
+		{
+			name:        "request removed",
+			annotations: map[string]string{"other": "1"},
+			last:        "1",
+			want:        "",
+			wantChanged: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := &metav1.ObjectMeta{Annotations: tc.annotations}
+			got, changed := ResyncRequested(controller, tc.last)
+			if got != tc.want || changed != tc.wantChanged {
+				t.Errorf("ResyncRequested() = %q, %v; want %q, %v", got, changed, tc.want, tc.wantChanged)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package common
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+var (
+	controllerRevisions = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "metacontroller",
+			Name:           "controller_revisions",
+			Help:           "Number of ControllerRevisions of all the parents of a controller, chunks included.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller_kind", "controller"},
+	)
+	controllerRevisionBytes = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "metacontroller",
+			Name:           "controller_revision_bytes",
+			Help:           "Total size of the parent patches recorded in the ControllerRevisions of all the parents of a controller.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller_kind", "controller"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(controllerRevisions, controllerRevisionBytes)
+}
+
+// revisionUsage is the number and size of the ControllerRevisions of a
+// parent.
+type revisionUsage struct {
+	count, bytes int
+}
+
+// RevisionCounts tracks the number and size of the ControllerRevisions of the
+// parents of a controller, as of their last sync.
+type RevisionCounts struct {
+	controllerKind, controller string
+
+	mutex sync.Mutex
+	// parents maps the queue key of each parent to its revision usage.
+	parents map[string]revisionUsage
+	total   revisionUsage
+}
+
+// NewRevisionCounts returns a RevisionCounts for the controller with the
+// given kind and name.
+func NewRevisionCounts(controllerKind, controller string) *RevisionCounts {
+	return &RevisionCounts{
+		controllerKind: controllerKind,
+		controller:     controller,
+		parents:        make(map[string]revisionUsage),
+	}
+}
+
+// Observe records the ControllerRevisions of the parent with the given queue
+// key, including the chunks of large revisions.
+func (c *RevisionCounts) Observe(key string, revisions []*v1alpha1.ControllerRevision) {
+	usage := revisionUsage{count: len(revisions)}
+	for _, revision := range revisions {
+		usage.bytes += len(revision.ParentPatch.Raw) + len(revision.ParentPatchData)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.update(c.parents[key], usage)
+	if usage.count > 0 {
+		c.parents[key] = usage
+	} else {
+		delete(c.parents, key)
+	}
+}
+
+// Forget stops counting the revisions of the parent with the given queue key,
+// because it's gone or no longer managed by the controller.
+func (c *RevisionCounts) Forget(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	old, ok := c.parents[key]
+	if !ok {
+		return
+	}
+	c.update(old, revisionUsage{})
+	delete(c.parents, key)
+}
+
+// update replaces the revision usage of a parent, before and after a sync, in
+// the totals.
+func (c *RevisionCounts) update(before, after revisionUsage) {
+	c.total.count += after.count - before.count
+	c.total.bytes += after.bytes - before.bytes
+	controllerRevisions.WithLabelValues(c.controllerKind, c.controller).Set(float64(c.total.count))
+	controllerRevisionBytes.WithLabelValues(c.controllerKind, c.controller).Set(float64(c.total.bytes))
+}
+
+// Stop removes the metrics of the controller, when it stops.
+func (c *RevisionCounts) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	controllerRevisions.DeleteLabelValues(c.controllerKind, c.controller)
+	controllerRevisionBytes.DeleteLabelValues(c.controllerKind, c.controller)
+	c.parents = make(map[string]revisionUsage)
+	c.total = revisionUsage{}
+}
@@ -0,0 +1,38 @@
+package common
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestRevisionCounts(t *testing.T) {
+	revision := func(patch string, data []byte) *v1alpha1.ControllerRevision {
+		return &v1alpha1.ControllerRevision{
+			ParentPatch:     runtime.RawExtension{Raw: []byte(patch)},
+			ParentPatchData: data,
+		}
+	}
+
+	c := NewRevisionCounts("CompositeController", "test")
+	defer c.Stop()
+
+	c.Observe("ns/a", []*v1alpha1.ControllerRevision{revision(`{"spec":{}}`, nil), revision("", make([]byte, 100))})
+	c.Observe("ns/b", []*v1alpha1.ControllerRevision{revision(`{}`, nil)})
+	c.Observe("ns/a", []*v1alpha1.ControllerRevision{revision(`{"spec":{}}`, nil)})
+	if want := (revisionUsage{count: 2, bytes: 13}); c.total != want {
+		t.Errorf("total = %+v, want %+v", c.total, want)
+	}
+
+	c.Forget("ns/a")
+	c.Forget("ns/missing")
+	if want := (revisionUsage{count: 1, bytes: 2}); c.total != want {
+		t.Errorf("total after Forget() = %+v, want %+v", c.total, want)
+	}
+	c.Observe("ns/b", nil)
+	if len(c.parents) != 0 || c.total != (revisionUsage{}) {
+		t.Errorf("parents = %v, total = %+v, want none", c.parents, c.total)
+	}
+}
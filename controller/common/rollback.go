@@ -0,0 +1,141 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+)
+
+// childWrites records the children written in a sync, so they can be rolled
+// back if the sync fails half-way. A nil *childWrites records nothing.
+type childWrites struct {
+	// mutex guards children, since children can be written in parallel.
+	mutex    sync.Mutex
+	children []writtenChild
+}
+
+// writtenChild is a child that was created or updated in a sync.
+type writtenChild struct {
+	client *dynamicclientset.ResourceClient
+	// observed is the child before it was updated, or nil if it was created.
+	observed *unstructured.Unstructured
+	// written is the child as it was written.
+	written *unstructured.Unstructured
+}
+
+// add records that written was written over observed, which is nil for a
+// new child. written is nil if nothing was written after all.
+func (w *childWrites) add(client *dynamicclientset.ResourceClient, observed, written *unstructured.Unstructured) {
+	if w == nil || written == nil {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.children = append(w.children, writtenChild{client: client, observed: observed, written: written})
+}
+
+// previous returns the child named name as it is before a server-side apply
+// that's meant to create it, or nil if it doesn't exist yet.
+// Server-side apply creates and updates alike, and the child may exist
+// without having been observed yet, so only a child that didn't exist is
+// rolled back by deleting it. A nil *childWrites looks nothing up.
+func (w *childWrites) previous(client *dynamicclientset.ResourceClient, name string) (*unstructured.Unstructured, error) {
+	if w == nil {
+		return nil, nil
+	}
+	obj, err := client.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't get %v %v: %v", client.Kind, name, err)
+	}
+	return obj, nil
+}
+
+// rollBack undoes the recorded writes, latest first: new children are deleted
+// and updated ones are restored to what they were before. Children that were
+// changed again since they were written are left alone.
+func (w *childWrites) rollBack(log logr.Logger) error {
+	var errs []error
+	for i := len(w.children) - 1; i >= 0; i-- {
+		child := w.children[i]
+		written := child.written
+		if child.observed == nil {
+			log.Info("Rolling back creation of child", "child", klog.KObj(written))
+			uid := written.GetUID()
+			propagation := metav1.DeletePropagationBackground
+			err := child.client.Delete(written.GetName(), &metav1.DeleteOptions{
+				Preconditions:     &metav1.Preconditions{UID: &uid},
+				PropagationPolicy: &propagation,
+			})
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("can't delete %v: %v", describeObject(written), err))
+			}
+			continue
+		}
+		log.Info("Rolling back update of child", "child", klog.KObj(written))
+		restored := child.observed.DeepCopy()
+		// The update only goes through if nobody changed the child since.
+		restored.SetResourceVersion(written.GetResourceVersion())
+		if _, err := child.client.Update(restored, metav1.UpdateOptions{FieldManager: FieldManager}); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("can't restore %v: %v", describeObject(written), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// ManageChildrenWithRollback is like ManageChildren, except that children are
+// only deleted once all desired children were written, and that if writing
+// any of them fails, the children created or updated in the same sync are
+// rolled back, so the hook's intent isn't left half applied.
+// Children deleted to be recreated by a Recreate update strategy can't be
+// brought back.
+func ManageChildrenWithRollback(log logr.Logger, dynClient *dynamicclientset.Clientset, eventRecorder record.EventRecorder, applyMode v1alpha1.ChildApplyMode, updateStrategy ChildUpdateStrategy, childFinalizer *ChildFinalizer, ownerRefs ChildOwnerReferences, parent *unstructured.Unstructured, observedChildren, desiredChildren ChildMap, parallelism int) (time.Duration, error) {
+	writes := &childWrites{}
+	workers := newChildWorkers(parallelism)
+	var errs []error
+	for key, objects := range desiredChildren {
+		apiVersion, kind := ParseChildMapKey(key)
+		client, err := dynClient.Kind(apiVersion, kind)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		updateChildren(log, workers, client, eventRecorder, applyMode, updateStrategy, childFinalizer, ownerRefs, parent, observedChildren[key], objects, writes)
+	}
+	if err := workers.wait(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		if err := writes.rollBack(log); err != nil {
+			errs = append(errs, fmt.Errorf("can't roll back children: %v", err))
+		}
+		return 0, utilerrors.NewAggregate(errs)
+	}
+
+	for key, objects := range observedChildren {
+		apiVersion, kind := ParseChildMapKey(key)
+		client, err := dynClient.Kind(apiVersion, kind)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleteChildren(log, workers, client, childFinalizer, parent, objects, desiredChildren[key])
+	}
+	if err := workers.wait(); err != nil {
+		errs = append(errs, err)
+	}
+	return workers.retryDelay(), utilerrors.NewAggregate(errs)
+}
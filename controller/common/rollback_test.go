@@ -0,0 +1,63 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestChildWritesAdd(t *testing.T) {
+	child := &unstructured.Unstructured{}
+	child.SetName("test")
+
+	var none *childWrites
+	// A nil *childWrites records nothing, for syncs without rollback.
+	none.add(nil, nil, child)
+
+	writes := &childWrites{}
+	writes.add(nil, nil, nil)
+	if len(writes.children) != 0 {
+		t.Errorf("add() recorded a write that didn't happen: %v", writes.children)
+	}
+	writes.add(nil, nil, child)
+	writes.add(nil, child, child)
+	if len(writes.children) != 2 {
+		t.Fatalf("got %v writes, want 2", len(writes.children))
+	}
+	if writes.children[0].observed != nil || writes.children[1].observed != child {
+		t.Errorf("add() = %+v, want a creation followed by an update", writes.children)
+	}
+}
+
+func TestChildWritesPrevious(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/existing") {
+			w.Write([]byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "existing", "namespace": "default", "uid": "1", "resourceVersion": "5"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404}`))
+	}))
+	defer apiServer.Close()
+	client := newTestResourceClient(t, apiServer.URL, "v1", "configmaps").Namespace("default")
+
+	writes := &childWrites{}
+	got, err := writes.previous(client, "existing")
+	if err != nil {
+		t.Fatalf("previous() = %v", err)
+	}
+	if got == nil || got.GetResourceVersion() != "5" {
+		t.Errorf("previous() = %v, want the existing child, so applying it is rolled back as an update", got)
+	}
+	got, err = writes.previous(client, "new")
+	if err != nil {
+		t.Fatalf("previous() = %v", err)
+	}
+	if got != nil {
+		t.Errorf("previous() = %v, want nil for a child that doesn't exist yet", got)
+	}
+}
@@ -0,0 +1,156 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+)
+
+// ScaleDownTimeAnnotation is set on children that are scaled to zero before
+// they're deleted, to when that started, in RFC 3339 format.
+const ScaleDownTimeAnnotation = "metacontroller.k8s.io/scale-down-time"
+
+// DefaultScaleDownTimeout is how long children are given to scale to zero
+// before they're deleted anyway, if their delete strategy doesn't say.
+const DefaultScaleDownTimeout = 5 * time.Minute
+
+// ScaleDownKinds maps the child kinds that are scaled to zero before they're
+// deleted to how long their pods are given to terminate.
+type ScaleDownKinds map[schema.GroupKind]time.Duration
+
+// NewScaleDownTimeout returns the scale-down timeout of strategy, or 0 if
+// children are deleted right away.
+func NewScaleDownTimeout(strategy *v1alpha1.ChildDeleteStrategy) (time.Duration, error) {
+	if strategy == nil {
+		return 0, nil
+	}
+	switch strategy.Method {
+	case "", v1alpha1.ChildDeleteMethodDelete:
+		return 0, nil
+	case v1alpha1.ChildDeleteMethodScaleDown:
+		if strategy.TimeoutSeconds == nil {
+			return DefaultScaleDownTimeout, nil
+		}
+		if *strategy.TimeoutSeconds <= 0 {
+			return 0, fmt.Errorf("deleteStrategy.timeoutSeconds must be positive")
+		}
+		return time.Duration(*strategy.TimeoutSeconds) * time.Second, nil
+	default:
+		return 0, fmt.Errorf("invalid deleteStrategy.method %q", strategy.Method)
+	}
+}
+
+// Filter scales to zero the observed children of ScaleDown kinds that aren't
+// desired, and returns the observed children without those whose pods
+// haven't terminated yet, so ManageChildren only deletes them once they
+// have, or once their timeout is up. It also returns when the parent should
+// be synced again to enforce the timeout.
+// Children without spec.replicas are deleted right away.
+func (k ScaleDownKinds) Filter(log logr.Logger, dynClient *dynamicclientset.Clientset, observed, desired ChildMap, now time.Time) (ChildMap, time.Duration, error) {
+	if len(k) == 0 {
+		return observed, 0, nil
+	}
+	var retryAfter time.Duration
+	var errs []error
+	kept := make(ChildMap, len(observed))
+	for key, objects := range observed {
+		apiVersion, kind := ParseChildMapKey(key)
+		apiGroup, _ := ParseAPIVersion(apiVersion)
+		timeout, ok := k[schema.GroupKind{Group: apiGroup, Kind: kind}]
+		if !ok {
+			kept[key] = objects
+			continue
+		}
+		kept[key] = make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if desired[key][name] != nil || obj.GetDeletionTimestamp() != nil {
+				kept[key][name] = obj
+				continue
+			}
+			wait, err := scaleDown(log, dynClient, obj, timeout, now)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if wait == 0 {
+				kept[key][name] = obj
+				continue
+			}
+			if retryAfter == 0 || wait < retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+	return kept, retryAfter, utilerrors.NewAggregate(errs)
+}
+
+// scaleDown scales obj to zero, if it isn't yet, and returns how long to wait
+// for its pods to terminate, or 0 if it can be deleted now.
+func scaleDown(log logr.Logger, dynClient *dynamicclientset.Clientset, obj *unstructured.Unstructured, timeout time.Duration, now time.Time) (time.Duration, error) {
+	replicas, found, err := unstructured.NestedInt64(obj.UnstructuredContent(), "spec", "replicas")
+	if err != nil || !found {
+		return 0, nil
+	}
+	started, err := time.Parse(time.RFC3339, obj.GetAnnotations()[ScaleDownTimeAnnotation])
+	if err != nil || replicas != 0 {
+		// The child was scaled up again since any earlier scale-down, so the
+		// timeout starts over.
+		log.Info("Scaling down child before deleting it", "child", klog.KObj(obj))
+		if err := patchScaleDown(dynClient, obj, now); err != nil {
+			return 0, fmt.Errorf("can't scale down %v: %v", describeObject(obj), err)
+		}
+		return timeout, nil
+	}
+	if scaledDown(obj) {
+		return 0, nil
+	}
+	if wait := started.Add(timeout).Sub(now); wait > 0 {
+		return wait, nil
+	}
+	log.Info("Timed out waiting for child to scale down", "child", klog.KObj(obj), "timeout", timeout)
+	return 0, nil
+}
+
+// scaledDown returns whether the status of obj reports no replicas left,
+// for its current generation.
+func scaledDown(obj *unstructured.Unstructured) bool {
+	observedGeneration, found, _ := unstructured.NestedInt64(obj.UnstructuredContent(), "status", "observedGeneration")
+	if found && observedGeneration < obj.GetGeneration() {
+		return false
+	}
+	replicas, _, _ := unstructured.NestedInt64(obj.UnstructuredContent(), "status", "replicas")
+	return replicas == 0
+}
+
+func patchScaleDown(dynClient *dynamicclientset.Clientset, obj *unstructured.Unstructured, started time.Time) error {
+	client, err := dynClient.Kind(obj.GetAPIVersion(), obj.GetKind())
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				ScaleDownTimeAnnotation: started.UTC().Format(time.RFC3339),
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": 0,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.Namespace(obj.GetNamespace()).Patch(obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+	return err
+}
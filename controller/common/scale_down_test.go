@@ -0,0 +1,64 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestNewScaleDownTimeout(t *testing.T) {
+	table := []struct {
+		name     string
+		strategy *v1alpha1.ChildDeleteStrategy
+		want     time.Duration
+		wantErr  bool
+	}{
+		{name: "no strategy"},
+		{name: "delete", strategy: &v1alpha1.ChildDeleteStrategy{Method: v1alpha1.ChildDeleteMethodDelete}},
+		{name: "default timeout", strategy: &v1alpha1.ChildDeleteStrategy{Method: v1alpha1.ChildDeleteMethodScaleDown}, want: DefaultScaleDownTimeout},
+		{name: "timeout", strategy: &v1alpha1.ChildDeleteStrategy{Method: v1alpha1.ChildDeleteMethodScaleDown, TimeoutSeconds: pointer.Int32Ptr(60)}, want: time.Minute},
+		{name: "invalid timeout", strategy: &v1alpha1.ChildDeleteStrategy{Method: v1alpha1.ChildDeleteMethodScaleDown, TimeoutSeconds: pointer.Int32Ptr(0)}, wantErr: true},
+		{name: "invalid method", strategy: &v1alpha1.ChildDeleteStrategy{Method: "Evict"}, wantErr: true},
+	}
+
+	for _, tc := range table {
+		got, err := NewScaleDownTimeout(tc.strategy)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%v: NewScaleDownTimeout() error = %v, want error %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%v: NewScaleDownTimeout() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestScaledDown(t *testing.T) {
+	table := []struct {
+		name                           string
+		generation, observedGeneration int64
+		replicas                       int64
+		want                           bool
+	}{
+		{name: "replicas left", generation: 2, observedGeneration: 2, replicas: 1},
+		{name: "no replicas left", generation: 2, observedGeneration: 2, want: true},
+		{name: "stale status", generation: 2, observedGeneration: 1},
+	}
+
+	for _, tc := range table {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{
+				"observedGeneration": tc.observedGeneration,
+				"replicas":           tc.replicas,
+			},
+		}}
+		obj.SetGeneration(tc.generation)
+		if got := scaledDown(obj); got != tc.want {
+			t.Errorf("%v: scaledDown() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
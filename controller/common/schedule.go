@@ -0,0 +1,224 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Schedule is a parsed cron expression with the standard five fields:
+// minute, hour, day of month, month and day of week.
+// Schedules are always evaluated in UTC.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record whether the day fields were "*", since cron
+	// matches either day field when both of them are restricted.
+	domStar, dowStar bool
+}
+
+type scheduleField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = scheduleField{name: "minute", min: 0, max: 59}
+	hourField   = scheduleField{name: "hour", min: 0, max: 23}
+	domField    = scheduleField{name: "day of month", min: 1, max: 31}
+	monthField  = scheduleField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 are Sunday.
+	dowField = scheduleField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSchedule parses a cron expression like "0 2 * * *".
+// Each field can be "*", a value, a range like "1-5", a step like "*/15"
+// or "0-30/10", or a comma-separated list of those. Months and days of
+// the week can also be given by their three-letter English names.
+// The descriptors @yearly, @monthly, @weekly, @daily and @hourly are also
+// supported.
+func ParseSchedule(spec string) (*Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if descriptor, ok := scheduleDescriptors[strings.ToLower(expr)]; ok {
+		expr = descriptor
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields, got %v", spec, len(fields))
+	}
+
+	s := &Schedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	var err error
+	for i, f := range []struct {
+		bits  *uint64
+		field scheduleField
+	}{
+		{&s.minute, minuteField},
+		{&s.hour, hourField},
+		{&s.dom, domField},
+		{&s.month, monthField},
+		{&s.dow, dowField},
+	} {
+		*f.bits, err = parseScheduleField(fields[i], f.field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+	}
+	// Fold Sunday as 7 into Sunday as 0.
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1<<0
+	}
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never fires", spec)
+	}
+	return s, nil
+}
+
+func parseScheduleField(value string, field scheduleField) (uint64, error) {
+	var bits uint64
+	for _, term := range strings.Split(value, ",") {
+		rangeValue, stepValue := term, ""
+		if i := strings.Index(term, "/"); i >= 0 {
+			rangeValue, stepValue = term[:i], term[i+1:]
+		}
+
+		var start, end int
+		switch {
+		case rangeValue == "*":
+			start, end = field.min, field.max
+		case strings.Contains(rangeValue, "-"):
+			i := strings.Index(rangeValue, "-")
+			var err error
+			if start, err = field.parseValue(rangeValue[:i]); err != nil {
+				return 0, err
+			}
+			if end, err = field.parseValue(rangeValue[i+1:]); err != nil {
+				return 0, err
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid %v range %q", field.name, rangeValue)
+			}
+		default:
+			var err error
+			if start, err = field.parseValue(rangeValue); err != nil {
+				return 0, err
+			}
+			end = start
+			if stepValue != "" {
+				// Like "5/10", which means starting at 5 until the end.
+				end = field.max
+			}
+		}
+
+		step := 1
+		if stepValue != "" {
+			var err error
+			step, err = strconv.Atoi(stepValue)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid %v step %q", field.name, stepValue)
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f scheduleField) parseValue(value string) (int, error) {
+	if v, ok := f.names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v %q", f.name, value)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%v %v out of range [%v, %v]", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time strictly after t that matches the schedule,
+// or the zero time if there is none within the next five years, which can
+// only happen for impossible dates like February 30th.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// RunSchedule calls fn every time the schedule fires, until stopCh is closed.
+func RunSchedule(schedule *Schedule, stopCh <-chan struct{}, fn func()) {
+	last := time.Now()
+	for {
+		next := schedule.Next(last)
+		if next.IsZero() {
+			klog.InfoS("Schedule never fires, giving up")
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+			fn()
+		}
+		// Never fire twice for the same minute, even if the timer was early.
+		last = next
+		if now := time.Now(); now.After(last) {
+			last = now
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// A Monday.
+	now := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	table := []struct {
+		schedule string
+		want     time.Time
+	}{
+		{schedule: "0 2 * * *", want: time.Date(2021, 3, 16, 2, 0, 0, 0, time.UTC)},
+		{schedule: "*/15 * * * *", want: time.Date(2021, 3, 15, 10, 45, 0, 0, time.UTC)},
+		{schedule: "30 10 * * *", want: time.Date(2021, 3, 16, 10, 30, 0, 0, time.UTC)},
+		{schedule: "@hourly", want: time.Date(2021, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{schedule: "0 0 1 * *", want: time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{schedule: "0 9 * * mon-fri", want: time.Date(2021, 3, 16, 9, 0, 0, 0, time.UTC)},
+		{schedule: "0 0 * * 7", want: time.Date(2021, 3, 21, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted.
+		{schedule: "0 0 13 * 5", want: time.Date(2021, 3, 19, 0, 0, 0, 0, time.UTC)},
+		{schedule: "0 0 29 feb *", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range table {
+		schedule, err := ParseSchedule(tc.schedule)
+		if err != nil {
+			t.Errorf("%q: ParseSchedule() error: %v", tc.schedule, err)
+			continue
+		}
+		if got := schedule.Next(now); !got.Equal(tc.want) {
+			t.Errorf("%q: Next() = %v, want %v", tc.schedule, got, tc.want)
+		}
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	table := []string{
+		"",
+		"* * * *",
+		"61 * * * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"0 0 * foo *",
+		"0 0 30 2 *",
+	}
+
+	for _, schedule := range table {
+		if _, err := ParseSchedule(schedule); err == nil {
+			t.Errorf("%q: expected error", schedule)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package common
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// ObjectSelector matches objects by both labels and annotations.
+// A nil label or annotation selector matches everything.
+type ObjectSelector struct {
+	labelSelector      labels.Selector
+	annotationSelector labels.Selector
+}
+
+func NewObjectSelector(labelSelector *metav1.LabelSelector, annotationSelector *v1alpha1.AnnotationSelector) (*ObjectSelector, error) {
+	s := &ObjectSelector{
+		labelSelector:      labels.Everything(),
+		annotationSelector: labels.Everything(),
+	}
+	var err error
+
+	if labelSelector != nil {
+		s.labelSelector, err = metav1.LabelSelectorAsSelector(labelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert label selector: %v", err)
+		}
+	}
+	// Convert the annotation selector to a label selector, then to internal form.
+	if annotationSelector != nil {
+		selector := &metav1.LabelSelector{
+			MatchLabels:      annotationSelector.MatchAnnotations,
+			MatchExpressions: annotationSelector.MatchExpressions,
+		}
+		s.annotationSelector, err = metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert annotation selector: %v", err)
+		}
+	}
+	return s, nil
+}
+
+func (s *ObjectSelector) Matches(obj *unstructured.Unstructured) bool {
+	return s.labelSelector.Matches(labels.Set(obj.GetLabels())) &&
+		s.annotationSelector.Matches(labels.Set(obj.GetAnnotations()))
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestObjectSelectorMatches(t *testing.T) {
+	selector, err := NewObjectSelector(
+		&metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "test"},
+		},
+		&v1alpha1.AnnotationSelector{
+			MatchAnnotations: map[string]string{"status": "enabled"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewObjectSelector() error: %v", err)
+	}
+
+	table := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name:        "matches both",
+			labels:      map[string]string{"app": "test"},
+			annotations: map[string]string{"status": "enabled"},
+			want:        true,
+		},
+		{
+			name:   "missing annotation",
+			labels: map[string]string{"app": "test"},
+			want:   false,
+		},
+		{
+			name:        "wrong label",
+			labels:      map[string]string{"app": "other"},
+			annotations: map[string]string{"status": "enabled"},
+			want:        false,
+		},
+	}
+
+	for _, tc := range table {
+		obj := &unstructured.Unstructured{}
+		obj.SetLabels(tc.labels)
+		obj.SetAnnotations(tc.annotations)
+		if got := selector.Matches(obj); got != tc.want {
+			t.Errorf("%v: Matches() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestObjectSelectorEmpty(t *testing.T) {
+	selector, err := NewObjectSelector(nil, nil)
+	if err != nil {
+		t.Fatalf("NewObjectSelector() error: %v", err)
+	}
+	if !selector.Matches(&unstructured.Unstructured{}) {
+		t.Error("Expected nil selectors to match everything")
+	}
+}
@@ -0,0 +1,306 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicapply "metacontroller.io/dynamic/apply"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicobject "metacontroller.io/dynamic/object"
+)
+
+// ValidateChildApplyMode returns an error if mode isn't a known ChildApplyMode.
+func ValidateChildApplyMode(mode v1alpha1.ChildApplyMode) error {
+	switch mode {
+	case "", v1alpha1.ChildApplyThreeWayMerge, v1alpha1.ChildApplyServerSide:
+		return nil
+	}
+	return fmt.Errorf("invalid childApplyMode %q", mode)
+}
+
+// serverSideApply applies config, the desired state of a child, with
+// server-side apply. If observed was written with a 3-way merge, it's
+// migrated to server-side apply first.
+//
+// If observed isn't nil, the apply only succeeds if the child wasn't changed
+// since it was observed, and fails with a conflict otherwise.
+func serverSideApply(client *dynamicclientset.ResourceClient, observed, config *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	if observed != nil {
+		if hasLastApplied(observed) {
+			migrated, err := migrateToServerSideApply(client, observed)
+			if apierrors.IsConflict(err) {
+				return nil, err
+			}
+			if err != nil {
+				return nil, fmt.Errorf("can't migrate %v to server-side apply: %v", describeObject(observed), err)
+			}
+			observed = migrated
+		}
+		config = config.DeepCopy()
+		config.SetResourceVersion(observed.GetResourceVersion())
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("can't marshal %v: %v", describeObject(config), err)
+	}
+	return client.Patch(config.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        pointer.BoolPtr(true),
+	})
+}
+
+// serverSideUpdate applies config, the desired state of a child, to oldObj,
+// its observed state. If the child changed since it was observed, it's
+// checked again against the latest version of the child, so changes made by
+// others in the meantime aren't overwritten unseen.
+// It returns the updated child, or nil if there was nothing left to change.
+func serverSideUpdate(log logr.Logger, client *dynamicclientset.ResourceClient, oldObj, config, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	var result *unstructured.Unstructured
+	err := client.RetryOnConflict(oldObj, func(current *unstructured.Unstructured) error {
+		if current != oldObj {
+			if ServerSideUpToDate(current, desired) {
+				// Someone else already made the changes we wanted.
+				result = nil
+				return nil
+			}
+			log.V(4).Info("Retrying apply after conflict", "child", klog.KObj(current))
+		}
+		var err error
+		result, err = serverSideApply(client, current, config)
+		return err
+	})
+	return result, err
+}
+
+// ServerSideUpToDate returns whether applying desired to observed with
+// server-side apply would leave it unchanged: observed already has all the
+// desired values, and we don't own any fields that are no longer desired,
+// which the API server would remove.
+//
+// Children that still have a last applied configuration annotation need to be
+// migrated, so they're never up to date.
+func ServerSideUpToDate(observed, desired *unstructured.Unstructured) bool {
+	if hasLastApplied(observed) {
+		return false
+	}
+	if !matchesApplied(desired.UnstructuredContent(), observed.UnstructuredContent()) {
+		return false
+	}
+
+	var desiredPaths []string
+	contentPaths(desired.UnstructuredContent(), "", &desiredPaths)
+	for _, entry := range observed.GetManagedFields() {
+		if entry.Manager != FieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return false
+		}
+		var ownedPaths []string
+		fieldSetPaths(fields, "", &ownedPaths)
+		for _, owned := range ownedPaths {
+			// Owner references and finalizers are managed separately.
+			if owned == "metadata.ownerReferences" || owned == "metadata.finalizers" {
+				continue
+			}
+			if !coversPath(desiredPaths, owned) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func hasLastApplied(obj *unstructured.Unstructured) bool {
+	_, ok := obj.GetAnnotations()[dynamicapply.LastAppliedAnnotation]
+	return ok
+}
+
+// migrateToServerSideApply moves ownership of the fields in the last applied
+// configuration of obj from our 3-way merge updates to server-side apply.
+// The annotation itself is owned by the apply too, so it's removed by the
+// next apply, which doesn't include it.
+//
+// Without this, fields that were applied before the migration, but are no
+// longer desired, would stay around forever.
+func migrateToServerSideApply(client *dynamicclientset.ResourceClient, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	lastApplied, err := dynamicapply.GetLastApplied(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := make(map[string]interface{})
+	var entries []metav1.ManagedFieldsEntry
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == FieldManager && entry.Operation == metav1.ManagedFieldsOperationUpdate && entry.FieldsV1 != nil {
+			fields := make(map[string]interface{})
+			if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+				return nil, fmt.Errorf("can't unmarshal managed fields: %v", err)
+			}
+			mergeFieldSets(updated, fields)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	seeded := filterFieldSet(updated, lastApplied)
+	metadata := childFieldSet(seeded, "metadata")
+	childFieldSet(childFieldSet(metadata, "annotations"), dynamicapply.LastAppliedAnnotation)
+	if updatedMetadata, ok := updated["f:metadata"].(map[string]interface{}); ok {
+		for _, field := range []string{"f:ownerReferences", "f:finalizers"} {
+			if value, ok := updatedMetadata[field]; ok {
+				metadata[field] = value
+			}
+		}
+	}
+	raw, err := json.Marshal(seeded)
+	if err != nil {
+		return nil, fmt.Errorf("can't marshal managed fields: %v", err)
+	}
+	now := metav1.Now()
+	entries = append(entries, metav1.ManagedFieldsEntry{
+		Manager:    FieldManager,
+		Operation:  metav1.ManagedFieldsOperationApply,
+		APIVersion: obj.GetAPIVersion(),
+		Time:       &now,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: raw},
+	})
+
+	klog.InfoS("Migrating to server-side apply", "child", klog.KObj(obj))
+	migrated := obj.DeepCopy()
+	migrated.SetManagedFields(entries)
+	return client.Update(migrated, metav1.UpdateOptions{FieldManager: FieldManager})
+}
+
+// filterFieldSet returns the part of a managed fields set (in FieldsV1 format)
+// for the fields present in applied.
+// Lists are kept or dropped as a whole.
+func filterFieldSet(fields map[string]interface{}, applied interface{}) map[string]interface{} {
+	appliedMap, ok := applied.(map[string]interface{})
+	if !ok {
+		return fields
+	}
+	result := make(map[string]interface{})
+	for key, value := range fields {
+		if key == "." {
+			result[key] = value
+			continue
+		}
+		if !strings.HasPrefix(key, "f:") {
+			continue
+		}
+		appliedValue, ok := appliedMap[strings.TrimPrefix(key, "f:")]
+		if !ok {
+			continue
+		}
+		nested, _ := value.(map[string]interface{})
+		result[key] = filterFieldSet(nested, appliedValue)
+	}
+	return result
+}
+
+// mergeFieldSets adds the fields in src to dst.
+func mergeFieldSets(dst, src map[string]interface{}) {
+	for key, value := range src {
+		child, ok := dst[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			dst[key] = child
+		}
+		nested, _ := value.(map[string]interface{})
+		mergeFieldSets(child, nested)
+	}
+}
+
+// childFieldSet returns the set for field name in fields, adding it if needed.
+func childFieldSet(fields map[string]interface{}, name string) map[string]interface{} {
+	child, ok := fields["f:"+name].(map[string]interface{})
+	if !ok {
+		child = make(map[string]interface{})
+		fields["f:"+name] = child
+	}
+	return child
+}
+
+// fieldSetPaths appends the paths of the fields in a managed fields set to
+// paths. Lists are treated as a single field.
+func fieldSetPaths(fields map[string]interface{}, prefix string, paths *[]string) {
+	for key, value := range fields {
+		if key == "." {
+			continue
+		}
+		if !strings.HasPrefix(key, "f:") {
+			// A list item, so prefix is a list.
+			*paths = append(*paths, prefix)
+			return
+		}
+		path := joinPath(prefix, strings.TrimPrefix(key, "f:"))
+		nested, _ := value.(map[string]interface{})
+		if len(nested) == 0 || (len(nested) == 1 && nested["."] != nil) {
+			*paths = append(*paths, path)
+			continue
+		}
+		fieldSetPaths(nested, path, paths)
+	}
+}
+
+// contentPaths appends the paths of the fields in an object to paths,
+// in the same form as fieldSetPaths.
+func contentPaths(content map[string]interface{}, prefix string, paths *[]string) {
+	for key, value := range content {
+		path := joinPath(prefix, key)
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			contentPaths(nested, path, paths)
+			continue
+		}
+		*paths = append(*paths, path)
+	}
+}
+
+// coversPath returns whether path is in paths, or is above or below one of
+// them.
+func coversPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path || strings.HasPrefix(path, p+".") || strings.HasPrefix(p, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// childApplyConfig returns what we apply for a desired child: the child
+// returned by the hook, claimed with ownerRef (unless it's nil), with our
+// finalizer if needed. Anything we stop including in it is removed by the
+// next apply.
+func childApplyConfig(ownerRef *metav1.OwnerReference, desired *unstructured.Unstructured, namespace string, childFinalizer *ChildFinalizer, addFinalizer bool) *unstructured.Unstructured {
+	config := desired.DeepCopy()
+	if config.GetNamespace() == "" {
+		config.SetNamespace(namespace)
+	}
+	if ownerRef != nil {
+		config.SetOwnerReferences(append(config.GetOwnerReferences(), *ownerRef))
+	}
+	if addFinalizer {
+		dynamicobject.AddFinalizer(config, childFinalizer.Name)
+	}
+	return config
+}
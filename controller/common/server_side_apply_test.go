@@ -0,0 +1,221 @@
+package common
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+func TestServerSideUpToDate(t *testing.T) {
+	desiredJSON := `{
+		"apiVersion": "v1",
+		"kind": "ConfigMap",
+		"metadata": {"name": "test"},
+		"data": {"a": "1"}
+	}`
+	table := []struct {
+		name         string
+		observedJSON string
+		want         bool
+	}{
+		{
+			name: "up to date",
+			observedJSON: `{
+				"apiVersion": "v1",
+				"kind": "ConfigMap",
+				"metadata": {
+					"name": "test",
+					"managedFields": [
+						{"manager": "metacontroller", "operation": "Apply", "fieldsType": "FieldsV1", "fieldsV1": {"f:data": {"f:a": {}}, "f:metadata": {"f:ownerReferences": {"k:{\"uid\":\"1\"}": {".": {}}}}}},
+						{"manager": "kubectl", "operation": "Update", "fieldsType": "FieldsV1", "fieldsV1": {"f:data": {"f:b": {}}}}
+					]
+				},
+				"data": {"a": "1", "b": "2"}
+			}`,
+			want: true,
+		},
+		{
+			name: "value differs",
+			observedJSON: `{
+				"apiVersion": "v1",
+				"kind": "ConfigMap",
+				"metadata": {"name": "test"},
+				"data": {"a": "2"}
+			}`,
+			want: false,
+		},
+		{
+			name: "owns field that's no longer desired",
+			observedJSON: `{
+				"apiVersion": "v1",
+				"kind": "ConfigMap",
+				"metadata": {
+					"name": "test",
+					"managedFields": [
+						{"manager": "metacontroller", "operation": "Apply", "fieldsType": "FieldsV1", "fieldsV1": {"f:data": {"f:a": {}, "f:c": {}}}}
+					]
+				},
+				"data": {"a": "1", "c": "3"}
+			}`,
+			want: false,
+		},
+		{
+			name: "not migrated yet",
+			observedJSON: `{
+				"apiVersion": "v1",
+				"kind": "ConfigMap",
+				"metadata": {
+					"name": "test",
+					"annotations": {"metacontroller.k8s.io/last-applied-configuration": "{\"data\":{\"a\":\"1\"}}"}
+				},
+				"data": {"a": "1"}
+			}`,
+			want: false,
+		},
+	}
+
+	desired := &unstructured.Unstructured{}
+	if err := json.Unmarshal([]byte(desiredJSON), &desired.Object); err != nil {
+		t.Fatalf("can't unmarshal desired: %v", err)
+	}
+	for _, tc := range table {
+		observed := &unstructured.Unstructured{}
+		if err := json.Unmarshal([]byte(tc.observedJSON), &observed.Object); err != nil {
+			t.Fatalf("%v: can't unmarshal observed: %v", tc.name, err)
+		}
+		if got := ServerSideUpToDate(observed, desired); got != tc.want {
+			t.Errorf("%v: ServerSideUpToDate() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFilterFieldSet(t *testing.T) {
+	fieldsJSON := `{
+		"f:metadata": {
+			"f:annotations": {".": {}, "f:a": {}, "f:metacontroller.k8s.io/last-applied-configuration": {}},
+			"f:ownerReferences": {".": {}, "k:{\"uid\":\"1\"}": {".": {}}}
+		},
+		"f:spec": {
+			"f:replicas": {},
+			"f:paused": {},
+			"f:template": {"f:spec": {"f:containers": {"k:{\"name\":\"main\"}": {".": {}, "f:image": {}}}}}
+		}
+	}`
+	appliedJSON := `{
+		"metadata": {"annotations": {"a": "1"}},
+		"spec": {"replicas": 1, "template": {"spec": {"containers": [{"name": "main", "image": "x"}]}}}
+	}`
+	wantJSON := `{
+		"f:metadata": {
+			"f:annotations": {".": {}, "f:a": {}}
+		},
+		"f:spec": {
+			"f:replicas": {},
+			"f:template": {"f:spec": {"f:containers": {"k:{\"name\":\"main\"}": {".": {}, "f:image": {}}}}}
+		}
+	}`
+
+	var fields, applied, want map[string]interface{}
+	if err := json.Unmarshal([]byte(fieldsJSON), &fields); err != nil {
+		t.Fatalf("can't unmarshal fields: %v", err)
+	}
+	if err := json.Unmarshal([]byte(appliedJSON), &applied); err != nil {
+		t.Fatalf("can't unmarshal applied: %v", err)
+	}
+	if err := json.Unmarshal([]byte(wantJSON), &want); err != nil {
+		t.Fatalf("can't unmarshal want: %v", err)
+	}
+	if got := filterFieldSet(fields, applied); !reflect.DeepEqual(got, want) {
+		t.Errorf("filterFieldSet() = %#v, want %#v", got, want)
+	}
+}
+
+func TestServerSideUpdate_retryOnConflict(t *testing.T) {
+	table := []struct {
+		name string
+		// refreshed is the child the API server returns after the conflict.
+		refreshed   string
+		wantPatches int
+		wantResult  bool
+	}{
+		{
+			// Someone else changed another field: apply again on top of
+			// their version.
+			name:        "changed by someone else",
+			refreshed:   `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "default", "uid": "1", "resourceVersion": "2"}, "data": {"key": "old", "other": "theirs"}}`,
+			wantPatches: 2,
+			wantResult:  true,
+		},
+		{
+			// Someone else already made our change.
+			name:        "already up to date",
+			refreshed:   `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "default", "uid": "1", "resourceVersion": "2"}, "data": {"key": "new"}}`,
+			wantPatches: 1,
+		},
+	}
+
+	for _, tc := range table {
+		var patchVersions []string
+		apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.Method {
+			case http.MethodGet:
+				w.Write([]byte(tc.refreshed))
+			case http.MethodPatch:
+				body, _ := ioutil.ReadAll(r.Body)
+				applied := &unstructured.Unstructured{}
+				if err := json.Unmarshal(body, &applied.Object); err != nil {
+					t.Errorf("%v: can't decode apply patch: %v", tc.name, err)
+				}
+				patchVersions = append(patchVersions, applied.GetResourceVersion())
+				if len(patchVersions) == 1 {
+					w.WriteHeader(http.StatusConflict)
+					w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "Conflict", "code": 409}`))
+					return
+				}
+				applied.SetResourceVersion("3")
+				data, _ := json.Marshal(applied)
+				w.Write(data)
+			}
+		}))
+		client := newTestResourceClient(t, apiServer.URL, "v1", "configmaps").Namespace("default")
+
+		oldObj := &unstructured.Unstructured{}
+		if err := json.Unmarshal([]byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "default", "uid": "1", "resourceVersion": "1"}, "data": {"key": "old"}}`), &oldObj.Object); err != nil {
+			t.Fatal(err)
+		}
+		desired := &unstructured.Unstructured{}
+		if err := json.Unmarshal([]byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "default"}, "data": {"key": "new"}}`), &desired.Object); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := serverSideUpdate(logr.Discard(), client, oldObj, desired, desired)
+		apiServer.Close()
+		if err != nil {
+			t.Errorf("%v: serverSideUpdate() = %v, want no error", tc.name, err)
+			continue
+		}
+		if got := len(patchVersions); got != tc.wantPatches {
+			t.Errorf("%v: got %v patches, want %v", tc.name, got, tc.wantPatches)
+			continue
+		}
+		if patchVersions[0] != "1" {
+			t.Errorf("%v: first patch has resourceVersion %q, want %q", tc.name, patchVersions[0], "1")
+		}
+		if tc.wantPatches > 1 && patchVersions[1] != "2" {
+			t.Errorf("%v: retried patch has resourceVersion %q, want the refreshed %q", tc.name, patchVersions[1], "2")
+		}
+		if got := result != nil; got != tc.wantResult {
+			t.Errorf("%v: serverSideUpdate() returned a child: %v, want %v", tc.name, got, tc.wantResult)
+		}
+		if result != nil && result.GetResourceVersion() != "3" {
+			t.Errorf("%v: serverSideUpdate() resourceVersion = %q, want %q", tc.name, result.GetResourceVersion(), "3")
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/workqueue"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+const (
+	// ReasonStarted is the reason of the Ready condition of a started
+	// controller.
+	ReasonStarted = "Started"
+	// ReasonWaitingForResources is the reason of the Ready condition of a
+	// controller whose parent or child resources aren't served yet.
+	ReasonWaitingForResources = "WaitingForResources"
+	// ReasonStartFailed is the reason of the Ready condition of a controller
+	// that can't be started for another reason, such as an invalid spec.
+	ReasonStartFailed = "StartFailed"
+)
+
+// WaitingForResourcesError is returned when a controller can't start yet
+// because discovery hasn't synced, or one of its resources isn't served yet,
+// for example because its CRD was just created.
+type WaitingForResourcesError struct {
+	Message string
+}
+
+func (e *WaitingForResourcesError) Error() string {
+	return e.Message
+}
+
+// IsWaitingForResources returns whether err is a WaitingForResourcesError.
+func IsWaitingForResources(err error) bool {
+	var waitErr *WaitingForResourcesError
+	return errors.As(err, &waitErr)
+}
+
+// NewWaitingRateLimiter returns the rate limiter for retrying controllers
+// that wait for their resources. It backs off exponentially from one second to
+// one minute, so controllers start soon after their CRDs are created even if
+// metacontroller started long before.
+func NewWaitingRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(time.Second, time.Minute)
+}
+
+// crdVersions are the versions of apiextensions.k8s.io to look up CRDs with,
+// in order of preference.
+var crdVersions = []string{"apiextensions.k8s.io/v1", "apiextensions.k8s.io/v1beta1"}
+
+// CheckResources returns a WaitingForResourcesError unless discovery has
+// synced and every resource in rules is served, and Established if it's
+// defined by a CRD.
+func CheckResources(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, rules []v1alpha1.ResourceRule) error {
+	if !resources.HasSynced() {
+		return &WaitingForResourcesError{Message: "waiting for discovery to sync"}
+	}
+	var crdClient *dynamicclientset.ResourceClient
+	for _, apiVersion := range crdVersions {
+		if client, err := dynClient.Resource(apiVersion, "customresourcedefinitions"); err == nil {
+			crdClient = client
+			break
+		}
+	}
+	for _, rule := range rules {
+		resource := resources.Get(rule.APIVersion, rule.Resource)
+		if resource == nil {
+			return &WaitingForResourcesError{Message: fmt.Sprintf("waiting for resource %q in apiVersion %q to be served", rule.Resource, rule.APIVersion)}
+		}
+		if crdClient == nil || resource.Group == "" {
+			continue
+		}
+		crd, err := crdClient.Get(resource.Name+"."+resource.Group, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			// The resource isn't defined by a CRD.
+			continue
+		}
+		if err != nil {
+			return &WaitingForResourcesError{Message: fmt.Sprintf("can't get CRD of resource %q in apiVersion %q: %v", rule.Resource, rule.APIVersion, err)}
+		}
+		if !crdEstablished(crd) {
+			return &WaitingForResourcesError{Message: fmt.Sprintf("waiting for CRD %q to be Established", crd.GetName())}
+		}
+	}
+	return nil
+}
+
+func crdEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.UnstructuredContent(), "status", "conditions")
+	for _, c := range conditions {
+		if c, ok := c.(map[string]interface{}); ok && c["type"] == "Established" {
+			return c["status"] == "True"
+		}
+	}
+	return false
+}
+
+// SetControllerReadyCondition sets the Ready condition of a CompositeController
+// or DecoratorController, whose current conditions are conditions. It doesn't
+// write anything if the condition is already up to date.
+func SetControllerReadyCondition(dynClient *dynamicclientset.Clientset, resource string, controller metav1.Object, conditions []v1alpha1.ControllerCondition, ready bool, reason, message string) error {
+	status := "False"
+	if ready {
+		status = "True"
+	}
+	for _, c := range conditions {
+		if c.Type == v1alpha1.ControllerConditionReady && c.Status == status && c.Reason == reason && c.Message == message {
+			// Nothing to do.
+			return nil
+		}
+	}
+	client, err := dynClient.Resource(v1alpha1.SchemeGroupVersion.String(), resource)
+	if err != nil {
+		return err
+	}
+	orig := &unstructured.Unstructured{}
+	orig.SetName(controller.GetName())
+	orig.SetUID(controller.GetUID())
+	_, err = client.AtomicStatusUpdate(orig, func(obj *unstructured.Unstructured) bool {
+		current, _, _ := unstructured.NestedSlice(obj.UnstructuredContent(), "status", "conditions")
+		updated := make([]interface{}, 0, len(current)+1)
+		lastTransitionTime := time.Now().UTC().Format(time.RFC3339)
+		for _, c := range current {
+			if c, ok := c.(map[string]interface{}); ok && c["type"] == v1alpha1.ControllerConditionReady {
+				if c["status"] == status && c["reason"] == reason && c["message"] == message {
+					// Nothing to do.
+					return false
+				}
+				if c["status"] == status {
+					if t, ok := c["lastTransitionTime"].(string); ok {
+						lastTransitionTime = t
+					}
+				}
+				continue
+			}
+			updated = append(updated, c)
+		}
+		updated = append(updated, map[string]interface{}{
+			"type":               v1alpha1.ControllerConditionReady,
+			"status":             status,
+			"reason":             reason,
+			"message":            message,
+			"lastTransitionTime": lastTransitionTime,
+		})
+		return unstructured.SetNestedSlice(obj.UnstructuredContent(), updated, "status", "conditions") == nil
+	})
+	return err
+}
+
+var readiness = struct {
+	mutex sync.Mutex
+	// discoverySynced reports whether discovery has synced. Readiness waits
+	// for it once it's set.
+	discoverySynced func() bool
+	// waiting maps each controller that isn't started yet, as
+	// "<kind>/<name>", to why.
+	waiting map[string]string
+}{waiting: make(map[string]string)}
+
+// InitReadiness makes readiness wait for discovery to sync.
+func InitReadiness(discoverySynced func() bool) {
+	readiness.mutex.Lock()
+	defer readiness.mutex.Unlock()
+	readiness.discoverySynced = discoverySynced
+}
+
+// SetWaiting delays readiness until the controller of the given kind and name
+// is started, and reports message as the reason it's waiting. An empty name
+// stands for the metacontroller of kind, until its informers have synced.
+func SetWaiting(kind, name, message string) {
+	readiness.mutex.Lock()
+	defer readiness.mutex.Unlock()
+	readiness.waiting[kind+"/"+name] = message
+}
+
+// SetNotWaiting stops the controller of the given kind and name from delaying
+// readiness, once it's started, fails to start for another reason than its
+// resources, or is deleted.
+func SetNotWaiting(kind, name string) {
+	readiness.mutex.Lock()
+	defer readiness.mutex.Unlock()
+	delete(readiness.waiting, kind+"/"+name)
+}
+
+// WaitingControllers returns the names of the controllers of the given kind
+// that delay readiness, such as those waiting for their resources.
+func WaitingControllers(kind string) []string {
+	readiness.mutex.Lock()
+	defer readiness.mutex.Unlock()
+	var names []string
+	for controller := range readiness.waiting {
+		if name := strings.TrimPrefix(controller, kind+"/"); name != controller && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// notReady returns why metacontroller isn't ready yet, sorted, or nothing if
+// it's ready.
+func notReady() []string {
+	readiness.mutex.Lock()
+	defer readiness.mutex.Unlock()
+	var reasons []string
+	if readiness.discoverySynced != nil && !readiness.discoverySynced() {
+		reasons = append(reasons, "discovery: waiting to sync")
+	}
+	for controller, message := range readiness.waiting {
+		reasons = append(reasons, strings.TrimSuffix(controller, "/")+": "+message)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// ServeReadyz serves the readiness of metacontroller: OK once discovery has
+// synced and every controller is started, 503 with the reasons otherwise.
+func ServeReadyz(w http.ResponseWriter, r *http.Request) {
+	reasons := notReady()
+	if len(reasons) == 0 {
+		w.Write([]byte("ok\n"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(reasons)
+}
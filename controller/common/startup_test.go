@@ -0,0 +1,84 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCRDEstablished(t *testing.T) {
+	table := []struct {
+		name       string
+		conditions []interface{}
+		want       bool
+	}{
+		{
+			name: "no conditions",
+		},
+		{
+			name: "established",
+			conditions: []interface{}{
+				map[string]interface{}{"type": "NamesAccepted", "status": "True"},
+				map[string]interface{}{"type": "Established", "status": "True"},
+			},
+			want: true,
+		},
+		{
+			name: "not established",
+			conditions: []interface{}{
+				map[string]interface{}{"type": "Established", "status": "False"},
+			},
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			crd := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			if tc.conditions != nil {
+				unstructured.SetNestedSlice(crd.Object, tc.conditions, "status", "conditions")
+			}
+			if got := crdEstablished(crd); got != tc.want {
+				t.Errorf("crdEstablished() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestServeReadyz(t *testing.T) {
+	discoverySynced := false
+	InitReadiness(func() bool { return discoverySynced })
+	defer InitReadiness(nil)
+
+	readyz := func() (int, string) {
+		w := httptest.NewRecorder()
+		ServeReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code, w.Body.String()
+	}
+
+	SetWaiting("CompositeController", "", "waiting for informer caches to sync")
+	if code, body := readyz(); code != http.StatusServiceUnavailable || !strings.Contains(body, "discovery") {
+		t.Errorf("readyz = %v %q, want 503 waiting for discovery", code, body)
+	}
+
+	discoverySynced = true
+	SetNotWaiting("CompositeController", "")
+	SetWaiting("CompositeController", "catset-controller", "waiting for CRD")
+	if code, body := readyz(); code != http.StatusServiceUnavailable || !strings.Contains(body, "CompositeController/catset-controller: waiting for CRD") {
+		t.Errorf("readyz = %v %q, want 503 waiting for catset-controller", code, body)
+	}
+
+	if got := WaitingControllers("CompositeController"); len(got) != 1 || got[0] != "catset-controller" {
+		t.Errorf("WaitingControllers() = %v, want [catset-controller]", got)
+	}
+	if got := WaitingControllers("DecoratorController"); len(got) != 0 {
+		t.Errorf("WaitingControllers() = %v, want none", got)
+	}
+
+	SetNotWaiting("CompositeController", "catset-controller")
+	if code, body := readyz(); code != http.StatusOK {
+		t.Errorf("readyz = %v %q, want 200", code, body)
+	}
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// conditionTimestamps are the fields of conditions that only tell when they
+// were last changed or checked.
+var conditionTimestamps = []string{"lastTransitionTime", "lastUpdateTime", "lastHeartbeatTime", "lastProbeTime"}
+
+// StatusEqual returns whether the statuses a and b are semantically the same,
+// so there's no point writing b over a: they're SemanticDeepEqual once the
+// conditions in them are sorted by type, and their timestamps are left out.
+func StatusEqual(a, b interface{}) bool {
+	return SemanticDeepEqual(normalizeStatus(a), normalizeStatus(b))
+}
+
+// normalizeStatus returns a copy of a status value with its conditions sorted
+// by type and without timestamps, at any depth.
+func normalizeStatus(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			if conditions, ok := field.([]interface{}); ok && key == "conditions" {
+				normalized[key] = normalizeConditions(conditions)
+				continue
+			}
+			normalized[key] = normalizeStatus(field)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(value))
+		for i, item := range value {
+			normalized[i] = normalizeStatus(item)
+		}
+		return normalized
+	}
+	return value
+}
+
+// normalizeConditions returns a copy of conditions sorted by type, without
+// timestamps. Lists that aren't made of objects are left as they are.
+func normalizeConditions(conditions []interface{}) []interface{} {
+	normalized := make([]interface{}, 0, len(conditions))
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok {
+			return conditions
+		}
+		copied := normalizeStatus(condition).(map[string]interface{})
+		for _, field := range conditionTimestamps {
+			delete(copied, field)
+		}
+		normalized = append(normalized, copied)
+	}
+	sort.SliceStable(normalized, func(i, j int) bool {
+		return fmt.Sprint(normalized[i].(map[string]interface{})["type"]) < fmt.Sprint(normalized[j].(map[string]interface{})["type"])
+	})
+	return normalized
+}
+
+// StatusWrites coalesces the status writes of parents that come in quick
+// succession, by remembering when the status of each parent was last written.
+type StatusWrites struct {
+	period time.Duration
+
+	mutex sync.Mutex
+	last  map[string]time.Time
+}
+
+// NewStatusWrites returns a StatusWrites that lets the status of a parent be
+// written at most once per periodSeconds, or every time if it's 0.
+func NewStatusWrites(periodSeconds int32) *StatusWrites {
+	return &StatusWrites{
+		period: time.Duration(periodSeconds) * time.Second,
+		last:   make(map[string]time.Time),
+	}
+}
+
+// Delay returns how long to wait before writing the status of the parent with
+// the given queue key, or 0 if it can be written now.
+func (w *StatusWrites) Delay(key string) time.Duration {
+	if w.period <= 0 {
+		return 0
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	last, ok := w.last[key]
+	if !ok {
+		return 0
+	}
+	if delay := w.period - time.Since(last); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// Written records that the status of the parent with the given queue key was
+// just written.
+func (w *StatusWrites) Written(key string) {
+	if w.period <= 0 {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.last[key] = time.Now()
+}
+
+// Forget drops when the status of the parent with the given queue key was
+// last written, because it's gone.
+func (w *StatusWrites) Forget(key string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	delete(w.last, key)
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatusEqual(t *testing.T) {
+	condition := func(conditionType, status, lastTransitionTime string) map[string]interface{} {
+		return map[string]interface{}{"type": conditionType, "status": status, "lastTransitionTime": lastTransitionTime}
+	}
+	status := func(conditions ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"replicas": int64(1), "conditions": conditions}
+	}
+
+	table := []struct {
+		name string
+		a, b interface{}
+		want bool
+	}{
+		{
+			name: "same",
+			a:    status(condition("Ready", "True", "2021-01-01T00:00:00Z")),
+			b:    status(condition("Ready", "True", "2021-01-01T00:00:00Z")),
+			want: true,
+		},
+		{
+			name: "reordered conditions",
+			a:    status(condition("Ready", "True", "t1"), condition("Synced", "True", "t1")),
+			b:    status(condition("Synced", "True", "t1"), condition("Ready", "True", "t1")),
+			want: true,
+		},
+		{
+			name: "timestamp only",
+			a:    status(condition("Ready", "True", "2021-01-01T00:00:00Z")),
+			b:    status(condition("Ready", "True", "2021-01-02T00:00:00Z")),
+			want: true,
+		},
+		{
+			name: "condition changed",
+			a:    status(condition("Ready", "True", "2021-01-01T00:00:00Z")),
+			b:    status(condition("Ready", "False", "2021-01-02T00:00:00Z")),
+			want: false,
+		},
+		{
+			name: "other field changed",
+			a:    map[string]interface{}{"replicas": int64(1)},
+			b:    map[string]interface{}{"replicas": int64(2)},
+			want: false,
+		},
+		{
+			name: "numbers",
+			a:    map[string]interface{}{"replicas": int64(1)},
+			b:    map[string]interface{}{"replicas": float64(1)},
+			want: true,
+		},
+	}
+	for _, tc := range table {
+		if got := StatusEqual(tc.a, tc.b); got != tc.want {
+			t.Errorf("%v: StatusEqual() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStatusWrites(t *testing.T) {
+	writes := NewStatusWrites(60)
+	if delay := writes.Delay("ns/a"); delay != 0 {
+		t.Errorf("Delay() = %v before any write, want 0", delay)
+	}
+	writes.Written("ns/a")
+	if delay := writes.Delay("ns/a"); delay <= 0 || delay > time.Minute {
+		t.Errorf("Delay() = %v right after a write, want up to a minute", delay)
+	}
+	if delay := writes.Delay("ns/b"); delay != 0 {
+		t.Errorf("Delay() = %v for another parent, want 0", delay)
+	}
+	writes.Forget("ns/a")
+	if delay := writes.Delay("ns/a"); delay != 0 {
+		t.Errorf("Delay() = %v after Forget(), want 0", delay)
+	}
+
+	disabled := NewStatusWrites(0)
+	disabled.Written("ns/a")
+	if delay := disabled.Delay("ns/a"); delay != 0 {
+		t.Errorf("Delay() = %v without coalescing, want 0", delay)
+	}
+}
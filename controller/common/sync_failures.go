@@ -0,0 +1,311 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	"metacontroller.io/events"
+	"metacontroller.io/hooks"
+	"metacontroller.io/notify"
+)
+
+const (
+	// SyncFailuresAnnotation is set on parents, if requested, to the number
+	// of consecutive times their sync failed.
+	SyncFailuresAnnotation = "metacontroller.k8s.io/sync-failures"
+	// NextSyncRetryAnnotation is set on parents, if requested, to the time
+	// their failed sync is retried, in RFC 3339 format.
+	NextSyncRetryAnnotation = "metacontroller.k8s.io/next-sync-retry"
+)
+
+var (
+	parentSyncFailures = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      "metacontroller",
+			Name:           "parent_sync_failures_total",
+			Help:           "Number of failed parent syncs.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller_kind", "controller"},
+	)
+	failingParents = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "metacontroller",
+			Name:           "failing_parents",
+			Help:           "Number of parents whose last sync failed, and are waiting to be retried.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller_kind", "controller"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(parentSyncFailures, failingParents)
+}
+
+// SyncRetries tracks the consecutive sync failures of the parents of a
+// controller, and when they're retried. Retries back off exponentially,
+// like the default rate limiter of work queues.
+type SyncRetries struct {
+	controllerKind, controller string
+	rateLimiter                workqueue.RateLimiter
+	// backoff is the per-parent part of rateLimiter, which can be replayed
+	// to resume the backoff of a parent.
+	backoff workqueue.RateLimiter
+
+	mutex    sync.Mutex
+	failures map[string]int
+	// permanent maps the parents whose sync failed permanently to their
+	// generation at the time.
+	permanent map[string]int64
+	// crashes tracks the consecutive syncs of parents that crashed or timed
+	// out the hook.
+	crashes     map[string]*crashStreak
+	quarantined map[string]*quarantine
+	deadLetters map[string]DeadLetter
+	// lastSuccess maps parents to when they last synced successfully.
+	lastSuccess map[string]time.Time
+	// responseHashes maps parents to the hash of the last applied response.
+	responseHashes map[string]string
+}
+
+// NewSyncRetries returns a SyncRetries for the controller with the given kind
+// and name.
+func NewSyncRetries(controllerKind, controller string) *SyncRetries {
+	backoff := workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second)
+	return &SyncRetries{
+		controllerKind: controllerKind,
+		controller:     controller,
+		rateLimiter:    workqueue.NewMaxOfRateLimiter(backoff, workqueue.DefaultControllerRateLimiter()),
+		backoff:        backoff,
+		failures:       make(map[string]int),
+		permanent:      make(map[string]int64),
+		crashes:        make(map[string]*crashStreak),
+		quarantined:    make(map[string]*quarantine),
+		deadLetters:    make(map[string]DeadLetter),
+		lastSuccess:    make(map[string]time.Time),
+		responseHashes: make(map[string]string),
+	}
+}
+
+// Failed records a failed sync of the parent with the given queue key.
+// It returns the number of consecutive failures, and how long to wait before
+// retrying.
+func (r *SyncRetries) Failed(key string) (int, time.Duration) {
+	delay := r.rateLimiter.When(key)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failures[key]++
+	parentSyncFailures.WithLabelValues(r.controllerKind, r.controller).Inc()
+	failingParents.WithLabelValues(r.controllerKind, r.controller).Set(float64(len(r.failures)))
+	return r.failures[key], delay
+}
+
+// Succeeded forgets the failures of the parent with the given queue key,
+// because it synced or is gone. It returns the number of consecutive
+// failures it had.
+func (r *SyncRetries) Succeeded(key string) int {
+	r.rateLimiter.Forget(key)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.permanent, key)
+	delete(r.crashes, key)
+	r.release(key)
+	r.removeDeadLetter(key)
+	failures, ok := r.failures[key]
+	if !ok {
+		return 0
+	}
+	delete(r.failures, key)
+	failingParents.WithLabelValues(r.controllerKind, r.controller).Set(float64(len(r.failures)))
+	return failures
+}
+
+// maxResumedBackoff bounds how many failures are replayed when resuming the
+// backoff of a parent, past which the backoff is at its maximum anyway.
+const maxResumedBackoff = 32
+
+// Resume restores the failures of the parent with the given queue key from
+// its sync failure annotations, unless they're already known, such as after
+// Metacontroller restarted or another replica took over the controller.
+// The backoff of the parent continues from there, instead of starting over.
+// It returns how long to wait until the retry that was scheduled, if any.
+func (r *SyncRetries) Resume(key string, parent *unstructured.Unstructured) time.Duration {
+	annotations := parent.GetAnnotations()
+	failures, err := strconv.Atoi(annotations[SyncFailuresAnnotation])
+	if err != nil || failures <= 0 {
+		return 0
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.failures[key]; ok {
+		return 0
+	}
+	r.failures[key] = failures
+	failingParents.WithLabelValues(r.controllerKind, r.controller).Set(float64(len(r.failures)))
+	for i := 0; i < failures && i < maxResumedBackoff; i++ {
+		r.backoff.When(key)
+	}
+
+	next, err := time.Parse(time.RFC3339, annotations[NextSyncRetryAnnotation])
+	if err != nil {
+		return 0
+	}
+	if delay := time.Until(next); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// Notify sends a failure notification about parent, which just failed to
+// sync failures times in a row, if that reaches a notification threshold.
+func (r *SyncRetries) Notify(parent *unstructured.Unstructured, failures int, syncErr error) {
+	r.mutex.Lock()
+	failing := len(r.failures)
+	r.mutex.Unlock()
+	notify.SyncFailed(r.controllerKind, r.controller, parent, failures, failing, syncErr)
+}
+
+// Failing returns the number of parents whose last sync failed, among those
+// whose queue key matches filter.
+func (r *SyncRetries) Failing(filter func(key string) bool) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	count := 0
+	for key := range r.failures {
+		if filter(key) {
+			count++
+		}
+	}
+	return count
+}
+
+// Stop forgets all failures, when the controller stops.
+func (r *SyncRetries) Stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failures = make(map[string]int)
+	r.permanent = make(map[string]int64)
+	r.lastSuccess = make(map[string]time.Time)
+	r.responseHashes = make(map[string]string)
+	r.forgetQuarantine()
+	r.forgetDeadLetters()
+	failingParents.DeleteLabelValues(r.controllerKind, r.controller)
+}
+
+// ReportSyncFailure records a Warning event on parent saying how many times
+// in a row its sync failed, and when it's retried. If annotate is true,
+// this is also recorded in the annotations of parent.
+func ReportSyncFailure(eventRecorder record.EventRecorder, parentClient *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, failures int, delay time.Duration, syncErr error, annotate bool) {
+	eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonSyncRetry,
+		"Sync failed %v time(s) in a row, retrying in %v: %v", failures, delay.Round(time.Millisecond), syncErr)
+	if !annotate {
+		return
+	}
+	err := patchSyncFailureAnnotations(parentClient, parent, map[string]interface{}{
+		SyncFailuresAnnotation:  strconv.Itoa(failures),
+		NextSyncRetryAnnotation: time.Now().Add(delay).UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		klog.ErrorS(err, "Can't annotate sync failures", "parent", klog.KObj(parent))
+	}
+}
+
+// SyncRetryDelay returns how long to wait before retrying a sync that failed
+// with err, given the delay of the usual backoff, or false if the sync can't
+// succeed by being retried.
+func SyncRetryDelay(err error, backoff time.Duration) (time.Duration, bool) {
+	if IsPermanent(err) {
+		return 0, false
+	}
+	if hookErr := hooks.AsError(err); hookErr != nil && hookErr.RequeueAfter() > 0 {
+		return hookErr.RequeueAfter(), true
+	}
+	return backoff, true
+}
+
+// ReportPermanentSyncFailure records a Warning event on parent saying its
+// sync failed, and won't be retried until its generation changes.
+func ReportPermanentSyncFailure(eventRecorder record.EventRecorder, parent *unstructured.Unstructured, syncErr error) {
+	eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonSyncFailed, "Sync failed, not retrying until the spec changes: %v", syncErr)
+}
+
+// ReportHookError records a Warning event on parent for hookErr, an error
+// the hook only wants reported, without failing the sync.
+func ReportHookError(eventRecorder record.EventRecorder, parent *unstructured.Unstructured, hookErr *hooks.Error) {
+	eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonHookError, "Hook reported an error: %v", hookErr)
+}
+
+// ClearSyncFailures removes the sync failure annotations from parent, if it
+// has any.
+func ClearSyncFailures(parentClient *dynamicclientset.ResourceClient, parent *unstructured.Unstructured) error {
+	annotations := parent.GetAnnotations()
+	_, hasFailures := annotations[SyncFailuresAnnotation]
+	_, hasRetry := annotations[NextSyncRetryAnnotation]
+	if !hasFailures && !hasRetry {
+		return nil
+	}
+	err := patchSyncFailureAnnotations(parentClient, parent, map[string]interface{}{
+		SyncFailuresAnnotation:  nil,
+		NextSyncRetryAnnotation: nil,
+	})
+	if err != nil {
+		return fmt.Errorf("can't clear sync failures of %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	return nil
+}
+
+func patchSyncFailureAnnotations(parentClient *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, annotations map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = parentClient.Namespace(parent.GetNamespace()).Patch(parent.GetName(), types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+	return err
+}
+
+// OnlySyncFailuresChanged returns whether the only change from old to cur is
+// to the sync failure or inventory annotations, so the update doesn't need a
+// sync, which would defeat the retry backoff.
+func OnlySyncFailuresChanged(old, cur *unstructured.Unstructured) bool {
+	if old.GetResourceVersion() == cur.GetResourceVersion() {
+		// This is a resync, not an update.
+		return false
+	}
+	strip := func(obj *unstructured.Unstructured) map[string]interface{} {
+		obj = obj.DeepCopy()
+		content := obj.UnstructuredContent()
+		unstructured.RemoveNestedField(content, "metadata", "resourceVersion")
+		unstructured.RemoveNestedField(content, "metadata", "managedFields")
+		unstructured.RemoveNestedField(content, "metadata", "annotations", SyncFailuresAnnotation)
+		unstructured.RemoveNestedField(content, "metadata", "annotations", NextSyncRetryAnnotation)
+		unstructured.RemoveNestedField(content, "metadata", "annotations", InventoryAnnotation)
+		if len(obj.GetAnnotations()) == 0 {
+			unstructured.RemoveNestedField(content, "metadata", "annotations")
+		}
+		return content
+	}
+	return reflect.DeepEqual(strip(old), strip(cur))
+}
@@ -0,0 +1,137 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSyncRetries(t *testing.T) {
+	retries := NewSyncRetries("CompositeController", "test")
+	defer retries.Stop()
+
+	failures, first := retries.Failed("ns/a")
+	if failures != 1 {
+		t.Errorf("Failed() = %v failures, want 1", failures)
+	}
+	failures, second := retries.Failed("ns/a")
+	if failures != 2 {
+		t.Errorf("Failed() = %v failures, want 2", failures)
+	}
+	if second <= first {
+		t.Errorf("Failed() delay = %v after %v, want it to back off", second, first)
+	}
+	if failures, _ := retries.Failed("ns/b"); failures != 1 {
+		t.Errorf("Failed() = %v failures for another parent, want 1", failures)
+	}
+
+	if got := retries.Succeeded("ns/a"); got != 2 {
+		t.Errorf("Succeeded() = %v, want 2", got)
+	}
+	if got := retries.Succeeded("ns/a"); got != 0 {
+		t.Errorf("Succeeded() = %v after success, want 0", got)
+	}
+	if failures, delay := retries.Failed("ns/a"); failures != 1 || delay != first {
+		t.Errorf("Failed() = %v, %v after success, want 1, %v", failures, delay, first)
+	}
+}
+
+func TestSyncRetriesResume(t *testing.T) {
+	retries := NewSyncRetries("CompositeController", "test")
+	defer retries.Stop()
+
+	parent := &unstructured.Unstructured{}
+	next := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+	parent.SetAnnotations(map[string]string{
+		SyncFailuresAnnotation:  "4",
+		NextSyncRetryAnnotation: next,
+	})
+	if delay := retries.Resume("ns/a", parent); delay <= 0 || delay > time.Minute {
+		t.Errorf("Resume() = %v, want the time until the scheduled retry", delay)
+	}
+	if failures, delay := retries.Failed("ns/a"); failures != 5 || delay != 80*time.Millisecond {
+		t.Errorf("Failed() = %v, %v after Resume(), want 5, 80ms", failures, delay)
+	}
+	// Failures that are already known aren't resumed again.
+	if delay := retries.Resume("ns/a", parent); delay != 0 {
+		t.Errorf("Resume() = %v for a known parent, want 0", delay)
+	}
+	if delay := retries.Resume("ns/b", &unstructured.Unstructured{}); delay != 0 {
+		t.Errorf("Resume() = %v without annotations, want 0", delay)
+	}
+}
+
+func TestOnlySyncFailuresChanged(t *testing.T) {
+	parent := func(resourceVersion string, annotations map[string]string, replicas int64) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("example.com/v1")
+		obj.SetKind("Thing")
+		obj.SetName("test")
+		obj.SetResourceVersion(resourceVersion)
+		if annotations != nil {
+			obj.SetAnnotations(annotations)
+		}
+		unstructured.SetNestedField(obj.Object, replicas, "spec", "replicas")
+		return obj
+	}
+	failing := map[string]string{
+		SyncFailuresAnnotation:  "3",
+		NextSyncRetryAnnotation: "2021-01-01T00:00:00Z",
+	}
+
+	table := []struct {
+		name     string
+		old, cur *unstructured.Unstructured
+		want     bool
+	}{
+		{
+			name: "annotations added",
+			old:  parent("1", nil, 1),
+			cur:  parent("2", failing, 1),
+			want: true,
+		},
+		{
+			name: "annotations removed",
+			old:  parent("1", failing, 1),
+			cur:  parent("2", map[string]string{}, 1),
+			want: true,
+		},
+		{
+			name: "other annotations kept",
+			old:  parent("1", map[string]string{"a": "1"}, 1),
+			cur:  parent("2", map[string]string{"a": "1", SyncFailuresAnnotation: "1"}, 1),
+			want: true,
+		},
+		{
+			name: "inventory changed",
+			old:  parent("1", map[string]string{InventoryAnnotation: "[]"}, 1),
+			cur:  parent("2", map[string]string{InventoryAnnotation: `[{"apiVersion":"v1","kind":"ConfigMap","name":"a"}]`}, 1),
+			want: true,
+		},
+		{
+			name: "spec changed too",
+			old:  parent("1", nil, 1),
+			cur:  parent("2", failing, 2),
+			want: false,
+		},
+		{
+			name: "other annotation changed",
+			old:  parent("1", map[string]string{"a": "1"}, 1),
+			cur:  parent("2", map[string]string{"a": "2"}, 1),
+			want: false,
+		},
+		{
+			name: "resync",
+			old:  parent("1", failing, 1),
+			cur:  parent("1", failing, 1),
+			want: false,
+		},
+	}
+
+	for _, tc := range table {
+		if got := OnlySyncFailuresChanged(tc.old, tc.cur); got != tc.want {
+			t.Errorf("%v: OnlySyncFailuresChanged() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
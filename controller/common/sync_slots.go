@@ -0,0 +1,112 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var syncSlotWait = metrics.NewHistogramVec(
+	&metrics.HistogramOpts{
+		Namespace:      "metacontroller",
+		Name:           "sync_slot_wait_seconds",
+		Help:           "How long syncs waited for one of the --max-concurrent-syncs slots shared by all controllers.",
+		Buckets:        []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10, 30, 60},
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"controller_kind", "controller"},
+)
+
+func init() {
+	legacyregistry.MustRegister(syncSlotWait)
+}
+
+// SyncSlotOptions configures how many syncs all controllers can run at the
+// same time.
+type SyncSlotOptions struct {
+	// MaxConcurrentSyncs is how many syncs can run at the same time, across
+	// all controllers. Zero means no limit.
+	MaxConcurrentSyncs int
+}
+
+// syncSlots shares a limited number of sync slots between controllers.
+// When syncs of several controllers are waiting for a slot, the controllers
+// take turns, so one with many parents can't starve the others, no matter
+// how many of its syncs are waiting.
+type syncSlots struct {
+	mutex sync.Mutex
+	max   int
+	used  int
+	// turns holds the controllers with waiting syncs, in the order they get
+	// their next slot.
+	turns []string
+	// waiting maps each controller in turns to its waiting syncs, oldest
+	// first.
+	waiting map[string][]chan struct{}
+}
+
+var slots = &syncSlots{waiting: make(map[string][]chan struct{})}
+
+// InitSyncSlots configures the sync slots shared by all controllers.
+func InitSyncSlots(options SyncSlotOptions) {
+	slots.mutex.Lock()
+	defer slots.mutex.Unlock()
+	slots.max = options.MaxConcurrentSyncs
+}
+
+// AcquireSyncSlot waits for a free sync slot for the controller with the
+// given kind and name, if they're limited. It returns a function to call
+// once the sync is done, to release the slot.
+func AcquireSyncSlot(controllerKind, controller string) (release func()) {
+	return slots.acquire(controllerKind, controller)
+}
+
+func (s *syncSlots) acquire(controllerKind, controller string) func() {
+	s.mutex.Lock()
+	if s.max <= 0 {
+		s.mutex.Unlock()
+		return func() {}
+	}
+	start := time.Now()
+	if s.used < s.max && len(s.turns) == 0 {
+		s.used++
+		s.mutex.Unlock()
+		syncSlotWait.WithLabelValues(controllerKind, controller).Observe(0)
+		return s.release
+	}
+	key := controllerKind + "/" + controller
+	granted := make(chan struct{})
+	if len(s.waiting[key]) == 0 {
+		s.turns = append(s.turns, key)
+	}
+	s.waiting[key] = append(s.waiting[key], granted)
+	s.mutex.Unlock()
+
+	<-granted
+	syncSlotWait.WithLabelValues(controllerKind, controller).Observe(time.Since(start).Seconds())
+	return s.release
+}
+
+// release hands the slot of a finished sync over to the controller whose
+// turn it is, if any sync is waiting.
+func (s *syncSlots) release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.turns) == 0 {
+		s.used--
+		return
+	}
+	key := s.turns[0]
+	s.turns = s.turns[1:]
+	granted := s.waiting[key][0]
+	if waiting := s.waiting[key][1:]; len(waiting) > 0 {
+		// Wait for the next turn of the controller.
+		s.waiting[key] = waiting
+		s.turns = append(s.turns, key)
+	} else {
+		delete(s.waiting, key)
+	}
+	close(granted)
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyncSlotsTakeTurns(t *testing.T) {
+	s := &syncSlots{max: 1, waiting: make(map[string][]chan struct{})}
+	release := s.acquire("CompositeController", "busy")
+
+	waiters := func() int {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		count := 0
+		for _, waiting := range s.waiting {
+			count += len(waiting)
+		}
+		return count
+	}
+	granted := make(chan string)
+	var done sync.WaitGroup
+	for i, controller := range []string{"busy", "busy", "busy", "quiet"} {
+		done.Add(1)
+		go func(controller string) {
+			defer done.Done()
+			release := s.acquire("CompositeController", controller)
+			granted <- controller
+			release()
+		}(controller)
+		// Queue the syncs in order.
+		for waiters() != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	release()
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, <-granted)
+	}
+	want := []string{"busy", "quiet", "busy", "busy"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("syncs got slots in order %v, want %v", got, want)
+	}
+	// The last sync releases its slot after it's received.
+	done.Wait()
+	if s.used != 0 {
+		t.Errorf("%v slots still used after all syncs are done", s.used)
+	}
+}
+
+func TestSyncSlotsUnlimited(t *testing.T) {
+	s := &syncSlots{waiting: make(map[string][]chan struct{})}
+	for i := 0; i < 10; i++ {
+		// This would block if slots were limited.
+		s.acquire("DecoratorController", "test")
+	}
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic/dynamiclister"
+	"k8s.io/klog/v2"
+)
+
+// SkipTerminatingNamespaces returns the desired children, without those that
+// don't exist yet and would be created in a namespace that's being deleted,
+// since the API server forbids that. Existing children are still updated or
+// deleted, so parents in such namespaces can still be finalized.
+func SkipTerminatingNamespaces(namespaces dynamiclister.Lister, parent *unstructured.Unstructured, observed, desired ChildMap) ChildMap {
+	terminating := make(map[string]bool)
+	isTerminating := func(namespace string) bool {
+		if namespace == "" {
+			return false
+		}
+		if result, ok := terminating[namespace]; ok {
+			return result
+		}
+		ns, err := namespaces.Get(namespace)
+		result := err == nil && ns.GetDeletionTimestamp() != nil
+		terminating[namespace] = result
+		return result
+	}
+
+	result := make(ChildMap, len(desired))
+	for key, objects := range desired {
+		result[key] = make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if observed[key][name] == nil {
+				namespace := obj.GetNamespace()
+				if namespace == "" {
+					namespace = parent.GetNamespace()
+				}
+				if isTerminating(namespace) {
+					klog.V(4).InfoS("Not creating child in terminating namespace", "parent", klog.KObj(parent), "child_kind", obj.GetKind(), "child", klog.KRef(namespace, obj.GetName()))
+					continue
+				}
+			}
+			result[key][name] = obj
+		}
+	}
+	return result
+}
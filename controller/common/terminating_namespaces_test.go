@@ -0,0 +1,56 @@
+package common
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamiclister"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSkipTerminatingNamespaces(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, name := range []string{"active", "terminating"} {
+		ns := &unstructured.Unstructured{}
+		ns.SetAPIVersion("v1")
+		ns.SetKind("Namespace")
+		ns.SetName(name)
+		if name == "terminating" {
+			now := metav1.Now()
+			ns.SetDeletionTimestamp(&now)
+		}
+		indexer.Add(ns)
+	}
+	namespaces := dynamiclister.New(indexer, schema.GroupVersionResource{Version: "v1", Resource: "namespaces"})
+
+	child := func(namespace, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return obj
+	}
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("terminating")
+	observed := MakeChildMap(parent, []*unstructured.Unstructured{child("", "existing")})
+	desired := MakeChildMap(parent, []*unstructured.Unstructured{
+		child("", "existing"),
+		child("", "new"),
+		child("active", "elsewhere"),
+		child("missing", "unknown"),
+	})
+
+	var got []string
+	for _, obj := range SkipTerminatingNamespaces(namespaces, parent, observed, desired).List() {
+		got = append(got, obj.GetName())
+	}
+	sort.Strings(got)
+	if want := []string{"elsewhere", "existing", "unknown"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SkipTerminatingNamespaces() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"metacontroller.io/tracing"
+)
+
+// The latency histograms are plain Prometheus histograms, rather than
+// component-base ones, since only those can be observed with exemplars.
+var (
+	syncDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "metacontroller",
+			Name:      "sync_duration_seconds",
+			Help:      "How long syncs of parents took, with the ID of the trace of a sync as exemplar when it's traced.",
+			Buckets:   []float64{.005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"controller_kind", "controller", "result"},
+	)
+	hookDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "metacontroller",
+			Name:      "hook_duration_seconds",
+			Help:      "How long hook calls took, with the ID of the trace of the sync that made the call as exemplar when it's traced.",
+			Buckets:   []float64{.005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"controller_kind", "controller", "hook", "result"},
+	)
+)
+
+func init() {
+	legacyregistry.RawMustRegister(syncDuration, hookDuration)
+}
+
+// traceKey identifies the sync of parent by the controller with the given
+// kind and name among the traced syncs in progress.
+func traceKey(controllerKind, controller string, parent metav1.Object) string {
+	return controllerKind + "/" + controller + "/" + string(parent.GetUID())
+}
+
+// TimeSync calls sync, which syncs parent for the controller with the given
+// kind and name, traces it if tracing is enabled, and records how long it
+// took. parent may be nil if it's gone from the cache, in which case the
+// sync isn't traced.
+func TimeSync(controllerKind, controller string, parent *unstructured.Unstructured, sync func() error) error {
+	var span *tracing.Span
+	if parent != nil {
+		span = tracing.Start("sync",
+			"metacontroller.controller_kind", controllerKind,
+			"metacontroller.controller", controller,
+			"metacontroller.parent.namespace", parent.GetNamespace(),
+			"metacontroller.parent.name", parent.GetName(),
+		)
+		key := traceKey(controllerKind, controller, parent)
+		tracing.SetActive(key, span)
+		defer tracing.SetActive(key, nil)
+	}
+	start := time.Now()
+	err := sync()
+	span.End(err)
+	observeWithTrace(syncDuration.WithLabelValues(controllerKind, controller, resultLabel(err)), time.Since(start), span)
+	return err
+}
+
+// CallHook calls call, which calls the hook of the given type (e.g. "sync" or
+// "finalize") on behalf of obj for the controller with the given kind and
+// name, with the goroutine labeled as CallWithProfileLabels does. If the sync
+// of obj is traced, the call is traced as part of it, and call gets the W3C
+// traceparent header that makes the spans of the hook children of the call.
+// Otherwise, call gets "". CallHook records how long the call took.
+func CallHook(controllerKind, controller, hook string, obj metav1.Object, call func(traceParent string) error) error {
+	span := tracing.Active(traceKey(controllerKind, controller, obj)).StartChild(hook+" hook", "metacontroller.hook", hook)
+	start := time.Now()
+	err := CallWithProfileLabels(controllerKind, controller, hook, func() error {
+		return call(span.TraceParent())
+	})
+	span.End(err)
+	observeWithTrace(hookDuration.WithLabelValues(controllerKind, controller, hook, resultLabel(err)), time.Since(start), span)
+	return err
+}
+
+// observeWithTrace observes duration, with the ID of the trace of span as
+// exemplar if span is traced.
+func observeWithTrace(observer prometheus.Observer, duration time.Duration, span *tracing.Span) {
+	if span == nil {
+		observer.Observe(duration.Seconds())
+		return
+	}
+	observer.(prometheus.ExemplarObserver).ObserveWithExemplar(duration.Seconds(), prometheus.Labels{"trace_id": span.TraceID()})
+}
+
+// resultLabel is the result label of an operation that returned err.
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
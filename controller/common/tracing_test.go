@@ -0,0 +1,79 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/tracing"
+)
+
+// exemplar returns the exemplar of a bucket of observer, which is observed
+// once, if any.
+func exemplar(t *testing.T, observer prometheus.Observer) *dto.Exemplar {
+	var metric dto.Metric
+	if err := observer.(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range metric.GetHistogram().GetBucket() {
+		if e := bucket.GetExemplar(); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func TestTimeSyncExemplars(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetUID("uid")
+	parent.SetName("test")
+
+	table := []struct {
+		name         string
+		endpoint     string
+		parent       *unstructured.Unstructured
+		wantExemplar bool
+	}{
+		{name: "tracing disabled", parent: parent},
+		{name: "parent gone", endpoint: "http://127.0.0.1:0", parent: nil},
+		{name: "traced", endpoint: "http://127.0.0.1:0", parent: parent, wantExemplar: true},
+	}
+	for _, tc := range table {
+		stop := tracing.Init(tracing.Options{Endpoint: tc.endpoint, SampleRatio: 1})
+		controller := "test-" + tc.name
+		var traceParent string
+		err := TimeSync("CompositeController", controller, tc.parent, func() error {
+			return CallHook("CompositeController", controller, "sync", parent, func(tp string) error {
+				traceParent = tp
+				return nil
+			})
+		})
+		stop()
+		if err != nil {
+			t.Errorf("%v: TimeSync() = %v", tc.name, err)
+		}
+
+		syncExemplar := exemplar(t, syncDuration.WithLabelValues("CompositeController", controller, "success"))
+		hookExemplar := exemplar(t, hookDuration.WithLabelValues("CompositeController", controller, "sync", "success"))
+		if !tc.wantExemplar {
+			if syncExemplar != nil || hookExemplar != nil || traceParent != "" {
+				t.Errorf("%v: exemplars = %v, %v, traceparent = %q; want none", tc.name, syncExemplar, hookExemplar, traceParent)
+			}
+			continue
+		}
+		if syncExemplar == nil || hookExemplar == nil {
+			t.Errorf("%v: exemplars = %v, %v; want both", tc.name, syncExemplar, hookExemplar)
+			continue
+		}
+		traceID := syncExemplar.GetLabel()[0].GetValue()
+		if got := hookExemplar.GetLabel()[0].GetValue(); got != traceID {
+			t.Errorf("%v: hook exemplar trace_id = %q, want %q", tc.name, got, traceID)
+		}
+		if want := "00-" + traceID + "-"; len(traceParent) < len(want) || traceParent[:len(want)] != want {
+			t.Errorf("%v: traceparent = %q, want it in trace %q", tc.name, traceParent, traceID)
+		}
+	}
+	tracing.Init(tracing.Options{})
+}
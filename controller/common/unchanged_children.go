@@ -0,0 +1,43 @@
+package common
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DeletedChild names a child a sync hook wants deleted, when it only returns
+// the children that changed and so can't delete one by leaving it out.
+type DeletedChild struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Namespace defaults to the namespace of the parent.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// KeepUnchanged restricts the observed children of a parent to the ones that
+// are desired or deleted, for hooks that only return the children that
+// changed and say the others are unchanged. The others are then neither
+// compared with what the hook wants nor deleted. Deleted children that
+// aren't observed are ignored, since they may already be gone.
+func KeepUnchanged(parent *unstructured.Unstructured, observed, desired ChildMap, deleted []DeletedChild) (ChildMap, error) {
+	marked := make(map[string]map[string]bool)
+	for i, d := range deleted {
+		key := childMapKey(d.APIVersion, d.Kind)
+		target := &unstructured.Unstructured{}
+		target.SetNamespace(d.Namespace)
+		target.SetName(d.Name)
+		name := relativeName(parent, target)
+		if desired[key][name] != nil {
+			return nil, Permanent(fmt.Errorf("deleted[%v]: %v %v is also a desired child", i, d.Kind, name))
+		}
+		if marked[key] == nil {
+			marked[key] = make(map[string]bool)
+		}
+		marked[key][name] = true
+	}
+	return keepObserved(observed, desired, func(obj *unstructured.Unstructured) bool {
+		return !marked[childMapKey(obj.GetAPIVersion(), obj.GetKind())][relativeName(parent, obj)]
+	}), nil
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestKeepUnchanged(t *testing.T) {
+	child := func(name string, deleting bool) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		if deleting {
+			now := metav1.Now()
+			obj.SetDeletionTimestamp(&now)
+		}
+		return obj
+	}
+
+	table := []struct {
+		name              string
+		observed, desired []*unstructured.Unstructured
+		deleted           []DeletedChild
+		want              []string
+		wantErr           bool
+	}{
+		{
+			name:     "changed children are kept",
+			observed: []*unstructured.Unstructured{child("a", false), child("b", false)},
+			desired:  []*unstructured.Unstructured{child("a", false), child("b", false)},
+			want:     []string{"a", "b"},
+		},
+		{
+			name:     "unchanged children are left out",
+			observed: []*unstructured.Unstructured{child("a", false), child("b", false)},
+			desired:  []*unstructured.Unstructured{child("a", false)},
+			want:     []string{"a"},
+		},
+		{
+			name:     "children pending deletion are kept",
+			observed: []*unstructured.Unstructured{child("a", false), child("b", true)},
+			want:     []string{"b"},
+		},
+		{
+			name:     "deleted children are kept",
+			observed: []*unstructured.Unstructured{child("a", false), child("b", false), child("c", false)},
+			desired:  []*unstructured.Unstructured{child("a", false)},
+			deleted:  []DeletedChild{{APIVersion: "v1", Kind: "ConfigMap", Name: "b"}},
+			want:     []string{"a", "b"},
+		},
+		{
+			name:     "deleted children of another kind are left out",
+			observed: []*unstructured.Unstructured{child("a", false), child("b", false)},
+			deleted:  []DeletedChild{{APIVersion: "v1", Kind: "Secret", Name: "b"}},
+			want:     []string{},
+		},
+		{
+			name:     "deleted children that are gone are ignored",
+			observed: []*unstructured.Unstructured{child("a", false)},
+			deleted:  []DeletedChild{{APIVersion: "v1", Kind: "ConfigMap", Name: "b"}},
+			want:     []string{},
+		},
+		{
+			name:     "deleted children can't be desired",
+			observed: []*unstructured.Unstructured{child("a", false)},
+			desired:  []*unstructured.Unstructured{child("a", false)},
+			deleted:  []DeletedChild{{APIVersion: "v1", Kind: "ConfigMap", Name: "a"}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			parent := &unstructured.Unstructured{}
+			kept, err := KeepUnchanged(parent, MakeChildMap(parent, tc.observed), MakeChildMap(parent, tc.desired), tc.deleted)
+			if tc.wantErr {
+				if !IsPermanent(err) {
+					t.Errorf("KeepUnchanged() = %v, want permanent error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("KeepUnchanged() = %v", err)
+			}
+			got := []string{}
+			for _, obj := range kept.List() {
+				got = append(got, obj.GetName())
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("KeepUnchanged() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
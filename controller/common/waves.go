@@ -0,0 +1,196 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// WaveAnnotation can be set by hooks on desired children to create them
+	// in waves. Children of a wave are only created once every desired child
+	// of the lower waves exists and is ready. Children without it are in
+	// wave 0.
+	WaveAnnotation = "metacontroller.k8s.io/wave"
+	// ReadyWhenAnnotation can be set by hooks on desired children to replace
+	// the built-in readiness conventions with a readiness gate, a JSONPath
+	// template evaluated against the child. See ReadyWhen for the format.
+	ReadyWhenAnnotation = "metacontroller.k8s.io/ready-when"
+)
+
+// WaitingChild is a desired child whose creation is held back until the
+// children of a lower wave are ready.
+type WaitingChild struct {
+	Child *unstructured.Unstructured
+	// WaitingFor is the wave that isn't ready yet.
+	WaitingFor int
+	// WaitingForNamespace is the namespace, itself a desired child, that
+	// doesn't exist yet, if the child waits for it rather than for a wave.
+	WaitingForNamespace string
+}
+
+// GateWaves returns the desired children that can be created or updated now.
+// Children that don't exist yet, and are in a wave above the lowest wave that
+// isn't ready, are left out, along with the reason they're waiting.
+// Children that already exist are never left out, so they aren't deleted.
+func GateWaves(observed, desired ChildMap) (ChildMap, []WaitingChild) {
+	// Find the lowest wave with a desired child that's missing or not ready.
+	var blocking *int
+	for key, objects := range desired {
+		for name, obj := range objects {
+			wave := childWave(obj)
+			if blocking != nil && wave >= *blocking {
+				continue
+			}
+			if child := observed[key][name]; child != nil {
+				if ready, _ := childReadyWhen(child, obj); ready {
+					continue
+				}
+			}
+			blocking = &wave
+		}
+	}
+	if blocking == nil {
+		return desired, nil
+	}
+
+	var waiting []WaitingChild
+	result := make(ChildMap, len(desired))
+	for key, objects := range desired {
+		group := make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			if observed[key][name] == nil && childWave(obj) > *blocking {
+				klog.V(4).InfoS("Waiting to create child", "child", klog.KObj(obj), "wave", childWave(obj), "waitingFor", *blocking)
+				waiting = append(waiting, WaitingChild{Child: obj, WaitingFor: *blocking})
+				continue
+			}
+			group[name] = obj
+		}
+		result[key] = group
+	}
+	return result, waiting
+}
+
+// AddWaiting counts children that are waiting to be created as not ready,
+// so the parent isn't reported ready before every wave is.
+func (summary *ReadinessSummary) AddWaiting(waiting []WaitingChild) {
+	if summary == nil || len(waiting) == 0 {
+		return
+	}
+	for _, w := range waiting {
+		reason := fmt.Sprintf("waiting for wave %v to be ready", w.WaitingFor)
+		if w.WaitingForNamespace != "" {
+			reason = fmt.Sprintf("waiting for namespace %v to be created", w.WaitingForNamespace)
+		}
+		summary.Total++
+		summary.NotReady = append(summary.NotReady, NotReadyChild{
+			APIVersion: w.Child.GetAPIVersion(),
+			Kind:       w.Child.GetKind(),
+			Name:       w.Child.GetName(),
+			Reason:     reason,
+		})
+	}
+	summary.Ready = false
+	sortNotReady(summary.NotReady)
+}
+
+// namespaceChildKey is the key of Namespace children in a ChildMap.
+var namespaceChildKey = childMapKey("v1", "Namespace")
+
+// WaitForNamespaces returns the desired children that can be created now.
+// Children that don't exist yet, and are in a namespace that is itself a
+// desired child that doesn't exist yet, are left out, along with the
+// namespace they're waiting for, so they're created once it is.
+func WaitForNamespaces(observed, desired ChildMap) (ChildMap, []WaitingChild) {
+	namespaces := desired[namespaceChildKey]
+	if len(namespaces) == 0 {
+		return desired, nil
+	}
+	var waiting []WaitingChild
+	result := make(ChildMap, len(desired))
+	for key, objects := range desired {
+		group := make(map[string]*unstructured.Unstructured, len(objects))
+		for name, obj := range objects {
+			ns := obj.GetNamespace()
+			if ns != "" && namespaces[ns] != nil && observed[namespaceChildKey][ns] == nil && observed[key][name] == nil {
+				klog.V(4).InfoS("Waiting to create child", "child", klog.KObj(obj), "waitingForNamespace", ns)
+				waiting = append(waiting, WaitingChild{Child: obj, WaitingForNamespace: ns})
+				continue
+			}
+			group[name] = obj
+		}
+		result[key] = group
+	}
+	return result, waiting
+}
+
+func childWave(obj *unstructured.Unstructured) int {
+	value, ok := obj.GetAnnotations()[WaveAnnotation]
+	if !ok {
+		return 0
+	}
+	wave, err := strconv.Atoi(value)
+	if err != nil {
+		klog.InfoS("Ignoring invalid wave", "child", klog.KObj(obj), "wave", value)
+		return 0
+	}
+	return wave
+}
+
+// childReadyWhen checks whether an observed child is ready, using the
+// readiness gate of the desired child, since it may be newer than the one
+// the observed child was last written with.
+func childReadyWhen(observed, desired *unstructured.Unstructured) (bool, string) {
+	if _, ok := desired.GetAnnotations()[ReadyWhenAnnotation]; !ok {
+		return ChildReady(observed)
+	}
+	withGate := observed.DeepCopy()
+	annotations := withGate.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string, 1)
+	}
+	annotations[ReadyWhenAnnotation] = desired.GetAnnotations()[ReadyWhenAnnotation]
+	withGate.SetAnnotations(annotations)
+	return ChildReady(withGate)
+}
+
+// ReadyWhen evaluates a readiness gate against a child.
+//
+// The gate is a JSONPath template, as accepted by kubectl, optionally followed
+// by "=" and the expected value. Without an expected value, the child is
+// ready if the template evaluates to "true", ignoring case. For example:
+//
+//	{.status.conditions[?(@.type=="Available")].status}
+//	{.status.phase}=Succeeded
+//
+// Fields that are missing evaluate to an empty string.
+func ReadyWhen(child *unstructured.Unstructured, gate string) (bool, string) {
+	template, expected := gate, "true"
+	if i := strings.LastIndex(gate, "}"); i >= 0 && i < len(gate)-1 {
+		rest := gate[i+1:]
+		if !strings.HasPrefix(rest, "=") {
+			return false, fmt.Sprintf("invalid readiness gate %q", gate)
+		}
+		template, expected = gate[:i+1], rest[1:]
+	}
+
+	jp := jsonpath.New(ReadyWhenAnnotation)
+	jp.AllowMissingKeys(true)
+	if err := jp.Parse(template); err != nil {
+		return false, fmt.Sprintf("invalid readiness gate %q: %v", gate, err)
+	}
+	var buf bytes.Buffer
+	if err := jp.Execute(&buf, child.UnstructuredContent()); err != nil {
+		return false, fmt.Sprintf("can't evaluate readiness gate %q: %v", gate, err)
+	}
+	value := buf.String()
+	if value != expected && !(expected == "true" && strings.EqualFold(value, expected)) {
+		return false, fmt.Sprintf("%v is %q, want %q", template, value, expected)
+	}
+	return true, ""
+}
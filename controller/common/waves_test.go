@@ -0,0 +1,205 @@
+package common
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGateWaves(t *testing.T) {
+	child := func(name, wave, ready string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		annotations := map[string]string{ReadyWhenAnnotation: "{.data.ready}"}
+		if wave != "" {
+			annotations[WaveAnnotation] = wave
+		}
+		obj.SetAnnotations(annotations)
+		if ready != "" {
+			obj.Object["data"] = map[string]interface{}{"ready": ready}
+		}
+		return obj
+	}
+
+	table := []struct {
+		name     string
+		observed []*unstructured.Unstructured
+		desired  []*unstructured.Unstructured
+		want     []string
+		waiting  []string
+	}{
+		{
+			name:    "no waves",
+			desired: []*unstructured.Unstructured{child("a", "", ""), child("b", "", "")},
+			want:    []string{"a", "b"},
+		},
+		{
+			name:    "first wave only",
+			desired: []*unstructured.Unstructured{child("db", "0", ""), child("app", "1", ""), child("job", "2", "")},
+			want:    []string{"db"},
+			waiting: []string{"app", "job"},
+		},
+		{
+			name:     "first wave not ready",
+			observed: []*unstructured.Unstructured{child("db", "0", "false")},
+			desired:  []*unstructured.Unstructured{child("db", "0", ""), child("app", "1", "")},
+			want:     []string{"db"},
+			waiting:  []string{"app"},
+		},
+		{
+			name:     "next wave once previous is ready",
+			observed: []*unstructured.Unstructured{child("db", "0", "true")},
+			desired:  []*unstructured.Unstructured{child("db", "0", ""), child("app", "1", ""), child("job", "2", "")},
+			want:     []string{"app", "db"},
+			waiting:  []string{"job"},
+		},
+		{
+			name:     "existing children are kept",
+			observed: []*unstructured.Unstructured{child("db", "0", "false"), child("app", "1", "true")},
+			desired:  []*unstructured.Unstructured{child("db", "0", ""), child("app", "1", "")},
+			want:     []string{"app", "db"},
+		},
+		{
+			name:     "all waves ready",
+			observed: []*unstructured.Unstructured{child("db", "-1", "True"), child("app", "x", "true")},
+			desired:  []*unstructured.Unstructured{child("db", "-1", ""), child("app", "x", "")},
+			want:     []string{"app", "db"},
+		},
+	}
+
+	parent := &unstructured.Unstructured{}
+	for _, tc := range table {
+		observed := MakeChildMap(parent, tc.observed)
+		desired := MakeChildMap(parent, tc.desired)
+		gated, waiting := GateWaves(observed, desired)
+		var got, gotWaiting []string
+		for _, obj := range gated.List() {
+			got = append(got, obj.GetName())
+		}
+		for _, w := range waiting {
+			gotWaiting = append(gotWaiting, w.Child.GetName())
+		}
+		sort.Strings(got)
+		sort.Strings(gotWaiting)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: GateWaves() = %v, want %v", tc.name, got, tc.want)
+		}
+		if !reflect.DeepEqual(gotWaiting, tc.waiting) {
+			t.Errorf("%v: GateWaves() waiting = %v, want %v", tc.name, gotWaiting, tc.waiting)
+		}
+	}
+}
+
+func TestReadyWhen(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]interface{}{"name": "test"},
+		"status": map[string]interface{}{
+			"phase": "Succeeded",
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Complete", "status": "True"},
+				map[string]interface{}{"type": "Failed", "status": "False"},
+			},
+		},
+	}}
+
+	table := []struct {
+		gate  string
+		ready bool
+	}{
+		{gate: `{.status.conditions[?(@.type=="Complete")].status}`, ready: true},
+		{gate: `{.status.conditions[?(@.type=="Failed")].status}`, ready: false},
+		{gate: `{.status.phase}=Succeeded`, ready: true},
+		{gate: `{.status.phase}=Running`, ready: false},
+		{gate: `{.status.missing}`, ready: false},
+		{gate: `{.status.missing}=`, ready: true},
+		{gate: `{.status.phase}Succeeded`, ready: false},
+		{gate: `{.status[}`, ready: false},
+	}
+
+	for _, tc := range table {
+		if got, reason := ReadyWhen(obj, tc.gate); got != tc.ready {
+			t.Errorf("%v: ReadyWhen() = %v (%v), want %v", tc.gate, got, reason, tc.ready)
+		}
+	}
+}
+
+func TestWaitForNamespaces(t *testing.T) {
+	namespace := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("Namespace")
+		obj.SetName(name)
+		return obj
+	}
+	configMap := func(namespace, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return obj
+	}
+
+	table := []struct {
+		name     string
+		observed []*unstructured.Unstructured
+		desired  []*unstructured.Unstructured
+		want     []string
+		waiting  []string
+	}{
+		{
+			name:    "no namespace children",
+			desired: []*unstructured.Unstructured{configMap("apps", "config")},
+			want:    []string{"config"},
+		},
+		{
+			name:    "namespace not created yet",
+			desired: []*unstructured.Unstructured{namespace("apps"), configMap("apps", "config"), configMap("other", "other")},
+			want:    []string{"apps", "other"},
+			waiting: []string{"config"},
+		},
+		{
+			name:     "namespace created",
+			observed: []*unstructured.Unstructured{namespace("apps")},
+			desired:  []*unstructured.Unstructured{namespace("apps"), configMap("apps", "config")},
+			want:     []string{"apps", "config"},
+		},
+		{
+			name:     "existing children are kept",
+			observed: []*unstructured.Unstructured{configMap("apps", "config")},
+			desired:  []*unstructured.Unstructured{namespace("apps"), configMap("apps", "config")},
+			want:     []string{"apps", "config"},
+		},
+	}
+
+	parent := &unstructured.Unstructured{}
+	for _, tc := range table {
+		observed := MakeChildMap(parent, tc.observed)
+		desired := MakeChildMap(parent, tc.desired)
+		result, waiting := WaitForNamespaces(observed, desired)
+		var got, gotWaiting []string
+		for _, obj := range result.List() {
+			got = append(got, obj.GetName())
+		}
+		for _, w := range waiting {
+			if w.WaitingForNamespace != w.Child.GetNamespace() {
+				t.Errorf("%v: %v is waiting for namespace %q, want %q", tc.name, w.Child.GetName(), w.WaitingForNamespace, w.Child.GetNamespace())
+			}
+			gotWaiting = append(gotWaiting, w.Child.GetName())
+		}
+		sort.Strings(got)
+		sort.Strings(gotWaiting)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: WaitForNamespaces() = %v, want %v", tc.name, got, tc.want)
+		}
+		if !reflect.DeepEqual(gotWaiting, tc.waiting) {
+			t.Errorf("%v: WaitForNamespaces() waiting = %v, want %v", tc.name, gotWaiting, tc.waiting)
+		}
+	}
+}
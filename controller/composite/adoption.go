@@ -0,0 +1,140 @@
+package composite
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamiccontrollerref "metacontroller.io/dynamic/controllerref"
+)
+
+// childAdoption selects pre-existing objects that a parent adopts although
+// they don't match its selector, by name or labels.
+type childAdoption struct {
+	name   *template.Template
+	labels map[string]*template.Template
+}
+
+// childAdoptions holds the adoption of each child resource that has one.
+type childAdoptions map[schema.GroupVersionResource]*childAdoption
+
+func makeChildAdoptions(cc *v1alpha1.CompositeController) (childAdoptions, error) {
+	adoptions := make(childAdoptions)
+	for _, child := range cc.Spec.ChildResources {
+		if child.Adopt == nil {
+			continue
+		}
+		adoption, err := newChildAdoption(child.Adopt)
+		if err != nil {
+			return nil, fmt.Errorf("child resource %q in apiVersion %q: %v", child.Resource, child.APIVersion, err)
+		}
+		groupVersion, err := schema.ParseGroupVersion(child.APIVersion)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse child resource groupVersion: %v", err)
+		}
+		adoptions[groupVersion.WithResource(child.Resource)] = adoption
+	}
+	return adoptions, nil
+}
+
+func newChildAdoption(config *v1alpha1.ChildAdoption) (*childAdoption, error) {
+	if config.NameTemplate == "" && len(config.MatchLabels) == 0 {
+		return nil, fmt.Errorf("adopt requires nameTemplate, matchLabels or both")
+	}
+	adoption := &childAdoption{labels: make(map[string]*template.Template, len(config.MatchLabels))}
+	var err error
+	if config.NameTemplate != "" {
+		adoption.name, err = template.New("nameTemplate").Option("missingkey=error").Parse(config.NameTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid adopt.nameTemplate: %v", err)
+		}
+	}
+	for key, value := range config.MatchLabels {
+		adoption.labels[key], err = template.New(key).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid adopt.matchLabels[%q]: %v", key, err)
+		}
+	}
+	return adoption, nil
+}
+
+// matches returns whether parent adopts obj.
+func (a *childAdoption) matches(parent, obj *unstructured.Unstructured) (bool, error) {
+	if a.name != nil {
+		name, err := render(a.name, parent)
+		if err != nil {
+			return false, err
+		}
+		if obj.GetName() != name {
+			return false, nil
+		}
+	}
+	objLabels := obj.GetLabels()
+	for key, tmpl := range a.labels {
+		value, err := render(tmpl, parent)
+		if err != nil {
+			return false, err
+		}
+		if current, ok := objLabels[key]; !ok || current != value {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func render(tmpl *template.Template, parent *unstructured.Unstructured) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, parent.UnstructuredContent()); err != nil {
+		return "", common.Permanent(fmt.Errorf("can't render adopt template %q: %v", tmpl.Name(), err))
+	}
+	return buf.String(), nil
+}
+
+// adoptOrphans adopts the candidates that have no controller, don't match
+// the selector of parent, but match adoption. They get the labels of the
+// selector, so they match it from then on.
+func (pc *parentController) adoptOrphans(parent *unstructured.Unstructured, selector labels.Selector, crm *dynamiccontrollerref.UnstructuredManager, adoption *childAdoption, candidates []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	var adopted []*unstructured.Unstructured
+	var selectorLabels map[string]string
+	for _, obj := range candidates {
+		if metav1.GetControllerOf(obj) != nil || obj.GetDeletionTimestamp() != nil || selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		ok, err := adoption.matches(parent, obj)
+		if err != nil {
+			return adopted, err
+		}
+		if !ok {
+			continue
+		}
+		if selectorLabels == nil {
+			labelSelector, err := pc.parentLabelSelector(parent)
+			if err != nil {
+				return adopted, err
+			}
+			selectorLabels = labelSelector.MatchLabels
+		}
+		objLabels := labels.Merge(obj.GetLabels(), selectorLabels)
+		if !selector.Matches(objLabels) {
+			// The selector has expressions that labels alone can't satisfy.
+			klog.InfoS("Not adopting object that wouldn't match the parent's selector", "parent_kind", parent.GetKind(), "parent", klog.KObj(parent), "child_kind", obj.GetKind(), "object", klog.KObj(obj))
+			continue
+		}
+		result, err := crm.AdoptOrphan(obj, selectorLabels)
+		if err != nil {
+			return adopted, err
+		}
+		if result != nil {
+			adopted = append(adopted, result)
+		}
+	}
+	return adopted, nil
+}
@@ -0,0 +1,97 @@
+package composite
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+)
+
+func TestChildAdoptionMatches(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetName("nginx")
+	parent.SetNamespace("default")
+
+	table := []struct {
+		name        string
+		config      v1alpha1.ChildAdoption
+		objName     string
+		objLabels   map[string]string
+		want        bool
+		wantErr     bool
+		wantInitErr bool
+	}{
+		{
+			name:        "empty",
+			wantInitErr: true,
+		},
+		{
+			name:        "invalid template",
+			config:      v1alpha1.ChildAdoption{NameTemplate: "{{ .metadata.name"},
+			wantInitErr: true,
+		},
+		{
+			name:    "name matches",
+			config:  v1alpha1.ChildAdoption{NameTemplate: "{{ .metadata.name }}-web"},
+			objName: "nginx-web",
+			want:    true,
+		},
+		{
+			name:    "name doesn't match",
+			config:  v1alpha1.ChildAdoption{NameTemplate: "{{ .metadata.name }}-web"},
+			objName: "apache-web",
+		},
+		{
+			name:      "labels match",
+			config:    v1alpha1.ChildAdoption{MatchLabels: map[string]string{"instance": "{{ .metadata.name }}", "tier": "web"}},
+			objName:   "anything",
+			objLabels: map[string]string{"instance": "nginx", "tier": "web", "other": "label"},
+			want:      true,
+		},
+		{
+			name:      "label missing",
+			config:    v1alpha1.ChildAdoption{MatchLabels: map[string]string{"instance": "{{ .metadata.name }}", "tier": "web"}},
+			objName:   "anything",
+			objLabels: map[string]string{"instance": "nginx"},
+		},
+		{
+			name:      "name and labels",
+			config:    v1alpha1.ChildAdoption{NameTemplate: "{{ .metadata.name }}", MatchLabels: map[string]string{"tier": "web"}},
+			objName:   "nginx",
+			objLabels: map[string]string{"tier": "db"},
+		},
+		{
+			name:    "missing field",
+			config:  v1alpha1.ChildAdoption{NameTemplate: "{{ .spec.name }}"},
+			objName: "nginx",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			adoption, err := newChildAdoption(&tc.config)
+			if (err != nil) != tc.wantInitErr {
+				t.Fatalf("newChildAdoption() = %v, want error %v", err, tc.wantInitErr)
+			}
+			if err != nil {
+				return
+			}
+			obj := &unstructured.Unstructured{}
+			obj.SetName(tc.objName)
+			obj.SetLabels(tc.objLabels)
+			got, err := adoption.matches(parent, obj)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("matches() error = %v, want error %v", err, tc.wantErr)
+			}
+			if err != nil && !common.IsPermanent(err) {
+				t.Errorf("matches() error = %v, want a permanent error", err)
+			}
+			if got != tc.want {
+				t.Errorf("matches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
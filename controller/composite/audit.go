@@ -0,0 +1,73 @@
+package composite
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"metacontroller.io/controller/common"
+	dynamiccontrollerref "metacontroller.io/dynamic/controllerref"
+)
+
+// auditChildren returns the children of parent in Audit mode: those it
+// already owns, since orphans aren't adopted, and an Audit listing the orphans
+// that claimChildren would have adopted.
+func (pc *parentController) auditChildren(parent *unstructured.Unstructured) (common.ChildMap, *common.Audit, error) {
+	observed, err := pc.ownedChildren(parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	orphans, err := pc.adoptableChildren(parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return observed, common.NewAudit(parent, orphans), nil
+}
+
+// adoptableChildren returns the objects that match the selector of parent,
+// but aren't controlled by anything yet.
+func (pc *parentController) adoptableChildren(parent *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	selector, err := pc.makeSelector(parent, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []*unstructured.Unstructured
+	for _, child := range pc.cc.Spec.ChildResources {
+		resource := pc.resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find child resource %q in apiVersion %q", child.Resource, child.APIVersion)
+		}
+		groupVersion, _ := schema.ParseGroupVersion(child.APIVersion)
+		informer := pc.childInformers.Get(groupVersion.WithResource(child.Resource))
+		if informer == nil {
+			return nil, fmt.Errorf("no informer for resource %q in apiVersion %q", child.Resource, child.APIVersion)
+		}
+		childClient, err := pc.dynClient.Resource(child.APIVersion, child.Resource)
+		if err != nil {
+			return nil, err
+		}
+		namespace := ""
+		if pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).Namespaced {
+			namespace = parent.GetNamespace()
+		}
+		all, err := common.ListObjects(informer, childClient, namespace, selector, child.BypassCache)
+		if err != nil {
+			return nil, fmt.Errorf("can't list %v children: %v", resource.Kind, err)
+		}
+
+		ownerRef := pc.ownerRefs.Get(resource.Group, resource.Kind)
+		for _, obj := range all {
+			if metav1.GetControllerOf(obj) != nil || obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			if dynamiccontrollerref.IsOwnedBy(obj, parent.GetUID(), ownerRef) {
+				continue
+			}
+			orphans = append(orphans, obj)
+		}
+	}
+	return orphans, nil
+}
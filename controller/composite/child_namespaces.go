@@ -0,0 +1,181 @@
+package composite
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicinformer "metacontroller.io/dynamic/informer"
+)
+
+// parentAnnotation holds the queue key of the parent of children in other
+// namespaces, so changes to them sync it.
+const parentAnnotation = "metacontroller.io/parent"
+
+// makeChildNamespaceSelector returns the selector of the namespaces children
+// may be in, besides the namespace of their parent, or nil if they may be in
+// any namespace.
+func makeChildNamespaceSelector(policy *v1alpha1.ChildNamespacePolicy) (labels.Selector, error) {
+	if policy == nil || policy.NamespaceSelector == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(policy.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid childNamespaces.namespaceSelector: %v", err)
+	}
+	return selector, nil
+}
+
+// cleansUpChildNamespaces returns whether parents of cc may have children in
+// other namespaces, which the garbage collector doesn't delete with them.
+func cleansUpChildNamespaces(cc *v1alpha1.CompositeController, parents map[schema.GroupKind]*parentResource) bool {
+	if cc.Spec.ChildNamespaces == nil {
+		return false
+	}
+	for _, parent := range parents {
+		if parent.Namespaced {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChildNamespaces returns an error if a desired child of parent is in a
+// namespace it isn't allowed in. It labels and annotates the children that
+// are in another namespace than their namespaced parent, so they can be
+// found without an owner reference.
+func (pc *parentController) checkChildNamespaces(parent *unstructured.Unstructured, desired common.ChildMap) error {
+	parentKey := ""
+	for _, group := range desired {
+		for _, obj := range group {
+			resource := pc.resources.GetKind(obj.GetAPIVersion(), obj.GetKind())
+			if resource == nil || !resource.Namespaced {
+				continue
+			}
+			namespace := obj.GetNamespace()
+			if namespace == "" || namespace == parent.GetNamespace() {
+				continue
+			}
+			if pc.cc.Spec.ChildNamespaces == nil {
+				if parent.GetNamespace() == "" {
+					// Cluster-scoped parents may have children in any namespace.
+					continue
+				}
+				return common.Permanent(fmt.Errorf("desired child %v %v/%v isn't in the namespace of its parent, and childNamespaces isn't set", obj.GetKind(), namespace, obj.GetName()))
+			}
+			if pc.childNamespaceSelector != nil {
+				ns, err := pc.namespaceInformer.Lister().Get(namespace)
+				if apierrors.IsNotFound(err) {
+					return fmt.Errorf("namespace %q of desired child %v %v doesn't exist", namespace, obj.GetKind(), obj.GetName())
+				}
+				if err != nil {
+					return err
+				}
+				if !pc.childNamespaceSelector.Matches(labels.Set(ns.GetLabels())) {
+					return common.Permanent(fmt.Errorf("desired child %v %v/%v is in a namespace that childNamespaces.namespaceSelector doesn't select", obj.GetKind(), namespace, obj.GetName()))
+				}
+			}
+			if !common.IsCrossNamespace(parent, true, namespace) {
+				continue
+			}
+			if parentKey == "" {
+				key, err := common.ParentQueueKey(parent)
+				if err != nil {
+					return err
+				}
+				parentKey = key
+			}
+			objLabels := obj.GetLabels()
+			if objLabels == nil {
+				objLabels = make(map[string]string)
+			}
+			objLabels[dynamicinformer.ParentUIDLabel] = string(parent.GetUID())
+			obj.SetLabels(objLabels)
+			ann := obj.GetAnnotations()
+			if ann == nil {
+				ann = make(map[string]string)
+			}
+			ann[parentAnnotation] = parentKey
+			obj.SetAnnotations(ann)
+		}
+	}
+	return nil
+}
+
+// listCrossNamespaceChildren returns the children of parent that are in
+// other namespaces, which are tracked by label since they can't have an
+// owner reference to it. They're looked up in the index of the informer, so
+// a parent with children in many namespaces doesn't scan all of them.
+func listCrossNamespaceChildren(informer *dynamicinformer.ResourceInformer, client *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, selector labels.Selector, bypassCache bool) ([]*unstructured.Unstructured, error) {
+	var all []*unstructured.Unstructured
+	var err error
+	if bypassCache {
+		uidSelector := labels.SelectorFromSet(labels.Set{dynamicinformer.ParentUIDLabel: string(parent.GetUID())})
+		all, err = common.ListObjects(informer, client, "", uidSelector, true)
+	} else {
+		all, err = informer.ListByParentUID(parent.GetUID())
+	}
+	if err != nil {
+		return nil, err
+	}
+	var children []*unstructured.Unstructured
+	for _, obj := range all {
+		if !common.IsCrossNamespace(parent, true, obj.GetNamespace()) || metav1.GetControllerOf(obj) != nil {
+			continue
+		}
+		if !selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		children = append(children, obj)
+	}
+	return children, nil
+}
+
+// withoutOthersChildren drops the objects that are tracked by label as the
+// children of another parent, so they're never adopted.
+func withoutOthersChildren(objects []*unstructured.Unstructured, parentUID types.UID) []*unstructured.Unstructured {
+	kept := objects[:0:0]
+	for _, obj := range objects {
+		if uid := obj.GetLabels()[dynamicinformer.ParentUIDLabel]; uid != "" && uid != string(parentUID) {
+			continue
+		}
+		kept = append(kept, obj)
+	}
+	return kept
+}
+
+// enqueueCrossNamespaceParent enqueues the parent of a child in another
+// namespace, if child is one.
+func (pc *parentController) enqueueCrossNamespaceParent(child *unstructured.Unstructured) bool {
+	key := child.GetAnnotations()[parentAnnotation]
+	if key == "" || child.GetLabels()[dynamicinformer.ParentUIDLabel] == "" {
+		return false
+	}
+	apiVersion, kind, _, _, err := common.SplitParentQueueKey(key)
+	if err != nil || pc.parentResourceOf(apiVersion, kind) == nil {
+		return false
+	}
+	pc.queue.Add(key)
+	return true
+}
+
+// cleanupResponse is the response used instead of calling the hooks when a
+// namespaced parent that may have children in other namespaces is deleted
+// and there's no finalize hook: it asks for no children at all, and is
+// finalized once they're all gone, since the garbage collector can't delete
+// the children in other namespaces.
+func cleanupResponse(request *SyncHookRequest) *SyncHookResponse {
+	status, _, _ := unstructured.NestedMap(request.Parent.UnstructuredContent(), "status")
+	return &SyncHookResponse{
+		Status:    status,
+		Finalized: len(request.Children.List()) == 0,
+	}
+}
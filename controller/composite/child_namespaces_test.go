@@ -0,0 +1,117 @@
+package composite
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicinformer "metacontroller.io/dynamic/informer"
+)
+
+func TestMakeChildNamespaceSelector(t *testing.T) {
+	table := []struct {
+		name      string
+		policy    *v1alpha1.ChildNamespacePolicy
+		wantNil   bool
+		wantErr   bool
+		namespace labels.Set
+		wantMatch bool
+	}{
+		{name: "no policy", wantNil: true},
+		{name: "any namespace", policy: &v1alpha1.ChildNamespacePolicy{}, wantNil: true},
+		{
+			name: "matching namespace",
+			policy: &v1alpha1.ChildNamespacePolicy{NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"tenant": "acme"},
+			}},
+			namespace: labels.Set{"tenant": "acme"},
+			wantMatch: true,
+		},
+		{
+			name: "other namespace",
+			policy: &v1alpha1.ChildNamespacePolicy{NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"tenant": "acme"},
+			}},
+			namespace: labels.Set{"tenant": "other"},
+		},
+		{
+			name: "invalid selector",
+			policy: &v1alpha1.ChildNamespacePolicy{NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tenant", Operator: "Bogus"}},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			selector, err := makeChildNamespaceSelector(tc.policy)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("makeChildNamespaceSelector() error = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if gotNil := selector == nil; gotNil != tc.wantNil {
+				t.Fatalf("makeChildNamespaceSelector() = %v, want nil %v", selector, tc.wantNil)
+			}
+			if selector != nil && selector.Matches(tc.namespace) != tc.wantMatch {
+				t.Errorf("selector %q matches %v = %v, want %v", selector, tc.namespace, !tc.wantMatch, tc.wantMatch)
+			}
+		})
+	}
+}
+
+func TestWithoutOthersChildren(t *testing.T) {
+	object := func(name, parentUID string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetName(name)
+		if parentUID != "" {
+			obj.SetLabels(map[string]string{dynamicinformer.ParentUIDLabel: parentUID})
+		}
+		return obj
+	}
+	objects := []*unstructured.Unstructured{
+		object("orphan", ""),
+		object("ours", "parent-uid"),
+		object("theirs", "other-uid"),
+	}
+
+	var got []string
+	for _, obj := range withoutOthersChildren(objects, "parent-uid") {
+		got = append(got, obj.GetName())
+	}
+	if want := []string{"orphan", "ours"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("withoutOthersChildren() = %q, want %q", got, want)
+	}
+	if len(objects) != 3 || objects[2].GetName() != "theirs" {
+		t.Errorf("withoutOthersChildren() modified its argument")
+	}
+}
+
+func TestCleanupResponse(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("ns")
+	parent.Object["status"] = map[string]interface{}{"ready": true}
+	policy := &unstructured.Unstructured{}
+	policy.SetAPIVersion("networking.k8s.io/v1")
+	policy.SetKind("NetworkPolicy")
+	policy.SetNamespace("other")
+	policy.SetName("deny-all")
+
+	response := cleanupResponse(&SyncHookRequest{Parent: parent, Children: common.MakeChildMap(parent, []*unstructured.Unstructured{policy})})
+	if response.Finalized || len(response.Children) != 0 {
+		t.Errorf("cleanupResponse() = %+v, want no children, not finalized", response)
+	}
+	if response.Status["ready"] != true {
+		t.Errorf("cleanupResponse() status = %v, want the status of the parent", response.Status)
+	}
+	response = cleanupResponse(&SyncHookRequest{Parent: parent, Children: make(common.ChildMap)})
+	if !response.Finalized {
+		t.Errorf("cleanupResponse() isn't finalized once children are gone")
+	}
+}
@@ -0,0 +1,31 @@
+package composite
+
+import (
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/controller/common/finalizer"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+)
+
+// newCleanup returns the cleanup of the parents and children of cc. Resources
+// that aren't served anymore are skipped, since there's nothing left of them
+// to clean up.
+func newCleanup(dynClient *dynamicclientset.Clientset, cc *v1alpha1.CompositeController) *common.ControllerCleanup {
+	cleanup := &common.ControllerCleanup{
+		ControllerKind: "CompositeController",
+		Controller:     cc.Name,
+		Finalizer:      finalizer.NewManager("metacontroller.io/compositecontroller-"+cc.Name, cc.Spec.Finalizer, false),
+		DeleteChildren: cc.Spec.DeletionPolicy != nil && cc.Spec.DeletionPolicy.DeleteChildren,
+	}
+	for _, parent := range cc.GetParentResources() {
+		if client, err := dynClient.Resource(parent.APIVersion, parent.Resource); err == nil {
+			cleanup.Parents = append(cleanup.Parents, client)
+		}
+	}
+	for _, child := range cc.Spec.ChildResources {
+		if client, err := dynClient.Resource(child.APIVersion, child.Resource); err == nil {
+			cleanup.Children = append(cleanup.Children, client)
+		}
+	}
+	return cleanup
+}
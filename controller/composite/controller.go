@@ -18,13 +18,14 @@ package composite
 
 import (
 	"fmt"
-	"reflect"
 	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
 	"metacontroller.io/events"
+	"metacontroller.io/leader"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
@@ -39,66 +40,218 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 
+	"metacontroller.io/admission"
 	"metacontroller.io/apis/metacontroller/v1alpha1"
 	mcclientset "metacontroller.io/client/generated/clientset/internalclientset"
 	mclisters "metacontroller.io/client/generated/lister/metacontroller/v1alpha1"
 	"metacontroller.io/controller/common"
+	"metacontroller.io/controller/common/cel"
 	"metacontroller.io/controller/common/customize"
 	"metacontroller.io/controller/common/finalizer"
 	dynamicclientset "metacontroller.io/dynamic/clientset"
 	dynamiccontrollerref "metacontroller.io/dynamic/controllerref"
 	dynamicdiscovery "metacontroller.io/dynamic/discovery"
 	dynamicinformer "metacontroller.io/dynamic/informer"
+	"metacontroller.io/hooks"
+	"metacontroller.io/logging"
 	k8s "metacontroller.io/third_party/kubernetes"
 )
 
 type parentController struct {
 	cc *v1alpha1.CompositeController
 
-	resources      *dynamicdiscovery.ResourceMap
-	parentResource *dynamicdiscovery.APIResource
+	resources *dynamicdiscovery.ResourceMap
+	// parents holds the parent resources by group and kind.
+	parents map[schema.GroupKind]*parentResource
 
-	mcClient       mcclientset.Interface
-	dynClient      *dynamicclientset.Clientset
-	parentClient   *dynamicclientset.ResourceClient
-	parentInformer *dynamicinformer.ResourceInformer
+	mcClient  mcclientset.Interface
+	dynClient *dynamicclientset.Clientset
 
 	revisionLister mclisters.ControllerRevisionLister
 
 	stopCh, doneCh chan struct{}
 	queue          workqueue.RateLimitingInterface
+	syncRetries    *common.SyncRetries
+	objectCounts   *common.ObjectCounts
+	revisionCounts *common.RevisionCounts
+
+	deletionProtection *common.DeletionProtection
+	childUIDs          *common.ChildUIDs
+	statusWrites       *common.StatusWrites
+	// namespaceInformer is used to skip creating children in namespaces
+	// that are being deleted.
+	namespaceInformer *dynamicinformer.ResourceInformer
+	// childNamespaceSelector selects the namespaces children may be in
+	// besides the namespace of their parent, or is nil if they may be in any.
+	childNamespaceSelector labels.Selector
 
 	updateStrategy updateStrategyMap
+	childPatches   common.ChildPatches
+	ownerRefs      common.ChildOwnerReferences
+	adoptions      childAdoptions
+	namespaces     childNamespaceTemplates
+	noPrune        common.NoPruneKinds
+	scaleDown      common.ScaleDownKinds
 	childInformers common.InformerMap
+	resyncSchedule *common.Schedule
+	// resyncRequest is the last value seen of the resync request annotation.
+	resyncRequest string
+	// childKinds are the kinds of the declared child resources, which are
+	// the only kinds the sync hook may return, if childKindPolicy allows them.
+	childKinds      common.GroupKindMap
+	childKindPolicy common.ChildKindPolicy
 
 	numWorkers    int
 	eventRecorder record.EventRecorder
+	// log attributes log lines to the controller.
+	log logr.Logger
+	// stopEventRecorder shuts down the broadcaster of eventRecorder, if it's
+	// dedicated to the controller.
+	stopEventRecorder func()
+
+	finalizer      *finalizer.Manager
+	childFinalizer *common.ChildFinalizer
+	customize      customize.Manager
+
+	admission        *admission.Server
+	admissionWebhook *admission.Webhook
+
+	// rollout splits the parents with another parentController while a
+	// change to the spec is rolled out. candidate is whether pc runs the new
+	// spec.
+	rollout   *rolloutShare
+	candidate bool
+}
+
+// parentResource is one of the parent resources of a CompositeController.
+type parentResource struct {
+	*dynamicdiscovery.APIResource
 
-	finalizer *finalizer.Manager
-	customize customize.Manager
+	client          *dynamicclientset.ResourceClient
+	informer        *dynamicinformer.ResourceInformer
+	revisionHistory *v1alpha1.CompositeControllerRevisionHistory
+	scale           *parentScale
+	// match, if not nil, must be true for parents to be synced.
+	match *cel.Program
 }
 
-func newParentController(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, mcClient mcclientset.Interface, revisionLister mclisters.ControllerRevisionLister, cc *v1alpha1.CompositeController, numWorkers int, eventRecorder record.EventRecorder) (pc *parentController, newErr error) {
-	// Make a dynamic client for the parent resource.
-	parentClient, err := dynClient.Resource(cc.Spec.ParentResource.APIVersion, cc.Spec.ParentResource.Resource)
+func newParentController(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, mcClient mcclientset.Interface, revisionLister mclisters.ControllerRevisionLister, cc *v1alpha1.CompositeController, numWorkers int, eventRecorder record.EventRecorder, childKindPolicy common.ChildKindPolicy, admissionServer *admission.Server) (pc *parentController, newErr error) {
+	// Make a dynamic client for each parent resource.
+	parents := make(map[schema.GroupKind]*parentResource)
+	for _, parent := range cc.GetParentResources() {
+		parentClient, err := dynClient.Resource(parent.APIVersion, parent.Resource)
+		if err != nil {
+			return nil, err
+		}
+		groupKind := schema.GroupKind{Group: parentClient.Group, Kind: parentClient.Kind}
+		if parents[groupKind] != nil {
+			return nil, fmt.Errorf("duplicate parent resource %q in apiVersion %q", parent.Resource, parent.APIVersion)
+		}
+		scale, err := newParentScale(parent.Scale)
+		if err != nil {
+			return nil, fmt.Errorf("parent resource %q in apiVersion %q: %v", parent.Resource, parent.APIVersion, err)
+		}
+		var match *cel.Program
+		if parent.Match != "" {
+			if match, err = cel.Compile(parent.Match); err != nil {
+				return nil, fmt.Errorf("invalid match for parent resource %q in apiVersion %q: %v", parent.Resource, parent.APIVersion, err)
+			}
+		}
+		parents[groupKind] = &parentResource{
+			APIResource:     parentClient.APIResource,
+			client:          parentClient,
+			revisionHistory: parent.RevisionHistory,
+			scale:           scale,
+			match:           match,
+		}
+	}
+
+	// Make sure we're allowed to manage all the requested child kinds
+	// before we start creating informers.
+	childKinds := make(common.GroupKindMap)
+	for _, child := range cc.Spec.ChildResources {
+		resource := resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find child resource %q in apiVersion %q", child.Resource, child.APIVersion)
+		}
+		if err := childKindPolicy.Check(resource); err != nil {
+			return nil, fmt.Errorf("invalid child resource %q in apiVersion %q: %v", child.Resource, child.APIVersion, err)
+		}
+		childKinds.Set(schema.GroupKind{Group: resource.Group, Kind: resource.Kind}, resource)
+	}
+
+	updateStrategy, err := makeUpdateStrategyMap(resources, cc)
 	if err != nil {
 		return nil, err
 	}
-	parentResource := parentClient.APIResource
+	if err := validateHooks(cc); err != nil {
+		return nil, err
+	}
 
-	updateStrategy, err := makeUpdateStrategyMap(resources, cc)
+	var resyncSchedule *common.Schedule
+	if cc.Spec.ResyncSchedule != "" {
+		resyncSchedule, err = common.ParseSchedule(cc.Spec.ResyncSchedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resyncSchedule: %v", err)
+		}
+	}
+	if err := common.ValidateChildApplyMode(cc.Spec.ChildApplyMode); err != nil {
+		return nil, err
+	}
+	if err := common.ValidateControllerMode(cc.Spec.Mode); err != nil {
+		return nil, err
+	}
+	if err := common.ValidateDeletionPolicy(cc.Spec.DeletionPolicy); err != nil {
+		return nil, err
+	}
+	if err := common.ValidateUIDMismatchPolicy(cc.Spec.UIDMismatchPolicy); err != nil {
+		return nil, err
+	}
+	if err := validateRevisionSnapshots(cc.Spec.RevisionSnapshots); err != nil {
+		return nil, err
+	}
+	childPatches, err := common.NewChildPatches(cc.Spec.ChildPatches)
+	if err != nil {
+		return nil, err
+	}
+	adoptions, err := makeChildAdoptions(cc)
+	if err != nil {
+		return nil, err
+	}
+	childNamespaceSelector, err := makeChildNamespaceSelector(cc.Spec.ChildNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := makeChildNamespaceTemplates(resources, cc)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create informer for the parent resource.
-	parentInformer, err := dynInformers.Resource(cc.Spec.ParentResource.APIVersion, cc.Spec.ParentResource.Resource)
+	parentFinalizer := finalizer.NewManager(
+		"metacontroller.io/compositecontroller-"+cc.Name,
+		cc.Spec.Finalizer,
+		cc.Spec.Hooks.Finalize != nil || cleansUpChildNamespaces(cc, parents),
+	)
+	childFinalizer, err := makeChildFinalizer(resources, cc, parentFinalizer, eventRecorder)
 	if err != nil {
-		return nil, fmt.Errorf("can't create informer for parent resource: %v", err)
+		return nil, err
+	}
+	ownerRefs, err := makeChildOwnerReferences(resources, cc)
+	if err != nil {
+		return nil, err
+	}
+	noPrune, err := makeNoPruneKinds(resources, cc)
+	if err != nil {
+		return nil, err
+	}
+	scaleDown, err := makeScaleDownKinds(resources, cc)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create informers for all child resources.
+	// Create informers for all parent and child resources, and for namespaces.
 	childInformers := make(common.InformerMap)
+	var namespaceInformer *dynamicinformer.ResourceInformer
 	defer func() {
 		if newErr != nil {
 			// If newParentController fails, Close() any informers we created
@@ -106,9 +259,23 @@ func newParentController(resources *dynamicdiscovery.ResourceMap, dynClient *dyn
 			for _, childInformer := range childInformers {
 				childInformer.Close()
 			}
-			parentInformer.Close()
+			if namespaceInformer != nil {
+				namespaceInformer.Close()
+			}
+			for _, parent := range parents {
+				if parent.informer != nil {
+					parent.informer.Close()
+				}
+			}
 		}
 	}()
+	for _, parent := range parents {
+		parentInformer, err := dynInformers.Resource(parent.APIVersion, parent.Name)
+		if err != nil {
+			return nil, fmt.Errorf("can't create informer for parent resource: %v", err)
+		}
+		parent.informer = parentInformer
+	}
 	for _, child := range cc.Spec.ChildResources {
 		childInformer, err := dynInformers.Resource(child.APIVersion, child.Resource)
 		if err != nil {
@@ -120,13 +287,17 @@ func newParentController(resources *dynamicdiscovery.ResourceMap, dynClient *dyn
 		}
 		childInformers.Set(groupVersion.WithResource(child.Resource), childInformer)
 	}
+	namespaceInformer, err = dynInformers.Resource("v1", "namespaces")
+	if err != nil {
+		return nil, fmt.Errorf("can't create informer for namespaces: %v", err)
+	}
 
-	parentGroupVersion := schema.GroupVersion{Group: parentResource.Group, Version: parentResource.Version}
-
-	parentResources := make(common.GroupKindMap)
-	parentResources.Set(schema.GroupKind{Group: parentGroupVersion.Group, Kind: parentResource.Kind}, parentResource)
+	parentKinds := make(common.GroupKindMap)
 	parentInformers := make(common.InformerMap)
-	parentInformers.Set(parentGroupVersion.WithResource(parentResource.Name), parentInformer)
+	for groupKind, parent := range parents {
+		parentKinds.Set(groupKind, parent.APIResource)
+		parentInformers.Set(parent.GroupVersion().WithResource(parent.Name), parent.informer)
+	}
 
 	pc = &parentController{
 		cc:             cc,
@@ -134,30 +305,51 @@ func newParentController(resources *dynamicdiscovery.ResourceMap, dynClient *dyn
 		mcClient:       mcClient,
 		dynClient:      dynClient,
 		childInformers: childInformers,
-		parentClient:   parentClient,
-		parentInformer: parentInformer,
-		parentResource: parentResource,
+		parents:        parents,
 		revisionLister: revisionLister,
 		updateStrategy: updateStrategy,
+		childPatches:   childPatches,
+		ownerRefs:      ownerRefs,
+		adoptions:      adoptions,
+		namespaces:     namespaces,
+		noPrune:        noPrune,
+		scaleDown:      scaleDown,
+		resyncSchedule: resyncSchedule,
+		resyncRequest:  cc.Annotations[common.ResyncRequestAnnotation],
 		queue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "CompositeController-"+cc.Name),
+		syncRetries:    common.NewSyncRetries("CompositeController", cc.Name),
+		objectCounts:   common.NewObjectCounts("CompositeController", cc.Name),
+		revisionCounts: common.NewRevisionCounts("CompositeController", cc.Name),
 		numWorkers:     numWorkers,
 		eventRecorder:  eventRecorder,
-		finalizer: &finalizer.Manager{
-			Name:    "metacontroller.io/compositecontroller-" + cc.Name,
-			Enabled: cc.Spec.Hooks.Finalize != nil,
-		},
+		log:            logging.ForController("CompositeController", cc.Name),
+		finalizer:      parentFinalizer,
+		childFinalizer: childFinalizer,
+		admission:      admissionServer,
 	}
+	pc.childKinds = childKinds
+	pc.childKindPolicy = childKindPolicy
+	pc.namespaceInformer = namespaceInformer
+	pc.childNamespaceSelector = childNamespaceSelector
+	pc.rollout = &rolloutShare{percent: noRollout}
+	pc.deletionProtection = common.NewDeletionProtection("CompositeController", cc.Name, cc.Spec.DeletionProtection)
+	pc.childUIDs = common.NewChildUIDs()
+	pc.statusWrites = common.NewStatusWrites(cc.Spec.StatusCoalesceSeconds)
 
 	pc.customize = customize.NewCustomizeManager(
-		parentResource.Kind,
+		cc.Name,
 		pc.enqueueParentObject,
 		cc,
 		dynClient,
 		dynInformers,
 		parentInformers,
-		parentResources,
+		parentKinds,
 	)
 
+	if err := pc.syncAdmissionWebhook(); err != nil {
+		return nil, err
+	}
+
 	return pc, nil
 }
 
@@ -167,11 +359,15 @@ func (pc *parentController) Start() {
 
 	pc.customize.Start(pc.stopCh)
 
+	if pc.admissionWebhook != nil {
+		pc.admission.Register(pc.admissionWebhook, pc.validate)
+	}
+
 	// Install event handlers. CompositeControllers can be created at any time,
 	// so we have to assume the shared informers are already running. We can't
 	// add event handlers in newParentController() since pc might be incomplete.
 	parentHandlers := cache.ResourceEventHandlerFuncs{
-		AddFunc:    pc.enqueueParentObject,
+		AddFunc:    pc.onParentAdd,
 		UpdateFunc: pc.updateParentObject,
 		DeleteFunc: pc.enqueueParentObject,
 	}
@@ -182,9 +378,13 @@ func (pc *parentController) Start() {
 		if resyncPeriod < time.Second {
 			resyncPeriod = time.Second
 		}
-		pc.parentInformer.Informer().AddEventHandlerWithResyncPeriod(parentHandlers, resyncPeriod)
+		for _, parent := range pc.parents {
+			parent.informer.Informer().AddEventHandlerWithResyncPeriod(parentHandlers, resyncPeriod)
+		}
 	} else {
-		pc.parentInformer.Informer().AddEventHandler(parentHandlers)
+		for _, parent := range pc.parents {
+			parent.informer.Informer().AddEventHandler(parentHandlers)
+		}
 	}
 	for _, childInformer := range pc.childInformers {
 		childInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -199,24 +399,39 @@ func (pc *parentController) Start() {
 		defer utilruntime.HandleCrash()
 
 		klog.InfoS("Starting CompositeController", "controller", klog.KObj(pc.cc))
-		pc.eventRecorder.Eventf(pc.cc, v1.EventTypeNormal, events.ReasonStarting, "Starting controller: %s", pc.cc.Name)
+		pc.eventRecorder.Eventf(pc.cc, v1.EventTypeNormal, events.ReasonStarting, "Starting controller: %s on replica %s", pc.cc.Name, leader.Identity())
 		defer klog.InfoS("Shutting down CompositeController", "controller", klog.KObj(pc.cc))
-		defer pc.eventRecorder.Eventf(pc.cc, v1.EventTypeNormal, events.ReasonStopping, "Stopping controller: %s", pc.cc.Name)
+		defer pc.eventRecorder.Eventf(pc.cc, v1.EventTypeNormal, events.ReasonStopping, "Stopping controller: %s on replica %s", pc.cc.Name, leader.Identity())
 
 		// Wait for dynamic client and all informers.
 		klog.InfoS("Waiting for CompositeController caches to sync", "controller", klog.KObj(pc.cc))
-		syncFuncs := make([]cache.InformerSynced, 0, 2+len(pc.cc.Spec.ChildResources))
-		syncFuncs = append(syncFuncs, pc.dynClient.HasSynced, pc.parentInformer.Informer().HasSynced)
+		syncFuncs := make([]cache.InformerSynced, 0, 2+len(pc.parents)+len(pc.cc.Spec.ChildResources))
+		syncFuncs = append(syncFuncs, pc.dynClient.HasSynced, pc.namespaceInformer.Informer().HasSynced)
+		for _, parent := range pc.parents {
+			syncFuncs = append(syncFuncs, parent.informer.Informer().HasSynced)
+		}
 		for _, childInformer := range pc.childInformers {
 			syncFuncs = append(syncFuncs, childInformer.Informer().HasSynced)
 		}
-		if !cache.WaitForNamedCacheSync(pc.parentResource.Kind, pc.stopCh, syncFuncs...) {
+		if !cache.WaitForNamedCacheSync(pc.cc.Name, pc.stopCh, syncFuncs...) {
 			// We wait forever unless Stop() is called, so this isn't an error.
 			klog.InfoS("CompositeController cache sync never finished", "controller", klog.KObj(pc.cc))
 			return
 		}
 
 		var wg sync.WaitGroup
+		if pc.resyncSchedule != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				common.RunSchedule(pc.resyncSchedule, pc.stopCh, pc.resyncAll)
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pc.orphanAudit().Run(pc.stopCh)
+		}()
 		for i := 0; i < pc.numWorkers; i++ {
 			wg.Add(1)
 			go func() {
@@ -229,6 +444,19 @@ func (pc *parentController) Start() {
 }
 
 func (pc *parentController) Stop() {
+	if pc.admissionWebhook != nil {
+		pc.admission.Unregister(pc.admissionWebhook)
+	}
+	pc.stop()
+	pc.syncRetries.Stop()
+	pc.objectCounts.Stop()
+	pc.revisionCounts.Stop()
+}
+
+// stop stops pc, but leaves the handler of its admission webhook, its sync
+// retries and its object counts, which it shares with the other
+// parentController of a rollout.
+func (pc *parentController) stop() {
 	close(pc.stopCh)
 	pc.queue.ShutDown()
 	<-pc.doneCh
@@ -238,12 +466,20 @@ func (pc *parentController) Stop() {
 		informer.Informer().RemoveEventHandlers()
 		informer.Close()
 	}
-	// Remove event handlers and close informer for the parent resource.
-	pc.parentInformer.Informer().RemoveEventHandlers()
-	pc.parentInformer.Close()
+	pc.namespaceInformer.Close()
+	// Remove event handlers and close informers for all parent resources.
+	for _, parent := range pc.parents {
+		parent.informer.Informer().RemoveEventHandlers()
+		parent.informer.Close()
+	}
+
+	if pc.stopEventRecorder != nil {
+		pc.stopEventRecorder()
+	}
 }
 
 func (pc *parentController) worker() {
+	common.SetProfileLabels("CompositeController", pc.cc.Name)
 	for pc.processNextWorkItem() {
 	}
 }
@@ -255,19 +491,182 @@ func (pc *parentController) processNextWorkItem() bool {
 	}
 	defer pc.queue.Done(key)
 
-	err := pc.sync(key.(string))
+	if !pc.rollout.handles(key.(string), pc.candidate) {
+		// The other parentController of the rollout syncs this parent.
+		pc.queue.Forget(key)
+		return true
+	}
+	// Look the parent up once, so all the checks below agree on it. If it's
+	// gone from the cache, the sync forgets about it.
+	parent := pc.cachedParent(key.(string))
+	if parent != nil {
+		if pc.syncRetries.Abandoned(key.(string), parent) {
+			// The sync failed permanently, and the parent didn't change since.
+			pc.queue.Forget(key)
+			return true
+		}
+		if pc.syncRetries.DeadLettered(key.(string), parent) {
+			// The sync failed too many times in a row, and nobody requeued
+			// the parent since.
+			pc.queue.Forget(key)
+			return true
+		}
+		if wait, ok := pc.syncRetries.Quarantined(key.(string), parent); ok {
+			// Only sync quarantined parents at their scheduled retry, since
+			// other enqueues may have replaced it.
+			pc.queue.Forget(key)
+			pc.queue.AddAfter(key, wait)
+			return true
+		}
+	}
+
+	release := common.AcquireSyncSlot("CompositeController", pc.cc.Name)
+	err := common.TimeSync("CompositeController", pc.cc.Name, parent, func() error {
+		return pc.sync(key.(string))
+	})
+	release()
+	if hookErr := hooks.AsError(err); hookErr != nil && hookErr.EventOnly {
+		if parent != nil {
+			common.ReportHookError(pc.eventRecorder, parent, hookErr)
+		}
+		err = nil
+	}
 	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("failed to sync %v %q: %v", pc.parentResource.Kind, key, err))
-		pc.queue.AddRateLimited(key)
+		utilruntime.HandleError(fmt.Errorf("failed to sync parent %q: %v", key, err))
+		failures, delay := pc.syncRetries.Failed(key.(string))
+		delay, retry := common.SyncRetryDelay(err, delay)
+		deadLetter := retry && pc.syncRetries.Exhausted(failures)
+		quarantined := false
+		if parent != nil && retry && !deadLetter {
+			if interval, ok := pc.syncRetries.Quarantine(key.(string), parent, err); ok {
+				delay, quarantined = interval, true
+			}
+		}
+		if retry && !deadLetter {
+			pc.queue.AddAfter(key, delay)
+		} else {
+			pc.queue.Forget(key)
+		}
+		if parent != nil {
+			if deadLetter {
+				if err := pc.syncRetries.DeadLetter(pc.eventRecorder, pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).client, key.(string), parent, failures, err); err != nil {
+					utilruntime.HandleError(fmt.Errorf("can't dead-letter parent %q: %v", key, err))
+					pc.queue.AddAfter(key, delay)
+				}
+			} else if retry {
+				common.ReportSyncFailure(pc.eventRecorder, pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).client, parent, failures, delay, err, pc.cc.Spec.SyncFailureAnnotations)
+				if quarantined {
+					common.ReportQuarantine(pc.eventRecorder, parent, delay)
+				}
+			} else {
+				pc.syncRetries.FailedPermanently(key.(string), parent)
+				common.ReportPermanentSyncFailure(pc.eventRecorder, parent, err)
+				if err := common.SetSyncFailedCondition(pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).client, parent, err); err != nil {
+					utilruntime.HandleError(err)
+				}
+			}
+			pc.syncRetries.Notify(parent, failures, err)
+		}
 		return true
 	}
 
+	pc.syncRetries.Succeeded(key.(string))
 	pc.queue.Forget(key)
+	if parent != nil {
+		if err := common.ClearSyncFailures(pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).client, parent); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
 	return true
 }
 
+// matches returns whether parent must be synced, according to the match
+// expression of its resource. Parents being deleted are always synced, so
+// their finalizer is released.
+func (r *parentResource) matches(log logr.Logger, parent *unstructured.Unstructured) bool {
+	if r.match == nil || parent.GetDeletionTimestamp() != nil {
+		return true
+	}
+	matches, err := r.match.Matches(parent.UnstructuredContent())
+	if err != nil {
+		log.Info("Match expression failed, ignoring parent", "err", err)
+		return false
+	}
+	return matches
+}
+
+// cachedParent returns the parent with the given queue key from the cache,
+// or nil if it's not there.
+func (pc *parentController) cachedParent(key string) *unstructured.Unstructured {
+	apiVersion, kind, namespace, name, err := common.SplitParentQueueKey(key)
+	if err != nil {
+		return nil
+	}
+	resource := pc.parentResourceOf(apiVersion, kind)
+	if resource == nil {
+		return nil
+	}
+	parent, err := common.GetObject(resource.informer, namespace, name)
+	if err != nil {
+		return nil
+	}
+	return parent
+}
+
+// parentResourceOf returns the parent resource with the group of apiVersion
+// and the given kind, or nil if there's none. Versions don't matter.
+func (pc *parentController) parentResourceOf(apiVersion, kind string) *parentResource {
+	apiGroup, _ := common.ParseAPIVersion(apiVersion)
+	return pc.parents[schema.GroupKind{Group: apiGroup, Kind: kind}]
+}
+
+// clusterInfo returns what hooks are told about the cluster when syncing
+// parent.
+func (pc *parentController) clusterInfo(parent *unstructured.Unstructured) *common.ClusterInfo {
+	childAPIVersions := make([]string, 0, len(pc.cc.Spec.ChildResources))
+	for _, child := range pc.cc.Spec.ChildResources {
+		childAPIVersions = append(childAPIVersions, child.APIVersion)
+	}
+	return common.NewClusterInfo(pc.resources, pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).APIResource, childAPIVersions)
+}
+
+// syncPressure returns what hooks are told about the pressure on the
+// controller when syncing parent.
+func (pc *parentController) syncPressure(parent *unstructured.Unstructured) *common.SyncPressure {
+	key, err := common.ParentQueueKey(parent)
+	if err != nil {
+		return nil
+	}
+	return pc.syncRetries.Pressure(key, pc.queue.Len())
+}
+
+// previousResponseHash returns the hash of the hook response applied in the
+// last successful sync of parent.
+func (pc *parentController) previousResponseHash(parent *unstructured.Unstructured) string {
+	key, err := common.ParentQueueKey(parent)
+	if err != nil {
+		return ""
+	}
+	return pc.syncRetries.PreviousResponseHash(key)
+}
+
+// onParentAdd enqueues a parent seen for the first time, such as when the
+// controller starts. If it was failing to sync before, its retry backoff is
+// resumed from its sync failure annotations.
+func (pc *parentController) onParentAdd(obj interface{}) {
+	if parent, ok := obj.(*unstructured.Unstructured); ok {
+		if key, err := common.ParentQueueKey(parent); err == nil {
+			if delay := pc.syncRetries.Resume(key, parent); delay > 0 {
+				pc.queue.AddAfter(key, delay)
+				return
+			}
+		}
+	}
+	pc.enqueueParentObject(obj)
+}
+
 func (pc *parentController) enqueueParentObject(obj interface{}) {
-	key, err := common.KeyFunc(obj)
+	key, err := common.ParentQueueKey(obj)
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
 		return
@@ -275,8 +674,23 @@ func (pc *parentController) enqueueParentObject(obj interface{}) {
 	pc.queue.Add(key)
 }
 
+// resyncAll enqueues every parent, for scheduled resyncs.
+func (pc *parentController) resyncAll() {
+	for _, resource := range pc.parents {
+		parents, err := resource.informer.Lister().List(labels.Everything())
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("can't list %v for scheduled resync: %v", resource.Kind, err))
+			continue
+		}
+		klog.V(4).InfoS("Scheduled resync", "controller", klog.KObj(pc.cc), "parent_kind", resource.Kind, "count", len(parents))
+		for _, parent := range parents {
+			pc.enqueueParentObject(parent)
+		}
+	}
+}
+
 func (pc *parentController) enqueueParentObjectAfter(obj interface{}, delay time.Duration) {
-	key, err := common.KeyFunc(obj)
+	key, err := common.ParentQueueKey(obj)
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
 		return
@@ -292,6 +706,14 @@ func (pc *parentController) updateParentObject(old, cur interface{}) {
 	// different status (e.g. you have some incrementing counter).
 	// Doing that is an anti-pattern anyway because status generation should be
 	// idempotent if nothing meaningful has actually changed in the system.
+	//
+	// We do ignore our own updates of the sync failure annotations though,
+	// since syncing right away would defeat the retry backoff.
+	if oldParent, ok := old.(*unstructured.Unstructured); ok {
+		if curParent, ok := cur.(*unstructured.Unstructured); ok && common.OnlySyncFailuresChanged(oldParent, curParent) {
+			return
+		}
+	}
 	pc.enqueueParentObject(cur)
 }
 
@@ -301,20 +723,18 @@ func (pc *parentController) updateParentObject(old, cur interface{}) {
 func (pc *parentController) resolveControllerRef(childNamespace string, controllerRef *metav1.OwnerReference) *unstructured.Unstructured {
 	// We can't look up by UID, so look up by Name and then verify UID.
 	// Don't even try to look up by Name if it's the wrong APIGroup or Kind.
-	if apiGroup, _ := common.ParseAPIVersion(controllerRef.APIVersion); apiGroup != pc.parentResource.Group {
-		return nil
-	}
-	if controllerRef.Kind != pc.parentResource.Kind {
+	resource := pc.parentResourceOf(controllerRef.APIVersion, controllerRef.Kind)
+	if resource == nil {
 		return nil
 	}
 	parentNamespace := ""
-	if pc.parentResource.Namespaced {
+	if resource.Namespaced {
 		// If the parent is namespaced, it must be in the same namespace as the
 		// child because controllerRef does not support cross-namespace references
 		// (except for namespaced child -> cluster-scoped parent).
 		parentNamespace = childNamespace
 	}
-	parent, err := common.GetObject(pc.parentInformer, parentNamespace, controllerRef.Name)
+	parent, err := common.GetObject(resource.informer, parentNamespace, controllerRef.Name)
 	if err != nil {
 		return nil
 	}
@@ -341,18 +761,23 @@ func (pc *parentController) onChildAdd(obj interface{}) {
 			// The controllerRef isn't a parent we know about.
 			return
 		}
-		klog.V(4).InfoS("Child created or updated", "parent_kind", pc.parentResource.Kind, "parent", klog.KObj(parent), "child_kind", child.GetKind(), "child", klog.KObj(child))
+		logging.ForParent(pc.log, parent.GetKind(), klog.KObj(parent)).V(4).Info("Child created or updated", "child_kind", child.GetKind(), "child", klog.KObj(child))
 		pc.enqueueParentObject(parent)
 		return
 	}
 
+	// Children in another namespace than their parent are tracked by label.
+	if pc.enqueueCrossNamespaceParent(child) {
+		return
+	}
+
 	// Otherwise, it's an orphan. Get a list of all matching parents and sync
 	// them to see if anyone wants to adopt it.
 	parents := pc.findPotentialParents(child)
 	if len(parents) == 0 {
 		return
 	}
-	klog.V(4).InfoS("Orphan child created or updated", "parent_kind", pc.parentResource.Kind, "child_kind", child.GetKind(), "child", klog.KObj(child))
+	klog.V(4).InfoS("Orphan child created or updated", "controller", klog.KObj(pc.cc), "child_kind", child.GetKind(), "child", klog.KObj(child))
 	for _, parent := range parents {
 		pc.enqueueParentObject(parent)
 	}
@@ -390,9 +815,10 @@ func (pc *parentController) onChildDelete(obj interface{}) {
 	}
 
 	// If it's an orphan, there's nothing to do because we never adopt orphans
-	// that are being deleted.
+	// that are being deleted, unless it's tracked by label.
 	controllerRef := metav1.GetControllerOf(child)
 	if controllerRef == nil {
+		pc.enqueueCrossNamespaceParent(child)
 		return
 	}
 
@@ -402,7 +828,7 @@ func (pc *parentController) onChildDelete(obj interface{}) {
 		// The controllerRef isn't a parent we know about.
 		return
 	}
-	klog.V(4).InfoS("Child deleted", "parent_kind", pc.parentResource.Kind, "parent", klog.KObj(parent), "child_kind", child.GetKind(), "child", klog.KObj(child))
+	logging.ForParent(pc.log, parent.GetKind(), klog.KObj(parent)).V(4).Info("Child deleted", "child_kind", child.GetKind(), "child", klog.KObj(child))
 	pc.enqueueParentObject(parent)
 }
 
@@ -410,15 +836,19 @@ func (pc *parentController) findPotentialParents(child *unstructured.Unstructure
 	childLabels := labels.Set(child.GetLabels())
 
 	var parents []*unstructured.Unstructured
-	var err error
-	if pc.parentResource.Namespaced {
-		// If the parent is namespaced, it must be in the same namespace as the child.
-		parents, err = pc.parentInformer.Lister().Namespace(child.GetNamespace()).List(labels.Everything())
-	} else {
-		parents, err = pc.parentInformer.Lister().List(labels.Everything())
-	}
-	if err != nil {
-		return nil
+	for _, resource := range pc.parents {
+		var objects []*unstructured.Unstructured
+		var err error
+		if resource.Namespaced {
+			// If the parent is namespaced, it must be in the same namespace as the child.
+			objects, err = resource.informer.Lister().Namespace(child.GetNamespace()).List(labels.Everything())
+		} else {
+			objects, err = resource.informer.Lister().List(labels.Everything())
+		}
+		if err != nil {
+			continue
+		}
+		parents = append(parents, objects...)
 	}
 
 	var matchingParents []*unstructured.Unstructured
@@ -435,29 +865,54 @@ func (pc *parentController) findPotentialParents(child *unstructured.Unstructure
 }
 
 func (pc *parentController) sync(key string) error {
-	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	apiVersion, kind, namespace, name, err := common.SplitParentQueueKey(key)
 	if err != nil {
 		return err
 	}
+	resource := pc.parentResourceOf(apiVersion, kind)
+	if resource == nil {
+		return fmt.Errorf("unknown parent kind %q in apiVersion %q", kind, apiVersion)
+	}
 
-	klog.V(4).InfoS("Sync", "parent_kind", pc.parentResource.Kind, "object", klog.KRef(namespace, name))
+	log := logging.ForParent(pc.log, kind, klog.KRef(namespace, name))
+	log.V(4).Info("Sync")
 
-	parent, err := common.GetObject(pc.parentInformer, namespace, name)
+	parent, err := common.GetParent(resource.informer, resource.client, namespace, name, pc.cc.Spec.FreshParentRead)
 	if apierrors.IsNotFound(err) {
 		// Swallow the error since there's no point retrying if the parent is gone.
-		klog.V(4).InfoS("Object has been deleted", "parent_kind", pc.parentResource.Kind, "object", klog.KRef(namespace, name))
+		log.V(4).Info("Object has been deleted")
+		pc.objectCounts.Forget(key)
+		pc.revisionCounts.Forget(key)
+		pc.syncRetries.Forget(key)
+		pc.deletionProtection.Forget(key)
+		pc.childUIDs.Forget(key)
+		pc.statusWrites.Forget(key)
 		return nil
 	}
 	if err != nil {
 		return err
 	}
-	return pc.syncParentObject(parent)
+	if !resource.matches(log, parent) {
+		log.V(4).Info("Parent doesn't match, ignoring it")
+		return nil
+	}
+	if err := pc.syncParentObject(log, parent); err != nil {
+		return err
+	}
+	pc.syncRetries.Synced(key)
+	return nil
 }
 
-func (pc *parentController) syncParentObject(parent *unstructured.Unstructured) error {
+func (pc *parentController) syncParentObject(log logr.Logger, parent *unstructured.Unstructured) error {
+	if pc.cc.Spec.Mode == v1alpha1.ControllerModeObserve {
+		return pc.observeParentObject(parent)
+	}
+
+	parentClient := pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).client
+
 	// Before taking any other action, add our finalizer (if desired).
 	// This ensures we have a chance to clean up after any action we later take.
-	updatedParent, err := pc.finalizer.SyncObject(pc.parentClient, parent)
+	updatedParent, err := pc.finalizer.SyncObject(parentClient, parent)
 	if err != nil {
 		// If we fail to do this, abort before doing anything else and requeue.
 		return fmt.Errorf("can't sync finalizer for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
@@ -465,24 +920,71 @@ func (pc *parentController) syncParentObject(parent *unstructured.Unstructured)
 	parent = updatedParent
 
 	// Claim all matching child resources, including orphan/adopt as necessary.
-	observedChildren, err := pc.claimChildren(parent)
+	// In Audit mode, orphans are only reported.
+	var observedChildren common.ChildMap
+	var audit *common.Audit
+	if pc.cc.Spec.Mode == v1alpha1.ControllerModeAudit {
+		observedChildren, audit, err = pc.auditChildren(parent)
+	} else {
+		observedChildren, err = pc.claimChildren(parent)
+	}
+	if err != nil {
+		return err
+	}
+	key, err := common.ParentQueueKey(parent)
 	if err != nil {
 		return err
 	}
+	pc.objectCounts.Observe(key, observedChildren)
+	pc.childUIDs.Observe(key, parent, observedChildren)
+
+	// Keep children pending deletion until the sync hook below has seen them.
+	holdFor, err := pc.childFinalizer.HoldChildren(pc.dynClient, parent, observedChildren)
+	if err != nil {
+		return fmt.Errorf("can't release children of %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	if holdFor > 0 {
+		pc.enqueueParentObjectAfter(parent, holdFor)
+	}
 
 	relatedObjects, err := pc.customize.GetRelatedObjects(parent)
 	if err != nil {
 		return err
 	}
 
+	// Summarize child readiness, if requested, so hooks don't have to.
+	readiness := common.SummarizeReadiness(pc.cc.Spec.ChildReadiness, observedChildren)
+
 	// Reconcile ControllerRevisions belonging to this parent.
 	// Call the sync hook for each revision, then compute the overall status and
 	// desired children, accounting for any rollout in progress.
-	syncResult, err := pc.syncRevisions(parent, observedChildren, relatedObjects)
+	// While a rollback is requested, hooks see the parent as it was then.
+	syncParent, err := pc.rolledBackParent(parent)
+	if err != nil {
+		return err
+	}
+	syncResult, err := pc.syncDesired(parent, syncParent, observedChildren, relatedObjects, readiness)
 	if err != nil {
 		return err
 	}
+	responseHash := common.ResponseHash(syncResult)
+	// Place children that don't set a namespace, if their kind has a template.
+	if err := pc.namespaces.apply(parent, syncResult.Children); err != nil {
+		return err
+	}
 	desiredChildren := common.MakeChildMap(parent, syncResult.Children)
+	if err := pc.childKindPolicy.CheckChildren(pc.childKinds, desiredChildren); err != nil {
+		return fmt.Errorf("invalid sync hook response for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	// Don't create children in place of objects that are left alone.
+	desiredChildren = pc.childUIDs.WithoutIgnored(key, parent, desiredChildren)
+
+	// Hold back children of waves that have to wait for lower waves to be ready.
+	desiredChildren, waitingChildren := common.GateWaves(observedChildren, desiredChildren)
+	readiness.AddWaiting(waitingChildren)
+	// Likewise for children in namespaces that are children yet to be created.
+	desiredChildren, waitingChildren = common.WaitForNamespaces(observedChildren, desiredChildren)
+	readiness.AddWaiting(waitingChildren)
 
 	// Enqueue a delayed resync, if requested.
 	if syncResult.ResyncAfterSeconds > 0 {
@@ -492,18 +994,155 @@ func (pc *parentController) syncParentObject(parent *unstructured.Unstructured)
 	// If all revisions agree that they've finished finalizing,
 	// remove our finalizer.
 	if syncResult.Finalized {
-		updatedParent, err := pc.parentClient.Namespace(parent.GetNamespace()).RemoveFinalizer(parent, pc.finalizer.Name)
+		// Release our finalizer from children first, since nobody will be left
+		// to release them once the parent is gone.
+		if err := pc.childFinalizer.ReleaseChildren(pc.dynClient, observedChildren); err != nil {
+			return fmt.Errorf("can't release children of %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+		}
+		updatedParent, err := pc.finalizer.RemoveFinalizer(parentClient, parent)
 		if err != nil {
 			return fmt.Errorf("can't remove finalizer for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
 		}
 		parent = updatedParent
 	}
 
+	// Make sure desired children are only in the namespaces they may be in.
+	if err := pc.checkChildNamespaces(parent, desiredChildren); err != nil {
+		return err
+	}
+
 	// Enforce invariants between parent selector and child labels.
+	if err := pc.labelDesiredChildren(parent, desiredChildren); err != nil {
+		return err
+	}
+
+	// Reconcile child objects belonging to this parent.
+	// Remember manage error, but continue to update status regardless.
+	//
+	// We only manage children if the parent is "alive" (not pending deletion),
+	// or if it's pending deletion and we have a `finalize` hook.
+	var manageErr error
+	if parent.GetDeletionTimestamp() == nil || pc.finalizer.ShouldFinalize(parent) {
+		// Reconcile children.
+		// Children the hook patched are left out, so they're only patched.
+		manageChildren, childPatchPlan, err := common.PlanChildPatches(parent, observedChildren, desiredChildren, syncResult.Patches)
+		if err != nil {
+			return err
+		}
+		// The hook may only have returned the children that changed, and
+		// the ones to delete.
+		if syncResult.OthersUnchanged {
+			manageChildren, err = common.KeepUnchanged(parent, manageChildren, desiredChildren, syncResult.Deleted)
+			if err != nil {
+				return err
+			}
+		}
+		// Children of some kinds are never deleted, if requested.
+		manageChildren = pc.noPrune.Filter(manageChildren, desiredChildren)
+		// While finalizing, children are deleted in order, if requested.
+		if parent.GetDeletionTimestamp() != nil {
+			manageChildren = common.OrderDeletions(manageChildren, desiredChildren)
+		}
+		if pc.cc.Spec.AdoptOnly {
+			manageChildren, desiredChildren = common.AdoptOnly(manageChildren, desiredChildren)
+		}
+		if audit != nil {
+			manageChildren, desiredChildren = audit.Filter(manageChildren, desiredChildren)
+			audit.Report(pc.eventRecorder)
+		}
+		desiredChildren = common.SkipTerminatingNamespaces(pc.namespaceInformer.Lister(), parent, manageChildren, desiredChildren)
+		manageChildren, retryAfter := pc.deletionProtection.Filter(pc.eventRecorder, key, parent, manageChildren, desiredChildren)
+		if retryAfter > 0 {
+			pc.enqueueParentObjectAfter(parent, retryAfter)
+		}
+		// Workloads may have to scale down before they're deleted.
+		manageChildren, retryAfter, err = pc.scaleDown.Filter(log, pc.dynClient, manageChildren, desiredChildren, time.Now())
+		if retryAfter > 0 {
+			pc.enqueueParentObjectAfter(parent, retryAfter)
+		}
+		if err == nil && pc.cc.Spec.DryRunChildren {
+			err = common.DryRunChildren(pc.dynClient, pc.eventRecorder, parent, manageChildren, desiredChildren)
+		}
+		if err == nil {
+			manage := common.ManageChildren
+			if pc.cc.Spec.RollbackChildren {
+				manage = common.ManageChildrenWithRollback
+			}
+			retryAfter, err = manage(log, pc.dynClient, pc.eventRecorder, pc.cc.Spec.ChildApplyMode, pc.updateStrategy, pc.childFinalizer, pc.ownerRefs, parent, manageChildren, desiredChildren, int(pc.cc.Spec.ChildWriteParallelism))
+			if retryAfter > 0 {
+				pc.enqueueParentObjectAfter(parent, retryAfter)
+			}
+		}
+		if err == nil {
+			err = childPatchPlan.Send(log, pc.dynClient)
+		}
+		// Delete children of removed kinds, and record the ones left.
+		if err == nil && pc.cc.Spec.Inventory && audit == nil && !pc.cc.Spec.AdoptOnly {
+			parent, err = pc.syncInventory(log, parentClient, parent, observedChildren, desiredChildren)
+		}
+		if err != nil {
+			manageErr = fmt.Errorf("can't reconcile children for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+		}
+	} else if err := pc.childFinalizer.ReleaseChildren(pc.dynClient, observedChildren); err != nil {
+		// We're not going to manage children anymore (e.g. the GC is deleting
+		// them), so don't let our finalizer hold them up.
+		manageErr = fmt.Errorf("can't release children of %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+
+	// Update parent status.
+	// We'll want to make sure this happens after manageChildren once we support observedGeneration.
+	if syncResult.Status == nil {
+		syncResult.Status = make(map[string]interface{})
+	}
+	common.SetReadinessStatus(pc.cc.Spec.ChildReadiness, syncResult.Status, readiness)
+	if audit != nil {
+		audit.SetStatus(syncResult.Status)
+	}
+	if err := pc.setScaleStatus(parent, syncResult.Status); err != nil {
+		return fmt.Errorf("can't set scale status for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	if err := pc.writeStatus(key, parent, syncResult.Status, responseHash, manageErr == nil); err != nil {
+		return err
+	}
+
+	return manageErr
+}
+
+// writeStatus updates the status of parent, then records responseHash as the
+// applied response if its children were applied too. A status that's
+// coalesced with the one of a later sync isn't written yet, so the response
+// is only recorded by the sync that writes it.
+func (pc *parentController) writeStatus(key string, parent *unstructured.Unstructured, status map[string]interface{}, responseHash string, childrenApplied bool) error {
+	_, deferred, err := pc.updateParentStatus(parent, status)
+	if err != nil {
+		return fmt.Errorf("can't update status for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	if childrenApplied && !deferred {
+		pc.syncRetries.Applied(key, responseHash)
+	}
+	return nil
+}
+
+// labelDesiredChildren adds the labels the sync path adds to desiredChildren:
+// the controller-uid label if selector generation is enabled, and the labels
+// the parent selector matches on if InjectSelectorLabels is set. It returns
+// an error if a child still doesn't match the parent selector.
+func (pc *parentController) labelDesiredChildren(parent *unstructured.Unstructured, desiredChildren common.ChildMap) error {
+	labelSelector, err := pc.parentLabelSelector(parent)
+	if err != nil {
+		return err
+	}
 	selector, err := pc.makeSelector(parent, nil)
 	if err != nil {
 		return err
 	}
+	// If selector generation is enabled, add the controller-uid label to all
+	// desired children so they match the generated selector. Likewise, add
+	// the labels the parent selector matches on, if requested.
+	var injectLabels map[string]string
+	if (pc.cc.Spec.GenerateSelector != nil && *pc.cc.Spec.GenerateSelector) || pc.cc.Spec.InjectSelectorLabels {
+		injectLabels = labelSelector.MatchLabels
+	}
 	for _, group := range desiredChildren {
 		for _, obj := range group {
 			// We don't use GetLabels() because that swallows conversion errors.
@@ -511,65 +1150,64 @@ func (pc *parentController) syncParentObject(parent *unstructured.Unstructured)
 			if err != nil {
 				return fmt.Errorf("invalid labels on desired child %v %v/%v: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
 			}
-			// If selector generation is enabled, add the controller-uid label to all
-			// desired children so they match the generated selector.
-			if pc.cc.Spec.GenerateSelector != nil && *pc.cc.Spec.GenerateSelector {
-				if objLabels == nil {
-					objLabels = make(map[string]string, 1)
-				}
-				if _, ok := objLabels["controller-uid"]; !ok {
-					objLabels["controller-uid"] = string(parent.GetUID())
-					obj.SetLabels(objLabels)
-				}
+			if objLabels, ok := addMissingLabels(objLabels, injectLabels); ok {
+				obj.SetLabels(objLabels)
 			}
 			// Make sure all desired children match the parent's selector.
 			// We consider it user error to try to create children that would be
 			// immediately orphaned.
-			if !selector.Matches(labels.Set(objLabels)) {
-				return fmt.Errorf("labels on desired child %v %v/%v don't match parent selector", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+			if !selector.Matches(labels.Set(obj.GetLabels())) {
+				return fmt.Errorf("labels on desired child %v %v/%v don't match parent selector %q", obj.GetKind(), obj.GetNamespace(), obj.GetName(), selector)
 			}
 		}
 	}
+	return nil
+}
 
-	// Reconcile child objects belonging to this parent.
-	// Remember manage error, but continue to update status regardless.
-	//
-	// We only manage children if the parent is "alive" (not pending deletion),
-	// or if it's pending deletion and we have a `finalize` hook.
-	var manageErr error
-	if parent.GetDeletionTimestamp() == nil || pc.finalizer.ShouldFinalize(parent) {
-		// Reconcile children.
-		if err := common.ManageChildren(pc.dynClient, pc.updateStrategy, parent, observedChildren, desiredChildren); err != nil {
-			manageErr = fmt.Errorf("can't reconcile children for %v %v/%v: %v", pc.parentResource.Kind, parent.GetNamespace(), parent.GetName(), err)
+// addMissingLabels adds the given labels to objLabels, unless they're already
+// set (even to another value). It returns whether objLabels changed.
+func addMissingLabels(objLabels, add map[string]string) (map[string]string, bool) {
+	changed := false
+	for key, value := range add {
+		if _, ok := objLabels[key]; ok {
+			continue
 		}
+		if objLabels == nil {
+			objLabels = make(map[string]string, len(add))
+		}
+		objLabels[key] = value
+		changed = true
 	}
-
-	// Update parent status.
-	// We'll want to make sure this happens after manageChildren once we support observedGeneration.
-	if _, err := pc.updateParentStatus(parent, syncResult.Status); err != nil {
-		return fmt.Errorf("can't update status for %v %v/%v: %v", pc.parentResource.Kind, parent.GetNamespace(), parent.GetName(), err)
-	}
-
-	return manageErr
+	return objLabels, changed
 }
 
-func (pc *parentController) makeSelector(parent *unstructured.Unstructured, extraMatchLabels map[string]string) (labels.Selector, error) {
+// parentLabelSelector returns the label selector of parent, or the one
+// generated for it if selector generation is enabled.
+func (pc *parentController) parentLabelSelector(parent *unstructured.Unstructured) (*metav1.LabelSelector, error) {
 	labelSelector := &metav1.LabelSelector{}
 
 	if pc.cc.Spec.GenerateSelector != nil && *pc.cc.Spec.GenerateSelector {
 		// Select by controller-uid, like Job does.
 		// Any selector on the parent is ignored in this case.
-		labelSelector = metav1.AddLabelToSelector(labelSelector, "controller-uid", string(parent.GetUID()))
-	} else {
-		// Get the parent's LabelSelector.
-		if err := k8s.GetNestedFieldInto(labelSelector, parent.UnstructuredContent(), "spec", "selector"); err != nil {
-			return nil, fmt.Errorf("can't get label selector from %v %v/%v", pc.parentResource.Kind, parent.GetNamespace(), parent.GetName())
-		}
-		// An empty selector doesn't make sense for a CompositeController parent.
-		// This is likely user error, and could be dangerous (selecting everything).
-		if len(labelSelector.MatchLabels) == 0 && len(labelSelector.MatchExpressions) == 0 {
-			return nil, fmt.Errorf(".spec.selector must have either matchLabels, matchExpressions, or both")
-		}
+		return metav1.AddLabelToSelector(labelSelector, "controller-uid", string(parent.GetUID())), nil
+	}
+
+	// Get the parent's LabelSelector.
+	if err := k8s.GetNestedFieldInto(labelSelector, parent.UnstructuredContent(), "spec", "selector"); err != nil {
+		return nil, common.Permanent(fmt.Errorf("can't get label selector from %v %v/%v", parent.GetKind(), parent.GetNamespace(), parent.GetName()))
+	}
+	// An empty selector doesn't make sense for a CompositeController parent.
+	// This is likely user error, and could be dangerous (selecting everything).
+	if len(labelSelector.MatchLabels) == 0 && len(labelSelector.MatchExpressions) == 0 {
+		return nil, common.Permanent(fmt.Errorf(".spec.selector must have either matchLabels, matchExpressions, or both"))
+	}
+	return labelSelector, nil
+}
+
+func (pc *parentController) makeSelector(parent *unstructured.Unstructured, extraMatchLabels map[string]string) (labels.Selector, error) {
+	labelSelector, err := pc.parentLabelSelector(parent)
+	if err != nil {
+		return nil, err
 	}
 
 	for key, value := range extraMatchLabels {
@@ -578,7 +1216,7 @@ func (pc *parentController) makeSelector(parent *unstructured.Unstructured, extr
 
 	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
 	if err != nil {
-		return nil, fmt.Errorf("can't convert label selector (%#v): %v", labelSelector, err)
+		return nil, common.Permanent(fmt.Errorf("can't convert label selector (%#v): %v", labelSelector, err))
 	}
 	return selector, nil
 }
@@ -586,12 +1224,13 @@ func (pc *parentController) makeSelector(parent *unstructured.Unstructured, extr
 func (pc *parentController) canAdoptFunc(parent *unstructured.Unstructured) func() error {
 	return k8s.RecheckDeletionTimestamp(func() (metav1.Object, error) {
 		// Make sure this is always an uncached read.
-		fresh, err := pc.parentClient.Namespace(parent.GetNamespace()).Get(parent.GetName(), metav1.GetOptions{})
+		parentClient := pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).client
+		fresh, err := parentClient.Namespace(parent.GetNamespace()).Get(parent.GetName(), metav1.GetOptions{})
 		if err != nil {
 			return nil, err
 		}
 		if fresh.GetUID() != parent.GetUID() {
-			return nil, fmt.Errorf("original %v %v/%v is gone: got uid %v, wanted %v", pc.parentResource.Kind, parent.GetNamespace(), parent.GetName(), fresh.GetUID(), parent.GetUID())
+			return nil, fmt.Errorf("original %v %v/%v is gone: got uid %v, wanted %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), fresh.GetUID(), parent.GetUID())
 		}
 		return fresh, nil
 	})
@@ -600,7 +1239,8 @@ func (pc *parentController) canAdoptFunc(parent *unstructured.Unstructured) func
 func (pc *parentController) claimChildren(parent *unstructured.Unstructured) (common.ChildMap, error) {
 	// Set up values common to all child types.
 	parentNamespace := parent.GetNamespace()
-	parentGVK := pc.parentResource.GroupVersionKind()
+	parentResource := pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind())
+	parentGVK := parentResource.GroupVersionKind()
 	selector, err := pc.makeSelector(parent, nil)
 	if err != nil {
 		return nil, err
@@ -621,28 +1261,64 @@ func (pc *parentController) claimChildren(parent *unstructured.Unstructured) (co
 		if informer == nil {
 			return nil, fmt.Errorf("no informer for resource %q in apiVersion %q", child.Resource, child.APIVersion)
 		}
+		namespace := ""
+		if parentResource.Namespaced {
+			namespace = parentNamespace
+		}
 		var all []*unstructured.Unstructured
-		if pc.parentResource.Namespaced {
-			all, err = informer.Lister().Namespace(parentNamespace).List(labels.Everything())
+		if !child.BypassCache && pc.ownerRefs.IsController(childClient.Group, childClient.Kind) {
+			// Only the children of this parent and orphans can be claimed,
+			// so look them up in the index rather than scanning everything.
+			all, err = informer.ListControlledBy(parent.GetUID())
+			if err == nil {
+				var orphans []*unstructured.Unstructured
+				orphans, err = informer.ListOrphans(namespace)
+				all = append(all, orphans...)
+			}
 		} else {
-			all, err = informer.Lister().List(labels.Everything())
+			all, err = common.ListObjects(informer, childClient, namespace, labels.Everything(), child.BypassCache)
 		}
 		if err != nil {
 			return nil, fmt.Errorf("can't list %v children: %v", childClient.Kind, err)
 		}
+		// Never adopt the children that another parent tracks by label.
+		all = withoutOthersChildren(all, parent.GetUID())
+		// Children tracked by labels only can't be told apart from objects
+		// that took their place.
+		if ownerRef := pc.ownerRefs.Get(childClient.Group, childClient.Kind); ownerRef == nil || !ownerRef.LabelsOnly {
+			all, err = pc.handleUIDMismatches(childClient, parent, all)
+			if err != nil {
+				return nil, err
+			}
+		}
 
 		// Always include the requested groups, even if there are no entries.
 		childMap.InitGroup(child.APIVersion, childClient.Kind)
 
 		// Handle orphan/adopt and filter by owner+selector.
 		crm := dynamiccontrollerref.NewUnstructuredManager(childClient, parent, selector, parentGVK, childClient.GroupVersionKind(), canAdoptFunc)
+		crm.OwnerReference = pc.ownerRefs.Get(childClient.Group, childClient.Kind)
 		children, err := crm.ClaimChildren(all)
 		if err != nil {
 			return nil, fmt.Errorf("can't claim %v children: %v", childClient.Kind, err)
 		}
+		if adoption := pc.adoptions[groupVersion.WithResource(child.Resource)]; adoption != nil {
+			adopted, err := pc.adoptOrphans(parent, selector, crm, adoption, all)
+			if err != nil {
+				return nil, fmt.Errorf("can't adopt %v orphans: %w", childClient.Kind, err)
+			}
+			children = append(children, adopted...)
+		}
+		if pc.cc.Spec.ChildNamespaces != nil && parentResource.Namespaced && childClient.Namespaced {
+			crossNamespace, err := listCrossNamespaceChildren(informer, childClient, parent, selector, child.BypassCache)
+			if err != nil {
+				return nil, fmt.Errorf("can't list %v children in other namespaces: %v", childClient.Kind, err)
+			}
+			children = append(children, crossNamespace...)
+		}
 
-		// Add children to map by name.
-		// Note that we limit each parent to only working within its own namespace.
+		// Add children to map by name, or by namespace and name if they're in
+		// another namespace than their parent.
 		for _, obj := range children {
 			childMap.Insert(parent, obj)
 		}
@@ -650,24 +1326,127 @@ func (pc *parentController) claimChildren(parent *unstructured.Unstructured) (co
 	return childMap, nil
 }
 
-func (pc *parentController) updateParentStatus(parent *unstructured.Unstructured, status map[string]interface{}) (*unstructured.Unstructured, error) {
+// updateParentStatus writes status to parent, unless it's deferred to a later
+// sync because the status was just written.
+func (pc *parentController) updateParentStatus(parent *unstructured.Unstructured, status map[string]interface{}) (updated *unstructured.Unstructured, deferred bool, err error) {
 	// Inject ObservedGeneration before comparing with old status,
 	// so we're comparing against the final form we desire.
 	if status == nil {
 		status = make(map[string]interface{})
 	}
 	status["observedGeneration"] = parent.GetGeneration()
+	// Keep the transition times of conditions that didn't change.
+	common.MergeConditions(parent.UnstructuredContent()["status"], status, time.Now())
+
+	key, err := common.ParentQueueKey(parent)
+	if err != nil {
+		return nil, false, err
+	}
+	// If the status was just written by another sync, write this one along
+	// with the status of a later sync instead.
+	if delay := pc.statusWrites.Delay(key); delay > 0 && !common.StatusEqual(parent.UnstructuredContent()["status"], status) {
+		pc.enqueueParentObjectAfter(parent, delay)
+		return parent, true, nil
+	}
 
 	// Overwrite .status field of parent object without touching other parts.
 	// We can't use Patch() because we need to ensure that the UID matches.
-	return pc.parentClient.Namespace(parent.GetNamespace()).AtomicStatusUpdate(parent, func(obj *unstructured.Unstructured) bool {
+	parentClient := pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).client
+	written := false
+	updated, err = parentClient.Namespace(parent.GetNamespace()).AtomicStatusUpdate(parent, func(obj *unstructured.Unstructured) bool {
 		oldStatus := obj.UnstructuredContent()["status"]
-		if reflect.DeepEqual(oldStatus, status) {
-			// Nothing to do.
+		if common.StatusEqual(oldStatus, status) {
+			// Nothing to do, besides reordering conditions or bumping their
+			// timestamps.
+			written = false
 			return false
 		}
 
 		obj.UnstructuredContent()["status"] = status
+		written = true
 		return true
 	})
+	if err == nil && written {
+		pc.statusWrites.Written(key)
+	}
+	return updated, false, err
+}
+
+// makeChildFinalizer returns the finalizer to place on children of the kinds
+// that request it.
+func makeChildFinalizer(resources *dynamicdiscovery.ResourceMap, cc *v1alpha1.CompositeController, parentFinalizer *finalizer.Manager, eventRecorder record.EventRecorder) (*common.ChildFinalizer, error) {
+	childFinalizer := &common.ChildFinalizer{
+		Manager:       parentFinalizer,
+		Kinds:         make(map[schema.GroupKind]bool),
+		EventRecorder: eventRecorder,
+	}
+	for _, child := range cc.Spec.ChildResources {
+		if child.Finalize == nil || !*child.Finalize {
+			continue
+		}
+		if !parentFinalizer.Enabled {
+			return nil, fmt.Errorf("child resource %q in apiVersion %q: finalize requires a finalize hook", child.Resource, child.APIVersion)
+		}
+		resource := resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find child resource %q in %v", child.Resource, child.APIVersion)
+		}
+		childFinalizer.Kinds[schema.GroupKind{Group: resource.Group, Kind: resource.Kind}] = true
+	}
+	return childFinalizer, nil
+}
+
+// makeNoPruneKinds returns the child kinds that aren't pruned.
+func makeNoPruneKinds(resources *dynamicdiscovery.ResourceMap, cc *v1alpha1.CompositeController) (common.NoPruneKinds, error) {
+	noPrune := make(common.NoPruneKinds)
+	for _, child := range cc.Spec.ChildResources {
+		if child.Prune == nil || *child.Prune {
+			continue
+		}
+		resource := resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find child resource %q in %v", child.Resource, child.APIVersion)
+		}
+		noPrune[schema.GroupKind{Group: resource.Group, Kind: resource.Kind}] = true
+	}
+	return noPrune, nil
+}
+
+// makeScaleDownKinds returns the child kinds that are scaled down before
+// they're deleted, with their timeouts.
+func makeScaleDownKinds(resources *dynamicdiscovery.ResourceMap, cc *v1alpha1.CompositeController) (common.ScaleDownKinds, error) {
+	scaleDown := make(common.ScaleDownKinds)
+	for _, child := range cc.Spec.ChildResources {
+		timeout, err := common.NewScaleDownTimeout(child.DeleteStrategy)
+		if err != nil {
+			return nil, fmt.Errorf("child resource %q in apiVersion %q: %v", child.Resource, child.APIVersion, err)
+		}
+		if timeout == 0 {
+			continue
+		}
+		resource := resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find child resource %q in %v", child.Resource, child.APIVersion)
+		}
+		scaleDown[schema.GroupKind{Group: resource.Group, Kind: resource.Kind}] = timeout
+	}
+	return scaleDown, nil
+}
+
+func makeChildOwnerReferences(resources *dynamicdiscovery.ResourceMap, cc *v1alpha1.CompositeController) (common.ChildOwnerReferences, error) {
+	ownerRefs := make(common.ChildOwnerReferences)
+	for _, child := range cc.Spec.ChildResources {
+		if child.OwnerReference == nil {
+			continue
+		}
+		if child.OwnerReference.LabelsOnly && (child.OwnerReference.Controller != nil || child.OwnerReference.BlockOwnerDeletion != nil) {
+			return nil, fmt.Errorf("child resource %q in apiVersion %q: ownerReference.labelsOnly can't be combined with other ownerReference fields", child.Resource, child.APIVersion)
+		}
+		resource := resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find child resource %q in %v", child.Resource, child.APIVersion)
+		}
+		ownerRefs[schema.GroupKind{Group: resource.Group, Kind: resource.Kind}] = child.OwnerReference
+	}
+	return ownerRefs, nil
 }
@@ -21,8 +21,10 @@ import (
 	"encoding/hex"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"k8s.io/klog/v2"
 	"metacontroller.io/controller/common"
@@ -30,7 +32,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/json"
 
 	"metacontroller.io/apis/metacontroller/v1alpha1"
@@ -43,12 +44,13 @@ const (
 )
 
 func (pc *parentController) claimRevisions(parent *unstructured.Unstructured) ([]*v1alpha1.ControllerRevision, error) {
-	parentGVK := pc.parentResource.GroupVersionKind()
+	parentResource := pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind())
+	parentGVK := parentResource.GroupVersionKind()
 
 	// Add labels to prevent accidental overlap between different parent types.
 	extraMatchLabels := map[string]string{
-		labelKeyAPIGroup: pc.parentResource.Group,
-		labelKeyResource: pc.parentResource.Name,
+		labelKeyAPIGroup: parentResource.Group,
+		labelKeyResource: parentResource.Name,
 	}
 	selector, err := pc.makeSelector(parent, extraMatchLabels)
 	if err != nil {
@@ -72,7 +74,45 @@ func (pc *parentController) claimRevisions(parent *unstructured.Unstructured) ([
 	return revisions, nil
 }
 
-func (pc *parentController) syncRevisions(parent *unstructured.Unstructured, observedChildren common.ChildMap, relatedObjects common.ChildMap) (*SyncHookResponse, error) {
+// syncDesired calls the sync hook for syncParent, the parent as hooks see it,
+// through syncRevisions, and returns the children it wants, carrying the
+// current metadata of parent.
+// Metadata is propagated here, so children get it whether or not they're
+// rolled out. Rolling updates already propagated it to compare the children
+// of each revision, and propagating it again changes nothing.
+func (pc *parentController) syncDesired(parent, syncParent *unstructured.Unstructured, observedChildren, relatedObjects common.ChildMap, readiness *common.ReadinessSummary) (*SyncHookResponse, error) {
+	syncResult, err := pc.syncRevisions(syncParent, observedChildren, relatedObjects, readiness)
+	if err != nil {
+		return nil, err
+	}
+	common.PropagateMetadata(pc.cc.Spec.PropagateMetadata, parent, syncResult.Children)
+	return syncResult, nil
+}
+
+// callPatchedSyncHook calls the sync hook with request, and applies the child
+// patches of the controller to the children it returns. All sync hook calls
+// for a parent go through it, so children are patched the same way whether or
+// not they're rolled out, and in previews.
+func (pc *parentController) callPatchedSyncHook(request *SyncHookRequest) (*SyncHookResponse, error) {
+	syncResult, err := callSyncHook(pc.cc, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := pc.childPatches.Apply(syncResult.Children); err != nil {
+		return nil, fmt.Errorf("can't apply child patches: %v", err)
+	}
+	return syncResult, nil
+}
+
+func (pc *parentController) syncRevisions(parent *unstructured.Unstructured, observedChildren common.ChildMap, relatedObjects common.ChildMap, readiness *common.ReadinessSummary) (*SyncHookResponse, error) {
+	scale, err := pc.scaleRequest(parent)
+	if err != nil {
+		return nil, err
+	}
+	cluster := pc.clusterInfo(parent)
+	pressure := pc.syncPressure(parent)
+	previousResponseHash := pc.previousResponseHash(parent)
+
 	// If no child resources use rolling updates, just sync the latest parent.
 	// Also, if the parent object is being deleted and we don't have a finalizer,
 	// just sync the latest parent to get the status since we won't manage
@@ -80,68 +120,89 @@ func (pc *parentController) syncRevisions(parent *unstructured.Unstructured, obs
 	if !pc.updateStrategy.anyRolling() ||
 		(parent.GetDeletionTimestamp() != nil && !pc.finalizer.ShouldFinalize(parent)) {
 		syncRequest := &SyncHookRequest{
-			Controller: pc.cc,
-			Parent:     parent,
-			Children:   observedChildren,
-			Related:    relatedObjects,
+			Controller:           pc.cc,
+			Parent:               parent,
+			Children:             observedChildren,
+			Related:              relatedObjects,
+			Readiness:            readiness,
+			Jobs:                 common.SummarizeJobs(observedChildren),
+			Scale:                scale,
+			Cluster:              cluster,
+			Pressure:             pressure,
+			PreviousResponseHash: previousResponseHash,
 		}
-		syncResult, err := callSyncHook(pc.cc, syncRequest)
+		syncResult, err := pc.callPatchedSyncHook(syncRequest)
 		if err != nil {
-			return nil, fmt.Errorf("sync hook failed for %v %v/%v: %v", pc.parentResource.Kind, parent.GetNamespace(), parent.GetName(), err)
+			return nil, fmt.Errorf("sync hook failed for %v %v/%v: %w", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
 		}
 		return syncResult, nil
 	}
 
 	// Claim all matching ControllerRevisions for the parent.
-	observedRevisions, err := pc.claimRevisions(parent)
+	claimedRevisions, err := pc.claimRevisions(parent)
+	if err != nil {
+		return nil, err
+	}
+	key, err := common.ParentQueueKey(parent)
 	if err != nil {
 		return nil, err
 	}
+	pc.revisionCounts.Observe(key, claimedRevisions)
+	// Some of them may only hold chunks of larger revisions.
+	observedRevisions, revisionChunks := filterRevisionChunks(claimedRevisions)
 
 	// Extract the fields from parent that the controller author
 	// said are relevant for revision history.
 	// If nothing was specified, default to all of "spec".
-	var fieldPaths []string
-	if rh := pc.cc.Spec.ParentResource.RevisionHistory; rh != nil && len(rh.FieldPaths) > 0 {
-		fieldPaths = rh.FieldPaths
-	} else {
-		fieldPaths = []string{"spec"}
-	}
+	parentResource := pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind())
+	fieldPaths := parentResource.revisionFieldPaths()
 	latestPatch, err := makePatch(parent.UnstructuredContent(), fieldPaths)
 	if err != nil {
 		return nil, err
 	}
+	// Replicas are left out of revisions, since scaling isn't a rollout.
+	latestPatch = parentResource.scale.withoutReplicas(latestPatch)
 
 	// The first item in the list is always the latest parent.
 	// The rest are in no particular order.
 	latest := &parentRevision{parent: parent}
 	parentRevisions := make([]*parentRevision, 0, len(observedRevisions)+1)
 	parentRevisions = append(parentRevisions, latest)
+	// Revisions that don't own any children are only kept as history,
+	// so we don't need to call the sync hook for them.
+	var historyRevisions []*parentRevision
 
 	// Materialize the parent object that each revision represents
 	// by applying its parentPatch to the current parent object.
 	// We make deep copies of the ControllerRevisions since we modify them later.
 	for _, revision := range observedRevisions {
-		patch := make(map[string]interface{})
-		if err := json.Unmarshal(revision.ParentPatch.Raw, &patch); err != nil {
-			return nil, fmt.Errorf("can't unmarshal ControllerRevision parentPatch: %v", err)
+		patch, err := revisionPatch(revision, revisionChunks)
+		if err != nil {
+			return nil, err
 		}
 		if reflect.DeepEqual(patch, latestPatch) {
 			// This ControllerRevision matches the latest parent state.
 			latest.revision = revision.DeepCopy()
 			continue
 		}
+		if countRevisionChildren(revision) == 0 {
+			historyRevisions = append(historyRevisions, &parentRevision{revision: revision.DeepCopy()})
+			continue
+		}
 		// Also deep copy parent, so we can apply the patch to it.
 		pr := &parentRevision{parent: latest.parent.DeepCopy(), revision: revision.DeepCopy()}
 		if err := applyPatch(pr.parent.UnstructuredContent(), patch, fieldPaths); err != nil {
 			return nil, err
 		}
+		if err := parentResource.scale.restoreReplicas(pr.parent, latest.parent); err != nil {
+			return nil, err
+		}
 		parentRevisions = append(parentRevisions, pr)
 	}
 
 	// Create a new ControllerRevision for the latest parent state, if needed.
 	if latest.revision == nil {
-		revision, err := newControllerRevision(&pc.parentResource.APIResource, latest.parent, latestPatch)
+		revision, err := newControllerRevision(&parentResource.APIResource.APIResource, latest.parent, latestPatch)
 		if err != nil {
 			return nil, err
 		}
@@ -156,15 +217,22 @@ func (pc *parentController) syncRevisions(parent *unstructured.Unstructured, obs
 			defer wg.Done()
 
 			syncRequest := &SyncHookRequest{
-				Controller: pc.cc,
-				Parent:     pr.parent,
-				Children:   observedChildren,
+				Controller:           pc.cc,
+				Parent:               pr.parent,
+				Children:             observedChildren,
+				Readiness:            readiness,
+				Jobs:                 common.SummarizeJobs(observedChildren),
+				Scale:                scale,
+				Cluster:              cluster,
+				Pressure:             pressure,
+				PreviousResponseHash: previousResponseHash,
 			}
-			syncResult, err := callSyncHook(pc.cc, syncRequest)
+			syncResult, err := pc.callPatchedSyncHook(syncRequest)
 			if err != nil {
 				pr.syncError = err
 				return
 			}
+			common.PropagateMetadata(pc.cc.Spec.PropagateMetadata, parent, syncResult.Children)
 			pr.syncResult = syncResult
 			pr.desiredChildMap = common.MakeChildMap(parent, syncResult.Children)
 		}(pr)
@@ -174,7 +242,14 @@ func (pc *parentController) syncRevisions(parent *unstructured.Unstructured, obs
 	// If any of the sync calls failed, abort.
 	for _, pr := range parentRevisions {
 		if pr.syncError != nil {
-			return nil, fmt.Errorf("sync hook failed for %v %v/%v: %v", pc.parentResource.Kind, parent.GetNamespace(), parent.GetName(), pr.syncError)
+			return nil, fmt.Errorf("sync hook failed for %v %v/%v: %w", parent.GetKind(), parent.GetNamespace(), parent.GetName(), pr.syncError)
+		}
+	}
+	// Rollouts move children between revisions based on the children each
+	// revision returns, so they need all of them.
+	for _, pr := range parentRevisions {
+		if pr.syncResult.OthersUnchanged {
+			return nil, common.Permanent(fmt.Errorf("sync hook for %v %v/%v: othersUnchanged isn't supported with rolling updates", parent.GetKind(), parent.GetNamespace(), parent.GetName()))
 		}
 	}
 
@@ -183,11 +258,11 @@ func (pc *parentController) syncRevisions(parent *unstructured.Unstructured, obs
 		return nil, err
 	}
 
-	// Remove any ControllerRevisions that no longer have any children.
-	// We don't remember previous revisions that we finished migrating away from.
-	// The user is responsible for recovering an old config from source control
-	// if a rollback is necessary.
-	parentRevisions = pruneParentRevisions(parentRevisions)
+	// Set aside any ControllerRevisions that no longer have any children.
+	// By default, we don't remember previous revisions that we finished
+	// migrating away from, but the controller may ask us to keep some history.
+	parentRevisions, finishedRevisions := pruneParentRevisions(parentRevisions)
+	historyRevisions = append(historyRevisions, finishedRevisions...)
 
 	// Reconcile any changes to ControllerRevision objects.
 	// For now, we require these changes to all commit before we start managing
@@ -199,8 +274,14 @@ func (pc *parentController) syncRevisions(parent *unstructured.Unstructured, obs
 			desiredRevisions = append(desiredRevisions, pr.revision)
 		}
 	}
-	if err := pc.manageRevisions(parent, observedRevisions, desiredRevisions); err != nil {
-		return nil, fmt.Errorf("%v %v/%v: can't reconcile ControllerRevisions: %v", pc.parentResource.Kind, parent.GetNamespace(), parent.GetName(), err)
+	historyLimit := int(pc.revisionHistoryLimit()) - len(desiredRevisions)
+	if pc.cc.Spec.RevisionSnapshots != nil {
+		desiredRevisions = append(desiredRevisions, compactRevisionHistory(historyRevisions, historyLimit, pc.cc.Spec.RevisionSnapshots, time.Now())...)
+	} else {
+		desiredRevisions = append(desiredRevisions, limitRevisionHistory(historyRevisions, historyLimit)...)
+	}
+	if err := pc.manageRevisions(parent, observedRevisions, desiredRevisions, revisionChunks); err != nil {
+		return nil, fmt.Errorf("%v %v/%v: can't reconcile ControllerRevisions: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
 	}
 
 	// We now know which revision ought to be responsible for which children.
@@ -219,10 +300,11 @@ func (pc *parentController) syncRevisions(parent *unstructured.Unstructured, obs
 	}
 
 	// Build a single, aggregated syncResult.
-	// We only take parent status from the latest revision.
+	// We only take parent status and child patches from the latest revision.
 	syncResult := &SyncHookResponse{
 		Status:   latest.syncResult.Status,
 		Children: desiredChildren.List(),
+		Patches:  latest.syncResult.Patches,
 	}
 
 	// Aggregate `resyncAfterSeconds` from all revisions.
@@ -246,7 +328,16 @@ func (pc *parentController) syncRevisions(parent *unstructured.Unstructured, obs
 	return syncResult, nil
 }
 
-func (pc *parentController) manageRevisions(parent *unstructured.Unstructured, observedRevisions, desiredRevisions []*v1alpha1.ControllerRevision) error {
+// revisionFieldPaths returns the paths of the fields of parents that are
+// recorded in revisions.
+func (r *parentResource) revisionFieldPaths() []string {
+	if rh := r.revisionHistory; rh != nil && len(rh.FieldPaths) > 0 {
+		return rh.FieldPaths
+	}
+	return []string{"spec"}
+}
+
+func (pc *parentController) manageRevisions(parent *unstructured.Unstructured, observedRevisions, desiredRevisions []*v1alpha1.ControllerRevision, revisionChunks map[string]*v1alpha1.ControllerRevision) error {
 	client := pc.mcClient.MetacontrollerV1alpha1().ControllerRevisions(parent.GetNamespace())
 
 	// Build maps for convenient lookup by object name.
@@ -266,7 +357,20 @@ func (pc *parentController) manageRevisions(parent *unstructured.Unstructured, o
 			}
 			klog.InfoS("Deleting ControllerRevision", "parent_kind", parent.GetKind(), "parent", klog.KObj(parent), "name", revision.GetName())
 			if err := client.Delete(revision.Name, opts); err != nil {
-				return fmt.Errorf("can't delete ControllerRevision %v for %v %v/%v: %v", revision.Name, pc.parentResource.Kind, parent.GetNamespace(), parent.GetName(), err)
+				return fmt.Errorf("can't delete ControllerRevision %v for %v %v/%v: %v", revision.Name, parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+			}
+		}
+	}
+
+	// Delete chunks of revisions that are not desired.
+	for _, chunk := range revisionChunks {
+		if _, desired := desiredMap[chunk.Labels[labelKeyRevisionChunkOf]]; !desired {
+			opts := &metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{UID: &chunk.UID},
+			}
+			klog.InfoS("Deleting ControllerRevision chunk", "parent_kind", parent.GetKind(), "parent", klog.KObj(parent), "name", chunk.GetName())
+			if err := client.Delete(chunk.Name, opts); err != nil {
+				return fmt.Errorf("can't delete ControllerRevision chunk %v for %v %v/%v: %v", chunk.Name, parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
 			}
 		}
 	}
@@ -281,15 +385,26 @@ func (pc *parentController) manageRevisions(parent *unstructured.Unstructured, o
 			}
 			klog.InfoS("Updating ControllerRevision", "parent_kind", parent.GetKind(), "parent", klog.KObj(parent), "name", revision.GetName())
 			if _, err := client.Update(revision); err != nil {
-				return fmt.Errorf("can't update ControllerRevision %v for %v %v/%v: %v", revision.Name, pc.parentResource.Kind, parent.GetNamespace(), parent.GetName(), err)
+				return fmt.Errorf("can't update ControllerRevision %v for %v %v/%v: %v", revision.Name, parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
 			}
 		} else {
 			// Create
 			controllerRef := common.MakeControllerRef(parent)
 			revision.OwnerReferences = append(revision.OwnerReferences, *controllerRef)
+			// Create any chunks first, so the revision is never observed without them.
+			for _, chunk := range splitRevisionChunks(revision) {
+				if revisionChunks[chunk.Name] != nil {
+					// Left over from a previous, partially successful attempt.
+					continue
+				}
+				klog.InfoS("Creating ControllerRevision chunk", "parent_kind", parent.GetKind(), "parent", klog.KObj(parent), "name", chunk.GetName())
+				if _, err := client.Create(chunk); err != nil {
+					return fmt.Errorf("can't create ControllerRevision chunk %v for %v %v/%v: %v", chunk.Name, parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+				}
+			}
 			klog.InfoS("Creating ControllerRevision", "parent_kind", parent.GetKind(), "parent", klog.KObj(parent), "name", revision.GetName())
 			if _, err := client.Create(revision); err != nil {
-				return fmt.Errorf("can't create ControllerRevision %v for %v %v/%v: %v", revision.Name, pc.parentResource.Kind, parent.GetNamespace(), parent.GetName(), err)
+				return fmt.Errorf("can't create ControllerRevision %v for %v %v/%v: %v", revision.Name, parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
 			}
 		}
 	}
@@ -326,7 +441,9 @@ func newControllerRevision(parentResource *metav1.APIResource, parent *unstructu
 			Namespace: parent.GetNamespace(),
 			Labels:    labels,
 		},
-		ParentPatch: runtime.RawExtension{Raw: patchData},
+	}
+	if err := setRevisionPatch(revision, patchData); err != nil {
+		return nil, err
 	}
 	return revision, nil
 }
@@ -405,11 +522,15 @@ type parentRevision struct {
 }
 
 func (pr *parentRevision) countChildren() int {
-	count := 0
 	if pr.revision == nil {
-		return count
+		return 0
 	}
-	for _, children := range pr.revision.Children {
+	return countRevisionChildren(pr.revision)
+}
+
+func countRevisionChildren(revision *v1alpha1.ControllerRevision) int {
+	count := 0
+	for _, children := range revision.Children {
 		count += len(children.Names)
 	}
 	return count
@@ -467,19 +588,117 @@ func (pr *parentRevision) removeChild(apiGroup, kind, name string) {
 	children.Names = append(children.Names[:pos], children.Names[pos+1:]...)
 }
 
-func pruneParentRevisions(parentRevisions []*parentRevision) []*parentRevision {
-	result := make([]*parentRevision, 0, len(parentRevisions))
+// pruneParentRevisions splits parentRevisions into those that are still
+// active (the latest revision, plus any that have remaining children),
+// and those that are finished.
+func pruneParentRevisions(parentRevisions []*parentRevision) (active, finished []*parentRevision) {
+	active = make([]*parentRevision, 0, len(parentRevisions))
 	// Always include the first item (the latest revision).
-	result = append(result, parentRevisions[0])
+	active = append(active, parentRevisions[0])
 	// Include the rest only if they have remaining children.
 	for _, pr := range parentRevisions[1:] {
 		if pr.countChildren() > 0 {
-			result = append(result, pr)
+			active = append(active, pr)
+		} else {
+			finished = append(finished, pr)
 		}
 	}
+	return active, finished
+}
+
+// limitRevisionHistory returns the ControllerRevisions of up to limit of the
+// most recently created revisions in history.
+func limitRevisionHistory(history []*parentRevision, limit int) []*v1alpha1.ControllerRevision {
+	if limit <= 0 {
+		return nil
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[j].revision.CreationTimestamp.Before(&history[i].revision.CreationTimestamp)
+	})
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	result := make([]*v1alpha1.ControllerRevision, 0, len(history))
+	for _, pr := range history {
+		result = append(result, pr.revision)
+	}
 	return result
 }
 
+// Defaults of RevisionSnapshots.
+const (
+	defaultRevisionSnapshotInterval = 24 * time.Hour
+	defaultRevisionSnapshotLimit    = 10
+)
+
+// validateRevisionSnapshots returns an error if the snapshot settings are
+// invalid.
+func validateRevisionSnapshots(snapshots *v1alpha1.RevisionSnapshots) error {
+	if snapshots == nil {
+		return nil
+	}
+	if snapshots.IntervalSeconds < 0 || snapshots.MaxAgeSeconds < 0 || (snapshots.Limit != nil && *snapshots.Limit < 0) {
+		return fmt.Errorf("invalid revisionSnapshots: intervalSeconds, limit and maxAgeSeconds can't be negative")
+	}
+	return nil
+}
+
+// compactRevisionHistory returns the ControllerRevisions of up to limit of the
+// most recently created revisions in history, like limitRevisionHistory, and
+// of snapshots of the older ones: the most recent revision of each interval,
+// up to the snapshot limit, as long as it's not older than the maximum age.
+// Since each revision holds the whole recorded state of the parent, the
+// snapshots can be rolled back to on their own.
+func compactRevisionHistory(history []*parentRevision, limit int, snapshots *v1alpha1.RevisionSnapshots, now time.Time) []*v1alpha1.ControllerRevision {
+	if limit < 0 {
+		limit = 0
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[j].revision.CreationTimestamp.Before(&history[i].revision.CreationTimestamp)
+	})
+	result := limitRevisionHistory(history, limit)
+	if len(history) <= limit {
+		return result
+	}
+	interval := defaultRevisionSnapshotInterval
+	if snapshots.IntervalSeconds > 0 {
+		interval = time.Duration(snapshots.IntervalSeconds) * time.Second
+	}
+	snapshotLimit := defaultRevisionSnapshotLimit
+	if snapshots.Limit != nil {
+		snapshotLimit = int(*snapshots.Limit)
+	}
+
+	var last time.Time
+	kept := 0
+	for _, pr := range history[limit:] {
+		if kept >= snapshotLimit {
+			break
+		}
+		created := pr.revision.CreationTimestamp.Time
+		if snapshots.MaxAgeSeconds > 0 && now.Sub(created) > time.Duration(snapshots.MaxAgeSeconds)*time.Second {
+			break
+		}
+		if !last.IsZero() && last.Sub(created) < interval {
+			continue
+		}
+		result = append(result, pr.revision)
+		last = created
+		kept++
+	}
+	return result
+}
+
+// revisionHistoryLimit returns the maximum number of ControllerRevisions to
+// keep for each parent. Revisions that still own children are always kept,
+// even if that means going over the limit.
+func (pc *parentController) revisionHistoryLimit() int32 {
+	if pc.cc.Spec.RevisionHistoryLimit == nil {
+		return 0
+	}
+	return *pc.cc.Spec.RevisionHistoryLimit
+}
+
 type childClaimMap map[string]map[string]*parentRevision
 
 func (m childClaimMap) getKind(apiGroup, kind string) map[string]*parentRevision {
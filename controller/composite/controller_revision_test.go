@@ -0,0 +1,145 @@
+package composite
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+)
+
+func newHistoryRevision(name string, age time.Duration) *parentRevision {
+	return &parentRevision{
+		revision: &v1alpha1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			},
+		},
+	}
+}
+
+func TestLimitRevisionHistory(t *testing.T) {
+	table := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{name: "no history by default", limit: 0, want: nil},
+		{name: "negative limit", limit: -1, want: nil},
+		{name: "keeps most recent", limit: 2, want: []string{"new", "mid"}},
+		{name: "limit above size", limit: 5, want: []string{"new", "mid", "old"}},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			history := []*parentRevision{
+				newHistoryRevision("old", 3*time.Hour),
+				newHistoryRevision("new", time.Hour),
+				newHistoryRevision("mid", 2*time.Hour),
+			}
+			var got []string
+			for _, revision := range limitRevisionHistory(history, tc.limit) {
+				got = append(got, revision.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("limitRevisionHistory() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompactRevisionHistory(t *testing.T) {
+	table := []struct {
+		name      string
+		limit     int
+		snapshots v1alpha1.RevisionSnapshots
+		want      []string
+	}{
+		{name: "one snapshot per day", limit: 1, want: []string{"1h", "2h", "30h", "60h"}},
+		{name: "without recent history", limit: 0, want: []string{"1h", "30h", "60h"}},
+		{name: "shorter interval", limit: 1, snapshots: v1alpha1.RevisionSnapshots{IntervalSeconds: 3600}, want: []string{"1h", "2h", "3h", "30h", "31h", "60h"}},
+		{name: "snapshot limit", limit: 1, snapshots: v1alpha1.RevisionSnapshots{Limit: pointer.Int32Ptr(1)}, want: []string{"1h", "2h"}},
+		{name: "max age", limit: 1, snapshots: v1alpha1.RevisionSnapshots{MaxAgeSeconds: 48 * 3600}, want: []string{"1h", "2h", "30h"}},
+		{name: "everything recent", limit: 10, want: []string{"1h", "2h", "3h", "30h", "31h", "60h"}},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			history := []*parentRevision{
+				newHistoryRevision("60h", 60*time.Hour),
+				newHistoryRevision("3h", 3*time.Hour),
+				newHistoryRevision("31h", 31*time.Hour),
+				newHistoryRevision("1h", time.Hour),
+				newHistoryRevision("30h", 30*time.Hour),
+				newHistoryRevision("2h", 2*time.Hour),
+			}
+			var got []string
+			for _, revision := range compactRevisionHistory(history, tc.limit, &tc.snapshots, time.Now()) {
+				got = append(got, revision.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("compactRevisionHistory() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSyncDesired_appliesChildPatchesWithoutRollingUpdate(t *testing.T) {
+	var created map[string]interface{}
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "unexpected request", http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &created); err != nil {
+			t.Errorf("can't decode created child: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer apiServer.Close()
+
+	cc := &v1alpha1.CompositeController{
+		ObjectMeta: metav1.ObjectMeta{Name: "things"},
+		Spec: v1alpha1.CompositeControllerSpec{
+			ChildResources: []v1alpha1.CompositeControllerChildResourceRule{{
+				ResourceRule: v1alpha1.ResourceRule{APIVersion: "v1", Resource: "configmaps"},
+			}},
+			ChildPatches: []v1alpha1.ChildPatch{{Patch: `{"data": {"patched": "true"}}`}},
+			Hooks: &v1alpha1.CompositeControllerHooks{
+				Sync: newTestSyncHook(t, `{"children": [{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test"}, "data": {"hook": "true"}}]}`),
+			},
+		},
+	}
+	pc := newTestParentController(t, cc, apiServer.URL)
+	if pc.updateStrategy.anyRolling() {
+		t.Fatalf("test controller uses a rolling update strategy")
+	}
+
+	parent := newTestParent("test", nil)
+	syncResult, err := pc.syncDesired(parent, parent, common.ChildMap{}, common.ChildMap{}, nil)
+	if err != nil {
+		t.Fatalf("syncDesired() = %v", err)
+	}
+	desired := common.MakeChildMap(parent, syncResult.Children)
+	if _, err := common.ManageChildren(pc.log, pc.dynClient, nil, "", pc.updateStrategy, nil, nil, parent, common.ChildMap{}, desired, 1); err != nil {
+		t.Fatalf("ManageChildren() = %v", err)
+	}
+
+	want := map[string]interface{}{"hook": "true", "patched": "true"}
+	if got := created["data"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("created child data = %v, want %v", got, want)
+	}
+
+}
@@ -0,0 +1,426 @@
+package composite
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/controller/common/cel"
+	"metacontroller.io/controller/common/finalizer"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+	dynamicinformer "metacontroller.io/dynamic/informer"
+	"metacontroller.io/logging"
+)
+
+func TestAddMissingLabels(t *testing.T) {
+	table := []struct {
+		name        string
+		labels, add map[string]string
+		want        map[string]string
+		wantChanged bool
+	}{
+		{
+			name: "nothing to add",
+			want: nil,
+		},
+		{
+			name:        "no labels",
+			add:         map[string]string{"app": "test"},
+			want:        map[string]string{"app": "test"},
+			wantChanged: true,
+		},
+		{
+			name:        "missing label",
+			labels:      map[string]string{"tier": "web"},
+			add:         map[string]string{"app": "test"},
+			want:        map[string]string{"app": "test", "tier": "web"},
+			wantChanged: true,
+		},
+		{
+			name:   "label set to another value",
+			labels: map[string]string{"app": "other"},
+			add:    map[string]string{"app": "test"},
+			want:   map[string]string{"app": "other"},
+		},
+	}
+
+	for _, tc := range table {
+		got, changed := addMissingLabels(tc.labels, tc.add)
+		if !reflect.DeepEqual(got, tc.want) || changed != tc.wantChanged {
+			t.Errorf("%v: addMissingLabels() = %v, %v; want %v, %v", tc.name, got, changed, tc.want, tc.wantChanged)
+		}
+	}
+}
+
+func TestLabelDesiredChildren(t *testing.T) {
+	table := []struct {
+		name                 string
+		generateSelector     bool
+		injectSelectorLabels bool
+		labels               map[string]string
+		want                 map[string]string
+		wantErr              bool
+	}{
+		{
+			name:   "matching labels",
+			labels: map[string]string{"app": "web"},
+			want:   map[string]string{"app": "web"},
+		},
+		{
+			name:    "missing labels",
+			wantErr: true,
+		},
+		{
+			name:                 "injected selector labels",
+			injectSelectorLabels: true,
+			labels:               map[string]string{"tier": "backend"},
+			want:                 map[string]string{"app": "web", "tier": "backend"},
+		},
+		{
+			name:                 "selector label set to another value",
+			injectSelectorLabels: true,
+			labels:               map[string]string{"app": "db"},
+			wantErr:              true,
+		},
+		{
+			name:             "generated selector",
+			generateSelector: true,
+			want:             map[string]string{"controller-uid": "test-uid"},
+		},
+	}
+
+	for _, tc := range table {
+		cc := &v1alpha1.CompositeController{Spec: v1alpha1.CompositeControllerSpec{
+			GenerateSelector:     pointer.BoolPtr(tc.generateSelector),
+			InjectSelectorLabels: tc.injectSelectorLabels,
+		}}
+		pc := newTestParentController(t, cc, "")
+		parent := newTestParent("test", nil)
+		parent.Object["spec"] = map[string]interface{}{
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+		}
+		child := &unstructured.Unstructured{}
+		child.SetAPIVersion("v1")
+		child.SetKind("ConfigMap")
+		child.SetName("test")
+		child.SetLabels(tc.labels)
+
+		err := pc.labelDesiredChildren(parent, common.MakeChildMap(parent, []*unstructured.Unstructured{child}))
+		if got := err != nil; got != tc.wantErr {
+			t.Errorf("%v: labelDesiredChildren() = %v, want error: %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(child.GetLabels(), tc.want) {
+			t.Errorf("%v: child labels = %v, want %v", tc.name, child.GetLabels(), tc.want)
+		}
+	}
+}
+
+// newTestParentController returns a parentController for cc, whose parent
+// resource is things in example.com/v1 and whose children may be ConfigMaps,
+// with enough of its state to call its hooks and to read and write objects
+// through the API server at host.
+func newTestParentController(t *testing.T, cc *v1alpha1.CompositeController, host string) *parentController {
+	resources := dynamicdiscovery.NewResourceMap(&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{
+		Resources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "example.com/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "things", Namespaced: true, Kind: "Thing", Verbs: metav1.Verbs{"get", "list", "watch", "update"}},
+					{Name: "widgets", Namespaced: true, Kind: "Widget", Verbs: metav1.Verbs{"get", "list", "watch", "update"}},
+				},
+			},
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: metav1.Verbs{"get", "list", "watch", "create", "update", "patch", "delete"}}},
+			},
+		},
+	}})
+	resources.Start(time.Hour)
+	t.Cleanup(resources.Stop)
+	for !resources.HasSynced() {
+		time.Sleep(time.Millisecond)
+	}
+	dynClient, err := dynamicclientset.New(&rest.Config{Host: host}, resources)
+	if err != nil {
+		t.Fatalf("Can't create dynamic clientset: %v", err)
+	}
+	parentClient, err := dynClient.Resource("example.com/v1", "things")
+	if err != nil {
+		t.Fatalf("Can't create parent client: %v", err)
+	}
+
+	if cc.Spec.ParentResource.APIVersion == "" {
+		cc.Spec.ParentResource.APIVersion = "example.com/v1"
+		cc.Spec.ParentResource.Resource = "things"
+	}
+	updateStrategy, err := makeUpdateStrategyMap(resources, cc)
+	if err != nil {
+		t.Fatalf("Can't make update strategy: %v", err)
+	}
+	childPatches, err := common.NewChildPatches(cc.Spec.ChildPatches)
+	if err != nil {
+		t.Fatalf("Can't make child patches: %v", err)
+	}
+	queue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "CompositeController-"+cc.Name)
+	t.Cleanup(queue.ShutDown)
+	return &parentController{
+		cc:        cc,
+		resources: resources,
+		dynClient: dynClient,
+		parents: map[schema.GroupKind]*parentResource{
+			{Group: "example.com", Kind: "Thing"}: {APIResource: parentClient.APIResource, client: parentClient},
+		},
+		queue:          queue,
+		syncRetries:    common.NewSyncRetries("CompositeController", cc.Name),
+		updateStrategy: updateStrategy,
+		childPatches:   childPatches,
+		finalizer:      finalizer.NewManager("metacontroller.io/compositecontroller-"+cc.Name, cc.Spec.Finalizer, false),
+		log:            logging.ForController("CompositeController", cc.Name),
+	}
+}
+
+// newTestSyncHook returns a sync hook server that answers every request with
+// response.
+func newTestSyncHook(t *testing.T, response string) *v1alpha1.Hook {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{URL: pointer.StringPtr(server.URL)}}
+}
+
+func newTestParent(name string, labels map[string]string) *unstructured.Unstructured {
+	parent := &unstructured.Unstructured{}
+	parent.SetAPIVersion("example.com/v1")
+	parent.SetKind("Thing")
+	parent.SetNamespace("default")
+	parent.SetName(name)
+	parent.SetUID(types.UID(name + "-uid"))
+	parent.SetLabels(labels)
+	return parent
+}
+
+func TestSyncDesired_propagatesMetadataWithoutRollingUpdate(t *testing.T) {
+	cc := &v1alpha1.CompositeController{
+		ObjectMeta: metav1.ObjectMeta{Name: "things"},
+		Spec: v1alpha1.CompositeControllerSpec{
+			ChildResources: []v1alpha1.CompositeControllerChildResourceRule{{
+				ResourceRule: v1alpha1.ResourceRule{APIVersion: "v1", Resource: "configmaps"},
+			}},
+			PropagateMetadata: &v1alpha1.MetadataPropagation{Labels: []string{"team"}},
+			Hooks: &v1alpha1.CompositeControllerHooks{
+				Sync: newTestSyncHook(t, `{"children": [{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "labels": {"team": "hook"}}}]}`),
+			},
+		},
+	}
+	pc := newTestParentController(t, cc, "")
+	if pc.updateStrategy.anyRolling() {
+		t.Fatalf("test controller uses a rolling update strategy")
+	}
+
+	parent := newTestParent("test", map[string]string{"team": "web", "tier": "backend"})
+	syncResult, err := pc.syncDesired(parent, parent, common.ChildMap{}, common.ChildMap{}, nil)
+	if err != nil {
+		t.Fatalf("syncDesired() = %v", err)
+	}
+	if len(syncResult.Children) != 1 {
+		t.Fatalf("syncDesired() returned %v children, want 1", len(syncResult.Children))
+	}
+	want := map[string]string{"team": "web"}
+	if got := syncResult.Children[0].GetLabels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("child labels = %v, want %v", got, want)
+	}
+}
+
+func TestParentResourceMatches(t *testing.T) {
+	match, err := cel.Compile("object.spec.enabled == true")
+	if err != nil {
+		t.Fatalf("Compile() = %v", err)
+	}
+	parent := func(spec map[string]interface{}, deleted bool) *unstructured.Unstructured {
+		p := newTestParent("test", nil)
+		p.Object["spec"] = spec
+		if deleted {
+			now := metav1.Now()
+			p.SetDeletionTimestamp(&now)
+		}
+		return p
+	}
+
+	table := []struct {
+		name   string
+		match  *cel.Program
+		parent *unstructured.Unstructured
+		want   bool
+	}{
+		{name: "no match", parent: parent(nil, false), want: true},
+		{name: "matching", match: match, parent: parent(map[string]interface{}{"enabled": true}, false), want: true},
+		{name: "not matching", match: match, parent: parent(map[string]interface{}{"enabled": false}, false), want: false},
+		{name: "evaluation error", match: match, parent: parent(map[string]interface{}{}, false), want: false},
+		{name: "deleted", match: match, parent: parent(map[string]interface{}{"enabled": false}, true), want: true},
+	}
+	log := logging.ForController("CompositeController", "test")
+	for _, tc := range table {
+		resource := &parentResource{match: tc.match}
+		if got := resource.matches(log, tc.parent); got != tc.want {
+			t.Errorf("%v: matches() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestOnChildAdd_enqueuesEveryParentKind(t *testing.T) {
+	// A Thing and a Widget of the same controller both select the orphan,
+	// along with a Thing that doesn't.
+	lists := map[string]string{
+		"/apis/example.com/v1/things": `{"apiVersion": "example.com/v1", "kind": "ThingList", "metadata": {"resourceVersion": "1"}, "items": [
+			{"apiVersion": "example.com/v1", "kind": "Thing", "metadata": {"name": "a", "namespace": "default", "uid": "a-uid"}, "spec": {"selector": {"matchLabels": {"app": "web"}}}},
+			{"apiVersion": "example.com/v1", "kind": "Thing", "metadata": {"name": "b", "namespace": "default", "uid": "b-uid"}, "spec": {"selector": {"matchLabels": {"app": "db"}}}}
+		]}`,
+		"/apis/example.com/v1/widgets": `{"apiVersion": "example.com/v1", "kind": "WidgetList", "metadata": {"resourceVersion": "1"}, "items": [
+			{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "a", "namespace": "default", "uid": "widget-a-uid"}, "spec": {"selector": {"matchLabels": {"app": "web"}}}}
+		]}`,
+	}
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			// Send no events until the informer stops watching.
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(lists[r.URL.Path]))
+	}))
+	// Stop the informers, which are cleaned up first, before the server.
+	t.Cleanup(apiServer.Close)
+
+	pc := newTestParentController(t, &v1alpha1.CompositeController{}, apiServer.URL)
+	informers := dynamicinformer.NewSharedInformerFactory(pc.dynClient, time.Hour)
+	pc.parents = map[schema.GroupKind]*parentResource{}
+	for kind, resource := range map[string]string{"Thing": "things", "Widget": "widgets"} {
+		informer, err := informers.Resource("example.com/v1", resource)
+		if err != nil {
+			t.Fatalf("Can't create informer for %v: %v", resource, err)
+		}
+		t.Cleanup(informer.Close)
+		for !informer.Informer().HasSynced() {
+			time.Sleep(time.Millisecond)
+		}
+		pc.parents[schema.GroupKind{Group: "example.com", Kind: kind}] = &parentResource{APIResource: pc.resources.Get("example.com/v1", resource), informer: informer}
+	}
+
+	child := &unstructured.Unstructured{}
+	child.SetAPIVersion("v1")
+	child.SetKind("ConfigMap")
+	child.SetNamespace("default")
+	child.SetName("orphan")
+	child.SetLabels(map[string]string{"app": "web"})
+	pc.onChildAdd(child)
+
+	got := map[string]bool{}
+	for pc.queue.Len() > 0 {
+		key, _ := pc.queue.Get()
+		got[key.(string)] = true
+		pc.queue.Done(key)
+	}
+	want := map[string]bool{
+		"example.com/v1:Thing:default:a":  true,
+		"example.com/v1:Widget:default:a": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("onChildAdd() enqueued %v, want %v", got, want)
+	}
+}
+
+func TestWriteStatus_recordsResponseOnceWritten(t *testing.T) {
+	var mutex sync.Mutex
+	stored := newTestParent("test", nil)
+	failWrites := false
+	writes := 0
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(stored.Object)
+		case http.MethodPut:
+			writes++
+			if failWrites {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "InternalError", "code": 500}`))
+				return
+			}
+			updated := &unstructured.Unstructured{}
+			json.NewDecoder(r.Body).Decode(&updated.Object)
+			stored = updated
+			json.NewEncoder(w).Encode(stored.Object)
+		}
+	}))
+	defer apiServer.Close()
+
+	pc := newTestParentController(t, &v1alpha1.CompositeController{}, apiServer.URL)
+	pc.statusWrites = common.NewStatusWrites(60)
+	key, _ := common.ParentQueueKey(stored)
+
+	steps := []struct {
+		name            string
+		phase           string
+		hash            string
+		childrenApplied bool
+		// periodOver makes the next write due, as when the coalesced sync
+		// runs.
+		periodOver bool
+		failWrites bool
+		wantErr    bool
+		wantWrites int
+		wantHash   string
+	}{
+		{name: "written", phase: "a", hash: "1", childrenApplied: true, wantWrites: 1, wantHash: "1"},
+		{name: "coalesced", phase: "b", hash: "2", childrenApplied: true, wantHash: "1"},
+		{name: "coalesced write fails", phase: "b", hash: "2", childrenApplied: true, periodOver: true, failWrites: true, wantErr: true, wantWrites: 1, wantHash: "1"},
+		{name: "coalesced write succeeds", phase: "b", hash: "2", childrenApplied: true, periodOver: true, wantWrites: 1, wantHash: "2"},
+		{name: "children not applied", phase: "c", hash: "3", periodOver: true, wantWrites: 1, wantHash: "2"},
+	}
+	for _, step := range steps {
+		if step.periodOver {
+			pc.statusWrites = common.NewStatusWrites(60)
+		}
+		mutex.Lock()
+		failWrites, writes = step.failWrites, 0
+		parent := stored.DeepCopy()
+		mutex.Unlock()
+
+		err := pc.writeStatus(key, parent, map[string]interface{}{"phase": step.phase}, step.hash, step.childrenApplied)
+		if got := err != nil; got != step.wantErr {
+			t.Errorf("%v: writeStatus() = %v, want error: %v", step.name, err, step.wantErr)
+		}
+		mutex.Lock()
+		if writes != step.wantWrites {
+			t.Errorf("%v: got %v status writes, want %v", step.name, writes, step.wantWrites)
+		}
+		mutex.Unlock()
+		if got := pc.syncRetries.PreviousResponseHash(key); got != step.wantHash {
+			t.Errorf("%v: PreviousResponseHash() = %q, want %q", step.name, got, step.wantHash)
+		}
+	}
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package composite
 
 import (
+	_ "embed"
 	"fmt"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"metacontroller.io/apis/metacontroller/v1alpha1"
@@ -33,12 +35,59 @@ type SyncHookRequest struct {
 	Children   common.ChildMap               `json:"children"`
 	Related    common.ChildMap               `json:"related"`
 	Finalizing bool                          `json:"finalizing"`
+
+	// Readiness is only set if the controller enables childReadiness.
+	Readiness *common.ReadinessSummary `json:"readiness,omitempty"`
+
+	// Jobs is only set if there are Job children.
+	Jobs *common.JobSummary `json:"jobs,omitempty"`
+
+	// Desired is only set for postSync hooks. It's the response of the
+	// previous hook in the pipeline.
+	Desired *SyncHookResponse `json:"desired,omitempty"`
+
+	// Scale is only set if the parent resource has a scale mapping.
+	Scale *SyncHookScale `json:"scale,omitempty"`
+
+	// Cluster tells which versions of the API groups the controller deals
+	// with are served.
+	Cluster *common.ClusterInfo `json:"cluster"`
+
+	// Pressure tells how busy the controller is, and how the syncs of the
+	// parent went lately.
+	Pressure *common.SyncPressure `json:"pressure,omitempty"`
+
+	// PreviousResponseHash is the hash of the response applied in the last
+	// successful sync of the parent, so hooks can tell if anything they
+	// returned still has to be applied. It's empty if there was none since
+	// Metacontroller started.
+	PreviousResponseHash string `json:"previousResponseHash,omitempty"`
+
+	// Parameters are the hook parameters of the controller, if it has any.
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// SyncHookScale is the scale of a parent, as seen through the scale
+// subresource.
+type SyncHookScale struct {
+	// Replicas is the desired number of replicas, or nil if it isn't set.
+	Replicas *int64 `json:"replicas"`
+	// Selector is the parent selector, as a string.
+	Selector string `json:"selector"`
 }
 
 // SyncHookResponse is the expected format of the JSON response from the sync hook.
 type SyncHookResponse struct {
 	Status   map[string]interface{}       `json:"status"`
 	Children []*unstructured.Unstructured `json:"children"`
+	// Patches change existing children that aren't in Children, leaving the
+	// fields they don't touch alone.
+	Patches []common.PatchedChild `json:"patches,omitempty"`
+	// OthersUnchanged says Children only holds the children that changed,
+	// and the other observed children are to be left as they are.
+	OthersUnchanged bool `json:"othersUnchanged,omitempty"`
+	// Deleted lists the children to delete when OthersUnchanged is set.
+	Deleted []common.DeletedChild `json:"deleted,omitempty"`
 
 	ResyncAfterSeconds float64 `json:"resyncAfterSeconds"`
 
@@ -46,11 +95,36 @@ type SyncHookResponse struct {
 	Finalized bool `json:"finalized"`
 }
 
+//go:embed sync-response.schema.json
+var syncResponseSchemaJSON []byte
+
+// syncResponseSchema is the published schema of sync hook responses.
+var syncResponseSchema = hooks.MustLoadSchema(syncResponseSchemaJSON)
+
+// ResponseSchema implements hooks.ResponseValidator.
+func (*SyncHookResponse) ResponseSchema() *hooks.Schema {
+	return syncResponseSchema
+}
+
 func callSyncHook(cc *v1alpha1.CompositeController, request *SyncHookRequest) (*SyncHookResponse, error) {
 	if cc.Spec.Hooks == nil {
 		return nil, fmt.Errorf("no hooks defined")
 	}
 
+	parameters, err := hooks.ResolveParameters(cc.GetHookParameters())
+	if err != nil {
+		return nil, err
+	}
+	request.Parameters = parameters
+
+	// Without a finalize hook, our finalizer is only there to delete children
+	// in other namespaces, which the garbage collector can't.
+	if request.Parent.GetDeletionTimestamp() != nil && cc.Spec.Hooks.Finalize == nil &&
+		cc.Spec.ChildNamespaces != nil && request.Parent.GetNamespace() != "" {
+		request.Finalizing = true
+		return cleanupResponse(request), nil
+	}
+
 	var response SyncHookResponse
 
 	// First check if we should instead call the finalize hook,
@@ -59,20 +133,125 @@ func callSyncHook(cc *v1alpha1.CompositeController, request *SyncHookRequest) (*
 	if request.Parent.GetDeletionTimestamp() != nil && cc.Spec.Hooks.Finalize != nil {
 		// Finalize
 		request.Finalizing = true
-		if err := hooks.Call(cc.Spec.Hooks.Finalize, request, &response); err != nil {
-			return nil, fmt.Errorf("finalize hook failed: %v", err)
+		if err := common.CallHook("CompositeController", cc.Name, "finalize", request.Parent, func(traceParent string) error {
+			return hooks.CallForObject(cc.Spec.Hooks.Finalize, request.Parent, traceParent, request, &response)
+		}); err != nil {
+			return nil, fmt.Errorf("finalize hook failed: %w", err)
 		}
 	} else {
 		// Sync
 		request.Finalizing = false
-		if cc.Spec.Hooks.Sync == nil {
-			return nil, fmt.Errorf("sync hook not defined")
+		if cc.Spec.Hooks.Helm != nil {
+			if err := common.CallHook("CompositeController", cc.Name, "helm", request.Parent, func(string) error {
+				children, err := hooks.RenderChart(cc.Spec.Hooks.Helm, request.Parent)
+				response.Children = children
+				return err
+			}); err != nil {
+				return nil, fmt.Errorf("helm chart failed: %v", err)
+			}
+		} else {
+			if cc.Spec.Hooks.Sync == nil {
+				return nil, fmt.Errorf("sync hook not defined")
+			}
+
+			if err := common.CallHook("CompositeController", cc.Name, "sync", request.Parent, func(traceParent string) error {
+				return hooks.CallForObject(cc.Spec.Hooks.Sync, request.Parent, traceParent, request, &response)
+			}); err != nil {
+				return nil, fmt.Errorf("sync hook failed: %w", err)
+			}
 		}
+	}
 
-		if err := hooks.Call(cc.Spec.Hooks.Sync, request, &response); err != nil {
-			return nil, fmt.Errorf("sync hook failed: %v", err)
+	// Pass the response through each postSync hook, in order.
+	for i := range cc.Spec.Hooks.PostSync {
+		request.Desired = &response
+		var next SyncHookResponse
+		if err := common.CallHook("CompositeController", cc.Name, "postSync", request.Parent, func(traceParent string) error {
+			return hooks.CallForObject(&cc.Spec.Hooks.PostSync[i], request.Parent, traceParent, request, &next)
+		}); err != nil {
+			return nil, fmt.Errorf("postSync hook %v failed: %w", i, err)
 		}
+		response = next
+	}
+	request.Desired = nil
+
+	// Conditions the hooks set must be valid metav1.Conditions.
+	if err := common.ValidateConditions(response.Status); err != nil {
+		return nil, fmt.Errorf("invalid status in hook response: %v", err)
 	}
 
 	return &response, nil
 }
+
+// ValidateHookRequest is the object sent as JSON to the validate hook.
+type ValidateHookRequest struct {
+	Controller *v1alpha1.CompositeController `json:"controller"`
+	Operation  string                        `json:"operation"`
+	Object     *unstructured.Unstructured    `json:"object"`
+	OldObject  *unstructured.Unstructured    `json:"oldObject"`
+	UserInfo   authenticationv1.UserInfo     `json:"userInfo"`
+
+	// Parameters are the hook parameters of the controller, if it has any.
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// ValidateHookResponse is the expected format of the JSON response from the validate hook.
+type ValidateHookResponse struct {
+	Allowed bool   `json:"allowed"`
+	Message string `json:"message"`
+}
+
+func callValidateHook(cc *v1alpha1.CompositeController, request *ValidateHookRequest) (*ValidateHookResponse, error) {
+	parameters, err := hooks.ResolveParameters(cc.GetHookParameters())
+	if err != nil {
+		return nil, err
+	}
+	request.Parameters = parameters
+
+	var response ValidateHookResponse
+	if err := hooks.Call(cc.Spec.Hooks.Validate, request, &response); err != nil {
+		return nil, fmt.Errorf("validate hook failed: %v", err)
+	}
+	return &response, nil
+}
+
+// validateHooks checks the hooks of cc when the controller is created.
+func validateHooks(cc *v1alpha1.CompositeController) error {
+	if err := hooks.ValidateParameters(cc.GetHookParameters()); err != nil {
+		return err
+	}
+	spec := cc.Spec.Hooks
+	if spec == nil {
+		return nil
+	}
+	named := []struct {
+		name string
+		hook *v1alpha1.Hook
+	}{
+		{"customize", spec.Customize},
+		{"sync", spec.Sync},
+		{"finalize", spec.Finalize},
+		{"preUpdateChild", spec.PreUpdateChild},
+		{"postUpdateChild", spec.PostUpdateChild},
+		{"validate", spec.Validate},
+	}
+	for _, h := range named {
+		if err := hooks.ValidateHook(h.hook); err != nil {
+			return fmt.Errorf("invalid %v hook: %v", h.name, err)
+		}
+	}
+	for i := range spec.PostSync {
+		if err := hooks.ValidateHook(&spec.PostSync[i]); err != nil {
+			return fmt.Errorf("invalid postSync hook %d: %v", i, err)
+		}
+	}
+	if spec.Helm != nil {
+		if spec.Sync != nil {
+			return fmt.Errorf("invalid hooks: at most one of 'sync' and 'helm' may be set")
+		}
+		if err := hooks.ValidateHelmChart(spec.Helm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package composite
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+)
+
+func TestCallSyncHookPostSync(t *testing.T) {
+	child := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": name},
+		}
+	}
+	hook := func(handle func(request map[string]interface{}) map[string]interface{}) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var request map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Errorf("can't decode request: %v", err)
+			}
+			json.NewEncoder(w).Encode(handle(request))
+		}))
+	}
+
+	sync := hook(func(request map[string]interface{}) map[string]interface{} {
+		if _, ok := request["desired"]; ok {
+			t.Errorf("sync hook got desired %v, want none", request["desired"])
+		}
+		return map[string]interface{}{
+			"status":   map[string]interface{}{"base": true},
+			"children": []interface{}{child("base")},
+		}
+	})
+	defer sync.Close()
+	addon := hook(func(request map[string]interface{}) map[string]interface{} {
+		desired, _ := request["desired"].(map[string]interface{})
+		children, _ := desired["children"].([]interface{})
+		status, _ := desired["status"].(map[string]interface{})
+		status["addon"] = true
+		return map[string]interface{}{
+			"status":   status,
+			"children": append(children, child("addon")),
+		}
+	})
+	defer addon.Close()
+
+	cc := &v1alpha1.CompositeController{
+		Spec: v1alpha1.CompositeControllerSpec{
+			Hooks: &v1alpha1.CompositeControllerHooks{
+				Sync: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{URL: pointer.StringPtr(sync.URL)}},
+				PostSync: []v1alpha1.Hook{
+					{Webhook: &v1alpha1.Webhook{URL: pointer.StringPtr(addon.URL)}},
+					{Webhook: &v1alpha1.Webhook{URL: pointer.StringPtr(addon.URL)}},
+				},
+			},
+		},
+	}
+	request := &SyncHookRequest{Controller: cc, Parent: &unstructured.Unstructured{}}
+	response, err := callSyncHook(cc, request)
+	if err != nil {
+		t.Fatalf("callSyncHook() = %v", err)
+	}
+
+	var names []string
+	for _, obj := range response.Children {
+		names = append(names, obj.GetName())
+	}
+	if len(names) != 3 || names[0] != "base" || names[1] != "addon" || names[2] != "addon" {
+		t.Errorf("callSyncHook() children = %v, want [base addon addon]", names)
+	}
+	if response.Status["base"] != true || response.Status["addon"] != true {
+		t.Errorf("callSyncHook() status = %v, want base and addon", response.Status)
+	}
+	if request.Desired != nil {
+		t.Errorf("request.Desired = %v after callSyncHook(), want nil", request.Desired)
+	}
+}
+
+func TestCallSyncHookCachedDespitePressure(t *testing.T) {
+	calls := 0
+	sync := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"status": {"ok": true}}`))
+	}))
+	defer sync.Close()
+
+	cc := &v1alpha1.CompositeController{
+		Spec: v1alpha1.CompositeControllerSpec{
+			Hooks: &v1alpha1.CompositeControllerHooks{
+				Sync: &v1alpha1.Hook{
+					Webhook: &v1alpha1.Webhook{URL: pointer.StringPtr(sync.URL)},
+					Cache:   &v1alpha1.HookCache{},
+				},
+			},
+		},
+	}
+	parent := &unstructured.Unstructured{}
+	parent.SetName("test")
+	lastSuccess := 30.0
+	for _, pressure := range []*common.SyncPressure{
+		{QueueDepth: 1},
+		{QueueDepth: 5, Failures: 1, SecondsSinceLastSuccess: &lastSuccess},
+	} {
+		request := &SyncHookRequest{Controller: cc, Parent: parent, Pressure: pressure}
+		if _, err := callSyncHook(cc, request); err != nil {
+			t.Fatalf("callSyncHook() = %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("sync hook called %v times for requests that only differ in pressure, want 1", calls)
+	}
+}
+
+func TestCallSyncHookConditionWithoutReason(t *testing.T) {
+	// Shaped like the response of examples/indexedjob, before conditions
+	// had to have a reason.
+	sync := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": {"active": 1, "succeeded": 0, "failed": 0, "conditions": [{"type": "Complete", "status": "False"}]}, "children": []}`))
+	}))
+	defer sync.Close()
+
+	cc := &v1alpha1.CompositeController{
+		Spec: v1alpha1.CompositeControllerSpec{
+			Hooks: &v1alpha1.CompositeControllerHooks{
+				Sync: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{URL: pointer.StringPtr(sync.URL)}},
+			},
+		},
+	}
+	request := &SyncHookRequest{Controller: cc, Parent: &unstructured.Unstructured{}}
+	response, err := callSyncHook(cc, request)
+	if err != nil {
+		t.Fatalf("callSyncHook() = %v, want no error", err)
+	}
+	conditions, _ := response.Status["conditions"].([]interface{})
+	if len(conditions) != 1 {
+		t.Fatalf("callSyncHook() conditions = %v, want 1", conditions)
+	}
+	if got := conditions[0].(map[string]interface{})["reason"]; got != common.DefaultConditionReason {
+		t.Errorf("callSyncHook() reason = %v, want %v", got, common.DefaultConditionReason)
+	}
+}
@@ -0,0 +1,49 @@
+package composite
+
+import (
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/controller/common"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	"metacontroller.io/events"
+)
+
+// syncInventory deletes the children of parent whose kinds were removed from
+// the child resources since its last sync, and records the inventory of its
+// children. It returns the updated parent, or parent itself if it wasn't
+// updated. An inventory too large to record is reported by an event rather
+// than failing the sync, since retrying wouldn't make it fit, and the
+// previous inventory is kept.
+func (pc *parentController) syncInventory(log logr.Logger, parentClient *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, observed, desired common.ChildMap) (*unstructured.Unstructured, error) {
+	inventory, err := common.ReadInventory(parent)
+	if err != nil {
+		return parent, err
+	}
+	remaining, pruneErr := common.PruneInventory(log, pc.dynClient, parent, inventory, pc.managesKind)
+	updated, err := common.WriteInventory(parentClient, parent, common.MakeInventory(remaining, observed, desired))
+	if common.IsInventoryTooLarge(err) {
+		log.Info("Not recording inventory", "err", err)
+		pc.eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonInventoryTooLarge, "Can't record inventory: %v", err)
+		return parent, pruneErr
+	}
+	if err != nil {
+		return parent, err
+	}
+	return updated, pruneErr
+}
+
+// managesKind returns whether kind is one of the child resources.
+func (pc *parentController) managesKind(apiVersion, kind string) bool {
+	apiGroup, _ := common.ParseAPIVersion(apiVersion)
+	for _, child := range pc.cc.Spec.ChildResources {
+		if childGroup, _ := common.ParseAPIVersion(child.APIVersion); childGroup != apiGroup {
+			continue
+		}
+		if resource := pc.resources.Get(child.APIVersion, child.Resource); resource != nil && resource.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
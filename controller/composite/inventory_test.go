@@ -0,0 +1,64 @@
+package composite
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/events"
+)
+
+func TestSyncInventory_tooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		http.Error(w, "unexpected", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	cc := &v1alpha1.CompositeController{}
+	cc.Spec.ChildResources = []v1alpha1.CompositeControllerChildResourceRule{{ResourceRule: v1alpha1.ResourceRule{APIVersion: "v1", Resource: "configmaps"}}}
+	pc := newTestParentController(t, cc, server.URL)
+	recorder := record.NewFakeRecorder(10)
+	pc.eventRecorder = recorder
+	parent := newTestParent("test", nil)
+
+	// Random names don't compress, so the inventory can't fit.
+	random := rand.New(rand.NewSource(1))
+	var children []*unstructured.Unstructured
+	for i := 0; i < 1000; i++ {
+		name := make([]byte, 253)
+		for j := range name {
+			name[j] = byte('a' + random.Intn(26))
+		}
+		child := &unstructured.Unstructured{}
+		child.SetAPIVersion("v1")
+		child.SetKind("ConfigMap")
+		child.SetNamespace(parent.GetNamespace())
+		child.SetName(string(name))
+		children = append(children, child)
+	}
+	desired := common.MakeChildMap(parent, children)
+
+	got, err := pc.syncInventory(logr.Discard(), pc.parents[parent.GroupVersionKind().GroupKind()].client, parent, nil, desired)
+	if err != nil {
+		t.Fatalf("syncInventory() = %v, want the sync to go on", err)
+	}
+	if got != parent {
+		t.Errorf("syncInventory() updated the parent")
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, events.ReasonInventoryTooLarge) {
+			t.Errorf("syncInventory() sent event %q, want %v", event, events.ReasonInventoryTooLarge)
+		}
+	default:
+		t.Errorf("syncInventory() sent no event, want %v", events.ReasonInventoryTooLarge)
+	}
+}
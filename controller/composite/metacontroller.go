@@ -28,10 +28,12 @@ import (
 
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 
+	"metacontroller.io/admission"
 	"metacontroller.io/apis/metacontroller/v1alpha1"
 	mcclientset "metacontroller.io/client/generated/clientset/internalclientset"
 	mcinformers "metacontroller.io/client/generated/informer/externalversions"
@@ -40,6 +42,7 @@ import (
 	dynamicclientset "metacontroller.io/dynamic/clientset"
 	dynamicdiscovery "metacontroller.io/dynamic/discovery"
 	dynamicinformer "metacontroller.io/dynamic/informer"
+	dynamicobject "metacontroller.io/dynamic/object"
 )
 
 type Metacontroller struct {
@@ -53,17 +56,32 @@ type Metacontroller struct {
 	revisionLister   mclisters.ControllerRevisionLister
 	revisionInformer cache.SharedIndexInformer
 
-	queue             workqueue.RateLimitingInterface
+	queue workqueue.RateLimitingInterface
+	// waitLimiter delays retries of controllers that wait for their
+	// resources.
+	waitLimiter       workqueue.RateLimiter
 	parentControllers map[string]*parentController
+	// parentControllersMutex guards parentControllers against concurrent
+	// reads from the preview endpoint. Only the worker writes to it.
+	parentControllersMutex sync.RWMutex
+	// rollouts holds the rollouts in progress, by controller name. During a
+	// rollout, parentControllers holds the candidate.
+	rollouts map[string]*rollout
 
 	stopCh, doneCh chan struct{}
 
 	numWorkers int
 
 	eventRecorder record.EventRecorder
+	broadcasters  *events.Broadcasters
+
+	childKindPolicy common.ChildKindPolicy
+
+	// admission is nil if admission webhooks are disabled.
+	admission *admission.Server
 }
 
-func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, mcInformerFactory mcinformers.SharedInformerFactory, mcClient mcclientset.Interface, numWorkers int, recorder record.EventRecorder) *Metacontroller {
+func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, mcInformerFactory mcinformers.SharedInformerFactory, mcClient mcclientset.Interface, numWorkers int, recorder record.EventRecorder, broadcasters *events.Broadcasters, childKindPolicy common.ChildKindPolicy, admissionServer *admission.Server) *Metacontroller {
 	mc := &Metacontroller{
 		resources:    resources,
 		mcClient:     mcClient,
@@ -76,10 +94,15 @@ func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynClient *dynam
 		revisionInformer: mcInformerFactory.Metacontroller().V1alpha1().ControllerRevisions().Informer(),
 
 		queue:             workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "CompositeController"),
+		waitLimiter:       common.NewWaitingRateLimiter(),
 		parentControllers: make(map[string]*parentController),
+		rollouts:          make(map[string]*rollout),
 
-		numWorkers:    numWorkers,
-		eventRecorder: recorder,
+		numWorkers:      numWorkers,
+		eventRecorder:   recorder,
+		broadcasters:    broadcasters,
+		childKindPolicy: childKindPolicy,
+		admission:       admissionServer,
 	}
 
 	mc.ccInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -87,6 +110,8 @@ func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynClient *dynam
 		UpdateFunc: mc.updateCompositeController,
 		DeleteFunc: mc.enqueueCompositeController,
 	})
+	// Start controllers that wait for their resources as soon as they appear.
+	resources.AddListener(mc.enqueueWaiting)
 
 	return mc
 }
@@ -102,9 +127,17 @@ func (mc *Metacontroller) Start() {
 		klog.InfoS("Starting CompositeController metacontroller")
 		defer klog.InfoS("Shutting down CompositeController metacontroller")
 
+		common.SetWaiting("CompositeController", "", "waiting for informer caches to sync")
 		if !cache.WaitForNamedCacheSync("CompositeController", mc.stopCh, mc.ccInformer.HasSynced) {
 			return
 		}
+		// Stay unready until every existing controller was started once.
+		if ccs, err := mc.ccLister.List(labels.Everything()); err == nil {
+			for _, cc := range ccs {
+				common.SetWaiting("CompositeController", cc.Name, "starting")
+			}
+		}
+		common.SetNotWaiting("CompositeController", "")
 
 		// In the metacontroller, we are only responsible for starting/stopping
 		// the actual controllers, so a single worker should be enough.
@@ -128,6 +161,13 @@ func (mc *Metacontroller) Stop() {
 			pc.Stop()
 		}(pc)
 	}
+	for _, r := range mc.rollouts {
+		wg.Add(1)
+		go func(pc *parentController) {
+			defer wg.Done()
+			pc.stop()
+		}(r.stable)
+	}
 	wg.Wait()
 }
 
@@ -139,6 +179,11 @@ func (mc *Metacontroller) processNextWorkItem() bool {
 	defer mc.queue.Done(key)
 
 	err := mc.sync(key.(string))
+	if common.IsWaitingForResources(err) {
+		klog.InfoS("CompositeController is waiting for its resources", "name", key, "reason", err.Error())
+		mc.queue.AddAfter(key, mc.waitLimiter.When(key))
+		return true
+	}
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("failed to sync CompositeController %q: %v", key, err))
 		mc.queue.AddRateLimited(key)
@@ -146,6 +191,7 @@ func (mc *Metacontroller) processNextWorkItem() bool {
 	}
 
 	mc.queue.Forget(key)
+	mc.waitLimiter.Forget(key)
 	return true
 }
 
@@ -160,12 +206,8 @@ func (mc *Metacontroller) sync(key string) error {
 	cc, err := mc.ccLister.Get(name)
 	if apierrors.IsNotFound(err) {
 		klog.V(4).InfoS("CompositeController has been deleted", "name", name)
-		// Stop and remove the controller if it exists.
-		if pc, ok := mc.parentControllers[name]; ok {
-			pc.Stop()
-			defer pc.eventRecorder.Eventf(pc.cc, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", pc.cc.Name)
-			delete(mc.parentControllers, name)
-		}
+		common.SetNotWaiting("CompositeController", name)
+		mc.stopParentController(name)
 		return nil
 	}
 	if err != nil {
@@ -175,29 +217,153 @@ func (mc *Metacontroller) sync(key string) error {
 	return mc.syncCompositeController(cc)
 }
 
+// stopParentController stops and removes the controller with the given name,
+// if it exists.
+func (mc *Metacontroller) stopParentController(name string) {
+	pc, ok := mc.parentControllers[name]
+	if !ok {
+		return
+	}
+	if r, ok := mc.rollouts[name]; ok {
+		r.stable.stop()
+		delete(mc.rollouts, name)
+	}
+	pc.Stop()
+	mc.eventRecorder.Eventf(pc.cc, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", pc.cc.Name)
+	mc.parentControllersMutex.Lock()
+	delete(mc.parentControllers, name)
+	mc.parentControllersMutex.Unlock()
+}
+
 func (mc *Metacontroller) syncCompositeController(cc *v1alpha1.CompositeController) error {
+	if cc.DeletionTimestamp != nil && dynamicobject.HasFinalizer(cc, common.CleanupFinalizer) {
+		return mc.cleanupCompositeController(cc)
+	}
+	if common.ValidateDeletionPolicy(cc.Spec.DeletionPolicy) == nil {
+		if err := common.SyncCleanupFinalizer(mc.dynClient, "compositecontrollers", cc, cc.Spec.DeletionPolicy); err != nil {
+			return fmt.Errorf("can't sync cleanup finalizer: %v", err)
+		}
+	}
+
 	if pc, ok := mc.parentControllers[cc.Name]; ok {
 		// The controller was already started.
 		if apiequality.Semantic.DeepEqual(cc.Spec, pc.cc.Spec) {
-			// Nothing has changed.
+			// Nothing has changed, unless a resync was requested.
+			if request, changed := common.ResyncRequested(cc, pc.resyncRequest); changed {
+				klog.InfoS("Resync requested", "controller", klog.KObj(cc), "request", request)
+				pc.resyncRequest = request
+				pc.resyncAll()
+				if r, ok := mc.rollouts[cc.Name]; ok {
+					r.stable.resyncAll()
+				}
+			}
+			if r, ok := mc.rollouts[cc.Name]; ok {
+				mc.progressRollout(cc, r)
+			}
 			return nil
 		}
+		if r, ok := mc.rollouts[cc.Name]; ok {
+			// The spec changed again during a rollout. Start over from the
+			// stable spec.
+			stable, err := mc.abortRollout(cc.Name, r)
+			if err != nil {
+				return err
+			}
+			pc = stable
+			if apiequality.Semantic.DeepEqual(cc.Spec, pc.cc.Spec) {
+				klog.InfoS("Rollout reverted", "controller", klog.KObj(cc))
+				return nil
+			}
+		}
+		if canRollout(pc.cc, cc) {
+			return mc.startRollout(cc, pc)
+		}
 		// Stop and remove the controller so it can be recreated.
 		pc.Stop()
 		mc.eventRecorder.Eventf(cc, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", cc.Name)
+		mc.parentControllersMutex.Lock()
 		delete(mc.parentControllers, cc.Name)
+		mc.parentControllersMutex.Unlock()
 	}
 
-	pc, err := newParentController(mc.resources, mc.dynClient, mc.dynInformers, mc.mcClient, mc.revisionLister, cc, mc.numWorkers, mc.eventRecorder)
+	// Wait for discovery and CRDs instead of failing to create informers.
+	if err := common.CheckResources(mc.resources, mc.dynClient, compositeResourceRules(cc)); err != nil {
+		common.SetWaiting("CompositeController", cc.Name, err.Error())
+		mc.setReadyCondition(cc, false, common.ReasonWaitingForResources, err.Error())
+		return err
+	}
+	pc, err := mc.newParentController(cc)
 	if err != nil {
+		common.SetNotWaiting("CompositeController", cc.Name)
+		mc.setReadyCondition(cc, false, common.ReasonStartFailed, err.Error())
 		return err
 	}
 	pc.Start()
 	mc.eventRecorder.Eventf(cc, v1.EventTypeNormal, events.ReasonStarted, "Started controller: %s", cc.Name)
-	mc.parentControllers[cc.Name] = pc
+	mc.setParentController(cc.Name, pc)
+	common.SetNotWaiting("CompositeController", cc.Name)
+	mc.setReadyCondition(cc, true, common.ReasonStarted, "")
 	return nil
 }
 
+// cleanupCompositeController stops cc, which is being deleted with the Cleanup
+// deletion policy, and cleans up its parents and children before letting it
+// go.
+func (mc *Metacontroller) cleanupCompositeController(cc *v1alpha1.CompositeController) error {
+	common.SetNotWaiting("CompositeController", cc.Name)
+	// Stop first, so the controller doesn't put back the finalizers removed
+	// from its parents.
+	mc.stopParentController(cc.Name)
+	if err := newCleanup(mc.dynClient, cc).Run(); err != nil {
+		return fmt.Errorf("can't clean up: %v", err)
+	}
+	mc.eventRecorder.Eventf(cc, v1.EventTypeNormal, events.ReasonCleanedUp, "Cleaned up parents and children of controller: %s", cc.Name)
+	return common.RemoveCleanupFinalizer(mc.dynClient, "compositecontrollers", cc)
+}
+
+// compositeResourceRules returns the parent and child resources of cc.
+func compositeResourceRules(cc *v1alpha1.CompositeController) []v1alpha1.ResourceRule {
+	var rules []v1alpha1.ResourceRule
+	for _, parent := range cc.GetParentResources() {
+		rules = append(rules, parent.ResourceRule)
+	}
+	for _, child := range cc.Spec.ChildResources {
+		rules = append(rules, child.ResourceRule)
+	}
+	return rules
+}
+
+// setReadyCondition updates the Ready condition of cc. Errors are only logged,
+// since the condition is informative.
+func (mc *Metacontroller) setReadyCondition(cc *v1alpha1.CompositeController, ready bool, reason, message string) {
+	if err := common.SetControllerReadyCondition(mc.dynClient, "compositecontrollers", cc, cc.Status.Conditions, ready, reason, message); err != nil {
+		klog.ErrorS(err, "Can't update Ready condition", "controller", klog.KObj(cc))
+	}
+}
+
+// newParentController returns a parentController for cc, which has its own
+// events rate limits and source, and its own client rate limits if requested.
+func (mc *Metacontroller) newParentController(cc *v1alpha1.CompositeController) (*parentController, error) {
+	dynClient, err := common.RateLimitedClient(mc.dynClient, cc.Spec.ClientRateLimit)
+	if err != nil {
+		return nil, err
+	}
+	recorder, stopRecorder := mc.broadcasters.NewRecorder(cc.Spec.EventRateLimit, cc.Spec.EventSource)
+	pc, err := newParentController(mc.resources, dynClient, mc.dynInformers, mc.mcClient, mc.revisionLister, cc, mc.numWorkers, recorder, mc.childKindPolicy, mc.admission)
+	if err != nil {
+		stopRecorder()
+		return nil, err
+	}
+	pc.stopEventRecorder = stopRecorder
+	return pc, nil
+}
+
+func (mc *Metacontroller) setParentController(name string, pc *parentController) {
+	mc.parentControllersMutex.Lock()
+	mc.parentControllers[name] = pc
+	mc.parentControllersMutex.Unlock()
+}
+
 func (mc *Metacontroller) enqueueCompositeController(obj interface{}) {
 	key, err := common.KeyFunc(obj)
 	if err != nil {
@@ -207,6 +373,14 @@ func (mc *Metacontroller) enqueueCompositeController(obj interface{}) {
 	mc.queue.Add(key)
 }
 
+// enqueueWaiting retries the controllers that wait for their resources, when
+// discovery finds new resources.
+func (mc *Metacontroller) enqueueWaiting() {
+	for _, name := range common.WaitingControllers("CompositeController") {
+		mc.queue.Add(name)
+	}
+}
+
 func (mc *Metacontroller) updateCompositeController(old, cur interface{}) {
 	mc.enqueueCompositeController(cur)
 }
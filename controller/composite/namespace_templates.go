@@ -0,0 +1,72 @@
+package composite
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+// childNamespaceTemplates holds the namespace template of each child kind
+// that has one.
+type childNamespaceTemplates map[schema.GroupKind]*template.Template
+
+func makeChildNamespaceTemplates(resources *dynamicdiscovery.ResourceMap, cc *v1alpha1.CompositeController) (childNamespaceTemplates, error) {
+	templates := make(childNamespaceTemplates)
+	for _, child := range cc.Spec.ChildResources {
+		if child.NamespaceTemplate == "" {
+			continue
+		}
+		resource := resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find child resource %q in %v", child.Resource, child.APIVersion)
+		}
+		if !resource.Namespaced {
+			return nil, fmt.Errorf("child resource %q in apiVersion %q: namespaceTemplate requires a namespaced resource", child.Resource, child.APIVersion)
+		}
+		tmpl, err := template.New("namespaceTemplate").Option("missingkey=error").Parse(child.NamespaceTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("child resource %q in apiVersion %q: invalid namespaceTemplate: %v", child.Resource, child.APIVersion, err)
+		}
+		templates[schema.GroupKind{Group: resource.Group, Kind: resource.Kind}] = tmpl
+	}
+	return templates, nil
+}
+
+// apply sets the namespace of the desired children that don't have one, from
+// the template of their kind, if any. The template is evaluated with the
+// parent as ".parent".
+func (t childNamespaceTemplates) apply(parent *unstructured.Unstructured, children []*unstructured.Unstructured) error {
+	if len(t) == 0 {
+		return nil
+	}
+	data := map[string]interface{}{"parent": parent.UnstructuredContent()}
+	for _, obj := range children {
+		if obj.GetNamespace() != "" {
+			continue
+		}
+		apiGroup, _ := common.ParseAPIVersion(obj.GetAPIVersion())
+		tmpl := t[schema.GroupKind{Group: apiGroup, Kind: obj.GetKind()}]
+		if tmpl == nil {
+			continue
+		}
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, data); err != nil {
+			return common.Permanent(fmt.Errorf("can't render namespaceTemplate of %v %v: %v", obj.GetKind(), obj.GetName(), err))
+		}
+		namespace := buf.String()
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return common.Permanent(fmt.Errorf("namespaceTemplate of %v %v rendered invalid namespace %q: %v", obj.GetKind(), obj.GetName(), namespace, strings.Join(errs, ", ")))
+		}
+		obj.SetNamespace(namespace)
+	}
+	return nil
+}
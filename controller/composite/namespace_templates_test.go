@@ -0,0 +1,53 @@
+package composite
+
+import (
+	"testing"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestChildNamespaceTemplatesApply(t *testing.T) {
+	templates := childNamespaceTemplates{
+		schema.GroupKind{Kind: "ConfigMap"}: template.Must(template.New("namespaceTemplate").Option("missingkey=error").Parse("{{ .parent.spec.tenant }}-apps")),
+	}
+	child := func(kind, namespace string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName("test")
+		return obj
+	}
+
+	table := []struct {
+		name          string
+		tenant        interface{}
+		child         *unstructured.Unstructured
+		wantNamespace string
+		wantErr       bool
+	}{
+		{name: "templated", tenant: "acme", child: child("ConfigMap", ""), wantNamespace: "acme-apps"},
+		{name: "namespace set by hook", tenant: "acme", child: child("ConfigMap", "mine"), wantNamespace: "mine"},
+		{name: "kind without template", tenant: "acme", child: child("Secret", ""), wantNamespace: ""},
+		{name: "missing field", child: child("ConfigMap", ""), wantErr: true},
+		{name: "invalid namespace", tenant: "Not_Valid", child: child("ConfigMap", ""), wantErr: true},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			parent := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+			if tc.tenant != nil {
+				parent.Object["spec"].(map[string]interface{})["tenant"] = tc.tenant
+			}
+			err := templates.apply(parent, []*unstructured.Unstructured{tc.child})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("apply() error = %v, want error %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && tc.child.GetNamespace() != tc.wantNamespace {
+				t.Errorf("namespace = %q, want %q", tc.child.GetNamespace(), tc.wantNamespace)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package composite
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/controller/common"
+)
+
+// observeParentObject syncs parent in Observe mode: the sync hook is called
+// and the parent status is updated, but the changes that would be made to
+// children are only reported. Orphans aren't adopted and finalizers aren't
+// added or removed, since those would be writes too.
+func (pc *parentController) observeParentObject(parent *unstructured.Unstructured) error {
+	result, err := pc.plan(parent)
+	if err != nil {
+		return err
+	}
+	if key, err := common.ParentQueueKey(parent); err == nil {
+		pc.objectCounts.Observe(key, result.Observed)
+	}
+	common.ReportChanges(pc.eventRecorder, "CompositeController", pc.cc.Name, parent, result.Changes)
+
+	status := result.Status
+	readiness := common.SummarizeReadiness(pc.cc.Spec.ChildReadiness, result.Observed)
+	if status == nil {
+		status = make(map[string]interface{})
+	}
+	common.SetReadinessStatus(pc.cc.Spec.ChildReadiness, status, readiness)
+	if err := pc.setScaleStatus(parent, status); err != nil {
+		return fmt.Errorf("can't set scale status for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	if _, _, err := pc.updateParentStatus(parent, status); err != nil {
+		return fmt.Errorf("can't update status for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	return nil
+}
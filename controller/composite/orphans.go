@@ -0,0 +1,43 @@
+package composite
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	"metacontroller.io/controller/common"
+)
+
+// orphanAudit returns the audit of children whose parent no longer exists.
+// Children tracked by labels only have no owner reference, so they're never
+// reported.
+func (pc *parentController) orphanAudit() *common.OrphanAudit {
+	return &common.OrphanAudit{
+		ControllerKind: "CompositeController",
+		Controller:     pc.cc.Name,
+		DynClient:      pc.dynClient,
+		ChildInformers: pc.childInformers,
+		ParentUIDs: func() (map[types.UID]bool, error) {
+			uids := make(map[types.UID]bool)
+			for _, resource := range pc.parents {
+				parents, err := resource.informer.Lister().List(labels.Everything())
+				if err != nil {
+					return nil, err
+				}
+				for _, parent := range parents {
+					uids[parent.GetUID()] = true
+				}
+			}
+			return uids, nil
+		},
+		ParentRef: func(child *unstructured.Unstructured) *metav1.OwnerReference {
+			for _, ref := range child.GetOwnerReferences() {
+				if pc.parentResourceOf(ref.APIVersion, ref.Kind) != nil {
+					return &ref
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,187 @@
+package composite
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"metacontroller.io/controller/common"
+	dynamiccontrollerref "metacontroller.io/dynamic/controllerref"
+)
+
+// PreviewResult is what the preview endpoint returns for a parent.
+type PreviewResult struct {
+	Parent   *unstructured.Unstructured `json:"parent"`
+	Observed common.ChildMap            `json:"observed"`
+	Desired  common.ChildMap            `json:"desired"`
+	Status   map[string]interface{}     `json:"status"`
+	Changes  []common.ChildChange       `json:"changes"`
+}
+
+// ServeHTTP serves a preview of what a CompositeController would do to the
+// children of a parent, without doing any of it. The controller and parent
+// are selected with the controller, kind, namespace and name query
+// parameters. The kind is only needed if the controller has several parent
+// resources.
+func (mc *Metacontroller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ccName, kind, namespace, name := query.Get("controller"), query.Get("kind"), query.Get("namespace"), query.Get("name")
+	if ccName == "" || name == "" {
+		http.Error(w, "the controller and name query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	mc.parentControllersMutex.RLock()
+	pc := mc.parentControllers[ccName]
+	mc.parentControllersMutex.RUnlock()
+	if pc == nil {
+		http.Error(w, fmt.Sprintf("CompositeController %q is not running", ccName), http.StatusNotFound)
+		return
+	}
+
+	result, err := pc.preview(kind, namespace, name)
+	if apierrors.IsBadRequest(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if apierrors.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(result)
+}
+
+// preview calls the sync hook for a parent and computes the changes that
+// would be made to its children, without changing anything in the cluster.
+// Unlike a real sync, it doesn't adopt orphans or account for rollouts.
+func (pc *parentController) preview(kind, namespace, name string) (*PreviewResult, error) {
+	parent, err := pc.lookupParent(kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return pc.plan(parent)
+}
+
+// lookupParent returns the parent with the given kind, namespace and name
+// from the cache. The kind may be empty if the controller has a single parent
+// resource.
+func (pc *parentController) lookupParent(kind, namespace, name string) (*unstructured.Unstructured, error) {
+	var resource *parentResource
+	for _, parent := range pc.parents {
+		if parent.Kind == kind || (kind == "" && len(pc.parents) == 1) {
+			resource = parent
+		}
+	}
+	if resource == nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("kind must be one of the parent kinds of CompositeController %q", pc.cc.Name))
+	}
+	return common.GetObject(resource.informer, namespace, name)
+}
+
+// plan calls the sync hook for parent and computes the changes that would be
+// made to its children, without changing anything in the cluster.
+func (pc *parentController) plan(parent *unstructured.Unstructured) (*PreviewResult, error) {
+	observedChildren, err := pc.ownedChildren(parent)
+	if err != nil {
+		return nil, err
+	}
+	relatedObjects, err := pc.customize.GetRelatedObjects(parent)
+	if err != nil {
+		return nil, err
+	}
+	scale, err := pc.scaleRequest(parent)
+	if err != nil {
+		return nil, err
+	}
+	syncRequest := &SyncHookRequest{
+		Controller:           pc.cc,
+		Parent:               parent,
+		Children:             observedChildren,
+		Related:              relatedObjects,
+		Readiness:            common.SummarizeReadiness(pc.cc.Spec.ChildReadiness, observedChildren),
+		Jobs:                 common.SummarizeJobs(observedChildren),
+		Scale:                scale,
+		Cluster:              pc.clusterInfo(parent),
+		Pressure:             pc.syncPressure(parent),
+		PreviousResponseHash: pc.previousResponseHash(parent),
+	}
+	syncResult, err := pc.callPatchedSyncHook(syncRequest)
+	if err != nil {
+		return nil, err
+	}
+	common.PropagateMetadata(pc.cc.Spec.PropagateMetadata, parent, syncResult.Children)
+
+	desiredChildren := common.MakeChildMap(parent, syncResult.Children)
+	if err := pc.labelDesiredChildren(parent, desiredChildren); err != nil {
+		return nil, err
+	}
+
+	if pc.cc.Spec.AdoptOnly {
+		observedChildren, desiredChildren = common.AdoptOnly(observedChildren, desiredChildren)
+	}
+	changes, err := common.PlanChildren(pc.cc.Spec.ChildApplyMode, pc.updateStrategy, pc.ownerRefs, parent, observedChildren, desiredChildren)
+	if err != nil {
+		return nil, err
+	}
+	return &PreviewResult{
+		Parent:   parent,
+		Observed: observedChildren,
+		Desired:  desiredChildren,
+		Status:   syncResult.Status,
+		Changes:  changes,
+	}, nil
+}
+
+// ownedChildren returns the children that already belong to the parent,
+// like claimChildren but without adopting or releasing anything.
+func (pc *parentController) ownedChildren(parent *unstructured.Unstructured) (common.ChildMap, error) {
+	selector, err := pc.makeSelector(parent, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	childMap := make(common.ChildMap)
+	for _, child := range pc.cc.Spec.ChildResources {
+		resource := pc.resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find child resource %q in apiVersion %q", child.Resource, child.APIVersion)
+		}
+		groupVersion, _ := schema.ParseGroupVersion(child.APIVersion)
+		informer := pc.childInformers.Get(groupVersion.WithResource(child.Resource))
+		if informer == nil {
+			return nil, fmt.Errorf("no informer for resource %q in apiVersion %q", child.Resource, child.APIVersion)
+		}
+		childClient, err := pc.dynClient.Resource(child.APIVersion, child.Resource)
+		if err != nil {
+			return nil, err
+		}
+		namespace := ""
+		if pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).Namespaced {
+			namespace = parent.GetNamespace()
+		}
+		all, err := common.ListObjects(informer, childClient, namespace, selector, child.BypassCache)
+		if err != nil {
+			return nil, fmt.Errorf("can't list %v children: %v", resource.Kind, err)
+		}
+
+		childMap.InitGroup(child.APIVersion, resource.Kind)
+		ownerRef := pc.ownerRefs.Get(resource.Group, resource.Kind)
+		for _, obj := range all {
+			if dynamiccontrollerref.IsOwnedBy(obj, parent.GetUID(), ownerRef) {
+				childMap.Insert(parent, obj)
+			}
+		}
+	}
+	return childMap, nil
+}
@@ -0,0 +1,138 @@
+package composite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/json"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+const (
+	// labelKeyRevisionChunkOf is set on ControllerRevisions that only hold
+	// a chunk of another revision's parentPatchData. Its value is the name of
+	// that other revision.
+	labelKeyRevisionChunkOf = "metacontroller.k8s.io/revision-chunk-of"
+
+	// parentPatchCompressThreshold is the size above which parentPatch is
+	// stored compressed.
+	parentPatchCompressThreshold = 64 * 1024
+	// parentPatchChunkSize is the maximum amount of compressed data stored in
+	// a single ControllerRevision. It leaves plenty of room below the etcd
+	// object size limit (1.5MiB by default) for base64 encoding and metadata.
+	parentPatchChunkSize = 512 * 1024
+)
+
+// setRevisionPatch stores the serialized parentPatch in the revision,
+// compressing it if it's large. The compressed data may still be too large
+// to fit into a single object; see splitRevisionChunks.
+func setRevisionPatch(revision *v1alpha1.ControllerRevision, patchData []byte) error {
+	if len(patchData) <= parentPatchCompressThreshold {
+		revision.ParentPatch = runtime.RawExtension{Raw: patchData}
+		return nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(patchData); err != nil {
+		return fmt.Errorf("can't compress ControllerRevision parentPatch: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("can't compress ControllerRevision parentPatch: %v", err)
+	}
+	revision.ParentPatch = runtime.RawExtension{Raw: []byte("{}")}
+	revision.ParentPatchData = buf.Bytes()
+	return nil
+}
+
+// revisionPatch returns the parentPatch stored in the revision, reassembling
+// it from the given chunks (keyed by name) and decompressing it as needed.
+func revisionPatch(revision *v1alpha1.ControllerRevision, chunks map[string]*v1alpha1.ControllerRevision) (map[string]interface{}, error) {
+	patchData := revision.ParentPatch.Raw
+	if len(revision.ParentPatchData) > 0 {
+		data := revision.ParentPatchData
+		if revision.ParentPatchChunks > 0 {
+			data = append([]byte{}, data...)
+			for i := 1; i <= int(revision.ParentPatchChunks); i++ {
+				chunk := chunks[controllerRevisionChunkName(revision.Name, i)]
+				if chunk == nil {
+					return nil, fmt.Errorf("ControllerRevision %v is missing chunk %v of %v", revision.Name, i, revision.ParentPatchChunks)
+				}
+				data = append(data, chunk.ParentPatchData...)
+			}
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("can't decompress ControllerRevision parentPatch: %v", err)
+		}
+		patchData, err = ioutil.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("can't decompress ControllerRevision parentPatch: %v", err)
+		}
+	}
+	patch := make(map[string]interface{})
+	if err := json.Unmarshal(patchData, &patch); err != nil {
+		return nil, fmt.Errorf("can't unmarshal ControllerRevision parentPatch: %v", err)
+	}
+	return patch, nil
+}
+
+// splitRevisionChunks moves any parentPatchData beyond what fits into
+// a single object into separate chunk ControllerRevisions, which are returned
+// in the order they should be created.
+func splitRevisionChunks(revision *v1alpha1.ControllerRevision) []*v1alpha1.ControllerRevision {
+	if len(revision.ParentPatchData) <= parentPatchChunkSize {
+		return nil
+	}
+	data := revision.ParentPatchData
+	revision.ParentPatchData = data[:parentPatchChunkSize]
+	data = data[parentPatchChunkSize:]
+
+	var chunks []*v1alpha1.ControllerRevision
+	for len(data) > 0 {
+		size := len(data)
+		if size > parentPatchChunkSize {
+			size = parentPatchChunkSize
+		}
+		chunk := &v1alpha1.ControllerRevision{
+			TypeMeta:        revision.TypeMeta,
+			ObjectMeta:      *revision.ObjectMeta.DeepCopy(),
+			ParentPatch:     runtime.RawExtension{Raw: []byte("{}")},
+			ParentPatchData: data[:size],
+		}
+		chunk.Name = controllerRevisionChunkName(revision.Name, len(chunks)+1)
+		if chunk.Labels == nil {
+			chunk.Labels = make(map[string]string)
+		}
+		chunk.Labels[labelKeyRevisionChunkOf] = revision.Name
+		chunks = append(chunks, chunk)
+		data = data[size:]
+	}
+	revision.ParentPatchChunks = int32(len(chunks))
+	return chunks
+}
+
+// filterRevisionChunks separates chunk ControllerRevisions from the rest.
+func filterRevisionChunks(all []*v1alpha1.ControllerRevision) (revisions []*v1alpha1.ControllerRevision, chunks map[string]*v1alpha1.ControllerRevision) {
+	chunks = make(map[string]*v1alpha1.ControllerRevision)
+	for _, revision := range all {
+		if _, isChunk := revision.Labels[labelKeyRevisionChunkOf]; isChunk {
+			chunks[revision.Name] = revision
+			continue
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, chunks
+}
+
+func controllerRevisionChunkName(name string, index int) string {
+	suffix := fmt.Sprintf("-chunk-%d", index)
+	// Make sure the name is 253 chars or less.
+	if len(name)+len(suffix) > 253 {
+		name = name[:253-len(suffix)]
+	}
+	return name + suffix
+}
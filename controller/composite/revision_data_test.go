@@ -0,0 +1,83 @@
+package composite
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestRevisionPatchRoundTrip(t *testing.T) {
+	table := []struct {
+		name       string
+		size       int
+		compressed bool
+	}{
+		{name: "small patch is stored as-is", size: 10},
+		{name: "large patch is compressed", size: 2 * parentPatchCompressThreshold, compressed: true},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			patch := map[string]interface{}{
+				"spec": map[string]interface{}{"data": strings.Repeat("x", tc.size)},
+			}
+			patchData, err := json.Marshal(patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			revision := &v1alpha1.ControllerRevision{}
+			if err := setRevisionPatch(revision, patchData); err != nil {
+				t.Fatalf("setRevisionPatch() error: %v", err)
+			}
+			if got := len(revision.ParentPatchData) > 0; got != tc.compressed {
+				t.Errorf("compressed = %v, want %v", got, tc.compressed)
+			}
+			got, err := revisionPatch(revision, nil)
+			if err != nil {
+				t.Fatalf("revisionPatch() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, patch) {
+				t.Error("revisionPatch() didn't return the original patch")
+			}
+		})
+	}
+}
+
+func TestSplitRevisionChunks(t *testing.T) {
+	data := []byte(strings.Repeat("x", 2*parentPatchChunkSize+1))
+	revision := &v1alpha1.ControllerRevision{
+		ObjectMeta:      metav1.ObjectMeta{Name: "test", Labels: map[string]string{"app": "test"}},
+		ParentPatchData: data,
+	}
+
+	chunks := splitRevisionChunks(revision)
+	if len(chunks) != 2 || revision.ParentPatchChunks != 2 {
+		t.Fatalf("got %v chunks (ParentPatchChunks=%v), want 2", len(chunks), revision.ParentPatchChunks)
+	}
+	reassembled := append([]byte{}, revision.ParentPatchData...)
+	for i, chunk := range chunks {
+		if want := controllerRevisionChunkName("test", i+1); chunk.Name != want {
+			t.Errorf("chunk name = %q, want %q", chunk.Name, want)
+		}
+		if chunk.Labels[labelKeyRevisionChunkOf] != "test" || chunk.Labels["app"] != "test" {
+			t.Errorf("unexpected chunk labels: %v", chunk.Labels)
+		}
+		reassembled = append(reassembled, chunk.ParentPatchData...)
+	}
+	if !reflect.DeepEqual(reassembled, data) {
+		t.Error("chunks don't reassemble into the original data")
+	}
+	if revision.Labels[labelKeyRevisionChunkOf] != "" {
+		t.Error("splitRevisionChunks() modified the labels of the original revision")
+	}
+
+	revisions, chunkMap := filterRevisionChunks(append([]*v1alpha1.ControllerRevision{revision}, chunks...))
+	if len(revisions) != 1 || len(chunkMap) != 2 {
+		t.Errorf("filterRevisionChunks() = %v revisions, %v chunks; want 1, 2", len(revisions), len(chunkMap))
+	}
+}
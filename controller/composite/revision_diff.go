@@ -0,0 +1,217 @@
+package composite
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// RevisionDiff is what the revision diff endpoint returns for two
+// ControllerRevisions of a parent.
+type RevisionDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Fields are the parent fields recorded in the revisions that differ.
+	Fields []RevisionFieldChange `json:"fields"`
+	// Children are the children that only one of the revisions owns.
+	Children []RevisionChildChange `json:"children"`
+}
+
+// RevisionFieldChange is a parent field that differs between two revisions.
+// From or To is nil if the field is only set in the other revision.
+type RevisionFieldChange struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// RevisionChildChange is a child owned by only one of two revisions.
+type RevisionChildChange struct {
+	APIGroup string `json:"apiGroup"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	// Revision is the name of the revision that owns the child.
+	Revision string `json:"revision"`
+}
+
+// ServeRevisionDiff serves the differences between two ControllerRevisions of
+// a parent, selected with the from and to query parameters. The controller
+// and parent are selected with the controller, kind, namespace and name
+// query parameters, like for ServeHTTP. Nothing is changed in the cluster.
+func (mc *Metacontroller) ServeRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ccName, kind, namespace, name := query.Get("controller"), query.Get("kind"), query.Get("namespace"), query.Get("name")
+	if ccName == "" || name == "" {
+		http.Error(w, "the controller and name query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	mc.parentControllersMutex.RLock()
+	pc := mc.parentControllers[ccName]
+	mc.parentControllersMutex.RUnlock()
+	if pc == nil {
+		http.Error(w, fmt.Sprintf("CompositeController %q is not running", ccName), http.StatusNotFound)
+		return
+	}
+
+	result, err := pc.diffRevisions(kind, namespace, name, query.Get("from"), query.Get("to"))
+	if apierrors.IsBadRequest(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if apierrors.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(result)
+}
+
+// diffRevisions returns the differences between the revisions named from and
+// to of a parent. If either is missing, the error lists the revisions.
+func (pc *parentController) diffRevisions(kind, namespace, name, from, to string) (*RevisionDiff, error) {
+	parent, err := pc.lookupParent(kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	revisions, revisionChunks, err := pc.ownedRevisions(parent)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*v1alpha1.ControllerRevision, len(revisions))
+	names := make([]string, 0, len(revisions))
+	for _, revision := range revisions {
+		byName[revision.Name] = revision
+		names = append(names, revision.Name)
+	}
+	sort.Strings(names)
+	fromRevision, toRevision := byName[from], byName[to]
+	if fromRevision == nil || toRevision == nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("from and to must be revisions of %v %v/%v: %v", parent.GetKind(), namespace, name, strings.Join(names, ", ")))
+	}
+
+	fromPatch, err := revisionPatch(fromRevision, revisionChunks)
+	if err != nil {
+		return nil, err
+	}
+	toPatch, err := revisionPatch(toRevision, revisionChunks)
+	if err != nil {
+		return nil, err
+	}
+	return &RevisionDiff{
+		From:     from,
+		To:       to,
+		Fields:   diffFields("", fromPatch, toPatch),
+		Children: diffRevisionChildren(fromRevision, toRevision),
+	}, nil
+}
+
+// ownedRevisions returns the ControllerRevisions of parent, and their chunks,
+// like claimRevisions but without adopting or releasing anything.
+func (pc *parentController) ownedRevisions(parent *unstructured.Unstructured) ([]*v1alpha1.ControllerRevision, map[string]*v1alpha1.ControllerRevision, error) {
+	all, err := pc.revisionLister.ControllerRevisions(parent.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't list ControllerRevisions: %v", err)
+	}
+	var owned []*v1alpha1.ControllerRevision
+	for _, revision := range all {
+		if metav1.IsControlledBy(revision, parent) {
+			owned = append(owned, revision)
+		}
+	}
+	revisions, chunks := filterRevisionChunks(owned)
+	return revisions, chunks, nil
+}
+
+// diffFields returns the fields under path that differ between from and to,
+// sorted by path. Lists are compared as a whole.
+func diffFields(path string, from, to interface{}) []RevisionFieldChange {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if !fromIsMap || !toIsMap {
+		if reflect.DeepEqual(from, to) {
+			return nil
+		}
+		return []RevisionFieldChange{{Path: path, From: from, To: to}}
+	}
+	keys := make(map[string]bool, len(fromMap)+len(toMap))
+	for key := range fromMap {
+		keys[key] = true
+	}
+	for key := range toMap {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []RevisionFieldChange
+	for _, key := range sorted {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		changes = append(changes, diffFields(fieldPath, fromMap[key], toMap[key])...)
+	}
+	return changes
+}
+
+// diffRevisionChildren returns the children that only one of from and to
+// owns, sorted by kind and name.
+func diffRevisionChildren(from, to *v1alpha1.ControllerRevision) []RevisionChildChange {
+	type childKey struct {
+		groupKind schema.GroupKind
+		name      string
+	}
+	owned := func(revision *v1alpha1.ControllerRevision) map[childKey]bool {
+		children := make(map[childKey]bool)
+		for _, ck := range revision.Children {
+			for _, name := range ck.Names {
+				children[childKey{schema.GroupKind{Group: ck.APIGroup, Kind: ck.Kind}, name}] = true
+			}
+		}
+		return children
+	}
+	fromChildren, toChildren := owned(from), owned(to)
+
+	var changes []RevisionChildChange
+	add := func(children, others map[childKey]bool, revision string) {
+		for key := range children {
+			if !others[key] {
+				changes = append(changes, RevisionChildChange{APIGroup: key.groupKind.Group, Kind: key.groupKind.Kind, Name: key.name, Revision: revision})
+			}
+		}
+	}
+	add(fromChildren, toChildren, from.Name)
+	add(toChildren, fromChildren, to.Name)
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.APIGroup != b.APIGroup {
+			return a.APIGroup < b.APIGroup
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return changes
+}
@@ -0,0 +1,57 @@
+package composite
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestDiffFields(t *testing.T) {
+	from := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"image":    "nginx:1.19",
+			"ports":    []interface{}{int64(80)},
+			"replicas": int64(3),
+			"old":      "gone",
+		},
+	}
+	to := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"image":    "nginx:1.20",
+			"ports":    []interface{}{int64(80)},
+			"replicas": int64(3),
+			"new":      "added",
+		},
+	}
+	want := []RevisionFieldChange{
+		{Path: "spec.image", From: "nginx:1.19", To: "nginx:1.20"},
+		{Path: "spec.new", To: "added"},
+		{Path: "spec.old", From: "gone"},
+	}
+	if got := diffFields("", from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffFields() = %+v, want %+v", got, want)
+	}
+	if got := diffFields("", from, from); got != nil {
+		t.Errorf("diffFields() of the same fields = %+v, want none", got)
+	}
+}
+
+func TestDiffRevisionChildren(t *testing.T) {
+	revision := func(name string, names ...string) *v1alpha1.ControllerRevision {
+		return &v1alpha1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Children:   []v1alpha1.ControllerRevisionChildren{{APIGroup: "", Kind: "Pod", Names: names}},
+		}
+	}
+	want := []RevisionChildChange{
+		{Kind: "Pod", Name: "pod-0", Revision: "old"},
+		{Kind: "Pod", Name: "pod-2", Revision: "new"},
+	}
+	got := diffRevisionChildren(revision("old", "pod-0", "pod-1"), revision("new", "pod-1", "pod-2"))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffRevisionChildren() = %+v, want %+v", got, want)
+	}
+}
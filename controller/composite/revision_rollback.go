@@ -0,0 +1,52 @@
+package composite
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/controller/common"
+	"metacontroller.io/events"
+)
+
+// RollbackAnnotation can be set on a parent to the name of one of its
+// ControllerRevisions, to sync its children as that revision of the parent
+// would have them, until the annotation is removed.
+const RollbackAnnotation = "metacontroller.k8s.io/rollback-to"
+
+// rolledBackParent returns the parent as recorded in the revision named by its
+// RollbackAnnotation, or the parent itself if there's none.
+// Only the fields recorded in revisions are rolled back; replicas are kept.
+func (pc *parentController) rolledBackParent(parent *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	name := parent.GetAnnotations()[RollbackAnnotation]
+	if name == "" {
+		return parent, nil
+	}
+	claimedRevisions, err := pc.claimRevisions(parent)
+	if err != nil {
+		return nil, err
+	}
+	observedRevisions, revisionChunks := filterRevisionChunks(claimedRevisions)
+	for _, revision := range observedRevisions {
+		if revision.Name != name {
+			continue
+		}
+		patch, err := revisionPatch(revision, revisionChunks)
+		if err != nil {
+			return nil, err
+		}
+		parentResource := pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind())
+		rolledBack := parent.DeepCopy()
+		if err := applyPatch(rolledBack.UnstructuredContent(), patch, parentResource.revisionFieldPaths()); err != nil {
+			return nil, fmt.Errorf("can't roll back to ControllerRevision %v: %v", name, err)
+		}
+		if err := parentResource.scale.restoreReplicas(rolledBack, parent); err != nil {
+			return nil, err
+		}
+		pc.eventRecorder.Eventf(parent, v1.EventTypeNormal, events.ReasonRolledBack,
+			"Syncing children as of ControllerRevision %v until the %v annotation is removed", name, RollbackAnnotation)
+		return rolledBack, nil
+	}
+	return nil, common.Permanent(fmt.Errorf("can't roll back %v %v/%v to ControllerRevision %v: it's not one of its revisions", parent.GetKind(), parent.GetNamespace(), parent.GetName(), name))
+}
@@ -0,0 +1,110 @@
+package composite
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	mcclientset "metacontroller.io/client/generated/clientset/internalclientset"
+	mclisters "metacontroller.io/client/generated/lister/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+)
+
+func TestRolledBackParent(t *testing.T) {
+	pc := newTestParentController(t, &v1alpha1.CompositeController{}, "")
+	// Revisions already owned by the parent are claimed without API calls.
+	pc.mcClient = mcclientset.NewForConfigOrDie(&rest.Config{Host: "http://127.0.0.1:0"})
+	parentResource := pc.parentResourceOf("example.com/v1", "Thing")
+
+	parentAt := func(image string) *unstructured.Unstructured {
+		parent := newTestParent("test", nil)
+		parent.Object["spec"] = map[string]interface{}{
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+				"spec":     map[string]interface{}{"image": image},
+			},
+		}
+		return parent
+	}
+
+	// Record the revision of the parent with image v1.
+	old := parentAt("v1")
+	patch, err := makePatch(old.UnstructuredContent(), parentResource.revisionFieldPaths())
+	if err != nil {
+		t.Fatalf("makePatch() = %v", err)
+	}
+	revision, err := newControllerRevision(&parentResource.APIResource.APIResource, old, patch)
+	if err != nil {
+		t.Fatalf("newControllerRevision() = %v", err)
+	}
+	revision.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(old, old.GroupVersionKind())}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := indexer.Add(revision); err != nil {
+		t.Fatal(err)
+	}
+	pc.revisionLister = mclisters.NewControllerRevisionLister(indexer)
+
+	table := []struct {
+		name       string
+		annotation string
+		wantImage  string
+		wantEvent  bool
+		wantErr    bool
+	}{
+		{
+			// The annotation was removed after the rollback, or never set:
+			// normal syncs resume with the parent as it is.
+			name:      "no annotation",
+			wantImage: "v2",
+		},
+		{
+			name:       "known revision",
+			annotation: revision.Name,
+			wantImage:  "v1",
+			wantEvent:  true,
+		},
+		{
+			name:       "unknown revision",
+			annotation: "things-test-unknown",
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range table {
+		recorder := record.NewFakeRecorder(10)
+		pc.eventRecorder = recorder
+		parent := parentAt("v2")
+		if tc.annotation != "" {
+			parent.SetAnnotations(map[string]string{RollbackAnnotation: tc.annotation})
+		}
+		want := parent.DeepCopy()
+
+		got, err := pc.rolledBackParent(parent)
+		if tc.wantErr {
+			if !common.IsPermanent(err) {
+				t.Errorf("%v: rolledBackParent() = %v, want permanent error", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: rolledBackParent() = %v", tc.name, err)
+			continue
+		}
+		if image, _, _ := unstructured.NestedString(got.Object, "spec", "template", "spec", "image"); image != tc.wantImage {
+			t.Errorf("%v: rolledBackParent() image = %q, want %q", tc.name, image, tc.wantImage)
+		}
+		if !reflect.DeepEqual(parent, want) {
+			t.Errorf("%v: rolledBackParent() changed the parent to %v", tc.name, parent)
+		}
+		if got := len(recorder.Events) > 0; got != tc.wantEvent {
+			t.Errorf("%v: sent a RolledBack event: %v, want %v", tc.name, got, tc.wantEvent)
+		}
+	}
+}
@@ -18,7 +18,6 @@ package composite
 
 import (
 	"fmt"
-	"reflect"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"metacontroller.io/controller/common"
@@ -65,12 +64,12 @@ func (pc *parentController) syncRollingUpdate(parentRevisions []*parentRevision,
 				// The child wasn't observed, so we don't know if it'll match latest.
 				continue
 			}
-			updated, err := common.ApplyUpdate(child, desiredChild)
+			upToDate, err := common.ChildUpToDate(pc.cc.Spec.ChildApplyMode, child, desiredChild)
 			if err != nil {
 				// We can't prove it'll be a no-op, so don't move it to latest.
 				continue
 			}
-			if reflect.DeepEqual(child, updated) {
+			if upToDate {
 				// This will be a no-op update, so move it immediately instead of
 				// waiting until the next sync. In addition to reducing unnecessary
 				// ControllerRevision updates, this helps ensure that the overall sync
@@ -175,11 +174,11 @@ func (pc *parentController) shouldContinueRolling(latest *parentRevision, observ
 			// Is this child up-to-date with what the latest revision wants?
 			// Apply the latest update to it and see if anything changes.
 			update := latest.desiredChildMap.FindGroupKindName(ck.APIGroup, ck.Kind, name)
-			updated, err := common.ApplyUpdate(child, update)
+			upToDate, err := common.ChildUpToDate(pc.cc.Spec.ChildApplyMode, child, update)
 			if err != nil {
 				return fmt.Errorf("can't check if child %v %v is updated: %v", ck.Kind, name, err)
 			}
-			if !reflect.DeepEqual(child, updated) {
+			if !upToDate {
 				return fmt.Errorf("child %v %v is not updated yet", ck.Kind, name)
 			}
 			// For RollingInPlace, we should check ObservedGeneration (if possible)
@@ -0,0 +1,204 @@
+package composite
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/events"
+)
+
+var (
+	defaultRolloutSteps       = []int32{10, 50}
+	defaultRolloutStepSeconds = int32(300)
+)
+
+// noRollout is the share of a rolloutShare while no rollout is in progress.
+const noRollout = -1
+
+// rolloutShare splits the parents of a controller between the parentController
+// running its previous spec, the stable one, and the one running its new
+// spec, the candidate, while the new spec is rolled out.
+type rolloutShare struct {
+	mutex sync.RWMutex
+	// percent is the percentage of parents synced by the candidate, or
+	// noRollout if the controller has a single parentController.
+	percent int32
+}
+
+// handles returns whether the parent with the given queue key is synced by
+// the candidate, if candidate is true, or by the stable parentController.
+func (s *rolloutShare) handles(key string, candidate bool) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.percent == noRollout {
+		return true
+	}
+	return (rolloutBucket(key) < s.percent) == candidate
+}
+
+func (s *rolloutShare) set(percent int32) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.percent = percent
+}
+
+// rolloutBucket sorts a parent, by its kind, namespace and name, in one of
+// 100 buckets, so growing the share of the candidate only moves parents to
+// it.
+func rolloutBucket(key string) int32 {
+	_, kind, namespace, name, err := common.SplitParentQueueKey(key)
+	if err != nil {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(kind + "/" + namespace + "/" + name))
+	return int32(h.Sum32() % 100)
+}
+
+// rollout is a change to the spec of a CompositeController being rolled out.
+type rollout struct {
+	stable, candidate *parentController
+	// step is the index of the current step in steps.
+	step        int
+	stepStarted time.Time
+}
+
+func rolloutSteps(r *v1alpha1.ControllerRollout) []int32 {
+	if len(r.Steps) == 0 {
+		return defaultRolloutSteps
+	}
+	return r.Steps
+}
+
+func rolloutStepDuration(r *v1alpha1.ControllerRollout) time.Duration {
+	seconds := defaultRolloutStepSeconds
+	if r.StepSeconds != nil {
+		seconds = *r.StepSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// canRollout returns whether the change from the spec of old to that of cur
+// can be rolled out in steps. Both specs must sync the same parents.
+func canRollout(old, cur *v1alpha1.CompositeController) bool {
+	return cur.Spec.Rollout != nil && apiequality.Semantic.DeepEqual(old.GetParentResources(), cur.GetParentResources())
+}
+
+// startRollout starts a candidate parentController for the new spec of cc,
+// next to stable, which keeps syncing the parents that aren't moved to the
+// candidate yet.
+func (mc *Metacontroller) startRollout(cc *v1alpha1.CompositeController, stable *parentController) error {
+	steps := rolloutSteps(cc.Spec.Rollout)
+	candidate, err := mc.newParentController(cc)
+	if err != nil {
+		return err
+	}
+	// The candidate takes over the counts and sync failures of the stable
+	// parentController, so metrics cover the parents of both.
+	candidate.syncRetries = stable.syncRetries
+	candidate.objectCounts = stable.objectCounts
+	candidate.rollout = stable.rollout
+	candidate.candidate = true
+	stable.rollout.set(steps[0])
+
+	candidate.Start()
+	mc.rollouts[cc.Name] = &rollout{stable: stable, candidate: candidate, stepStarted: time.Now()}
+	mc.setParentController(cc.Name, candidate)
+	mc.eventRecorder.Eventf(cc, v1.EventTypeNormal, events.ReasonRolloutStarted, "Rolling out new spec to %v%% of parents", steps[0])
+	mc.queue.AddAfter(cc.Name, rolloutStepDuration(cc.Spec.Rollout))
+	return nil
+}
+
+// progressRollout moves the rollout of cc on to its next step, once the
+// current one has lasted long enough, unless too many parents synced by the
+// candidate are failing.
+func (mc *Metacontroller) progressRollout(cc *v1alpha1.CompositeController, r *rollout) {
+	stepDuration := rolloutStepDuration(cc.Spec.Rollout)
+	if wait := stepDuration - time.Since(r.stepStarted); wait > 0 {
+		mc.queue.AddAfter(cc.Name, wait)
+		return
+	}
+
+	failing, total := r.candidate.rolloutFailures()
+	maxFailing := int32(0)
+	if cc.Spec.Rollout.MaxFailingPercent != nil {
+		maxFailing = *cc.Spec.Rollout.MaxFailingPercent
+	}
+	if total > 0 && failing*100 > int(maxFailing)*total {
+		klog.InfoS("Rollout paused", "controller", klog.KObj(cc), "failing", failing, "parents", total)
+		mc.eventRecorder.Eventf(cc, v1.EventTypeWarning, events.ReasonRolloutPaused, "Rollout paused: %v of %v parents synced with the new spec are failing", failing, total)
+		r.stepStarted = time.Now()
+		mc.queue.AddAfter(cc.Name, stepDuration)
+		return
+	}
+
+	steps := rolloutSteps(cc.Spec.Rollout)
+	r.step++
+	if r.step >= len(steps) {
+		mc.completeRollout(cc, r)
+		return
+	}
+	klog.InfoS("Rollout progressing", "controller", klog.KObj(cc), "percent", steps[r.step])
+	mc.eventRecorder.Eventf(cc, v1.EventTypeNormal, events.ReasonRolloutProgress, "Rolling out new spec to %v%% of parents", steps[r.step])
+	r.candidate.rollout.set(steps[r.step])
+	r.stepStarted = time.Now()
+	r.candidate.resyncAll()
+	mc.queue.AddAfter(cc.Name, stepDuration)
+}
+
+// completeRollout hands all parents over to the candidate.
+func (mc *Metacontroller) completeRollout(cc *v1alpha1.CompositeController, r *rollout) {
+	r.candidate.rollout.set(noRollout)
+	r.stable.stop()
+	delete(mc.rollouts, cc.Name)
+	r.candidate.resyncAll()
+	klog.InfoS("Rollout completed", "controller", klog.KObj(cc))
+	mc.eventRecorder.Eventf(cc, v1.EventTypeNormal, events.ReasonRolloutCompleted, "Rolled out new spec to all parents")
+}
+
+// abortRollout stops the candidate of the rollout of the controller with the
+// given name, and returns the stable parentController, which syncs all
+// parents again.
+func (mc *Metacontroller) abortRollout(name string, r *rollout) (*parentController, error) {
+	r.candidate.stop()
+	r.stable.rollout.set(noRollout)
+	delete(mc.rollouts, name)
+	mc.setParentController(name, r.stable)
+	// The candidate may have changed the admission webhook.
+	if err := r.stable.syncAdmissionWebhook(); err != nil {
+		return r.stable, err
+	}
+	if r.stable.admissionWebhook != nil {
+		r.stable.admission.Register(r.stable.admissionWebhook, r.stable.validate)
+	}
+	r.stable.resyncAll()
+	return r.stable, nil
+}
+
+// rolloutFailures returns how many of the parents synced by pc, the
+// candidate of a rollout, are failing, out of how many.
+func (pc *parentController) rolloutFailures() (failing, total int) {
+	handles := func(key string) bool {
+		return pc.rollout.handles(key, pc.candidate)
+	}
+	for _, resource := range pc.parents {
+		parents, err := resource.informer.Lister().List(labels.Everything())
+		if err != nil {
+			continue
+		}
+		for _, parent := range parents {
+			if key, err := common.ParentQueueKey(parent); err == nil && handles(key) {
+				total++
+			}
+		}
+	}
+	return pc.syncRetries.Failing(handles), total
+}
@@ -0,0 +1,52 @@
+package composite
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRolloutShare(t *testing.T) {
+	keys := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, fmt.Sprintf("example.com/v1:Thing:default:thing-%v", i))
+	}
+	share := &rolloutShare{percent: noRollout}
+
+	candidates := func(percent int32) map[string]bool {
+		share.set(percent)
+		result := make(map[string]bool)
+		for _, key := range keys {
+			candidate, stable := share.handles(key, true), share.handles(key, false)
+			if candidate == stable {
+				t.Fatalf("percent %v: parent %v is synced by both or neither parentControllers", percent, key)
+			}
+			if candidate {
+				result[key] = true
+			}
+		}
+		return result
+	}
+
+	share.set(noRollout)
+	for _, key := range keys {
+		if !share.handles(key, true) || !share.handles(key, false) {
+			t.Fatalf("no rollout: parent %v isn't synced by every parentController", key)
+		}
+	}
+	if got := candidates(0); len(got) != 0 {
+		t.Errorf("percent 0: got %v parents on the candidate, want 0", len(got))
+	}
+	if got := candidates(100); len(got) != len(keys) {
+		t.Errorf("percent 100: got %v parents on the candidate, want %v", len(got), len(keys))
+	}
+	small := candidates(10)
+	if len(small) == 0 || len(small) > 200 {
+		t.Errorf("percent 10: got %v parents on the candidate, want about 100", len(small))
+	}
+	large := candidates(50)
+	for key := range small {
+		if !large[key] {
+			t.Errorf("parent %v left the candidate when its share grew", key)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package composite
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// parentScale is the scale mapping of a parent resource, with its paths
+// split into fields.
+type parentScale struct {
+	specReplicasPath  []string
+	labelSelectorPath []string
+}
+
+// newParentScale checks a scale mapping. It returns nil if there's none.
+func newParentScale(scale *v1alpha1.CompositeControllerParentScale) (*parentScale, error) {
+	if scale == nil {
+		return nil, nil
+	}
+	s := &parentScale{specReplicasPath: splitScalePath(scale.SpecReplicasPath)}
+	if len(s.specReplicasPath) < 2 || s.specReplicasPath[0] != "spec" {
+		return nil, fmt.Errorf("invalid scale specReplicasPath %q: must be a field in .spec", scale.SpecReplicasPath)
+	}
+	if scale.LabelSelectorPath != "" {
+		s.labelSelectorPath = splitScalePath(scale.LabelSelectorPath)
+		if len(s.labelSelectorPath) < 2 || s.labelSelectorPath[0] != "status" {
+			return nil, fmt.Errorf("invalid scale labelSelectorPath %q: must be a field in .status", scale.LabelSelectorPath)
+		}
+	}
+	return s, nil
+}
+
+func splitScalePath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "."), ".")
+}
+
+// request returns the scale of parent for the sync hook.
+func (s *parentScale) request(parent *unstructured.Unstructured, selector labels.Selector) *SyncHookScale {
+	scale := &SyncHookScale{Selector: selector.String()}
+	replicas, found, err := unstructured.NestedInt64(parent.UnstructuredContent(), s.specReplicasPath...)
+	if err == nil && found {
+		scale.Replicas = &replicas
+	}
+	return scale
+}
+
+// setStatus writes selector into status, the status of a parent, where the
+// scale subresource reads it.
+func (s *parentScale) setStatus(status map[string]interface{}, selector labels.Selector) error {
+	if s.labelSelectorPath == nil {
+		return nil
+	}
+	return unstructured.SetNestedField(status, selector.String(), s.labelSelectorPath[1:]...)
+}
+
+// withoutReplicas returns patch, a revision patch, without the desired
+// replicas, so that scaling a parent never starts a rolling update.
+func (s *parentScale) withoutReplicas(patch map[string]interface{}) map[string]interface{} {
+	if s == nil {
+		return patch
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(patch, s.specReplicasPath...); !found {
+		return patch
+	}
+	// The patch shares its fields with the parent, which may be in the cache.
+	patch = runtime.DeepCopyJSON(patch)
+	unstructured.RemoveNestedField(patch, s.specReplicasPath...)
+	return patch
+}
+
+// restoreReplicas copies the desired replicas of latest into parent, a parent
+// materialized from an older revision, so all revisions scale together.
+func (s *parentScale) restoreReplicas(parent, latest *unstructured.Unstructured) error {
+	if s == nil {
+		return nil
+	}
+	unstructured.RemoveNestedField(parent.UnstructuredContent(), s.specReplicasPath...)
+	replicas, found, err := unstructured.NestedFieldCopy(latest.UnstructuredContent(), s.specReplicasPath...)
+	if err != nil || !found {
+		return err
+	}
+	return unstructured.SetNestedField(parent.UnstructuredContent(), replicas, s.specReplicasPath...)
+}
+
+// scaleRequest returns the scale of parent for the sync hook, or nil if its
+// parent resource has no scale mapping.
+func (pc *parentController) scaleRequest(parent *unstructured.Unstructured) (*SyncHookScale, error) {
+	scale := pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).scale
+	if scale == nil {
+		return nil, nil
+	}
+	selector, err := pc.makeSelector(parent, nil)
+	if err != nil {
+		return nil, err
+	}
+	return scale.request(parent, selector), nil
+}
+
+// setScaleStatus writes the selector of parent into status, if its parent
+// resource has a scale mapping with a labelSelectorPath.
+func (pc *parentController) setScaleStatus(parent *unstructured.Unstructured, status map[string]interface{}) error {
+	scale := pc.parentResourceOf(parent.GetAPIVersion(), parent.GetKind()).scale
+	if scale == nil {
+		return nil
+	}
+	selector, err := pc.makeSelector(parent, nil)
+	if err != nil {
+		return err
+	}
+	return scale.setStatus(status, selector)
+}
@@ -0,0 +1,80 @@
+package composite
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestNewParentScale(t *testing.T) {
+	table := []struct {
+		name    string
+		scale   v1alpha1.CompositeControllerParentScale
+		wantErr bool
+	}{
+		{
+			name:  "paths with leading dots",
+			scale: v1alpha1.CompositeControllerParentScale{SpecReplicasPath: ".spec.replicas", LabelSelectorPath: ".status.selector"},
+		},
+		{
+			name:  "paths without leading dots",
+			scale: v1alpha1.CompositeControllerParentScale{SpecReplicasPath: "spec.replicas"},
+		},
+		{
+			name:    "replicas outside of spec",
+			scale:   v1alpha1.CompositeControllerParentScale{SpecReplicasPath: ".status.replicas"},
+			wantErr: true,
+		},
+		{
+			name:    "selector outside of status",
+			scale:   v1alpha1.CompositeControllerParentScale{SpecReplicasPath: ".spec.replicas", LabelSelectorPath: ".spec.selector"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newParentScale(&tc.scale)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("newParentScale() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParentScaleRevisions(t *testing.T) {
+	scale, err := newParentScale(&v1alpha1.CompositeControllerParentScale{SpecReplicasPath: ".spec.replicas"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": int64(3), "image": "v2"},
+	}}
+
+	patch, err := makePatch(latest.UnstructuredContent(), []string{"spec"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch = scale.withoutReplicas(patch)
+	if want := map[string]interface{}{"spec": map[string]interface{}{"image": "v2"}}; !reflect.DeepEqual(patch, want) {
+		t.Errorf("withoutReplicas() = %v, want %v", patch, want)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(latest.Object, "spec", "replicas"); replicas != 3 {
+		t.Errorf("withoutReplicas() changed the parent: replicas = %v, want 3", replicas)
+	}
+
+	old := latest.DeepCopy()
+	oldPatch := map[string]interface{}{"spec": map[string]interface{}{"image": "v1"}}
+	if err := applyPatch(old.UnstructuredContent(), oldPatch, []string{"spec"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := scale.restoreReplicas(old, latest); err != nil {
+		t.Fatalf("restoreReplicas() error: %v", err)
+	}
+	if want := map[string]interface{}{"image": "v1", "replicas": int64(3)}; !reflect.DeepEqual(old.Object["spec"], want) {
+		t.Errorf("restoreReplicas() spec = %v, want %v", old.Object["spec"], want)
+	}
+}
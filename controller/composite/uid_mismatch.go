@@ -0,0 +1,61 @@
+package composite
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	"metacontroller.io/events"
+)
+
+// handleUIDMismatches applies the UID mismatch policy of the controller to
+// the objects that took the place of children of parent, and returns the
+// objects that may still be claimed.
+func (pc *parentController) handleUIDMismatches(client *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	key, err := common.ParentQueueKey(parent)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, obj := range objects {
+		oldUID, ok := pc.childUIDs.Mismatched(key, parent, obj)
+		if !ok {
+			result = append(result, obj)
+			continue
+		}
+		policy := pc.cc.Spec.UIDMismatchPolicy
+		if policy == "" {
+			policy = v1alpha1.UIDMismatchAdopt
+		}
+		pc.log.Info("Child was replaced by another object", "parent", klog.KObj(parent), "child", klog.KObj(obj), "child_kind", obj.GetKind(), "uid", obj.GetUID(), "oldUID", oldUID, "policy", policy)
+		pc.eventRecorder.Eventf(parent, v1.EventTypeWarning, events.ReasonUIDMismatch,
+			"%v %v has UID %v instead of %v: %v", obj.GetKind(), obj.GetName(), obj.GetUID(), oldUID, policy)
+
+		switch policy {
+		case v1alpha1.UIDMismatchAdopt:
+			result = append(result, obj)
+		case v1alpha1.UIDMismatchIgnore:
+			pc.childUIDs.Ignore(key, parent, obj)
+		case v1alpha1.UIDMismatchReplace:
+			// The UID precondition makes sure only the object we looked at is
+			// deleted. The child is recreated once it's gone.
+			uid := obj.GetUID()
+			propagation := metav1.DeletePropagationBackground
+			err := client.Namespace(obj.GetNamespace()).Delete(obj.GetName(), &metav1.DeleteOptions{
+				Preconditions:     &metav1.Preconditions{UID: &uid},
+				PropagationPolicy: &propagation,
+			})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("can't delete %v %v/%v in place of child: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package composite
+
+import (
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/admission"
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// syncAdmissionWebhook makes sure parent objects are sent to the validate
+// hook if there is one, and aren't anymore if it was removed.
+func (pc *parentController) syncAdmissionWebhook() error {
+	hasValidateHook := pc.cc.Spec.Hooks != nil && pc.cc.Spec.Hooks.Validate != nil
+	if pc.admission == nil {
+		if hasValidateHook {
+			return fmt.Errorf("validate hook requires admission webhooks to be enabled with --admission-addr")
+		}
+		return nil
+	}
+
+	webhook := &admission.Webhook{
+		Name: "compositecontroller-" + pc.cc.Name,
+		Owner: metav1.OwnerReference{
+			APIVersion:         v1alpha1.SchemeGroupVersion.String(),
+			Kind:               "CompositeController",
+			Name:               pc.cc.Name,
+			UID:                pc.cc.UID,
+			BlockOwnerDeletion: pointer.BoolPtr(false),
+		},
+	}
+	for _, parent := range pc.parents {
+		scope := admissionregistrationv1.ClusterScope
+		if parent.Namespaced {
+			scope = admissionregistrationv1.NamespacedScope
+		}
+		webhook.Rules = append(webhook.Rules, admissionregistrationv1.RuleWithOperations{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{parent.Group},
+				APIVersions: []string{parent.Version},
+				Resources:   []string{parent.Name},
+				Scope:       &scope,
+			},
+		})
+	}
+	if !hasValidateHook {
+		// Clean up after a validate hook that was removed.
+		return pc.admission.Delete(webhook)
+	}
+	if err := pc.admission.Ensure(webhook); err != nil {
+		return err
+	}
+	pc.admissionWebhook = webhook
+	return nil
+}
+
+// validate handles admission requests for parent objects.
+func (pc *parentController) validate(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	hookRequest := &ValidateHookRequest{
+		Controller: pc.cc,
+		Operation:  string(request.Operation),
+		UserInfo:   request.UserInfo,
+	}
+	var err error
+	if hookRequest.Object, err = decodeAdmissionObject(request.Object.Raw); err != nil {
+		return admission.Denied(fmt.Sprintf("can't decode object: %v", err))
+	}
+	if hookRequest.OldObject, err = decodeAdmissionObject(request.OldObject.Raw); err != nil {
+		return admission.Denied(fmt.Sprintf("can't decode old object: %v", err))
+	}
+
+	klog.V(4).InfoS("CompositeController validate", "controller", klog.KObj(pc.cc), "operation", request.Operation, "parent", klog.KRef(request.Namespace, request.Name))
+	response, err := callValidateHook(pc.cc, hookRequest)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	if !response.Allowed {
+		return admission.Denied(response.Message)
+	}
+	return admission.Allowed()
+}
+
+func decodeAdmissionObject(raw []byte) (*unstructured.Unstructured, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,44 @@
+package decorator
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/controller/common/finalizer"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+// newCleanup returns the cleanup of the target objects and attachments of dc.
+// Resources that aren't served anymore are skipped, since there's nothing left
+// of them to clean up.
+func newCleanup(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dc *v1alpha1.DecoratorController) *common.ControllerCleanup {
+	cleanup := &common.ControllerCleanup{
+		ControllerKind: "DecoratorController",
+		Controller:     dc.Name,
+		Finalizer:      finalizer.NewManager("metacontroller.io/decoratorcontroller-"+dc.Name, dc.Spec.Finalizer, false),
+		IsChild: func(child *unstructured.Unstructured) bool {
+			return child.GetAnnotations()[decoratorControllerAnnotation] == dc.Name
+		},
+		DeleteChildren: dc.Spec.DeletionPolicy != nil && dc.Spec.DeletionPolicy.DeleteChildren,
+	}
+	if selector, err := newDecoratorSelector(resources, dc); err == nil {
+		cleanup.IsParent = selector.Matches
+	} else {
+		// Some target resource is gone: only clean up after the target objects
+		// that have the finalizer.
+		cleanup.IsParent = func(*unstructured.Unstructured) bool { return false }
+	}
+	for _, parent := range dc.Spec.Resources {
+		if client, err := dynClient.Resource(parent.APIVersion, parent.Resource); err == nil {
+			cleanup.Parents = append(cleanup.Parents, client)
+		}
+	}
+	for _, attachment := range dc.Spec.Attachments {
+		if client, err := dynClient.Resource(attachment.APIVersion, attachment.Resource); err == nil {
+			cleanup.Children = append(cleanup.Children, client)
+		}
+	}
+	return cleanup
+}
@@ -18,14 +18,14 @@ package decorator
 
 import (
 	"fmt"
-	"reflect"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
 	"metacontroller.io/events"
+	"metacontroller.io/leader"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
@@ -47,7 +47,8 @@ import (
 	dynamicclientset "metacontroller.io/dynamic/clientset"
 	dynamicdiscovery "metacontroller.io/dynamic/discovery"
 	dynamicinformer "metacontroller.io/dynamic/informer"
-	dynamicobject "metacontroller.io/dynamic/object"
+	"metacontroller.io/hooks"
+	"metacontroller.io/logging"
 )
 
 const (
@@ -66,35 +67,68 @@ type decoratorController struct {
 
 	stopCh, doneCh chan struct{}
 	queue          workqueue.RateLimitingInterface
+	syncRetries    *common.SyncRetries
+	objectCounts   *common.ObjectCounts
+
+	deletionProtection *common.DeletionProtection
+	statusWrites       *common.StatusWrites
+	// namespaceInformer is used to skip creating attachments in namespaces
+	// that are being deleted.
+	namespaceInformer *dynamicinformer.ResourceInformer
 
 	updateStrategy updateStrategyMap
+	childPatches   common.ChildPatches
+	resyncSchedule *common.Schedule
+	// resyncRequest is the last value seen of the resync request annotation.
+	resyncRequest string
+	// childKinds are the kinds of the declared attachments, which are the
+	// only kinds the sync hook may return, if childKindPolicy allows them.
+	childKinds      common.GroupKindMap
+	childKindPolicy common.ChildKindPolicy
 
 	parentInformers common.InformerMap
 	childInformers  common.InformerMap
 
 	numWorkers    int
 	eventRecorder record.EventRecorder
-
-	finalizer *finalizer.Manager
-	customize customize.Manager
+	// log attributes log lines to the controller.
+	log logr.Logger
+	// stopEventRecorder shuts down the broadcaster of eventRecorder, if it's
+	// dedicated to the controller.
+	stopEventRecorder func()
+
+	finalizer      *finalizer.Manager
+	childFinalizer *common.ChildFinalizer
+	customize      customize.Manager
+
+	// crossScope holds the attachment kinds that are tracked by labels for
+	// namespaced targets, because they're cluster-scoped.
+	crossScope common.ChildOwnerReferences
 }
 
-func newDecoratorController(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, dc *v1alpha1.DecoratorController, numWorkers int, eventRecorder record.EventRecorder) (controller *decoratorController, newErr error) {
+func newDecoratorController(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, dc *v1alpha1.DecoratorController, numWorkers int, eventRecorder record.EventRecorder, childKindPolicy common.ChildKindPolicy) (controller *decoratorController, newErr error) {
 	c := &decoratorController{
 		dc:              dc,
 		resources:       resources,
 		dynClient:       dynClient,
 		parentKinds:     make(common.GroupKindMap),
+		childKinds:      make(common.GroupKindMap),
+		childKindPolicy: childKindPolicy,
 		parentInformers: make(common.InformerMap),
 		childInformers:  make(common.InformerMap),
+		resyncRequest:   dc.Annotations[common.ResyncRequestAnnotation],
 
 		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "DecoratorController-"+dc.Name),
+		syncRetries:   common.NewSyncRetries("DecoratorController", dc.Name),
+		objectCounts:  common.NewObjectCounts("DecoratorController", dc.Name),
 		numWorkers:    numWorkers,
 		eventRecorder: eventRecorder,
-		finalizer: &finalizer.Manager{
-			Name:    "metacontroller.io/decoratorcontroller-" + dc.Name,
-			Enabled: dc.Spec.Hooks.Finalize != nil,
-		},
+		log:           logging.ForController("DecoratorController", dc.Name),
+		finalizer: finalizer.NewManager(
+			"metacontroller.io/decoratorcontroller-"+dc.Name,
+			dc.Spec.Finalizer,
+			dc.Spec.Hooks.Finalize != nil,
+		),
 	}
 
 	customize := customize.NewCustomizeManager(
@@ -107,6 +141,8 @@ func newDecoratorController(resources *dynamicdiscovery.ResourceMap, dynClient *
 		c.parentKinds,
 	)
 	c.customize = customize
+	c.deletionProtection = common.NewDeletionProtection("DecoratorController", dc.Name, dc.Spec.DeletionProtection)
+	c.statusWrites = common.NewStatusWrites(dc.Spec.StatusCoalesceSeconds)
 
 	var err error
 
@@ -124,13 +160,63 @@ func newDecoratorController(resources *dynamicdiscovery.ResourceMap, dynClient *
 		c.parentKinds.Set(schema.GroupKind{Group: resource.Group, Kind: resource.Kind}, resource)
 	}
 
+	// Make sure we're allowed to manage all the requested attachment kinds.
+	for _, child := range dc.Spec.Attachments {
+		resource := resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find attachment resource %q in apiVersion %q", child.Resource, child.APIVersion)
+		}
+		if err := childKindPolicy.Check(resource); err != nil {
+			return nil, fmt.Errorf("invalid attachment resource %q in apiVersion %q: %v", child.Resource, child.APIVersion, err)
+		}
+		c.childKinds.Set(schema.GroupKind{Group: resource.Group, Kind: resource.Kind}, resource)
+	}
+
 	// Remember the update strategy for each child type.
 	c.updateStrategy, err = makeUpdateStrategyMap(resources, dc)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateHooks(dc); err != nil {
+		return nil, err
+	}
+
+	// Cross-scope attachments aren't garbage collected with their target,
+	// so our finalizer is needed to delete them.
+	c.crossScope, err = makeCrossScopeAttachments(resources, dc)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.crossScope) > 0 {
+		c.finalizer.Enabled = true
+	}
+
+	c.childFinalizer, err = makeChildFinalizer(resources, dc, c.finalizer, eventRecorder)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create informers for all parent and child resources.
+	if dc.Spec.ResyncSchedule != "" {
+		c.resyncSchedule, err = common.ParseSchedule(dc.Spec.ResyncSchedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resyncSchedule: %v", err)
+		}
+	}
+	if err := common.ValidateChildApplyMode(dc.Spec.ChildApplyMode); err != nil {
+		return nil, err
+	}
+	if err := common.ValidateControllerMode(dc.Spec.Mode); err != nil {
+		return nil, err
+	}
+	if err := common.ValidateDeletionPolicy(dc.Spec.DeletionPolicy); err != nil {
+		return nil, err
+	}
+	c.childPatches, err = common.NewChildPatches(dc.Spec.ChildPatches)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create informers for all parent and child resources, and for namespaces.
 	defer func() {
 		if newErr != nil {
 			// If newDecoratorController fails, Close() any informers we created
@@ -141,6 +227,9 @@ func newDecoratorController(resources *dynamicdiscovery.ResourceMap, dynClient *
 			for _, informer := range c.parentInformers {
 				informer.Close()
 			}
+			if c.namespaceInformer != nil {
+				c.namespaceInformer.Close()
+			}
 		}
 	}()
 
@@ -168,6 +257,11 @@ func newDecoratorController(resources *dynamicdiscovery.ResourceMap, dynClient *
 		c.childInformers.Set(groupVersion.WithResource(child.Resource), informer)
 	}
 
+	c.namespaceInformer, err = dynInformers.Resource("v1", "namespaces")
+	if err != nil {
+		return nil, fmt.Errorf("can't create informer for namespaces: %v", err)
+	}
+
 	return c, nil
 }
 
@@ -179,7 +273,7 @@ func (c *decoratorController) Start() {
 	// so we have to assume the shared informers are already running. We can't
 	// add event handlers in newParentController() since c might be incomplete.
 	parentHandlers := cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.enqueueParentObject,
+		AddFunc:    c.onParentAdd,
 		UpdateFunc: c.updateParentObject,
 		DeleteFunc: c.enqueueParentObject,
 	}
@@ -212,13 +306,14 @@ func (c *decoratorController) Start() {
 		defer utilruntime.HandleCrash()
 
 		klog.InfoS("Starting DecoratorController", "controller", klog.KObj(c.dc))
-		c.eventRecorder.Eventf(c.dc, v1.EventTypeNormal, events.ReasonStarting, "Starting controller: %s", c.dc.Name)
+		c.eventRecorder.Eventf(c.dc, v1.EventTypeNormal, events.ReasonStarting, "Starting controller: %s on replica %s", c.dc.Name, leader.Identity())
 		defer klog.InfoS("Shutting down DecoratorController", "controller", klog.KObj(c.dc))
-		defer c.eventRecorder.Eventf(c.dc, v1.EventTypeNormal, events.ReasonStopping, "Stopping controller: %s", c.dc.Name)
+		defer c.eventRecorder.Eventf(c.dc, v1.EventTypeNormal, events.ReasonStopping, "Stopping controller: %s on replica %s", c.dc.Name, leader.Identity())
 
 		// Wait for dynamic client and all informers.
 		klog.InfoS("Waiting for DecoratorController caches to sync", "controller", klog.KObj(c.dc))
 		syncFuncs := make([]cache.InformerSynced, 0, 1+len(c.dc.Spec.Resources)+len(c.dc.Spec.Attachments))
+		syncFuncs = append(syncFuncs, c.namespaceInformer.Informer().HasSynced)
 		for _, informer := range c.parentInformers {
 			syncFuncs = append(syncFuncs, informer.Informer().HasSynced)
 		}
@@ -232,6 +327,18 @@ func (c *decoratorController) Start() {
 		}
 
 		var wg sync.WaitGroup
+		if c.resyncSchedule != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				common.RunSchedule(c.resyncSchedule, c.stopCh, c.resyncAll)
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.orphanAudit().Run(c.stopCh)
+		}()
 		for i := 0; i < c.numWorkers; i++ {
 			wg.Add(1)
 			go func() {
@@ -247,6 +354,8 @@ func (c *decoratorController) Stop() {
 	close(c.stopCh)
 	c.queue.ShutDown()
 	<-c.doneCh
+	c.syncRetries.Stop()
+	c.objectCounts.Stop()
 
 	// Remove event handlers and close informers for all child resources.
 	for _, informer := range c.childInformers {
@@ -258,9 +367,15 @@ func (c *decoratorController) Stop() {
 		informer.Informer().RemoveEventHandlers()
 		informer.Close()
 	}
+	c.namespaceInformer.Close()
+
+	if c.stopEventRecorder != nil {
+		c.stopEventRecorder()
+	}
 }
 
 func (c *decoratorController) worker() {
+	common.SetProfileLabels("DecoratorController", c.dc.Name)
 	for c.processNextWorkItem() {
 	}
 }
@@ -272,27 +387,144 @@ func (c *decoratorController) processNextWorkItem() bool {
 	}
 	defer c.queue.Done(key)
 
-	err := c.sync(key.(string))
+	// Look the parent up once, so all the checks below agree on it. If it's
+	// gone from the cache, the sync forgets about it.
+	parent := c.cachedParent(key.(string))
+	if parent != nil {
+		if c.syncRetries.Abandoned(key.(string), parent) {
+			// The sync failed permanently, and the target object didn't
+			// change since.
+			c.queue.Forget(key)
+			return true
+		}
+		if c.syncRetries.DeadLettered(key.(string), parent) {
+			// The sync failed too many times in a row, and nobody requeued
+			// the parent since.
+			c.queue.Forget(key)
+			return true
+		}
+		if wait, ok := c.syncRetries.Quarantined(key.(string), parent); ok {
+			// Only sync quarantined parents at their scheduled retry, since
+			// other enqueues may have replaced it.
+			c.queue.Forget(key)
+			c.queue.AddAfter(key, wait)
+			return true
+		}
+	}
+
+	release := common.AcquireSyncSlot("DecoratorController", c.dc.Name)
+	err := common.TimeSync("DecoratorController", c.dc.Name, parent, func() error {
+		return c.sync(key.(string))
+	})
+	release()
+	if hookErr := hooks.AsError(err); hookErr != nil && hookErr.EventOnly {
+		if parent != nil {
+			common.ReportHookError(c.eventRecorder, parent, hookErr)
+		}
+		err = nil
+	}
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("failed to sync %v %q: %v", c.dc.Name, key, err))
-		c.queue.AddRateLimited(key)
+		failures, delay := c.syncRetries.Failed(key.(string))
+		delay, retry := common.SyncRetryDelay(err, delay)
+		deadLetter := retry && c.syncRetries.Exhausted(failures)
+		quarantined := false
+		if parent != nil && retry && !deadLetter {
+			if interval, ok := c.syncRetries.Quarantine(key.(string), parent, err); ok {
+				delay, quarantined = interval, true
+			}
+		}
+		if retry && !deadLetter {
+			c.queue.AddAfter(key, delay)
+		} else {
+			c.queue.Forget(key)
+		}
+		if parent != nil {
+			if deadLetter {
+				parentClient, clientErr := c.dynClient.Kind(parent.GetAPIVersion(), parent.GetKind())
+				if clientErr == nil {
+					clientErr = c.syncRetries.DeadLetter(c.eventRecorder, parentClient, key.(string), parent, failures, err)
+				}
+				if clientErr != nil {
+					utilruntime.HandleError(fmt.Errorf("can't dead-letter %v %q: %v", c.dc.Name, key, clientErr))
+					c.queue.AddAfter(key, delay)
+				}
+			} else if !retry {
+				// The status of target objects belongs to their own
+				// controller, so only report this in an event.
+				c.syncRetries.FailedPermanently(key.(string), parent)
+				common.ReportPermanentSyncFailure(c.eventRecorder, parent, err)
+			} else if parentClient, clientErr := c.dynClient.Kind(parent.GetAPIVersion(), parent.GetKind()); clientErr == nil {
+				common.ReportSyncFailure(c.eventRecorder, parentClient, parent, failures, delay, err, c.dc.Spec.SyncFailureAnnotations)
+			}
+			if quarantined {
+				common.ReportQuarantine(c.eventRecorder, parent, delay)
+			}
+			c.syncRetries.Notify(parent, failures, err)
+		}
 		return true
 	}
 
+	c.syncRetries.Succeeded(key.(string))
 	c.queue.Forget(key)
+	if parent != nil {
+		if parentClient, err := c.dynClient.Kind(parent.GetAPIVersion(), parent.GetKind()); err == nil {
+			if err := common.ClearSyncFailures(parentClient, parent); err != nil {
+				utilruntime.HandleError(err)
+			}
+		}
+	}
 	return true
 }
 
+// cachedParent returns the parent with the given queue key from the cache,
+// or nil if it's not there.
+func (c *decoratorController) cachedParent(key string) *unstructured.Unstructured {
+	apiVersion, kind, namespace, name, err := common.SplitParentQueueKey(key)
+	if err != nil {
+		return nil
+	}
+	resource := c.resources.GetKind(apiVersion, kind)
+	if resource == nil {
+		return nil
+	}
+	groupVersion, _ := schema.ParseGroupVersion(apiVersion)
+	informer := c.parentInformers.Get(groupVersion.WithResource(resource.Name))
+	if informer == nil {
+		return nil
+	}
+	parent, err := common.GetObject(informer, namespace, name)
+	if err != nil {
+		return nil
+	}
+	return parent
+}
+
+// onParentAdd enqueues a parent seen for the first time, such as when the
+// controller starts. If it was failing to sync before, its retry backoff is
+// resumed from its sync failure annotations.
+func (c *decoratorController) onParentAdd(obj interface{}) {
+	if parent, ok := obj.(*unstructured.Unstructured); ok && (c.parentSelector.Matches(parent) || c.finalizer.HasFinalizer(parent)) {
+		if key, err := common.ParentQueueKey(parent); err == nil {
+			if delay := c.syncRetries.Resume(key, parent); delay > 0 {
+				c.queue.AddAfter(key, delay)
+				return
+			}
+		}
+	}
+	c.enqueueParentObject(obj)
+}
+
 func (c *decoratorController) enqueueParentObject(obj interface{}) {
 	// If the parent doesn't match our selector, and it doesn't have our
 	// finalizer, we don't care about it.
 	if parent, ok := obj.(*unstructured.Unstructured); ok {
-		if !c.parentSelector.Matches(parent) && !dynamicobject.HasFinalizer(parent, c.finalizer.Name) {
+		if !c.parentSelector.Matches(parent) && !c.finalizer.HasFinalizer(parent) {
 			return
 		}
 	}
 
-	key, err := parentQueueKey(obj)
+	key, err := common.ParentQueueKey(obj)
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
 		return
@@ -300,8 +532,23 @@ func (c *decoratorController) enqueueParentObject(obj interface{}) {
 	c.queue.Add(key)
 }
 
+// resyncAll enqueues every matching parent, for scheduled resyncs.
+func (c *decoratorController) resyncAll() {
+	for _, informer := range c.parentInformers {
+		parents, err := informer.Lister().List(labels.Everything())
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("can't list parents of %v for scheduled resync: %v", c.dc.Name, err))
+			continue
+		}
+		for _, parent := range parents {
+			c.enqueueParentObject(parent)
+		}
+	}
+	klog.V(4).InfoS("Scheduled resync", "controller", klog.KObj(c.dc))
+}
+
 func (c *decoratorController) enqueueParentObjectAfter(obj interface{}, delay time.Duration) {
-	key, err := parentQueueKey(obj)
+	key, err := common.ParentQueueKey(obj)
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
 		return
@@ -311,6 +558,13 @@ func (c *decoratorController) enqueueParentObjectAfter(obj interface{}, delay ti
 
 func (c *decoratorController) updateParentObject(old, cur interface{}) {
 	// TODO(enisoc): Is there any way to avoid resyncing after our own updates?
+	// We do ignore our own updates of the sync failure annotations, since
+	// syncing right away would defeat the retry backoff.
+	if oldParent, ok := old.(*unstructured.Unstructured); ok {
+		if curParent, ok := cur.(*unstructured.Unstructured); ok && common.OnlySyncFailuresChanged(oldParent, curParent) {
+			return
+		}
+	}
 	c.enqueueParentObject(cur)
 }
 
@@ -350,7 +604,7 @@ func (c *decoratorController) resolveControllerRef(childNamespace string, contro
 		// ControllerRef points to.
 		return nil
 	}
-	if !c.parentSelector.Matches(parent) && !dynamicobject.HasFinalizer(parent, c.finalizer.Name) {
+	if !c.parentSelector.Matches(parent) && !c.finalizer.HasFinalizer(parent) {
 		// If the parent doesn't match our selector and doesn't have our finalizer,
 		// we don't care about it.
 		return nil
@@ -366,10 +620,11 @@ func (c *decoratorController) onChildAdd(obj interface{}) {
 		return
 	}
 
-	// If it has no ControllerRef, we don't care.
+	// If it has no ControllerRef, we only care if it's a cross-scope attachment.
 	// DecoratorController doesn't do adoption since there are no child selectors.
 	controllerRef := metav1.GetControllerOf(child)
 	if controllerRef == nil {
+		c.enqueueCrossScopeParent(child)
 		return
 	}
 
@@ -378,7 +633,7 @@ func (c *decoratorController) onChildAdd(obj interface{}) {
 		// The controllerRef isn't a parent we know about.
 		return
 	}
-	klog.V(4).InfoS("Child created or updated", "controller", klog.KObj(c.dc), "parent_kind", parent.GetKind(), "parent", klog.KObj(parent), "child_kind", child.GetKind(), "child", klog.KObj(child))
+	logging.ForParent(c.log, parent.GetKind(), klog.KObj(parent)).V(4).Info("Child created or updated", "child_kind", child.GetKind(), "child", klog.KObj(child))
 	c.enqueueParentObject(parent)
 }
 
@@ -414,9 +669,10 @@ func (c *decoratorController) onChildDelete(obj interface{}) {
 	}
 
 	// If it's an orphan, there's nothing to do because we never adopt orphans
-	// that are being deleted.
+	// that are being deleted, unless it's a cross-scope attachment.
 	controllerRef := metav1.GetControllerOf(child)
 	if controllerRef == nil {
+		c.enqueueCrossScopeParent(child)
 		return
 	}
 
@@ -426,12 +682,32 @@ func (c *decoratorController) onChildDelete(obj interface{}) {
 		// The controllerRef isn't a parent we know about.
 		return
 	}
-	klog.V(4).InfoS("DecoratorController child deleted", "controller", klog.KObj(c.dc), "parent_kind", parent.GetKind(), "parent", klog.KObj(parent), "child_kind", child.GetKind(), "child", klog.KObj(child))
+	logging.ForParent(c.log, parent.GetKind(), klog.KObj(parent)).V(4).Info("DecoratorController child deleted", "child_kind", child.GetKind(), "child", klog.KObj(child))
 	c.enqueueParentObject(parent)
 }
 
+// syncPressure returns what hooks are told about the pressure on the
+// controller when syncing parent.
+func (c *decoratorController) syncPressure(parent *unstructured.Unstructured) *common.SyncPressure {
+	key, err := common.ParentQueueKey(parent)
+	if err != nil {
+		return nil
+	}
+	return c.syncRetries.Pressure(key, c.queue.Len())
+}
+
+// previousResponseHash returns the hash of the hook response applied in the
+// last successful sync of parent.
+func (c *decoratorController) previousResponseHash(parent *unstructured.Unstructured) string {
+	key, err := common.ParentQueueKey(parent)
+	if err != nil {
+		return ""
+	}
+	return c.syncRetries.PreviousResponseHash(key)
+}
+
 func (c *decoratorController) sync(key string) error {
-	apiVersion, kind, namespace, name, err := splitParentQueueKey(key)
+	apiVersion, kind, namespace, name, err := common.SplitParentQueueKey(key)
 	if err != nil {
 		return err
 	}
@@ -440,31 +716,53 @@ func (c *decoratorController) sync(key string) error {
 	if resource == nil {
 		return fmt.Errorf("can't find kind %q in apiVersion %q", kind, apiVersion)
 	}
+	log := logging.ForParent(c.log, kind, klog.KRef(namespace, name))
 
 	groupVersion, _ := schema.ParseGroupVersion(apiVersion)
 	informer := c.parentInformers.Get(groupVersion.WithResource(resource.Name))
 	if informer == nil {
 		return fmt.Errorf("no informer for resource %q in apiVersion %q", resource.Name, apiVersion)
 	}
-	parent, err := common.GetObject(informer, namespace, name)
+	parentClient, err := c.dynClient.Resource(apiVersion, resource.Name)
+	if err != nil {
+		return err
+	}
+	parent, err := common.GetParent(informer, parentClient, namespace, name, c.dc.Spec.FreshParentRead)
 	if apierrors.IsNotFound(err) {
 		// Swallow the error since there's no point retrying if the parent is gone.
-		klog.V(4).InfoS("Object has been deleted", "kind", kind, "object", klog.KRef(namespace, name))
+		log.V(4).Info("Object has been deleted")
+		c.objectCounts.Forget(key)
+		c.deletionProtection.Forget(key)
+		c.statusWrites.Forget(key)
+		c.syncRetries.Forget(key)
 		return nil
 	}
 	if err != nil {
 		return err
 	}
-	return c.syncParentObject(parent)
+	if err := c.syncParentObject(log, parent); err != nil {
+		return err
+	}
+	c.syncRetries.Synced(key)
+	return nil
 }
 
-func (c *decoratorController) syncParentObject(parent *unstructured.Unstructured) error {
+func (c *decoratorController) syncParentObject(log logr.Logger, parent *unstructured.Unstructured) error {
+	key, err := common.ParentQueueKey(parent)
+	if err != nil {
+		return err
+	}
+
 	// If it doesn't match our selector, and it doesn't have our finalizer, ignore it.
-	if !c.parentSelector.Matches(parent) && !dynamicobject.HasFinalizer(parent, c.finalizer.Name) {
+	if !c.parentSelector.Matches(parent) && !c.finalizer.HasFinalizer(parent) {
+		c.objectCounts.Forget(key)
+		c.deletionProtection.Forget(key)
+		c.statusWrites.Forget(key)
+		c.syncRetries.Forget(key)
 		return nil
 	}
 
-	klog.V(4).InfoS("DecoratorController sync", "controller", klog.KObj(c.dc), "parent_kind", parent.GetKind(), "parent", klog.KObj(parent))
+	log.V(4).Info("DecoratorController sync")
 
 	parentClient, err := c.dynClient.Kind(parent.GetAPIVersion(), parent.GetKind())
 	if err != nil {
@@ -473,16 +771,22 @@ func (c *decoratorController) syncParentObject(parent *unstructured.Unstructured
 
 	// Before taking any other action, add our finalizer (if desired).
 	// This ensures we have a chance to clean up after any action we later take.
-	updatedParent, err := c.finalizer.SyncObject(parentClient, parent)
-	if err != nil {
-		// If we fail to do this, abort before doing anything else and requeue.
-		return fmt.Errorf("can't sync finalizer for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
-	}
-	parent = updatedParent
-
-	// Check the finalizer again in case we just removed it.
-	if !c.parentSelector.Matches(parent) && !dynamicobject.HasFinalizer(parent, c.finalizer.Name) {
-		return nil
+	// In Observe mode, the finalizer is left alone like everything else.
+	if c.dc.Spec.Mode != v1alpha1.ControllerModeObserve {
+		updatedParent, err := c.finalizer.SyncObject(parentClient, parent)
+		if err != nil {
+			// If we fail to do this, abort before doing anything else and requeue.
+			return fmt.Errorf("can't sync finalizer for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+		}
+		parent = updatedParent
+
+		// Check the finalizer again in case we just removed it.
+		if !c.parentSelector.Matches(parent) && !c.finalizer.HasFinalizer(parent) {
+			c.objectCounts.Forget(key)
+			c.deletionProtection.Forget(key)
+			c.statusWrites.Forget(key)
+			return nil
+		}
 	}
 
 	// List all children belonging to this parent, of the kinds we care about.
@@ -491,82 +795,69 @@ func (c *decoratorController) syncParentObject(parent *unstructured.Unstructured
 	if err != nil {
 		return err
 	}
+	c.objectCounts.Observe(key, observedChildren)
+
+	// Keep children pending deletion until the sync hook below has seen them.
+	holdFor, err := c.childFinalizer.HoldChildren(c.dynClient, parent, observedChildren)
+	if err != nil {
+		return fmt.Errorf("can't release children of %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	if holdFor > 0 {
+		c.enqueueParentObjectAfter(parent, holdFor)
+	}
 
 	relatedObjects, err := c.customize.GetRelatedObjects(parent)
 	if err != nil {
 		return err
 	}
 
+	// Summarize attachment readiness, if requested, so hooks don't have to.
+	readiness := common.SummarizeReadiness(c.dc.Spec.ChildReadiness, observedChildren)
+
 	// Call the sync hook to get the desired annotations and children.
+	attachmentAPIVersions := make([]string, 0, len(c.dc.Spec.Attachments))
+	for _, attachment := range c.dc.Spec.Attachments {
+		attachmentAPIVersions = append(attachmentAPIVersions, attachment.APIVersion)
+	}
 	syncRequest := &SyncHookRequest{
-		Controller:  c.dc,
-		Object:      parent,
-		Attachments: observedChildren,
-		Related:     relatedObjects,
+		Controller:           c.dc,
+		Object:               parent,
+		Attachments:          observedChildren,
+		Related:              relatedObjects,
+		Readiness:            readiness,
+		Jobs:                 common.SummarizeJobs(observedChildren),
+		Cluster:              common.NewClusterInfo(c.resources, c.resources.GetKind(parent.GetAPIVersion(), parent.GetKind()), attachmentAPIVersions),
+		Pressure:             c.syncPressure(parent),
+		PreviousResponseHash: c.previousResponseHash(parent),
 	}
 	syncResult, err := c.callSyncHook(syncRequest)
 	if err != nil {
 		return err
 	}
+	responseHash := common.ResponseHash(syncResult)
+	common.PropagateMetadata(c.dc.Spec.PropagateMetadata, parent, syncResult.Attachments)
+	if err := c.childPatches.Apply(syncResult.Attachments); err != nil {
+		return fmt.Errorf("can't apply child patches for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
 	desiredChildren := common.MakeChildMap(parent, syncResult.Attachments)
+	if err := c.childKindPolicy.CheckChildren(c.childKinds, desiredChildren); err != nil {
+		return fmt.Errorf("invalid sync hook response for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+
+	// Hold back children of waves that have to wait for lower waves to be ready.
+	desiredChildren, waitingChildren := common.GateWaves(observedChildren, desiredChildren)
+	readiness.AddWaiting(waitingChildren)
 
 	// Enqueue a delayed resync, if requested.
 	if syncResult.ResyncAfterSeconds > 0 {
 		c.enqueueParentObjectAfter(parent, time.Duration(syncResult.ResyncAfterSeconds*float64(time.Second)))
 	}
 
-	// Set desired labels and annotations on parent.
-	// Also remove finalizer if requested.
-	// Make a copy since parent is from the cache.
-	updatedParent = parent.DeepCopy()
-	parentLabels := updatedParent.GetLabels()
-	if parentLabels == nil {
-		parentLabels = make(map[string]string)
-	}
-	parentAnnotations := updatedParent.GetAnnotations()
-	if parentAnnotations == nil {
-		parentAnnotations = make(map[string]string)
-	}
-	parentStatus, _, err := unstructured.NestedMap(updatedParent.Object, "status")
-	if err != nil {
-		return err
-	}
-	if syncResult.Status == nil {
-		// A null .status in the sync response means leave it unchanged.
-		syncResult.Status = parentStatus
-	}
-
-	labelsChanged := updateStringMap(parentLabels, syncResult.Labels)
-	annotationsChanged := updateStringMap(parentAnnotations, syncResult.Annotations)
-	statusChanged := !reflect.DeepEqual(parentStatus, syncResult.Status)
-
-	// Only do the update if something changed.
-	if labelsChanged || annotationsChanged || statusChanged ||
-		(syncResult.Finalized && dynamicobject.HasFinalizer(parent, c.finalizer.Name)) {
-		updatedParent.SetLabels(parentLabels)
-		updatedParent.SetAnnotations(parentAnnotations)
-		if err := unstructured.SetNestedField(updatedParent.Object, syncResult.Status, "status"); err != nil {
-			return err
-		}
-
-		if statusChanged && parentClient.HasSubresource("status") {
-			// The regular Update below will ignore changes to .status so we do it separately.
-			result, err := parentClient.Namespace(parent.GetNamespace()).UpdateStatus(updatedParent, metav1.UpdateOptions{})
-			if err != nil {
-				return fmt.Errorf("can't update status: %v", err)
-			}
-			// The Update below needs to use the latest ResourceVersion.
-			updatedParent.SetResourceVersion(result.GetResourceVersion())
-		}
-
-		if syncResult.Finalized {
-			dynamicobject.RemoveFinalizer(updatedParent, c.finalizer.Name)
-		}
-
-		klog.V(4).InfoS("DecoratorController updating", "controller", klog.KObj(c.dc), "parent_kind", parent.GetKind(), "parent", klog.KObj(parent))
-		_, err = parentClient.Namespace(parent.GetNamespace()).Update(updatedParent, metav1.UpdateOptions{})
-		if err != nil {
-			return fmt.Errorf("can't update %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	// Release our finalizer from attachments before the parent is finalized,
+	// since nobody will be left to release them afterwards.
+	if syncResult.Finalized && c.finalizer.HasFinalizer(parent) {
+		if err := c.childFinalizer.ReleaseChildren(c.dynClient, observedChildren); err != nil {
+			return fmt.Errorf("can't release attachments of %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
 		}
 	}
 
@@ -585,6 +876,28 @@ func (c *decoratorController) syncParentObject(parent *unstructured.Unstructured
 			child.SetAnnotations(ann)
 		}
 	}
+	if err := c.markCrossScope(parent, desiredChildren); err != nil {
+		return err
+	}
+
+	if c.dc.Spec.Mode == v1alpha1.ControllerModeObserve {
+		return c.observeParentObject(log, parentClient, parent, observedChildren, desiredChildren, syncResult, readiness)
+	}
+
+	// Set desired labels, annotations and status on parent.
+	// Also remove finalizer if requested.
+	// If the parent was changed since we read it, try again on a fresh copy.
+	// A status that's deferred to a later sync isn't written yet, so the
+	// response is only recorded as applied by the sync that writes it.
+	statusDeferred := false
+	err = parentClient.Namespace(parent.GetNamespace()).RetryOnConflict(parent, func(current *unstructured.Unstructured) error {
+		var err error
+		statusDeferred, err = c.updateParent(log, parentClient, current, syncResult, readiness)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("can't update %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
 
 	// Reconcile child objects belonging to this parent.
 	// Remember manage error, but continue to update status regardless.
@@ -594,9 +907,60 @@ func (c *decoratorController) syncParentObject(parent *unstructured.Unstructured
 	var manageErr error
 	if parent.GetDeletionTimestamp() == nil || c.finalizer.ShouldFinalize(parent) {
 		// Reconcile children.
-		if err := common.ManageChildren(c.dynClient, c.updateStrategy, parent, observedChildren, desiredChildren); err != nil {
+		// Attachments the hook patched are left out, so they're only patched.
+		manageChildren, childPatchPlan, err := common.PlanChildPatches(parent, observedChildren, desiredChildren, syncResult.Patches)
+		if err != nil {
+			return err
+		}
+		// The hook may only have returned the children that changed, and
+		// the ones to delete.
+		if syncResult.OthersUnchanged {
+			manageChildren, err = common.KeepUnchanged(parent, manageChildren, desiredChildren, syncResult.Deleted)
+			if err != nil {
+				return err
+			}
+		}
+		// While finalizing, children are deleted in order, if requested.
+		if parent.GetDeletionTimestamp() != nil {
+			manageChildren = common.OrderDeletions(manageChildren, desiredChildren)
+		}
+		if c.dc.Spec.Mode == v1alpha1.ControllerModeAudit {
+			// Attachments are never adopted, so only deletions are held back.
+			audit := common.NewAudit(parent, nil)
+			manageChildren, desiredChildren = audit.Filter(manageChildren, desiredChildren)
+			audit.Report(c.eventRecorder)
+		}
+		desiredChildren = common.SkipTerminatingNamespaces(c.namespaceInformer.Lister(), parent, manageChildren, desiredChildren)
+		manageChildren, retryAfter := c.deletionProtection.Filter(c.eventRecorder, key, parent, manageChildren, desiredChildren)
+		if retryAfter > 0 {
+			c.enqueueParentObjectAfter(parent, retryAfter)
+		}
+		if c.dc.Spec.DryRunChildren {
+			err = common.DryRunChildren(c.dynClient, c.eventRecorder, parent, manageChildren, desiredChildren)
+		}
+		if err == nil {
+			manage := common.ManageChildren
+			if c.dc.Spec.RollbackChildren {
+				manage = common.ManageChildrenWithRollback
+			}
+			retryAfter, err = manage(log, c.dynClient, c.eventRecorder, c.dc.Spec.ChildApplyMode, c.updateStrategy, c.childFinalizer, c.ownerRefsFor(parent), parent, manageChildren, desiredChildren, int(c.dc.Spec.ChildWriteParallelism))
+			if retryAfter > 0 {
+				c.enqueueParentObjectAfter(parent, retryAfter)
+			}
+		}
+		if err == nil {
+			err = childPatchPlan.Send(log, c.dynClient)
+		}
+		if err != nil {
 			manageErr = fmt.Errorf("can't reconcile children for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
 		}
+	} else if err := c.childFinalizer.ReleaseChildren(c.dynClient, observedChildren); err != nil {
+		// We're not going to manage children anymore (e.g. the GC is deleting
+		// them), so don't let our finalizer hold them up.
+		manageErr = fmt.Errorf("can't release children of %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	if manageErr == nil && !statusDeferred {
+		c.syncRetries.Applied(key, responseHash)
 	}
 
 	return manageErr
@@ -615,12 +979,22 @@ func (c *decoratorController) getChildren(parent *unstructured.Unstructured) (co
 		if informer == nil {
 			return nil, fmt.Errorf("no informer for resource %q in apiVersion %q", child.Resource, child.APIVersion)
 		}
+		childClient, err := c.dynClient.Resource(child.APIVersion, child.Resource)
+		if err != nil {
+			return nil, err
+		}
+		// Attachments have a controller reference to their parent, so
+		// they can be looked up in the index rather than scanning everything.
+		// Cross-scope attachments are looked up by label instead.
+		crossScope := c.isCrossScope(parent, childClient.Group, childClient.Kind)
 		var all []*unstructured.Unstructured
-		var err error
-		if parentNamespace != "" {
-			all, err = informer.Lister().Namespace(parentNamespace).List(labels.Everything())
+		if crossScope {
+			selector := labels.SelectorFromSet(labels.Set{parentUIDLabel: string(parentUID)})
+			all, err = common.ListObjects(informer, childClient, "", selector, child.BypassCache)
+		} else if child.BypassCache {
+			all, err = common.ListObjects(informer, childClient, parentNamespace, labels.Everything(), true)
 		} else {
-			all, err = informer.Lister().List(labels.Everything())
+			all, err = informer.ListControlledBy(parentUID)
 		}
 		if err != nil {
 			return nil, fmt.Errorf("can't list children for resource %q in apiVersion %q: %v", child.Resource, child.APIVersion, err)
@@ -636,8 +1010,11 @@ func (c *decoratorController) getChildren(parent *unstructured.Unstructured) (co
 		// Take only the objects that belong to this parent,
 		// and that were created by this decorator.
 		for _, obj := range all {
-			controllerRef := metav1.GetControllerOf(obj)
-			if controllerRef == nil || controllerRef.UID != parentUID {
+			if crossScope {
+				if obj.GetLabels()[parentUIDLabel] != string(parentUID) {
+					continue
+				}
+			} else if controllerRef := metav1.GetControllerOf(obj); controllerRef == nil || controllerRef.UID != parentUID {
 				continue
 			}
 			if obj.GetAnnotations()[decoratorControllerAnnotation] != c.dc.Name {
@@ -685,25 +1062,120 @@ func makeUpdateStrategyMap(resources *dynamicdiscovery.ResourceMap, dc *v1alpha1
 	return m, nil
 }
 
-func parentQueueKey(obj interface{}) (string, error) {
-	switch o := obj.(type) {
-	case cache.DeletedFinalStateUnknown:
-		return o.Key, nil
-	case cache.ExplicitKey:
-		return string(o), nil
-	case *unstructured.Unstructured:
-		return fmt.Sprintf("%s:%s:%s:%s", o.GetAPIVersion(), o.GetKind(), o.GetNamespace(), o.GetName()), nil
-	default:
-		return "", fmt.Errorf("can't get key for object of type %T; expected *unstructured.Unstructured", obj)
+// makeChildFinalizer returns the finalizer to place on attachments of the
+// kinds that request it.
+func makeChildFinalizer(resources *dynamicdiscovery.ResourceMap, dc *v1alpha1.DecoratorController, parentFinalizer *finalizer.Manager, eventRecorder record.EventRecorder) (*common.ChildFinalizer, error) {
+	childFinalizer := &common.ChildFinalizer{
+		Manager:       parentFinalizer,
+		Kinds:         make(map[schema.GroupKind]bool),
+		EventRecorder: eventRecorder,
+	}
+	for _, child := range dc.Spec.Attachments {
+		if child.Finalize == nil || !*child.Finalize {
+			continue
+		}
+		if !parentFinalizer.Enabled {
+			return nil, fmt.Errorf("attachment resource %q in apiVersion %q: finalize requires a finalize hook", child.Resource, child.APIVersion)
+		}
+		resource := resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find attachment resource %q in %v", child.Resource, child.APIVersion)
+		}
+		childFinalizer.Kinds[schema.GroupKind{Group: resource.Group, Kind: resource.Kind}] = true
 	}
+	return childFinalizer, nil
 }
 
-func splitParentQueueKey(key string) (apiVersion, kind, namespace, name string, err error) {
-	parts := strings.SplitN(key, ":", 4)
-	if len(parts) != 4 {
-		return "", "", "", "", fmt.Errorf("invalid parent key: %q", key)
+// updateParent applies the labels, annotations and status returned by the
+// sync hook to parent, and removes our finalizer if the hook is done with it.
+// It returns API errors as-is so conflicts can be retried.
+func (c *decoratorController) updateParent(log logr.Logger, parentClient *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, syncResult *SyncHookResponse, readiness *common.ReadinessSummary) (statusDeferred bool, err error) {
+	// Make a copy since parent may be from the cache.
+	updatedParent := parent.DeepCopy()
+	parentLabels := updatedParent.GetLabels()
+	if parentLabels == nil {
+		parentLabels = make(map[string]string)
+	}
+	parentAnnotations := updatedParent.GetAnnotations()
+	if parentAnnotations == nil {
+		parentAnnotations = make(map[string]string)
+	}
+	parentStatus, _, err := unstructured.NestedMap(updatedParent.Object, "status")
+	if err != nil {
+		return false, err
+	}
+	status := syncResult.Status
+	if status == nil {
+		// A null .status in the sync response means leave it unchanged.
+		status = parentStatus
+	} else {
+		// Keep the transition times of conditions that didn't change.
+		common.MergeConditions(parentStatus, status, time.Now())
+	}
+	if readiness != nil && c.dc.Spec.ChildReadiness.StatusField != "" {
+		// Copy the status before injecting the summary, so we can still tell
+		// whether it changed.
+		withReadiness := make(map[string]interface{}, len(status)+1)
+		for k, v := range status {
+			withReadiness[k] = v
+		}
+		common.SetReadinessStatus(c.dc.Spec.ChildReadiness, withReadiness, readiness)
+		status = withReadiness
+	}
+
+	labelsChanged := updateStringMap(parentLabels, syncResult.Labels)
+	annotationsChanged := updateStringMap(parentAnnotations, syncResult.Annotations)
+	statusChanged := !common.StatusEqual(parentStatus, status)
+	key, err := common.ParentQueueKey(parent)
+	if err != nil {
+		return false, err
+	}
+	if statusChanged {
+		// If the status was just written by another sync, write this one
+		// along with the status of a later sync instead.
+		if delay := c.statusWrites.Delay(key); delay > 0 {
+			c.enqueueParentObjectAfter(parent, delay)
+			statusChanged, statusDeferred = false, true
+		}
+	}
+	if !statusChanged {
+		// Don't reorder conditions or bump their timestamps for nothing.
+		status = parentStatus
+	}
+
+	// Only do the update if something changed.
+	if !labelsChanged && !annotationsChanged && !statusChanged &&
+		!(syncResult.Finalized && c.finalizer.HasFinalizer(parent)) {
+		return statusDeferred, nil
+	}
+	updatedParent.SetLabels(parentLabels)
+	updatedParent.SetAnnotations(parentAnnotations)
+	if err := unstructured.SetNestedField(updatedParent.Object, status, "status"); err != nil {
+		return false, err
+	}
+
+	if statusChanged && parentClient.HasSubresource("status") {
+		// The regular Update below will ignore changes to .status so we do it separately.
+		result, err := parentClient.Namespace(parent.GetNamespace()).UpdateStatus(updatedParent, metav1.UpdateOptions{})
+		if err != nil {
+			return false, err
+		}
+		// The Update below needs to use the latest ResourceVersion.
+		updatedParent.SetResourceVersion(result.GetResourceVersion())
+	}
+
+	if syncResult.Finalized {
+		c.finalizer.RemoveFinalizerFrom(updatedParent)
+	}
+
+	log.V(4).Info("DecoratorController updating")
+	if _, err := parentClient.Namespace(parent.GetNamespace()).Update(updatedParent, metav1.UpdateOptions{}); err != nil {
+		return false, err
+	}
+	if statusChanged {
+		c.statusWrites.Written(key)
 	}
-	return parts[0], parts[1], parts[2], parts[3], nil
+	return statusDeferred, nil
 }
 
 func updateStringMap(dest map[string]string, updates map[string]*string) (changed bool) {
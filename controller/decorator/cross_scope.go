@@ -0,0 +1,124 @@
+package decorator
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+const (
+	// parentUIDLabel holds the UID of the namespaced target of a
+	// cluster-scoped attachment, which can't have an owner reference to it.
+	parentUIDLabel = "metacontroller.io/decorator-parent-uid"
+	// parentAnnotation holds the queue key of the namespaced target of a
+	// cluster-scoped attachment, so changes to the attachment sync it.
+	parentAnnotation = "metacontroller.io/decorator-parent"
+)
+
+// makeCrossScopeAttachments returns the attachment kinds that are
+// cluster-scoped while some of the target resources are namespaced. Such
+// attachments are tracked by labels rather than owner references, since
+// owner references can't point from a cluster-scoped object to a namespaced
+// one.
+func makeCrossScopeAttachments(resources *dynamicdiscovery.ResourceMap, dc *v1alpha1.DecoratorController) (common.ChildOwnerReferences, error) {
+	namespacedTargets := false
+	for _, parent := range dc.Spec.Resources {
+		resource := resources.Get(parent.APIVersion, parent.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find resource %q in apiVersion %q", parent.Resource, parent.APIVersion)
+		}
+		namespacedTargets = namespacedTargets || resource.Namespaced
+	}
+	if !namespacedTargets {
+		return nil, nil
+	}
+	crossScope := make(common.ChildOwnerReferences)
+	for _, child := range dc.Spec.Attachments {
+		resource := resources.Get(child.APIVersion, child.Resource)
+		if resource == nil {
+			return nil, fmt.Errorf("can't find attachment resource %q in apiVersion %q", child.Resource, child.APIVersion)
+		}
+		if !resource.Namespaced {
+			crossScope[schema.GroupKind{Group: resource.Group, Kind: resource.Kind}] = &v1alpha1.ChildOwnerReference{LabelsOnly: true}
+		}
+	}
+	if len(crossScope) == 0 {
+		return nil, nil
+	}
+	return crossScope, nil
+}
+
+// ownerRefsFor returns the owner reference settings of the attachments of
+// parent: cross-scope attachments of namespaced targets are tracked by labels.
+func (c *decoratorController) ownerRefsFor(parent *unstructured.Unstructured) common.ChildOwnerReferences {
+	if parent.GetNamespace() == "" {
+		return nil
+	}
+	return c.crossScope
+}
+
+// isCrossScope returns whether attachments of the given kind are tracked by
+// labels for parent.
+func (c *decoratorController) isCrossScope(parent *unstructured.Unstructured, apiGroup, kind string) bool {
+	return c.ownerRefsFor(parent).Get(apiGroup, kind) != nil
+}
+
+// markCrossScope labels and annotates the desired cross-scope attachments of
+// parent, so they can be found without an owner reference.
+func (c *decoratorController) markCrossScope(parent *unstructured.Unstructured, desired common.ChildMap) error {
+	if c.ownerRefsFor(parent) == nil {
+		return nil
+	}
+	key, err := common.ParentQueueKey(parent)
+	if err != nil {
+		return err
+	}
+	for _, group := range desired {
+		for _, child := range group {
+			apiGroup, _ := common.ParseAPIVersion(child.GetAPIVersion())
+			if !c.isCrossScope(parent, apiGroup, child.GetKind()) {
+				continue
+			}
+			childLabels := child.GetLabels()
+			if childLabels == nil {
+				childLabels = make(map[string]string)
+			}
+			childLabels[parentUIDLabel] = string(parent.GetUID())
+			child.SetLabels(childLabels)
+			ann := child.GetAnnotations()
+			if ann == nil {
+				ann = make(map[string]string)
+			}
+			ann[parentAnnotation] = key
+			child.SetAnnotations(ann)
+		}
+	}
+	return nil
+}
+
+// enqueueCrossScopeParent enqueues the target of a cross-scope attachment
+// created by this decorator, if child is one.
+func (c *decoratorController) enqueueCrossScopeParent(child *unstructured.Unstructured) {
+	if child.GetLabels()[parentUIDLabel] == "" || child.GetAnnotations()[decoratorControllerAnnotation] != c.dc.Name {
+		return
+	}
+	if key := child.GetAnnotations()[parentAnnotation]; key != "" {
+		c.queue.Add(key)
+	}
+}
+
+// cleanupResponse is the response used instead of calling the hooks when a
+// target that has cross-scope attachments is finalized and there's no
+// finalize hook: it asks for no attachments at all, and is finalized once
+// they're all gone, since the garbage collector can't delete cross-scope
+// attachments.
+func cleanupResponse(request *SyncHookRequest) *SyncHookResponse {
+	return &SyncHookResponse{
+		Finalized: len(request.Attachments.List()) == 0,
+	}
+}
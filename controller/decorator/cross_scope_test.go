@@ -0,0 +1,110 @@
+package decorator
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+)
+
+func TestMarkCrossScope(t *testing.T) {
+	c := &decoratorController{
+		dc: &v1alpha1.DecoratorController{},
+		crossScope: common.ChildOwnerReferences{
+			schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}: &v1alpha1.ChildOwnerReference{LabelsOnly: true},
+		},
+		queue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "test"),
+	}
+	defer c.queue.ShutDown()
+	c.dc.Name = "test"
+
+	child := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetAnnotations(map[string]string{decoratorControllerAnnotation: "test"})
+		return obj
+	}
+
+	table := []struct {
+		name       string
+		namespace  string
+		child      *unstructured.Unstructured
+		wantMarked bool
+	}{
+		{
+			name:       "cluster-scoped attachment of namespaced target",
+			namespace:  "ns",
+			child:      child("rbac.authorization.k8s.io/v1", "ClusterRole", "role"),
+			wantMarked: true,
+		},
+		{
+			name:      "namespaced attachment",
+			namespace: "ns",
+			child:     child("v1", "ConfigMap", "config"),
+		},
+		{
+			name:  "cluster-scoped attachment of cluster-scoped target",
+			child: child("rbac.authorization.k8s.io/v1", "ClusterRole", "role"),
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			parent := &unstructured.Unstructured{}
+			parent.SetAPIVersion("v1")
+			parent.SetKind("ServiceAccount")
+			parent.SetNamespace(tc.namespace)
+			parent.SetName("sa")
+			parent.SetUID("parent-uid")
+
+			if err := c.markCrossScope(parent, common.MakeChildMap(parent, []*unstructured.Unstructured{tc.child})); err != nil {
+				t.Fatalf("markCrossScope() error: %v", err)
+			}
+			uid := tc.child.GetLabels()[parentUIDLabel]
+			if marked := uid != ""; marked != tc.wantMarked {
+				t.Fatalf("marked = %v, want %v", marked, tc.wantMarked)
+			}
+			if !tc.wantMarked {
+				return
+			}
+			if uid != "parent-uid" {
+				t.Errorf("%v label = %q, want %q", parentUIDLabel, uid, "parent-uid")
+			}
+
+			// Changes to the attachment sync its target.
+			c.enqueueCrossScopeParent(tc.child)
+			if got := c.queue.Len(); got != 1 {
+				t.Fatalf("queue length = %v, want 1", got)
+			}
+			key, _ := c.queue.Get()
+			c.queue.Done(key)
+			if want, _ := common.ParentQueueKey(parent); key != want {
+				t.Errorf("enqueued %q, want %q", key, want)
+			}
+		})
+	}
+}
+
+func TestCleanupResponse(t *testing.T) {
+	parent := &unstructured.Unstructured{}
+	parent.SetNamespace("ns")
+	role := &unstructured.Unstructured{}
+	role.SetAPIVersion("rbac.authorization.k8s.io/v1")
+	role.SetKind("ClusterRole")
+	role.SetName("role")
+
+	response := cleanupResponse(&SyncHookRequest{Attachments: common.MakeChildMap(parent, []*unstructured.Unstructured{role})})
+	if response.Finalized || len(response.Attachments) != 0 {
+		t.Errorf("cleanupResponse() = %+v, want no attachments, not finalized", response)
+	}
+	response = cleanupResponse(&SyncHookRequest{Attachments: make(common.ChildMap)})
+	if !response.Finalized {
+		t.Errorf("cleanupResponse() isn't finalized once attachments are gone")
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package decorator
 
 import (
+	_ "embed"
 	"fmt"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -33,6 +34,33 @@ type SyncHookRequest struct {
 	Attachments common.ChildMap               `json:"attachments"`
 	Related     common.ChildMap               `json:"related"`
 	Finalizing  bool                          `json:"finalizing"`
+
+	// Readiness is only set if the controller enables childReadiness.
+	Readiness *common.ReadinessSummary `json:"readiness,omitempty"`
+
+	// Jobs is only set if there are Job attachments.
+	Jobs *common.JobSummary `json:"jobs,omitempty"`
+
+	// Desired is only set for postSync hooks. It's the response of the
+	// previous hook in the pipeline.
+	Desired *SyncHookResponse `json:"desired,omitempty"`
+
+	// Cluster tells which versions of the API groups the controller deals
+	// with are served.
+	Cluster *common.ClusterInfo `json:"cluster"`
+
+	// Pressure tells how busy the controller is, and how the syncs of the
+	// object went lately.
+	Pressure *common.SyncPressure `json:"pressure,omitempty"`
+
+	// PreviousResponseHash is the hash of the response applied in the last
+	// successful sync of the object, so hooks can tell if anything they
+	// returned still has to be applied. It's empty if there was none since
+	// Metacontroller started.
+	PreviousResponseHash string `json:"previousResponseHash,omitempty"`
+
+	// Parameters are the hook parameters of the controller, if it has any.
+	Parameters map[string]string `json:"parameters,omitempty"`
 }
 
 // SyncHookResponse is the expected format of the JSON response from the sync hook.
@@ -41,6 +69,14 @@ type SyncHookResponse struct {
 	Annotations map[string]*string           `json:"annotations"`
 	Status      map[string]interface{}       `json:"status"`
 	Attachments []*unstructured.Unstructured `json:"attachments"`
+	// Patches change existing attachments that aren't in Attachments,
+	// leaving the fields they don't touch alone.
+	Patches []common.PatchedChild `json:"patches,omitempty"`
+	// OthersUnchanged says Attachments only holds the attachments that
+	// changed, and the other observed attachments are to be left as they are.
+	OthersUnchanged bool `json:"othersUnchanged,omitempty"`
+	// Deleted lists the attachments to delete when OthersUnchanged is set.
+	Deleted []common.DeletedChild `json:"deleted,omitempty"`
 
 	ResyncAfterSeconds float64 `json:"resyncAfterSeconds"`
 
@@ -48,11 +84,36 @@ type SyncHookResponse struct {
 	Finalized bool `json:"finalized"`
 }
 
+//go:embed sync-response.schema.json
+var syncResponseSchemaJSON []byte
+
+// syncResponseSchema is the published schema of sync hook responses.
+var syncResponseSchema = hooks.MustLoadSchema(syncResponseSchemaJSON)
+
+// ResponseSchema implements hooks.ResponseValidator.
+func (*SyncHookResponse) ResponseSchema() *hooks.Schema {
+	return syncResponseSchema
+}
+
 func (c *decoratorController) callSyncHook(request *SyncHookRequest) (*SyncHookResponse, error) {
 	if c.dc.Spec.Hooks == nil {
 		return nil, fmt.Errorf("no hooks defined")
 	}
 
+	parameters, err := hooks.ResolveParameters(c.dc.GetHookParameters())
+	if err != nil {
+		return nil, err
+	}
+	request.Parameters = parameters
+
+	// Without a finalize hook, our finalizer is only there to delete
+	// cross-scope attachments, which the garbage collector can't.
+	if c.dc.Spec.Hooks.Finalize == nil && len(c.crossScope) > 0 &&
+		(request.Object.GetDeletionTimestamp() != nil || !c.parentSelector.Matches(request.Object)) {
+		request.Finalizing = true
+		return cleanupResponse(request), nil
+	}
+
 	var response SyncHookResponse
 
 	// First check if we should instead call the finalize hook,
@@ -67,8 +128,10 @@ func (c *decoratorController) callSyncHook(request *SyncHookRequest) (*SyncHookR
 		(request.Object.GetDeletionTimestamp() != nil || !c.parentSelector.Matches(request.Object)) {
 		// Finalize
 		request.Finalizing = true
-		if err := hooks.Call(c.dc.Spec.Hooks.Finalize, request, &response); err != nil {
-			return nil, fmt.Errorf("finalize hook failed: %v", err)
+		if err := common.CallHook("DecoratorController", c.dc.Name, "finalize", request.Object, func(traceParent string) error {
+			return hooks.CallForObject(c.dc.Spec.Hooks.Finalize, request.Object, traceParent, request, &response)
+		}); err != nil {
+			return nil, fmt.Errorf("finalize hook failed: %w", err)
 		}
 	} else {
 		// Sync
@@ -77,10 +140,60 @@ func (c *decoratorController) callSyncHook(request *SyncHookRequest) (*SyncHookR
 			return nil, fmt.Errorf("sync hook not defined")
 		}
 
-		if err := hooks.Call(c.dc.Spec.Hooks.Sync, request, &response); err != nil {
-			return nil, fmt.Errorf("sync hook failed: %v", err)
+		if err := common.CallHook("DecoratorController", c.dc.Name, "sync", request.Object, func(traceParent string) error {
+			return hooks.CallForObject(c.dc.Spec.Hooks.Sync, request.Object, traceParent, request, &response)
+		}); err != nil {
+			return nil, fmt.Errorf("sync hook failed: %w", err)
+		}
+	}
+
+	// Pass the response through each postSync hook, in order.
+	for i := range c.dc.Spec.Hooks.PostSync {
+		request.Desired = &response
+		var next SyncHookResponse
+		if err := common.CallHook("DecoratorController", c.dc.Name, "postSync", request.Object, func(traceParent string) error {
+			return hooks.CallForObject(&c.dc.Spec.Hooks.PostSync[i], request.Object, traceParent, request, &next)
+		}); err != nil {
+			return nil, fmt.Errorf("postSync hook %v failed: %w", i, err)
 		}
+		response = next
+	}
+	request.Desired = nil
+
+	// Conditions the hooks set must be valid metav1.Conditions.
+	if err := common.ValidateConditions(response.Status); err != nil {
+		return nil, fmt.Errorf("invalid status in hook response: %v", err)
 	}
 
 	return &response, nil
 }
+
+// validateHooks checks the hooks of dc when the controller is created.
+func validateHooks(dc *v1alpha1.DecoratorController) error {
+	if err := hooks.ValidateParameters(dc.GetHookParameters()); err != nil {
+		return err
+	}
+	spec := dc.Spec.Hooks
+	if spec == nil {
+		return nil
+	}
+	named := []struct {
+		name string
+		hook *v1alpha1.Hook
+	}{
+		{"customize", spec.Customize},
+		{"sync", spec.Sync},
+		{"finalize", spec.Finalize},
+	}
+	for _, h := range named {
+		if err := hooks.ValidateHook(h.hook); err != nil {
+			return fmt.Errorf("invalid %v hook: %v", h.name, err)
+		}
+	}
+	for i := range spec.PostSync {
+		if err := hooks.ValidateHook(&spec.PostSync[i]); err != nil {
+			return fmt.Errorf("invalid postSync hook %d: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -28,6 +28,7 @@ import (
 
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
@@ -39,6 +40,7 @@ import (
 	dynamicclientset "metacontroller.io/dynamic/clientset"
 	dynamicdiscovery "metacontroller.io/dynamic/discovery"
 	dynamicinformer "metacontroller.io/dynamic/informer"
+	dynamicobject "metacontroller.io/dynamic/object"
 )
 
 type Metacontroller struct {
@@ -49,16 +51,22 @@ type Metacontroller struct {
 	dcLister   mclisters.DecoratorControllerLister
 	dcInformer cache.SharedIndexInformer
 
-	queue                workqueue.RateLimitingInterface
+	queue workqueue.RateLimitingInterface
+	// waitLimiter delays retries of controllers that wait for their
+	// resources.
+	waitLimiter          workqueue.RateLimiter
 	decoratorControllers map[string]*decoratorController
 
 	stopCh, doneCh chan struct{}
 
 	numWorkers    int
 	eventRecorder record.EventRecorder
+	broadcasters  *events.Broadcasters
+
+	childKindPolicy common.ChildKindPolicy
 }
 
-func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, mcInformerFactory mcinformers.SharedInformerFactory, numWorkers int, recorder record.EventRecorder) *Metacontroller {
+func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, mcInformerFactory mcinformers.SharedInformerFactory, numWorkers int, recorder record.EventRecorder, broadcasters *events.Broadcasters, childKindPolicy common.ChildKindPolicy) *Metacontroller {
 	mc := &Metacontroller{
 		resources:    resources,
 		dynClient:    dynClient,
@@ -68,10 +76,13 @@ func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynClient *dynam
 		dcInformer: mcInformerFactory.Metacontroller().V1alpha1().DecoratorControllers().Informer(),
 
 		queue:                workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "DecoratorController"),
+		waitLimiter:          common.NewWaitingRateLimiter(),
 		decoratorControllers: make(map[string]*decoratorController),
 
-		numWorkers:    numWorkers,
-		eventRecorder: recorder,
+		numWorkers:      numWorkers,
+		eventRecorder:   recorder,
+		broadcasters:    broadcasters,
+		childKindPolicy: childKindPolicy,
 	}
 
 	mc.dcInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -79,6 +90,8 @@ func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynClient *dynam
 		UpdateFunc: mc.updateDecoratorController,
 		DeleteFunc: mc.enqueueDecoratorController,
 	})
+	// Start controllers that wait for their resources as soon as they appear.
+	resources.AddListener(mc.enqueueWaiting)
 
 	return mc
 }
@@ -94,9 +107,17 @@ func (mc *Metacontroller) Start() {
 		klog.InfoS("Starting DecoratorController metacontroller")
 		defer klog.InfoS("Shutting down DecoratorController metacontroller")
 
+		common.SetWaiting("DecoratorController", "", "waiting for informer caches to sync")
 		if !cache.WaitForNamedCacheSync("DecoratorController", mc.stopCh, mc.dcInformer.HasSynced) {
 			return
 		}
+		// Stay unready until every existing controller was started once.
+		if dcs, err := mc.dcLister.List(labels.Everything()); err == nil {
+			for _, dc := range dcs {
+				common.SetWaiting("DecoratorController", dc.Name, "starting")
+			}
+		}
+		common.SetNotWaiting("DecoratorController", "")
 
 		// In the metacontroller, we are only responsible for starting/stopping
 		// the actual controllers, so a single worker should be enough.
@@ -131,6 +152,11 @@ func (mc *Metacontroller) processNextWorkItem() bool {
 	defer mc.queue.Done(key)
 
 	err := mc.sync(key.(string))
+	if common.IsWaitingForResources(err) {
+		klog.InfoS("DecoratorController is waiting for its resources", "name", key, "reason", err.Error())
+		mc.queue.AddAfter(key, mc.waitLimiter.When(key))
+		return true
+	}
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("failed to sync DecoratorController %q: %v", key, err))
 		mc.queue.AddRateLimited(key)
@@ -138,6 +164,7 @@ func (mc *Metacontroller) processNextWorkItem() bool {
 	}
 
 	mc.queue.Forget(key)
+	mc.waitLimiter.Forget(key)
 	return true
 }
 
@@ -152,12 +179,8 @@ func (mc *Metacontroller) sync(key string) error {
 	dc, err := mc.dcLister.Get(name)
 	if apierrors.IsNotFound(err) {
 		klog.V(4).InfoS("DecoratorController has been deleted", "name", name)
-		// Stop and remove the controller if it exists.
-		if c, ok := mc.decoratorControllers[name]; ok {
-			c.Stop()
-			defer c.eventRecorder.Eventf(c.dc, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", c.dc.Name)
-			delete(mc.decoratorControllers, name)
-		}
+		common.SetNotWaiting("DecoratorController", name)
+		mc.stopDecoratorController(name)
 		return nil
 	}
 	if err != nil {
@@ -167,11 +190,37 @@ func (mc *Metacontroller) sync(key string) error {
 	return mc.syncDecoratorController(dc)
 }
 
+// stopDecoratorController stops and removes the controller with the given
+// name, if it exists.
+func (mc *Metacontroller) stopDecoratorController(name string) {
+	c, ok := mc.decoratorControllers[name]
+	if !ok {
+		return
+	}
+	c.Stop()
+	mc.eventRecorder.Eventf(c.dc, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", c.dc.Name)
+	delete(mc.decoratorControllers, name)
+}
+
 func (mc *Metacontroller) syncDecoratorController(dc *v1alpha1.DecoratorController) error {
+	if dc.DeletionTimestamp != nil && dynamicobject.HasFinalizer(dc, common.CleanupFinalizer) {
+		return mc.cleanupDecoratorController(dc)
+	}
+	if common.ValidateDeletionPolicy(dc.Spec.DeletionPolicy) == nil {
+		if err := common.SyncCleanupFinalizer(mc.dynClient, "decoratorcontrollers", dc, dc.Spec.DeletionPolicy); err != nil {
+			return fmt.Errorf("can't sync cleanup finalizer: %v", err)
+		}
+	}
+
 	if c, ok := mc.decoratorControllers[dc.Name]; ok {
 		// The controller was already started.
 		if apiequality.Semantic.DeepEqual(dc.Spec, c.dc.Spec) {
-			// Nothing has changed.
+			// Nothing has changed, unless a resync was requested.
+			if request, changed := common.ResyncRequested(dc, c.resyncRequest); changed {
+				klog.InfoS("Resync requested", "controller", klog.KObj(dc), "request", request)
+				c.resyncRequest = request
+				c.resyncAll()
+			}
 			return nil
 		}
 		// Stop and remove the controller so it can be recreated.
@@ -180,16 +229,73 @@ func (mc *Metacontroller) syncDecoratorController(dc *v1alpha1.DecoratorControll
 		delete(mc.decoratorControllers, dc.Name)
 	}
 
-	c, err := newDecoratorController(mc.resources, mc.dynClient, mc.dynInformers, dc, mc.numWorkers, mc.eventRecorder)
+	// Wait for discovery and CRDs instead of failing to create informers.
+	if err := common.CheckResources(mc.resources, mc.dynClient, decoratorResourceRules(dc)); err != nil {
+		common.SetWaiting("DecoratorController", dc.Name, err.Error())
+		mc.setReadyCondition(dc, false, common.ReasonWaitingForResources, err.Error())
+		return err
+	}
+
+	// Each controller has its own events rate limits and source, and its own
+	// client rate limits if requested.
+	dynClient, err := common.RateLimitedClient(mc.dynClient, dc.Spec.ClientRateLimit)
 	if err != nil {
+		common.SetNotWaiting("DecoratorController", dc.Name)
+		mc.setReadyCondition(dc, false, common.ReasonStartFailed, err.Error())
 		return err
 	}
+	recorder, stopRecorder := mc.broadcasters.NewRecorder(dc.Spec.EventRateLimit, dc.Spec.EventSource)
+	c, err := newDecoratorController(mc.resources, dynClient, mc.dynInformers, dc, mc.numWorkers, recorder, mc.childKindPolicy)
+	if err != nil {
+		stopRecorder()
+		common.SetNotWaiting("DecoratorController", dc.Name)
+		mc.setReadyCondition(dc, false, common.ReasonStartFailed, err.Error())
+		return err
+	}
+	c.stopEventRecorder = stopRecorder
 	c.Start()
 	mc.eventRecorder.Eventf(dc, v1.EventTypeNormal, events.ReasonStarted, "Started controller: %s", dc.Name)
 	mc.decoratorControllers[dc.Name] = c
+	common.SetNotWaiting("DecoratorController", dc.Name)
+	mc.setReadyCondition(dc, true, common.ReasonStarted, "")
 	return nil
 }
 
+// cleanupDecoratorController stops dc, which is being deleted with the Cleanup
+// deletion policy, and cleans up its target objects and attachments before
+// letting it go.
+func (mc *Metacontroller) cleanupDecoratorController(dc *v1alpha1.DecoratorController) error {
+	common.SetNotWaiting("DecoratorController", dc.Name)
+	// Stop first, so the controller doesn't put back the finalizers removed
+	// from its target objects.
+	mc.stopDecoratorController(dc.Name)
+	if err := newCleanup(mc.resources, mc.dynClient, dc).Run(); err != nil {
+		return fmt.Errorf("can't clean up: %v", err)
+	}
+	mc.eventRecorder.Eventf(dc, v1.EventTypeNormal, events.ReasonCleanedUp, "Cleaned up target objects and attachments of controller: %s", dc.Name)
+	return common.RemoveCleanupFinalizer(mc.dynClient, "decoratorcontrollers", dc)
+}
+
+// decoratorResourceRules returns the parent and attachment resources of dc.
+func decoratorResourceRules(dc *v1alpha1.DecoratorController) []v1alpha1.ResourceRule {
+	var rules []v1alpha1.ResourceRule
+	for _, resource := range dc.Spec.Resources {
+		rules = append(rules, resource.ResourceRule)
+	}
+	for _, attachment := range dc.Spec.Attachments {
+		rules = append(rules, attachment.ResourceRule)
+	}
+	return rules
+}
+
+// setReadyCondition updates the Ready condition of dc. Errors are only logged,
+// since the condition is informative.
+func (mc *Metacontroller) setReadyCondition(dc *v1alpha1.DecoratorController, ready bool, reason, message string) {
+	if err := common.SetControllerReadyCondition(mc.dynClient, "decoratorcontrollers", dc, dc.Status.Conditions, ready, reason, message); err != nil {
+		klog.ErrorS(err, "Can't update Ready condition", "controller", klog.KObj(dc))
+	}
+}
+
 func (mc *Metacontroller) enqueueDecoratorController(obj interface{}) {
 	key, err := common.KeyFunc(obj)
 	if err != nil {
@@ -199,6 +305,14 @@ func (mc *Metacontroller) enqueueDecoratorController(obj interface{}) {
 	mc.queue.Add(key)
 }
 
+// enqueueWaiting retries the controllers that wait for their resources, when
+// discovery finds new resources.
+func (mc *Metacontroller) enqueueWaiting() {
+	for _, name := range common.WaitingControllers("DecoratorController") {
+		mc.queue.Add(name)
+	}
+}
+
 func (mc *Metacontroller) updateDecoratorController(old, cur interface{}) {
 	mc.enqueueDecoratorController(cur)
 }
@@ -0,0 +1,32 @@
+package decorator
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/controller/common"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+)
+
+// observeParentObject finishes the sync of parent in Observe mode: the changes
+// that would be made to attachments are only reported, and only the status
+// of the parent is updated, leaving its labels and annotations alone.
+func (c *decoratorController) observeParentObject(log logr.Logger, parentClient *dynamicclientset.ResourceClient, parent *unstructured.Unstructured, observedChildren, desiredChildren common.ChildMap, syncResult *SyncHookResponse, readiness *common.ReadinessSummary) error {
+	changes, err := common.PlanChildren(c.dc.Spec.ChildApplyMode, c.updateStrategy, nil, parent, observedChildren, desiredChildren)
+	if err != nil {
+		return fmt.Errorf("can't plan attachments for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	common.ReportChanges(c.eventRecorder, "DecoratorController", c.dc.Name, parent, changes)
+
+	statusOnly := &SyncHookResponse{Status: syncResult.Status}
+	err = parentClient.Namespace(parent.GetNamespace()).RetryOnConflict(parent, func(current *unstructured.Unstructured) error {
+		_, err := c.updateParent(log, parentClient, current, statusOnly, readiness)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("can't update status for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package decorator
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"metacontroller.io/controller/common"
+)
+
+// orphanAudit returns the audit of attachments of this controller whose
+// target object no longer exists.
+func (c *decoratorController) orphanAudit() *common.OrphanAudit {
+	return &common.OrphanAudit{
+		ControllerKind: "DecoratorController",
+		Controller:     c.dc.Name,
+		DynClient:      c.dynClient,
+		ChildInformers: c.childInformers,
+		ParentUIDs: func() (map[types.UID]bool, error) {
+			uids := make(map[types.UID]bool)
+			for _, informer := range c.parentInformers {
+				parents, err := informer.Lister().List(labels.Everything())
+				if err != nil {
+					return nil, err
+				}
+				for _, parent := range parents {
+					uids[parent.GetUID()] = true
+				}
+			}
+			return uids, nil
+		},
+		ParentRef: func(child *unstructured.Unstructured) *metav1.OwnerReference {
+			if child.GetAnnotations()[decoratorControllerAnnotation] != c.dc.Name {
+				return nil
+			}
+			ref := metav1.GetControllerOf(child)
+			if ref == nil {
+				return nil
+			}
+			groupVersion, err := schema.ParseGroupVersion(ref.APIVersion)
+			if err != nil || c.parentKinds.Get(schema.GroupKind{Group: groupVersion.Group, Kind: ref.Kind}) == nil {
+				return nil
+			}
+			return ref
+		},
+	}
+}
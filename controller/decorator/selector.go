@@ -18,25 +18,33 @@ package decorator
 
 import (
 	"fmt"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
 
 	"metacontroller.io/apis/metacontroller/v1alpha1"
 	"metacontroller.io/controller/common"
+	"metacontroller.io/controller/common/cel"
 	dynamicdiscovery "metacontroller.io/dynamic/discovery"
 )
 
 type decoratorSelector struct {
 	labelSelectors      map[string]labels.Selector
 	annotationSelectors map[string]labels.Selector
+	fieldSelectors      map[string]fieldSelector
+	matches             map[string]*cel.Program
 }
 
 func newDecoratorSelector(resources *dynamicdiscovery.ResourceMap, dc *v1alpha1.DecoratorController) (*decoratorSelector, error) {
 	ds := &decoratorSelector{
 		labelSelectors:      make(map[string]labels.Selector),
 		annotationSelectors: make(map[string]labels.Selector),
+		fieldSelectors:      make(map[string]fieldSelector),
+		matches:             make(map[string]*cel.Program),
 	}
 	var err error
 
@@ -75,6 +83,22 @@ func newDecoratorSelector(resources *dynamicdiscovery.ResourceMap, dc *v1alpha1.
 			// missing (not a type we care about) and empty (select everything).
 			ds.annotationSelectors[key] = labels.Everything()
 		}
+
+		// Field paths and values aren't label keys and values, so the field
+		// selector isn't converted to a label selector.
+		if parent.FieldSelector != nil {
+			ds.fieldSelectors[key], err = newFieldSelector(parent.FieldSelector)
+			if err != nil {
+				return nil, fmt.Errorf("can't convert field selector for parent resource %q in apiVersion %q: %v", parent.Resource, parent.APIVersion, err)
+			}
+		}
+
+		if parent.Match != "" {
+			ds.matches[key], err = cel.Compile(parent.Match)
+			if err != nil {
+				return nil, fmt.Errorf("invalid match for parent resource %q in apiVersion %q: %v", parent.Resource, parent.APIVersion, err)
+			}
+		}
 	}
 
 	return ds, nil
@@ -93,9 +117,117 @@ func (ds *decoratorSelector) Matches(obj *unstructured.Unstructured) bool {
 		return false
 	}
 
-	// It must match both selectors.
-	return labelSelector.Matches(labels.Set(obj.GetLabels())) &&
-		annotationSelector.Matches(labels.Set(obj.GetAnnotations()))
+	// It must match all selectors.
+	fieldSelector := ds.fieldSelectors[key]
+	if !fieldSelector.Matches(fieldSet{obj: obj}) {
+		return false
+	}
+	if !labelSelector.Matches(labels.Set(obj.GetLabels())) ||
+		!annotationSelector.Matches(labels.Set(obj.GetAnnotations())) {
+		return false
+	}
+	// Evaluate the match expression last, since it's the most expensive.
+	if match := ds.matches[key]; match != nil {
+		matches, err := match.Matches(obj.UnstructuredContent())
+		if err != nil {
+			klog.InfoS("Match expression failed, not selecting object", "object", klog.KObj(obj), "err", err)
+			return false
+		}
+		return matches
+	}
+	return true
+}
+
+// fieldSelector is a field selector in internal form. The empty selector
+// selects everything.
+type fieldSelector []fieldRequirement
+
+// fieldRequirement is a requirement on the value of the field at path.
+type fieldRequirement struct {
+	path     string
+	operator metav1.LabelSelectorOperator
+	values   sets.String
+}
+
+// newFieldSelector converts selector to internal form. Unlike labels, field
+// paths and values have no syntax or length limits, so only the operators
+// and the number of values they take are checked.
+func newFieldSelector(selector *v1alpha1.FieldSelector) (fieldSelector, error) {
+	var fs fieldSelector
+	for path, value := range selector.MatchFields {
+		if err := validateFieldPath(path); err != nil {
+			return nil, err
+		}
+		fs = append(fs, fieldRequirement{path: path, operator: metav1.LabelSelectorOpIn, values: sets.NewString(value)})
+	}
+	for _, expr := range selector.MatchExpressions {
+		if err := validateFieldPath(expr.Key); err != nil {
+			return nil, err
+		}
+		switch expr.Operator {
+		case metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn:
+			if len(expr.Values) == 0 {
+				return nil, fmt.Errorf("operator %q for field %q needs values", expr.Operator, expr.Key)
+			}
+		case metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist:
+			if len(expr.Values) != 0 {
+				return nil, fmt.Errorf("operator %q for field %q takes no values", expr.Operator, expr.Key)
+			}
+		default:
+			return nil, fmt.Errorf("invalid operator %q for field %q", expr.Operator, expr.Key)
+		}
+		fs = append(fs, fieldRequirement{path: expr.Key, operator: expr.Operator, values: sets.NewString(expr.Values...)})
+	}
+	return fs, nil
+}
+
+func validateFieldPath(path string) error {
+	for _, field := range strings.Split(path, ".") {
+		if field == "" {
+			return fmt.Errorf("invalid field path %q", path)
+		}
+	}
+	return nil
+}
+
+// Matches returns whether fields meets all the requirements.
+func (fs fieldSelector) Matches(fields fieldSet) bool {
+	for _, r := range fs {
+		value, found := fields.lookup(r.path)
+		var ok bool
+		switch r.operator {
+		case metav1.LabelSelectorOpIn:
+			ok = found && r.values.Has(value)
+		case metav1.LabelSelectorOpNotIn:
+			ok = !found || !r.values.Has(value)
+		case metav1.LabelSelectorOpExists:
+			ok = found
+		case metav1.LabelSelectorOpDoesNotExist:
+			ok = !found
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldSet looks up fields of obj, by their dot-separated paths, for field
+// selectors. Fields that are missing or aren't scalars are treated as absent.
+type fieldSet struct {
+	obj *unstructured.Unstructured
+}
+
+func (fs fieldSet) lookup(path string) (string, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(fs.obj.UnstructuredContent(), strings.Split(path, ".")...)
+	if !found || err != nil {
+		return "", false
+	}
+	switch value.(type) {
+	case string, bool, int64, float64:
+		return fmt.Sprint(value), true
+	}
+	return "", false
 }
 
 func selectorMapKey(apiGroup, kind string) string {
@@ -0,0 +1,144 @@
+package decorator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+func TestFieldSelector(t *testing.T) {
+	longValue := strings.Repeat("a", 100)
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":      "test",
+			"namespace": "prod",
+		},
+		"spec": map[string]interface{}{
+			"enabled":  true,
+			"replicas": int64(3),
+			"ports":    []interface{}{int64(80)},
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{"serviceAccountName": "web"},
+			},
+			"url":         "https://example.com/hooks?name=web",
+			"description": longValue,
+		},
+	}}
+	expr := func(path string, operator metav1.LabelSelectorOperator, values ...string) metav1.LabelSelectorRequirement {
+		return metav1.LabelSelectorRequirement{Key: path, Operator: operator, Values: values}
+	}
+
+	table := []struct {
+		name     string
+		selector v1alpha1.FieldSelector
+		want     bool
+	}{
+		{name: "empty", want: true},
+		{name: "equal", selector: v1alpha1.FieldSelector{MatchFields: map[string]string{"metadata.namespace": "prod"}}, want: true},
+		{name: "not in", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{expr("metadata.namespace", metav1.LabelSelectorOpIn, "dev", "staging")}}, want: false},
+		{name: "bool and number", selector: v1alpha1.FieldSelector{MatchFields: map[string]string{"spec.enabled": "true", "spec.replicas": "3"}}, want: true},
+		{name: "nested path", selector: v1alpha1.FieldSelector{MatchFields: map[string]string{"spec.template.spec.serviceAccountName": "web"}}, want: true},
+		{name: "long value", selector: v1alpha1.FieldSelector{MatchFields: map[string]string{"spec.description": longValue}}, want: true},
+		{name: "other long value", selector: v1alpha1.FieldSelector{MatchFields: map[string]string{"spec.description": longValue + "b"}}, want: false},
+		{name: "value with other characters", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{expr("spec.url", metav1.LabelSelectorOpNotIn, "https://example.com/hooks?name=db")}}, want: true},
+		{name: "missing field exists", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{expr("spec.type", metav1.LabelSelectorOpExists)}}, want: false},
+		{name: "missing field does not exist", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{expr("spec.type", metav1.LabelSelectorOpDoesNotExist)}}, want: true},
+		{name: "missing field not in", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{expr("spec.type", metav1.LabelSelectorOpNotIn, "LoadBalancer")}}, want: true},
+		// Only scalar fields can be matched.
+		{name: "list", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{expr("spec.ports", metav1.LabelSelectorOpExists)}}, want: false},
+		{name: "map", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{expr("spec", metav1.LabelSelectorOpExists)}}, want: false},
+	}
+
+	for _, tc := range table {
+		selector, err := newFieldSelector(&tc.selector)
+		if err != nil {
+			t.Errorf("%v: newFieldSelector() = %v", tc.name, err)
+			continue
+		}
+		if got := selector.Matches(fieldSet{obj: obj}); got != tc.want {
+			t.Errorf("%v: Matches() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNewFieldSelector_invalid(t *testing.T) {
+	table := []struct {
+		name     string
+		selector v1alpha1.FieldSelector
+	}{
+		{name: "empty path", selector: v1alpha1.FieldSelector{MatchFields: map[string]string{"": "a"}}},
+		{name: "empty path segment", selector: v1alpha1.FieldSelector{MatchFields: map[string]string{"spec..type": "a"}}},
+		{name: "in without values", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "spec.type", Operator: metav1.LabelSelectorOpIn}}}},
+		{name: "exists with values", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "spec.type", Operator: metav1.LabelSelectorOpExists, Values: []string{"a"}}}}},
+		{name: "unknown operator", selector: v1alpha1.FieldSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "spec.type", Operator: "Gt", Values: []string{"1"}}}}},
+	}
+	for _, tc := range table {
+		if _, err := newFieldSelector(&tc.selector); err == nil {
+			t.Errorf("%v: newFieldSelector() succeeded, want error", tc.name)
+		}
+	}
+}
+
+func TestDecoratorSelectorMatch(t *testing.T) {
+	resources := dynamicdiscovery.NewResourceMap(&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{
+		Resources: []*metav1.APIResourceList{{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "services", Namespaced: true, Kind: "Service", Verbs: metav1.Verbs{"get", "list", "watch"}}},
+		}},
+	}})
+	resources.Start(time.Hour)
+	defer resources.Stop()
+	for !resources.HasSynced() {
+		time.Sleep(time.Millisecond)
+	}
+	service := func(spec map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   map[string]interface{}{"name": "test", "namespace": "default", "labels": map[string]interface{}{"app": "web"}},
+			"spec":       spec,
+		}}
+	}
+
+	dc := &v1alpha1.DecoratorController{Spec: v1alpha1.DecoratorControllerSpec{
+		Resources: []v1alpha1.DecoratorControllerResourceRule{{
+			ResourceRule:  v1alpha1.ResourceRule{APIVersion: "v1", Resource: "services"},
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Match:         "object.spec.type == 'LoadBalancer' && !has(object.spec.loadBalancerClass)",
+		}},
+	}}
+	ds, err := newDecoratorSelector(resources, dc)
+	if err != nil {
+		t.Fatalf("newDecoratorSelector() = %v", err)
+	}
+
+	table := []struct {
+		name string
+		obj  *unstructured.Unstructured
+		want bool
+	}{
+		{name: "load balancer", obj: service(map[string]interface{}{"type": "LoadBalancer"}), want: true},
+		{name: "load balancer with class", obj: service(map[string]interface{}{"type": "LoadBalancer", "loadBalancerClass": "internal"}), want: false},
+		{name: "cluster IP", obj: service(map[string]interface{}{"type": "ClusterIP"}), want: false},
+		// Evaluation errors don't select the object.
+		{name: "no type", obj: service(map[string]interface{}{}), want: false},
+	}
+	for _, tc := range table {
+		if got := ds.Matches(tc.obj); got != tc.want {
+			t.Errorf("%v: Matches() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	dc.Spec.Resources[0].Match = "object.spec.type =="
+	if _, err := newDecoratorSelector(resources, dc); err == nil {
+		t.Errorf("newDecoratorSelector() succeeded with an invalid match, want error")
+	}
+}
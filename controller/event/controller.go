@@ -0,0 +1,283 @@
+package event
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	"metacontroller.io/events"
+	"metacontroller.io/hooks"
+	"metacontroller.io/leader"
+)
+
+// eventController calls a hook for every core Event that matches a selector.
+//
+// Events are far too numerous to keep in a shared informer cache, so instead
+// we watch them directly, filtered on the server side as much as possible,
+// and only hold on to the ones that haven't been delivered yet.
+type eventController struct {
+	ec *v1alpha1.EventController
+
+	client        *dynamicclientset.ResourceClient
+	fieldSelector string
+	reasons       map[string]bool
+
+	stopCh, doneCh chan struct{}
+	queue          workqueue.RateLimitingInterface
+
+	// pending holds the latest state of each Event that hasn't been delivered yet.
+	pendingMutex sync.Mutex
+	pending      map[string]*unstructured.Unstructured
+
+	numWorkers    int
+	eventRecorder record.EventRecorder
+}
+
+func newEventController(dynClient *dynamicclientset.Clientset, ec *v1alpha1.EventController, numWorkers int, eventRecorder record.EventRecorder) (*eventController, error) {
+	client, err := dynClient.Resource("v1", "events")
+	if err != nil {
+		return nil, fmt.Errorf("can't create client for events: %v", err)
+	}
+	if ec.Spec.Hooks != nil {
+		if err := hooks.ValidateHook(ec.Spec.Hooks.Notify); err != nil {
+			return nil, fmt.Errorf("invalid notify hook: %v", err)
+		}
+	}
+	if err := hooks.ValidateParameters(ec.GetHookParameters()); err != nil {
+		return nil, err
+	}
+
+	c := &eventController{
+		ec:            ec,
+		client:        client.Namespace(ec.Spec.Selector.Namespace),
+		fieldSelector: eventFieldSelector(ec.Spec.Selector),
+
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "EventController-"+ec.Name),
+		pending:       make(map[string]*unstructured.Unstructured),
+		numWorkers:    numWorkers,
+		eventRecorder: eventRecorder,
+	}
+	if len(ec.Spec.Selector.Reasons) > 1 {
+		// Field selectors can only match a single reason,
+		// so we have to filter on the client side.
+		c.reasons = make(map[string]bool, len(ec.Spec.Selector.Reasons))
+		for _, reason := range ec.Spec.Selector.Reasons {
+			c.reasons[reason] = true
+		}
+	}
+	return c, nil
+}
+
+// eventFieldSelector returns the server-side field selector for Events.
+// Multiple reasons can't be expressed as a field selector, so they're left
+// for the client side.
+func eventFieldSelector(selector v1alpha1.EventSelector) string {
+	var terms []fields.Selector
+	if selector.Type != "" {
+		terms = append(terms, fields.OneTermEqualSelector("type", selector.Type))
+	}
+	if len(selector.Reasons) == 1 {
+		terms = append(terms, fields.OneTermEqualSelector("reason", selector.Reasons[0]))
+	}
+	if io := selector.InvolvedObject; io != nil {
+		if io.APIVersion != "" {
+			terms = append(terms, fields.OneTermEqualSelector("involvedObject.apiVersion", io.APIVersion))
+		}
+		if io.Kind != "" {
+			terms = append(terms, fields.OneTermEqualSelector("involvedObject.kind", io.Kind))
+		}
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	return fields.AndSelectors(terms...).String()
+}
+
+func (c *eventController) Start() {
+	c.stopCh = make(chan struct{})
+	c.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(c.doneCh)
+		defer utilruntime.HandleCrash()
+
+		klog.InfoS("Starting EventController", "controller", klog.KObj(c.ec))
+		c.eventRecorder.Eventf(c.ec, v1.EventTypeNormal, events.ReasonStarting, "Starting controller: %s on replica %s", c.ec.Name, leader.Identity())
+		defer klog.InfoS("Shutting down EventController", "controller", klog.KObj(c.ec))
+		defer c.eventRecorder.Eventf(c.ec, v1.EventTypeNormal, events.ReasonStopping, "Stopping controller: %s on replica %s", c.ec.Name, leader.Identity())
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait.Until(c.watchEvents, time.Second, c.stopCh)
+		}()
+		for i := 0; i < c.numWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wait.Until(c.worker, time.Second, c.stopCh)
+			}()
+		}
+		wg.Wait()
+	}()
+}
+
+func (c *eventController) Stop() {
+	close(c.stopCh)
+	c.queue.ShutDown()
+	<-c.doneCh
+}
+
+// watchEvents watches matching Events until the watch fails or we're stopped.
+func (c *eventController) watchEvents() {
+	// Start from the current resourceVersion, so we don't replay old Events
+	// every time we start or have to restart the watch.
+	list, err := c.client.List(metav1.ListOptions{FieldSelector: c.fieldSelector, Limit: 1})
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("can't list events for %v: %v", c.ec.Name, err))
+		return
+	}
+	resourceVersion := list.GetResourceVersion()
+
+	for {
+		w, err := c.client.Watch(metav1.ListOptions{
+			FieldSelector:       c.fieldSelector,
+			ResourceVersion:     resourceVersion,
+			AllowWatchBookmarks: true,
+		})
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("can't watch events for %v: %v", c.ec.Name, err))
+			return
+		}
+		resourceVersion, err = c.consumeEvents(w, resourceVersion)
+		w.Stop()
+		if err != nil {
+			// Most likely our resourceVersion is too old.
+			// Start over from a fresh list.
+			klog.V(4).InfoS("EventController watch failed", "controller", klog.KObj(c.ec), "err", err)
+			return
+		}
+		select {
+		case <-c.stopCh:
+			return
+		default:
+		}
+	}
+}
+
+// consumeEvents handles everything sent on the watch until it's closed,
+// and returns the last resourceVersion seen.
+func (c *eventController) consumeEvents(w watch.Interface, resourceVersion string) (string, error) {
+	for {
+		select {
+		case <-c.stopCh:
+			return resourceVersion, nil
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				// The server closed the watch. Resume where we left off.
+				return resourceVersion, nil
+			}
+			if e.Type == watch.Error {
+				return resourceVersion, apierrors.FromObject(e.Object)
+			}
+			obj, ok := e.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			resourceVersion = obj.GetResourceVersion()
+			// Events are updated in place when they recur, so each update
+			// is a new occurrence. We don't care about deletions.
+			if e.Type == watch.Added || e.Type == watch.Modified {
+				c.enqueueEvent(obj)
+			}
+		}
+	}
+}
+
+func (c *eventController) enqueueEvent(obj *unstructured.Unstructured) {
+	if c.reasons != nil {
+		reason, _, _ := unstructured.NestedString(obj.Object, "reason")
+		if !c.reasons[reason] {
+			return
+		}
+	}
+	key, err := common.KeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+
+	c.pendingMutex.Lock()
+	c.pending[key] = obj
+	c.pendingMutex.Unlock()
+
+	c.queue.Add(key)
+}
+
+func (c *eventController) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *eventController) processNextWorkItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	release := common.AcquireSyncSlot("EventController", c.ec.Name)
+	err := c.sync(key.(string))
+	release()
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync %v %q: %v", c.ec.Name, key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *eventController) sync(key string) error {
+	c.pendingMutex.Lock()
+	obj := c.pending[key]
+	c.pendingMutex.Unlock()
+	if obj == nil {
+		// Already delivered.
+		return nil
+	}
+
+	klog.V(4).InfoS("EventController notify", "controller", klog.KObj(c.ec), "event", klog.KObj(obj))
+	request := &NotifyHookRequest{
+		Controller: c.ec,
+		Event:      obj,
+	}
+	if err := callNotifyHook(c.ec, request); err != nil {
+		return fmt.Errorf("can't notify Event %v: %v", key, err)
+	}
+
+	// Forget the Event, unless it was updated again while we were busy.
+	c.pendingMutex.Lock()
+	if c.pending[key] == obj {
+		delete(c.pending, key)
+	}
+	c.pendingMutex.Unlock()
+	return nil
+}
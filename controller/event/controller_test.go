@@ -0,0 +1,51 @@
+package event
+
+import (
+	"testing"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestEventFieldSelector(t *testing.T) {
+	table := []struct {
+		name     string
+		selector v1alpha1.EventSelector
+		want     string
+	}{
+		{
+			name: "empty",
+			want: "",
+		},
+		{
+			name: "single reason",
+			selector: v1alpha1.EventSelector{
+				Type:    "Warning",
+				Reasons: []string{"OOMKilling"},
+			},
+			want: "type=Warning,reason=OOMKilling",
+		},
+		{
+			name: "multiple reasons are filtered on the client side",
+			selector: v1alpha1.EventSelector{
+				Reasons: []string{"OOMKilling", "FailedScheduling"},
+			},
+			want: "",
+		},
+		{
+			name: "involved object",
+			selector: v1alpha1.EventSelector{
+				InvolvedObject: &v1alpha1.EventInvolvedObjectSelector{
+					APIVersion: "v1",
+					Kind:       "Pod",
+				},
+			},
+			want: "involvedObject.apiVersion=v1,involvedObject.kind=Pod",
+		},
+	}
+
+	for _, tc := range table {
+		if got := eventFieldSelector(tc.selector); got != tc.want {
+			t.Errorf("%v: eventFieldSelector() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
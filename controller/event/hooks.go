@@ -0,0 +1,43 @@
+package event
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/hooks"
+)
+
+// NotifyHookRequest is the object sent as JSON to the notify hook.
+type NotifyHookRequest struct {
+	Controller *v1alpha1.EventController  `json:"controller"`
+	Event      *unstructured.Unstructured `json:"event"`
+
+	// Parameters are the hook parameters of the controller, if it has any.
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// NotifyHookResponse is the expected format of the JSON response from the notify hook.
+// The notify hook doesn't need to return anything, but it must succeed for
+// the Event to be considered delivered.
+type NotifyHookResponse struct {
+}
+
+func callNotifyHook(ec *v1alpha1.EventController, request *NotifyHookRequest) error {
+	if ec.Spec.Hooks == nil || ec.Spec.Hooks.Notify == nil {
+		return fmt.Errorf("notify hook not defined")
+	}
+
+	parameters, err := hooks.ResolveParameters(ec.GetHookParameters())
+	if err != nil {
+		return err
+	}
+	request.Parameters = parameters
+
+	var response NotifyHookResponse
+	if err := hooks.Call(ec.Spec.Hooks.Notify, request, &response); err != nil {
+		return fmt.Errorf("notify hook failed: %v", err)
+	}
+	return nil
+}
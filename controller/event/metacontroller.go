@@ -0,0 +1,182 @@
+package event
+
+import (
+	"fmt"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"metacontroller.io/events"
+
+	"k8s.io/klog/v2"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	mcinformers "metacontroller.io/client/generated/informer/externalversions"
+	mclisters "metacontroller.io/client/generated/lister/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+)
+
+type Metacontroller struct {
+	dynClient *dynamicclientset.Clientset
+
+	ecLister   mclisters.EventControllerLister
+	ecInformer cache.SharedIndexInformer
+
+	queue            workqueue.RateLimitingInterface
+	eventControllers map[string]*eventController
+
+	stopCh, doneCh chan struct{}
+
+	numWorkers    int
+	eventRecorder record.EventRecorder
+}
+
+func NewMetacontroller(dynClient *dynamicclientset.Clientset, mcInformerFactory mcinformers.SharedInformerFactory, numWorkers int, recorder record.EventRecorder) *Metacontroller {
+	mc := &Metacontroller{
+		dynClient: dynClient,
+
+		ecLister:   mcInformerFactory.Metacontroller().V1alpha1().EventControllers().Lister(),
+		ecInformer: mcInformerFactory.Metacontroller().V1alpha1().EventControllers().Informer(),
+
+		queue:            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "EventController"),
+		eventControllers: make(map[string]*eventController),
+
+		numWorkers:    numWorkers,
+		eventRecorder: recorder,
+	}
+
+	mc.ecInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    mc.enqueueEventController,
+		UpdateFunc: mc.updateEventController,
+		DeleteFunc: mc.enqueueEventController,
+	})
+
+	return mc
+}
+
+func (mc *Metacontroller) Start() {
+	mc.stopCh = make(chan struct{})
+	mc.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(mc.doneCh)
+		defer utilruntime.HandleCrash()
+
+		klog.InfoS("Starting EventController metacontroller")
+		defer klog.InfoS("Shutting down EventController metacontroller")
+
+		if !cache.WaitForNamedCacheSync("EventController", mc.stopCh, mc.ecInformer.HasSynced) {
+			return
+		}
+
+		// In the metacontroller, we are only responsible for starting/stopping
+		// the actual controllers, so a single worker should be enough.
+		for mc.processNextWorkItem() {
+		}
+	}()
+}
+
+func (mc *Metacontroller) Stop() {
+	// Stop metacontroller first so there's no more changes to controllers.
+	close(mc.stopCh)
+	mc.queue.ShutDown()
+	<-mc.doneCh
+
+	// Stop all controllers.
+	var wg sync.WaitGroup
+	for _, c := range mc.eventControllers {
+		wg.Add(1)
+		go func(c *eventController) {
+			defer wg.Done()
+			c.Stop()
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (mc *Metacontroller) processNextWorkItem() bool {
+	key, quit := mc.queue.Get()
+	if quit {
+		return false
+	}
+	defer mc.queue.Done(key)
+
+	err := mc.sync(key.(string))
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync EventController %q: %v", key, err))
+		mc.queue.AddRateLimited(key)
+		return true
+	}
+
+	mc.queue.Forget(key)
+	return true
+}
+
+func (mc *Metacontroller) sync(key string) error {
+	_, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	klog.V(4).InfoS("Sync EventController", "name", name)
+
+	ec, err := mc.ecLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).InfoS("EventController has been deleted", "name", name)
+		// Stop and remove the controller if it exists.
+		if c, ok := mc.eventControllers[name]; ok {
+			c.Stop()
+			defer c.eventRecorder.Eventf(c.ec, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", c.ec.Name)
+			delete(mc.eventControllers, name)
+		}
+		return nil
+	}
+	if err != nil {
+		mc.eventRecorder.Eventf(ec, v1.EventTypeNormal, events.ReasonSyncError, "[%s] sync error - %s", ec.Name, err)
+		return err
+	}
+	return mc.syncEventController(ec)
+}
+
+func (mc *Metacontroller) syncEventController(ec *v1alpha1.EventController) error {
+	if c, ok := mc.eventControllers[ec.Name]; ok {
+		// The controller was already started.
+		if apiequality.Semantic.DeepEqual(ec.Spec, c.ec.Spec) {
+			// Nothing has changed.
+			return nil
+		}
+		// Stop and remove the controller so it can be recreated.
+		c.Stop()
+		mc.eventRecorder.Eventf(ec, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", ec.Name)
+		delete(mc.eventControllers, ec.Name)
+	}
+
+	c, err := newEventController(mc.dynClient, ec, mc.numWorkers, mc.eventRecorder)
+	if err != nil {
+		return err
+	}
+	c.Start()
+	mc.eventRecorder.Eventf(ec, v1.EventTypeNormal, events.ReasonStarted, "Started controller: %s", ec.Name)
+	mc.eventControllers[ec.Name] = c
+	return nil
+}
+
+func (mc *Metacontroller) enqueueEventController(obj interface{}) {
+	key, err := common.KeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	mc.queue.Add(key)
+}
+
+func (mc *Metacontroller) updateEventController(old, cur interface{}) {
+	mc.enqueueEventController(cur)
+}
@@ -0,0 +1,374 @@
+package status
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/controller/common/customize"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+	dynamicinformer "metacontroller.io/dynamic/informer"
+	"metacontroller.io/events"
+	"metacontroller.io/leader"
+)
+
+// statusController computes the status of objects of a single resource.
+// Unlike a DecoratorController, it never manages any children, and it only
+// ever writes the status of the objects it watches.
+type statusController struct {
+	sc *v1alpha1.StatusController
+
+	resource       *dynamicdiscovery.APIResource
+	parentKinds    common.GroupKindMap
+	parentSelector *common.ObjectSelector
+	resyncSchedule *common.Schedule
+	// resyncRequest is the last value seen of the resync request annotation.
+	resyncRequest string
+
+	dynClient      *dynamicclientset.Clientset
+	parentClient   *dynamicclientset.ResourceClient
+	parentInformer *dynamicinformer.ResourceInformer
+
+	stopCh, doneCh chan struct{}
+	queue          workqueue.RateLimitingInterface
+	syncRetries    *common.SyncRetries
+	objectCounts   *common.ObjectCounts
+
+	numWorkers    int
+	eventRecorder record.EventRecorder
+
+	customize customize.Manager
+}
+
+func newStatusController(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, sc *v1alpha1.StatusController, numWorkers int, eventRecorder record.EventRecorder) (*statusController, error) {
+	rule := sc.Spec.Resource
+	resource := resources.Get(rule.APIVersion, rule.Resource)
+	if resource == nil {
+		return nil, fmt.Errorf("can't find resource %q in apiVersion %q", rule.Resource, rule.APIVersion)
+	}
+	parentClient, err := dynClient.Resource(rule.APIVersion, rule.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("can't create client for resource: %v", err)
+	}
+	parentSelector, err := common.NewObjectSelector(rule.LabelSelector, rule.AnnotationSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector for resource %q in apiVersion %q: %v", rule.Resource, rule.APIVersion, err)
+	}
+	groupVersion, err := schema.ParseGroupVersion(rule.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse resource groupVersion: %v", err)
+	}
+	if err := validateHooks(sc); err != nil {
+		return nil, err
+	}
+	var resyncSchedule *common.Schedule
+	if sc.Spec.ResyncSchedule != "" {
+		resyncSchedule, err = common.ParseSchedule(sc.Spec.ResyncSchedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resyncSchedule: %v", err)
+		}
+	}
+
+	c := &statusController{
+		sc:             sc,
+		resource:       resource,
+		parentKinds:    make(common.GroupKindMap),
+		parentSelector: parentSelector,
+		resyncSchedule: resyncSchedule,
+		resyncRequest:  sc.Annotations[common.ResyncRequestAnnotation],
+		dynClient:      dynClient,
+		parentClient:   parentClient,
+
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "StatusController-"+sc.Name),
+		syncRetries:   common.NewSyncRetries("StatusController", sc.Name),
+		objectCounts:  common.NewObjectCounts("StatusController", sc.Name),
+		numWorkers:    numWorkers,
+		eventRecorder: eventRecorder,
+	}
+	c.parentKinds.Set(schema.GroupKind{Group: resource.Group, Kind: resource.Kind}, resource)
+
+	c.parentInformer, err = dynInformers.Resource(rule.APIVersion, rule.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("can't create informer for resource: %v", err)
+	}
+	parentInformers := make(common.InformerMap)
+	parentInformers.Set(groupVersion.WithResource(rule.Resource), c.parentInformer)
+
+	c.customize = customize.NewCustomizeManager(
+		sc.Name,
+		c.enqueueParentObject,
+		sc,
+		dynClient,
+		dynInformers,
+		parentInformers,
+		c.parentKinds,
+	)
+
+	return c, nil
+}
+
+func (c *statusController) Start() {
+	c.stopCh = make(chan struct{})
+	c.doneCh = make(chan struct{})
+
+	c.customize.Start(c.stopCh)
+
+	// Install event handlers. StatusControllers can be created at any time,
+	// so we have to assume the shared informers are already running.
+	handlers := cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueParentObject,
+		UpdateFunc: c.updateParentObject,
+	}
+	if c.sc.Spec.ResyncPeriodSeconds != nil {
+		// Use a custom resync period if requested.
+		resyncPeriod := time.Duration(*c.sc.Spec.ResyncPeriodSeconds) * time.Second
+		// Put a reasonable limit on it.
+		if resyncPeriod < time.Second {
+			resyncPeriod = time.Second
+		}
+		c.parentInformer.Informer().AddEventHandlerWithResyncPeriod(handlers, resyncPeriod)
+	} else {
+		c.parentInformer.Informer().AddEventHandler(handlers)
+	}
+
+	go func() {
+		defer close(c.doneCh)
+		defer utilruntime.HandleCrash()
+
+		klog.InfoS("Starting StatusController", "controller", klog.KObj(c.sc))
+		c.eventRecorder.Eventf(c.sc, v1.EventTypeNormal, events.ReasonStarting, "Starting controller: %s on replica %s", c.sc.Name, leader.Identity())
+		defer klog.InfoS("Shutting down StatusController", "controller", klog.KObj(c.sc))
+		defer c.eventRecorder.Eventf(c.sc, v1.EventTypeNormal, events.ReasonStopping, "Stopping controller: %s on replica %s", c.sc.Name, leader.Identity())
+
+		// Wait for the informer to sync.
+		klog.InfoS("Waiting for StatusController caches to sync", "controller", klog.KObj(c.sc))
+		if !cache.WaitForNamedCacheSync(c.sc.Name, c.stopCh, c.parentInformer.Informer().HasSynced) {
+			// We wait forever unless Stop() is called, so this isn't an error.
+			klog.InfoS("StatusController cache sync never finished", "controller", klog.KObj(c.sc))
+			return
+		}
+
+		var wg sync.WaitGroup
+		if c.resyncSchedule != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				common.RunSchedule(c.resyncSchedule, c.stopCh, c.resyncAll)
+			}()
+		}
+		for i := 0; i < c.numWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wait.Until(c.worker, time.Second, c.stopCh)
+			}()
+		}
+		wg.Wait()
+	}()
+}
+
+func (c *statusController) Stop() {
+	close(c.stopCh)
+	c.queue.ShutDown()
+	<-c.doneCh
+	c.syncRetries.Stop()
+	c.objectCounts.Stop()
+
+	// Remove event handlers and close the informer.
+	c.parentInformer.Informer().RemoveEventHandlers()
+	c.parentInformer.Close()
+}
+
+func (c *statusController) worker() {
+	common.SetProfileLabels("StatusController", c.sc.Name)
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *statusController) processNextWorkItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	release := common.AcquireSyncSlot("StatusController", c.sc.Name)
+	err := c.sync(key.(string))
+	release()
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync %v %q: %v", c.sc.Name, key, err))
+		failures, delay := c.syncRetries.Failed(key.(string))
+		c.queue.AddAfter(key, delay)
+		if parent := c.cachedParent(key.(string)); parent != nil {
+			common.ReportSyncFailure(c.eventRecorder, c.parentClient, parent, failures, delay, err, false)
+			c.syncRetries.Notify(parent, failures, err)
+		}
+		return true
+	}
+
+	c.syncRetries.Succeeded(key.(string))
+	c.queue.Forget(key)
+	return true
+}
+
+// cachedParent returns the object with the given queue key from the cache,
+// or nil if it's gone.
+func (c *statusController) cachedParent(key string) *unstructured.Unstructured {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil
+	}
+	parent, err := common.GetObject(c.parentInformer, namespace, name)
+	if err != nil {
+		return nil
+	}
+	return parent
+}
+
+func (c *statusController) enqueueParentObject(obj interface{}) {
+	// If the object doesn't match our selector, we don't care about it.
+	if parent, ok := obj.(*unstructured.Unstructured); ok && !c.parentSelector.Matches(parent) {
+		return
+	}
+
+	key, err := common.KeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	c.queue.Add(key)
+}
+
+// resyncAll enqueues every matching object, for scheduled resyncs.
+func (c *statusController) resyncAll() {
+	objects, err := c.parentInformer.Lister().List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("can't list %v for scheduled resync: %v", c.resource.Kind, err))
+		return
+	}
+	klog.V(4).InfoS("Scheduled resync", "controller", klog.KObj(c.sc), "kind", c.resource.Kind, "count", len(objects))
+	for _, obj := range objects {
+		c.enqueueParentObject(obj)
+	}
+}
+
+func (c *statusController) enqueueParentObjectAfter(obj interface{}, delay time.Duration) {
+	key, err := common.KeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	c.queue.AddAfter(key, delay)
+}
+
+func (c *statusController) updateParentObject(old, cur interface{}) {
+	// We also resync on our own status updates, but that's cheap since we
+	// only write status when the hook asks for something different.
+	c.enqueueParentObject(cur)
+}
+
+func (c *statusController) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	parent, err := common.GetObject(c.parentInformer, namespace, name)
+	if apierrors.IsNotFound(err) {
+		// Swallow the error since there's no point retrying if the object is gone.
+		klog.V(4).InfoS("Object has been deleted", "kind", c.resource.Kind, "object", klog.KRef(namespace, name))
+		c.objectCounts.Forget(key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.syncParentObject(parent)
+}
+
+func (c *statusController) syncParentObject(parent *unstructured.Unstructured) error {
+	// If it doesn't match our selector, or it's going away, ignore it.
+	key, err := common.KeyFunc(parent)
+	if err != nil {
+		return err
+	}
+	if !c.parentSelector.Matches(parent) || parent.GetDeletionTimestamp() != nil {
+		c.objectCounts.Forget(key)
+		return nil
+	}
+	// Status controllers have no children, so only their parents are counted.
+	c.objectCounts.Observe(key, nil)
+
+	klog.V(4).InfoS("StatusController sync", "controller", klog.KObj(c.sc), "parent_kind", parent.GetKind(), "parent", klog.KObj(parent))
+
+	relatedObjects, err := c.customize.GetRelatedObjects(parent)
+	if err != nil {
+		return err
+	}
+
+	syncRequest := &SyncHookRequest{
+		Controller: c.sc,
+		Object:     parent,
+		Related:    relatedObjects,
+	}
+	syncResult, err := callSyncHook(c.sc, syncRequest)
+	if err != nil {
+		return fmt.Errorf("sync hook failed for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+
+	// Enqueue a delayed resync, if requested.
+	if syncResult.ResyncAfterSeconds > 0 {
+		c.enqueueParentObjectAfter(parent, time.Duration(syncResult.ResyncAfterSeconds*float64(time.Second)))
+	}
+
+	// A null .status in the sync response means leave it unchanged.
+	if syncResult.Status == nil {
+		return nil
+	}
+
+	// If the parent was changed since we read it, try again on a fresh copy.
+	parentClient := c.parentClient.Namespace(parent.GetNamespace())
+	err = parentClient.RetryOnConflict(parent, func(current *unstructured.Unstructured) error {
+		parentStatus, _, err := unstructured.NestedMap(current.Object, "status")
+		if err != nil {
+			return err
+		}
+		if reflect.DeepEqual(parentStatus, syncResult.Status) {
+			return nil
+		}
+
+		// Make a copy since current may be from the cache.
+		updatedParent := current.DeepCopy()
+		if err := unstructured.SetNestedField(updatedParent.Object, syncResult.Status, "status"); err != nil {
+			return err
+		}
+		klog.V(4).InfoS("StatusController updating status", "controller", klog.KObj(c.sc), "parent_kind", parent.GetKind(), "parent", klog.KObj(parent))
+		if parentClient.HasSubresource("status") {
+			_, err = parentClient.UpdateStatus(updatedParent, metav1.UpdateOptions{})
+		} else {
+			_, err = parentClient.Update(updatedParent, metav1.UpdateOptions{})
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("can't update status for %v %v/%v: %v", parent.GetKind(), parent.GetNamespace(), parent.GetName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package status
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/util/workqueue"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/controller/common/customize"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+// statusWrite is a write the fake API server received.
+type statusWrite struct {
+	path   string
+	status interface{}
+}
+
+// newTestStatusController returns a statusController for things.example.com
+// whose sync hook responds with hookResponse (or fails if it's nil), and
+// whose API server records writes. The resource has a status subresource if
+// statusSubresource is true.
+func newTestStatusController(t *testing.T, hookResponse *SyncHookResponse, statusSubresource bool) (*statusController, func() []statusWrite, func() int) {
+	var mutex sync.Mutex
+	var writes []statusWrite
+	hookCalls := 0
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		writes = append(writes, statusWrite{path: r.Method + " " + r.URL.Path, status: obj.Object["status"]})
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(apiServer.Close)
+	hookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		hookCalls++
+		mutex.Unlock()
+		if hookResponse == nil {
+			http.Error(w, "hook failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hookResponse)
+	}))
+	t.Cleanup(hookServer.Close)
+
+	apiResources := []metav1.APIResource{{Name: "things", Namespaced: true, Kind: "Thing"}}
+	if statusSubresource {
+		apiResources = append(apiResources, metav1.APIResource{Name: "things/status", Namespaced: true, Kind: "Thing"})
+	}
+	resources := dynamicdiscovery.NewResourceMap(&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{
+		Resources: []*metav1.APIResourceList{{GroupVersion: "example.com/v1", APIResources: apiResources}},
+	}})
+	resources.Start(time.Hour)
+	t.Cleanup(resources.Stop)
+	for !resources.HasSynced() {
+		time.Sleep(time.Millisecond)
+	}
+	dynClient, err := dynamicclientset.New(&rest.Config{Host: apiServer.URL}, resources)
+	if err != nil {
+		t.Fatalf("Can't create dynamic clientset: %v", err)
+	}
+	parentClient, err := dynClient.Resource("example.com/v1", "things")
+	if err != nil {
+		t.Fatalf("Can't create client for things: %v", err)
+	}
+	parentSelector, err := common.NewObjectSelector(nil, nil)
+	if err != nil {
+		t.Fatalf("NewObjectSelector() error: %v", err)
+	}
+
+	hookURL := hookServer.URL
+	sc := &v1alpha1.StatusController{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: v1alpha1.StatusControllerSpec{
+			Hooks: &v1alpha1.StatusControllerHooks{
+				Sync: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{URL: &hookURL}},
+			},
+		},
+	}
+	c := &statusController{
+		sc:             sc,
+		resource:       parentClient.APIResource,
+		parentKinds:    make(common.GroupKindMap),
+		parentSelector: parentSelector,
+		dynClient:      dynClient,
+		parentClient:   parentClient,
+		queue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "StatusController-test"),
+		objectCounts:   common.NewObjectCounts("StatusController", "test"),
+	}
+	t.Cleanup(c.queue.ShutDown)
+	t.Cleanup(c.objectCounts.Stop)
+	c.parentKinds.Set(schema.GroupKind{Group: "example.com", Kind: "Thing"}, parentClient.APIResource)
+	c.customize = customize.NewCustomizeManager(sc.Name, c.enqueueParentObject, sc, dynClient, nil, nil, c.parentKinds)
+
+	getWrites := func() []statusWrite {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return writes
+	}
+	getHookCalls := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return hookCalls
+	}
+	return c, getWrites, getHookCalls
+}
+
+func TestSyncParentObject(t *testing.T) {
+	newParent := func(status map[string]interface{}, deleting bool) *unstructured.Unstructured {
+		parent := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		parent.SetAPIVersion("example.com/v1")
+		parent.SetKind("Thing")
+		parent.SetNamespace("default")
+		parent.SetName("test")
+		if status != nil {
+			parent.Object["status"] = status
+		}
+		if deleting {
+			now := metav1.Now()
+			parent.SetDeletionTimestamp(&now)
+		}
+		return parent
+	}
+	ready := map[string]interface{}{"phase": "Ready"}
+	pending := map[string]interface{}{"phase": "Pending"}
+
+	table := []struct {
+		name              string
+		parent            *unstructured.Unstructured
+		hookResponse      *SyncHookResponse
+		statusSubresource bool
+		wantHookCalls     int
+		wantWrites        []statusWrite
+		wantErr           bool
+	}{
+		{
+			name:              "status changed",
+			parent:            newParent(pending, false),
+			hookResponse:      &SyncHookResponse{Status: ready},
+			statusSubresource: true,
+			wantHookCalls:     1,
+			wantWrites: []statusWrite{
+				{path: "PUT /apis/example.com/v1/namespaces/default/things/test/status", status: ready},
+			},
+		},
+		{
+			name:          "status changed without status subresource",
+			parent:        newParent(pending, false),
+			hookResponse:  &SyncHookResponse{Status: ready},
+			wantHookCalls: 1,
+			wantWrites: []statusWrite{
+				{path: "PUT /apis/example.com/v1/namespaces/default/things/test", status: ready},
+			},
+		},
+		{
+			name:              "status unchanged",
+			parent:            newParent(ready, false),
+			hookResponse:      &SyncHookResponse{Status: ready},
+			statusSubresource: true,
+			wantHookCalls:     1,
+		},
+		{
+			name:              "null status leaves it unchanged",
+			parent:            newParent(pending, false),
+			hookResponse:      &SyncHookResponse{},
+			statusSubresource: true,
+			wantHookCalls:     1,
+		},
+		{
+			name:              "hook fails",
+			parent:            newParent(pending, false),
+			statusSubresource: true,
+			wantHookCalls:     1,
+			wantErr:           true,
+		},
+		{
+			name:              "object pending deletion",
+			parent:            newParent(pending, true),
+			hookResponse:      &SyncHookResponse{Status: ready},
+			statusSubresource: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			c, getWrites, getHookCalls := newTestStatusController(t, tc.hookResponse, tc.statusSubresource)
+			err := c.syncParentObject(tc.parent)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("syncParentObject() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got := getHookCalls(); got != tc.wantHookCalls {
+				t.Errorf("sync hook calls = %v, want %v", got, tc.wantHookCalls)
+			}
+			if got := getWrites(); !reflect.DeepEqual(got, tc.wantWrites) {
+				t.Errorf("writes = %v, want %v", got, tc.wantWrites)
+			}
+		})
+	}
+}
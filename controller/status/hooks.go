@@ -0,0 +1,72 @@
+package status
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/hooks"
+)
+
+// SyncHookRequest is the object sent as JSON to the sync hook.
+type SyncHookRequest struct {
+	Controller *v1alpha1.StatusController `json:"controller"`
+	Object     *unstructured.Unstructured `json:"object"`
+	Related    common.ChildMap            `json:"related"`
+
+	// Parameters are the hook parameters of the controller, if it has any.
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// SyncHookResponse is the expected format of the JSON response from the sync hook.
+type SyncHookResponse struct {
+	Status map[string]interface{} `json:"status"`
+
+	ResyncAfterSeconds float64 `json:"resyncAfterSeconds"`
+}
+
+func callSyncHook(sc *v1alpha1.StatusController, request *SyncHookRequest) (*SyncHookResponse, error) {
+	if sc.Spec.Hooks == nil || sc.Spec.Hooks.Sync == nil {
+		return nil, fmt.Errorf("sync hook not defined")
+	}
+
+	parameters, err := hooks.ResolveParameters(sc.GetHookParameters())
+	if err != nil {
+		return nil, err
+	}
+	request.Parameters = parameters
+
+	var response SyncHookResponse
+	if err := common.CallWithProfileLabels("StatusController", sc.Name, "sync", func() error {
+		return hooks.Call(sc.Spec.Hooks.Sync, request, &response)
+	}); err != nil {
+		return nil, fmt.Errorf("sync hook failed: %v", err)
+	}
+	return &response, nil
+}
+
+// validateHooks checks the hooks of sc when the controller is created.
+func validateHooks(sc *v1alpha1.StatusController) error {
+	if err := hooks.ValidateParameters(sc.GetHookParameters()); err != nil {
+		return err
+	}
+	spec := sc.Spec.Hooks
+	if spec == nil {
+		return nil
+	}
+	named := []struct {
+		name string
+		hook *v1alpha1.Hook
+	}{
+		{"customize", spec.Customize},
+		{"sync", spec.Sync},
+	}
+	for _, h := range named {
+		if err := hooks.ValidateHook(h.hook); err != nil {
+			return fmt.Errorf("invalid %v hook: %v", h.name, err)
+		}
+	}
+	return nil
+}
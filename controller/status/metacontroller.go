@@ -0,0 +1,193 @@
+package status
+
+import (
+	"fmt"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"metacontroller.io/events"
+
+	"k8s.io/klog/v2"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	mcinformers "metacontroller.io/client/generated/informer/externalversions"
+	mclisters "metacontroller.io/client/generated/lister/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicclientset "metacontroller.io/dynamic/clientset"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+	dynamicinformer "metacontroller.io/dynamic/informer"
+)
+
+type Metacontroller struct {
+	resources    *dynamicdiscovery.ResourceMap
+	dynClient    *dynamicclientset.Clientset
+	dynInformers *dynamicinformer.SharedInformerFactory
+
+	scLister   mclisters.StatusControllerLister
+	scInformer cache.SharedIndexInformer
+
+	queue             workqueue.RateLimitingInterface
+	statusControllers map[string]*statusController
+
+	stopCh, doneCh chan struct{}
+
+	numWorkers    int
+	eventRecorder record.EventRecorder
+}
+
+func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynClient *dynamicclientset.Clientset, dynInformers *dynamicinformer.SharedInformerFactory, mcInformerFactory mcinformers.SharedInformerFactory, numWorkers int, recorder record.EventRecorder) *Metacontroller {
+	mc := &Metacontroller{
+		resources:    resources,
+		dynClient:    dynClient,
+		dynInformers: dynInformers,
+
+		scLister:   mcInformerFactory.Metacontroller().V1alpha1().StatusControllers().Lister(),
+		scInformer: mcInformerFactory.Metacontroller().V1alpha1().StatusControllers().Informer(),
+
+		queue:             workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "StatusController"),
+		statusControllers: make(map[string]*statusController),
+
+		numWorkers:    numWorkers,
+		eventRecorder: recorder,
+	}
+
+	mc.scInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    mc.enqueueStatusController,
+		UpdateFunc: mc.updateStatusController,
+		DeleteFunc: mc.enqueueStatusController,
+	})
+
+	return mc
+}
+
+func (mc *Metacontroller) Start() {
+	mc.stopCh = make(chan struct{})
+	mc.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(mc.doneCh)
+		defer utilruntime.HandleCrash()
+
+		klog.InfoS("Starting StatusController metacontroller")
+		defer klog.InfoS("Shutting down StatusController metacontroller")
+
+		if !cache.WaitForNamedCacheSync("StatusController", mc.stopCh, mc.scInformer.HasSynced) {
+			return
+		}
+
+		// In the metacontroller, we are only responsible for starting/stopping
+		// the actual controllers, so a single worker should be enough.
+		for mc.processNextWorkItem() {
+		}
+	}()
+}
+
+func (mc *Metacontroller) Stop() {
+	// Stop metacontroller first so there's no more changes to controllers.
+	close(mc.stopCh)
+	mc.queue.ShutDown()
+	<-mc.doneCh
+
+	// Stop all controllers.
+	var wg sync.WaitGroup
+	for _, c := range mc.statusControllers {
+		wg.Add(1)
+		go func(c *statusController) {
+			defer wg.Done()
+			c.Stop()
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (mc *Metacontroller) processNextWorkItem() bool {
+	key, quit := mc.queue.Get()
+	if quit {
+		return false
+	}
+	defer mc.queue.Done(key)
+
+	err := mc.sync(key.(string))
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync StatusController %q: %v", key, err))
+		mc.queue.AddRateLimited(key)
+		return true
+	}
+
+	mc.queue.Forget(key)
+	return true
+}
+
+func (mc *Metacontroller) sync(key string) error {
+	_, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	klog.V(4).InfoS("Sync StatusController", "name", name)
+
+	sc, err := mc.scLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).InfoS("StatusController has been deleted", "name", name)
+		// Stop and remove the controller if it exists.
+		if c, ok := mc.statusControllers[name]; ok {
+			c.Stop()
+			defer c.eventRecorder.Eventf(c.sc, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", c.sc.Name)
+			delete(mc.statusControllers, name)
+		}
+		return nil
+	}
+	if err != nil {
+		mc.eventRecorder.Eventf(sc, v1.EventTypeNormal, events.ReasonSyncError, "[%s] sync error - %s", sc.Name, err)
+		return err
+	}
+	return mc.syncStatusController(sc)
+}
+
+func (mc *Metacontroller) syncStatusController(sc *v1alpha1.StatusController) error {
+	if c, ok := mc.statusControllers[sc.Name]; ok {
+		// The controller was already started.
+		if apiequality.Semantic.DeepEqual(sc.Spec, c.sc.Spec) {
+			// Nothing has changed, unless a resync was requested.
+			if request, changed := common.ResyncRequested(sc, c.resyncRequest); changed {
+				klog.InfoS("Resync requested", "controller", klog.KObj(sc), "request", request)
+				c.resyncRequest = request
+				c.resyncAll()
+			}
+			return nil
+		}
+		// Stop and remove the controller so it can be recreated.
+		c.Stop()
+		mc.eventRecorder.Eventf(sc, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", sc.Name)
+		delete(mc.statusControllers, sc.Name)
+	}
+
+	c, err := newStatusController(mc.resources, mc.dynClient, mc.dynInformers, sc, mc.numWorkers, mc.eventRecorder)
+	if err != nil {
+		return err
+	}
+	c.Start()
+	mc.eventRecorder.Eventf(sc, v1.EventTypeNormal, events.ReasonStarted, "Started controller: %s", sc.Name)
+	mc.statusControllers[sc.Name] = c
+	return nil
+}
+
+func (mc *Metacontroller) enqueueStatusController(obj interface{}) {
+	key, err := common.KeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	mc.queue.Add(key)
+}
+
+func (mc *Metacontroller) updateStatusController(old, cur interface{}) {
+	mc.enqueueStatusController(cur)
+}
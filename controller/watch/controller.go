@@ -0,0 +1,310 @@
+package watch
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+	dynamicinformer "metacontroller.io/dynamic/informer"
+	"metacontroller.io/events"
+	"metacontroller.io/hooks"
+	"metacontroller.io/leader"
+)
+
+// watchController sends every change to objects of a single resource to
+// a hook. It never writes anything to the cluster.
+type watchController struct {
+	wc *v1alpha1.WatchController
+
+	resource *dynamicdiscovery.APIResource
+	selector *common.ObjectSelector
+	informer *dynamicinformer.ResourceInformer
+
+	stopCh, doneCh chan struct{}
+	queue          workqueue.RateLimitingInterface
+
+	// pending holds the changes that haven't been delivered yet, by object key.
+	pending *pendingEvents
+
+	numWorkers    int
+	eventRecorder record.EventRecorder
+}
+
+func newWatchController(resources *dynamicdiscovery.ResourceMap, dynInformers *dynamicinformer.SharedInformerFactory, wc *v1alpha1.WatchController, numWorkers int, eventRecorder record.EventRecorder) (*watchController, error) {
+	rule := wc.Spec.Resource
+	resource := resources.Get(rule.APIVersion, rule.Resource)
+	if resource == nil {
+		return nil, fmt.Errorf("can't find resource %q in apiVersion %q", rule.Resource, rule.APIVersion)
+	}
+	selector, err := common.NewObjectSelector(rule.LabelSelector, rule.AnnotationSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector for resource %q in apiVersion %q: %v", rule.Resource, rule.APIVersion, err)
+	}
+	if wc.Spec.Hooks != nil {
+		if err := hooks.ValidateHook(wc.Spec.Hooks.Notify); err != nil {
+			return nil, fmt.Errorf("invalid notify hook: %v", err)
+		}
+	}
+	if err := hooks.ValidateParameters(wc.GetHookParameters()); err != nil {
+		return nil, err
+	}
+	informer, err := dynInformers.Resource(rule.APIVersion, rule.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("can't create informer for resource: %v", err)
+	}
+
+	return &watchController{
+		wc:       wc,
+		resource: resource,
+		selector: selector,
+		informer: informer,
+
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "WatchController-"+wc.Name),
+		pending:       newPendingEvents(),
+		numWorkers:    numWorkers,
+		eventRecorder: eventRecorder,
+	}, nil
+}
+
+func (c *watchController) Start() {
+	c.stopCh = make(chan struct{})
+	c.doneCh = make(chan struct{})
+
+	// Install event handlers. WatchControllers can be created at any time,
+	// so we have to assume the shared informers are already running.
+	c.informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.onAdd,
+		UpdateFunc: c.onUpdate,
+		DeleteFunc: c.onDelete,
+	})
+
+	go func() {
+		defer close(c.doneCh)
+		defer utilruntime.HandleCrash()
+
+		klog.InfoS("Starting WatchController", "controller", klog.KObj(c.wc))
+		c.eventRecorder.Eventf(c.wc, v1.EventTypeNormal, events.ReasonStarting, "Starting controller: %s on replica %s", c.wc.Name, leader.Identity())
+		defer klog.InfoS("Shutting down WatchController", "controller", klog.KObj(c.wc))
+		defer c.eventRecorder.Eventf(c.wc, v1.EventTypeNormal, events.ReasonStopping, "Stopping controller: %s on replica %s", c.wc.Name, leader.Identity())
+
+		// Wait for the informer to sync.
+		klog.InfoS("Waiting for WatchController caches to sync", "controller", klog.KObj(c.wc))
+		if !cache.WaitForNamedCacheSync(c.wc.Name, c.stopCh, c.informer.Informer().HasSynced) {
+			// We wait forever unless Stop() is called, so this isn't an error.
+			klog.InfoS("WatchController cache sync never finished", "controller", klog.KObj(c.wc))
+			return
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < c.numWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wait.Until(c.worker, time.Second, c.stopCh)
+			}()
+		}
+		wg.Wait()
+	}()
+}
+
+func (c *watchController) Stop() {
+	close(c.stopCh)
+	c.queue.ShutDown()
+	<-c.doneCh
+
+	// Remove event handlers and close the informer.
+	c.informer.Informer().RemoveEventHandlers()
+	c.informer.Close()
+}
+
+func (c *watchController) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *watchController) processNextWorkItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	release := common.AcquireSyncSlot("WatchController", c.wc.Name)
+	err := c.sync(key.(string))
+	release()
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync %v %q: %v", c.wc.Name, key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *watchController) onAdd(obj interface{}) {
+	c.enqueue(EventAdded, obj)
+}
+
+func (c *watchController) onUpdate(old, cur interface{}) {
+	oldObj := old.(*unstructured.Unstructured)
+	curObj := cur.(*unstructured.Unstructured)
+
+	// Don't notify if it's a no-op update (probably a relist/resync).
+	if oldObj.GetResourceVersion() == curObj.GetResourceVersion() {
+		return
+	}
+	c.enqueue(EventUpdated, cur)
+}
+
+func (c *watchController) onDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	c.enqueue(EventDeleted, obj)
+}
+
+func (c *watchController) enqueue(eventType EventType, obj interface{}) {
+	object, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("expected *unstructured.Unstructured, got %T", obj))
+		return
+	}
+	// If the object doesn't match our selector, we don't care about it.
+	if !c.selector.Matches(object) {
+		return
+	}
+
+	key, err := common.KeyFunc(object)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	c.pending.Add(key, eventType, object)
+	c.queue.Add(key)
+}
+
+func (c *watchController) sync(key string) error {
+	event, ok := c.pending.Next(key)
+	if !ok {
+		// Everything for this key was already delivered.
+		return nil
+	}
+
+	object := event.object
+	if event.eventType != EventDeleted {
+		// Always send the latest state, since intermediate updates were coalesced.
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return err
+		}
+		object, err = common.GetObject(c.informer, namespace, name)
+		if apierrors.IsNotFound(err) {
+			// The object is gone, so a deletion is queued behind this change.
+			klog.V(4).InfoS("Object has been deleted", "kind", c.resource.Kind, "object", klog.KRef(namespace, name))
+			c.done(key, event)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	klog.V(4).InfoS("WatchController notify", "controller", klog.KObj(c.wc), "type", event.eventType, "kind", c.resource.Kind, "object", klog.KObj(object))
+	request := &NotifyHookRequest{
+		Controller: c.wc,
+		Type:       event.eventType,
+		Object:     object,
+	}
+	if err := callNotifyHook(c.wc, request); err != nil {
+		return fmt.Errorf("can't notify %v for %v %v: %v", event.eventType, c.resource.Kind, key, err)
+	}
+	c.done(key, event)
+	return nil
+}
+
+// done marks the event as delivered, and requeues the key if there are more.
+func (c *watchController) done(key string, event pendingEvent) {
+	if c.pending.Remove(key, event) {
+		c.queue.Add(key)
+	}
+}
+
+// pendingEvent is a change that hasn't been delivered to the hook yet.
+type pendingEvent struct {
+	// id distinguishes events of the same type for the same key.
+	id        uint64
+	eventType EventType
+	// object is the last known state. Added and Updated events are sent with
+	// the latest state from the cache instead, so this only matters for Deleted.
+	object *unstructured.Unstructured
+}
+
+// pendingEvents queues undelivered changes for each object, in order.
+// Consecutive updates are coalesced, since the hook always gets the latest
+// state, but a deletion is never merged with the changes around it.
+type pendingEvents struct {
+	mutex  sync.Mutex
+	nextID uint64
+	events map[string][]pendingEvent
+}
+
+func newPendingEvents() *pendingEvents {
+	return &pendingEvents{events: make(map[string][]pendingEvent)}
+}
+
+// Add queues a change for the given key.
+func (p *pendingEvents) Add(key string, eventType EventType, object *unstructured.Unstructured) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	events := p.events[key]
+	if n := len(events); n > 0 && eventType == EventUpdated && events[n-1].eventType != EventDeleted {
+		// The pending Added or Updated event will send the latest state anyway.
+		return
+	}
+	p.nextID++
+	p.events[key] = append(events, pendingEvent{id: p.nextID, eventType: eventType, object: object})
+}
+
+// Next returns the oldest undelivered change for the given key.
+func (p *pendingEvents) Next(key string) (pendingEvent, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	events := p.events[key]
+	if len(events) == 0 {
+		return pendingEvent{}, false
+	}
+	return events[0], true
+}
+
+// Remove forgets a delivered change, and reports whether more are pending.
+func (p *pendingEvents) Remove(key string, event pendingEvent) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	events := p.events[key]
+	if len(events) > 0 && events[0].id == event.id {
+		events = events[1:]
+	}
+	if len(events) == 0 {
+		delete(p.events, key)
+		return false
+	}
+	p.events[key] = events
+	return true
+}
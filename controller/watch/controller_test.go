@@ -0,0 +1,76 @@
+package watch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPendingEvents(t *testing.T) {
+	table := []struct {
+		name   string
+		events []EventType
+		want   []EventType
+	}{
+		{
+			name:   "updates are coalesced",
+			events: []EventType{EventUpdated, EventUpdated, EventUpdated},
+			want:   []EventType{EventUpdated},
+		},
+		{
+			name:   "updates after add are coalesced",
+			events: []EventType{EventAdded, EventUpdated},
+			want:   []EventType{EventAdded},
+		},
+		{
+			name:   "deletion is kept",
+			events: []EventType{EventAdded, EventUpdated, EventDeleted},
+			want:   []EventType{EventAdded, EventDeleted},
+		},
+		{
+			name:   "recreation after deletion is kept",
+			events: []EventType{EventDeleted, EventAdded, EventUpdated},
+			want:   []EventType{EventDeleted, EventAdded},
+		},
+		{
+			name:   "update after deletion is kept",
+			events: []EventType{EventDeleted, EventUpdated},
+			want:   []EventType{EventDeleted, EventUpdated},
+		},
+	}
+
+	for _, tc := range table {
+		p := newPendingEvents()
+		for _, eventType := range tc.events {
+			p.Add("ns/name", eventType, nil)
+		}
+		var got []EventType
+		for {
+			event, ok := p.Next("ns/name")
+			if !ok {
+				break
+			}
+			got = append(got, event.eventType)
+			p.Remove("ns/name", event)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: delivered %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPendingEventsRemoveStale(t *testing.T) {
+	p := newPendingEvents()
+	p.Add("ns/name", EventDeleted, nil)
+	stale, _ := p.Next("ns/name")
+	if more := p.Remove("ns/name", stale); more {
+		t.Error("Expected no more pending events")
+	}
+	// Removing the same event twice must not drop a newer one.
+	p.Add("ns/name", EventAdded, nil)
+	if more := p.Remove("ns/name", stale); !more {
+		t.Error("Expected the newer event to still be pending")
+	}
+	if event, ok := p.Next("ns/name"); !ok || event.eventType != EventAdded {
+		t.Errorf("Next() = %v, %v; want Added event", event, ok)
+	}
+}
@@ -0,0 +1,53 @@
+package watch
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	"metacontroller.io/hooks"
+)
+
+// EventType is the kind of change sent to the notify hook.
+type EventType string
+
+const (
+	EventAdded   EventType = "Added"
+	EventUpdated EventType = "Updated"
+	EventDeleted EventType = "Deleted"
+)
+
+// NotifyHookRequest is the object sent as JSON to the notify hook.
+type NotifyHookRequest struct {
+	Controller *v1alpha1.WatchController  `json:"controller"`
+	Type       EventType                  `json:"type"`
+	Object     *unstructured.Unstructured `json:"object"`
+
+	// Parameters are the hook parameters of the controller, if it has any.
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// NotifyHookResponse is the expected format of the JSON response from the notify hook.
+// The notify hook doesn't need to return anything, but it must succeed for
+// the change to be considered delivered.
+type NotifyHookResponse struct {
+}
+
+func callNotifyHook(wc *v1alpha1.WatchController, request *NotifyHookRequest) error {
+	if wc.Spec.Hooks == nil || wc.Spec.Hooks.Notify == nil {
+		return fmt.Errorf("notify hook not defined")
+	}
+
+	parameters, err := hooks.ResolveParameters(wc.GetHookParameters())
+	if err != nil {
+		return err
+	}
+	request.Parameters = parameters
+
+	var response NotifyHookResponse
+	if err := hooks.Call(wc.Spec.Hooks.Notify, request, &response); err != nil {
+		return fmt.Errorf("notify hook failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package watch
+
+import (
+	"fmt"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"metacontroller.io/events"
+
+	"k8s.io/klog/v2"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+	mcinformers "metacontroller.io/client/generated/informer/externalversions"
+	mclisters "metacontroller.io/client/generated/lister/metacontroller/v1alpha1"
+	"metacontroller.io/controller/common"
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+	dynamicinformer "metacontroller.io/dynamic/informer"
+)
+
+type Metacontroller struct {
+	resources    *dynamicdiscovery.ResourceMap
+	dynInformers *dynamicinformer.SharedInformerFactory
+
+	wcLister   mclisters.WatchControllerLister
+	wcInformer cache.SharedIndexInformer
+
+	queue            workqueue.RateLimitingInterface
+	watchControllers map[string]*watchController
+
+	stopCh, doneCh chan struct{}
+
+	numWorkers    int
+	eventRecorder record.EventRecorder
+}
+
+func NewMetacontroller(resources *dynamicdiscovery.ResourceMap, dynInformers *dynamicinformer.SharedInformerFactory, mcInformerFactory mcinformers.SharedInformerFactory, numWorkers int, recorder record.EventRecorder) *Metacontroller {
+	mc := &Metacontroller{
+		resources:    resources,
+		dynInformers: dynInformers,
+
+		wcLister:   mcInformerFactory.Metacontroller().V1alpha1().WatchControllers().Lister(),
+		wcInformer: mcInformerFactory.Metacontroller().V1alpha1().WatchControllers().Informer(),
+
+		queue:            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "WatchController"),
+		watchControllers: make(map[string]*watchController),
+
+		numWorkers:    numWorkers,
+		eventRecorder: recorder,
+	}
+
+	mc.wcInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    mc.enqueueWatchController,
+		UpdateFunc: mc.updateWatchController,
+		DeleteFunc: mc.enqueueWatchController,
+	})
+
+	return mc
+}
+
+func (mc *Metacontroller) Start() {
+	mc.stopCh = make(chan struct{})
+	mc.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(mc.doneCh)
+		defer utilruntime.HandleCrash()
+
+		klog.InfoS("Starting WatchController metacontroller")
+		defer klog.InfoS("Shutting down WatchController metacontroller")
+
+		if !cache.WaitForNamedCacheSync("WatchController", mc.stopCh, mc.wcInformer.HasSynced) {
+			return
+		}
+
+		// In the metacontroller, we are only responsible for starting/stopping
+		// the actual controllers, so a single worker should be enough.
+		for mc.processNextWorkItem() {
+		}
+	}()
+}
+
+func (mc *Metacontroller) Stop() {
+	// Stop metacontroller first so there's no more changes to controllers.
+	close(mc.stopCh)
+	mc.queue.ShutDown()
+	<-mc.doneCh
+
+	// Stop all controllers.
+	var wg sync.WaitGroup
+	for _, c := range mc.watchControllers {
+		wg.Add(1)
+		go func(c *watchController) {
+			defer wg.Done()
+			c.Stop()
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (mc *Metacontroller) processNextWorkItem() bool {
+	key, quit := mc.queue.Get()
+	if quit {
+		return false
+	}
+	defer mc.queue.Done(key)
+
+	err := mc.sync(key.(string))
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync WatchController %q: %v", key, err))
+		mc.queue.AddRateLimited(key)
+		return true
+	}
+
+	mc.queue.Forget(key)
+	return true
+}
+
+func (mc *Metacontroller) sync(key string) error {
+	_, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	klog.V(4).InfoS("Sync WatchController", "name", name)
+
+	wc, err := mc.wcLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).InfoS("WatchController has been deleted", "name", name)
+		// Stop and remove the controller if it exists.
+		if c, ok := mc.watchControllers[name]; ok {
+			c.Stop()
+			defer c.eventRecorder.Eventf(c.wc, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", c.wc.Name)
+			delete(mc.watchControllers, name)
+		}
+		return nil
+	}
+	if err != nil {
+		mc.eventRecorder.Eventf(wc, v1.EventTypeNormal, events.ReasonSyncError, "[%s] sync error - %s", wc.Name, err)
+		return err
+	}
+	return mc.syncWatchController(wc)
+}
+
+func (mc *Metacontroller) syncWatchController(wc *v1alpha1.WatchController) error {
+	if c, ok := mc.watchControllers[wc.Name]; ok {
+		// The controller was already started.
+		if apiequality.Semantic.DeepEqual(wc.Spec, c.wc.Spec) {
+			// Nothing has changed.
+			return nil
+		}
+		// Stop and remove the controller so it can be recreated.
+		c.Stop()
+		mc.eventRecorder.Eventf(wc, v1.EventTypeNormal, events.ReasonStopped, "Stopped controller: %s", wc.Name)
+		delete(mc.watchControllers, wc.Name)
+	}
+
+	c, err := newWatchController(mc.resources, mc.dynInformers, wc, mc.numWorkers, mc.eventRecorder)
+	if err != nil {
+		return err
+	}
+	c.Start()
+	mc.eventRecorder.Eventf(wc, v1.EventTypeNormal, events.ReasonStarted, "Started controller: %s", wc.Name)
+	mc.watchControllers[wc.Name] = c
+	return nil
+}
+
+func (mc *Metacontroller) enqueueWatchController(obj interface{}) {
+	key, err := common.KeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	mc.queue.Add(key)
+}
+
+func (mc *Metacontroller) updateWatchController(old, cur interface{}) {
+	mc.enqueueWatchController(cur)
+}
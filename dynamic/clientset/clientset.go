@@ -34,6 +34,9 @@ type Clientset struct {
 	config    rest.Config
 	resources *dynamicdiscovery.ResourceMap
 	dc        dynamic.Interface
+	// operations is only set if reads, writes and deletes are rate limited
+	// separately.
+	operations *operationClients
 }
 
 func New(config *rest.Config, resources *dynamicdiscovery.ResourceMap) (*Clientset, error) {
@@ -48,6 +51,21 @@ func New(config *rest.Config, resources *dynamicdiscovery.ResourceMap) (*Clients
 	}, nil
 }
 
+// WithRateLimit returns a Clientset like cs, whose requests are rate limited
+// to qps and burst separately from cs, for all operations. A qps or burst of
+// 0 keeps the one cs was created with.
+func (cs *Clientset) WithRateLimit(qps float32, burst int) (*Clientset, error) {
+	config := cs.config
+	config.RateLimiter = nil
+	if qps > 0 {
+		config.QPS = qps
+	}
+	if burst > 0 {
+		config.Burst = burst
+	}
+	return New(&config, cs.resources)
+}
+
 func (cs *Clientset) HasSynced() bool {
 	return cs.resources.HasSynced()
 }
@@ -71,7 +89,11 @@ func (cs *Clientset) Kind(apiVersion, kind string) (*ResourceClient, error) {
 }
 
 func (cs *Clientset) resource(apiResource *dynamicdiscovery.APIResource) *ResourceClient {
-	client := cs.dc.Resource(apiResource.GroupVersionResource())
+	var client dynamic.NamespaceableResourceInterface = cs.dc.Resource(apiResource.GroupVersionResource())
+	if cs.operations != nil {
+		gvr := apiResource.GroupVersionResource()
+		client = newOperationResource(cs.operations.read.Resource(gvr), cs.operations.write.Resource(gvr), cs.operations.delete.Resource(gvr))
+	}
 	return &ResourceClient{
 		ResourceInterface: client,
 		APIResource:       apiResource,
@@ -206,3 +228,33 @@ func (rc *ResourceClient) AtomicStatusUpdate(orig *unstructured.Unstructured, up
 	})
 	return result, err
 }
+
+// RetryOnConflict calls write with orig, which is usually up to date.
+// If write fails with an optimistic concurrency conflict, it's called again
+// with the latest version of the object from a live GET against the API
+// server, a bounded number of times.
+//
+// Unlike AtomicUpdate, this doesn't cost an extra GET unless there's a
+// conflict. write must not modify the object it's given, since orig may come
+// from a shared cache, and must return API errors as-is.
+func (rc *ResourceClient) RetryOnConflict(orig *unstructured.Unstructured, write func(current *unstructured.Unstructured) error) error {
+	current := orig
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if current == nil {
+			live, err := rc.Get(orig.GetName(), metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if live.GetUID() != orig.GetUID() {
+				// The original object was deleted and replaced with a new one.
+				return apierrors.NewNotFound(rc.GroupResource(), orig.GetName())
+			}
+			current = live
+		}
+		err := write(current)
+		if apierrors.IsConflict(err) {
+			current = nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,120 @@
+package clientset
+
+import (
+	"fmt"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	dynamicdiscovery "metacontroller.io/dynamic/discovery"
+)
+
+func newTestConfigMap(uid, resourceVersion string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName("test")
+	obj.SetUID(types.UID(uid))
+	obj.SetResourceVersion(resourceVersion)
+	return obj
+}
+
+func newTestResourceClient(objects ...runtime.Object) *ResourceClient {
+	resource := &dynamicdiscovery.APIResource{
+		APIResource: metav1.APIResource{Name: "configmaps", Namespaced: true, Kind: "ConfigMap"},
+		APIVersion:  "v1",
+	}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...).Resource(resource.GroupVersionResource())
+	rc := &ResourceClient{ResourceInterface: client, APIResource: resource, rootClient: client}
+	return rc.Namespace("default")
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	conflict := apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "test", fmt.Errorf("stale"))
+	table := []struct {
+		name string
+		// live is the object the API server has, if any.
+		live *unstructured.Unstructured
+		// results are the errors of successive writes.
+		results []error
+		// wantVersions are the resourceVersions of the objects the writes
+		// were given.
+		wantVersions []string
+		wantErr      func(error) bool
+	}{
+		{
+			name:         "no conflict",
+			live:         newTestConfigMap("1", "2"),
+			results:      []error{nil},
+			wantVersions: []string{"1"},
+		},
+		{
+			name:         "conflict retried with the live object",
+			live:         newTestConfigMap("1", "2"),
+			results:      []error{conflict, nil},
+			wantVersions: []string{"1", "2"},
+		},
+		{
+			name:         "conflicts give up",
+			live:         newTestConfigMap("1", "2"),
+			results:      []error{conflict, conflict, conflict, conflict, conflict},
+			wantVersions: []string{"1", "2", "2", "2", "2"},
+			wantErr:      apierrors.IsConflict,
+		},
+		{
+			name:         "other errors aren't retried",
+			live:         newTestConfigMap("1", "2"),
+			results:      []error{apierrors.NewBadRequest("invalid")},
+			wantVersions: []string{"1"},
+			wantErr:      apierrors.IsBadRequest,
+		},
+		{
+			name:         "object replaced",
+			live:         newTestConfigMap("2", "2"),
+			results:      []error{conflict},
+			wantVersions: []string{"1"},
+			wantErr:      apierrors.IsNotFound,
+		},
+		{
+			name:         "object deleted",
+			results:      []error{conflict},
+			wantVersions: []string{"1"},
+			wantErr:      apierrors.IsNotFound,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if tc.live != nil {
+				objects = append(objects, tc.live)
+			}
+			rc := newTestResourceClient(objects...)
+
+			var versions []string
+			err := rc.RetryOnConflict(newTestConfigMap("1", "1"), func(current *unstructured.Unstructured) error {
+				versions = append(versions, current.GetResourceVersion())
+				if len(versions) > len(tc.results) {
+					t.Fatalf("write called %v times, want at most %v", len(versions), len(tc.results))
+				}
+				return tc.results[len(versions)-1]
+			})
+			if tc.wantErr == nil && err != nil {
+				t.Errorf("RetryOnConflict() = %v, want no error", err)
+			}
+			if tc.wantErr != nil && !tc.wantErr(err) {
+				t.Errorf("RetryOnConflict() = %v, want a different error", err)
+			}
+			if fmt.Sprint(versions) != fmt.Sprint(tc.wantVersions) {
+				t.Errorf("write got resourceVersions %v, want %v", versions, tc.wantVersions)
+			}
+		})
+	}
+}
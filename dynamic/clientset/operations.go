@@ -0,0 +1,121 @@
+package clientset
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// RateLimit is the QPS and burst of a client.
+type RateLimit struct {
+	QPS   float32
+	Burst int
+}
+
+// operationClients are the clients that reads, writes and deletes go
+// through, when they're rate limited separately.
+type operationClients struct {
+	read, write, delete dynamic.Interface
+}
+
+// WithOperationRateLimits returns a Clientset like cs, whose reads, writes and
+// deletes are each rate limited separately, if their rate limit is set.
+// The others share the rate limit of cs. A QPS or burst of 0 keeps the one
+// cs was created with.
+func (cs *Clientset) WithOperationRateLimits(reads, writes, deletes *RateLimit) (*Clientset, error) {
+	clients := operationClients{read: cs.dc, write: cs.dc, delete: cs.dc}
+	if cs.operations != nil {
+		clients = *cs.operations
+	}
+	for _, op := range []struct {
+		limit  *RateLimit
+		client *dynamic.Interface
+	}{
+		{reads, &clients.read},
+		{writes, &clients.write},
+		{deletes, &clients.delete},
+	} {
+		if op.limit == nil {
+			continue
+		}
+		limited, err := cs.WithRateLimit(op.limit.QPS, op.limit.Burst)
+		if err != nil {
+			return nil, err
+		}
+		*op.client = limited.dc
+	}
+	return &Clientset{
+		config:     cs.config,
+		resources:  cs.resources,
+		dc:         cs.dc,
+		operations: &clients,
+	}, nil
+}
+
+// operationResource sends each operation on a resource through the client
+// for its kind.
+type operationResource struct {
+	read, write, delete dynamic.ResourceInterface
+}
+
+func (r *operationResource) Create(obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.write.Create(obj, options, subresources...)
+}
+
+func (r *operationResource) Update(obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.write.Update(obj, options, subresources...)
+}
+
+func (r *operationResource) UpdateStatus(obj *unstructured.Unstructured, options metav1.UpdateOptions) (*unstructured.Unstructured, error) {
+	return r.write.UpdateStatus(obj, options)
+}
+
+func (r *operationResource) Patch(name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.write.Patch(name, pt, data, options, subresources...)
+}
+
+func (r *operationResource) Delete(name string, options *metav1.DeleteOptions, subresources ...string) error {
+	return r.delete.Delete(name, options, subresources...)
+}
+
+func (r *operationResource) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	return r.delete.DeleteCollection(options, listOptions)
+}
+
+func (r *operationResource) Get(name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.read.Get(name, options, subresources...)
+}
+
+func (r *operationResource) List(opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return r.read.List(opts)
+}
+
+func (r *operationResource) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	return r.read.Watch(opts)
+}
+
+// namespaceableOperationResource is an operationResource that can be scoped
+// down to a namespace.
+type namespaceableOperationResource struct {
+	operationResource
+	readRoot, writeRoot, deleteRoot dynamic.NamespaceableResourceInterface
+}
+
+func newOperationResource(read, write, delete dynamic.NamespaceableResourceInterface) *namespaceableOperationResource {
+	return &namespaceableOperationResource{
+		operationResource: operationResource{read: read, write: write, delete: delete},
+		readRoot:          read,
+		writeRoot:         write,
+		deleteRoot:        delete,
+	}
+}
+
+func (r *namespaceableOperationResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &operationResource{
+		read:   r.readRoot.Namespace(namespace),
+		write:  r.writeRoot.Namespace(namespace),
+		delete: r.deleteRoot.Namespace(namespace),
+	}
+}
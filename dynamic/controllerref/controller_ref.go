@@ -19,8 +19,40 @@ package controllerref
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
 )
 
+// IsOwnedBy returns whether obj already belongs to the owner with the given
+// UID, according to the owner reference settings of its kind (nil for the
+// defaults): by controller reference, by plain owner reference if the owner
+// doesn't set controller references, or, if it tracks children by labels
+// only, as long as nobody else controls obj. Selectors aren't checked.
+func IsOwnedBy(obj metav1.Object, ownerUID types.UID, config *v1alpha1.ChildOwnerReference) bool {
+	if controllerRef := metav1.GetControllerOf(obj); controllerRef != nil {
+		return controllerRef.UID == ownerUID
+	}
+	if config == nil {
+		return false
+	}
+	if config.LabelsOnly {
+		return true
+	}
+	if config.Controller != nil && !*config.Controller {
+		return hasOwnerReference(obj.GetOwnerReferences(), ownerUID)
+	}
+	return false
+}
+
+func hasOwnerReference(refs []metav1.OwnerReference, uid types.UID) bool {
+	for _, ref := range refs {
+		if ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
 func addOwnerReference(in []metav1.OwnerReference, add metav1.OwnerReference) []metav1.OwnerReference {
 	out := make([]metav1.OwnerReference, 0, len(in)+1)
 	found := false
@@ -30,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
+	"metacontroller.io/apis/metacontroller/v1alpha1"
 	dynamicclientset "metacontroller.io/dynamic/clientset"
 	k8s "metacontroller.io/third_party/kubernetes"
 )
@@ -39,6 +40,10 @@ type UnstructuredManager struct {
 	parentKind schema.GroupVersionKind
 	childKind  schema.GroupVersionKind
 	client     *dynamicclientset.ResourceClient
+
+	// OwnerReference configures the owner reference of children, if it
+	// isn't the default blocking controller reference.
+	OwnerReference *v1alpha1.ChildOwnerReference
 }
 
 func NewUnstructuredManager(client *dynamicclientset.ResourceClient, parent metav1.Object, selector labels.Selector, parentKind, childKind schema.GroupVersionKind, canAdopt func() error) *UnstructuredManager {
@@ -69,7 +74,15 @@ func (m *UnstructuredManager) ClaimChildren(children []*unstructured.Unstructure
 	}
 
 	for _, child := range children {
-		ok, err := m.ClaimObject(child, match, adopt, release)
+		var ok bool
+		var err error
+		if metav1.GetControllerOf(child) == nil && IsOwnedBy(child, m.Controller.GetUID(), m.OwnerReference) {
+			// Children without a controller reference that are ours anyway,
+			// because we don't set controller references.
+			ok, err = m.claimOwned(child, match, release)
+		} else {
+			ok, err = m.ClaimObject(child, match, adopt, release)
+		}
 		if err != nil {
 			errlist = append(errlist, err)
 			continue
@@ -81,6 +94,22 @@ func (m *UnstructuredManager) ClaimChildren(children []*unstructured.Unstructure
 	return claimed, utilerrors.NewAggregate(errlist)
 }
 
+// claimOwned is like ClaimObject for children we own without being their
+// controller.
+func (m *UnstructuredManager) claimOwned(child *unstructured.Unstructured, match func(metav1.Object) bool, release func(metav1.Object) error) (bool, error) {
+	if match(child) {
+		return true, nil
+	}
+	if m.OwnerReference.LabelsOnly || m.Controller.GetDeletionTimestamp() != nil {
+		// There's nothing to release.
+		return false, nil
+	}
+	if err := release(child); err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	return false, nil
+}
+
 func atomicUpdate(rc *dynamicclientset.ResourceClient, obj *unstructured.Unstructured, updateFunc func(obj *unstructured.Unstructured) bool) error {
 	// We can't use strategic merge patch because we want this to work with custom resources.
 	// We can't use merge patch because that would replace the whole list.
@@ -95,6 +124,51 @@ func (m *UnstructuredManager) adoptChild(obj *unstructured.Unstructured) error {
 		return fmt.Errorf("can't adopt %v %v/%v (%v): %v", m.childKind.Kind, obj.GetNamespace(), obj.GetName(), obj.GetUID(), err)
 	}
 	klog.InfoS("Adopting", "parent_kind", m.parentKind.Kind, "controller", klog.KObj(m.Controller), "child_kind", m.childKind.Kind, "object", klog.KObj(obj))
+	controllerRef := m.controllerRef()
+	return atomicUpdate(m.client, obj, func(obj *unstructured.Unstructured) bool {
+		ownerRefs := addOwnerReference(obj.GetOwnerReferences(), controllerRef)
+		obj.SetOwnerReferences(ownerRefs)
+		return true
+	})
+}
+
+// AdoptOrphan adopts obj, which doesn't match the selector, and adds
+// selectorLabels to it so it matches from then on. It returns the adopted
+// object, or nil if obj got another controller in the meantime.
+func (m *UnstructuredManager) AdoptOrphan(obj *unstructured.Unstructured, selectorLabels map[string]string) (*unstructured.Unstructured, error) {
+	if err := m.CanAdopt(); err != nil {
+		return nil, fmt.Errorf("can't adopt %v %v/%v (%v): %v", m.childKind.Kind, obj.GetNamespace(), obj.GetName(), obj.GetUID(), err)
+	}
+	klog.InfoS("Adopting orphan", "parent_kind", m.parentKind.Kind, "controller", klog.KObj(m.Controller), "child_kind", m.childKind.Kind, "object", klog.KObj(obj))
+	controllerRef := m.controllerRef()
+	adopted := false
+	result, err := m.client.Namespace(obj.GetNamespace()).AtomicUpdate(obj, func(obj *unstructured.Unstructured) bool {
+		adopted = metav1.GetControllerOf(obj) == nil
+		if !adopted {
+			// Someone else got there first.
+			return false
+		}
+		objLabels := obj.GetLabels()
+		if objLabels == nil {
+			objLabels = make(map[string]string, len(selectorLabels))
+		}
+		for key, value := range selectorLabels {
+			objLabels[key] = value
+		}
+		obj.SetLabels(objLabels)
+		if m.OwnerReference == nil || !m.OwnerReference.LabelsOnly {
+			obj.SetOwnerReferences(addOwnerReference(obj.GetOwnerReferences(), controllerRef))
+		}
+		return true
+	})
+	if err != nil || !adopted {
+		return nil, err
+	}
+	return result, nil
+}
+
+// controllerRef returns the owner reference of children to the parent.
+func (m *UnstructuredManager) controllerRef() metav1.OwnerReference {
 	controllerRef := metav1.OwnerReference{
 		APIVersion:         m.parentKind.GroupVersion().String(),
 		Kind:               m.parentKind.Kind,
@@ -103,11 +177,15 @@ func (m *UnstructuredManager) adoptChild(obj *unstructured.Unstructured) error {
 		Controller:         pointer.BoolPtr(true),
 		BlockOwnerDeletion: pointer.BoolPtr(true),
 	}
-	return atomicUpdate(m.client, obj, func(obj *unstructured.Unstructured) bool {
-		ownerRefs := addOwnerReference(obj.GetOwnerReferences(), controllerRef)
-		obj.SetOwnerReferences(ownerRefs)
-		return true
-	})
+	if config := m.OwnerReference; config != nil {
+		if config.Controller != nil {
+			controllerRef.Controller = pointer.BoolPtr(*config.Controller)
+		}
+		if config.BlockOwnerDeletion != nil {
+			controllerRef.BlockOwnerDeletion = pointer.BoolPtr(*config.BlockOwnerDeletion)
+		}
+	}
+	return controllerRef
 }
 
 func (m *UnstructuredManager) releaseChild(obj *unstructured.Unstructured) error {
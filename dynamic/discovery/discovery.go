@@ -18,6 +18,7 @@ package discovery
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -26,6 +27,7 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/discovery"
 )
 
@@ -67,9 +69,23 @@ type groupVersionEntry struct {
 type ResourceMap struct {
 	mutex         sync.RWMutex
 	groupVersions map[string]groupVersionEntry
+	serverVersion *version.Info
 
 	discoveryClient discovery.DiscoveryInterface
 	stopCh, doneCh  chan struct{}
+
+	listenersMutex sync.Mutex
+	// listeners are called when a refresh finds new resources.
+	listeners []func()
+}
+
+// AddListener registers listener to be called, from the refresh goroutine,
+// each time a refresh of discovery finds resources that weren't served before,
+// including on the first refresh. listener mustn't block.
+func (rm *ResourceMap) AddListener(listener func()) {
+	rm.listenersMutex.Lock()
+	defer rm.listenersMutex.Unlock()
+	rm.listeners = append(rm.listeners, listener)
 }
 
 func (rm *ResourceMap) Get(apiVersion, resource string) (result *APIResource) {
@@ -94,6 +110,49 @@ func (rm *ResourceMap) GetKind(apiVersion, kind string) (result *APIResource) {
 	return gv.kinds[kind]
 }
 
+// ServerVersion returns the version of the API server, or nil if it's not
+// known yet.
+func (rm *ResourceMap) ServerVersion() *version.Info {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return rm.serverVersion
+}
+
+// GroupVersions returns the versions served for an API group, from the most
+// to the least stable.
+func (rm *ResourceMap) GroupVersions(group string) []string {
+	return rm.versions(group, "")
+}
+
+// ResourceVersions returns the versions in which a resource of an API group
+// is served, from the most to the least stable.
+func (rm *ResourceMap) ResourceVersions(group, resource string) []string {
+	return rm.versions(group, resource)
+}
+
+// versions returns the versions of group that serve resource, or all of
+// them if resource is empty.
+func (rm *ResourceMap) versions(group, resource string) []string {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	versions := []string{}
+	for apiVersion, gve := range rm.groupVersions {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil || gv.Group != group {
+			continue
+		}
+		if resource != "" && gve.resources[resource] == nil {
+			continue
+		}
+		versions = append(versions, gv.Version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return version.CompareKubeAwareVersionStrings(versions[i], versions[j]) > 0
+	})
+	return versions
+}
+
 func (rm *ResourceMap) refresh() {
 	// Fetch all API Group-Versions and their resources from the server.
 	// We do this before acquiring the lock so we don't block readers.
@@ -160,10 +219,45 @@ func (rm *ResourceMap) refresh() {
 		groupVersions[group.GroupVersion] = gve
 	}
 
+	// The server version only matters to hooks, so keep the last one known
+	// if it can't be fetched.
+	serverVersion, err := rm.discoveryClient.ServerVersion()
+	if err != nil {
+		klog.ErrorS(err, "Failed to fetch server version")
+	}
+
 	// Replace the local cache.
 	rm.mutex.Lock()
+	added := hasNewResources(rm.groupVersions, groupVersions)
 	rm.groupVersions = groupVersions
+	if serverVersion != nil {
+		rm.serverVersion = serverVersion
+	}
 	rm.mutex.Unlock()
+
+	if added {
+		rm.listenersMutex.Lock()
+		listeners := append([]func(){}, rm.listeners...)
+		rm.listenersMutex.Unlock()
+		for _, listener := range listeners {
+			listener()
+		}
+	}
+}
+
+// hasNewResources returns whether current has resources that previous doesn't.
+func hasNewResources(previous, current map[string]groupVersionEntry) bool {
+	if previous == nil {
+		return current != nil
+	}
+	for groupVersion, gve := range current {
+		for name := range gve.resources {
+			if _, ok := previous[groupVersion].resources[name]; !ok {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (rm *ResourceMap) Start(refreshInterval time.Duration) {
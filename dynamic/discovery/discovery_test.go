@@ -0,0 +1,55 @@
+package discovery
+
+import "testing"
+
+func TestHasNewResources(t *testing.T) {
+	entry := func(resources ...string) groupVersionEntry {
+		gve := groupVersionEntry{resources: make(map[string]*APIResource)}
+		for _, resource := range resources {
+			gve.resources[resource] = &APIResource{}
+		}
+		return gve
+	}
+
+	table := []struct {
+		name              string
+		previous, current map[string]groupVersionEntry
+		want              bool
+	}{
+		{
+			name:    "first refresh",
+			current: map[string]groupVersionEntry{"v1": entry("pods")},
+			want:    true,
+		},
+		{
+			name:     "unchanged",
+			previous: map[string]groupVersionEntry{"v1": entry("pods")},
+			current:  map[string]groupVersionEntry{"v1": entry("pods")},
+		},
+		{
+			name:     "removed",
+			previous: map[string]groupVersionEntry{"v1": entry("pods", "configmaps")},
+			current:  map[string]groupVersionEntry{"v1": entry("pods")},
+		},
+		{
+			name:     "new resource",
+			previous: map[string]groupVersionEntry{"v1": entry("pods")},
+			current:  map[string]groupVersionEntry{"v1": entry("pods", "configmaps")},
+			want:     true,
+		},
+		{
+			name:     "new group version",
+			previous: map[string]groupVersionEntry{"v1": entry("pods")},
+			current:  map[string]groupVersionEntry{"v1": entry("pods"), "ctl.enisoc.com/v1": entry("catsets")},
+			want:     true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := hasNewResources(tc.previous, tc.current); got != tc.want {
+				t.Errorf("hasNewResources() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -103,6 +103,7 @@ func (f *SharedInformerFactory) Resource(apiVersion, resource string) (*Resource
 
 	klog.V(4).InfoS("Starting shared informer", "resource", resource, "api_version", apiVersion)
 	sharedInformer := newSharedResourceInformer(client, f.defaultResync, closeFn)
+	sharedInformer.apiVersion, sharedInformer.resource = apiVersion, resource
 	f.sharedInformers[key] = sharedInformer
 	f.refCount[key] = 1
 
@@ -21,10 +21,12 @@ import (
 	"sync"
 	"time"
 
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/cache"
 
@@ -85,6 +87,42 @@ func (ri *ResourceInformer) Lister() dynamiclister.Lister {
 	return ri.sharedResourceInformer.lister
 }
 
+// ListControlledBy returns the objects whose controller has the given UID,
+// without scanning the whole cache.
+func (ri *ResourceInformer) ListControlledBy(uid types.UID) ([]*unstructured.Unstructured, error) {
+	return ri.byIndex(ControllerIndex, string(uid))
+}
+
+// ListOrphans returns the objects in namespace, or in all namespaces if it's
+// empty, that don't have a controller, without scanning the whole cache.
+func (ri *ResourceInformer) ListOrphans(namespace string) ([]*unstructured.Unstructured, error) {
+	value := orphansIndexValue
+	if namespace != "" {
+		value += "/" + namespace
+	}
+	return ri.byIndex(ControllerIndex, value)
+}
+
+// ListByParentUID returns the objects whose ParentUIDLabel is uid, in all
+// namespaces, without scanning the whole cache.
+func (ri *ResourceInformer) ListByParentUID(uid types.UID) ([]*unstructured.Unstructured, error) {
+	return ri.byIndex(ParentUIDIndex, string(uid))
+}
+
+func (ri *ResourceInformer) byIndex(indexName, value string) ([]*unstructured.Unstructured, error) {
+	objs, err := ri.sharedResourceInformer.informer.GetIndexer().ByIndex(indexName, value)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			result = append(result, u)
+		}
+	}
+	return result, nil
+}
+
 // Close marks this ResourceInformer as unused, allowing the underlying shared
 // informer to be stopped when no users are left.
 // You should call this when you no longer need the informer, so the watches
@@ -94,9 +132,51 @@ func (ri *ResourceInformer) Close() {
 	ri.sharedResourceInformer.close()
 }
 
+// ControllerIndex indexes objects by the UID of their controller, so the
+// children of a parent can be found without scanning all objects of a kind,
+// which matters when there are tens of thousands of them.
+// Objects without a controller are indexed as orphans, both per namespace
+// and across all namespaces, since they may be adopted.
+const ControllerIndex = "controller"
+
+const orphansIndexValue = "orphans"
+
+func controllerIndexFunc(obj interface{}) ([]string, error) {
+	meta, err := apimeta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	if controllerRef := metav1.GetControllerOf(meta); controllerRef != nil {
+		return []string{string(controllerRef.UID)}, nil
+	}
+	return []string{orphansIndexValue, orphansIndexValue + "/" + meta.GetNamespace()}, nil
+}
+
+// ParentUIDLabel holds the UID of the parent of children that can't have an
+// owner reference to it, because they're in another namespace.
+const ParentUIDLabel = "metacontroller.io/parent-uid"
+
+// ParentUIDIndex indexes objects by their ParentUIDLabel, so the children of
+// a parent can be found in any number of namespaces without scanning all
+// objects of a kind.
+const ParentUIDIndex = "parent-uid"
+
+func parentUIDIndexFunc(obj interface{}) ([]string, error) {
+	meta, err := apimeta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	if uid := meta.GetLabels()[ParentUIDLabel]; uid != "" {
+		return []string{uid}, nil
+	}
+	return nil, nil
+}
+
 // sharedResourceInformer is the actual, single informer that's shared by
 // multiple ResourceInformer instances.
 type sharedResourceInformer struct {
+	apiVersion, resource string
+
 	informer cache.SharedIndexInformer
 	lister   dynamiclister.Lister
 
@@ -119,6 +199,8 @@ func newSharedResourceInformer(client *dynamicclientset.ResourceClient, defaultR
 		defaultResyncPeriod,
 		cache.Indexers{
 			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+			ControllerIndex:      controllerIndexFunc,
+			ParentUIDIndex:       parentUIDIndexFunc,
 		},
 	)
 	sri := &sharedResourceInformer{
@@ -0,0 +1,82 @@
+package informer
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// CacheStats describes the cache of one shared informer.
+type CacheStats struct {
+	APIVersion string `json:"apiVersion"`
+	Resource   string `json:"resource"`
+	// Subscribers is the number of controllers using the informer.
+	Subscribers int `json:"subscribers"`
+	// Objects is the number of objects in the cache.
+	Objects int `json:"objects"`
+	// Bytes approximates the memory used by the objects in the cache, as the
+	// size of their JSON encoding. It's only computed when sizes are asked
+	// for.
+	Bytes int64 `json:"bytes,omitempty"`
+}
+
+// CacheStats returns the stats of the caches of all shared informers,
+// largest first. If sizes is true, it encodes every cached object to compute
+// their size, so it's only meant for debugging.
+func (f *SharedInformerFactory) CacheStats(sizes bool) []CacheStats {
+	f.mutex.Lock()
+	informers := make([]*sharedResourceInformer, 0, len(f.sharedInformers))
+	subscribers := make(map[*sharedResourceInformer]int, len(f.sharedInformers))
+	for key, informer := range f.sharedInformers {
+		informers = append(informers, informer)
+		subscribers[informer] = f.refCount[key]
+	}
+	f.mutex.Unlock()
+
+	stats := make([]CacheStats, 0, len(informers))
+	for _, informer := range informers {
+		objects := informer.informer.GetStore().List()
+		stat := CacheStats{
+			APIVersion:  informer.apiVersion,
+			Resource:    informer.resource,
+			Subscribers: subscribers[informer],
+			Objects:     len(objects),
+		}
+		for _, obj := range objects {
+			if !sizes {
+				break
+			}
+			if obj, ok := obj.(*unstructured.Unstructured); ok {
+				data, err := json.Marshal(obj.UnstructuredContent())
+				if err == nil {
+					stat.Bytes += int64(len(data))
+				}
+			}
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Bytes != stats[j].Bytes {
+			return stats[i].Bytes > stats[j].Bytes
+		}
+		if stats[i].Objects != stats[j].Objects {
+			return stats[i].Objects > stats[j].Objects
+		}
+		return stats[i].Resource+"."+stats[i].APIVersion < stats[j].Resource+"."+stats[j].APIVersion
+	})
+	return stats
+}
+
+// CacheStatsHandler serves the stats of the caches of all shared informers,
+// as a JSON list. Unless sizes is true, it only serves the number of objects
+// and subscribers of each cache, which is cheap enough to poll.
+func (f *SharedInformerFactory) CacheStatsHandler(sizes bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(f.CacheStats(sizes))
+	})
+}
@@ -1,11 +1,15 @@
 package events
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
 )
 
 const (
@@ -14,6 +18,28 @@ const (
 	ReasonStopped   string = "Stopped"
 	ReasonStopping  string = "Stopping"
 	ReasonSyncError string = "SyncError"
+	ReasonCleanedUp string = "CleanedUp"
+
+	ReasonChildHeld         string = "ChildHeld"
+	ReasonChildReleased     string = "ChildReleased"
+	ReasonApplyConflict     string = "ApplyConflict"
+	ReasonSyncRetry         string = "SyncRetry"
+	ReasonSyncFailed        string = "SyncFailed"
+	ReasonHookError         string = "HookError"
+	ReasonQuarantined       string = "Quarantined"
+	ReasonDeadLettered      string = "DeadLettered"
+	ReasonObserved          string = "Observed"
+	ReasonDeletionProtected string = "DeletionProtected"
+	ReasonChildRejected     string = "ChildRejected"
+	ReasonAudited           string = "Audited"
+	ReasonUIDMismatch       string = "UIDMismatch"
+	ReasonInventoryTooLarge string = "InventoryTooLarge"
+
+	ReasonRolloutStarted   string = "RolloutStarted"
+	ReasonRolloutProgress  string = "RolloutProgress"
+	ReasonRolloutPaused    string = "RolloutPaused"
+	ReasonRolloutCompleted string = "RolloutCompleted"
+	ReasonRolledBack       string = "RolledBack"
 )
 
 func NewBroadcaster(config *rest.Config, options record.CorrelatorOptions) (record.EventBroadcaster, error) {
@@ -26,3 +52,58 @@ func NewBroadcaster(config *rest.Config, options record.CorrelatorOptions) (reco
 	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientSet.CoreV1().Events(metav1.NamespaceAll)})
 	return broadcaster, nil
 }
+
+// Broadcasters starts a broadcaster of events for each controller, so the
+// events of each controller are rate limited on their own, and a flapping
+// controller doesn't get the events of healthy ones dropped.
+type Broadcasters struct {
+	sink     record.EventSink
+	scheme   *runtime.Scheme
+	defaults record.CorrelatorOptions
+}
+
+// NewBroadcasters returns Broadcasters that record events of objects in scheme
+// with the given default rate limits.
+func NewBroadcasters(config *rest.Config, scheme *runtime.Scheme, defaults record.CorrelatorOptions) (*Broadcasters, error) {
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Broadcasters{
+		sink:     &typedcorev1.EventSinkImpl{Interface: clientSet.CoreV1().Events(metav1.NamespaceAll)},
+		scheme:   scheme,
+		defaults: defaults,
+	}, nil
+}
+
+// NewRecorder starts a broadcaster rate limited by limit, which overrides the
+// defaults if it's set, and returns a recorder of events through it, from
+// source if it's set, along with a function that shuts it down.
+func (b *Broadcasters) NewRecorder(limit *v1alpha1.EventRateLimit, source *v1alpha1.EventSource) (record.EventRecorder, func()) {
+	options := b.defaults
+	if limit != nil {
+		if limit.PeriodSeconds > 0 {
+			options.QPS = 1 / float32(limit.PeriodSeconds)
+		}
+		if limit.Burst > 0 {
+			options.BurstSize = int(limit.Burst)
+		}
+	}
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(options)
+	broadcaster.StartRecordingToSink(b.sink)
+	return broadcaster.NewRecorder(b.scheme, eventSource(source)), broadcaster.Shutdown
+}
+
+// eventSource returns the source of the events of a controller, which is
+// metacontroller unless source overrides it.
+func eventSource(source *v1alpha1.EventSource) corev1.EventSource {
+	result := corev1.EventSource{Component: "metacontroller"}
+	if source == nil {
+		return result
+	}
+	if source.Component != "" {
+		result.Component = source.Component
+	}
+	result.Host = source.Host
+	return result
+}
@@ -57,16 +57,16 @@ class Controller(BaseHTTPRequestHandler):
     # delete children, and take no further action.
     if is_job_finished(job):
       desired_status = copy.deepcopy(job['status'])
-      desired_status['conditions'] = [{'type': 'Complete', 'status': 'True'}]
+      desired_status['conditions'] = [{'type': 'Complete', 'status': 'True', 'reason': 'DaemonSetCompleted'}]
       return {'status': desired_status, 'children': []}
 
     # Compute status based on what we observed, before building desired state.
     # Our .status is just a copy of the DaemonSet .status with extra fields.
     desired_status = copy.deepcopy(children['DaemonSet.apps/v1'].get(child, {}).get('status',{}))
     if is_job_finished(children['DaemonSet.apps/v1'].get(child, {})):
-      desired_status['conditions'] = [{'type': 'Complete', 'status': 'True'}]
+      desired_status['conditions'] = [{'type': 'Complete', 'status': 'True', 'reason': 'DaemonSetCompleted'}]
     else:
-      desired_status['conditions'] = [{'type': 'Complete', 'status': 'False'}]
+      desired_status['conditions'] = [{'type': 'Complete', 'status': 'False', 'reason': 'DaemonSetRunning'}]
 
     # Always generate desired state for child if we reach this point.
     # We should not delete children until after we know we've recorded
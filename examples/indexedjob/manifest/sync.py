@@ -75,7 +75,12 @@ class Controller(BaseHTTPRequestHandler):
     # Compute status based on what we observed, before building desired state.
     spec_completions = job['spec'].get('completions', 1)
     desired_status = {'active': active, 'succeeded': succeeded, 'failed': failed}
-    desired_status['conditions'] = [{'type': 'Complete', 'status': str(succeeded == spec_completions)}]
+    complete = succeeded == spec_completions
+    desired_status['conditions'] = [{
+      'type': 'Complete',
+      'status': str(complete),
+      'reason': 'AllPodsSucceeded' if complete else 'PodsRunning'
+    }]
 
     # Generate desired state for existing Pods.
     desired_pods = {}
@@ -5,7 +5,21 @@ module metacontroller.io
 go 1.16
 
 require (
+	github.com/Masterminds/goutils v1.1.1 // indirect
+	github.com/Masterminds/semver/v3 v3.1.1 // indirect
+	github.com/Masterminds/sprig/v3 v3.1.0 // indirect
+	github.com/evanphx/json-patch v4.9.0+incompatible
+	github.com/go-logr/logr v0.4.0
+	github.com/google/go-cmp v0.5.5 // indirect
+	github.com/google/uuid v1.1.4 // indirect
+	github.com/huandu/xstrings v1.3.2 // indirect
+	github.com/imdario/mergo v0.3.9 // indirect
 	github.com/prometheus/client_golang v1.9.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/spf13/cast v1.4.1 // indirect
+	go.starlark.net v0.0.0-20221205180719-3fd0dac74452
+	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
+	helm.sh/helm/v3 v3.1.2
 	k8s.io/api v0.17.17
 	k8s.io/apimachinery v0.17.17
 	k8s.io/client-go v0.17.17
@@ -18,4 +32,5 @@ replace (
 	k8s.io/apimachinery => k8s.io/apimachinery v0.17.17
 	k8s.io/client-go => k8s.io/client-go v0.17.17
 	k8s.io/component-base => k8s.io/component-base v0.17.17
+	k8s.io/apiextensions-apiserver => k8s.io/apiextensions-apiserver v0.17.0
 )
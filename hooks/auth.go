@@ -0,0 +1,152 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// tokenSources holds one refreshing token source per OAuth2 client
+// configuration, so tokens are reused until they expire.
+var tokenSources = &tokenSourceCache{entries: make(map[tokenSourceKey]tokenSourceEntry)}
+
+type tokenSourceKey struct {
+	tokenURL         string
+	clientID         string
+	clientSecretFrom v1alpha1.SecretKeyReference
+	scopes           string
+}
+
+type tokenSourceEntry struct {
+	// secretHash is the hash of the client secret the token source uses.
+	secretHash string
+	// client is the HTTP client the token source requests tokens with.
+	client *http.Client
+	ts     oauth2.TokenSource
+}
+
+type tokenSourceCache struct {
+	mutex   sync.Mutex
+	entries map[tokenSourceKey]tokenSourceEntry
+}
+
+// Get returns a token source for the given client credentials config, which
+// requests tokens with client, so they get the timeout and the CA bundle of
+// the hook.
+// A rotated client secret, or a change of the hook's timeout or transport,
+// replaces the token source rather than reusing tokens obtained with the old
+// one.
+func (c *tokenSourceCache) Get(config *v1alpha1.OAuth2ClientCredentials, client *http.Client) (oauth2.TokenSource, error) {
+	clientSecret, err := secrets.Get(&config.ClientSecretFrom)
+	if err != nil {
+		return nil, fmt.Errorf("can't read OAuth2 client secret: %v", err)
+	}
+	key := tokenSourceKey{
+		tokenURL:         config.TokenURL,
+		clientID:         config.ClientID,
+		clientSecretFrom: config.ClientSecretFrom,
+		scopes:           strings.Join(config.Scopes, " "),
+	}
+	secretHash := contentHash(clientSecret)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if entry, ok := c.entries[key]; ok && entry.secretHash == secretHash &&
+		entry.client.Timeout == client.Timeout && entry.client.Transport == client.Transport {
+		return entry.ts, nil
+	}
+	ccConfig := &clientcredentials.Config{
+		ClientID:     key.clientID,
+		ClientSecret: string(clientSecret),
+		TokenURL:     key.tokenURL,
+		Scopes:       config.Scopes,
+	}
+	// TokenSource returns a ReuseTokenSource, which refreshes the token
+	// automatically once it's about to expire, with the HTTP client of ctx.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	ts := ccConfig.TokenSource(ctx)
+	c.entries[key] = tokenSourceEntry{secretHash: secretHash, client: client, ts: ts}
+	return ts, nil
+}
+
+// webhookAuthorization returns the value of the Authorization header to send
+// with requests to the webhook, or "" if none is configured. OAuth2 tokens
+// are requested with client, the HTTP client the webhook is called with.
+func webhookAuthorization(webhook *v1alpha1.Webhook, client *http.Client) (string, error) {
+	auth := webhook.Authorization
+	if auth == nil {
+		return "", nil
+	}
+	if err := validateAuthorization(auth); err != nil {
+		return "", err
+	}
+
+	switch {
+	case auth.BearerTokenFrom != nil:
+		token, err := secrets.Get(auth.BearerTokenFrom)
+		if err != nil {
+			return "", fmt.Errorf("can't read bearer token: %v", err)
+		}
+		return "Bearer " + strings.TrimSpace(string(token)), nil
+	case auth.OAuth2 != nil:
+		ts, err := tokenSources.Get(auth.OAuth2, client)
+		if err != nil {
+			return "", err
+		}
+		token, err := ts.Token()
+		if err != nil {
+			return "", fmt.Errorf("can't get OAuth2 token: %v", err)
+		}
+		return token.Type() + " " + token.AccessToken, nil
+	case auth.ServiceAccountToken != nil:
+		token, err := serviceAccountTokens.Get(auth.ServiceAccountToken)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	return "", nil
+}
+
+func validateAuthorization(auth *v1alpha1.WebhookAuthorization) error {
+	specified := 0
+	for _, set := range []bool{auth.BearerTokenFrom != nil, auth.OAuth2 != nil, auth.ServiceAccountToken != nil} {
+		if set {
+			specified++
+		}
+	}
+	if specified > 1 {
+		return fmt.Errorf("invalid webhook config: must specify at most one of 'bearerTokenFrom', 'oauth2' and 'serviceAccountToken'")
+	}
+	// Credentials are sent to URLs of the controller's choosing, so they may
+	// only come from Secrets in allowed namespaces, like CA bundles.
+	if ref := auth.BearerTokenFrom; ref != nil {
+		if err := checkReferenceNamespace("secret", ref.Namespace, ref.Name); err != nil {
+			return fmt.Errorf("invalid webhook config: 'bearerTokenFrom': %v", err)
+		}
+	}
+	if auth.OAuth2 != nil {
+		ref := auth.OAuth2.ClientSecretFrom
+		if err := checkReferenceNamespace("secret", ref.Namespace, ref.Name); err != nil {
+			return fmt.Errorf("invalid webhook config: 'oauth2.clientSecretFrom': %v", err)
+		}
+	}
+	if projection := auth.ServiceAccountToken; projection != nil {
+		// Without an audience, the token would be one for the API server.
+		if projection.Audience == "" {
+			return fmt.Errorf("invalid webhook config: 'serviceAccountToken' must specify an 'audience'")
+		}
+		if err := checkTokenAudience(projection.Audience); err != nil {
+			return fmt.Errorf("invalid webhook config: 'serviceAccountToken': %v", err)
+		}
+	}
+	return nil
+}
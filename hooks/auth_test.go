@@ -0,0 +1,95 @@
+package hooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestWebhookAuthorization_emptyIfNotSpecified(t *testing.T) {
+	authorization, err := webhookAuthorization(&v1alpha1.Webhook{}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if authorization != "" {
+		t.Errorf("Expected no authorization, got: %q", authorization)
+	}
+}
+
+func TestWebhookAuthorization_errorIfBothSpecified(t *testing.T) {
+	ref := v1alpha1.SecretKeyReference{Name: "token", Namespace: "default", Key: "token"}
+	webhook := &v1alpha1.Webhook{
+		Authorization: &v1alpha1.WebhookAuthorization{
+			BearerTokenFrom: &ref,
+			OAuth2:          &v1alpha1.OAuth2ClientCredentials{ClientSecretFrom: ref},
+		},
+	}
+	if _, err := webhookAuthorization(webhook, http.DefaultClient); err == nil {
+		t.Error("Expected error when both bearerTokenFrom and oauth2 are set")
+	}
+}
+
+func TestWebhookAuthorization_errorIfServiceAccountNotConfigured(t *testing.T) {
+	webhook := &v1alpha1.Webhook{
+		Authorization: &v1alpha1.WebhookAuthorization{
+			ServiceAccountToken: &v1alpha1.ServiceAccountTokenProjection{Audience: "my-hook"},
+		},
+	}
+	if _, err := webhookAuthorization(webhook, http.DefaultClient); err == nil {
+		t.Error("Expected error when metacontroller's service account is not configured")
+	}
+}
+
+func TestWebhookAuthorization_oauth2UsesHookClient(t *testing.T) {
+	secrets.setClient(fakeSecretsGetter(func(name string) (*corev1.Secret, error) {
+		return &corev1.Secret{Data: map[string][]byte{"secret": []byte("s3cr3t")}}, nil
+	}))
+	defer secrets.setClient(nil)
+
+	// The token endpoint uses a certificate only the hook's client trusts.
+	tokenServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"t0k3n","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+	release := make(chan struct{})
+	hangingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hangingServer.Close()
+	defer close(release)
+
+	webhook := func(tokenURL string) *v1alpha1.Webhook {
+		return &v1alpha1.Webhook{
+			Authorization: &v1alpha1.WebhookAuthorization{
+				OAuth2: &v1alpha1.OAuth2ClientCredentials{
+					TokenURL:         tokenURL,
+					ClientID:         "metacontroller",
+					ClientSecretFrom: v1alpha1.SecretKeyReference{Name: "oauth2", Namespace: "default", Key: "secret"},
+				},
+			},
+		}
+	}
+
+	authorization, err := webhookAuthorization(webhook(tokenServer.URL), tokenServer.Client())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if authorization != "Bearer t0k3n" {
+		t.Errorf("Expected %q, got: %q", "Bearer t0k3n", authorization)
+	}
+
+	start := time.Now()
+	client := &http.Client{Timeout: 100 * time.Millisecond, Transport: http.DefaultTransport}
+	if _, err := webhookAuthorization(webhook(hangingServer.URL), client); err == nil {
+		t.Error("Expected error when the token endpoint doesn't respond")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the hook's timeout to apply to the token request, took %v", elapsed)
+	}
+}
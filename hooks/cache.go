@@ -0,0 +1,105 @@
+package hooks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// defaultCacheTTL is how long hook responses are cached for if the hook
+// doesn't say.
+const defaultCacheTTL = 5 * time.Minute
+
+// responses caches the responses of hooks that ask for it, keyed by a hash of
+// the hook and the request, so resyncs that don't change anything, such as
+// informer relists, don't call the hook again.
+var responses = &responseCache{entries: make(map[string]cachedResponse)}
+
+type responseCache struct {
+	mutex   sync.Mutex
+	entries map[string]cachedResponse
+	// swept is when expired entries were last removed.
+	swept time.Time
+}
+
+type cachedResponse struct {
+	data    []byte
+	expires time.Time
+}
+
+// Get returns the response cached for key, if it hasn't expired.
+func (c *responseCache) Get(key string, now time.Time) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// Put caches data for key until now+ttl. It also removes expired entries,
+// at most once a minute, so the cache doesn't keep growing.
+func (c *responseCache) Put(key string, data []byte, now time.Time, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if now.Sub(c.swept) >= time.Minute {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.swept = now
+	}
+	c.entries[key] = cachedResponse{data: data, expires: now.Add(ttl)}
+}
+
+// pressureField is the field of sync requests that tells hooks about the
+// pressure on the controller. It changes from one sync to the next, such as
+// with the time since the last successful sync, so it's left out of cache
+// keys, or requests would never hit the cache.
+const pressureField = "pressure"
+
+// cacheKey returns the key that the response of hook to request is cached
+// under. Since JSON objects are encoded with sorted keys, equal requests
+// have the same key.
+func cacheKey(hook *v1alpha1.Hook, request interface{}) (string, error) {
+	reqData, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("can't marshal request: %v", err)
+	}
+	// Numbers are kept as they are, so distinct large integers don't end up
+	// with the same key.
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(reqData))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err == nil {
+		delete(fields, pressureField)
+		request = fields
+	}
+	data, err := json.Marshal(struct {
+		Hook    *v1alpha1.Hook `json:"hook"`
+		Request interface{}    `json:"request"`
+	}{hook, request})
+	if err != nil {
+		return "", fmt.Errorf("can't marshal request: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cacheTTL returns how long the responses of hook are cached for.
+func cacheTTL(cache *v1alpha1.HookCache) time.Duration {
+	if cache.TTL == nil || cache.TTL.Duration <= 0 {
+		return defaultCacheTTL
+	}
+	return cache.TTL.Duration
+}
@@ -0,0 +1,68 @@
+package hooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestCallCached(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"value": "ok"}`))
+	}))
+	defer srv.Close()
+
+	hook := &v1alpha1.Hook{
+		Webhook: &v1alpha1.Webhook{URL: pointer.StringPtr(srv.URL)},
+		Cache:   &v1alpha1.HookCache{TTL: &metav1.Duration{Duration: time.Minute}},
+	}
+	call := func(request map[string]interface{}) {
+		var response struct {
+			Value string `json:"value"`
+		}
+		if err := Call(hook, request, &response); err != nil {
+			t.Fatalf("Call() = %v", err)
+		}
+		if response.Value != "ok" {
+			t.Errorf("response = %q, want %q", response.Value, "ok")
+		}
+	}
+
+	call(map[string]interface{}{"a": "1", "b": "2"})
+	call(map[string]interface{}{"b": "2", "a": "1"})
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("hook called %v times for the same request, want 1", got)
+	}
+	call(map[string]interface{}{"a": "2"})
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("hook called %v times after another request, want 2", got)
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	cache := &responseCache{entries: make(map[string]cachedResponse)}
+	now := time.Now()
+	cache.Put("a", []byte("a"), now, time.Minute)
+
+	if _, ok := cache.Get("a", now.Add(30*time.Second)); !ok {
+		t.Errorf("Get() missed before the TTL")
+	}
+	if _, ok := cache.Get("a", now.Add(time.Minute)); ok {
+		t.Errorf("Get() hit after the TTL")
+	}
+
+	// Expired entries are swept on a later Put.
+	cache.Put("b", []byte("b"), now.Add(2*time.Minute), time.Minute)
+	if _, ok := cache.entries["a"]; ok {
+		t.Errorf("expired entry wasn't swept")
+	}
+}
@@ -0,0 +1,42 @@
+package hooks
+
+import (
+	"sync"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// limits holds one semaphore per webhook URL with a maxInFlight limit, so
+// calls to the same webhook are limited across all controllers that use it.
+var limits = &limitCache{entries: make(map[string]chan struct{})}
+
+type limitCache struct {
+	mutex   sync.Mutex
+	entries map[string]chan struct{}
+}
+
+// Get returns the semaphore limiting calls to url to maxInFlight at a time.
+// If the limit of url changed, calls made under the previous limit are not
+// counted against the new one.
+func (c *limitCache) Get(url string, maxInFlight int) chan struct{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if sem, ok := c.entries[url]; ok && cap(sem) == maxInFlight {
+		return sem
+	}
+	sem := make(chan struct{}, maxInFlight)
+	c.entries[url] = sem
+	return sem
+}
+
+// acquireWebhook blocks until a call to the webhook at url may be sent,
+// and returns a function to call once the call is done.
+func acquireWebhook(webhook *v1alpha1.Webhook, url string) (release func()) {
+	if webhook.MaxInFlight == nil || *webhook.MaxInFlight <= 0 {
+		return func() {}
+	}
+	sem := limits.Get(url, int(*webhook.MaxInFlight))
+	sem <- struct{}{}
+	return func() { <-sem }
+}
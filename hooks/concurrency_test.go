@@ -0,0 +1,49 @@
+package hooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestCallWebhookMaxInFlight(t *testing.T) {
+	var inFlight, maxSeen int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			seen := atomic.LoadInt32(&maxSeen)
+			if n <= seen || atomic.CompareAndSwapInt32(&maxSeen, seen, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	webhook := &v1alpha1.Webhook{URL: pointer.StringPtr(srv.URL), MaxInFlight: pointer.Int32Ptr(2)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var response map[string]interface{}
+			if err := callWebhook(webhook, map[string]interface{}{}, &response); err != nil {
+				t.Errorf("callWebhook() = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxSeen != 2 {
+		t.Errorf("got %v concurrent calls, want 2", maxSeen)
+	}
+}
@@ -0,0 +1,91 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Error is an error a webhook reports in the JSON body of a non-200
+// response, to tell Metacontroller how to handle it:
+//
+//	{"code": "QuotaExceeded", "message": "...", "retryable": true, "requeueAfterSeconds": 60}
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int `json:"-"`
+
+	// Code is a short, machine-readable reason for the error.
+	Code string `json:"code,omitempty"`
+	// Message is a human-readable description of the error.
+	Message string `json:"message,omitempty"`
+	// Retryable is whether the call may succeed if it's retried. Defaults to
+	// true.
+	Retryable *bool `json:"retryable,omitempty"`
+	// RequeueAfterSeconds, if positive, is when to retry, instead of the
+	// usual exponential backoff.
+	RequeueAfterSeconds float64 `json:"requeueAfterSeconds,omitempty"`
+	// EventOnly asks for the error to only be reported in an event, without
+	// failing the sync.
+	EventOnly bool `json:"eventOnly,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("remote error: %s", e.Message)
+	}
+	return fmt.Sprintf("remote error: %s: %s", e.Code, e.Message)
+}
+
+// IsRetryable returns whether the call may succeed if it's retried.
+func (e *Error) IsRetryable() bool {
+	return e.Retryable == nil || *e.Retryable
+}
+
+// RequeueAfter returns when the hook asked to be retried, or zero for the
+// usual exponential backoff.
+func (e *Error) RequeueAfter() time.Duration {
+	if e.RequeueAfterSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(e.RequeueAfterSeconds * float64(time.Second))
+}
+
+// AsError returns the hook Error that err wraps, or nil if there's none.
+func AsError(err error) *Error {
+	var hookErr *Error
+	if errors.As(err, &hookErr) {
+		return hookErr
+	}
+	return nil
+}
+
+// crashError is an error calling a webhook that suggests the request made it
+// crash or hang: the call timed out or lost its connection, or the webhook
+// answered with a server error that it didn't describe.
+type crashError struct {
+	err error
+}
+
+func (e *crashError) Error() string {
+	return e.err.Error()
+}
+
+func (e *crashError) Unwrap() error {
+	return e.err
+}
+
+// IsCrash returns whether err is a webhook call that timed out or failed
+// without the webhook describing the error.
+func IsCrash(err error) bool {
+	var crashErr *crashError
+	return errors.As(err, &crashErr)
+}
+
+// isDialError returns whether err is a failure to connect to a webhook,
+// such as a refused connection or an unknown host, rather than a failure of
+// a request the webhook received.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,129 @@
+package hooks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestCallWebhook_errors(t *testing.T) {
+	table := []struct {
+		name          string
+		body          string
+		wantHookErr   bool
+		wantRetryable bool
+		wantRequeue   time.Duration
+		wantCrash     bool
+	}{
+		{
+			name:      "plain text",
+			body:      "internal error",
+			wantCrash: true,
+		},
+		{
+			name:      "unrelated JSON",
+			body:      `{"foo": "bar"}`,
+			wantCrash: true,
+		},
+		{
+			name:          "retryable by default",
+			body:          `{"code": "Busy", "message": "try again"}`,
+			wantHookErr:   true,
+			wantRetryable: true,
+		},
+		{
+			name:          "delayed retry",
+			body:          `{"code": "QuotaExceeded", "message": "later", "requeueAfterSeconds": 30}`,
+			wantHookErr:   true,
+			wantRetryable: true,
+			wantRequeue:   30 * time.Second,
+		},
+		{
+			name:        "permanent",
+			body:        `{"code": "InvalidSpec", "message": "bad replicas", "retryable": false}`,
+			wantHookErr: true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer srv.Close()
+
+			var response map[string]interface{}
+			err := callWebhook(&v1alpha1.Webhook{URL: &srv.URL}, map[string]interface{}{}, &response)
+			if err == nil {
+				t.Fatal("callWebhook() succeeded, want error")
+			}
+			if got := IsCrash(fmt.Errorf("sync hook failed: %w", err)); got != tc.wantCrash {
+				t.Errorf("IsCrash() = %v, want %v", got, tc.wantCrash)
+			}
+			hookErr := AsError(fmt.Errorf("sync hook failed: %w", err))
+			if gotHookErr := hookErr != nil; gotHookErr != tc.wantHookErr {
+				t.Fatalf("AsError() = %v, want hook error %v", hookErr, tc.wantHookErr)
+			}
+			if hookErr == nil {
+				return
+			}
+			if hookErr.StatusCode != http.StatusInternalServerError {
+				t.Errorf("StatusCode = %v, want %v", hookErr.StatusCode, http.StatusInternalServerError)
+			}
+			if got := hookErr.IsRetryable(); got != tc.wantRetryable {
+				t.Errorf("IsRetryable() = %v, want %v", got, tc.wantRetryable)
+			}
+			if got := hookErr.RequeueAfter(); got != tc.wantRequeue {
+				t.Errorf("RequeueAfter() = %v, want %v", got, tc.wantRequeue)
+			}
+		})
+	}
+}
+
+func TestCallWebhook_connectionErrors(t *testing.T) {
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer hung.Close()
+	timeout := &metav1.Duration{Duration: 50 * time.Millisecond}
+
+	refused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	refusedURL := refused.URL
+	refused.Close()
+
+	table := []struct {
+		name      string
+		webhook   *v1alpha1.Webhook
+		wantCrash bool
+	}{
+		{
+			name:      "timeout",
+			webhook:   &v1alpha1.Webhook{URL: &hung.URL, Timeout: timeout},
+			wantCrash: true,
+		},
+		{
+			name:    "connection refused",
+			webhook: &v1alpha1.Webhook{URL: &refusedURL},
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			var response map[string]interface{}
+			err := callWebhook(tc.webhook, map[string]interface{}{}, &response)
+			if err == nil {
+				t.Fatal("callWebhook() succeeded, want error")
+			}
+			if got := IsCrash(err); got != tc.wantCrash {
+				t.Errorf("IsCrash(%v) = %v, want %v", err, got, tc.wantCrash)
+			}
+		})
+	}
+}
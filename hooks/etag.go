@@ -0,0 +1,117 @@
+package hooks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// etagIdleTimeout is how long the last response of a webhook for an object is
+// kept without being used, after which the object is assumed to be gone.
+const etagIdleTimeout = time.Hour
+
+// etags holds the last response of webhooks that returned an ETag, for each
+// object they were called for, so they can answer 304 Not Modified when the
+// response would be the same.
+var etags = &etagCache{entries: make(map[string]*etagEntry)}
+
+type etagCache struct {
+	mutex   sync.Mutex
+	entries map[string]*etagEntry
+	// swept is when idle entries were last removed.
+	swept time.Time
+}
+
+type etagEntry struct {
+	etag     string
+	data     []byte
+	lastUsed time.Time
+}
+
+// Get returns the last response cached for key and its ETag.
+func (c *etagCache) Get(key string, now time.Time) (etag string, data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", nil
+	}
+	entry.lastUsed = now
+	return entry.etag, entry.data
+}
+
+// Put caches the response data with the given ETag for key, or forgets the
+// last response if etag is empty. It also removes idle entries, at most once
+// a minute.
+func (c *etagCache) Put(key, etag string, data []byte, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if now.Sub(c.swept) >= time.Minute {
+		for k, entry := range c.entries {
+			if now.Sub(entry.lastUsed) >= etagIdleTimeout {
+				delete(c.entries, k)
+			}
+		}
+		c.swept = now
+	}
+	if etag == "" {
+		delete(c.entries, key)
+		return
+	}
+	c.entries[key] = &etagEntry{etag: etag, data: data, lastUsed: now}
+}
+
+// etagged wraps the response of a webhook call that may be answered with
+// 304 Not Modified.
+type etagged struct {
+	// IfNoneMatch is the ETag of the last response, if any.
+	IfNoneMatch string
+	// ETag is the ETag of the response, if the webhook returned one.
+	ETag string
+	// NotModified is set if the webhook answered 304 Not Modified, in which
+	// case Response is left alone.
+	NotModified bool
+	// TraceParent is the W3C traceparent header to send, if any.
+	TraceParent string
+	Response    interface{}
+}
+
+// callWebhookWithETag calls webhook on behalf of the object with the given key,
+// sending the ETag of its last response, and traceParent if it isn't empty.
+// It returns the response, validated against validator if it's not nil, or
+// the last response if the webhook answered 304 Not Modified, which isn't
+// validated again.
+func callWebhookWithETag(webhook *v1alpha1.Webhook, objectKey, traceParent string, request interface{}, validator ResponseValidator) ([]byte, error) {
+	url, err := webhookURL(webhook)
+	if err != nil {
+		return nil, err
+	}
+	key := url + " " + objectKey
+	lastETag, lastData := etags.Get(key, time.Now())
+
+	var raw interface{}
+	response := &etagged{IfNoneMatch: lastETag, TraceParent: traceParent, Response: &raw}
+	if err := callWebhook(webhook, request, response); err != nil {
+		return nil, err
+	}
+	if response.NotModified {
+		klog.V(4).InfoS("Webhook response not modified", "url", url, "etag", lastETag)
+		return lastData, nil
+	}
+	data, err := validateResponse(raw, validator)
+	if err != nil {
+		return nil, err
+	}
+	etags.Put(key, response.ETag, data, time.Now())
+	return data, nil
+}
+
+// errNotModifiedWithoutETag is returned if a webhook answers 304 Not
+// Modified although it wasn't sent an ETag.
+var errNotModifiedWithoutETag = fmt.Errorf("remote error: 304 Not Modified, but no ETag was sent")
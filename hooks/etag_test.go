@@ -0,0 +1,89 @@
+package hooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestCallForObjectETag(t *testing.T) {
+	var ifNoneMatch []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"value": "ok"}`))
+	}))
+	defer srv.Close()
+
+	hook := &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{URL: &srv.URL}}
+	call := func(uid types.UID) {
+		obj := &metav1.ObjectMeta{UID: uid}
+		var response struct {
+			Value string `json:"value"`
+		}
+		if err := CallForObject(hook, obj, "", map[string]interface{}{}, &response); err != nil {
+			t.Fatalf("CallForObject() = %v", err)
+		}
+		if response.Value != "ok" {
+			t.Errorf("response = %q, want %q", response.Value, "ok")
+		}
+	}
+
+	call("a")
+	call("a")
+	call("b")
+	want := []string{"", `"v1"`, ""}
+	if len(ifNoneMatch) != len(want) {
+		t.Fatalf("If-None-Match headers = %q, want %q", ifNoneMatch, want)
+	}
+	for i := range want {
+		if ifNoneMatch[i] != want[i] {
+			t.Errorf("If-None-Match headers = %q, want %q", ifNoneMatch, want)
+			break
+		}
+	}
+}
+
+func TestCallWebhookNotModifiedWithoutETag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	var response map[string]interface{}
+	if err := callWebhook(&v1alpha1.Webhook{URL: &srv.URL}, map[string]interface{}{}, &response); err == nil {
+		t.Error("callWebhook() succeeded, want error")
+	}
+}
+
+func TestCallForObjectTraceParent(t *testing.T) {
+	var traceParents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParents = append(traceParents, r.Header.Get("traceparent"))
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	hook := &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{URL: &srv.URL}}
+	obj := &metav1.ObjectMeta{UID: "a"}
+	traceParent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	for _, tp := range []string{traceParent, ""} {
+		var response map[string]interface{}
+		if err := CallForObject(hook, obj, tp, map[string]interface{}{}, &response); err != nil {
+			t.Fatalf("CallForObject() = %v", err)
+		}
+	}
+	want := []string{traceParent, ""}
+	if len(traceParents) != len(want) || traceParents[0] != want[0] || traceParents[1] != want[1] {
+		t.Errorf("traceparent headers = %q, want %q", traceParents, want)
+	}
+}
@@ -0,0 +1,320 @@
+package hooks
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// chartCacheTTL is how long a packaged chart is reused before it's read
+// again, so that updated ConfigMaps and moved tags are eventually picked up.
+const chartCacheTTL = time.Minute
+
+// maxChartSize is the largest packaged chart that is read.
+const maxChartSize = 20 << 20
+
+// helmHookAnnotation marks the objects of a chart that Helm runs as hooks.
+const helmHookAnnotation = "helm.sh/hook"
+
+var charts = &chartCache{entries: make(map[chartCacheKey]chartCacheEntry)}
+
+// RenderChart renders the chart selected by spec for parent, and returns the
+// objects it defines, to be used as the desired children of parent.
+// Objects that Helm would run as hooks, and the CRDs of the chart, are left
+// out, since they aren't part of the release.
+func RenderChart(spec *v1alpha1.HelmChart, parent *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	archive, err := charts.Get(&spec.Chart)
+	if err != nil {
+		return nil, err
+	}
+	// The chart is loaded again for each parent, since rendering may modify
+	// the values it holds.
+	chrt, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("can't load chart: %v", err)
+	}
+
+	data := map[string]interface{}{"parent": parent.UnstructuredContent()}
+	values, err := chartValues(spec, data)
+	if err != nil {
+		return nil, err
+	}
+	releaseName := parent.GetName()
+	if spec.ReleaseName != "" {
+		if releaseName, err = executeTemplate("releaseName", spec.ReleaseName, data); err != nil {
+			return nil, err
+		}
+	}
+	options := chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: parent.GetNamespace(),
+		Revision:  1,
+		IsInstall: true,
+	}
+	renderValues, err := chartutil.ToRenderValues(chrt, values, options, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't compute values of chart %v: %v", chrt.Name(), err)
+	}
+	files, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return nil, fmt.Errorf("can't render chart %v: %v", chrt.Name(), err)
+	}
+	return chartObjects(files)
+}
+
+// ValidateHelmChart checks the parts of spec that can be checked without
+// reading the chart.
+func ValidateHelmChart(spec *v1alpha1.HelmChart) error {
+	source := &spec.Chart
+	if (source.OCI == "") == (source.ConfigMapRef == nil) {
+		return fmt.Errorf("invalid helm chart: exactly one of 'oci' and 'configMapRef' must be set")
+	}
+	if source.OCI != "" {
+		if _, err := parseOCIReference(source.OCI); err != nil {
+			return fmt.Errorf("invalid helm chart: %v", err)
+		}
+	}
+	if ref := source.ConfigMapRef; ref != nil {
+		if err := checkReferenceNamespace("configmap", ref.Namespace, ref.Name); err != nil {
+			return fmt.Errorf("invalid helm chart: 'configMapRef': %v", err)
+		}
+	}
+	if _, err := parseTemplate("releaseName", spec.ReleaseName); err != nil {
+		return fmt.Errorf("invalid helm chart: %v", err)
+	}
+	if _, err := chartValues(spec, nil); err != nil {
+		return fmt.Errorf("invalid helm chart: %v", err)
+	}
+	return nil
+}
+
+// chartValues returns the values of spec, with each string evaluated as a
+// template with data. If data is nil, templates are only parsed.
+func chartValues(spec *v1alpha1.HelmChart, data map[string]interface{}) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if spec.Values != nil && len(spec.Values.Raw) > 0 {
+		if err := json.Unmarshal(spec.Values.Raw, &values); err != nil {
+			return nil, fmt.Errorf("can't unmarshal values: %v", err)
+		}
+	}
+	rendered, err := renderValue("values", values, data)
+	if err != nil {
+		return nil, err
+	}
+	return rendered.(map[string]interface{}), nil
+}
+
+func renderValue(name string, value interface{}, data map[string]interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			rendered, err := renderValue(name+"."+key, item, data)
+			if err != nil {
+				return nil, err
+			}
+			value[key] = rendered
+		}
+		return value, nil
+	case []interface{}:
+		for i, item := range value {
+			rendered, err := renderValue(fmt.Sprintf("%s[%d]", name, i), item, data)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = rendered
+		}
+		return value, nil
+	case string:
+		if !strings.Contains(value, "{{") {
+			return value, nil
+		}
+		if data == nil {
+			_, err := parseTemplate(name, value)
+			return value, err
+		}
+		rendered, err := executeTemplate(name, value, data)
+		if err != nil {
+			return nil, err
+		}
+		// Templates can only produce strings, so pass those that look like
+		// numbers or booleans to the chart as such.
+		if i, err := strconv.ParseInt(rendered, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(rendered, 64); err == nil {
+			return f, nil
+		}
+		if b, err := strconv.ParseBool(rendered); err == nil {
+			return b, nil
+		}
+		return rendered, nil
+	default:
+		return value, nil
+	}
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse template %v: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+func executeTemplate(name, text string, data map[string]interface{}) (string, error) {
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("can't evaluate template %v: %v", name, err)
+	}
+	return buf.String(), nil
+}
+
+// chartObjects decodes the objects of the rendered manifests in files, in the
+// order of their file names.
+func chartObjects(files map[string]string) ([]*unstructured.Unstructured, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		switch path.Ext(name) {
+		case ".yaml", ".yml", ".json":
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var objects []*unstructured.Unstructured
+	for _, name := range names {
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(files[name])))
+		for {
+			doc, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("can't read %v: %v", name, err)
+			}
+			// Decode through JSON, so numbers are integers where possible,
+			// like in objects read from the API server.
+			jsonDoc, err := utilyaml.ToJSON(doc)
+			if err != nil {
+				return nil, fmt.Errorf("can't decode %v: %v", name, err)
+			}
+			var content map[string]interface{}
+			if err := json.Unmarshal(jsonDoc, &content); err != nil {
+				return nil, fmt.Errorf("can't decode %v: %v", name, err)
+			}
+			if len(content) == 0 {
+				// Empty document, e.g. a template disabled by values.
+				continue
+			}
+			obj := &unstructured.Unstructured{Object: content}
+			if _, ok := obj.GetAnnotations()[helmHookAnnotation]; ok {
+				continue
+			}
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
+
+type chartCacheKey struct {
+	oci       string
+	configMap v1alpha1.ConfigMapKeyReference
+}
+
+type chartCacheEntry struct {
+	archive []byte
+	expires time.Time
+}
+
+// chartCache holds packaged charts, so they're not pulled on every sync.
+type chartCache struct {
+	mutex   sync.Mutex
+	client  typedcorev1.ConfigMapsGetter
+	entries map[chartCacheKey]chartCacheEntry
+}
+
+func (c *chartCache) setClient(client typedcorev1.ConfigMapsGetter) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.client = client
+	c.entries = make(map[chartCacheKey]chartCacheEntry)
+}
+
+// Get returns the packaged chart selected by source.
+// The chart is read without holding the lock, so lookups of other charts
+// don't wait for the registry or the API server.
+func (c *chartCache) Get(source *v1alpha1.HelmChartSource) ([]byte, error) {
+	key := chartCacheKey{oci: source.OCI}
+	if source.ConfigMapRef != nil {
+		key.configMap = *source.ConfigMapRef
+	}
+
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	client := c.client
+	c.mutex.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.archive, nil
+	}
+	var archive []byte
+	var err error
+	switch {
+	case source.OCI != "":
+		archive, err = pullChart(source.OCI)
+	case source.ConfigMapRef != nil:
+		archive, err = readChartConfigMap(client, source.ConfigMapRef)
+	default:
+		err = fmt.Errorf("invalid helm chart: exactly one of 'oci' and 'configMapRef' must be set")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = chartCacheEntry{archive: archive, expires: time.Now().Add(chartCacheTTL)}
+	return archive, nil
+}
+
+func readChartConfigMap(client typedcorev1.ConfigMapsGetter, ref *v1alpha1.ConfigMapKeyReference) ([]byte, error) {
+	if err := checkReferenceNamespace("configmap", ref.Namespace, ref.Name); err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, fmt.Errorf("can't read configmap %v/%v: hook client not initialized", ref.Namespace, ref.Name)
+	}
+	configMap, err := client.ConfigMaps(ref.Namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get configmap %v/%v: %v", ref.Namespace, ref.Name, err)
+	}
+	archive, ok := configMap.BinaryData[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("configmap %v/%v has no binary key %q", ref.Namespace, ref.Name, ref.Key)
+	}
+	return archive, nil
+}
@@ -0,0 +1,242 @@
+package hooks
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// testChart returns a packaged chart with one ConfigMap, a hook and a
+// disabled template.
+func testChart(t *testing.T) []byte {
+	files := map[string]string{
+		"mychart/Chart.yaml":  "apiVersion: v2\nname: mychart\nversion: 0.1.0\n",
+		"mychart/values.yaml": "replicas: 1\ncolor: blue\nextra: false\n",
+		"mychart/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-config
+  namespace: {{ .Release.Namespace }}
+data:
+  replicas: {{ .Values.replicas | quote }}
+  color: {{ .Values.color }}
+`,
+		"mychart/templates/extra.yaml": `{{- if .Values.extra }}
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-extra
+{{- end }}
+`,
+		"mychart/templates/test.yaml": `apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Release.Name }}-test
+  annotations:
+    helm.sh/hook: test
+`,
+		"mychart/templates/NOTES.txt": "Installed {{ .Release.Name }}.\n",
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testParent() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Redis",
+		"metadata":   map[string]interface{}{"name": "cache", "namespace": "default"},
+		"spec":       map[string]interface{}{"replicas": int64(3), "color": "red"},
+	}}
+}
+
+func TestRenderChart(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "charts", Namespace: "default"},
+		BinaryData: map[string][]byte{"mychart.tgz": testChart(t)},
+	})
+	charts.setClient(client.CoreV1())
+	defer charts.setClient(nil)
+
+	spec := &v1alpha1.HelmChart{
+		Chart: v1alpha1.HelmChartSource{
+			ConfigMapRef: &v1alpha1.ConfigMapKeyReference{Name: "charts", Namespace: "default", Key: "mychart.tgz"},
+		},
+		Values:      &runtime.RawExtension{Raw: []byte(`{"replicas": "{{ .parent.spec.replicas }}", "color": "{{ .parent.spec.color }}"}`)},
+		ReleaseName: "{{ .parent.metadata.name }}-redis",
+	}
+	got, err := RenderChart(spec, testParent())
+	if err != nil {
+		t.Fatalf("RenderChart() = %v", err)
+	}
+
+	want := []*unstructured.Unstructured{{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "cache-redis-config", "namespace": "default"},
+		"data":       map[string]interface{}{"replicas": "3", "color": "red"},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RenderChart() = %v, want %v", got, want)
+	}
+}
+
+func TestRenderChart_missingField(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "charts", Namespace: "default"},
+		BinaryData: map[string][]byte{"mychart.tgz": testChart(t)},
+	})
+	charts.setClient(client.CoreV1())
+	defer charts.setClient(nil)
+
+	spec := &v1alpha1.HelmChart{
+		Chart: v1alpha1.HelmChartSource{
+			ConfigMapRef: &v1alpha1.ConfigMapKeyReference{Name: "charts", Namespace: "default", Key: "mychart.tgz"},
+		},
+		Values: &runtime.RawExtension{Raw: []byte(`{"replicas": "{{ .parent.spec.size }}"}`)},
+	}
+	if _, err := RenderChart(spec, testParent()); err == nil {
+		t.Errorf("RenderChart() = nil, want error for missing field")
+	}
+}
+
+func TestPullChart(t *testing.T) {
+	archive := testChart(t)
+	sum := sha256.Sum256(archive)
+	layerDigest := "sha256:" + hex.EncodeToString(sum[:])
+	manifest := fmt.Sprintf(`{"layers": [{"mediaType": %q, "digest": %q}]}`, helmChartMediaType, layerDigest)
+	sum = sha256.Sum256([]byte(manifest))
+	manifestDigest := "sha256:" + hex.EncodeToString(sum[:])
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:charts/mychart:pull" {
+				t.Errorf("got token request for scope %q", r.URL.Query().Get("scope"))
+			}
+			w.Write([]byte(`{"token": "anonymous"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:charts/mychart:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/charts/mychart/manifests/0.1.0", "/v2/charts/mychart/manifests/" + manifestDigest:
+			w.Write([]byte(manifest))
+		case "/v2/charts/mychart/blobs/" + layerDigest:
+			w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	defaultClient := registryClient
+	registryClient = srv.Client()
+	defer func() { registryClient = defaultClient }()
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	table := []struct {
+		name      string
+		reference string
+		wantErr   bool
+	}{
+		{name: "tag", reference: "oci://" + host + "/charts/mychart:0.1.0"},
+		{name: "digest", reference: host + "/charts/mychart@" + manifestDigest},
+		{name: "wrong digest", reference: host + "/charts/mychart@" + layerDigest, wantErr: true},
+		{name: "missing tag", reference: host + "/charts/other:0.1.0", wantErr: true},
+	}
+	for _, tc := range table {
+		got, err := pullChart(tc.reference)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%v: pullChart() = nil, want error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: pullChart() = %v", tc.name, err)
+			continue
+		}
+		if !bytes.Equal(got, archive) {
+			t.Errorf("%v: pullChart() returned %d bytes, want the chart", tc.name, len(got))
+		}
+	}
+}
+
+func TestValidateHelmChart(t *testing.T) {
+	table := []struct {
+		name    string
+		spec    *v1alpha1.HelmChart
+		wantErr bool
+	}{
+		{
+			name: "OCI chart",
+			spec: &v1alpha1.HelmChart{Chart: v1alpha1.HelmChartSource{OCI: "oci://registry.example.com/charts/redis:17.3.0"}},
+		},
+		{
+			name:    "OCI chart without tag",
+			spec:    &v1alpha1.HelmChart{Chart: v1alpha1.HelmChartSource{OCI: "oci://registry.example.com/charts/redis"}},
+			wantErr: true,
+		},
+		{
+			name:    "no chart",
+			spec:    &v1alpha1.HelmChart{},
+			wantErr: true,
+		},
+		{
+			name: "ConfigMap in other namespace",
+			spec: &v1alpha1.HelmChart{Chart: v1alpha1.HelmChartSource{
+				ConfigMapRef: &v1alpha1.ConfigMapKeyReference{Name: "charts", Namespace: "kube-system", Key: "redis.tgz"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "invalid values template",
+			spec: &v1alpha1.HelmChart{
+				Chart:  v1alpha1.HelmChartSource{OCI: "registry.example.com/charts/redis:17.3.0"},
+				Values: &runtime.RawExtension{Raw: []byte(`{"replicas": "{{ .parent.spec.replicas"}`)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range table {
+		err := ValidateHelmChart(tc.spec)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%v: ValidateHelmChart() = %v, want error: %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -17,14 +17,95 @@ limitations under the License.
 package hooks
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
+
+	utiljson "k8s.io/apimachinery/pkg/util/json"
 
 	"metacontroller.io/apis/metacontroller/v1alpha1"
 )
 
 func Call(hook *v1alpha1.Hook, request interface{}, response interface{}) error {
+	return callHook(hook, "", "", request, response)
+}
+
+// callHook calls hook. If objectKey isn't empty, it identifies the object the
+// hook is called for, and webhooks may answer 304 Not Modified to a request
+// that carries the ETag of their last response for it. They're also sent
+// traceParent, if it isn't empty.
+func callHook(hook *v1alpha1.Hook, objectKey, traceParent string, request interface{}, response interface{}) error {
+	var key string
+	if hook.Cache != nil {
+		var err error
+		key, err = cacheKey(hook, request)
+		if err != nil {
+			return err
+		}
+		if data, ok := responses.Get(key, time.Now()); ok {
+			recordCacheLookup(true)
+			if err := utiljson.Unmarshal(data, response); err != nil {
+				return fmt.Errorf("can't unmarshal response: %v", err)
+			}
+			return nil
+		}
+		recordCacheLookup(false)
+	}
+
+	validator, _ := response.(ResponseValidator)
+	useETag := objectKey != "" && hook.Webhook != nil
+	if validator == nil && hook.Cache == nil && !useETag {
+		return call(hook, request, response)
+	}
+
+	var data []byte
+	var err error
+	if useETag {
+		data, err = callWebhookWithETag(hook.Webhook, objectKey, traceParent, request, validator)
+	} else {
+		var raw interface{}
+		if err := call(hook, request, &raw); err != nil {
+			return err
+		}
+		data, err = validateResponse(raw, validator)
+	}
+	if err != nil {
+		return err
+	}
+	if hook.Cache != nil {
+		responses.Put(key, data, time.Now(), cacheTTL(hook.Cache))
+	}
+	if err := utiljson.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("can't unmarshal response: %v", err)
+	}
+	return nil
+}
+
+// validateResponse checks the raw response against the schema of validator,
+// if it's not nil, and returns it encoded. The response is checked before it's
+// decoded, since decoding errors don't say which field is wrong.
+func validateResponse(raw interface{}, validator ResponseValidator) ([]byte, error) {
+	if validator != nil {
+		if err := validator.ResponseSchema().Validate(raw); err != nil {
+			return nil, fmt.Errorf("invalid response: %v", err)
+		}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("can't marshal response: %v", err)
+	}
+	return data, nil
+}
+
+func call(hook *v1alpha1.Hook, request interface{}, response interface{}) error {
 	if hook.Webhook != nil {
 		return callWebhook(hook.Webhook, request, response)
 	}
+	if hook.OPAServer != nil {
+		return callOPAServer(hook.OPAServer, request, response)
+	}
+	if hook.Starlark != nil {
+		return callStarlark(hook.Starlark, request, response)
+	}
 	return fmt.Errorf("hook spec not defined")
 }
@@ -0,0 +1,49 @@
+package hooks
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var webhookCalls = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Namespace:      "metacontroller",
+		Name:           "webhook_calls_total",
+		Help:           "Number of webhook calls, by URL and result (success or error).",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"url", "result"},
+)
+
+var hookCacheLookups = metrics.NewCounterVec(
+	&metrics.CounterOpts{
+		Namespace:      "metacontroller",
+		Name:           "hook_cache_lookups_total",
+		Help:           "Number of lookups of cached hook responses, by result (hit or miss).",
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"result"},
+)
+
+func init() {
+	legacyregistry.MustRegister(webhookCalls)
+	legacyregistry.MustRegister(hookCacheLookups)
+}
+
+// recordWebhookCall counts a call to the webhook at url that returned err.
+func recordWebhookCall(url string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	webhookCalls.WithLabelValues(url, result).Inc()
+}
+
+// recordCacheLookup counts a lookup of a cached hook response.
+func recordCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	hookCacheLookups.WithLabelValues(result).Inc()
+}
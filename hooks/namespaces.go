@@ -0,0 +1,56 @@
+package hooks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// inClusterNamespaceFile holds the namespace metacontroller runs in, when it
+// runs in a Pod.
+const inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// referenceNamespaces are the namespaces controllers may reference objects in
+// for their hooks. Metacontroller reads such objects with its own, broad
+// permissions, and sends their contents to URLs chosen by the controller, so
+// they're limited to namespaces the operator trusts controller authors with.
+var referenceNamespaces = sets.NewString()
+
+// ParseReferenceNamespaces parses the comma-separated --hook-reference-namespaces
+// flag. If it's empty, only the namespace metacontroller runs in is allowed,
+// or none if that's unknown, such as when it runs outside of the cluster.
+func ParseReferenceNamespaces(flag string) []string {
+	if namespaces := splitList(flag); len(namespaces) > 0 {
+		return namespaces
+	}
+	data, err := ioutil.ReadFile(inClusterNamespaceFile)
+	if err != nil {
+		return nil
+	}
+	if namespace := strings.TrimSpace(string(data)); namespace != "" {
+		return []string{namespace}
+	}
+	return nil
+}
+
+// checkReferenceNamespace returns an error unless hooks may reference objects
+// in namespace.
+func checkReferenceNamespace(kind, namespace, name string) error {
+	if !referenceNamespaces.Has(namespace) {
+		return fmt.Errorf("can't reference %v %v/%v: namespace %q isn't allowed by --hook-reference-namespaces", kind, namespace, name, namespace)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(flag string) []string {
+	var items []string
+	for _, item := range strings.Split(flag, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,215 @@
+package hooks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/json"
+)
+
+const (
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	// helmChartMediaType is the media type of the layer holding the packaged
+	// chart. Charts pushed by Helm before 3.7 use helmLegacyChartMediaType.
+	helmChartMediaType       = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	helmLegacyChartMediaType = "application/tar+gzip"
+
+	// maxManifestSize is the largest image manifest that is read.
+	maxManifestSize = 1 << 20
+)
+
+// registryClient is used to pull charts from OCI registries.
+var registryClient = &http.Client{Timeout: 30 * time.Second}
+
+var challengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ociReference is a parsed reference to an artifact in an OCI registry.
+type ociReference struct {
+	registry   string
+	repository string
+	// reference is either a tag or a digest.
+	reference string
+}
+
+// parseOCIReference parses a reference such as
+// registry.example.com/charts/redis:17.3.0, optionally prefixed by oci://.
+// The tag (or digest) is required, so every sync renders the same chart.
+func parseOCIReference(reference string) (*ociReference, error) {
+	name := strings.TrimPrefix(reference, "oci://")
+	slash := strings.Index(name, "/")
+	if slash <= 0 {
+		return nil, fmt.Errorf("invalid OCI reference %q: must start with a registry host", reference)
+	}
+	ref := &ociReference{registry: name[:slash]}
+	repository := name[slash+1:]
+	if at := strings.LastIndex(repository, "@"); at >= 0 {
+		ref.repository, ref.reference = repository[:at], repository[at+1:]
+		if !strings.HasPrefix(ref.reference, "sha256:") {
+			return nil, fmt.Errorf("invalid OCI reference %q: only sha256 digests are supported", reference)
+		}
+	} else if colon := strings.LastIndex(repository, ":"); colon > strings.LastIndex(repository, "/") {
+		ref.repository, ref.reference = repository[:colon], repository[colon+1:]
+	}
+	if ref.repository == "" || ref.reference == "" {
+		return nil, fmt.Errorf("invalid OCI reference %q: must include a repository and a tag or digest", reference)
+	}
+	return ref, nil
+}
+
+// pullChart returns the packaged chart pushed to an OCI registry at reference.
+// Only registries that allow anonymous pulls are supported.
+func pullChart(reference string) ([]byte, error) {
+	ref, err := parseOCIReference(reference)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ref.get("manifests/"+ref.reference, ociManifestMediaType, maxManifestSize)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(ref.reference, "sha256:") {
+		if err := checkDigest(ref.reference, body); err != nil {
+			return nil, fmt.Errorf("can't pull chart %v: %v", reference, err)
+		}
+	}
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("can't unmarshal manifest of %v: %v", reference, err)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != helmChartMediaType && layer.MediaType != helmLegacyChartMediaType {
+			continue
+		}
+		archive, err := ref.get("blobs/"+layer.Digest, "", maxChartSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDigest(layer.Digest, archive); err != nil {
+			return nil, fmt.Errorf("can't pull chart %v: %v", reference, err)
+		}
+		return archive, nil
+	}
+	return nil, fmt.Errorf("%v isn't a helm chart: its manifest has no chart layer", reference)
+}
+
+// get reads a manifest or blob of the repository, requesting an anonymous
+// token if the registry asks for one.
+func (r *ociReference) get(path, accept string, limit int64) ([]byte, error) {
+	location := fmt.Sprintf("https://%s/v2/%s/%s", r.registry, r.repository, path)
+	resp, err := r.do(location, accept, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		token, err := registryToken(resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, fmt.Errorf("can't authenticate to %v: %v", r.registry, err)
+		}
+		if resp, err = r.do(location, accept, token); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can't get %v: %v", location, resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("can't read %v: %v", location, err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("can't read %v: larger than %d bytes", location, limit)
+	}
+	return body, nil
+}
+
+func (r *ociReference) do(location, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't create request: %v", err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := registryClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http error: %v", err)
+	}
+	return resp, nil
+}
+
+// registryToken requests an anonymous token from the authorization server
+// named by a Bearer challenge of a registry.
+func registryToken(challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported challenge %q", challenge)
+	}
+	params := make(map[string]string)
+	for _, match := range challengeParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid realm in challenge %q", challenge)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if value, ok := params[key]; ok {
+			query.Set(key, value)
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	resp, err := registryClient.Get(realm.String())
+	if err != nil {
+		return "", fmt.Errorf("http error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %v", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return "", fmt.Errorf("can't read token response: %v", err)
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return "", fmt.Errorf("can't unmarshal token response: %v", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("token response has no token")
+}
+
+// checkDigest checks that content matches a sha256 digest.
+func checkDigest(digest string, content []byte) error {
+	sum := sha256.Sum256(content)
+	if got := "sha256:" + hex.EncodeToString(sum[:]); got != digest {
+		return fmt.Errorf("digest mismatch: got %v, want %v", got, digest)
+	}
+	return nil
+}
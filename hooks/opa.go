@@ -0,0 +1,40 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// opaRequest is the body of a request to the data API of an OPA server.
+type opaRequest struct {
+	Input interface{} `json:"input"`
+}
+
+// opaResponse is the body of a response from the data API of an OPA server.
+// Result is missing if the decision is undefined.
+type opaResponse struct {
+	Result *json.RawMessage `json:"result"`
+}
+
+// callOPAServer evaluates a policy decision through the data API of an OPA server,
+// at the URL of webhook (e.g. http://opa:8181/v1/data/example/sync).
+// The policies themselves are loaded by OPA, for example from bundles.
+func callOPAServer(webhook *v1alpha1.Webhook, request interface{}, response interface{}) error {
+	var decision opaResponse
+	if err := callWebhook(webhook, &opaRequest{Input: request}, &decision); err != nil {
+		return err
+	}
+	if decision.Result == nil {
+		return fmt.Errorf("policy decision is undefined")
+	}
+	// Decode the decision like any other hook response, so numbers in it
+	// are integers where possible.
+	if err := utiljson.Unmarshal(*decision.Result, response); err != nil {
+		return fmt.Errorf("can't unmarshal policy decision: %v", err)
+	}
+	return nil
+}
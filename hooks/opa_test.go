@@ -0,0 +1,67 @@
+package hooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestCallOPAServer(t *testing.T) {
+	table := []struct {
+		name     string
+		decision string
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "defined",
+			decision: `{"result": {"status": {"replicas": 2}}}`,
+			want:     map[string]interface{}{"status": map[string]interface{}{"replicas": int64(2)}},
+		},
+		{
+			name:     "undefined",
+			decision: `{}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range table {
+		var input map[string]interface{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("%v: can't decode request: %v", tc.name, err)
+			}
+			input, _ = body["input"].(map[string]interface{})
+			w.Write([]byte(tc.decision))
+		}))
+
+		hook := &v1alpha1.Hook{OPAServer: &v1alpha1.Webhook{URL: pointer.StringPtr(srv.URL + "/v1/data/example/sync")}}
+		var got map[string]interface{}
+		err := Call(hook, map[string]interface{}{"parent": "test"}, &got)
+		srv.Close()
+
+		if input["parent"] != "test" {
+			t.Errorf("%v: got input %v, want the hook request", tc.name, input)
+		}
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%v: Call() = nil, want error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: Call() = %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: Call() response = %#v, want %#v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package hooks
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// URLOverrideAnnotation can be set on a parent to call the webhooks of its
+// controller at another URL, such as a canary instance of the hook server.
+// Each webhook only honors URLs listed in its allowedURLOverrides.
+const URLOverrideAnnotation = "metacontroller.k8s.io/hook-url"
+
+// ForObject returns the hook to call on behalf of obj: hook itself, or a
+// copy of it calling the URL obj overrides it with, or the canary URL if obj
+// falls in the canary share of the webhook.
+func ForObject(hook *v1alpha1.Hook, obj metav1.Object) (*v1alpha1.Hook, error) {
+	if hook == nil || hook.Webhook == nil || obj == nil {
+		return hook, nil
+	}
+	if url, ok := obj.GetAnnotations()[URLOverrideAnnotation]; ok {
+		allowed := false
+		for _, allowedURL := range hook.Webhook.AllowedURLOverrides {
+			if url == allowedURL {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("hook URL override %q in annotation %v is not in allowedURLOverrides", url, URLOverrideAnnotation)
+		}
+		return withURL(hook, url), nil
+	}
+	if canary := hook.Webhook.Canary; canary != nil {
+		if canary.Weight < 0 || canary.Weight > 100 {
+			return nil, fmt.Errorf("invalid webhook canary weight %v: must be between 0 and 100", canary.Weight)
+		}
+		if canaryBucket(obj) < canary.Weight {
+			return withURL(hook, canary.URL), nil
+		}
+	}
+	return hook, nil
+}
+
+// canaryBucket sorts obj, by its UID, in one of 100 buckets, so it's always
+// sent to the same side of a canary split. Raising the weight only moves
+// objects to the canary.
+func canaryBucket(obj metav1.Object) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(obj.GetUID()))
+	return int32(h.Sum32() % 100)
+}
+
+// withURL returns a copy of hook that calls url.
+func withURL(hook *v1alpha1.Hook, url string) *v1alpha1.Hook {
+	webhook := *hook.Webhook
+	webhook.URL = &url
+	webhook.Service = nil
+	webhook.Path = nil
+	webhook.Canary = nil
+	return &v1alpha1.Hook{Webhook: &webhook}
+}
+
+// CallForObject calls the hook returned by ForObject for obj. Webhooks that
+// return an ETag may answer the next call for obj with 304 Not Modified.
+// If traceParent isn't empty, it's sent to webhooks as the W3C traceparent
+// header, so their spans join the trace of the call.
+func CallForObject(hook *v1alpha1.Hook, obj metav1.Object, traceParent string, request interface{}, response interface{}) error {
+	hook, err := ForObject(hook, obj)
+	if err != nil {
+		return err
+	}
+	return callHook(hook, string(obj.GetUID()), traceParent, request, response)
+}
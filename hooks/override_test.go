@@ -0,0 +1,104 @@
+package hooks
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestForObject(t *testing.T) {
+	canary := "http://canary/sync"
+	path := "/sync"
+	hook := &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{
+		Service:             &v1alpha1.ServiceReference{Name: "hook", Namespace: "default"},
+		Path:                &path,
+		AllowedURLOverrides: []string{canary},
+	}}
+
+	table := []struct {
+		name        string
+		annotations map[string]string
+		wantURL     string
+		wantErr     bool
+	}{
+		{
+			name:    "no annotation",
+			wantURL: "http://hook.default:80/sync",
+		},
+		{
+			name:        "allowed override",
+			annotations: map[string]string{URLOverrideAnnotation: canary},
+			wantURL:     canary,
+		},
+		{
+			name:        "override not allowed",
+			annotations: map[string]string{URLOverrideAnnotation: "http://elsewhere/sync"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := &metav1.ObjectMeta{Annotations: tc.annotations}
+			got, err := ForObject(hook, obj)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ForObject() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			url, err := webhookURL(got.Webhook)
+			if err != nil {
+				t.Fatalf("webhookURL() error: %v", err)
+			}
+			if url != tc.wantURL {
+				t.Errorf("ForObject() URL = %q, want %q", url, tc.wantURL)
+			}
+		})
+	}
+	if hook.Webhook.URL != nil {
+		t.Errorf("ForObject() changed the hook: URL = %q", *hook.Webhook.URL)
+	}
+}
+
+func TestForObject_canary(t *testing.T) {
+	url := "http://hook/sync"
+	hook := &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{URL: &url}}
+
+	canaryCalls := func(weight int32) map[types.UID]bool {
+		hook.Webhook.Canary = &v1alpha1.WebhookCanary{URL: "http://canary/sync", Weight: weight}
+		result := make(map[types.UID]bool)
+		for i := 0; i < 1000; i++ {
+			obj := &metav1.ObjectMeta{UID: types.UID(fmt.Sprintf("uid-%v", i))}
+			got, err := ForObject(hook, obj)
+			if err != nil {
+				t.Fatalf("ForObject() error: %v", err)
+			}
+			if *got.Webhook.URL == hook.Webhook.Canary.URL {
+				result[obj.UID] = true
+			}
+		}
+		return result
+	}
+
+	if got := canaryCalls(0); len(got) != 0 {
+		t.Errorf("weight 0: got %v parents on the canary, want 0", len(got))
+	}
+	if got := canaryCalls(100); len(got) != 1000 {
+		t.Errorf("weight 100: got %v parents on the canary, want 1000", len(got))
+	}
+	small := canaryCalls(5)
+	if len(small) == 0 || len(small) > 100 {
+		t.Errorf("weight 5: got %v parents on the canary, want about 50", len(small))
+	}
+	large := canaryCalls(50)
+	for uid := range small {
+		if !large[uid] {
+			t.Errorf("parent %v left the canary when its weight was raised", uid)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package hooks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+var parameterSources = &parameterSourceCache{entries: make(map[parameterSourceKey]parameterSourceEntry)}
+
+// ResolveParameters returns the parameters passed to the hooks of a
+// controller: the data of each of its ParametersFrom sources in order,
+// overridden by its inline Parameters. It returns nil if there are none.
+func ResolveParameters(params *v1alpha1.HookParameters) (map[string]string, error) {
+	if params == nil || (len(params.Parameters) == 0 && len(params.ParametersFrom) == 0) {
+		return nil, nil
+	}
+	result := make(map[string]string)
+	for i := range params.ParametersFrom {
+		data, err := parameterSources.Get(&params.ParametersFrom[i])
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range data {
+			result[key] = value
+		}
+	}
+	for key, value := range params.Parameters {
+		result[key] = value
+	}
+	return result, nil
+}
+
+// ValidateParameters checks the sources of params without reading them, so
+// controllers that take parameters from namespaces they may not reference
+// fail when they're created.
+func ValidateParameters(params *v1alpha1.HookParameters) error {
+	if params == nil {
+		return nil
+	}
+	for i := range params.ParametersFrom {
+		key, err := parameterSourceKeyFor(&params.ParametersFrom[i])
+		if err != nil {
+			return err
+		}
+		if err := checkReferenceNamespace(key.kind, key.namespace, key.name); err != nil {
+			return fmt.Errorf("invalid parametersFrom entry: %v", err)
+		}
+	}
+	return nil
+}
+
+type parameterSourceKey struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+type parameterSourceEntry struct {
+	data    map[string]string
+	expires time.Time
+}
+
+// parameterSourceCache reads the ConfigMaps and Secrets that hook parameters
+// come from, and reuses them for as long as Secrets read for hook
+// credentials, so parameter changes are eventually picked up.
+type parameterSourceCache struct {
+	mutex   sync.Mutex
+	client  typedcorev1.CoreV1Interface
+	entries map[parameterSourceKey]parameterSourceEntry
+}
+
+func (c *parameterSourceCache) setClient(client typedcorev1.CoreV1Interface) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.client = client
+	c.entries = make(map[parameterSourceKey]parameterSourceEntry)
+}
+
+// Get returns the data of the ConfigMap or Secret selected by source.
+// It's read without holding the lock, so lookups of other sources don't wait
+// for the API server.
+func (c *parameterSourceCache) Get(source *v1alpha1.ParametersSource) (map[string]string, error) {
+	key, err := parameterSourceKeyFor(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReferenceNamespace(key.kind, key.namespace, key.name); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	client := c.client
+	c.mutex.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.data, nil
+	}
+	if client == nil {
+		return nil, fmt.Errorf("can't read %v %v/%v: hook parameters client not initialized", key.kind, key.namespace, key.name)
+	}
+	data := make(map[string]string)
+	switch key.kind {
+	case "configmap":
+		configMap, err := client.ConfigMaps(key.namespace).Get(key.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("can't get configmap %v/%v: %v", key.namespace, key.name, err)
+		}
+		for k, v := range configMap.Data {
+			data[k] = v
+		}
+	case "secret":
+		secret, err := client.Secrets(key.namespace).Get(key.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("can't get secret %v/%v: %v", key.namespace, key.name, err)
+		}
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = parameterSourceEntry{data: data, expires: time.Now().Add(secretCacheTTL)}
+	return data, nil
+}
+
+func parameterSourceKeyFor(source *v1alpha1.ParametersSource) (parameterSourceKey, error) {
+	switch {
+	case source.ConfigMapRef != nil && source.SecretRef == nil:
+		return parameterSourceKey{kind: "configmap", namespace: source.ConfigMapRef.Namespace, name: source.ConfigMapRef.Name}, nil
+	case source.SecretRef != nil && source.ConfigMapRef == nil:
+		return parameterSourceKey{kind: "secret", namespace: source.SecretRef.Namespace, name: source.SecretRef.Name}, nil
+	default:
+		return parameterSourceKey{}, fmt.Errorf("invalid parametersFrom entry: exactly one of configMapRef and secretRef must be set")
+	}
+}
@@ -0,0 +1,95 @@
+package hooks
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestResolveParameters_nilIfNotSpecified(t *testing.T) {
+	parameters, err := ResolveParameters(&v1alpha1.HookParameters{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parameters != nil {
+		t.Errorf("Expected no parameters, got: %v", parameters)
+	}
+}
+
+func TestResolveParameters_inline(t *testing.T) {
+	want := map[string]string{"replicas": "3"}
+	parameters, err := ResolveParameters(&v1alpha1.HookParameters{Parameters: want})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(parameters, want) {
+		t.Errorf("Expected %v, got: %v", want, parameters)
+	}
+}
+
+func TestResolveParameters_errorIfSourceInvalid(t *testing.T) {
+	ref := &v1alpha1.ObjectReference{Name: "params", Namespace: "default"}
+	table := map[string]v1alpha1.ParametersSource{
+		"neither": {},
+		"both":    {ConfigMapRef: ref, SecretRef: ref},
+	}
+	for name, source := range table {
+		params := &v1alpha1.HookParameters{ParametersFrom: []v1alpha1.ParametersSource{source}}
+		if _, err := ResolveParameters(params); err == nil {
+			t.Errorf("%v: Expected error for invalid parametersFrom entry", name)
+		}
+	}
+}
+
+func TestResolveParameters_fromConfigMapAndSecret(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "default"},
+			Data:       map[string]string{"replicas": "1", "color": "blue"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("hunter2")},
+		},
+	)
+	parameterSources.setClient(client.CoreV1())
+	defer parameterSources.setClient(nil)
+
+	params := &v1alpha1.HookParameters{
+		Parameters: map[string]string{"replicas": "3"},
+		ParametersFrom: []v1alpha1.ParametersSource{
+			{ConfigMapRef: &v1alpha1.ObjectReference{Name: "defaults", Namespace: "default"}},
+			{SecretRef: &v1alpha1.ObjectReference{Name: "credentials", Namespace: "default"}},
+		},
+	}
+	parameters, err := ResolveParameters(params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string]string{"replicas": "3", "color": "blue", "password": "hunter2"}
+	if !reflect.DeepEqual(parameters, want) {
+		t.Errorf("Expected %v, got: %v", want, parameters)
+	}
+}
+
+func TestValidateParameters_errorIfNamespaceNotAllowed(t *testing.T) {
+	ref := &v1alpha1.ObjectReference{Name: "params", Namespace: "kube-system"}
+	table := map[string]v1alpha1.ParametersSource{
+		"configmap": {ConfigMapRef: ref},
+		"secret":    {SecretRef: ref},
+	}
+	for name, source := range table {
+		params := &v1alpha1.HookParameters{ParametersFrom: []v1alpha1.ParametersSource{source}}
+		if err := ValidateParameters(params); err == nil {
+			t.Errorf("%v: Expected error for namespace not allowed", name)
+		}
+		if _, err := ResolveParameters(params); err == nil {
+			t.Errorf("%v: Expected error for namespace not allowed", name)
+		}
+	}
+}
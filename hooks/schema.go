@@ -0,0 +1,161 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema used to validate hook responses:
+// type, properties, required, items, additionalProperties and minLength.
+type Schema struct {
+	// Type is either a single type name or a list of allowed type names.
+	Type                 interface{}        `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+}
+
+// ResponseValidator is implemented by hook responses that are checked
+// against a schema before they are decoded, so malformed responses are
+// reported with the path of the offending field.
+type ResponseValidator interface {
+	ResponseSchema() *Schema
+}
+
+// MustLoadSchema parses a JSON schema, and panics if it's invalid.
+// It's meant for schemas embedded in the binary.
+func MustLoadSchema(data []byte) *Schema {
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		panic(fmt.Sprintf("invalid hook response schema: %v", err))
+	}
+	return &schema
+}
+
+// Validate checks a decoded JSON value against the schema.
+// The error names the first offending field, e.g. "children[3].metadata.name missing".
+func (s *Schema) Validate(value interface{}) error {
+	return s.validate("", value)
+}
+
+func (s *Schema) validate(path string, value interface{}) error {
+	if s == nil {
+		return nil
+	}
+	if got := jsonType(value); !s.allows(got) {
+		return fmt.Errorf("%s must be %s, got %s", fieldName(path), s.typeNames(), got)
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				return fmt.Errorf("%s missing", joinPath(path, name))
+			}
+		}
+		// Check fields in a stable order, so the same response always
+		// gets the same error.
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			field := s.Properties[name]
+			if field == nil {
+				field = s.AdditionalProperties
+			}
+			if err := field.validate(joinPath(path, name), value[name]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case string:
+		if s.MinLength != nil && len(value) < *s.MinLength {
+			if len(value) == 0 {
+				return fmt.Errorf("%s must not be empty", fieldName(path))
+			}
+			return fmt.Errorf("%s must be at least %d characters", fieldName(path), *s.MinLength)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) allows(typ string) bool {
+	names := s.typeList()
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if name == typ || (name == "number" && typ == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) typeList() []string {
+	switch typ := s.Type.(type) {
+	case string:
+		return []string{typ}
+	case []interface{}:
+		var names []string
+		for _, name := range typ {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func (s *Schema) typeNames() string {
+	return strings.Join(s.typeList(), " or ")
+}
+
+// jsonType returns the JSON Schema type name of a decoded JSON value.
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int64, int:
+		return "integer"
+	case float64:
+		if value == float64(int64(value)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func fieldName(path string) string {
+	if path == "" {
+		return "response"
+	}
+	return path
+}
@@ -0,0 +1,89 @@
+package hooks
+
+import (
+	"testing"
+)
+
+var testSchema = MustLoadSchema([]byte(`{
+  "type": "object",
+  "properties": {
+    "labels": {"type": ["object", "null"], "additionalProperties": {"type": ["string", "null"]}},
+    "children": {
+      "type": ["array", "null"],
+      "items": {
+        "type": "object",
+        "required": ["kind", "metadata"],
+        "properties": {
+          "kind": {"type": "string", "minLength": 1},
+          "metadata": {"type": "object", "required": ["name"]}
+        }
+      }
+    },
+    "resyncAfterSeconds": {"type": "number"}
+  }
+}`))
+
+func TestSchemaValidate(t *testing.T) {
+	child := func(kind string) map[string]interface{} {
+		return map[string]interface{}{"kind": kind, "metadata": map[string]interface{}{"name": "test"}}
+	}
+	table := []struct {
+		name     string
+		response interface{}
+		wantErr  string
+	}{
+		{
+			name:     "valid",
+			response: map[string]interface{}{"children": []interface{}{child("Pod")}, "resyncAfterSeconds": int64(2)},
+		},
+		{
+			name:     "null fields",
+			response: map[string]interface{}{"children": nil, "labels": map[string]interface{}{"a": nil}},
+		},
+		{
+			name:     "unknown fields",
+			response: map[string]interface{}{"other": true},
+		},
+		{
+			name:     "not an object",
+			response: []interface{}{},
+			wantErr:  "response must be object, got array",
+		},
+		{
+			name: "missing child name",
+			response: map[string]interface{}{"children": []interface{}{
+				child("Pod"),
+				map[string]interface{}{"kind": "Pod", "metadata": map[string]interface{}{}},
+			}},
+			wantErr: "children[1].metadata.name missing",
+		},
+		{
+			name:     "empty kind",
+			response: map[string]interface{}{"children": []interface{}{child("")}},
+			wantErr:  "children[0].kind must not be empty",
+		},
+		{
+			name:     "wrong label type",
+			response: map[string]interface{}{"labels": map[string]interface{}{"a": int64(1)}},
+			wantErr:  "labels.a must be string or null, got integer",
+		},
+		{
+			name:     "wrong number type",
+			response: map[string]interface{}{"resyncAfterSeconds": "1"},
+			wantErr:  "resyncAfterSeconds must be number, got string",
+		},
+	}
+
+	for _, tc := range table {
+		err := testSchema.Validate(tc.response)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%v: Validate() = %v, want nil", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tc.wantErr {
+			t.Errorf("%v: Validate() = %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package hooks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// secretCacheTTL is how long a Secret read on behalf of a hook is reused
+// before it's read again, so that rotated values are eventually picked up
+// without hitting the API server on every hook call.
+const secretCacheTTL = time.Minute
+
+var secrets = &secretCache{entries: make(map[v1alpha1.SecretKeyReference]secretCacheEntry)}
+
+// Init sets up the clients used to read Secrets and ConfigMaps referenced by
+// hook specs and the sources of hook parameters, in the given namespaces, and
+// to request tokens for the given ServiceAccount bound to one of the given
+// audiences (both of which may be empty if hooks don't use ServiceAccount
+// token authentication).
+// It must be called before any hook that needs either is invoked.
+func Init(config *rest.Config, namespaces []string, serviceAccount types.NamespacedName, audiences []string) error {
+	if err := checkTokenAudiences(config, audiences); err != nil {
+		return err
+	}
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("can't create client for hook credentials: %v", err)
+	}
+	referenceNamespaces = sets.NewString(namespaces...)
+	tokenAudiences = sets.NewString(audiences...)
+	secrets.setClient(clientSet.CoreV1())
+	charts.setClient(clientSet.CoreV1())
+	parameterSources.setClient(clientSet.CoreV1())
+	serviceAccountTokens.setClient(clientSet.CoreV1(), serviceAccount)
+	return nil
+}
+
+type secretCacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+type secretCache struct {
+	mutex   sync.Mutex
+	client  typedcorev1.SecretsGetter
+	entries map[v1alpha1.SecretKeyReference]secretCacheEntry
+}
+
+func (c *secretCache) setClient(client typedcorev1.SecretsGetter) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.client = client
+	c.entries = make(map[v1alpha1.SecretKeyReference]secretCacheEntry)
+}
+
+// Get returns the value of the Secret key selected by ref.
+// The Secret is read without holding the lock, so lookups of other Secrets,
+// for other hooks, don't wait for the API server.
+func (c *secretCache) Get(ref *v1alpha1.SecretKeyReference) ([]byte, error) {
+	if err := checkReferenceNamespace("secret", ref.Namespace, ref.Name); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	entry, ok := c.entries[*ref]
+	client := c.client
+	c.mutex.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+	if client == nil {
+		return nil, fmt.Errorf("can't read secret %v/%v: hook secrets client not initialized", ref.Namespace, ref.Name)
+	}
+	secret, err := client.Secrets(ref.Namespace).Get(ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get secret %v/%v: %v", ref.Namespace, ref.Name, err)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("secret %v/%v has no key %q", ref.Namespace, ref.Name, ref.Key)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[*ref] = secretCacheEntry{value: value, expires: time.Now().Add(secretCacheTTL)}
+	return value, nil
+}
+
+// contentHash returns a hash of a credential or CA bundle, which caches keep
+// next to what they built from it, so they can tell when it was rotated
+// without holding on to the value itself.
+func contentHash(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
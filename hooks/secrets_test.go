@@ -0,0 +1,112 @@
+package hooks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func init() {
+	// Tests reference Secrets in the default namespace, and request tokens
+	// for the my-hook audience.
+	referenceNamespaces = sets.NewString("default")
+	tokenAudiences = sets.NewString("my-hook")
+}
+
+// fakeSecrets serves Get from a function, and panics on other calls.
+
+type fakeSecrets struct {
+	typedcorev1.SecretInterface
+	get func(name string) (*corev1.Secret, error)
+}
+
+func (s fakeSecrets) Get(name string, options metav1.GetOptions) (*corev1.Secret, error) {
+	return s.get(name)
+}
+
+type fakeSecretsGetter func(name string) (*corev1.Secret, error)
+
+func (f fakeSecretsGetter) Secrets(namespace string) typedcorev1.SecretInterface {
+	return fakeSecrets{get: f}
+}
+
+func TestSecretCache_getDoesNotBlockOtherSecrets(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	cache := &secretCache{}
+	cache.setClient(fakeSecretsGetter(func(name string) (*corev1.Secret, error) {
+		if name == "slow" {
+			close(entered)
+			<-release
+		}
+		return &corev1.Secret{Data: map[string][]byte{"token": []byte(name)}}, nil
+	}))
+
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		if _, err := cache.Get(&v1alpha1.SecretKeyReference{Name: "slow", Namespace: "default", Key: "token"}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}()
+	<-entered
+
+	fastDone := make(chan struct{})
+	go func() {
+		defer close(fastDone)
+		value, err := cache.Get(&v1alpha1.SecretKeyReference{Name: "fast", Namespace: "default", Key: "token"})
+		if err != nil || string(value) != "fast" {
+			t.Errorf("Get() = %q, %v; want %q", value, err, "fast")
+		}
+	}()
+	select {
+	case <-fastDone:
+	case <-time.After(10 * time.Second):
+		t.Error("Get() of a Secret waited for the read of another one")
+	}
+	close(release)
+	<-slowDone
+}
+
+func TestSecretCache_getCachesValues(t *testing.T) {
+	reads := 0
+	cache := &secretCache{}
+	cache.setClient(fakeSecretsGetter(func(name string) (*corev1.Secret, error) {
+		reads++
+		if name == "missing" {
+			return nil, fmt.Errorf("not found")
+		}
+		return &corev1.Secret{Data: map[string][]byte{"token": []byte(name)}}, nil
+	}))
+
+	ref := &v1alpha1.SecretKeyReference{Name: "token", Namespace: "default", Key: "token"}
+	for i := 0; i < 2; i++ {
+		if value, err := cache.Get(ref); err != nil || string(value) != "token" {
+			t.Errorf("Get() = %q, %v; want %q", value, err, "token")
+		}
+	}
+	if reads != 1 {
+		t.Errorf("Expected the Secret to be read once, got %v reads", reads)
+	}
+	if _, err := cache.Get(&v1alpha1.SecretKeyReference{Name: "missing", Namespace: "default", Key: "token"}); err == nil {
+		t.Error("Expected error for missing Secret")
+	}
+}
+
+func TestSecretCache_getRejectsOtherNamespaces(t *testing.T) {
+	cache := &secretCache{}
+	cache.setClient(fakeSecretsGetter(func(name string) (*corev1.Secret, error) {
+		t.Errorf("Unexpected read of Secret %v", name)
+		return &corev1.Secret{Data: map[string][]byte{"token": []byte(name)}}, nil
+	}))
+
+	if _, err := cache.Get(&v1alpha1.SecretKeyReference{Name: "token", Namespace: "kube-system", Key: "token"}); err == nil {
+		t.Error("Expected error for Secret in a namespace that isn't allowed")
+	}
+}
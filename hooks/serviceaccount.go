@@ -0,0 +1,141 @@
+package hooks
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// defaultTokenExpirationSeconds is the token lifetime requested when a hook
+// doesn't specify one.
+const defaultTokenExpirationSeconds = int64(60 * 60)
+
+// apiServerAudiences are the audiences API servers accept tokens for by
+// default. Tokens for metacontroller's ServiceAccount are as good as its
+// credentials for those audiences, so they're never requested.
+var apiServerAudiences = sets.NewString(
+	"https://kubernetes.default.svc",
+	"https://kubernetes.default.svc.cluster.local",
+	"kubernetes.default.svc",
+	"kubernetes.default.svc.cluster.local",
+)
+
+// tokenAudiences are the audiences hooks may request tokens for. Hooks choose
+// the URL the token is sent to, so the operator decides which audiences are
+// safe to hand out, and none are by default.
+var tokenAudiences = sets.NewString()
+
+// ParseTokenAudiences parses the comma-separated --hook-token-audiences flag.
+func ParseTokenAudiences(flag string) []string {
+	return splitList(flag)
+}
+
+// checkTokenAudiences returns an error if any of audiences is one the API
+// server, at the host of config, may accept tokens for.
+func checkTokenAudiences(config *rest.Config, audiences []string) error {
+	reserved := sets.NewString(apiServerAudiences.List()...)
+	if config != nil && config.Host != "" {
+		reserved.Insert(strings.TrimSuffix(config.Host, "/"))
+	}
+	for _, audience := range audiences {
+		if reserved.Has(strings.TrimSuffix(audience, "/")) {
+			return fmt.Errorf("invalid --hook-token-audiences: %q is an audience of the API server", audience)
+		}
+	}
+	return nil
+}
+
+// checkTokenAudience returns an error unless hooks may request tokens for
+// audience.
+func checkTokenAudience(audience string) error {
+	if !tokenAudiences.Has(audience) {
+		return fmt.Errorf("can't request service account token for audience %q: audience isn't allowed by --hook-token-audiences", audience)
+	}
+	return nil
+}
+
+var serviceAccountTokens = &serviceAccountTokenCache{entries: make(map[serviceAccountTokenKey]serviceAccountToken)}
+
+type serviceAccountTokenKey struct {
+	audience          string
+	expirationSeconds int64
+}
+
+type serviceAccountToken struct {
+	token   string
+	refresh time.Time
+}
+
+// serviceAccountTokenCache requests audience-bound tokens for metacontroller's
+// own ServiceAccount through the TokenRequest API, and reuses each one until
+// 80% of its lifetime has passed.
+type serviceAccountTokenCache struct {
+	mutex          sync.Mutex
+	client         typedcorev1.ServiceAccountsGetter
+	serviceAccount types.NamespacedName
+	entries        map[serviceAccountTokenKey]serviceAccountToken
+}
+
+func (c *serviceAccountTokenCache) setClient(client typedcorev1.ServiceAccountsGetter, serviceAccount types.NamespacedName) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.client = client
+	c.serviceAccount = serviceAccount
+	c.entries = make(map[serviceAccountTokenKey]serviceAccountToken)
+}
+
+// Get returns a token for the given projection.
+// The token is requested without holding the lock, so hooks whose tokens are
+// still fresh don't wait for the API server.
+func (c *serviceAccountTokenCache) Get(projection *v1alpha1.ServiceAccountTokenProjection) (string, error) {
+	if err := checkTokenAudience(projection.Audience); err != nil {
+		return "", err
+	}
+	expirationSeconds := defaultTokenExpirationSeconds
+	if projection.ExpirationSeconds != nil {
+		expirationSeconds = *projection.ExpirationSeconds
+	}
+	key := serviceAccountTokenKey{audience: projection.Audience, expirationSeconds: expirationSeconds}
+
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	client, serviceAccount := c.client, c.serviceAccount
+	c.mutex.Unlock()
+
+	if ok && time.Now().Before(entry.refresh) {
+		return entry.token, nil
+	}
+	if client == nil || serviceAccount.Name == "" || serviceAccount.Namespace == "" {
+		return "", fmt.Errorf("can't request service account token: metacontroller's service account is not configured (see --service-account)")
+	}
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         []string{projection.Audience},
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}
+	result, err := client.ServiceAccounts(serviceAccount.Namespace).CreateToken(serviceAccount.Name, tokenRequest)
+	if err != nil {
+		return "", fmt.Errorf("can't request token for service account %v: %v", serviceAccount, err)
+	}
+	issued := time.Now()
+	lifetime := result.Status.ExpirationTimestamp.Time.Sub(issued)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = serviceAccountToken{
+		token:   result.Status.Token,
+		refresh: issued.Add(lifetime * 8 / 10),
+	}
+	return result.Status.Token, nil
+}
@@ -0,0 +1,112 @@
+package hooks
+
+import (
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// fakeServiceAccounts serves CreateToken from a function, and panics on other
+// calls.
+type fakeServiceAccounts struct {
+	typedcorev1.ServiceAccountInterface
+	createToken func(audience string) (*authenticationv1.TokenRequest, error)
+}
+
+func (s fakeServiceAccounts) CreateToken(name string, tokenRequest *authenticationv1.TokenRequest) (*authenticationv1.TokenRequest, error) {
+	return s.createToken(tokenRequest.Spec.Audiences[0])
+}
+
+type fakeServiceAccountsGetter func(audience string) (*authenticationv1.TokenRequest, error)
+
+func (f fakeServiceAccountsGetter) ServiceAccounts(namespace string) typedcorev1.ServiceAccountInterface {
+	return fakeServiceAccounts{createToken: f}
+}
+
+func TestServiceAccountTokenCache_getDoesNotBlockFreshTokens(t *testing.T) {
+	defer func(audiences sets.String) { tokenAudiences = audiences }(tokenAudiences)
+	tokenAudiences = sets.NewString("fast", "slow")
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	cache := &serviceAccountTokenCache{}
+	cache.setClient(fakeServiceAccountsGetter(func(audience string) (*authenticationv1.TokenRequest, error) {
+		if audience == "slow" {
+			close(entered)
+			<-release
+		}
+		return &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{
+			Token:               audience,
+			ExpirationTimestamp: metav1.NewTime(time.Now().Add(time.Hour)),
+		}}, nil
+	}), types.NamespacedName{Namespace: "metacontroller", Name: "metacontroller"})
+
+	if token, err := cache.Get(&v1alpha1.ServiceAccountTokenProjection{Audience: "fast"}); err != nil || token != "fast" {
+		t.Fatalf("Get() = %q, %v; want %q", token, err, "fast")
+	}
+
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		if _, err := cache.Get(&v1alpha1.ServiceAccountTokenProjection{Audience: "slow"}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}()
+	<-entered
+
+	fastDone := make(chan struct{})
+	go func() {
+		defer close(fastDone)
+		if token, err := cache.Get(&v1alpha1.ServiceAccountTokenProjection{Audience: "fast"}); err != nil || token != "fast" {
+			t.Errorf("Get() = %q, %v; want %q", token, err, "fast")
+		}
+	}()
+	select {
+	case <-fastDone:
+	case <-time.After(10 * time.Second):
+		t.Error("Get() of a fresh token waited for the request of another one")
+	}
+	close(release)
+	<-slowDone
+}
+
+func TestServiceAccountTokenCache_getRejectsOtherAudiences(t *testing.T) {
+	cache := &serviceAccountTokenCache{}
+	cache.setClient(fakeServiceAccountsGetter(func(audience string) (*authenticationv1.TokenRequest, error) {
+		t.Fatalf("Unexpected token request for audience %q", audience)
+		return nil, nil
+	}), types.NamespacedName{Namespace: "metacontroller", Name: "metacontroller"})
+
+	if _, err := cache.Get(&v1alpha1.ServiceAccountTokenProjection{Audience: "other-hook"}); err == nil {
+		t.Error("Expected error for an audience that isn't allowed")
+	}
+}
+
+func TestCheckTokenAudiences(t *testing.T) {
+	config := &rest.Config{Host: "https://10.0.0.1:6443"}
+	table := []struct {
+		name      string
+		audiences []string
+		wantErr   bool
+	}{
+		{name: "none"},
+		{name: "hook audience", audiences: []string{"my-hook", "https://hooks.example.com"}},
+		{name: "default API server audience", audiences: []string{"my-hook", "https://kubernetes.default.svc.cluster.local"}, wantErr: true},
+		{name: "API server host", audiences: []string{"https://10.0.0.1:6443/"}, wantErr: true},
+	}
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkTokenAudiences(config, tc.audiences)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("checkTokenAudiences() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
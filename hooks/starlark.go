@@ -0,0 +1,175 @@
+package hooks
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"time"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"k8s.io/apimachinery/pkg/util/json"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+const (
+	defaultStarlarkMaxSteps  = 1000000
+	defaultStarlarkMaxMemory = 64 << 20
+	defaultStarlarkTimeout   = 10 * time.Second
+
+	// starlarkFunction is the function scripts must define.
+	starlarkFunction = "hook"
+)
+
+// starlarkHeapInterval is how often the heap is checked during a call.
+var starlarkHeapInterval = 10 * time.Millisecond
+
+// starlarkPredeclared holds the only globals of scripts besides the Starlark
+// built-ins. Scripts can't load other modules, and have no access to the
+// clock, the network or the filesystem.
+var starlarkPredeclared = starlark.StringDict{
+	"json": starlarkjson.Module,
+}
+
+// callStarlark runs the script of hook in its own thread, and calls its hook
+// function with the request, converted from JSON to Starlark values.
+// The returned value is converted back to JSON, as if a webhook had sent it.
+func callStarlark(hook *v1alpha1.StarlarkHook, request interface{}, response interface{}) error {
+	program, err := compileStarlark(hook)
+	if err != nil {
+		return err
+	}
+	reqBody, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("can't marshal request: %v", err)
+	}
+
+	thread := &starlark.Thread{Name: starlarkFunction}
+	thread.SetMaxExecutionSteps(uint64(starlarkMaxSteps(hook)))
+	timeout := starlarkTimeout(hook)
+	timer := time.AfterFunc(timeout, func() {
+		thread.Cancel(fmt.Sprintf("timed out after %v", timeout))
+	})
+	defer timer.Stop()
+	stop := make(chan struct{})
+	defer close(stop)
+	go watchHeap(thread, starlarkMaxMemory(hook), stop)
+
+	globals, err := program.Init(thread, starlarkPredeclared)
+	if err != nil {
+		return fmt.Errorf("can't run script: %v", starlarkError(err))
+	}
+	fn, ok := globals[starlarkFunction].(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("script doesn't define a %s function", starlarkFunction)
+	}
+	input, err := starlark.Call(thread, starlarkjson.Module.Members["decode"], starlark.Tuple{starlark.String(reqBody)}, nil)
+	if err != nil {
+		return fmt.Errorf("can't convert request: %v", err)
+	}
+	output, err := starlark.Call(thread, fn, starlark.Tuple{input}, nil)
+	if err != nil {
+		return fmt.Errorf("%s function failed: %v", starlarkFunction, starlarkError(err))
+	}
+	respBody, err := starlark.Call(thread, starlarkjson.Module.Members["encode"], starlark.Tuple{output}, nil)
+	if err != nil {
+		return fmt.Errorf("can't convert response: %v", err)
+	}
+
+	if err := json.Unmarshal([]byte(respBody.(starlark.String).GoString()), response); err != nil {
+		return fmt.Errorf("can't unmarshal response: %v", err)
+	}
+	return nil
+}
+
+// validateStarlark compiles the script of hook, so syntax errors show up when
+// the controller is created rather than on each call.
+func validateStarlark(hook *v1alpha1.StarlarkHook) error {
+	if hook.MaxSteps != nil && *hook.MaxSteps <= 0 {
+		return fmt.Errorf("invalid starlark hook: 'maxSteps' must be positive")
+	}
+	if hook.MaxMemory != nil && hook.MaxMemory.Sign() <= 0 {
+		return fmt.Errorf("invalid starlark hook: 'maxMemory' must be positive")
+	}
+	if hook.Timeout != nil && hook.Timeout.Duration <= 0 {
+		return fmt.Errorf("invalid starlark hook: 'timeout' must be positive")
+	}
+	_, err := compileStarlark(hook)
+	return err
+}
+
+func compileStarlark(hook *v1alpha1.StarlarkHook) (*starlark.Program, error) {
+	_, program, err := starlark.SourceProgram("hook.star", hook.Script, starlarkPredeclared.Has)
+	if err != nil {
+		return nil, fmt.Errorf("invalid starlark hook: can't compile script: %v", err)
+	}
+	if program.NumLoads() > 0 {
+		return nil, fmt.Errorf("invalid starlark hook: scripts can't load modules")
+	}
+	return program, nil
+}
+
+// starlarkError adds the Starlark backtrace of err, if any, since the
+// position in the script is the most useful part of the error.
+func starlarkError(err error) error {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		return fmt.Errorf("%s", evalErr.Backtrace())
+	}
+	return err
+}
+
+// watchHeap cancels thread if the heap grows by more than limit bytes before
+// stop is closed.
+// The heap is shared with the rest of Metacontroller, so this is approximate:
+// it's meant to stop runaway scripts before they exhaust its memory, not to
+// account for their allocations precisely.
+func watchHeap(thread *starlark.Thread, limit int64, stop <-chan struct{}) {
+	start := heapBytes()
+	ticker := time.NewTicker(starlarkHeapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if heapBytes()-start <= limit {
+			continue
+		}
+		// Garbage counts until it's collected, so collect it before blaming
+		// the script.
+		runtime.GC()
+		if heapBytes()-start > limit {
+			thread.Cancel(fmt.Sprintf("heap grew by more than %d bytes", limit))
+			return
+		}
+	}
+}
+
+func heapBytes() int64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return int64(sample[0].Value.Uint64())
+}
+
+func starlarkMaxSteps(hook *v1alpha1.StarlarkHook) int64 {
+	if hook.MaxSteps == nil {
+		return defaultStarlarkMaxSteps
+	}
+	return *hook.MaxSteps
+}
+
+func starlarkMaxMemory(hook *v1alpha1.StarlarkHook) int64 {
+	if hook.MaxMemory == nil {
+		return defaultStarlarkMaxMemory
+	}
+	return hook.MaxMemory.Value()
+}
+
+func starlarkTimeout(hook *v1alpha1.StarlarkHook) time.Duration {
+	if hook.Timeout == nil {
+		return defaultStarlarkTimeout
+	}
+	return hook.Timeout.Duration
+}
@@ -0,0 +1,128 @@
+package hooks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/pointer"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestCallStarlark(t *testing.T) {
+	table := []struct {
+		name    string
+		hook    *v1alpha1.StarlarkHook
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "sync",
+			hook: &v1alpha1.StarlarkHook{Script: `
+def hook(request):
+  replicas = request["parent"]["spec"]["replicas"]
+  return {"status": {"replicas": replicas, "ready": json.encode(replicas > 1)}}
+`},
+			want: map[string]interface{}{"status": map[string]interface{}{"replicas": int64(2), "ready": "true"}},
+		},
+		{
+			name:    "no hook function",
+			hook:    &v1alpha1.StarlarkHook{Script: `def sync(request): return {}`},
+			wantErr: "doesn't define a hook function",
+		},
+		{
+			name:    "failing hook function",
+			hook:    &v1alpha1.StarlarkHook{Script: `def hook(request): return request["missing"]`},
+			wantErr: "hook function failed",
+		},
+		{
+			name: "too many steps",
+			hook: &v1alpha1.StarlarkHook{
+				Script: `
+def hook(request):
+  for i in range(1000000):
+    pass
+  return {}
+`,
+				MaxSteps: pointer.Int64Ptr(1000),
+			},
+			wantErr: "too many steps",
+		},
+		{
+			name: "too much memory",
+			hook: &v1alpha1.StarlarkHook{
+				Script: `
+def hook(request):
+  chunks = []
+  for i in range(100000):
+    chunks.append("x" * 1000 + str(i))
+  return {}
+`,
+				MaxMemory: resource.NewQuantity(1<<20, resource.BinarySI),
+			},
+			wantErr: "heap grew",
+		},
+	}
+
+	for _, tc := range table {
+		hook := &v1alpha1.Hook{Starlark: tc.hook}
+		request := map[string]interface{}{"parent": map[string]interface{}{"spec": map[string]interface{}{"replicas": 2}}}
+		var got map[string]interface{}
+		err := Call(hook, request, &got)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%v: Call() = %v, want error containing %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: Call() = %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: Call() response = %#v, want %#v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateStarlark(t *testing.T) {
+	table := []struct {
+		name    string
+		hook    *v1alpha1.StarlarkHook
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			hook: &v1alpha1.StarlarkHook{Script: `def hook(request): return {}`},
+		},
+		{
+			name:    "syntax error",
+			hook:    &v1alpha1.StarlarkHook{Script: `def hook(request) return {}`},
+			wantErr: true,
+		},
+		{
+			name:    "load",
+			hook:    &v1alpha1.StarlarkHook{Script: `load("os.star", "os")`},
+			wantErr: true,
+		},
+		{
+			name:    "undefined global",
+			hook:    &v1alpha1.StarlarkHook{Script: `def hook(request): return http.get(request)`},
+			wantErr: true,
+		},
+		{
+			name:    "negative max steps",
+			hook:    &v1alpha1.StarlarkHook{Script: `def hook(request): return {}`, MaxSteps: pointer.Int64Ptr(-1)},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range table {
+		err := ValidateHook(&v1alpha1.Hook{Starlark: tc.hook})
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%v: ValidateHook() = %v, want error: %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package hooks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// transports holds one transport per source of CA bundle, so that connections
+// to hooks using custom PKI can be reused across calls. A transport is
+// replaced once the CA bundle of its source changes, such as when the Secret
+// holding it is rotated, so the cache doesn't grow with every rotation.
+var transports = &transportCache{entries: make(map[transportKey]transportCacheEntry)}
+
+// transportKey identifies where the CA bundle of a webhook comes from.
+type transportKey struct {
+	// caBundleFrom is the Secret key the bundle is read from, if any.
+	caBundleFrom v1alpha1.SecretKeyReference
+	// caBundleHash is the hash of the inline bundle, if any.
+	caBundleHash string
+}
+
+type transportCacheEntry struct {
+	// hash is the hash of the whole CA bundle the transport trusts.
+	hash      string
+	transport *http.Transport
+}
+
+type transportCache struct {
+	mutex   sync.Mutex
+	entries map[transportKey]transportCacheEntry
+}
+
+// Get returns a transport that trusts the system roots plus the given
+// PEM encoded CA bundle, which comes from the source identified by key.
+func (c *transportCache) Get(key transportKey, caBundle []byte) (*http.Transport, error) {
+	hash := contentHash(caBundle)
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	c.mutex.Unlock()
+	if ok && entry.hash == hash {
+		return entry.transport, nil
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil || rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("invalid webhook config: no PEM certificates found in CA bundle")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if current, ok := c.entries[key]; ok {
+		if current.hash == hash {
+			// Another call made a transport for the same bundle meanwhile.
+			return current.transport, nil
+		}
+		// The bundle was rotated, so nobody should use the old transport
+		// anymore.
+		current.transport.CloseIdleConnections()
+	}
+	c.entries[key] = transportCacheEntry{hash: hash, transport: transport}
+	return transport, nil
+}
+
+// webhookCABundle returns the combined inline and Secret-referenced CA bundle
+// for the webhook, or nil if none is configured.
+func webhookCABundle(webhook *v1alpha1.Webhook) ([]byte, error) {
+	caBundle := webhook.CABundle
+	if webhook.CABundleFrom != nil {
+		fromSecret, err := secrets.Get(webhook.CABundleFrom)
+		if err != nil {
+			return nil, fmt.Errorf("can't read CA bundle: %v", err)
+		}
+		caBundle = append(append(append([]byte{}, caBundle...), '\n'), fromSecret...)
+	}
+	return caBundle, nil
+}
+
+// webhookTransport returns the transport to use when calling the webhook.
+func webhookTransport(webhook *v1alpha1.Webhook) (http.RoundTripper, error) {
+	caBundle, err := webhookCABundle(webhook)
+	if err != nil {
+		return nil, err
+	}
+	if len(caBundle) == 0 {
+		return http.DefaultTransport, nil
+	}
+	key := transportKey{caBundleHash: contentHash(webhook.CABundle)}
+	if webhook.CABundleFrom != nil {
+		key.caBundleFrom = *webhook.CABundleFrom
+	}
+	return transports.Get(key, caBundle)
+}
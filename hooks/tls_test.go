@@ -0,0 +1,88 @@
+package hooks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestWebhookTransport_defaultIfNoCABundle(t *testing.T) {
+	transport, err := webhookTransport(&v1alpha1.Webhook{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if transport != http.DefaultTransport {
+		t.Errorf("Expected default transport, got: %v", transport)
+	}
+}
+
+func TestWebhookTransport_errorIfInvalidCABundle(t *testing.T) {
+	_, err := webhookTransport(&v1alpha1.Webhook{CABundle: []byte("not a certificate")})
+	if err == nil {
+		t.Error("Expected error for CA bundle without PEM certificates")
+	}
+}
+
+func TestWebhookTransport_errorIfSecretsNotInitialized(t *testing.T) {
+	webhook := &v1alpha1.Webhook{
+		CABundleFrom: &v1alpha1.SecretKeyReference{Name: "ca", Namespace: "default", Key: "ca.crt"},
+	}
+	_, err := webhookTransport(webhook)
+	if err == nil {
+		t.Error("Expected error when secrets client is not initialized")
+	}
+}
+
+// testCABundle returns a PEM encoded self-signed CA certificate.
+func testCABundle(t *testing.T, name string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("can't generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("can't create certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestTransportCache_replacesRotatedBundle(t *testing.T) {
+	cache := &transportCache{entries: make(map[transportKey]transportCacheEntry)}
+	key := transportKey{caBundleFrom: v1alpha1.SecretKeyReference{Name: "ca", Namespace: "default", Key: "ca.crt"}}
+	first, second := testCABundle(t, "first"), testCABundle(t, "second")
+
+	transport, err := cache.Get(key, first)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again, _ := cache.Get(key, first); again != transport {
+		t.Error("Expected the transport to be reused for the same bundle")
+	}
+	rotated, err := cache.Get(key, second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rotated == transport {
+		t.Error("Expected a new transport for the rotated bundle")
+	}
+	if len(cache.entries) != 1 {
+		t.Errorf("Expected the rotated bundle to replace the old one, got %v entries", len(cache.entries))
+	}
+}
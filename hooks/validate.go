@@ -0,0 +1,40 @@
+package hooks
+
+import (
+	"fmt"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+// ValidateHook checks the parts of hook that can be checked without calling
+// it, such as the authorization of its webhook, the Secrets it references and
+// the syntax of its script.
+// Controllers check their hooks when they're created, so such mistakes don't
+// wait for a sync to show up.
+func ValidateHook(hook *v1alpha1.Hook) error {
+	if hook == nil {
+		return nil
+	}
+	if hook.Webhook != nil {
+		return validateWebhook(hook.Webhook)
+	}
+	if hook.OPAServer != nil {
+		return validateWebhook(hook.OPAServer)
+	}
+	if hook.Starlark != nil {
+		return validateStarlark(hook.Starlark)
+	}
+	return nil
+}
+
+func validateWebhook(webhook *v1alpha1.Webhook) error {
+	if ref := webhook.CABundleFrom; ref != nil {
+		if err := checkReferenceNamespace("secret", ref.Namespace, ref.Name); err != nil {
+			return fmt.Errorf("invalid webhook config: 'caBundleFrom': %v", err)
+		}
+	}
+	if webhook.Authorization != nil {
+		return validateAuthorization(webhook.Authorization)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package hooks
+
+import (
+	"testing"
+
+	"metacontroller.io/apis/metacontroller/v1alpha1"
+)
+
+func TestValidateHook(t *testing.T) {
+	table := []struct {
+		name    string
+		hook    *v1alpha1.Hook
+		wantErr bool
+	}{
+		{
+			name: "no hook",
+		},
+		{
+			name: "no authorization",
+			hook: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{}},
+		},
+		{
+			name: "CA bundle in allowed namespace",
+			hook: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{
+				CABundleFrom: &v1alpha1.SecretKeyReference{Name: "ca", Namespace: "default", Key: "ca.crt"},
+			}},
+		},
+		{
+			name: "CA bundle in other namespace",
+			hook: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{
+				CABundleFrom: &v1alpha1.SecretKeyReference{Name: "ca", Namespace: "kube-system", Key: "ca.crt"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "OPA server CA bundle in other namespace",
+			hook: &v1alpha1.Hook{OPAServer: &v1alpha1.Webhook{
+				CABundleFrom: &v1alpha1.SecretKeyReference{Name: "ca", Namespace: "kube-system", Key: "ca.crt"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "bearer token in other namespace",
+			hook: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{
+				Authorization: &v1alpha1.WebhookAuthorization{
+					BearerTokenFrom: &v1alpha1.SecretKeyReference{Name: "token", Namespace: "kube-system", Key: "token"},
+				},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "OAuth2 client secret in other namespace",
+			hook: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{
+				Authorization: &v1alpha1.WebhookAuthorization{
+					OAuth2: &v1alpha1.OAuth2ClientCredentials{
+						TokenURL:         "https://auth.example.com/token",
+						ClientSecretFrom: v1alpha1.SecretKeyReference{Name: "oauth2", Namespace: "kube-system", Key: "secret"},
+					},
+				},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "service account token",
+			hook: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{Authorization: &v1alpha1.WebhookAuthorization{
+				ServiceAccountToken: &v1alpha1.ServiceAccountTokenProjection{Audience: "my-hook"},
+			}}},
+		},
+		{
+			name: "service account token without audience",
+			hook: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{Authorization: &v1alpha1.WebhookAuthorization{
+				ServiceAccountToken: &v1alpha1.ServiceAccountTokenProjection{},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "OPA server service account token without audience",
+			hook: &v1alpha1.Hook{OPAServer: &v1alpha1.Webhook{Authorization: &v1alpha1.WebhookAuthorization{
+				ServiceAccountToken: &v1alpha1.ServiceAccountTokenProjection{},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "service account token for other audience",
+			hook: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{Authorization: &v1alpha1.WebhookAuthorization{
+				ServiceAccountToken: &v1alpha1.ServiceAccountTokenProjection{Audience: "https://kubernetes.default.svc"},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "both bearer token and OAuth2",
+			hook: &v1alpha1.Hook{Webhook: &v1alpha1.Webhook{Authorization: &v1alpha1.WebhookAuthorization{
+				BearerTokenFrom: &v1alpha1.SecretKeyReference{Name: "token", Namespace: "default", Key: "token"},
+				OAuth2: &v1alpha1.OAuth2ClientCredentials{
+					ClientSecretFrom: v1alpha1.SecretKeyReference{Name: "oauth2", Namespace: "default", Key: "secret"},
+				},
+			}}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateHook(tc.hook)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidateHook() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -29,11 +29,12 @@ import (
 	"metacontroller.io/apis/metacontroller/v1alpha1"
 )
 
-func callWebhook(webhook *v1alpha1.Webhook, request interface{}, response interface{}) error {
+func callWebhook(webhook *v1alpha1.Webhook, request interface{}, response interface{}) (err error) {
 	url, err := webhookURL(webhook)
 	if err != nil {
 		return err
 	}
+	defer func() { recordWebhookCall(url, err) }()
 	hookTimeout, err := webhookTimeout(webhook)
 	if err != nil {
 		klog.InfoS(err.Error())
@@ -47,12 +48,43 @@ func callWebhook(webhook *v1alpha1.Webhook, request interface{}, response interf
 		klog.InfoS("Webhook request", "url", url, "body", string(reqBody))
 	}
 
-	// Send request.
-	client := &http.Client{Timeout: hookTimeout}
+	transport, err := webhookTransport(webhook)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: hookTimeout, Transport: transport}
+	authorization, err := webhookAuthorization(webhook, client)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("can't create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	etag, _ := response.(*etagged)
+	if etag != nil && etag.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", etag.IfNoneMatch)
+	}
+	if etag != nil && etag.TraceParent != "" {
+		req.Header.Set("traceparent", etag.TraceParent)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	// Send request, waiting for a free slot if the webhook limits concurrent calls.
+	release := acquireWebhook(webhook, url)
+	defer release()
 	klog.V(6).InfoS("Webhook timeout", "timeout", hookTimeout)
-	resp, err := client.Post(url, "application/json", bytes.NewReader(reqBody))
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("http error: %v", err)
+		if isDialError(err) {
+			// The request never reached the webhook, so it can't have
+			// crashed it.
+			return fmt.Errorf("http error: %v", err)
+		}
+		return &crashError{fmt.Errorf("http error: %v", err)}
 	}
 	defer resp.Body.Close()
 
@@ -63,12 +95,34 @@ func callWebhook(webhook *v1alpha1.Webhook, request interface{}, response interf
 	}
 	klog.V(6).InfoS("Webhook response", "url", url, "body", string(respBody))
 
+	// A 304 Not Modified means the last response still holds.
+	if resp.StatusCode == http.StatusNotModified {
+		if etag == nil || etag.IfNoneMatch == "" {
+			return errNotModifiedWithoutETag
+		}
+		etag.NotModified = true
+		return nil
+	}
+
 	// Check status code.
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("remote error: %s", respBody)
+		hookErr := &Error{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(respBody, hookErr); err != nil || (hookErr.Code == "" && hookErr.Message == "") {
+			// Not a structured error.
+			err := fmt.Errorf("remote error: %s", respBody)
+			if resp.StatusCode >= http.StatusInternalServerError {
+				return &crashError{err}
+			}
+			return err
+		}
+		return hookErr
 	}
 
 	// Decode response.
+	if etag != nil {
+		etag.ETag = resp.Header.Get("ETag")
+		response = etag.Response
+	}
 	if err := json.Unmarshal(respBody, response); err != nil {
 		return fmt.Errorf("can't unmarshal response: %v", err)
 	}
@@ -0,0 +1,271 @@
+// Package leader elects the replica of Metacontroller that runs the
+// controllers, when several replicas run for availability, and reports which
+// replica leads.
+//
+// Parents aren't sharded between replicas: there's a single shard, 0, which
+// is owned by the leader. The shard is still reported, in the debug endpoint
+// and the metrics, so dashboards and alerts don't have to change once parents
+// are sharded.
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coordinationv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+// LeaseName is the name of the Lease replicas compete for.
+const LeaseName = "metacontroller"
+
+var (
+	leaderGauge = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      "metacontroller",
+			Name:           "leader",
+			Help:           "Whether this replica is the leader, which runs the controllers: 1 if it is, 0 otherwise.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	shardOwned = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "metacontroller",
+			Name:           "shard_owned",
+			Help:           "Whether this replica owns the shard, and syncs its parents: 1 if it does, 0 otherwise.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"shard"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(leaderGauge, shardOwned)
+}
+
+// Options configures leader election.
+type Options struct {
+	// Enabled turns leader election on. Otherwise, this replica leads as
+	// soon as it starts.
+	Enabled bool
+	// Namespace is the namespace of the Lease.
+	Namespace string
+	// LeaseDuration is how long the other replicas wait, after the leader
+	// last renewed the Lease, before they try to take it over.
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader keeps trying to renew the Lease
+	// before it gives up leading.
+	RenewDeadline time.Duration
+	// RetryPeriod is how often replicas try to acquire or renew the Lease.
+	RetryPeriod time.Duration
+}
+
+var election = struct {
+	mutex    sync.RWMutex
+	options  Options
+	client   coordinationv1client.LeasesGetter
+	identity string
+	// leader is the identity of the last observed leader.
+	leader string
+	// acquired is when this replica started leading, if it leads without
+	// leader election.
+	acquired metav1.Time
+}{}
+
+// Identity returns the identity of this replica, which is its host name, so
+// the name of its Pod when it runs in a cluster.
+func Identity() string {
+	election.mutex.RLock()
+	defer election.mutex.RUnlock()
+	if election.identity != "" {
+		return election.identity
+	}
+	identity, _ := os.Hostname()
+	return identity
+}
+
+// Run calls start once this replica leads, then waits for ctx to be done and
+// calls the stop function start returned, before giving up the Lease so
+// another replica takes over right away. It returns an error if start fails,
+// or if this replica loses the Lease before ctx is done, since its
+// controllers must then stop at once.
+func Run(ctx context.Context, client coordinationv1client.LeasesGetter, options Options, start func() (stop func(), err error)) error {
+	identity, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("can't get identity of replica: %v", err)
+	}
+	election.mutex.Lock()
+	election.options = options
+	election.client = client
+	election.identity = identity
+	election.mutex.Unlock()
+	setLeading(false)
+
+	if !options.Enabled {
+		election.mutex.Lock()
+		election.leader = identity
+		election.acquired = metav1.Now()
+		election.mutex.Unlock()
+		setLeading(true)
+		stop, err := start()
+		if err != nil {
+			return err
+		}
+		<-ctx.Done()
+		stop()
+		return nil
+	}
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: options.Namespace, Name: LeaseName},
+			Client:     client,
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration:   options.LeaseDuration,
+		RenewDeadline:   options.RenewDeadline,
+		RetryPeriod:     options.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) { close(started) },
+			OnStoppedLeading: func() { close(stopped) },
+			OnNewLeader: func(leader string) {
+				klog.InfoS("New leader elected", "leader", leader, "identity", identity)
+				election.mutex.Lock()
+				defer election.mutex.Unlock()
+				election.leader = leader
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	// The Lease is only given up once the controllers are stopped, so the
+	// elector has its own context.
+	electionCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	klog.InfoS("Waiting to be elected leader", "lease", klog.KRef(options.Namespace, LeaseName), "identity", identity)
+	go elector.Run(electionCtx)
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-started:
+	}
+	klog.InfoS("Elected leader", "lease", klog.KRef(options.Namespace, LeaseName), "identity", identity)
+	setLeading(true)
+	stop, err := start()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		stop()
+		setLeading(false)
+		cancel()
+		<-stopped
+		return nil
+	case <-stopped:
+		setLeading(false)
+		return fmt.Errorf("lost lease %v/%v", options.Namespace, LeaseName)
+	}
+}
+
+// setLeading updates the metrics of whether this replica leads.
+func setLeading(leading bool) {
+	value := 0.0
+	if leading {
+		value = 1
+	}
+	leaderGauge.Set(value)
+	shardOwned.WithLabelValues("0").Set(value)
+}
+
+// Status is the report of the /debug/leader endpoint.
+type Status struct {
+	// Identity is the identity of this replica.
+	Identity string `json:"identity"`
+	// Leader is the identity of the last observed leader, if any.
+	Leader string `json:"leader"`
+	// Shards are the shards of parents, and the replicas that own them.
+	Shards []Shard `json:"shards"`
+	// Error is why the Lease couldn't be read, if it couldn't. The shards
+	// are then reported as this replica last observed them.
+	Error string `json:"error,omitempty"`
+}
+
+// Shard is a shard of parents, and the replica that owns it.
+type Shard struct {
+	Shard int `json:"shard"`
+	// Holder is the identity of the replica that owns the shard, if any.
+	Holder string `json:"holder"`
+	// Acquired is when the holder acquired the shard.
+	Acquired *metav1.Time `json:"acquired,omitempty"`
+	// Renewed is when the holder last renewed its Lease.
+	Renewed *metav1.Time `json:"renewed,omitempty"`
+}
+
+// ServeLeader serves the identity of this replica and of the leader, and
+// which replica owns which shard, as a Status.
+func ServeLeader(w http.ResponseWriter, r *http.Request) {
+	status := currentStatus()
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(status)
+}
+
+// currentStatus returns the Status of leader election, reading the Lease if
+// leader election is enabled.
+func currentStatus() Status {
+	election.mutex.RLock()
+	status := Status{
+		Identity: election.identity,
+		Leader:   election.leader,
+		Shards:   []Shard{{Shard: 0, Holder: election.leader}},
+	}
+	options, client, acquired := election.options, election.client, election.acquired
+	election.mutex.RUnlock()
+
+	if !options.Enabled {
+		if !acquired.IsZero() {
+			status.Shards[0].Acquired = &acquired
+		}
+		return status
+	}
+	lease, err := client.Leases(options.Namespace).Get(LeaseName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return status
+	}
+	if err != nil {
+		status.Error = fmt.Sprintf("can't get lease %v/%v: %v", options.Namespace, LeaseName, err)
+		return status
+	}
+	shard := &status.Shards[0]
+	shard.Holder = ""
+	if lease.Spec.HolderIdentity != nil {
+		shard.Holder = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.AcquireTime != nil {
+		shard.Acquired = &metav1.Time{Time: lease.Spec.AcquireTime.Time}
+	}
+	if lease.Spec.RenewTime != nil {
+		shard.Renewed = &metav1.Time{Time: lease.Spec.RenewTime.Time}
+	}
+	status.Leader = shard.Holder
+	return status
+}
@@ -0,0 +1,162 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testOptions = Options{
+	Enabled:       true,
+	Namespace:     "metacontroller",
+	LeaseDuration: time.Second,
+	RenewDeadline: 500 * time.Millisecond,
+	RetryPeriod:   100 * time.Millisecond,
+}
+
+// runInBackground runs Run until the returned cancel function is called,
+// and returns the channels that receive when start and stop are called, and
+// the result of Run.
+func runInBackground(t *testing.T, clientset *fake.Clientset, options Options, stop func()) (started <-chan struct{}, done <-chan error, cancel func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	startedCh := make(chan struct{})
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- Run(ctx, clientset.CoordinationV1(), options, func() (func(), error) {
+			close(startedCh)
+			return stop, nil
+		})
+	}()
+	t.Cleanup(cancel)
+	return startedCh, doneCh, cancel
+}
+
+func wait(t *testing.T, ch <-chan struct{}, what string) {
+	select {
+	case <-ch:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Timed out waiting for %v", what)
+	}
+}
+
+func TestRun_withoutElection(t *testing.T) {
+	stopped := make(chan struct{})
+	started, done, cancel := runInBackground(t, fake.NewSimpleClientset(), Options{}, func() { close(stopped) })
+	wait(t, started, "start")
+
+	identity, _ := os.Hostname()
+	status := currentStatus()
+	if status.Identity != identity || status.Leader != identity {
+		t.Errorf("currentStatus() = %+v, want %q as identity and leader", status, identity)
+	}
+	if len(status.Shards) != 1 || status.Shards[0].Holder != identity || status.Shards[0].Acquired == nil {
+		t.Errorf("currentStatus() shards = %+v, want shard 0 acquired by %q", status.Shards, identity)
+	}
+
+	cancel()
+	wait(t, stopped, "stop")
+	if err := <-done; err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+}
+
+func TestRun_election(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	identity, _ := os.Hostname()
+	var holderOnStop string
+	started, done, cancel := runInBackground(t, clientset, testOptions, func() {
+		// The Lease must still be held while controllers stop.
+		holderOnStop = leaseHolder(t, clientset)
+	})
+	wait(t, started, "election")
+
+	status := currentStatus()
+	if status.Leader != identity {
+		t.Errorf("currentStatus() leader = %q, want %q", status.Leader, identity)
+	}
+	if len(status.Shards) != 1 || status.Shards[0].Holder != identity || status.Shards[0].Acquired == nil || status.Shards[0].Renewed == nil {
+		t.Errorf("currentStatus() shards = %+v, want shard 0 held by %q", status.Shards, identity)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+	if holderOnStop != identity {
+		t.Errorf("Lease holder while stopping = %q, want %q", holderOnStop, identity)
+	}
+	if holder := leaseHolder(t, clientset); holder != "" {
+		t.Errorf("Lease holder after Run() = %q, want it released", holder)
+	}
+}
+
+func TestRun_lostLease(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	started, done, _ := runInBackground(t, clientset, testOptions, func() {
+		t.Error("Controllers were stopped gracefully after losing the Lease")
+	})
+	wait(t, started, "election")
+
+	// Another replica takes over the Lease, and keeps renewing it.
+	stopRenewing := make(chan struct{})
+	defer close(stopRenewing)
+	go func() {
+		for {
+			lease, err := clientset.CoordinationV1().Leases(testOptions.Namespace).Get(LeaseName, metav1.GetOptions{})
+			if err == nil {
+				other := "other"
+				now := metav1.NewMicroTime(time.Now())
+				lease.Spec.HolderIdentity = &other
+				lease.Spec.RenewTime = &now
+				clientset.CoordinationV1().Leases(testOptions.Namespace).Update(lease)
+			}
+			select {
+			case <-stopRenewing:
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Run() = nil, want an error after losing the Lease")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run() didn't return after losing the Lease")
+	}
+}
+
+func TestServeLeader(t *testing.T) {
+	started, _, _ := runInBackground(t, fake.NewSimpleClientset(), Options{}, func() {})
+	wait(t, started, "start")
+
+	w := httptest.NewRecorder()
+	ServeLeader(w, httptest.NewRequest("GET", "/debug/leader", nil))
+	var status Status
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("ServeLeader() = %q: %v", w.Body.String(), err)
+	}
+	if status.Identity == "" || status.Leader != status.Identity || len(status.Shards) != 1 {
+		t.Errorf("ServeLeader() = %+v, want this replica to lead shard 0", status)
+	}
+}
+
+// leaseHolder returns the holder of the Lease.
+func leaseHolder(t *testing.T, clientset *fake.Clientset) string {
+	lease, err := clientset.CoordinationV1().Leases(testOptions.Namespace).Get(LeaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Can't get Lease: %v", err)
+	}
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
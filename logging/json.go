@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+)
+
+// jsonLogger is a logr.Logger that writes one JSON object per line, like:
+//
+//	{"ts":"2021-01-02T03:04:05.678Z","v":4,"msg":"Sync","controller":"catset-controller"}
+//
+// Verbosity follows the -v and -vmodule flags of klog.
+type jsonLogger struct {
+	out *syncWriter
+	// level is the verbosity of the lines.
+	level int
+	name  string
+	// values are key/value pairs added to every line.
+	values []interface{}
+}
+
+type syncWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func newJSONLogger(w io.Writer) *jsonLogger {
+	return &jsonLogger{out: &syncWriter{w: w}}
+}
+
+func (l *jsonLogger) Enabled() bool {
+	return klog.V(klog.Level(l.level)).Enabled()
+}
+
+func (l *jsonLogger) Info(msg string, keysAndValues ...interface{}) {
+	if !l.Enabled() {
+		return
+	}
+	l.write(msg, nil, keysAndValues)
+}
+
+func (l *jsonLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.write(msg, err, keysAndValues)
+}
+
+func (l *jsonLogger) V(level int) logr.Logger {
+	clone := *l
+	clone.level += level
+	return &clone
+}
+
+func (l *jsonLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	clone := *l
+	clone.values = append(append([]interface{}{}, l.values...), keysAndValues...)
+	return &clone
+}
+
+func (l *jsonLogger) WithName(name string) logr.Logger {
+	clone := *l
+	if clone.name != "" {
+		name = clone.name + "." + name
+	}
+	clone.name = name
+	return &clone
+}
+
+func (l *jsonLogger) write(msg string, err error, keysAndValues []interface{}) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"ts":`)
+	writeJSON(buf, time.Now().UTC().Format(time.RFC3339Nano))
+	if err == nil {
+		buf.WriteString(`,"v":`)
+		writeJSON(buf, l.level)
+	}
+	if l.name != "" {
+		buf.WriteString(`,"logger":`)
+		writeJSON(buf, l.name)
+	}
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, msg)
+	if err != nil {
+		buf.WriteString(`,"err":`)
+		writeJSON(buf, err.Error())
+	}
+	writeKeysAndValues(buf, l.values)
+	writeKeysAndValues(buf, keysAndValues)
+	buf.WriteString("}\n")
+
+	l.out.mutex.Lock()
+	defer l.out.mutex.Unlock()
+	l.out.w.Write(buf.Bytes())
+}
+
+func writeKeysAndValues(buf *bytes.Buffer, keysAndValues []interface{}) {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		buf.WriteByte(',')
+		writeJSON(buf, fmt.Sprint(keysAndValues[i]))
+		buf.WriteByte(':')
+		writeJSON(buf, value)
+	}
+}
+
+// writeJSON writes value as JSON, or as a string if it can't be marshaled.
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", value))
+	}
+	buf.Write(data)
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/klog/v2"
+)
+
+func TestJSONLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := newJSONLogger(buf)
+	parentLog := ForParent(logger.WithValues("controller", "catset-controller"), "CatSet", klog.KRef("default", "nginx"))
+
+	parentLog.Info("Creating", "child", klog.KRef("default", "nginx-0"), "replicas", 3)
+	parentLog.Error(fmt.Errorf("boom"), "Can't sync", "odd")
+	parentLog.V(10).Info("Too verbose")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %v lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var info, errorLine map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &info); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[0], err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &errorLine); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[1], err)
+	}
+
+	wantInfo := map[string]interface{}{
+		"v":           float64(0),
+		"msg":         "Creating",
+		"controller":  "catset-controller",
+		"parent_kind": "CatSet",
+		"parent":      map[string]interface{}{"name": "nginx", "namespace": "default"},
+		"child":       map[string]interface{}{"name": "nginx-0", "namespace": "default"},
+		"replicas":    float64(3),
+	}
+	for key, want := range wantInfo {
+		if got, _ := json.Marshal(info[key]); string(got) != mustMarshal(t, want) {
+			t.Errorf("info line %v = %s, want %s", key, got, mustMarshal(t, want))
+		}
+	}
+	if errorLine["err"] != "boom" || errorLine["odd"] != "(MISSING)" {
+		t.Errorf("error line = %v, want err boom and odd (MISSING)", errorLine)
+	}
+}
+
+func mustMarshal(t *testing.T, value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestInitUnknownFormat(t *testing.T) {
+	if err := Init(Options{Format: "yaml"}); err == nil {
+		t.Errorf("Init() succeeded with an unknown format")
+	}
+}
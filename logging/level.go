@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"k8s.io/klog/v2"
+)
+
+// Verbosity returns the current log level, as set by the -v flag of klog.
+func Verbosity() (int, error) {
+	f := flag.Lookup("v")
+	if f == nil {
+		return 0, fmt.Errorf("klog flags aren't registered")
+	}
+	return strconv.Atoi(f.Value.String())
+}
+
+// SetVerbosity changes the log level, as if the -v flag of klog was set to
+// level.
+func SetVerbosity(level int) error {
+	if level < 0 {
+		return fmt.Errorf("invalid log level %v: must not be negative", level)
+	}
+	f := flag.Lookup("v")
+	if f == nil {
+		return fmt.Errorf("klog flags aren't registered")
+	}
+	return f.Value.Set(strconv.Itoa(level))
+}
+
+// ToggleVerbosityOnSIGHUP switches the log level to level on SIGHUP, and
+// back to the level it had before on the next SIGHUP, and so on.
+func ToggleVerbosityOnSIGHUP(level int) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		// toggledFrom is the log level to go back to on the next SIGHUP.
+		toggledFrom := 0
+		for range sighup {
+			current, err := Verbosity()
+			if err != nil {
+				klog.ErrorS(err, "Can't toggle log level")
+				continue
+			}
+			next := level
+			if current == level {
+				next = toggledFrom
+			} else {
+				toggledFrom = current
+			}
+			if err := SetVerbosity(next); err != nil {
+				klog.ErrorS(err, "Can't toggle log level")
+				continue
+			}
+			klog.InfoS("Changed log level", "from", current, "to", next, "signal", "SIGHUP")
+		}
+	}()
+}
+
+// LevelHandler serves the current log level on GET, and changes it on PUT to
+// the value of the v query parameter. Changes require the bearer token
+// token; they're refused if it's empty.
+func LevelHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			level, err := Verbosity()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprintln(w, level)
+		case http.MethodPut:
+			if token == "" {
+				http.Error(w, "changing the log level is disabled: set --debug-token-file to enable it", http.StatusForbidden)
+				return
+			}
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+				return
+			}
+			level, err := strconv.Atoi(r.URL.Query().Get("v"))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid log level %q", r.URL.Query().Get("v")), http.StatusBadRequest)
+				return
+			}
+			current, _ := Verbosity()
+			if err := SetVerbosity(level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			klog.InfoS("Changed log level", "from", current, "to", level, "remote", r.RemoteAddr)
+			fmt.Fprintln(w, level)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
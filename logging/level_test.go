@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/klog/v2"
+)
+
+func TestLevelHandler(t *testing.T) {
+	klog.InitFlags(nil)
+	defer SetVerbosity(0)
+
+	table := []struct {
+		name       string
+		token      string
+		method     string
+		url        string
+		auth       string
+		wantStatus int
+		wantLevel  int
+	}{
+		{
+			name:       "get",
+			method:     http.MethodGet,
+			url:        "/debug/log-level",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "change disabled",
+			method:     http.MethodPut,
+			url:        "/debug/log-level?v=4",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "wrong token",
+			token:      "secret",
+			method:     http.MethodPut,
+			url:        "/debug/log-level?v=4",
+			auth:       "Bearer guess",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid level",
+			token:      "secret",
+			method:     http.MethodPut,
+			url:        "/debug/log-level?v=loud",
+			auth:       "Bearer secret",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "change",
+			token:      "secret",
+			method:     http.MethodPut,
+			url:        "/debug/log-level?v=4",
+			auth:       "Bearer secret",
+			wantStatus: http.StatusOK,
+			wantLevel:  4,
+		},
+	}
+
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := SetVerbosity(0); err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(tc.method, tc.url, nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			w := httptest.NewRecorder()
+			LevelHandler(tc.token).ServeHTTP(w, req)
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %v, want %v: %v", w.Code, tc.wantStatus, strings.TrimSpace(w.Body.String()))
+			}
+			if got, err := Verbosity(); err != nil || got != tc.wantLevel {
+				t.Errorf("Verbosity() = %v, %v, want %v", got, err, tc.wantLevel)
+			}
+		})
+	}
+}
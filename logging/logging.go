@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/klogr"
+)
+
+const (
+	// FormatText logs in the text format of klog.
+	FormatText = "text"
+	// FormatJSON logs one JSON object per line.
+	FormatJSON = "json"
+)
+
+// Options configures the logging backend.
+type Options struct {
+	// Format is FormatText, the default, or FormatJSON.
+	Format string
+}
+
+var root logr.Logger = klogr.New()
+
+// Init selects the logging backend. Lines logged through klog, including by
+// client-go, go to the same backend.
+func Init(options Options) error {
+	switch options.Format {
+	case "", FormatText:
+		root = klogr.New()
+	case FormatJSON:
+		logger := newJSONLogger(os.Stderr)
+		klog.SetLogger(logger)
+		root = logger
+	default:
+		return fmt.Errorf("unknown log format %q: must be %q or %q", options.Format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// Logger returns the logger of Metacontroller.
+func Logger() logr.Logger {
+	return root
+}
+
+// ForController returns a logger whose lines are attributed to the
+// controller with the given kind and name.
+func ForController(kind, name string) logr.Logger {
+	return root.WithValues("controller_kind", kind, "controller", name)
+}
+
+// ForParent returns a logger, derived from that of its controller, whose
+// lines are attributed to parent.
+func ForParent(controllerLog logr.Logger, kind string, parent klog.ObjectRef) logr.Logger {
+	return controllerLog.WithValues("parent_kind", kind, "parent", parent)
+}
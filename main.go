@@ -19,9 +19,12 @@ package main
 import (
 	"context"
 	"flag"
+	"io/ioutil"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -30,33 +33,82 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/klog/v2"
 
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/component-base/metrics/legacyregistry"
 	_ "k8s.io/component-base/metrics/prometheus/clientgo"
+	_ "k8s.io/component-base/metrics/prometheus/workqueue"
 
+	"metacontroller.io/admission"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/hooks"
+	"metacontroller.io/leader"
+	"metacontroller.io/logging"
+	"metacontroller.io/notify"
 	"metacontroller.io/options"
 	"metacontroller.io/server"
+	"metacontroller.io/tracing"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 )
 
 var (
-	discoveryInterval = flag.Duration("discovery-interval", 30*time.Second, "How often to refresh discovery cache to pick up newly-installed resources")
-	informerRelist    = flag.Duration("cache-flush-interval", 30*time.Minute, "How often to flush local caches and relist objects from the API server")
-	debugAddr         = flag.String("debug-addr", ":9999", "The address to bind the debug http endpoints")
-	clientConfigPath  = flag.String("client-config-path", "", "Path to kubeconfig file (same format as used by kubectl); if not specified, use in-cluster config")
-	clientGoQPS       = flag.Float64("client-go-qps", 5, "Number of queries per second client-go is allowed to make (default 5)")
-	clientGoBurst     = flag.Int("client-go-burst", 10, "Allowed burst queries for client-go (default 10)")
-	workers           = flag.Int("workers", 5, "Number of sync workers to run (default 5)")
-	eventsQPS         = flag.Float64("events-qps", 1./300., "Rate of events flowing per object (default - 1 event per 5 minutes)")
-	eventsBurst       = flag.Int("events-burst", 25, "Number of events allowed to send per object (default 25)")
-	version           = "No version provided"
+	discoveryInterval   = flag.Duration("discovery-interval", 30*time.Second, "How often to refresh discovery cache to pick up newly-installed resources")
+	informerRelist      = flag.Duration("cache-flush-interval", 30*time.Minute, "How often to flush local caches and relist objects from the API server")
+	logFormat           = flag.String("log-format", logging.FormatText, "Format of the logs: 'text' for klog's text format, or 'json' for one JSON object per line")
+	sighupLogLevel      = flag.Int("sighup-log-level", 4, "Log level to switch to on SIGHUP, and back from on the next SIGHUP")
+	debugTokenFile      = flag.String("debug-token-file", "", "Path to a file holding the bearer token required to change the log level on the debug address; if empty, the log level can't be changed there")
+	debugAddr           = flag.String("debug-addr", ":9999", "The address to bind the debug http endpoints")
+	clientConfigPath    = flag.String("client-config-path", "", "Path to kubeconfig file (same format as used by kubectl); if not specified, use in-cluster config")
+	clientGoQPS         = flag.Float64("client-go-qps", 5, "Number of queries per second client-go is allowed to make (default 5)")
+	clientGoBurst       = flag.Int("client-go-burst", 10, "Allowed burst queries for client-go (default 10)")
+	workers             = flag.Int("workers", 5, "Number of sync workers to run (default 5)")
+	eventsQPS           = flag.Float64("events-qps", 1./300., "Rate of events flowing per object (default - 1 event per 5 minutes)")
+	eventsBurst         = flag.Int("events-burst", 25, "Number of events allowed to send per object (default 25)")
+	allowedChildKinds   = flag.String("allowed-child-kinds", "", "Comma-separated list of kinds (e.g. 'Deployment.apps,ConfigMap') controllers may declare as children or attachments; if empty, all kinds are allowed")
+	forbiddenChildKinds = flag.String("forbidden-child-kinds", "", "Comma-separated list of kinds (e.g. 'ClusterRoleBinding.rbac.authorization.k8s.io') controllers may not declare as children or attachments")
+	hookNamespaces      = flag.String("hook-reference-namespaces", "", "Comma-separated list of namespaces in which controllers may reference Secrets for their hooks; if empty, only the namespace metacontroller runs in")
+	serviceAccount      = flag.String("service-account", "", "The '<namespace>/<name>' of the ServiceAccount metacontroller runs as, used to request audience-bound tokens for hooks")
+	hookTokenAudiences  = flag.String("hook-token-audiences", "", "Comma-separated list of audiences hooks may request ServiceAccount tokens for; if empty, ServiceAccount token authorization is disabled")
+	admissionAddr       = flag.String("admission-addr", "", "The address to serve admission webhooks for validate hooks on; if empty, validate hooks are disabled")
+	admissionCertFile   = flag.String("admission-cert-file", "", "Path to the TLS certificate used to serve admission webhooks")
+	admissionKeyFile    = flag.String("admission-key-file", "", "Path to the TLS private key used to serve admission webhooks")
+	admissionCAFile     = flag.String("admission-ca-file", "", "Path to the PEM encoded CA bundle the API server uses to verify the admission webhook certificate")
+	admissionService    = flag.String("admission-service", "", "The '<namespace>/<name>' of the Service through which the API server reaches the admission webhook address")
+	enablePreview       = flag.Bool("enable-preview", false, "Serve previews of the changes controllers would make to children, and diffs between revisions of parents, on the debug address; this exposes the contents of children and calls sync hooks")
+	notifyURL           = flag.String("notify-url", "", "URL of an HTTP endpoint to POST notifications to when parents keep failing to sync; if empty, notifications are disabled")
+	notifyAfterFailures = flag.Int("notify-after-failures", 10, "Notify when a parent failed to sync this many times in a row; 0 disables these notifications")
+	notifyFailing       = flag.Int("notify-failing-parents", 0, "Notify when this many parents of a controller are failing to sync at the same time; 0 disables these notifications")
+	notifyTimeout       = flag.Duration("notify-timeout", 10*time.Second, "How long to wait for the notification endpoint to respond")
+	orphanAuditInterval = flag.Duration("orphan-audit-interval", time.Hour, "How often each controller looks for children whose parent no longer exists; 0 disables the audit")
+	orphanCleanup       = flag.Bool("orphan-cleanup", false, "Delete the orphaned children found by the audit, instead of only reporting them")
+	quarantineAfter     = flag.Int("quarantine-after", 0, "Quarantine a parent after its sync crashed or timed out the hook this many times in a row within --quarantine-window; 0 disables the quarantine")
+	quarantineWindow    = flag.Duration("quarantine-window", 10*time.Minute, "How long after the first crash of a parent's sync the following ones count towards --quarantine-after; 0 counts them all")
+	quarantineInterval  = flag.Duration("quarantine-interval", 30*time.Minute, "How often to retry the sync of a quarantined parent")
+	maxConcurrentSyncs  = flag.Int("max-concurrent-syncs", 0, "Number of syncs all controllers can run at the same time, shared fairly between controllers; 0 means no limit besides --workers per controller")
+	enableCacheSizes    = flag.Bool("enable-cache-sizes", false, "Report the approximate memory used by each informer cache on /debug/cache; this encodes every cached object on each request")
+	enableProfiling     = flag.Bool("enable-profiling", false, "Serve pprof profiles on the debug address under /debug/pprof/, with CPU samples labeled by controller and hook")
+	deadLetterAfter     = flag.Int("dead-letter-after", 0, "Stop retrying the sync of a parent after it failed this many times in a row, until its dead-letter annotation is removed; 0 retries forever")
+	leaderElect         = flag.Bool("leader-elect", false, "Elect a leader among the replicas through a Lease, and only run controllers on the leader, so several replicas can run for availability")
+	leaderElectNS       = flag.String("leader-elect-namespace", "metacontroller", "Namespace of the Lease used for leader election")
+	leaseDuration       = flag.Duration("leader-elect-lease-duration", 15*time.Second, "How long the other replicas wait, after the leader last renewed its Lease, before they try to take over")
+	renewDeadline       = flag.Duration("leader-elect-renew-deadline", 10*time.Second, "How long the leader keeps trying to renew its Lease before it stops leading")
+	retryPeriod         = flag.Duration("leader-elect-retry-period", 2*time.Second, "How often replicas try to acquire or renew the Lease")
+	tracingEndpoint     = flag.String("tracing-endpoint", "", "Base URL of the OTLP/HTTP receiver of an OpenTelemetry collector (e.g. 'http://otel-collector:4318') to export traces of syncs and hook calls to; if empty, tracing is disabled")
+	tracingSampleRatio  = flag.Float64("tracing-sample-ratio", 1, "Share of syncs to trace, from 0 to 1, when tracing is enabled")
+	version             = "No version provided"
 )
 
 func main() {
 	klog.InitFlags(nil)
 	flag.Parse()
+	if err := logging.Init(logging.Options{Format: *logFormat}); err != nil {
+		klog.ErrorS(err, "Terminating")
+		os.Exit(1)
+	}
 
 	klog.InfoS("Discovery cache flush interval", "discovery_interval", *discoveryInterval)
 	klog.InfoS("API server object cache flush interval", "cache_flush_interval", *informerRelist)
@@ -78,6 +130,37 @@ func main() {
 		os.Exit(1)
 	}
 
+	serviceAccountNamespace, serviceAccountName, err := cache.SplitMetaNamespaceKey(*serviceAccount)
+	if err != nil {
+		klog.ErrorS(err, "Terminating")
+		os.Exit(1)
+	}
+
+	admissionServiceNamespace, admissionServiceName, err := cache.SplitMetaNamespaceKey(*admissionService)
+	if err != nil {
+		klog.ErrorS(err, "Terminating")
+		os.Exit(1)
+	}
+	var admissionCABundle []byte
+	if *admissionCAFile != "" {
+		admissionCABundle, err = ioutil.ReadFile(*admissionCAFile)
+		if err != nil {
+			klog.ErrorS(err, "Terminating")
+			os.Exit(1)
+		}
+	}
+
+	var debugToken string
+	if *debugTokenFile != "" {
+		data, err := ioutil.ReadFile(*debugTokenFile)
+		if err != nil {
+			klog.ErrorS(err, "Terminating")
+			os.Exit(1)
+		}
+		debugToken = strings.TrimSpace(string(data))
+	}
+	logging.ToggleVerbosityOnSIGHUP(*sighupLogLevel)
+
 	config.QPS = float32(*clientGoQPS)
 	config.Burst = *clientGoBurst
 
@@ -90,16 +173,81 @@ func main() {
 			BurstSize: *eventsBurst,
 			QPS:       float32(*eventsQPS),
 		},
+		ChildKindPolicy: common.ChildKindPolicy{
+			Allowed:   common.ParseGroupKinds(*allowedChildKinds),
+			Forbidden: common.ParseGroupKinds(*forbiddenChildKinds),
+		},
+		HookReferenceNamespaces: hooks.ParseReferenceNamespaces(*hookNamespaces),
+		ServiceAccount: types.NamespacedName{
+			Namespace: serviceAccountNamespace,
+			Name:      serviceAccountName,
+		},
+		HookTokenAudiences: hooks.ParseTokenAudiences(*hookTokenAudiences),
+		Admission: admission.Options{
+			Addr:     *admissionAddr,
+			CertFile: *admissionCertFile,
+			KeyFile:  *admissionKeyFile,
+			Service: types.NamespacedName{
+				Namespace: admissionServiceNamespace,
+				Name:      admissionServiceName,
+			},
+			CABundle: admissionCABundle,
+		},
+		Notify: notify.Options{
+			URL:            *notifyURL,
+			ParentFailures: *notifyAfterFailures,
+			FailingParents: *notifyFailing,
+			Timeout:        *notifyTimeout,
+		},
+		OrphanAudit: common.OrphanAuditOptions{
+			Interval: *orphanAuditInterval,
+			Cleanup:  *orphanCleanup,
+		},
+		Quarantine: common.QuarantineOptions{
+			After:    *quarantineAfter,
+			Window:   *quarantineWindow,
+			Interval: *quarantineInterval,
+		},
+		SyncSlots: common.SyncSlotOptions{
+			MaxConcurrentSyncs: *maxConcurrentSyncs,
+		},
+		DeadLetters: common.DeadLetterOptions{
+			After: *deadLetterAfter,
+		},
+		Tracing: tracing.Options{
+			Endpoint:        *tracingEndpoint,
+			SampleRatio:     *tracingSampleRatio,
+			ServiceInstance: leader.Identity(),
+		},
+	}
+
+	mux := http.NewServeMux()
+	options.DebugMux = mux
+	options.CacheSizes = *enableCacheSizes
+	if *enablePreview {
+		options.PreviewMux = mux
 	}
 
-	stopServer, err := server.Start(options)
+	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		klog.ErrorS(err, "Terminating")
 		os.Exit(1)
 	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.HandlerFor(legacyregistry.DefaultGatherer, promhttp.HandlerOpts{}))
+	mux.Handle("/metrics", promhttp.HandlerFor(legacyregistry.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	mux.HandleFunc("/readyz", common.ServeReadyz)
+	mux.HandleFunc("/debug/orphans", common.ServeOrphans)
+	mux.HandleFunc("/debug/quarantine", common.ServeQuarantine)
+	mux.HandleFunc("/debug/dead-letters", common.ServeDeadLetters)
+	mux.HandleFunc("/debug/leader", leader.ServeLeader)
+	mux.Handle("/debug/log-level", logging.LevelHandler(debugToken))
+	if *enableProfiling {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
 	srv := &http.Server{
 		Addr:    *debugAddr,
 		Handler: mux,
@@ -109,11 +257,29 @@ func main() {
 	}()
 
 	// On SIGTERM, stop all controllers gracefully.
+	ctx, cancel := context.WithCancel(context.Background())
 	sigchan := make(chan os.Signal, 2)
 	signal.Notify(sigchan, os.Interrupt, syscall.SIGTERM)
-	sig := <-sigchan
-	klog.InfoS("Shutting down...", "signal", sig)
+	go func() {
+		sig := <-sigchan
+		klog.InfoS("Shutting down...", "signal", sig)
+		cancel()
+	}()
 
-	stopServer()
+	// Only run controllers while this replica leads.
+	leaderOptions := leader.Options{
+		Enabled:       *leaderElect,
+		Namespace:     *leaderElectNS,
+		LeaseDuration: *leaseDuration,
+		RenewDeadline: *renewDeadline,
+		RetryPeriod:   *retryPeriod,
+	}
+	err = leader.Run(ctx, clientset.CoordinationV1(), leaderOptions, func() (func(), error) {
+		return server.Start(options)
+	})
 	srv.Shutdown(context.Background())
+	if err != nil {
+		klog.ErrorS(err, "Terminating")
+		os.Exit(1)
+	}
 }
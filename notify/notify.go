@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ReasonParentFailing is sent when a parent's sync failed
+	// Options.ParentFailures times in a row.
+	ReasonParentFailing = "ParentFailing"
+	// ReasonControllerFailing is sent when Options.FailingParents parents of
+	// a controller are failing at the same time.
+	ReasonControllerFailing = "ControllerFailing"
+)
+
+// Options configures the failure notification sink.
+type Options struct {
+	// URL is where notifications are POSTed.
+	// Notifications are disabled if it's empty.
+	URL string
+	// ParentFailures is the number of consecutive sync failures of a parent
+	// after which a notification is sent. Zero disables them.
+	ParentFailures int
+	// FailingParents is the number of failing parents of a controller after
+	// which a notification is sent. Zero disables them.
+	FailingParents int
+	// Timeout is how long to wait for the sink to respond.
+	Timeout time.Duration
+}
+
+// Notification is the payload POSTed to the sink, as JSON.
+type Notification struct {
+	Reason     string     `json:"reason"`
+	Controller Controller `json:"controller"`
+	// Parent is the parent that failed, or the last one that did
+	// for ReasonControllerFailing.
+	Parent Parent `json:"parent"`
+	// Failures is the number of consecutive sync failures of Parent.
+	Failures int `json:"failures"`
+	// FailingParents is the number of failing parents of the controller.
+	FailingParents int `json:"failingParents"`
+	// Error is the last sync error of Parent.
+	Error string `json:"error"`
+}
+
+type Controller struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type Parent struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+var sink struct {
+	mutex   sync.RWMutex
+	options Options
+	client  *http.Client
+}
+
+// Init configures the sink notifications are sent to.
+func Init(options Options) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	sink.options = options
+	sink.client = &http.Client{Timeout: options.Timeout}
+}
+
+// SyncFailed notifies the sink, if a threshold was just reached, that parent
+// failed to sync failures times in a row, and that failingParents parents of
+// the controller are now failing.
+func SyncFailed(controllerKind, controller string, parent *unstructured.Unstructured, failures, failingParents int, syncErr error) {
+	sink.mutex.RLock()
+	options, client := sink.options, sink.client
+	sink.mutex.RUnlock()
+	if options.URL == "" {
+		return
+	}
+
+	notification := Notification{
+		Controller: Controller{Kind: controllerKind, Name: controller},
+		Parent: Parent{
+			APIVersion: parent.GetAPIVersion(),
+			Kind:       parent.GetKind(),
+			Namespace:  parent.GetNamespace(),
+			Name:       parent.GetName(),
+		},
+		Failures:       failures,
+		FailingParents: failingParents,
+		Error:          syncErr.Error(),
+	}
+	// Each threshold is only notified when it's reached, not while it stays
+	// exceeded, so a broken parent doesn't page again on every retry.
+	if options.ParentFailures > 0 && failures == options.ParentFailures {
+		notification.Reason = ReasonParentFailing
+		go send(client, options.URL, notification)
+	}
+	if options.FailingParents > 0 && failures == 1 && failingParents == options.FailingParents {
+		notification.Reason = ReasonControllerFailing
+		go send(client, options.URL, notification)
+	}
+}
+
+func send(client *http.Client, url string, notification Notification) {
+	if err := post(client, url, notification); err != nil {
+		klog.ErrorS(err, "Can't send failure notification", "reason", notification.Reason, "controller", notification.Controller.Name)
+	}
+}
+
+func post(client *http.Client, url string, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("can't marshal notification: %v", err)
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("remote error: %s: %s", resp.Status, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSyncFailed(t *testing.T) {
+	received := make(chan Notification, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Errorf("can't decode notification: %v", err)
+		}
+		received <- notification
+	}))
+	defer srv.Close()
+
+	Init(Options{URL: srv.URL, ParentFailures: 3, FailingParents: 2, Timeout: time.Second})
+	defer Init(Options{})
+
+	parent := &unstructured.Unstructured{}
+	parent.SetAPIVersion("example.com/v1")
+	parent.SetKind("Thing")
+	parent.SetNamespace("ns")
+	parent.SetName("test")
+	syncErr := errors.New("hook failed")
+
+	table := []struct {
+		name                     string
+		failures, failingParents int
+		want                     []string
+	}{
+		{name: "below thresholds", failures: 1, failingParents: 1},
+		{name: "parent threshold", failures: 3, failingParents: 1, want: []string{ReasonParentFailing}},
+		{name: "past parent threshold", failures: 4, failingParents: 1},
+		{name: "controller threshold", failures: 1, failingParents: 2, want: []string{ReasonControllerFailing}},
+		{name: "past controller threshold", failures: 1, failingParents: 3},
+		{name: "controller threshold on retry", failures: 2, failingParents: 2},
+	}
+
+	for _, tc := range table {
+		SyncFailed("CompositeController", "test", parent, tc.failures, tc.failingParents, syncErr)
+		var got []string
+		for range tc.want {
+			select {
+			case notification := <-received:
+				got = append(got, notification.Reason)
+				if notification.Parent.Name != "test" || notification.Error != "hook failed" {
+					t.Errorf("%v: got notification %+v", tc.name, notification)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%v: timed out waiting for notification", tc.name)
+			}
+		}
+		select {
+		case notification := <-received:
+			got = append(got, notification.Reason)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if len(got) != len(tc.want) || (len(got) > 0 && got[0] != tc.want[0]) {
+			t.Errorf("%v: got notifications %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPostError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := post(srv.Client(), srv.URL, Notification{Reason: ReasonParentFailing}); err == nil {
+		t.Errorf("post() = nil, want error")
+	}
+}
@@ -1,10 +1,17 @@
 package options
 
 import (
+	"net/http"
 	"time"
 
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
+
+	"metacontroller.io/admission"
+	"metacontroller.io/controller/common"
+	"metacontroller.io/notify"
+	"metacontroller.io/tracing"
 )
 
 type Options struct {
@@ -13,4 +20,28 @@ type Options struct {
 	InformerRelist    time.Duration
 	Workers           int
 	CorrelatorOptions record.CorrelatorOptions
+	ChildKindPolicy   common.ChildKindPolicy
+	// HookReferenceNamespaces are the namespaces controllers may reference
+	// Secrets in for their hooks.
+	HookReferenceNamespaces []string
+	ServiceAccount          types.NamespacedName
+	// HookTokenAudiences are the audiences hooks may request ServiceAccount
+	// tokens for.
+	HookTokenAudiences []string
+	Admission          admission.Options
+	// PreviewMux, if set, serves previews of what controllers would do, and
+	// the differences between revisions of parents.
+	PreviewMux *http.ServeMux
+	// DebugMux, if set, serves debug endpoints about the state of the
+	// server, such as the size of its caches.
+	DebugMux *http.ServeMux
+	// CacheSizes enables reporting the size of the caches on DebugMux,
+	// which encodes every cached object on each request.
+	CacheSizes  bool
+	Notify      notify.Options
+	OrphanAudit common.OrphanAuditOptions
+	Quarantine  common.QuarantineOptions
+	DeadLetters common.DeadLetterOptions
+	SyncSlots   common.SyncSlotOptions
+	Tracing     tracing.Options
 }
@@ -27,14 +27,22 @@ import (
 	"metacontroller.io/options"
 
 	"k8s.io/client-go/discovery"
+	"metacontroller.io/admission"
 	"metacontroller.io/apis/metacontroller/v1alpha1"
 	mcclientset "metacontroller.io/client/generated/clientset/internalclientset"
 	mcinformers "metacontroller.io/client/generated/informer/externalversions"
+	"metacontroller.io/controller/common"
 	"metacontroller.io/controller/composite"
+	"metacontroller.io/controller/event"
+	"metacontroller.io/controller/status"
+	"metacontroller.io/controller/watch"
 	dynamicclientset "metacontroller.io/dynamic/clientset"
 	dynamicdiscovery "metacontroller.io/dynamic/discovery"
 	dynamicinformer "metacontroller.io/dynamic/informer"
 	"metacontroller.io/events"
+	"metacontroller.io/hooks"
+	"metacontroller.io/notify"
+	"metacontroller.io/tracing"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 )
@@ -58,6 +66,8 @@ func Start(options options.Options) (stop func(), err error) {
 	resources := dynamicdiscovery.NewResourceMap(dc)
 	// We don't care about stopping this cleanly since it has no external effects.
 	resources.Start(options.DiscoveryInterval)
+	// Stay unready until discovery has synced.
+	common.InitReadiness(resources.HasSynced)
 
 	// Create informer factory for metacontroller API objects.
 	mcClient, err := mcclientset.NewForConfig(options.Config)
@@ -73,6 +83,33 @@ func Start(options options.Options) (stop func(), err error) {
 	}
 	// Create dynamic informer factory (for sharing dynamic informers).
 	dynInformers := dynamicinformer.NewSharedInformerFactory(dynClient, options.InformerRelist)
+	if options.DebugMux != nil {
+		options.DebugMux.Handle("/debug/cache", dynInformers.CacheStatsHandler(options.CacheSizes))
+	}
+
+	// Allow hooks to read Secrets referenced in their specs (e.g. CA bundles),
+	// and to request tokens for metacontroller's own ServiceAccount.
+	if err := hooks.Init(options.Config, options.HookReferenceNamespaces, options.ServiceAccount, options.HookTokenAudiences); err != nil {
+		return nil, err
+	}
+
+	// Send notifications about failing parents, if enabled.
+	notify.Init(options.Notify)
+
+	// Look for orphaned children, if enabled.
+	common.InitOrphanAudit(options.OrphanAudit)
+
+	// Quarantine parents that keep crashing their hooks, if enabled.
+	common.InitQuarantine(options.Quarantine)
+
+	// Stop retrying parents that keep failing to sync, if enabled.
+	common.InitDeadLetters(options.DeadLetters)
+
+	// Share sync slots fairly between controllers, if limited.
+	common.InitSyncSlots(options.SyncSlots)
+
+	// Trace syncs and hook calls, if enabled.
+	stopTracing := tracing.Init(options.Tracing)
 
 	// Start metacontrollers (controllers that spawn controllers).
 	// Each one requests the informers it needs from the factory.
@@ -81,9 +118,33 @@ func Start(options options.Options) (stop func(), err error) {
 		return nil, err
 	}
 	recorder := broadcaster.NewRecorder(scheme, corev1.EventSource{Component: "metacontroller"})
+	broadcasters, err := events.NewBroadcasters(options.Config, scheme, options.CorrelatorOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Serve admission webhooks for validate hooks, if enabled.
+	var admissionServer *admission.Server
+	if options.Admission.Addr != "" {
+		admissionServer, err = admission.NewServer(options.Config, options.Admission)
+		if err != nil {
+			return nil, err
+		}
+		admissionServer.Start()
+	}
+
+	compositeMetacontroller := composite.NewMetacontroller(resources, dynClient, dynInformers, mcInformerFactory, mcClient, options.Workers, recorder, broadcasters, options.ChildKindPolicy, admissionServer)
+	if options.PreviewMux != nil {
+		options.PreviewMux.Handle("/debug/preview/compositecontroller", compositeMetacontroller)
+		options.PreviewMux.HandleFunc("/debug/revisions/diff", compositeMetacontroller.ServeRevisionDiff)
+	}
+
 	controllers := []controller{
-		composite.NewMetacontroller(resources, dynClient, dynInformers, mcInformerFactory, mcClient, options.Workers, recorder),
-		decorator.NewMetacontroller(resources, dynClient, dynInformers, mcInformerFactory, options.Workers, recorder),
+		compositeMetacontroller,
+		decorator.NewMetacontroller(resources, dynClient, dynInformers, mcInformerFactory, options.Workers, recorder, broadcasters, options.ChildKindPolicy),
+		status.NewMetacontroller(resources, dynClient, dynInformers, mcInformerFactory, options.Workers, recorder),
+		watch.NewMetacontroller(resources, dynInformers, mcInformerFactory, options.Workers, recorder),
+		event.NewMetacontroller(dynClient, mcInformerFactory, options.Workers, recorder),
 	}
 
 	// Start all requested informers.
@@ -106,6 +167,10 @@ func Start(options options.Options) (stop func(), err error) {
 			}(c)
 		}
 		wg.Wait()
+		if admissionServer != nil {
+			admissionServer.Stop()
+		}
+		stopTracing()
 		time.Sleep(1 * time.Second)
 		broadcaster.Shutdown()
 	}, nil
@@ -0,0 +1,221 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// exportInterval is how often spans are exported.
+	exportInterval = 5 * time.Second
+	// maxBatchSize is how many spans are exported at most in one request.
+	maxBatchSize = 512
+	// maxQueueSize is how many spans wait at most to be exported. Spans
+	// that end while the queue is full are dropped.
+	maxQueueSize = 4096
+)
+
+// exporter exports spans to the OTLP/HTTP receiver of a collector.
+type exporter struct {
+	url      string
+	client   *http.Client
+	resource otlpResource
+	spans    chan *Span
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func newExporter(options Options) *exporter {
+	resource := otlpResource{Attributes: []otlpAttribute{newAttribute("service.name", "metacontroller")}}
+	if options.ServiceInstance != "" {
+		resource.Attributes = append(resource.Attributes, newAttribute("service.instance.id", options.ServiceInstance))
+	}
+	return &exporter{
+		url:      strings.TrimSuffix(options.Endpoint, "/") + "/v1/traces",
+		client:   &http.Client{Timeout: 10 * time.Second},
+		resource: resource,
+		spans:    make(chan *Span, maxQueueSize),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// export queues span to be exported, or drops it if the queue is full.
+func (e *exporter) export(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+		klog.V(4).InfoS("Dropping span, export queue is full", "span", span.name)
+	}
+}
+
+// run exports the queued spans every exportInterval, or as soon as a batch
+// is full, until stop is called.
+func (e *exporter) run() {
+	defer close(e.doneCh)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			klog.V(2).InfoS("Can't export spans", "url", e.url, "spans", len(batch), "err", err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= maxBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stopCh:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// stop exports the queued spans and stops the exporter.
+func (e *exporter) stop() {
+	e.stopOnce.Do(func() { close(e.stopCh) })
+	<-e.doneCh
+}
+
+// send exports spans in one request.
+func (e *exporter) send(spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%v: %s", resp.Status, message)
+	}
+	return nil
+}
+
+// request returns the OTLP request that exports spans.
+func (e *exporter) request(spans []*Span) otlpRequest {
+	converted := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		converted = append(converted, span.otlp())
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "metacontroller"}, Spans: converted}},
+	}}}
+}
+
+// The types below are the JSON encoding of the OTLP
+// ExportTraceServiceRequest, limited to the fields Metacontroller sets.
+// IDs are hex encoded, and 64-bit integers are strings.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code"`
+}
+
+// Status codes, as numbered by OTLP.
+const (
+	statusCodeOK    = 1
+	statusCodeError = 2
+)
+
+func newAttribute(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
+
+// otlp returns the OTLP encoding of s.
+func (s *Span) otlp() otlpSpan {
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: statusCodeOK},
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for _, a := range s.attributes {
+		span.Attributes = append(span.Attributes, newAttribute(a.key, a.value))
+	}
+	if s.err != nil {
+		span.Status = otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+	}
+	return span
+}
@@ -0,0 +1,171 @@
+// Package tracing traces the syncs of parents and the hook calls they make,
+// and exports the spans to an OpenTelemetry collector, so slow syncs can be
+// followed from the buckets of the latency histograms, through exemplars, to
+// their traces.
+//
+// Spans are exported with the OTLP/HTTP protocol, JSON encoded, rather than
+// through the OpenTelemetry SDK, whose releases need newer dependencies than
+// Metacontroller builds with. Hooks that are webhooks get the trace context
+// in a W3C traceparent header, so their own spans join the trace of the sync.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand"
+	"sync"
+	"time"
+)
+
+// Options configures tracing.
+type Options struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver of an OpenTelemetry
+	// collector (e.g. http://otel-collector:4318). Tracing is disabled if
+	// it's empty.
+	Endpoint string
+	// SampleRatio is the share of syncs that are traced, from 0 to 1.
+	SampleRatio float64
+	// ServiceInstance identifies this replica of Metacontroller in the
+	// exported spans.
+	ServiceInstance string
+}
+
+var tracer = struct {
+	mutex    sync.RWMutex
+	options  Options
+	exporter *exporter
+}{}
+
+// Init starts exporting spans, if options enable tracing, and returns a
+// function that exports the remaining spans and stops.
+func Init(options Options) (stop func()) {
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+
+	tracer.options = options
+	if options.Endpoint == "" {
+		tracer.exporter = nil
+		return func() {}
+	}
+	tracer.exporter = newExporter(options)
+	go tracer.exporter.run()
+	return tracer.exporter.stop
+}
+
+// Span is a traced operation, such as a sync or a hook call.
+// A nil Span is a span that isn't traced, whose methods do nothing.
+type Span struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	kind       int
+	attributes []attribute
+	start, end time.Time
+	err        error
+}
+
+type attribute struct {
+	key, value string
+}
+
+// Span kinds, as numbered by OTLP.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+)
+
+// Start starts a root span with the given name and attributes, as key-value
+// pairs, if tracing is enabled and the span is sampled. Otherwise, it
+// returns nil.
+func Start(name string, attributes ...string) *Span {
+	tracer.mutex.RLock()
+	enabled := tracer.exporter != nil
+	ratio := tracer.options.SampleRatio
+	tracer.mutex.RUnlock()
+	if !enabled || mathrand.Float64() >= ratio {
+		return nil
+	}
+	span := newSpan(name, spanKindInternal, attributes)
+	rand.Read(span.traceID[:])
+	return span
+}
+
+// StartChild starts a span for a call to another service, such as a hook,
+// in the trace of s. It returns nil if s is nil.
+func (s *Span) StartChild(name string, attributes ...string) *Span {
+	if s == nil {
+		return nil
+	}
+	span := newSpan(name, spanKindClient, attributes)
+	span.traceID = s.traceID
+	span.parentID = s.spanID
+	return span
+}
+
+func newSpan(name string, kind int, attributes []string) *Span {
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	rand.Read(span.spanID[:])
+	for i := 0; i+1 < len(attributes); i += 2 {
+		span.attributes = append(span.attributes, attribute{key: attributes[i], value: attributes[i+1]})
+	}
+	return span
+}
+
+// End ends s, which failed if err isn't nil, and exports it.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	tracer.mutex.RLock()
+	exporter := tracer.exporter
+	tracer.mutex.RUnlock()
+	if exporter != nil {
+		exporter.export(s)
+	}
+}
+
+// TraceID returns the hex-encoded trace ID of s, or "" if s is nil.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// TraceParent returns the W3C traceparent header that makes the spans of
+// the callee children of s, or "" if s is nil.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-01"
+}
+
+// active holds the span of the sync each parent is in, by the key given to
+// SetActive, so the hook calls of the sync can join its trace.
+var active = struct {
+	mutex sync.Mutex
+	spans map[string]*Span
+}{spans: make(map[string]*Span)}
+
+// SetActive records span as the one of the operation in progress for key,
+// such as the sync of a parent by a controller, or forgets it if span is nil.
+func SetActive(key string, span *Span) {
+	active.mutex.Lock()
+	defer active.mutex.Unlock()
+	if span == nil {
+		delete(active.spans, key)
+		return
+	}
+	active.spans[key] = span
+}
+
+// Active returns the span recorded for key, or nil if there's none.
+func Active(key string) *Span {
+	active.mutex.Lock()
+	defer active.mutex.Unlock()
+	return active.spans[key]
+}
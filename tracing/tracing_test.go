@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestStartDisabled(t *testing.T) {
+	table := []struct {
+		name    string
+		options Options
+	}{
+		{name: "no endpoint", options: Options{SampleRatio: 1}},
+		{name: "not sampled", options: Options{Endpoint: "http://127.0.0.1:0", SampleRatio: 0}},
+	}
+	for _, tc := range table {
+		stop := Init(tc.options)
+		span := Start("sync")
+		stop()
+		if span != nil {
+			t.Errorf("%v: Start() = %v, want nil", tc.name, span)
+		}
+		// A span that isn't traced can still be used.
+		child := span.StartChild("sync hook")
+		child.End(nil)
+		span.End(nil)
+		if got := child.TraceParent(); got != "" {
+			t.Errorf("%v: TraceParent() = %q, want empty", tc.name, got)
+		}
+	}
+	Init(Options{})
+}
+
+func TestSpanTraceParent(t *testing.T) {
+	stop := Init(Options{Endpoint: "http://127.0.0.1:0", SampleRatio: 1})
+	defer Init(Options{})
+	defer stop()
+
+	span := Start("sync")
+	child := span.StartChild("sync hook")
+	if child.TraceID() != span.TraceID() {
+		t.Errorf("child TraceID() = %q, want %q", child.TraceID(), span.TraceID())
+	}
+	traceParent := regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-01$`)
+	match := traceParent.FindStringSubmatch(child.TraceParent())
+	if match == nil {
+		t.Fatalf("TraceParent() = %q, want W3C traceparent", child.TraceParent())
+	}
+	if match[1] != span.TraceID() {
+		t.Errorf("TraceParent() trace ID = %q, want %q", match[1], span.TraceID())
+	}
+	if match[2] == fmt.Sprintf("%x", span.spanID) {
+		t.Errorf("TraceParent() span ID = %q, want the child's", match[2])
+	}
+}
+
+func TestExport(t *testing.T) {
+	requests := make(chan otlpRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("path = %q, want /v1/traces", r.URL.Path)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		var request otlpRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("can't unmarshal request: %v", err)
+		}
+		requests <- request
+	}))
+	defer srv.Close()
+
+	stop := Init(Options{Endpoint: srv.URL + "/", SampleRatio: 1, ServiceInstance: "metacontroller-0"})
+	defer Init(Options{})
+	span := Start("sync", "metacontroller.controller", "catset-controller")
+	child := span.StartChild("sync hook")
+	child.End(fmt.Errorf("hook failed"))
+	span.End(nil)
+	stop()
+
+	request := <-requests
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("request = %+v, want one resource and scope", request)
+	}
+	resource := request.ResourceSpans[0].Resource
+	if len(resource.Attributes) != 2 || resource.Attributes[1].Value.StringValue != "metacontroller-0" {
+		t.Errorf("resource attributes = %+v, want service name and instance", resource.Attributes)
+	}
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("spans = %+v, want 2", spans)
+	}
+	hook, sync := spans[0], spans[1]
+	if sync.TraceID != span.TraceID() || hook.TraceID != span.TraceID() {
+		t.Errorf("trace IDs = %q, %q; want %q", sync.TraceID, hook.TraceID, span.TraceID())
+	}
+	if sync.ParentSpanID != "" || hook.ParentSpanID != sync.SpanID {
+		t.Errorf("parent span IDs = %q, %q; want none and %q", sync.ParentSpanID, hook.ParentSpanID, sync.SpanID)
+	}
+	if sync.Status.Code != statusCodeOK || hook.Status.Code != statusCodeError || hook.Status.Message != "hook failed" {
+		t.Errorf("statuses = %+v, %+v; want ok and error", sync.Status, hook.Status)
+	}
+	if len(sync.Attributes) != 1 || sync.Attributes[0].Key != "metacontroller.controller" || sync.Attributes[0].Value.StringValue != "catset-controller" {
+		t.Errorf("sync attributes = %+v", sync.Attributes)
+	}
+}
+
+func TestActive(t *testing.T) {
+	stop := Init(Options{Endpoint: "http://127.0.0.1:0", SampleRatio: 1})
+	defer Init(Options{})
+	defer stop()
+
+	span := Start("sync")
+	SetActive("CompositeController/catset-controller/uid", span)
+	if got := Active("CompositeController/catset-controller/uid"); got != span {
+		t.Errorf("Active() = %v, want %v", got, span)
+	}
+	if got := Active("CompositeController/other-controller/uid"); got != nil {
+		t.Errorf("Active() of other key = %v, want nil", got)
+	}
+	SetActive("CompositeController/catset-controller/uid", nil)
+	if got := Active("CompositeController/catset-controller/uid"); got != nil {
+		t.Errorf("Active() after forgetting = %v, want nil", got)
+	}
+}